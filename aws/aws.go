@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -17,6 +19,8 @@ import (
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/aws/aws-sdk-go/service/opsworks"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/aws/aws-sdk-go/service/servicequotas/servicequotasiface"
 	sh "github.com/codeskyblue/go-sh"
 	"github.com/libopenstorage/cloudops"
 	"github.com/libopenstorage/cloudops/backoff"
@@ -36,11 +40,41 @@ const (
 	awsDevicePrefixNvme          = "/dev/nvme"
 	contextTimeout               = 30 * time.Second
 	awsErrorModificationNotFound = "InvalidVolumeModification.NotFound"
+	// gp3 volumes provision 3000 IOPS unless IOPS are set explicitly
+	gp3BaselineIops = 3000
+	gp3MaxIops      = 16000
+	// maximum IOPS per GiB of provisioned IOPS volume types
+	gp3MaxIopsPerGiB = 500
+	io1MaxIopsPerGiB = 50
+	io2MaxIopsPerGiB = 500
+	ioMaxIops        = 64000
+	// gp3 throughput limits in MiB/s. Throughput is further limited to a
+	// quarter of the provisioned IOPS.
+	gp3MinThroughput = 125
+	gp3MaxThroughput = 1000
 	// Standard aws credential constants
 	awsAccessKeyName       = "AWS_ACCESS_KEY_ID"
 	awsSecretAccessKeyName = "AWS_SECRET_ACCESS_KEY"
+	// ephemeralDevicePrefix is the prefix of the instance store volumes in the
+	// block device mapping of the instance metadata, e.g. ephemeral0
+	ephemeralDevicePrefix = "ephemeral"
+	// bounds of the page size of DescribeVolumes
+	describeVolumesMinPageSize = 5
+	describeVolumesMaxPageSize = 500
 )
 
+// instanceRefreshRetryInterval is the interval at which the status of an
+// instance refresh is polled
+var instanceRefreshRetryInterval = 30 * time.Second
+
+// volumeModificationBackoff is the backoff at which the state of a volume
+// modification is polled. Taken from k8s.io/legacy-cloud-providers/aws
+var volumeModificationBackoff = wait.Backoff{
+	Duration: 1 * time.Second,
+	Factor:   2,
+	Steps:    10,
+}
+
 // For unit testing purpose
 type ec2Wrapper struct {
 	Client ec2iface.EC2API
@@ -55,6 +89,8 @@ type awsOps struct {
 	outpostARN   string
 	ec2          *ec2Wrapper
 	autoscaling  *autoscaling.AutoScaling
+	quotas       servicequotasiface.ServiceQuotasAPI
+	opsTimeout   cloudops.OpsTimeoutConfig
 	mutex        sync.Mutex
 }
 
@@ -66,6 +102,27 @@ var (
 
 // NewClient creates a new cloud operations client for AWS
 func NewClient(k8sSecretName, k8sSecretNamespace string) (cloudops.Ops, error) {
+	return NewClientWithOpsTimeout(k8sSecretName, k8sSecretNamespace, cloudops.DefaultOpsTimeoutConfig())
+}
+
+// NewClientWithOpsTimeout creates a new cloud operations client for AWS which
+// waits for volume state transitions as configured by opsTimeout
+func NewClientWithOpsTimeout(
+	k8sSecretName, k8sSecretNamespace string,
+	opsTimeout cloudops.OpsTimeoutConfig,
+) (cloudops.Ops, error) {
+	return NewClientWithBackoff(k8sSecretName, k8sSecretNamespace, opsTimeout, nil)
+}
+
+// NewClientWithBackoff creates a new cloud operations client for AWS which
+// waits for volume state transitions as configured by opsTimeout and retries
+// throttled requests as configured by backoffConfig. The retries default to
+// backoff.DefaultExponentialBackoff if backoffConfig is nil.
+func NewClientWithBackoff(
+	k8sSecretName, k8sSecretNamespace string,
+	opsTimeout cloudops.OpsTimeoutConfig,
+	backoffConfig *backoff.Config,
+) (cloudops.Ops, error) {
 	runningOnEc2 := true
 	zone, instanceID, instanceType, outpostARN, err := getInfoFromMetadata()
 	if err != nil {
@@ -131,7 +188,16 @@ func NewClient(k8sSecretName, k8sSecretNamespace string) (cloudops.Ops, error) {
 		),
 	)
 
-	return backoff.NewExponentialBackoffOps(
+	quotas := servicequotas.New(
+		session.New(
+			&aws.Config{
+				Region:      &region,
+				Credentials: creds,
+			},
+		),
+	)
+
+	return backoff.NewProviderExponentialBackoffOps(
 		&awsOps{
 			Compute:      unsupported.NewUnsupportedCompute(),
 			instance:     instanceID,
@@ -140,11 +206,73 @@ func NewClient(k8sSecretName, k8sSecretNamespace string) (cloudops.Ops, error) {
 			zone:         zone,
 			region:       region,
 			autoscaling:  autoscaling,
+			quotas:       quotas,
 			outpostARN:   outpostARN,
+			opsTimeout:   opsTimeout,
 		},
 		isExponentialError,
-		backoff.DefaultExponentialBackoff,
-	), nil
+		backoffConfig,
+		0,
+	)
+}
+
+// RollInstanceGroup starts an instance refresh of the given ASG and waits for
+// it to complete. ASG instance refreshes do not support surge settings, the
+// pace of the roll is controlled by MinHealthyPercentage.
+func (s *awsOps) RollInstanceGroup(instanceGroupID string, opts cloudops.RollOpts) error {
+	input := &autoscaling.StartInstanceRefreshInput{
+		AutoScalingGroupName: aws.String(instanceGroupID),
+		Strategy:             aws.String(autoscaling.RefreshStrategyRolling),
+	}
+	if opts.MinHealthyPercentage > 0 {
+		input.Preferences = &autoscaling.RefreshPreferences{
+			MinHealthyPercentage: aws.Int64(opts.MinHealthyPercentage),
+		}
+	}
+
+	output, err := s.autoscaling.StartInstanceRefresh(input)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Started instance refresh [%s] of instance group [%s]",
+		aws.StringValue(output.InstanceRefreshId), instanceGroupID)
+	return s.waitForInstanceRefresh(instanceGroupID, aws.StringValue(output.InstanceRefreshId), opts.Timeout)
+}
+
+func (s *awsOps) waitForInstanceRefresh(instanceGroupID, instanceRefreshID string, timeout time.Duration) error {
+	input := &autoscaling.DescribeInstanceRefreshesInput{
+		AutoScalingGroupName: aws.String(instanceGroupID),
+		InstanceRefreshIds:   []*string{aws.String(instanceRefreshID)},
+	}
+
+	f := func() (interface{}, bool, error) {
+		output, err := s.autoscaling.DescribeInstanceRefreshes(input)
+		if err != nil {
+			return nil, true, err
+		}
+
+		if len(output.InstanceRefreshes) != 1 {
+			return nil, true, fmt.Errorf("DescribeInstanceRefreshes (%v) returned %v refreshes, expect 1",
+				instanceRefreshID, len(output.InstanceRefreshes))
+		}
+
+		status := aws.StringValue(output.InstanceRefreshes[0].Status)
+		switch status {
+		case autoscaling.InstanceRefreshStatusSuccessful:
+			return nil, false, nil
+		case autoscaling.InstanceRefreshStatusFailed,
+			autoscaling.InstanceRefreshStatusCancelled:
+			return nil, false, fmt.Errorf("instance refresh [%s] of instance group [%s] is in [%s] state: %s",
+				instanceRefreshID, instanceGroupID, status,
+				aws.StringValue(output.InstanceRefreshes[0].StatusReason))
+		}
+		return nil, true, fmt.Errorf("instance refresh [%s] of instance group [%s] is in [%s] state. Waiting to become %s",
+			instanceRefreshID, instanceGroupID, status, autoscaling.InstanceRefreshStatusSuccessful)
+	}
+
+	_, err := task.DoRetryWithTimeout(f, timeout, instanceRefreshRetryInterval)
+	return err
 }
 
 func (s *awsOps) filters(
@@ -215,8 +343,8 @@ func (s *awsOps) waitStatus(id string, desired string) error {
 				id, desired, actual)
 
 		},
-		cloudops.ProviderOpsTimeout,
-		cloudops.ProviderOpsRetryInterval)
+		s.opsTimeout.OpsTimeout(),
+		s.opsTimeout.OpsRetryInterval())
 
 	return err
 
@@ -224,6 +352,7 @@ func (s *awsOps) waitStatus(id string, desired string) error {
 
 func (s *awsOps) waitAttachmentStatus(
 	volumeID string,
+	instanceID string,
 	desired string,
 	timeout time.Duration,
 ) (*ec2.Volume, error) {
@@ -242,16 +371,8 @@ func (s *awsOps) waitAttachmentStatus(
 				volumeID, len(awsVols.Volumes))
 		}
 
-		var actual string
 		vol := awsVols.Volumes[0]
-		awsAttachment := vol.Attachments
-		if awsAttachment == nil || len(awsAttachment) == 0 {
-			// We have encountered scenarios where AWS returns a nil attachment state
-			// for a volume transitioning from detaching -> attaching.
-			actual = ec2.VolumeAttachmentStateDetached
-		} else {
-			actual = *awsAttachment[0].State
-		}
+		actual := attachmentState(vol, instanceID)
 		if actual == desired {
 			return vol, false, nil
 		}
@@ -270,6 +391,30 @@ func (s *awsOps) waitAttachmentStatus(
 		fmt.Sprintf("Invalid volume object for volume %s", volumeID), "")
 }
 
+// attachmentState returns the state of the attachment of the volume to the
+// given instance. A multi-attach volume can be attached to other instances
+// while its attachment to this one is still attaching.
+func attachmentState(vol *ec2.Volume, instanceID string) string {
+	if attachment := localAttachment(vol, instanceID); attachment != nil && attachment.State != nil {
+		return *attachment.State
+	}
+	// We have encountered scenarios where AWS returns a nil attachment state
+	// for a volume transitioning from detaching -> attaching.
+	return ec2.VolumeAttachmentStateDetached
+}
+
+// localAttachment returns the attachment of the volume to the given instance,
+// or nil if the volume is not attached to it. A multi-attach volume has one
+// attachment per instance it is attached to.
+func localAttachment(vol *ec2.Volume, instanceID string) *ec2.VolumeAttachment {
+	for _, attachment := range vol.Attachments {
+		if aws.StringValue(attachment.InstanceId) == instanceID {
+			return attachment
+		}
+	}
+	return nil
+}
+
 func (s *awsOps) Name() string { return string(cloudops.AWS) }
 
 func (s *awsOps) InstanceID() string { return s.instance }
@@ -298,6 +443,31 @@ func (s *awsOps) InspectInstance(instanceID string) (*cloudops.InstanceInfo, err
 	return instInfo, nil
 }
 
+func (s *awsOps) GetNetworkInfo(instanceID string) (*cloudops.NetworkInfo, error) {
+	inst, err := DescribeInstanceByID(s.ec2, instanceID)
+	if err != nil {
+		return nil, err
+	}
+	return networkInfoFromInstance(inst), nil
+}
+
+// GetInstanceGroupZones returns the availability zones of the autoscaling group
+func (s *awsOps) GetInstanceGroupZones(instanceGroupID string) ([]string, error) {
+	result, err := s.autoscaling.DescribeAutoScalingGroups(&autoscaling.DescribeAutoScalingGroupsInput{
+		AutoScalingGroupNames: []*string{aws.String(instanceGroupID)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.AutoScalingGroups) != 1 {
+		return nil, fmt.Errorf("DescribeAutoScalingGroups (%v) returned %v groups, expect 1",
+			instanceGroupID, len(result.AutoScalingGroups))
+	}
+
+	return aws.StringValueSlice(result.AutoScalingGroups[0].AvailabilityZones), nil
+}
+
 func (s *awsOps) InspectInstanceGroupForInstance(instanceID string) (*cloudops.InstanceGroupInfo, error) {
 	selfInfo, err := s.InspectInstance(instanceID)
 	if err != nil {
@@ -353,6 +523,10 @@ func (s *awsOps) InspectInstanceGroupForInstance(instanceID string) (*cloudops.I
 }
 
 func (s *awsOps) ApplyTags(volumeID string, labels map[string]string, options map[string]string) error {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return err
+	}
+
 	req := &ec2.CreateTagsInput{
 		Resources: []*string{&volumeID},
 		Tags:      s.tags(labels),
@@ -363,6 +537,10 @@ func (s *awsOps) ApplyTags(volumeID string, labels map[string]string, options ma
 }
 
 func (s *awsOps) RemoveTags(volumeID string, labels map[string]string, options map[string]string) error {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return err
+	}
+
 	req := &ec2.DeleteTagsInput{
 		Resources: []*string{&volumeID},
 		Tags:      s.tags(labels),
@@ -584,20 +762,19 @@ func (s *awsOps) FreeDevices() ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
-	rootDeviceName := *self.RootDeviceName
-
-	var blockDeviceMappings = make([]interface{}, len(self.BlockDeviceMappings))
-	for i, b := range self.BlockDeviceMappings {
-		blockDeviceMappings[i] = b
+	instanceStoreDevices, err := instanceStoreDeviceNames()
+	if err != nil {
+		return nil, err
 	}
+	return s.freeDevices(aws.StringValue(self.RootDeviceName), self.BlockDeviceMappings, instanceStoreDevices)
+}
 
-	freeLetterTracker := []byte("fghijklmnop")
-	devNamesInUse := make(map[string]string) // used as a set, values not used
-
-	// We also need to fetch ephemeral device mappings as they are not populated
-	// in blockDeviceMappings
-	// See bottom of this page:
-	// https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/block-device-mapping-concepts.html?icmpid=docs_ec2_console#instance-block-device-mapping
+// instanceStoreDeviceNames returns the device names of the instance store
+// (ephemeral) volumes of the instance. They are not listed in the block device
+// mappings returned by DescribeInstances, only in the instance metadata.
+// See bottom of this page:
+// https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/block-device-mapping-concepts.html?icmpid=docs_ec2_console#instance-block-device-mapping
+func instanceStoreDeviceNames() ([]string, error) {
 	c, err := GetMetadataInstance()
 	if err != nil {
 		return nil, err
@@ -607,9 +784,11 @@ func (s *awsOps) FreeDevices() ([]string, error) {
 		return nil, err
 	}
 
-	devices := strings.Split(mappingsFromMetadata, "\n")
-	for _, device := range devices {
-		if device == "root" || device == "ami" {
+	var devNames []string
+	for _, device := range strings.Split(mappingsFromMetadata, "\n") {
+		// EBS volumes, including the root and the AMI volumes, are already
+		// listed in the block device mappings of the instance
+		if !strings.HasPrefix(device, ephemeralDevicePrefix) {
 			continue
 		}
 
@@ -617,7 +796,23 @@ func (s *awsOps) FreeDevices() ([]string, error) {
 		if err != nil {
 			return nil, err
 		}
+		devNames = append(devNames, devName)
+	}
+	return devNames, nil
+}
+
+// freeDevices returns the device names in the /dev/sd[f-p] range which are
+// neither used by the block device mappings nor by the instance store devices
+// of the instance, using the same prefix as the root device
+func (s *awsOps) freeDevices(
+	rootDeviceName string,
+	blockDeviceMappings []*ec2.InstanceBlockDeviceMapping,
+	instanceStoreDevices []string,
+) ([]string, error) {
+	freeLetterTracker := []byte("fghijklmnop")
+	devNamesInUse := make(map[string]string) // used as a set, values not used
 
+	for _, devName := range instanceStoreDevices {
 		if !strings.HasPrefix(devName, "/dev/") {
 			devName = "/dev/" + devName
 		}
@@ -626,9 +821,7 @@ func (s *awsOps) FreeDevices() ([]string, error) {
 	}
 
 	devPrefix := awsDevicePrefix
-	for _, d := range blockDeviceMappings {
-		dev := d.(*ec2.InstanceBlockDeviceMapping)
-
+	for _, dev := range blockDeviceMappings {
 		if dev.DeviceName == nil {
 			return nil, fmt.Errorf("Nil device name")
 		}
@@ -679,7 +872,7 @@ func (s *awsOps) FreeDevices() ([]string, error) {
 	// The reason we do this is based on the virtualization type AWS might attach
 	// the device "sda" at /dev/sda OR /dev/xvda. So we look at how the root device
 	// is attached and use that prefix
-	devPrefix, err = s.getPrefixFromRootDeviceName(rootDeviceName)
+	devPrefix, err := s.getPrefixFromRootDeviceName(rootDeviceName)
 	if err != nil {
 		return nil, err
 	}
@@ -698,6 +891,69 @@ func (s *awsOps) FreeDevices() ([]string, error) {
 	return reverse(free[:count]), nil
 }
 
+// NextFreeDeviceName returns the first free device name, in the /dev/sd[f-p]
+// range, on the instance using the same prefix as the root device
+func (s *awsOps) NextFreeDeviceName() (string, error) {
+	self, err := s.describe()
+	if err != nil {
+		return "", err
+	}
+	instanceStoreDevices, err := instanceStoreDeviceNames()
+	if err != nil {
+		return "", err
+	}
+	return s.nextFreeDeviceName(aws.StringValue(self.RootDeviceName), self.BlockDeviceMappings, instanceStoreDevices)
+}
+
+func (s *awsOps) nextFreeDeviceName(
+	rootDeviceName string,
+	blockDeviceMappings []*ec2.InstanceBlockDeviceMapping,
+	instanceStoreDevices []string,
+) (string, error) {
+	devPrefix, err := s.getPrefixFromRootDeviceName(rootDeviceName)
+	if err != nil {
+		return "", err
+	}
+
+	devNames := make([]string, 0, len(blockDeviceMappings)+len(instanceStoreDevices))
+	for _, d := range blockDeviceMappings {
+		devNames = append(devNames, aws.StringValue(d.DeviceName))
+	}
+	for _, devName := range instanceStoreDevices {
+		if !strings.HasPrefix(devName, "/dev/") {
+			devName = "/dev/" + devName
+		}
+		devNames = append(devNames, devName)
+	}
+
+	lettersInUse := make(map[byte]bool)
+	for _, devName := range devNames {
+		if len(devName) == 0 || devName == rootDeviceName {
+			continue
+		}
+
+		letter := devName
+		for _, prefix := range []string{awsDevicePrefix, awsDevicePrefixWithX, awsDevicePrefixWithH} {
+			if strings.HasPrefix(devName, prefix) {
+				letter = strings.TrimPrefix(devName, prefix)
+				break
+			}
+		}
+		// We do not attach EBS volumes with "/dev/xvdc[a-z]" formats
+		if len(letter) == 1 {
+			lettersInUse[letter[0]] = true
+		}
+	}
+
+	for letter := byte('f'); letter <= 'p'; letter++ {
+		if !lettersInUse[letter] {
+			return devPrefix + string(letter), nil
+		}
+	}
+	return "", cloudops.NewStorageError(cloudops.ErrNoAttachSlotAvailable,
+		fmt.Sprintf("no free device names available on instance %s", s.instance), s.instance)
+}
+
 func (s *awsOps) rollbackCreate(id string, createErr error) error {
 	logrus.Warnf("Rollback create volume %v, Error %v", id, createErr)
 	err := s.Delete(id, nil)
@@ -737,6 +993,15 @@ func (s *awsOps) available(v *ec2.Volume) bool {
 	return *v.State == ec2.VolumeStateAvailable
 }
 
+// CloneVolume is not supported as EBS volumes can only be created from
+// snapshots.
+func (s *awsOps) CloneVolume(sourceVolumeID string, template interface{}, labels map[string]string) (interface{}, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "CloneVolume",
+		Reason:    "EBS volumes can only be created from snapshots",
+	}
+}
+
 func (s *awsOps) GetDeviceID(vol interface{}) (string, error) {
 	if d, ok := vol.(*ec2.Volume); ok {
 		return *d.VolumeId, nil
@@ -748,6 +1013,10 @@ func (s *awsOps) GetDeviceID(vol interface{}) (string, error) {
 }
 
 func (s *awsOps) Inspect(volumeIds []*string, options map[string]string) ([]interface{}, error) {
+	if err := cloudops.ValidateVolumeIDs(volumeIds); err != nil {
+		return nil, err
+	}
+
 	req := &ec2.DescribeVolumesInput{
 		VolumeIds: volumeIds,
 		DryRun:    dryRun(options),
@@ -765,7 +1034,57 @@ func (s *awsOps) Inspect(volumeIds []*string, options map[string]string) ([]inte
 	return awsVols, nil
 }
 
+// BatchInspect returns the given volumes keyed by volume ID. The volumes are
+// listed with a single paged DescribeVolumes call filtered by volume ID.
+func (s *awsOps) BatchInspect(volumeIds []*string) (map[string]interface{}, error) {
+	if err := cloudops.ValidateVolumeIDs(volumeIds); err != nil {
+		return nil, err
+	}
+
+	vols := make(map[string]interface{}, len(volumeIds))
+	if len(volumeIds) == 0 {
+		return vols, nil
+	}
+
+	found := make(map[string]*ec2.Volume, len(volumeIds))
+	req := &ec2.DescribeVolumesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("volume-id"),
+				Values: volumeIds,
+			},
+		},
+	}
+	for {
+		resp, err := s.ec2.Client.DescribeVolumes(req)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range resp.Volumes {
+			found[aws.StringValue(v.VolumeId)] = v
+		}
+		if resp.NextToken == nil || len(*resp.NextToken) == 0 {
+			break
+		}
+		req.NextToken = resp.NextToken
+	}
+
+	for _, id := range volumeIds {
+		v, ok := found[aws.StringValue(id)]
+		if !ok {
+			return nil, cloudops.NewStorageError(cloudops.ErrVolNotFound,
+				fmt.Sprintf("volume %s not found", aws.StringValue(id)), s.instance)
+		}
+		vols[aws.StringValue(id)] = v
+	}
+	return vols, nil
+}
+
 func (s *awsOps) Tags(volumeID string) (map[string]string, error) {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return nil, err
+	}
+
 	vol, err := s.refreshVol(&volumeID)
 	if err != nil {
 		return nil, err
@@ -784,6 +1103,10 @@ func (s *awsOps) Enumerate(
 	setIdentifier string,
 
 ) (map[string][]interface{}, error) {
+	if err := cloudops.ValidateVolumeIDs(volumeIds); err != nil {
+		return nil, err
+	}
+
 	sets := make(map[string][]interface{})
 
 	// Enumerate all volumes that have same labels.
@@ -819,6 +1142,36 @@ func (s *awsOps) Enumerate(
 	return sets, nil
 }
 
+func (s *awsOps) ListVolumesPaged(labels map[string]string, pageSize int) (cloudops.VolumeIterator, error) {
+	req := &ec2.DescribeVolumesInput{Filters: s.filters(labels, nil)}
+	if pageSize > 0 {
+		if pageSize < describeVolumesMinPageSize {
+			pageSize = describeVolumesMinPageSize
+		} else if pageSize > describeVolumesMaxPageSize {
+			pageSize = describeVolumesMaxPageSize
+		}
+		req.MaxResults = aws.Int64(int64(pageSize))
+	}
+
+	return cloudops.NewVolumeIterator(func() ([]interface{}, bool, error) {
+		resp, err := s.ec2.Client.DescribeVolumes(req)
+		if err != nil {
+			return nil, false, err
+		}
+		vols := make([]interface{}, 0, len(resp.Volumes))
+		for _, vol := range resp.Volumes {
+			if !s.deleted(vol) {
+				vols = append(vols, vol)
+			}
+		}
+		if resp.NextToken == nil || len(*resp.NextToken) == 0 {
+			return vols, false, nil
+		}
+		req.NextToken = resp.NextToken
+		return vols, true, nil
+	}), nil
+}
+
 func (s *awsOps) Create(
 	v interface{},
 	labels map[string]string,
@@ -834,15 +1187,43 @@ func (s *awsOps) Create(
 			"Drive type not specified in the storage spec", "")
 	}
 
+	multiAttach, err := cloudops.MultiAttachRequested(options)
+	if err != nil {
+		return nil, err
+	}
+	if multiAttach {
+		// don't modify the caller's template
+		template := *vol
+		template.MultiAttachEnabled = aws.Bool(true)
+		vol = &template
+	}
+
+	if err := validatePerformance(vol); err != nil {
+		return nil, err
+	}
+
+	encryption, err := cloudops.EncryptionRequested(options)
+	if err != nil {
+		return nil, err
+	}
+
 	req := &ec2.CreateVolumeInput{
-		AvailabilityZone: vol.AvailabilityZone,
-		Encrypted:        vol.Encrypted,
-		KmsKeyId:         vol.KmsKeyId,
-		Size:             vol.Size,
-		VolumeType:       vol.VolumeType,
-		SnapshotId:       vol.SnapshotId,
-		Throughput:       vol.Throughput,
-		DryRun:           dryRun(options),
+		AvailabilityZone:   vol.AvailabilityZone,
+		Encrypted:          vol.Encrypted,
+		KmsKeyId:           vol.KmsKeyId,
+		Size:               vol.Size,
+		VolumeType:         vol.VolumeType,
+		SnapshotId:         vol.SnapshotId,
+		Throughput:         vol.Throughput,
+		MultiAttachEnabled: vol.MultiAttachEnabled,
+		DryRun:             dryRun(options),
+	}
+
+	if encryption.Encrypted {
+		req.Encrypted = aws.Bool(true)
+	}
+	if len(encryption.KmsKeyID) > 0 {
+		req.KmsKeyId = aws.String(encryption.KmsKeyID)
 	}
 
 	if len(s.outpostARN) > 0 {
@@ -874,13 +1255,14 @@ func (s *awsOps) Create(
 	}
 
 	// note, as of 2021-05-04, `opsworks` does not have `const VolumeTypeGp3 = gp3`  (using RAW format)
-	if *vol.VolumeType == opsworks.VolumeTypeIo1 || *vol.VolumeType == "gp3" {
+	switch *vol.VolumeType {
+	case opsworks.VolumeTypeIo1, "io2", "gp3":
 		req.Iops = vol.Iops
 	}
 
 	resp, err := s.ec2.Client.CreateVolume(req)
 	if err != nil {
-		return nil, err
+		return nil, encryptionKeyError(err, aws.StringValue(req.KmsKeyId))
 	}
 	if err = s.waitStatus(
 		*resp.VolumeId,
@@ -891,11 +1273,176 @@ func (s *awsOps) Create(
 	return s.refreshVol(resp.VolumeId)
 }
 
+// encryptionKeyError returns an ErrInvalidEncryptionKey error if err reports
+// that the KMS key given to create a volume cannot be used, else err as is.
+func encryptionKeyError(err error, kmsKeyID string) error {
+	awsErr, ok := err.(awserr.Error)
+	if !ok {
+		return err
+	}
+	code := strings.ToLower(awsErr.Code())
+	switch {
+	case strings.HasPrefix(code, "invalidkmskey"), code == "kmskeynotaccessiblefault":
+	case (code == "invalidparameter" || code == "invalidparametervalue") &&
+		strings.Contains(strings.ToLower(awsErr.Message()), "kms"):
+	default:
+		return err
+	}
+	return cloudops.NewStorageError(cloudops.ErrInvalidEncryptionKey,
+		fmt.Sprintf("invalid encryption key %s: %v", kmsKeyID, awsErr.Message()), "")
+}
+
+// validatePerformance checks the IOPS and throughput requested by a volume
+// template against the limits of its volume type. Throughput can only be
+// provisioned on gp3 volumes.
+func validatePerformance(vol *ec2.Volume) error {
+	if aws.BoolValue(vol.MultiAttachEnabled) && !isProvisionedIopsType(*vol.VolumeType) {
+		return cloudops.NewStorageError(cloudops.ErrInvalidStorageRequest,
+			fmt.Sprintf("multi-attach is only supported for io1 and io2 volumes, not %s", *vol.VolumeType), "")
+	}
+	if vol.Size != nil {
+		if err := validateIopsRatio(*vol.VolumeType, vol.Iops, *vol.Size); err != nil {
+			return err
+		}
+	}
+	if vol.Throughput != nil && *vol.VolumeType != "gp3" {
+		return cloudops.NewStorageError(cloudops.ErrVolInval,
+			fmt.Sprintf("throughput cannot be configured for volume type %s", *vol.VolumeType), "")
+	}
+	if *vol.VolumeType != "gp3" {
+		return nil
+	}
+
+	iops := int64(gp3BaselineIops)
+	if vol.Iops != nil {
+		iops = *vol.Iops
+		if iops > gp3MaxIops {
+			return cloudops.NewStorageError(cloudops.ErrVolInval,
+				fmt.Sprintf("requested iops %d exceeds the gp3 maximum of %d", iops, gp3MaxIops), "")
+		}
+	}
+	if vol.Throughput != nil {
+		return validateGp3Throughput(*vol.Throughput, iops)
+	}
+	return nil
+}
+
+// isProvisionedIopsType returns true for the io1 and io2 volume types
+func isProvisionedIopsType(volumeType string) bool {
+	return volumeType == opsworks.VolumeTypeIo1 || volumeType == "io2"
+}
+
+// validateIopsRatio checks that the IOPS of a provisioned IOPS volume do not
+// exceed the IOPS-to-GiB ratio enforced by EC2 for the given size. io2 Block
+// Express volumes allow up to 500 IOPS per GiB.
+func validateIopsRatio(volumeType string, iops *int64, sizeInGiB int64) error {
+	if iops == nil || !isProvisionedIopsType(volumeType) {
+		return nil
+	}
+	if maxIops := maxIopsForSize(volumeType, sizeInGiB); *iops > maxIops {
+		return cloudops.NewStorageError(cloudops.ErrInvalidStorageRequest,
+			fmt.Sprintf("requested iops %d exceeds the maximum of %d allowed for a %d GiB %s volume",
+				*iops, maxIops, sizeInGiB, volumeType), "")
+	}
+	return nil
+}
+
+// validateGp3Throughput checks that throughput is within the gp3 limits for
+// a volume provisioned with the given IOPS.
+func validateGp3Throughput(throughput, iops int64) error {
+	maxThroughput := gp3MaxThroughputForIops(iops)
+	if throughput < gp3MinThroughput || throughput > maxThroughput {
+		return cloudops.NewStorageError(cloudops.ErrVolInval,
+			fmt.Sprintf("requested throughput %d is not in range [%d, %d] for gp3 volume with iops %d",
+				throughput, gp3MinThroughput, maxThroughput, iops), "")
+	}
+	return nil
+}
+
+// gp3MaxThroughputForIops returns the maximum throughput of a gp3 volume
+// provisioned with the given IOPS
+func gp3MaxThroughputForIops(iops int64) int64 {
+	maxThroughput := iops / 4
+	if maxThroughput > gp3MaxThroughput {
+		maxThroughput = gp3MaxThroughput
+	}
+	return maxThroughput
+}
+
+// maxIopsForSize returns the maximum IOPS of a volume of the given type and
+// size, or 0 if IOPS cannot be provisioned for the volume type
+func maxIopsForSize(volumeType string, sizeInGiB int64) int64 {
+	var perGiB, limit int64
+	switch volumeType {
+	case "gp3":
+		perGiB, limit = gp3MaxIopsPerGiB, gp3MaxIops
+	case opsworks.VolumeTypeIo1:
+		perGiB, limit = io1MaxIopsPerGiB, ioMaxIops
+	case "io2":
+		perGiB, limit = io2MaxIopsPerGiB, ioMaxIops
+	default:
+		return 0
+	}
+	if perGiB*sizeInGiB < limit {
+		return perGiB * sizeInGiB
+	}
+	return limit
+}
+
+// scalePerformance returns the IOPS and throughput which keep the per-GiB
+// performance of the volume when it is expanded to the given size, clamped to
+// the limits of the volume type. nil is returned for the values which cannot
+// be provisioned for the volume type.
+func scalePerformance(vol *ec2.Volume, newSizeInGiB uint64) (*int64, *int64) {
+	volumeType := aws.StringValue(vol.VolumeType)
+	maxIops := maxIopsForSize(volumeType, int64(newSizeInGiB))
+	if maxIops == 0 {
+		return nil, nil
+	}
+
+	currentSizeInGiB := uint64(aws.Int64Value(vol.Size))
+	iops := aws.Int64Value(vol.Iops)
+	if iops == 0 && volumeType == "gp3" {
+		iops = gp3BaselineIops
+	}
+	iops = int64(cloudops.ScaleToSize(uint64(iops), currentSizeInGiB, newSizeInGiB))
+	if iops > maxIops {
+		iops = maxIops
+	}
+	if volumeType != "gp3" {
+		return &iops, nil
+	}
+
+	throughput := aws.Int64Value(vol.Throughput)
+	if throughput < gp3MinThroughput {
+		throughput = gp3MinThroughput
+	}
+	throughput = int64(cloudops.ScaleToSize(uint64(throughput), currentSizeInGiB, newSizeInGiB))
+	if maxThroughput := gp3MaxThroughputForIops(iops); throughput > maxThroughput {
+		throughput = maxThroughput
+	}
+	return &iops, &throughput
+}
+
 func (s *awsOps) DeleteFrom(id, _ string) error {
 	return s.Delete(id, nil)
 }
 
+func (s *awsOps) AttachByInstanceID(instanceID, volumeID string, options map[string]string) (string, error) {
+	if instanceID == s.instance {
+		return s.Attach(volumeID, options)
+	}
+	return "", &cloudops.ErrNotSupported{
+		Operation: "AttachByInstanceID",
+		Reason:    "free device names are only known for the local instance",
+	}
+}
+
 func (s *awsOps) Delete(id string, options map[string]string) error {
+	if err := cloudops.ValidateVolumeID(id); err != nil {
+		return err
+	}
+
 	req := &ec2.DeleteVolumeInput{
 		VolumeId: &id,
 		DryRun:   dryRun(options),
@@ -905,6 +1452,10 @@ func (s *awsOps) Delete(id string, options map[string]string) error {
 }
 
 func (s *awsOps) Attach(volumeID string, options map[string]string) (string, error) {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return "", err
+	}
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -931,6 +1482,7 @@ func (s *awsOps) Attach(volumeID string, options map[string]string) (string, err
 
 		vol, err := s.waitAttachmentStatus(
 			volumeID,
+			s.instance,
 			ec2.VolumeAttachmentStateAttached,
 			time.Minute,
 		)
@@ -953,6 +1505,10 @@ func (s *awsOps) DetachFrom(volumeID, instanceName string) error {
 }
 
 func (s *awsOps) detachInternal(volumeID, instanceName string, options map[string]string) error {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return err
+	}
+
 	force := false
 	req := &ec2.DetachVolumeInput{
 		InstanceId: &instanceName,
@@ -964,6 +1520,7 @@ func (s *awsOps) detachInternal(volumeID, instanceName string, options map[strin
 		return err
 	}
 	_, err := s.waitAttachmentStatus(volumeID,
+		instanceName,
 		ec2.VolumeAttachmentStateDetached,
 		time.Minute,
 	)
@@ -974,6 +1531,35 @@ func isErrorModificationNotFound(err error) bool {
 	return strings.HasPrefix(err.Error(), awsErrorModificationNotFound)
 }
 
+// WaitForVolumeState waits until the volume is in the given normalized state
+func (s *awsOps) WaitForVolumeState(volumeID string, desiredState string, timeout time.Duration) error {
+	getState := func() (string, error) {
+		vol, err := s.refreshVol(&volumeID)
+		if err != nil {
+			return "", err
+		}
+		return volumeState(vol), nil
+	}
+	return cloudops.PollVolumeState(volumeID, desiredState, getState, timeout, s.opsTimeout.OpsRetryInterval())
+}
+
+// volumeState maps the state of the EBS volume to the normalized volume state
+func volumeState(vol *ec2.Volume) string {
+	switch aws.StringValue(vol.State) {
+	case ec2.VolumeStateCreating:
+		return cloudops.VolumeStateCreating
+	case ec2.VolumeStateAvailable:
+		return cloudops.VolumeStateAvailable
+	case ec2.VolumeStateInUse:
+		return cloudops.VolumeStateAttached
+	case ec2.VolumeStateDeleting, ec2.VolumeStateDeleted:
+		return cloudops.VolumeStateDeleting
+	case ec2.VolumeStateError:
+		return cloudops.VolumeStateError
+	}
+	return aws.StringValue(vol.State)
+}
+
 func (s *awsOps) AreVolumesReadyToExpand(volumeIDs []*string) (bool, error) {
 	modificationStateRequest := &ec2.DescribeVolumesModificationsInput{
 		VolumeIds: volumeIDs,
@@ -991,23 +1577,22 @@ func (s *awsOps) AreVolumesReadyToExpand(volumeIDs []*string) (bool, error) {
 			Message: err.Error(),
 		}
 	}
-	states := describeOutput.VolumesModifications
-
-	var state string
-	for i := 0; i < len(states); i++ {
-		if states[i] == nil || states[i].ModificationState == nil {
-			logrus.Debugf("volume modification state is nil for volume id: %s", *volumeIDs[i])
+	notReady := make(map[string]string)
+	for _, mod := range describeOutput.VolumesModifications {
+		if mod == nil || mod.VolumeId == nil || mod.ModificationState == nil {
 			continue
 		}
-
-		state = *states[i].ModificationState
-		logrus.Infof("retrived volume modification state: %s for volume id: %s", state, *volumeIDs[i])
+		state := *mod.ModificationState
+		logrus.Infof("retrieved volume modification state: %s for volume id: %s", state, *mod.VolumeId)
 		if state == ec2.VolumeModificationStateModifying ||
 			state == ec2.VolumeModificationStateOptimizing {
-			return false, fmt.Errorf("aws has not fully completed the last modification: "+
-				"volume %s is in %s state. please retry later", *volumeIDs[i], state)
+			notReady[*mod.VolumeId] = fmt.Sprintf("aws has not fully completed the last "+
+				"modification, volume is in %s state", state)
 		}
 	}
+	if len(notReady) > 0 {
+		return false, &cloudops.ErrVolumesNotReadyToExpand{Volumes: notReady}
+	}
 	return true, nil
 }
 
@@ -1016,6 +1601,10 @@ func (s *awsOps) Expand(
 	newSizeInGiB uint64,
 	options map[string]string,
 ) (uint64, error) {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return 0, err
+	}
+
 	vol, err := s.refreshVol(&volumeID)
 	if err != nil {
 		return 0, err
@@ -1033,6 +1622,27 @@ func (s *awsOps) Expand(
 		Size:     &newSizeInGiBInt64,
 		DryRun:   dryRun(options),
 	}
+	scaleIops, err := cloudops.ScaleIopsRequested(options)
+	if err != nil {
+		return currentSizeInGiB, err
+	}
+	if scaleIops {
+		request.Iops, request.Throughput = scalePerformance(vol, newSizeInGiB)
+	}
+	throughput, err := expandThroughput(vol, request.Iops, options)
+	if err != nil {
+		return currentSizeInGiB, err
+	}
+	if throughput != nil {
+		request.Throughput = throughput
+	}
+	iops := request.Iops
+	if iops == nil {
+		iops = vol.Iops
+	}
+	if err := validateIopsRatio(aws.StringValue(vol.VolumeType), iops, newSizeInGiBInt64); err != nil {
+		return currentSizeInGiB, err
+	}
 	output, err := s.ec2.Client.ModifyVolume(request)
 	if err != nil {
 		return currentSizeInGiB, fmt.Errorf("failed to modify AWS volume for %v: %v", volumeID, err)
@@ -1042,21 +1652,74 @@ func (s *awsOps) Expand(
 		return uint64(*output.VolumeModification.TargetSize), nil
 	}
 
-	// Taken from k8s.io/legacy-cloud-providers/aws
-	backoff := wait.Backoff{
-		Duration: 1 * time.Second,
-		Factor:   2,
-		Steps:    10,
+	return newSizeInGiB, s.waitForVolumeModification(volumeID)
+}
+
+// ModifyVolumeType changes the type of the volume in place with ModifyVolume,
+// keeping its size. iops and throughput are validated against the limits of
+// the new type.
+func (s *awsOps) ModifyVolumeType(volumeID, newType string, iops, throughput *int64) error {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return err
+	}
+	if len(newType) == 0 {
+		return cloudops.NewStorageError(cloudops.ErrVolInval, "volume type must not be empty", "")
+	}
+
+	vol, err := s.refreshVol(&volumeID)
+	if err != nil {
+		return err
+	}
+	if aws.StringValue(vol.VolumeType) == newType && iops == nil && throughput == nil {
+		return nil
+	}
+
+	if throughput != nil {
+		if newType != "gp3" {
+			return cloudops.NewStorageError(cloudops.ErrVolInval,
+				fmt.Sprintf("throughput cannot be configured for volume type %s", newType), "")
+		}
+		provisionedIops := int64(gp3BaselineIops)
+		if iops != nil {
+			provisionedIops = *iops
+		}
+		if err := validateGp3Throughput(*throughput, provisionedIops); err != nil {
+			return err
+		}
+	}
+	if err := validateIopsRatio(newType, iops, aws.Int64Value(vol.Size)); err != nil {
+		return err
+	}
+
+	output, err := s.ec2.Client.ModifyVolume(&ec2.ModifyVolumeInput{
+		VolumeId:   vol.VolumeId,
+		VolumeType: aws.String(newType),
+		Iops:       iops,
+		Throughput: throughput,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to modify type of AWS volume %v to %v: %v", volumeID, newType, err)
+	}
+
+	if aws.StringValue(output.VolumeModification.ModificationState) == ec2.VolumeModificationStateCompleted {
+		return nil
 	}
+	return s.waitForVolumeModification(volumeID)
+}
 
-	checkForResize := func() (bool, error) {
+// waitForVolumeModification waits for the latest modification of the volume
+// to take effect. According to
+// https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/monitoring_mods.html
+// modifications take effect once the volume is in the optimizing state.
+func (s *awsOps) waitForVolumeModification(volumeID string) error {
+	checkForModification := func() (bool, error) {
 		request := &ec2.DescribeVolumesModificationsInput{
 			VolumeIds: []*string{&volumeID},
 		}
 
 		describeOutput, err := s.ec2.Client.DescribeVolumesModifications(request)
 		if err != nil {
-			return false, fmt.Errorf("error while checking status for AWS EBS volume resize: %v", err)
+			return false, fmt.Errorf("error while checking status for AWS EBS volume modification: %v", err)
 		}
 		volumeModifications := describeOutput.VolumesModifications
 		if len(volumeModifications) == 0 {
@@ -1064,16 +1727,54 @@ func (s *awsOps) Expand(
 		}
 		volumeModification := volumeModifications[len(volumeModifications)-1]
 
-		// According to https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/monitoring_mods.html
-		// Size changes usually take a few seconds to complete and take effect after a volume is in the Optimizing state.
-		if *volumeModification.ModificationState == ec2.VolumeModificationStateOptimizing {
+		switch aws.StringValue(volumeModification.ModificationState) {
+		case ec2.VolumeModificationStateOptimizing, ec2.VolumeModificationStateCompleted:
 			return true, nil
+		case ec2.VolumeModificationStateFailed:
+			return false, fmt.Errorf("modification of AWS EBS volume %v failed: %s",
+				volumeID, aws.StringValue(volumeModification.StatusMessage))
 		}
 		return false, nil
 	}
-	waitWithErr := wait.ExponentialBackoff(backoff, checkForResize)
-	return newSizeInGiB, waitWithErr
+	return wait.ExponentialBackoff(volumeModificationBackoff, checkForModification)
+}
+
+// expandThroughput returns the throughput requested through
+// cloudops.ThroughputOption for an Expand of the given volume to the given
+// IOPS, or nil if the throughput is not requested. The current IOPS of the
+// volume are used if iops is nil. A request below the gp3 minimum is raised
+// to the minimum.
+func expandThroughput(vol *ec2.Volume, iops *int64, options map[string]string) (*int64, error) {
+	value, ok := options[cloudops.ThroughputOption]
+	if !ok || len(value) == 0 {
+		return nil, nil
+	}
+	if aws.StringValue(vol.VolumeType) != "gp3" {
+		return nil, cloudops.NewStorageError(cloudops.ErrVolInval,
+			fmt.Sprintf("throughput cannot be configured for volume type %s", aws.StringValue(vol.VolumeType)), "")
+	}
+	throughput, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, cloudops.NewStorageError(cloudops.ErrVolInval,
+			fmt.Sprintf("invalid throughput %q: %v", value, err), "")
+	}
+	if throughput < gp3MinThroughput {
+		logrus.Warnf("gp3: requested throughput [%v] is below the minimum - defaulting to minimum throughput: [%v]",
+			throughput, gp3MinThroughput)
+		throughput = gp3MinThroughput
+	}
 
+	if iops == nil {
+		iops = vol.Iops
+	}
+	provisionedIops := int64(gp3BaselineIops)
+	if iops != nil {
+		provisionedIops = *iops
+	}
+	if err := validateGp3Throughput(throughput, provisionedIops); err != nil {
+		return nil, err
+	}
+	return &throughput, nil
 }
 
 func (s *awsOps) Snapshot(
@@ -1081,14 +1782,70 @@ func (s *awsOps) Snapshot(
 	readonly bool,
 	options map[string]string,
 ) (interface{}, error) {
+	return s.SnapshotWithOptions(volumeID, readonly, cloudops.SnapshotOptions{Options: options})
+}
+
+// SnapshotWithOptions snapshots the volume, applying the snapshot name as the
+// Name tag along with the labels
+func (s *awsOps) SnapshotWithOptions(
+	volumeID string,
+	readonly bool,
+	opts cloudops.SnapshotOptions,
+) (interface{}, error) {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return nil, err
+	}
+
+	appConsistent, err := cloudops.AppConsistentRequested(opts.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	name := opts.Name
+	if len(name) == 0 {
+		name = cloudops.SnapshotName()
+	}
+	tags := []*ec2.Tag{
+		{
+			Key:   aws.String("Name"),
+			Value: aws.String(name),
+		},
+	}
+	for key, value := range opts.Labels {
+		tags = append(tags, &ec2.Tag{
+			Key:   aws.String(key),
+			Value: aws.String(value),
+		})
+	}
+	if appConsistent {
+		// Application-consistent EBS snapshots need the VSS agent to be run
+		// through SSM on the instance, which this client does not manage.
+		logrus.Warnf("application-consistent snapshots are not supported for volume %s, "+
+			"taking a crash-consistent snapshot", volumeID)
+		tags = append(tags, &ec2.Tag{
+			Key:   aws.String(cloudops.SnapshotAppConsistentTagKey),
+			Value: aws.String("false"),
+		})
+	}
+
 	request := &ec2.CreateSnapshotInput{
 		VolumeId: &volumeID,
-		DryRun:   dryRun(options),
+		DryRun:   dryRun(opts.Options),
+		TagSpecifications: []*ec2.TagSpecification{
+			{
+				ResourceType: aws.String(ec2.ResourceTypeSnapshot),
+				Tags:         tags,
+			},
+		},
 	}
 	return s.ec2.Client.CreateSnapshot(request)
 }
 
 func (s *awsOps) SnapshotDelete(snapID string, options map[string]string) error {
+	if err := cloudops.ValidateVolumeID(snapID); err != nil {
+		return err
+	}
+
 	request := &ec2.DeleteSnapshotInput{
 		SnapshotId: &snapID,
 		DryRun:     dryRun(options),
@@ -1099,6 +1856,10 @@ func (s *awsOps) SnapshotDelete(snapID string, options map[string]string) error
 }
 
 func (s *awsOps) DevicePath(volumeID string) (string, error) {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return "", err
+	}
+
 	vol, err := s.refreshVol(&volumeID)
 	if err != nil {
 		return "", err
@@ -1108,31 +1869,38 @@ func (s *awsOps) DevicePath(volumeID string) (string, error) {
 		return "", cloudops.NewStorageError(cloudops.ErrVolDetached,
 			"Volume is detached", *vol.VolumeId)
 	}
-	if vol.Attachments[0].InstanceId == nil {
-		return "", cloudops.NewStorageError(cloudops.ErrVolInval,
-			"Unable to determine volume instance attachment", "")
-	}
-	if s.instance != *vol.Attachments[0].InstanceId {
+	attachment := localAttachment(vol, s.instance)
+	if attachment == nil {
+		// a multi-attach volume attached to other instances can still be
+		// attached to this one
+		if aws.BoolValue(vol.MultiAttachEnabled) {
+			return "", cloudops.NewStorageError(cloudops.ErrVolDetached,
+				fmt.Sprintf("Volume is not attached on current instance %q", s.instance),
+				*vol.VolumeId)
+		}
+		if vol.Attachments[0].InstanceId == nil {
+			return "", cloudops.NewStorageError(cloudops.ErrVolInval,
+				"Unable to determine volume instance attachment", "")
+		}
 		return "", cloudops.NewStorageError(cloudops.ErrVolAttachedOnRemoteNode,
 			fmt.Sprintf("Volume attached on %q current instance %q",
 				*vol.Attachments[0].InstanceId, s.instance),
 			*vol.Attachments[0].InstanceId)
-
 	}
-	if vol.Attachments[0].State == nil {
+	if attachment.State == nil {
 		return "", cloudops.NewStorageError(cloudops.ErrVolInval,
 			"Unable to determine volume attachment state", "")
 	}
-	if *vol.Attachments[0].State != ec2.VolumeAttachmentStateAttached {
+	if *attachment.State != ec2.VolumeAttachmentStateAttached {
 		return "", cloudops.NewStorageError(cloudops.ErrVolInval,
 			fmt.Sprintf("Invalid state %q, volume is not attached",
-				*vol.Attachments[0].State), "")
+				*attachment.State), "")
 	}
-	if vol.Attachments[0].Device == nil {
+	if attachment.Device == nil {
 		return "", cloudops.NewStorageError(cloudops.ErrVolInval,
 			"Unable to determine volume attachment path", "")
 	}
-	devicePath, err := s.getActualDevicePath(*vol.Attachments[0].Device, volumeID)
+	devicePath, err := s.getActualDevicePath(*attachment.Device, volumeID)
 	if err != nil {
 		return "", cloudops.NewStorageError(cloudops.ErrVolInval,
 			err.Error(), "")
@@ -1140,6 +1908,337 @@ func (s *awsOps) DevicePath(volumeID string) (string, error) {
 	return devicePath, nil
 }
 
+func (s *awsOps) ReconcileDataDisks(instanceID string) ([]string, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "ReconcileDataDisks",
+	}
+}
+
+func (s *awsOps) GetEffectivePerformance(volumeID string) (uint64, uint64, error) {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return 0, 0, err
+	}
+
+	vol, err := s.refreshVol(&volumeID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var iops, throughput uint64
+	if vol.Iops != nil {
+		iops = uint64(*vol.Iops)
+	}
+	if vol.Throughput != nil {
+		throughput = uint64(*vol.Throughput)
+	}
+	return iops, throughput, nil
+}
+
+func (s *awsOps) ConfigureReplication(volumeID, targetRegion string, options map[string]string) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "ConfigureReplication",
+	}
+}
+
+func (s *awsOps) StopReplication(volumeID string) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "StopReplication",
+	}
+}
+
+func (s *awsOps) EnumerateSnapshots(volumeIDs []*string, labels map[string]string) ([]*cloudops.SnapshotInfo, error) {
+	if err := cloudops.ValidateVolumeIDs(volumeIDs); err != nil {
+		return nil, err
+	}
+
+	// Push the filters down to DescribeSnapshots instead of filtering locally
+	// since accounts can own thousands of snapshots.
+	f := s.filters(labels, nil)
+	if len(volumeIDs) > 0 {
+		f = append(f, &ec2.Filter{
+			Name:   aws.String("volume-id"),
+			Values: volumeIDs,
+		})
+	}
+	req := &ec2.DescribeSnapshotsInput{
+		Filters:  f,
+		OwnerIds: []*string{aws.String("self")},
+	}
+
+	snapshots := make([]*cloudops.SnapshotInfo, 0)
+	for {
+		resp, err := s.ec2.Client.DescribeSnapshots(req)
+		if err != nil {
+			return nil, err
+		}
+		for _, snap := range resp.Snapshots {
+			snapshots = append(snapshots, snapshotInfoFromSnapshot(snap))
+		}
+		if resp.NextToken == nil || len(*resp.NextToken) == 0 {
+			break
+		}
+		req.NextToken = resp.NextToken
+	}
+	return snapshots, nil
+}
+
+func (s *awsOps) ListSnapshots(labels map[string]string) ([]*cloudops.SnapshotInfo, error) {
+	return s.EnumerateSnapshots(nil, labels)
+}
+
+func (s *awsOps) InspectSnapshot(snapID string) (*cloudops.SnapshotInfo, error) {
+	if err := cloudops.ValidateVolumeID(snapID); err != nil {
+		return nil, err
+	}
+
+	// Filter on the snapshot ID instead of passing it in SnapshotIds, which
+	// fails the whole request for an unknown ID
+	resp, err := s.ec2.Client.DescribeSnapshots(&ec2.DescribeSnapshotsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("snapshot-id"),
+				Values: []*string{&snapID},
+			},
+		},
+		OwnerIds: []*string{aws.String("self")},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Snapshots) == 0 {
+		return nil, cloudops.NewStorageError(cloudops.ErrVolNotFound,
+			fmt.Sprintf("snapshot %s not found", snapID), s.instance)
+	}
+	return snapshotInfoFromSnapshot(resp.Snapshots[0]), nil
+}
+
+func (s *awsOps) ReportCapacityByLabel(labelKey string) (map[string]uint64, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "ReportCapacityByLabel",
+	}
+}
+
+func (s *awsOps) UpdateAttachmentCaching(instanceID, diskName, caching string) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "UpdateAttachmentCaching",
+	}
+}
+
+func (s *awsOps) ExpandMany(volumeIDs []string, newSizeInGiB uint64, options map[string]string) (map[string]uint64, error) {
+	return cloudops.ExpandVolumes(s.Expand, volumeIDs, newSizeInGiB, options)
+}
+
+func (s *awsOps) DeleteVolumes(volumeIDs []string) (map[string]error, error) {
+	return cloudops.DeleteVolumes(s.Delete, isFatalError, volumeIDs)
+}
+
+func (s *awsOps) LockVolume(volumeID, owner string) (bool, error) {
+	return false, &cloudops.ErrNotSupported{
+		Operation: "LockVolume",
+		Reason:    "EC2 tags cannot be updated conditionally",
+	}
+}
+
+func (s *awsOps) UnlockVolume(volumeID, owner string) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "UnlockVolume",
+		Reason:    "EC2 tags cannot be updated conditionally",
+	}
+}
+
+func (s *awsOps) AdoptVolume(volumeID string, labels map[string]string) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "AdoptVolume",
+	}
+}
+
+func (s *awsOps) DeleteSnapshotChain(snapIDs []string) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "DeleteSnapshotChain",
+	}
+}
+
+func (s *awsOps) GetStoragePressure(instanceID string) ([]cloudops.VolumePressureInfo, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "GetStoragePressure",
+	}
+}
+
+// awsVolumeTypeLimits are the documented size and IOPS limits of the EBS SSD
+// volume types. EC2 has no API to list the volume types, so ListDiskTypes
+// lists these.
+var awsVolumeTypeLimits = []cloudops.StorageDecisionMatrixRow{
+	{DriveType: "gp2", MinSize: 1, MaxSize: 16384, MinIOPS: 100, MaxIOPS: 16000, IOPSPerGiB: 3},
+	{DriveType: "gp3", MinSize: 1, MaxSize: 16384, MinIOPS: gp3BaselineIops, MaxIOPS: gp3MaxIops},
+	{DriveType: "io1", MinSize: 4, MaxSize: 16384, MinIOPS: 100, MaxIOPS: ioMaxIops, Priority: 1},
+	{DriveType: "io2", MinSize: 4, MaxSize: 16384, MinIOPS: 100, MaxIOPS: ioMaxIops, Priority: 1},
+}
+
+func (s *awsOps) ListDiskTypes(region string) ([]cloudops.StorageDecisionMatrixRow, error) {
+	return append([]cloudops.StorageDecisionMatrixRow(nil), awsVolumeTypeLimits...), nil
+}
+
+// ebsServiceCode is the Service Quotas code of EBS
+const ebsServiceCode = "ebs"
+
+// ebsStorageQuotaCodes are the Service Quotas codes of the regional storage
+// quotas, in TiB, of the EBS volume types
+var ebsStorageQuotaCodes = map[string]string{
+	"gp2":                       "L-D18FCD1D",
+	"gp3":                       "L-7A658B76",
+	opsworks.VolumeTypeIo1:      "L-FD252861",
+	"io2":                       "L-09BD8365",
+	"st1":                       "L-82ACEF56",
+	"sc1":                       "L-17AF77E8",
+	opsworks.VolumeTypeStandard: "L-9CF3C2EB",
+}
+
+// CheckVolumeQuota checks the capacity of the given storage pools against the
+// storage quotas of the EBS volume types in the region of the instance. The
+// usage of a quota is the size of the existing volumes of its type, and the
+// limit, usage and required capacity of the breached quotas are in GiB.
+// Volume types without a known quota are not checked.
+func (s *awsOps) CheckVolumeQuota(required []*cloudops.StoragePoolSpec) error {
+	requiredGiB := make(map[string]uint64)
+	for _, spec := range required {
+		if _, ok := ebsStorageQuotaCodes[spec.DriveType]; !ok {
+			logrus.Debugf("skipping volume type %s without a known quota", spec.DriveType)
+			continue
+		}
+		requiredGiB[spec.DriveType] += spec.DriveCapacityGiB * spec.TotalDriveCount()
+	}
+	if len(requiredGiB) == 0 {
+		return nil
+	}
+
+	volumeTypes := make([]string, 0, len(requiredGiB))
+	for volumeType := range requiredGiB {
+		volumeTypes = append(volumeTypes, volumeType)
+	}
+	sort.Strings(volumeTypes)
+
+	usageGiB, err := s.volumeUsage(volumeTypes)
+	if err != nil {
+		return err
+	}
+
+	var breaches []cloudops.QuotaBreach
+	for _, volumeType := range volumeTypes {
+		output, err := s.quotas.GetServiceQuota(&servicequotas.GetServiceQuotaInput{
+			ServiceCode: aws.String(ebsServiceCode),
+			QuotaCode:   aws.String(ebsStorageQuotaCodes[volumeType]),
+		})
+		if err != nil {
+			return err
+		}
+		if output.Quota == nil || output.Quota.Value == nil {
+			continue
+		}
+
+		limit := uint64(*output.Quota.Value * 1024)
+		usage, req := usageGiB[volumeType], requiredGiB[volumeType]
+		if usage+req <= limit {
+			continue
+		}
+		quota := aws.StringValue(output.Quota.QuotaName)
+		if len(quota) == 0 {
+			quota = ebsStorageQuotaCodes[volumeType]
+		}
+		breaches = append(breaches, cloudops.QuotaBreach{
+			Quota:    quota,
+			Limit:    limit,
+			Usage:    usage,
+			Required: req,
+		})
+	}
+	if len(breaches) > 0 {
+		return &cloudops.ErrVolumeQuotaExceeded{Quotas: breaches}
+	}
+	return nil
+}
+
+// volumeUsage returns the total size in GiB of the volumes of each of the
+// given types in the region
+func (s *awsOps) volumeUsage(volumeTypes []string) (map[string]uint64, error) {
+	usage := make(map[string]uint64)
+	req := &ec2.DescribeVolumesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("volume-type"),
+				Values: aws.StringSlice(volumeTypes),
+			},
+		},
+	}
+	for {
+		resp, err := s.ec2.Client.DescribeVolumes(req)
+		if err != nil {
+			return nil, err
+		}
+		for _, vol := range resp.Volumes {
+			usage[aws.StringValue(vol.VolumeType)] += uint64(aws.Int64Value(vol.Size))
+		}
+		if resp.NextToken == nil || len(*resp.NextToken) == 0 {
+			return usage, nil
+		}
+		req.NextToken = resp.NextToken
+	}
+}
+
+func (s *awsOps) GetStorageLayout(instanceID string) ([]cloudops.VolumeSpec, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "GetStorageLayout",
+	}
+}
+
+func (s *awsOps) ProvisionStorageLayout(specs []cloudops.VolumeSpec, labels map[string]string) ([]interface{}, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "ProvisionStorageLayout",
+	}
+}
+
+// GetVolumeLineage returns the snapshot the given volume was created from.
+// EBS volumes can only be created empty or from a snapshot.
+func (s *awsOps) GetVolumeLineage(volumeID string) (string, string, error) {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return "", "", err
+	}
+
+	vol, err := s.refreshVol(&volumeID)
+	if err != nil {
+		return "", "", err
+	}
+	if len(aws.StringValue(vol.SnapshotId)) == 0 {
+		return "", "", nil
+	}
+	return cloudops.VolumeSourceSnapshot, aws.StringValue(vol.SnapshotId), nil
+}
+
+func (s *awsOps) GetVolumeQoS(volumeID string) (uint64, uint64, error) {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return 0, 0, err
+	}
+
+	vol, err := s.refreshVol(&volumeID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var iopsLimit, throughputLimit uint64
+	switch aws.StringValue(vol.VolumeType) {
+	case "gp3":
+		iopsLimit = uint64(aws.Int64Value(vol.Iops))
+		throughputLimit = uint64(aws.Int64Value(vol.Throughput))
+	case opsworks.VolumeTypeIo1, "io2":
+		iopsLimit = uint64(aws.Int64Value(vol.Iops))
+	default:
+		return 0, 0, &cloudops.ErrNotSupported{
+			Operation: "GetVolumeQoS",
+			Reason:    fmt.Sprintf("volume type %s has no provisioned QoS limits", aws.StringValue(vol.VolumeType)),
+		}
+	}
+	return iopsLimit, throughputLimit, nil
+}
+
 func getInfoFromMetadata() (string, string, string, string, error) {
 	c, err := GetMetadataInstance()
 	if err != nil {
@@ -1214,6 +2313,45 @@ func DescribeInstanceByID(service *ec2Wrapper, id string) (*ec2.Instance, error)
 	return out.Reservations[0].Instances[0], nil
 }
 
+// snapshotInfoFromSnapshot returns the normalized info of the given ec2 snapshot
+func snapshotInfoFromSnapshot(snap *ec2.Snapshot) *cloudops.SnapshotInfo {
+	labels := labelsFromTags(snap.Tags)
+	return &cloudops.SnapshotInfo{
+		CloudResourceInfo: cloudops.CloudResourceInfo{
+			Name:   labels["Name"],
+			ID:     aws.StringValue(snap.SnapshotId),
+			Labels: labels,
+		},
+		VolumeID:     aws.StringValue(snap.VolumeId),
+		SizeInGiB:    uint64(aws.Int64Value(snap.VolumeSize)),
+		State:        aws.StringValue(snap.State),
+		CreationTime: aws.TimeValue(snap.StartTime),
+	}
+}
+
+// networkInfoFromInstance returns the network info of the given ec2 instance
+func networkInfoFromInstance(inst *ec2.Instance) *cloudops.NetworkInfo {
+	networkInfo := &cloudops.NetworkInfo{
+		Network:    aws.StringValue(inst.VpcId),
+		Subnet:     aws.StringValue(inst.SubnetId),
+		PrivateIPs: make([]string, 0),
+	}
+	for _, nic := range inst.NetworkInterfaces {
+		if nic == nil {
+			continue
+		}
+		for _, ip := range nic.PrivateIpAddresses {
+			if ip != nil && ip.PrivateIpAddress != nil {
+				networkInfo.PrivateIPs = append(networkInfo.PrivateIPs, *ip.PrivateIpAddress)
+			}
+		}
+	}
+	if len(networkInfo.PrivateIPs) == 0 && inst.PrivateIpAddress != nil {
+		networkInfo.PrivateIPs = append(networkInfo.PrivateIPs, *inst.PrivateIpAddress)
+	}
+	return networkInfo
+}
+
 func labelsFromTags(input interface{}) map[string]string {
 	labels := make(map[string]string)
 	ec2Tags, ok := input.([]*ec2.Tag)
@@ -1276,6 +2414,28 @@ func isExponentialError(err error) bool {
 	return false
 }
 
+// isFatalError returns true if the request was rejected for its credentials or
+// could not reach the EC2 endpoint
+func isFatalError(err error) bool {
+	// Got the list of error codes from here
+	// https://docs.aws.amazon.com/AWSEC2/latest/APIReference/errors-overview.html
+	awsCodes := map[string]struct{}{
+		"AuthFailure":           {},
+		"UnauthorizedOperation": {},
+		"InvalidClientTokenId":  {},
+		"SignatureDoesNotMatch": {},
+		"ExpiredToken":          {},
+		"RequestExpired":        {},
+	}
+	if awsErr, ok := err.(awserr.Error); ok {
+		if _, exist := awsCodes[awsErr.Code()]; exist {
+			return true
+		}
+		return cloudops.IsConnectionError(awsErr.OrigErr())
+	}
+	return cloudops.IsConnectionError(err)
+}
+
 func reverse(a []string) []string {
 	reversed := make([]string, len(a))
 	for i, item := range a {
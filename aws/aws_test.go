@@ -3,11 +3,17 @@ package aws
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/aws/aws-sdk-go/service/opsworks"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/aws/aws-sdk-go/service/servicequotas/servicequotasiface"
 	"github.com/libopenstorage/cloudops"
 	"github.com/libopenstorage/cloudops/test"
 	"github.com/pborman/uuid"
@@ -112,6 +118,55 @@ func TestAwsGetPrefixFromRootDeviceName(t *testing.T) {
 	}
 }
 
+func TestAwsNextFreeDeviceName(t *testing.T) {
+	a := &awsOps{instance: "i-1"}
+	mappings := func(devNames ...string) []*ec2.InstanceBlockDeviceMapping {
+		m := make([]*ec2.InstanceBlockDeviceMapping, 0, len(devNames))
+		for _, devName := range devNames {
+			m = append(m, &ec2.InstanceBlockDeviceMapping{DeviceName: aws.String(devName)})
+		}
+		return m
+	}
+
+	devName, err := a.nextFreeDeviceName("/dev/xvda", mappings("/dev/xvda", "/dev/sdf", "/dev/xvdg", "/dev/sdi", "/dev/xvdca"), nil)
+	require.NoError(t, err)
+	require.Equal(t, "/dev/xvdh", devName)
+
+	devName, err = a.nextFreeDeviceName("/dev/sda1", mappings("/dev/sda1"), nil)
+	require.NoError(t, err)
+	require.Equal(t, "/dev/sdf", devName)
+
+	_, err = a.nextFreeDeviceName("/dev/sda1", mappings("/dev/sda1",
+		"/dev/sdf", "/dev/sdg", "/dev/sdh", "/dev/sdi", "/dev/sdj", "/dev/sdk",
+		"/dev/sdl", "/dev/sdm", "/dev/sdn", "/dev/sdo", "/dev/sdp"), nil)
+	require.Error(t, err)
+	se, ok := err.(*cloudops.StorageError)
+	require.True(t, ok)
+	require.Equal(t, cloudops.ErrNoAttachSlotAvailable, se.Code)
+
+	_, err = a.nextFreeDeviceName("/dev/nvme0n1", nil, nil)
+	require.Error(t, err)
+}
+
+func TestAwsFreeDevicesWithInstanceStore(t *testing.T) {
+	a := &awsOps{instance: "i-1"}
+	mappings := []*ec2.InstanceBlockDeviceMapping{
+		{DeviceName: aws.String("/dev/xvda")},
+		{DeviceName: aws.String("/dev/sdf")},
+	}
+
+	free, err := a.freeDevices("/dev/xvda", mappings, []string{"sdg", "/dev/sdh"})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"/dev/xvdp", "/dev/xvdo", "/dev/xvdn", "/dev/xvdm",
+		"/dev/xvdl", "/dev/xvdk", "/dev/xvdj", "/dev/xvdi",
+	}, free)
+
+	devName, err := a.nextFreeDeviceName("/dev/xvda", mappings, []string{"sdg", "/dev/sdh"})
+	require.NoError(t, err)
+	require.Equal(t, "/dev/xvdi", devName)
+}
+
 type mockEC2Client struct {
 	ec2iface.EC2API
 	Vol *ec2.Volume
@@ -121,6 +176,10 @@ func (m mockEC2Client) CreateVolume(*ec2.CreateVolumeInput) (*ec2.Volume, error)
 	return m.Vol, nil
 }
 
+func (m mockEC2Client) DescribeVolumes(*ec2.DescribeVolumesInput) (*ec2.DescribeVolumesOutput, error) {
+	return &ec2.DescribeVolumesOutput{Volumes: []*ec2.Volume{m.Vol}}, nil
+}
+
 func TestAwsCreate(t *testing.T) {
 	cases := []struct {
 		name           string
@@ -149,6 +208,690 @@ func TestAwsCreate(t *testing.T) {
 	}
 }
 
+// recordingEC2Client records the create and modify requests it receives.
+type recordingEC2Client struct {
+	mockEC2Client
+	created  *ec2.CreateVolumeInput
+	modified *ec2.ModifyVolumeInput
+}
+
+func (m *recordingEC2Client) CreateVolume(req *ec2.CreateVolumeInput) (*ec2.Volume, error) {
+	m.created = req
+	return m.Vol, nil
+}
+
+func (m *recordingEC2Client) ModifyVolume(req *ec2.ModifyVolumeInput) (*ec2.ModifyVolumeOutput, error) {
+	m.modified = req
+	return &ec2.ModifyVolumeOutput{
+		VolumeModification: &ec2.VolumeModification{
+			ModificationState: aws.String(ec2.VolumeModificationStateCompleted),
+			TargetSize:        req.Size,
+			TargetThroughput:  req.Throughput,
+		},
+	}, nil
+}
+
+// failingEC2Client fails every create request with err.
+type failingEC2Client struct {
+	mockEC2Client
+	err error
+}
+
+func (m failingEC2Client) CreateVolume(*ec2.CreateVolumeInput) (*ec2.Volume, error) {
+	return nil, m.err
+}
+
+func TestAwsCreateEncryption(t *testing.T) {
+	provisioned := &ec2.Volume{
+		VolumeId:   aws.String("vol-1"),
+		VolumeType: aws.String("gp2"),
+		Size:       aws.Int64(100),
+		State:      aws.String(ec2.VolumeStateAvailable),
+	}
+	client := &recordingEC2Client{mockEC2Client: mockEC2Client{Vol: provisioned}}
+	s := &awsOps{ec2: &ec2Wrapper{Client: client}}
+	template := &ec2.Volume{VolumeType: aws.String("gp2"), Size: aws.Int64(100)}
+
+	_, err := s.Create(template, nil, map[string]string{cloudops.EncryptedOption: "true"})
+	require.NoError(t, err)
+	require.True(t, aws.BoolValue(client.created.Encrypted))
+	require.Nil(t, client.created.KmsKeyId)
+
+	_, err = s.Create(template, nil, map[string]string{cloudops.KmsKeyIDOption: "alias/key"})
+	require.NoError(t, err)
+	require.True(t, aws.BoolValue(client.created.Encrypted))
+	require.Equal(t, "alias/key", aws.StringValue(client.created.KmsKeyId))
+
+	_, err = s.Create(template, nil, map[string]string{
+		cloudops.EncryptedOption: "false",
+		cloudops.KmsKeyIDOption:  "alias/key",
+	})
+	se, ok := err.(*cloudops.StorageError)
+	require.True(t, ok, "expected a storage error, got %v", err)
+	require.Equal(t, cloudops.ErrVolInval, se.Code)
+
+	s = &awsOps{ec2: &ec2Wrapper{Client: failingEC2Client{
+		err: awserr.New("InvalidKMSKey.NotFound", "key alias/missing does not exist", nil),
+	}}}
+	_, err = s.Create(template, nil, map[string]string{cloudops.KmsKeyIDOption: "alias/missing"})
+	se, ok = err.(*cloudops.StorageError)
+	require.True(t, ok, "expected a storage error, got %v", err)
+	require.Equal(t, cloudops.ErrInvalidEncryptionKey, se.Code)
+
+	createErr := awserr.New("VolumeLimitExceeded", "too many volumes", nil)
+	s = &awsOps{ec2: &ec2Wrapper{Client: failingEC2Client{err: createErr}}}
+	_, err = s.Create(template, nil, nil)
+	require.Equal(t, createErr, err)
+}
+
+func TestAwsCreateGp3Performance(t *testing.T) {
+	provisioned := &ec2.Volume{
+		VolumeId:   aws.String("vol-1"),
+		VolumeType: aws.String("gp3"),
+		Size:       aws.Int64(100),
+		Iops:       aws.Int64(6000),
+		Throughput: aws.Int64(500),
+		State:      aws.String(ec2.VolumeStateAvailable),
+	}
+	client := &recordingEC2Client{mockEC2Client: mockEC2Client{Vol: provisioned}}
+	s := &awsOps{ec2: &ec2Wrapper{Client: client}}
+
+	_, err := s.Create(&ec2.Volume{
+		VolumeType: aws.String("gp3"),
+		Size:       aws.Int64(100),
+		Iops:       aws.Int64(6000),
+		Throughput: aws.Int64(500),
+	}, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(6000), aws.Int64Value(client.created.Iops))
+	require.Equal(t, int64(500), aws.Int64Value(client.created.Throughput))
+
+	cases := []struct {
+		name     string
+		template *ec2.Volume
+	}{
+		{
+			"throughput on io2",
+			&ec2.Volume{VolumeType: aws.String("io2"), Iops: aws.Int64(1000), Throughput: aws.Int64(500)},
+		},
+		{
+			"throughput above the gp3 maximum",
+			&ec2.Volume{VolumeType: aws.String("gp3"), Iops: aws.Int64(16000), Throughput: aws.Int64(1001)},
+		},
+		{
+			"throughput above a quarter of the baseline iops",
+			&ec2.Volume{VolumeType: aws.String("gp3"), Throughput: aws.Int64(800)},
+		},
+		{
+			"iops above the gp3 maximum",
+			&ec2.Volume{VolumeType: aws.String("gp3"), Iops: aws.Int64(16001)},
+		},
+	}
+	for _, c := range cases {
+		client.created = nil
+		_, err := s.Create(c.template, nil, nil)
+		require.Error(t, err, c.name)
+		se, ok := err.(*cloudops.StorageError)
+		require.True(t, ok, c.name)
+		require.Equal(t, cloudops.ErrVolInval, se.Code, c.name)
+		require.Nil(t, client.created, c.name)
+	}
+}
+
+func TestAwsExpandGp3Throughput(t *testing.T) {
+	vol := &ec2.Volume{
+		VolumeId:   aws.String("vol-1"),
+		VolumeType: aws.String("gp3"),
+		Size:       aws.Int64(100),
+		Iops:       aws.Int64(4000),
+		Throughput: aws.Int64(125),
+		State:      aws.String(ec2.VolumeStateAvailable),
+	}
+	client := &recordingEC2Client{mockEC2Client: mockEC2Client{Vol: vol}}
+	s := &awsOps{ec2: &ec2Wrapper{Client: client}}
+
+	size, err := s.Expand("vol-1", 200, map[string]string{cloudops.ThroughputOption: "1000"})
+	require.NoError(t, err)
+	require.Equal(t, uint64(200), size)
+	require.Equal(t, int64(1000), aws.Int64Value(client.modified.Throughput))
+
+	_, err = s.Expand("vol-1", 200, map[string]string{cloudops.ThroughputOption: "50"})
+	require.NoError(t, err)
+	require.Equal(t, int64(gp3MinThroughput), aws.Int64Value(client.modified.Throughput))
+
+	_, err = s.Expand("vol-1", 200, nil)
+	require.NoError(t, err)
+	require.Nil(t, client.modified.Throughput)
+
+	client.modified = nil
+	_, err = s.Expand("vol-1", 200, map[string]string{cloudops.ThroughputOption: "1001"})
+	require.Error(t, err)
+	require.Nil(t, client.modified)
+}
+
+func TestAwsExpandScaleIops(t *testing.T) {
+	vol := &ec2.Volume{
+		VolumeId:   aws.String("vol-1"),
+		VolumeType: aws.String("gp3"),
+		Size:       aws.Int64(100),
+		Iops:       aws.Int64(6000),
+		Throughput: aws.Int64(250),
+		State:      aws.String(ec2.VolumeStateAvailable),
+	}
+	client := &recordingEC2Client{mockEC2Client: mockEC2Client{Vol: vol}}
+	s := &awsOps{ec2: &ec2Wrapper{Client: client}}
+	scaleIops := map[string]string{cloudops.ScaleIopsOption: "true"}
+
+	// doubling the size doubles the IOPS and throughput
+	_, err := s.Expand("vol-1", 200, scaleIops)
+	require.NoError(t, err)
+	require.Equal(t, int64(12000), aws.Int64Value(client.modified.Iops))
+	require.Equal(t, int64(500), aws.Int64Value(client.modified.Throughput))
+
+	// the IOPS and throughput are clamped to the gp3 maximums
+	_, err = s.Expand("vol-1", 500, scaleIops)
+	require.NoError(t, err)
+	require.Equal(t, int64(gp3MaxIops), aws.Int64Value(client.modified.Iops))
+	require.Equal(t, int64(gp3MaxThroughput), aws.Int64Value(client.modified.Throughput))
+
+	// an explicitly requested throughput takes precedence
+	_, err = s.Expand("vol-1", 200, map[string]string{
+		cloudops.ScaleIopsOption:  "true",
+		cloudops.ThroughputOption: "300",
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(12000), aws.Int64Value(client.modified.Iops))
+	require.Equal(t, int64(300), aws.Int64Value(client.modified.Throughput))
+
+	// the provisioned performance is unchanged by default
+	_, err = s.Expand("vol-1", 200, nil)
+	require.NoError(t, err)
+	require.Nil(t, client.modified.Iops)
+	require.Nil(t, client.modified.Throughput)
+
+	// io1 IOPS are limited to 50 per GiB
+	vol.VolumeType = aws.String(opsworks.VolumeTypeIo1)
+	vol.Iops = aws.Int64(5000)
+	vol.Throughput = nil
+	_, err = s.Expand("vol-1", 150, scaleIops)
+	require.NoError(t, err)
+	require.Equal(t, int64(7500), aws.Int64Value(client.modified.Iops))
+	require.Nil(t, client.modified.Throughput)
+}
+
+// modificationEC2Client reports the given states of a volume modification
+// one after the other, the first one in the ModifyVolume response.
+type modificationEC2Client struct {
+	recordingEC2Client
+	states    []string
+	described int
+}
+
+func (m *modificationEC2Client) ModifyVolume(req *ec2.ModifyVolumeInput) (*ec2.ModifyVolumeOutput, error) {
+	m.modified = req
+	return &ec2.ModifyVolumeOutput{
+		VolumeModification: &ec2.VolumeModification{
+			ModificationState: aws.String(m.states[0]),
+			TargetVolumeType:  req.VolumeType,
+		},
+	}, nil
+}
+
+func (m *modificationEC2Client) DescribeVolumesModifications(
+	*ec2.DescribeVolumesModificationsInput,
+) (*ec2.DescribeVolumesModificationsOutput, error) {
+	m.described++
+	state := m.states[len(m.states)-1]
+	if m.described < len(m.states) {
+		state = m.states[m.described]
+	}
+	return &ec2.DescribeVolumesModificationsOutput{
+		VolumesModifications: []*ec2.VolumeModification{
+			{
+				ModificationState: aws.String(state),
+				StatusMessage:     aws.String("insufficient capacity"),
+			},
+		},
+	}, nil
+}
+
+func TestAwsModifyVolumeType(t *testing.T) {
+	defaultBackoff := volumeModificationBackoff
+	volumeModificationBackoff.Duration = time.Millisecond
+	defer func() { volumeModificationBackoff = defaultBackoff }()
+
+	vol := &ec2.Volume{
+		VolumeId:   aws.String("vol-1"),
+		VolumeType: aws.String("gp2"),
+		Size:       aws.Int64(100),
+		State:      aws.String(ec2.VolumeStateAvailable),
+	}
+	newClient := func(states ...string) (*modificationEC2Client, *awsOps) {
+		client := &modificationEC2Client{
+			recordingEC2Client: recordingEC2Client{mockEC2Client: mockEC2Client{Vol: vol}},
+			states:             states,
+		}
+		return client, &awsOps{ec2: &ec2Wrapper{Client: client}}
+	}
+
+	// the modification is waited on until it is optimizing
+	client, s := newClient(
+		ec2.VolumeModificationStateModifying,
+		ec2.VolumeModificationStateModifying,
+		ec2.VolumeModificationStateOptimizing,
+	)
+	require.NoError(t, s.ModifyVolumeType("vol-1", "gp3", aws.Int64(4000), aws.Int64(250)))
+	require.Equal(t, "gp3", aws.StringValue(client.modified.VolumeType))
+	require.Equal(t, int64(4000), aws.Int64Value(client.modified.Iops))
+	require.Equal(t, int64(250), aws.Int64Value(client.modified.Throughput))
+	require.Nil(t, client.modified.Size)
+	require.Equal(t, 2, client.described)
+
+	// a modification completed right away is not waited on
+	client, s = newClient(ec2.VolumeModificationStateCompleted)
+	require.NoError(t, s.ModifyVolumeType("vol-1", "gp3", nil, nil))
+	require.Zero(t, client.described)
+
+	// a failed modification is surfaced
+	_, s = newClient(ec2.VolumeModificationStateModifying, ec2.VolumeModificationStateFailed)
+	err := s.ModifyVolumeType("vol-1", "gp3", nil, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "insufficient capacity")
+
+	// the volume already has the type
+	client, s = newClient(ec2.VolumeModificationStateCompleted)
+	require.NoError(t, s.ModifyVolumeType("vol-1", "gp2", nil, nil))
+	require.Nil(t, client.modified)
+
+	// throughput is only supported by gp3 and limited by the IOPS
+	client, s = newClient(ec2.VolumeModificationStateCompleted)
+	require.Error(t, s.ModifyVolumeType("vol-1", "io1", aws.Int64(1000), aws.Int64(250)))
+	require.Error(t, s.ModifyVolumeType("vol-1", "gp3", nil, aws.Int64(1000)))
+	// io1 IOPS are limited to 50 per GiB
+	require.Error(t, s.ModifyVolumeType("vol-1", "io1", aws.Int64(6000), nil))
+	require.Nil(t, client.modified)
+}
+
+func TestAwsIo2IopsRatio(t *testing.T) {
+	vol := &ec2.Volume{
+		VolumeId:   aws.String("vol-1"),
+		VolumeType: aws.String("io2"),
+		Size:       aws.Int64(100),
+		Iops:       aws.Int64(50000),
+		State:      aws.String(ec2.VolumeStateAvailable),
+	}
+	client := &recordingEC2Client{mockEC2Client: mockEC2Client{Vol: vol}}
+	s := &awsOps{ec2: &ec2Wrapper{Client: client}}
+
+	requireInvalidRequest := func(err error) {
+		require.Error(t, err)
+		storageErr, ok := err.(*cloudops.StorageError)
+		require.True(t, ok, "expected a StorageError, got %T", err)
+		require.Equal(t, cloudops.ErrInvalidStorageRequest, storageErr.Code)
+	}
+
+	// 501 IOPS per GiB exceeds the io2 ratio
+	_, err := s.Create(&ec2.Volume{
+		VolumeType: aws.String("io2"),
+		Size:       aws.Int64(100),
+		Iops:       aws.Int64(50100),
+	}, nil, nil)
+	requireInvalidRequest(err)
+	require.Contains(t, err.Error(), "50000")
+	require.Nil(t, client.created)
+
+	// 500 IOPS per GiB is allowed
+	_, err = s.Create(&ec2.Volume{
+		VolumeType:         aws.String("io2"),
+		Size:               aws.Int64(100),
+		Iops:               aws.Int64(50000),
+		MultiAttachEnabled: aws.Bool(true),
+	}, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(50000), aws.Int64Value(client.created.Iops))
+	require.True(t, aws.BoolValue(client.created.MultiAttachEnabled))
+
+	// multi-attach is only supported for provisioned IOPS volumes
+	_, err = s.Create(&ec2.Volume{
+		VolumeType:         aws.String("gp3"),
+		Size:               aws.Int64(100),
+		MultiAttachEnabled: aws.Bool(true),
+	}, nil, nil)
+	requireInvalidRequest(err)
+
+	// the ratio is checked against the expanded size
+	vol.Size = aws.Int64(20)
+	vol.Iops = aws.Int64(10500)
+	_, err = s.Expand("vol-1", 21, nil)
+	require.NoError(t, err)
+
+	client.modified = nil
+	vol.Iops = aws.Int64(10521)
+	_, err = s.Expand("vol-1", 21, nil)
+	requireInvalidRequest(err)
+	require.Nil(t, client.modified)
+}
+
+func TestAwsGetEffectivePerformance(t *testing.T) {
+	// gp3 allows at most 500 IOPS per GiB, so the requested IOPS get clamped
+	template := &ec2.Volume{
+		VolumeType: aws.String("gp3"),
+		Size:       aws.Int64(10),
+		Iops:       aws.Int64(16000),
+		Throughput: aws.Int64(1000),
+	}
+	provisioned := &ec2.Volume{
+		VolumeId:   aws.String("vol-1"),
+		VolumeType: aws.String("gp3"),
+		Size:       aws.Int64(10),
+		Iops:       aws.Int64(5000),
+		Throughput: aws.Int64(1000),
+		State:      aws.String(ec2.VolumeStateAvailable),
+	}
+	s := &awsOps{
+		ec2: &ec2Wrapper{
+			Client: mockEC2Client{Vol: provisioned},
+		},
+	}
+
+	vol, err := s.Create(template, nil, nil)
+	require.NoError(t, err)
+	id, err := s.GetDeviceID(vol)
+	require.NoError(t, err)
+
+	iops, throughput, err := s.GetEffectivePerformance(id)
+	require.NoError(t, err)
+	require.Equal(t, uint64(5000), iops)
+	require.Equal(t, uint64(1000), throughput)
+}
+
+func TestAwsEmptyVolumeID(t *testing.T) {
+	// A nil EC2API panics on any call so the test fails if a request is made.
+	s := &awsOps{
+		ec2: &ec2Wrapper{
+			Client: struct{ ec2iface.EC2API }{},
+		},
+	}
+
+	test.RunEmptyVolumeIDTest(t, s)
+}
+
+func TestAwsGetVolumeLineage(t *testing.T) {
+	vol := &ec2.Volume{
+		VolumeId:   aws.String("vol-1"),
+		SnapshotId: aws.String("snap-1"),
+	}
+	s := &awsOps{
+		ec2: &ec2Wrapper{
+			Client: mockEC2Client{Vol: vol},
+		},
+	}
+
+	sourceType, sourceID, err := s.GetVolumeLineage("vol-1")
+	require.NoError(t, err)
+	require.Equal(t, cloudops.VolumeSourceSnapshot, sourceType)
+	require.Equal(t, "snap-1", sourceID)
+
+	vol.SnapshotId = aws.String("")
+	sourceType, sourceID, err = s.GetVolumeLineage("vol-1")
+	require.NoError(t, err)
+	require.Empty(t, sourceType)
+	require.Empty(t, sourceID)
+}
+
+// pagedEC2Client returns each of its pages of volumes in turn
+type pagedEC2Client struct {
+	ec2iface.EC2API
+	pages    [][]*ec2.Volume
+	calls    int
+	requests []ec2.DescribeVolumesInput
+}
+
+func (m *pagedEC2Client) DescribeVolumes(req *ec2.DescribeVolumesInput) (*ec2.DescribeVolumesOutput, error) {
+	m.requests = append(m.requests, *req)
+	page := m.calls
+	m.calls++
+	out := &ec2.DescribeVolumesOutput{Volumes: m.pages[page]}
+	if page+1 < len(m.pages) {
+		out.NextToken = aws.String(strconv.Itoa(page + 1))
+	}
+	return out, nil
+}
+
+func TestAwsBatchInspect(t *testing.T) {
+	client := &pagedEC2Client{
+		pages: [][]*ec2.Volume{
+			{{VolumeId: aws.String("vol-1")}, {VolumeId: aws.String("vol-2")}},
+			{{VolumeId: aws.String("vol-3")}},
+		},
+	}
+	s := &awsOps{ec2: &ec2Wrapper{Client: client}}
+
+	vols, err := s.BatchInspect([]*string{aws.String("vol-1"), aws.String("vol-3")})
+	require.NoError(t, err)
+	require.Equal(t, 2, client.calls)
+	require.Len(t, vols, 2)
+	require.Equal(t, "vol-3", aws.StringValue(vols["vol-3"].(*ec2.Volume).VolumeId))
+
+	client.calls = 0
+	_, err = s.BatchInspect([]*string{aws.String("vol-4")})
+	require.Error(t, err)
+	se, ok := err.(*cloudops.StorageError)
+	require.True(t, ok)
+	require.Equal(t, cloudops.ErrVolNotFound, se.Code)
+}
+
+func TestAwsListVolumesPaged(t *testing.T) {
+	client := &pagedEC2Client{
+		pages: [][]*ec2.Volume{
+			{
+				{VolumeId: aws.String("vol-1"), State: aws.String(ec2.VolumeStateInUse)},
+				{VolumeId: aws.String("vol-2"), State: aws.String(ec2.VolumeStateDeleting)},
+			},
+			{},
+			{{VolumeId: aws.String("vol-3"), State: aws.String(ec2.VolumeStateAvailable)}},
+		},
+	}
+	s := &awsOps{ec2: &ec2Wrapper{Client: client}}
+
+	it, err := s.ListVolumesPaged(map[string]string{"app": "db"}, 2)
+	require.NoError(t, err)
+	require.Zero(t, client.calls, "pages should only be fetched when iterated")
+
+	var ids []string
+	for vol, ok := it.Next(); ok; vol, ok = it.Next() {
+		ids = append(ids, aws.StringValue(vol.(*ec2.Volume).VolumeId))
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, []string{"vol-1", "vol-3"}, ids)
+	require.Equal(t, 3, client.calls)
+
+	// the page size is raised to the minimum DescribeVolumes accepts
+	require.Equal(t, int64(describeVolumesMinPageSize), aws.Int64Value(client.requests[0].MaxResults))
+	require.Equal(t, "tag:app", aws.StringValue(client.requests[0].Filters[0].Name))
+	require.Nil(t, client.requests[0].NextToken)
+	require.Equal(t, "2", aws.StringValue(client.requests[2].NextToken))
+
+	_, ok := it.Next()
+	require.False(t, ok)
+	require.Equal(t, 3, client.calls)
+}
+
+func TestAwsGetVolumeQoS(t *testing.T) {
+	cases := []struct {
+		name               string
+		vol                *ec2.Volume
+		expectedIops       uint64
+		expectedThroughput uint64
+		expectErr          bool
+	}{
+		{
+			name: "gp3 reports iops and throughput",
+			vol: &ec2.Volume{
+				VolumeType: aws.String("gp3"),
+				Iops:       aws.Int64(4000),
+				Throughput: aws.Int64(250),
+			},
+			expectedIops:       4000,
+			expectedThroughput: 250,
+		},
+		{
+			name: "io2 reports iops",
+			vol: &ec2.Volume{
+				VolumeType: aws.String("io2"),
+				Iops:       aws.Int64(10000),
+			},
+			expectedIops: 10000,
+		},
+		{
+			name: "gp2 has no provisioned limits",
+			vol: &ec2.Volume{
+				VolumeType: aws.String("gp2"),
+				Iops:       aws.Int64(300),
+			},
+			expectErr: true,
+		},
+	}
+	for _, c := range cases {
+		c.vol.VolumeId = aws.String("vol-1")
+		c.vol.Size = aws.Int64(100)
+		c.vol.State = aws.String(ec2.VolumeStateAvailable)
+		s := &awsOps{
+			ec2: &ec2Wrapper{
+				Client: mockEC2Client{Vol: c.vol},
+			},
+		}
+
+		vol, err := s.Create(c.vol, nil, nil)
+		require.NoError(t, err, c.name)
+		id, err := s.GetDeviceID(vol)
+		require.NoError(t, err, c.name)
+
+		iops, throughput, err := s.GetVolumeQoS(id)
+		if c.expectErr {
+			require.Error(t, err, c.name)
+			_, ok := err.(*cloudops.ErrNotSupported)
+			require.True(t, ok, c.name)
+			continue
+		}
+		require.NoError(t, err, c.name)
+		require.Equal(t, c.expectedIops, iops, c.name)
+		require.Equal(t, c.expectedThroughput, throughput, c.name)
+	}
+}
+
+func TestAwsNetworkInfoFromInstance(t *testing.T) {
+	inst := &ec2.Instance{
+		VpcId:            aws.String("vpc-1"),
+		SubnetId:         aws.String("subnet-1"),
+		PrivateIpAddress: aws.String("10.0.0.4"),
+		NetworkInterfaces: []*ec2.InstanceNetworkInterface{
+			{
+				PrivateIpAddresses: []*ec2.InstancePrivateIpAddress{
+					{PrivateIpAddress: aws.String("10.0.0.4")},
+					{PrivateIpAddress: aws.String("10.0.0.5")},
+				},
+			},
+			{
+				PrivateIpAddresses: []*ec2.InstancePrivateIpAddress{
+					{PrivateIpAddress: aws.String("10.0.1.4")},
+				},
+			},
+		},
+	}
+
+	networkInfo := networkInfoFromInstance(inst)
+	require.Equal(t, "vpc-1", networkInfo.Network)
+	require.Equal(t, "subnet-1", networkInfo.Subnet)
+	require.Equal(t, []string{"10.0.0.4", "10.0.0.5", "10.0.1.4"}, networkInfo.PrivateIPs)
+}
+
+type mockSnapshotsEC2Client struct {
+	ec2iface.EC2API
+	pages    []*ec2.DescribeSnapshotsOutput
+	requests []*ec2.DescribeSnapshotsInput
+}
+
+func (m *mockSnapshotsEC2Client) DescribeSnapshots(req *ec2.DescribeSnapshotsInput) (*ec2.DescribeSnapshotsOutput, error) {
+	reqCopy := *req
+	m.requests = append(m.requests, &reqCopy)
+	page := 0
+	if req.NextToken != nil {
+		page, _ = strconv.Atoi(*req.NextToken)
+	}
+	return m.pages[page], nil
+}
+
+func TestAwsEnumerateSnapshots(t *testing.T) {
+	startTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	client := &mockSnapshotsEC2Client{
+		pages: []*ec2.DescribeSnapshotsOutput{
+			{
+				Snapshots: []*ec2.Snapshot{
+					{
+						SnapshotId: aws.String("snap-1"),
+						VolumeId:   aws.String("vol-1"),
+						VolumeSize: aws.Int64(10),
+						State:      aws.String(ec2.SnapshotStateCompleted),
+						StartTime:  aws.Time(startTime),
+						Tags: []*ec2.Tag{
+							{Key: aws.String("Name"), Value: aws.String("backup-1")},
+							{Key: aws.String("app"), Value: aws.String("db")},
+						},
+					},
+				},
+				NextToken: aws.String("1"),
+			},
+			{
+				Snapshots: []*ec2.Snapshot{
+					{
+						SnapshotId: aws.String("snap-2"),
+						VolumeId:   aws.String("vol-1"),
+						VolumeSize: aws.Int64(10),
+						State:      aws.String(ec2.SnapshotStatePending),
+						Tags: []*ec2.Tag{
+							{Key: aws.String("app"), Value: aws.String("db")},
+						},
+					},
+				},
+			},
+		},
+	}
+	s := &awsOps{
+		ec2: &ec2Wrapper{
+			Client: client,
+		},
+	}
+
+	snapshots, err := s.EnumerateSnapshots([]*string{aws.String("vol-1")}, map[string]string{"app": "db"})
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	require.Equal(t, &cloudops.SnapshotInfo{
+		CloudResourceInfo: cloudops.CloudResourceInfo{
+			Name:   "backup-1",
+			ID:     "snap-1",
+			Labels: map[string]string{"Name": "backup-1", "app": "db"},
+		},
+		VolumeID:     "vol-1",
+		SizeInGiB:    10,
+		State:        ec2.SnapshotStateCompleted,
+		CreationTime: startTime,
+	}, snapshots[0])
+	require.Equal(t, "snap-2", snapshots[1].ID)
+
+	// Both pages must be requested with the filters pushed down
+	require.Len(t, client.requests, 2)
+	require.Nil(t, client.requests[0].NextToken)
+	require.Equal(t, "1", aws.StringValue(client.requests[1].NextToken))
+	for _, req := range client.requests {
+		require.Equal(t, []*string{aws.String("self")}, req.OwnerIds)
+		require.ElementsMatch(t, []*ec2.Filter{
+			{Name: aws.String("tag:app"), Values: []*string{aws.String("db")}},
+			{Name: aws.String("volume-id"), Values: []*string{aws.String("vol-1")}},
+		}, req.Filters)
+	}
+}
+
 func TestAllWithKubernetes(t *testing.T) {
 
 	// Create a new fake clientset
@@ -252,3 +995,261 @@ func TestAllWithKubernetes(t *testing.T) {
 	require.Error(t, err)
 	require.Empty(t, vols)
 }
+
+func TestAwsAttachmentState(t *testing.T) {
+	vol := &ec2.Volume{
+		Attachments: []*ec2.VolumeAttachment{
+			{InstanceId: aws.String("other"), State: aws.String(ec2.VolumeAttachmentStateAttached)},
+			{InstanceId: aws.String("local"), State: aws.String(ec2.VolumeAttachmentStateAttaching)},
+		},
+	}
+	// the attachment to another instance of a multi-attach volume does not
+	// mean the volume is attached to this one
+	require.Equal(t, ec2.VolumeAttachmentStateAttaching, attachmentState(vol, "local"))
+	require.Equal(t, ec2.VolumeAttachmentStateAttached, attachmentState(vol, "other"))
+	require.Equal(t, ec2.VolumeAttachmentStateDetached, attachmentState(vol, "new"))
+	require.Equal(t, ec2.VolumeAttachmentStateDetached, attachmentState(&ec2.Volume{}, "local"))
+}
+
+// multiAttachEC2Client serves a multi-attach volume and removes the attachment
+// to the instance of a detach request from it.
+type multiAttachEC2Client struct {
+	recordingEC2Client
+	detached *ec2.DetachVolumeInput
+}
+
+func (m *multiAttachEC2Client) DetachVolume(req *ec2.DetachVolumeInput) (*ec2.VolumeAttachment, error) {
+	m.detached = req
+	attachments := make([]*ec2.VolumeAttachment, 0, len(m.Vol.Attachments))
+	for _, attachment := range m.Vol.Attachments {
+		if aws.StringValue(attachment.InstanceId) != aws.StringValue(req.InstanceId) {
+			attachments = append(attachments, attachment)
+		}
+	}
+	m.Vol.Attachments = attachments
+	return &ec2.VolumeAttachment{}, nil
+}
+
+func TestAwsMultiAttach(t *testing.T) {
+	vol := &ec2.Volume{
+		VolumeId:           aws.String("vol-1"),
+		VolumeType:         aws.String("io2"),
+		Size:               aws.Int64(100),
+		State:              aws.String(ec2.VolumeStateAvailable),
+		MultiAttachEnabled: aws.Bool(true),
+		Attachments: []*ec2.VolumeAttachment{
+			{
+				InstanceId: aws.String("i-1"),
+				Device:     aws.String("/dev/sdf"),
+				State:      aws.String(ec2.VolumeAttachmentStateAttached),
+			},
+			{
+				InstanceId: aws.String("i-2"),
+				Device:     aws.String("/dev/sdg"),
+				State:      aws.String(ec2.VolumeAttachmentStateAttaching),
+			},
+		},
+	}
+	client := &multiAttachEC2Client{
+		recordingEC2Client: recordingEC2Client{mockEC2Client: mockEC2Client{Vol: vol}},
+	}
+	newOps := func(instance string) *awsOps {
+		return &awsOps{instance: instance, ec2: &ec2Wrapper{Client: client}}
+	}
+	requireStorageError := func(err error, code int) {
+		require.Error(t, err)
+		storageErr, ok := err.(*cloudops.StorageError)
+		require.True(t, ok, "expected a StorageError, got %T", err)
+		require.Equal(t, code, storageErr.Code)
+	}
+
+	// multi-attach is enabled through the create options
+	template := &ec2.Volume{VolumeType: aws.String("io2"), Size: aws.Int64(100)}
+	_, err := newOps("i-1").Create(template, nil, map[string]string{cloudops.MultiAttachOption: "true"})
+	require.NoError(t, err)
+	require.True(t, aws.BoolValue(client.created.MultiAttachEnabled))
+	require.Nil(t, template.MultiAttachEnabled)
+
+	// the attachment of the local instance is used, not the first one
+	_, err = newOps("i-2").DevicePath("vol-1")
+	requireStorageError(err, cloudops.ErrVolInval)
+	require.Contains(t, err.Error(), ec2.VolumeAttachmentStateAttaching)
+
+	// the volume is attached to other instances but not to this one
+	_, err = newOps("i-3").DevicePath("vol-1")
+	requireStorageError(err, cloudops.ErrVolDetached)
+
+	vol.MultiAttachEnabled = aws.Bool(false)
+	_, err = newOps("i-3").DevicePath("vol-1")
+	requireStorageError(err, cloudops.ErrVolAttachedOnRemoteNode)
+	vol.MultiAttachEnabled = aws.Bool(true)
+
+	// detach only removes the attachment of the requested instance
+	require.NoError(t, newOps("i-1").DetachFrom("vol-1", "i-2"))
+	require.Equal(t, "i-2", aws.StringValue(client.detached.InstanceId))
+	require.Len(t, vol.Attachments, 1)
+	require.Equal(t, "i-1", aws.StringValue(vol.Attachments[0].InstanceId))
+}
+
+// volumesModificationsEC2Client reports the given modification states of
+// volumes, or err if it is set
+type volumesModificationsEC2Client struct {
+	mockEC2Client
+	states map[string]string
+	err    error
+}
+
+func (m volumesModificationsEC2Client) DescribeVolumesModifications(
+	*ec2.DescribeVolumesModificationsInput,
+) (*ec2.DescribeVolumesModificationsOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	out := &ec2.DescribeVolumesModificationsOutput{}
+	for id, state := range m.states {
+		out.VolumesModifications = append(out.VolumesModifications, &ec2.VolumeModification{
+			VolumeId:          aws.String(id),
+			ModificationState: aws.String(state),
+		})
+	}
+	return out, nil
+}
+
+func TestAwsAreVolumesReadyToExpand(t *testing.T) {
+	ids := []*string{aws.String("vol-1"), aws.String("vol-2"), aws.String("vol-3")}
+	s := &awsOps{ec2: &ec2Wrapper{Client: volumesModificationsEC2Client{
+		states: map[string]string{
+			"vol-1": ec2.VolumeModificationStateCompleted,
+			"vol-2": ec2.VolumeModificationStateOptimizing,
+			"vol-3": ec2.VolumeModificationStateModifying,
+		},
+	}}}
+	ready, err := s.AreVolumesReadyToExpand(ids)
+	require.False(t, ready)
+	notReady, ok := err.(*cloudops.ErrVolumesNotReadyToExpand)
+	require.True(t, ok, "expected ErrVolumesNotReadyToExpand, got %v", err)
+	require.Len(t, notReady.Volumes, 2)
+	require.Contains(t, notReady.Volumes["vol-2"], ec2.VolumeModificationStateOptimizing)
+	require.Contains(t, notReady.Volumes["vol-3"], ec2.VolumeModificationStateModifying)
+
+	s = &awsOps{ec2: &ec2Wrapper{Client: volumesModificationsEC2Client{
+		states: map[string]string{"vol-1": ec2.VolumeModificationStateCompleted},
+	}}}
+	ready, err = s.AreVolumesReadyToExpand(ids)
+	require.NoError(t, err)
+	require.True(t, ready)
+
+	// volumes which were never modified have no modification state
+	s = &awsOps{ec2: &ec2Wrapper{Client: volumesModificationsEC2Client{
+		err: awserr.New("InvalidVolumeModification.NotFound", "not found", nil),
+	}}}
+	ready, err = s.AreVolumesReadyToExpand(ids)
+	require.NoError(t, err)
+	require.True(t, ready)
+}
+
+// stateSequenceEC2Client describes the volume in each of the given states in
+// turn, staying in the last one
+type stateSequenceEC2Client struct {
+	ec2iface.EC2API
+	states []string
+	calls  int
+}
+
+func (m *stateSequenceEC2Client) DescribeVolumes(req *ec2.DescribeVolumesInput) (*ec2.DescribeVolumesOutput, error) {
+	state := m.states[len(m.states)-1]
+	if m.calls < len(m.states) {
+		state = m.states[m.calls]
+	}
+	m.calls++
+	return &ec2.DescribeVolumesOutput{Volumes: []*ec2.Volume{
+		{VolumeId: req.VolumeIds[0], State: aws.String(state)},
+	}}, nil
+}
+
+func TestWaitForVolumeState(t *testing.T) {
+	opsTimeout := cloudops.OpsTimeoutConfig{RetryInterval: time.Millisecond}
+
+	client := &stateSequenceEC2Client{states: []string{"creating", "creating", "available", "in-use"}}
+	s := &awsOps{ec2: &ec2Wrapper{Client: client}, opsTimeout: opsTimeout}
+	require.NoError(t, s.WaitForVolumeState("vol-1", cloudops.VolumeStateAttached, time.Second))
+	require.Equal(t, 4, client.calls)
+
+	client = &stateSequenceEC2Client{states: []string{"creating", "error"}}
+	s = &awsOps{ec2: &ec2Wrapper{Client: client}, opsTimeout: opsTimeout}
+	err := s.WaitForVolumeState("vol-1", cloudops.VolumeStateAvailable, time.Second)
+	require.Error(t, err)
+	require.Equal(t, 2, client.calls)
+
+	client = &stateSequenceEC2Client{states: []string{"creating"}}
+	s = &awsOps{ec2: &ec2Wrapper{Client: client}, opsTimeout: opsTimeout}
+	require.Error(t, s.WaitForVolumeState("vol-1", cloudops.VolumeStateAvailable, 20*time.Millisecond))
+
+	err = s.WaitForVolumeState("vol-1", "in-use", time.Second)
+	se, ok := err.(*cloudops.StorageError)
+	require.True(t, ok, "expected a storage error, got %v", err)
+	require.Equal(t, cloudops.ErrVolInval, se.Code)
+}
+
+// mockQuotasClient returns the quotas with the given codes, in TiB
+type mockQuotasClient struct {
+	servicequotasiface.ServiceQuotasAPI
+	values   map[string]float64
+	requests int
+}
+
+func (m *mockQuotasClient) GetServiceQuota(req *servicequotas.GetServiceQuotaInput) (*servicequotas.GetServiceQuotaOutput, error) {
+	m.requests++
+	code := aws.StringValue(req.QuotaCode)
+	return &servicequotas.GetServiceQuotaOutput{
+		Quota: &servicequotas.ServiceQuota{
+			QuotaCode: req.QuotaCode,
+			QuotaName: aws.String("Storage for " + code),
+			Value:     aws.Float64(m.values[code]),
+		},
+	}, nil
+}
+
+func TestAwsCheckVolumeQuota(t *testing.T) {
+	client := &pagedEC2Client{
+		pages: [][]*ec2.Volume{
+			{{VolumeType: aws.String("gp3"), Size: aws.Int64(2048)}},
+			{{VolumeType: aws.String("io2"), Size: aws.Int64(512)}},
+		},
+	}
+	quotas := &mockQuotasClient{values: map[string]float64{
+		ebsStorageQuotaCodes["gp3"]: 8,
+		ebsStorageQuotaCodes["io2"]: 1,
+	}}
+	s := &awsOps{ec2: &ec2Wrapper{Client: client}, quotas: quotas}
+
+	// 3 instances with 2 drives of 1 TiB in each of 3 zones fit in the
+	// remaining 6 TiB of the gp3 quota
+	err := s.CheckVolumeQuota([]*cloudops.StoragePoolSpec{
+		{DriveType: "gp3", DriveCapacityGiB: 1024, DriveCount: 2, InstancesPerZone: 1, ZoneCount: 3},
+	})
+	require.NoError(t, err)
+	require.Len(t, client.requests, 2)
+	require.Equal(t, []*string{aws.String("gp3")}, client.requests[0].Filters[0].Values)
+
+	client.calls, client.requests = 0, nil
+	err = s.CheckVolumeQuota([]*cloudops.StoragePoolSpec{
+		{DriveType: "gp3", DriveCapacityGiB: 1024, DriveCount: 2, InstancesPerZone: 2, ZoneCount: 3},
+		{DriveType: "io2", DriveCapacityGiB: 256, DriveCount: 1, InstancesPerZone: 1},
+	})
+	require.Error(t, err)
+	quotaErr, ok := err.(*cloudops.ErrVolumeQuotaExceeded)
+	require.True(t, ok, "expected a quota error, got: %v", err)
+	require.Equal(t, []cloudops.QuotaBreach{
+		{Quota: "Storage for L-7A658B76", Limit: 8192, Usage: 2048, Required: 12288},
+	}, quotaErr.Quotas)
+
+	// volume types without a known quota are not checked
+	client.calls, quotas.requests = 0, 0
+	err = s.CheckVolumeQuota([]*cloudops.StoragePoolSpec{
+		{DriveType: "unknown", DriveCapacityGiB: 1024, DriveCount: 100, InstancesPerZone: 3},
+	})
+	require.NoError(t, err)
+	require.Zero(t, client.calls)
+	require.Zero(t, quotas.requests)
+}
@@ -0,0 +1,132 @@
+package aws
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/libopenstorage/cloudops"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestAutoscaling returns an autoscaling client which talks to the given handler
+func newTestAutoscaling(t *testing.T, handler http.Handler) *autoscaling.AutoScaling {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return autoscaling.New(session.Must(session.NewSession(&aws.Config{
+		Endpoint:    aws.String(server.URL),
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	})))
+}
+
+func TestAwsRollInstanceGroup(t *testing.T) {
+	origInterval := instanceRefreshRetryInterval
+	instanceRefreshRetryInterval = 10 * time.Millisecond
+	defer func() { instanceRefreshRetryInterval = origInterval }()
+
+	var (
+		minHealthyPercentage string
+		describeCalls        int
+	)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		require.Equal(t, "asg", r.Form.Get("AutoScalingGroupName"))
+		w.Header().Set("Content-Type", "text/xml")
+		switch r.Form.Get("Action") {
+		case "StartInstanceRefresh":
+			minHealthyPercentage = r.Form.Get("Preferences.MinHealthyPercentage")
+			fmt.Fprint(w, `<StartInstanceRefreshResponse><StartInstanceRefreshResult>
+				<InstanceRefreshId>refresh-1</InstanceRefreshId>
+			</StartInstanceRefreshResult></StartInstanceRefreshResponse>`)
+		case "DescribeInstanceRefreshes":
+			require.Equal(t, "refresh-1", r.Form.Get("InstanceRefreshIds.member.1"))
+			describeCalls++
+			status := autoscaling.InstanceRefreshStatusInProgress
+			if describeCalls == 3 {
+				status = autoscaling.InstanceRefreshStatusSuccessful
+			}
+			fmt.Fprintf(w, `<DescribeInstanceRefreshesResponse><DescribeInstanceRefreshesResult>
+				<InstanceRefreshes><member>
+					<InstanceRefreshId>refresh-1</InstanceRefreshId>
+					<AutoScalingGroupName>asg</AutoScalingGroupName>
+					<Status>%s</Status>
+				</member></InstanceRefreshes>
+			</DescribeInstanceRefreshesResult></DescribeInstanceRefreshesResponse>`, status)
+		default:
+			t.Errorf("unexpected action %s", r.Form.Get("Action"))
+		}
+	})
+
+	s := &awsOps{autoscaling: newTestAutoscaling(t, handler)}
+	err := s.RollInstanceGroup("asg", cloudops.RollOpts{
+		MinHealthyPercentage: 90,
+		Timeout:              time.Minute,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "90", minHealthyPercentage)
+	require.Equal(t, 3, describeCalls)
+}
+
+func TestAwsRollInstanceGroupFailed(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		w.Header().Set("Content-Type", "text/xml")
+		if r.Form.Get("Action") == "StartInstanceRefresh" {
+			fmt.Fprint(w, `<StartInstanceRefreshResponse><StartInstanceRefreshResult>
+				<InstanceRefreshId>refresh-1</InstanceRefreshId>
+			</StartInstanceRefreshResult></StartInstanceRefreshResponse>`)
+			return
+		}
+		fmt.Fprint(w, `<DescribeInstanceRefreshesResponse><DescribeInstanceRefreshesResult>
+			<InstanceRefreshes><member>
+				<InstanceRefreshId>refresh-1</InstanceRefreshId>
+				<Status>Failed</Status>
+				<StatusReason>launch template is invalid</StatusReason>
+			</member></InstanceRefreshes>
+		</DescribeInstanceRefreshesResult></DescribeInstanceRefreshesResponse>`)
+	})
+
+	s := &awsOps{autoscaling: newTestAutoscaling(t, handler)}
+	err := s.RollInstanceGroup("asg", cloudops.RollOpts{Timeout: time.Minute})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "launch template is invalid")
+}
+
+func TestAwsGetInstanceGroupZones(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		require.Equal(t, "DescribeAutoScalingGroups", r.Form.Get("Action"))
+		w.Header().Set("Content-Type", "text/xml")
+		if r.Form.Get("AutoScalingGroupNames.member.1") != "asg" {
+			fmt.Fprint(w, `<DescribeAutoScalingGroupsResponse><DescribeAutoScalingGroupsResult>
+				<AutoScalingGroups></AutoScalingGroups>
+			</DescribeAutoScalingGroupsResult></DescribeAutoScalingGroupsResponse>`)
+			return
+		}
+		fmt.Fprint(w, `<DescribeAutoScalingGroupsResponse><DescribeAutoScalingGroupsResult>
+			<AutoScalingGroups><member>
+				<AutoScalingGroupName>asg</AutoScalingGroupName>
+				<AvailabilityZones>
+					<member>us-east-1a</member>
+					<member>us-east-1b</member>
+				</AvailabilityZones>
+			</member></AutoScalingGroups>
+		</DescribeAutoScalingGroupsResult></DescribeAutoScalingGroupsResponse>`)
+	})
+
+	s := &awsOps{autoscaling: newTestAutoscaling(t, handler)}
+	zones, err := s.GetInstanceGroupZones("asg")
+	require.NoError(t, err)
+	require.Equal(t, []string{"us-east-1a", "us-east-1b"}, zones)
+
+	_, err = s.GetInstanceGroupZones("missing")
+	require.Error(t, err)
+}
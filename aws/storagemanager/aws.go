@@ -37,13 +37,14 @@ func NewAWSStorageManager(
 func (a *awsStorageManager) GetStorageDistribution(
 	request *cloudops.StorageDistributionRequest,
 ) (*cloudops.StorageDistributionResponse, error) {
+	decisionMatrix := storagedistribution.FilterForRequest(a.decisionMatrix, request)
 	response := &cloudops.StorageDistributionResponse{}
-	for _, userRequest := range request.UserStorageSpec {
+	for _, userRequest := range storagedistribution.UserStorageSpecs(request) {
 		// for for request, find how many instances per zone needs to have storage
 		// and the storage spec for each of them
 		instStorage, instancePerZone, row, err :=
 			storagedistribution.GetStorageDistributionForPool(
-				a.decisionMatrix,
+				decisionMatrix,
 				userRequest,
 				request.InstancesPerZone,
 				request.ZoneCount,
@@ -59,13 +60,28 @@ func (a *awsStorageManager) GetStorageDistribution(
 				InstancesPerZone: instancePerZone,
 				DriveCount:       instStorage.DriveCount,
 				IOPS:             determineIOPSForPool(instStorage, row, userRequest.IOPS),
+				ThinProvisioning: instStorage.ThinProvisioning,
 			},
 		)
+		if request.IncludeDecisionMatrixRows {
+			response.DecisionMatrixRows = append(response.DecisionMatrixRows, row)
+		}
 
 	}
 	return response, nil
 }
 
+func (a *awsStorageManager) GetStorageDistributionWithExplanation(
+	request *cloudops.StorageDistributionRequest,
+) (*cloudops.StorageDistributionResponse, []cloudops.CandidateEvaluation, error) {
+	evaluations, err := storagedistribution.ExplainStorageDistribution(a.decisionMatrix, request)
+	if err != nil {
+		return nil, evaluations, err
+	}
+	response, err := a.GetStorageDistribution(request)
+	return response, evaluations, err
+}
+
 func (a *awsStorageManager) RecommendStoragePoolUpdate(
 	request *cloudops.StoragePoolUpdateRequest) (*cloudops.StoragePoolUpdateResponse, error) {
 	resp, row, err := storagedistribution.GetStorageUpdateConfig(request, a.decisionMatrix)
@@ -85,7 +101,14 @@ func (a *awsStorageManager) GetMaxDriveSize(
 	return resp, err
 }
 
+func (a *awsStorageManager) ValidateStorageSpec(spec *cloudops.StorageSpec) error {
+	return storagedistribution.ValidateStorageSpec(spec, a.decisionMatrix)
+}
+
 func determineIOPSForPool(instStorage *cloudops.StoragePoolSpec, row *cloudops.StorageDecisionMatrixRow, currentIOPS uint64) uint64 {
+	if iops, ok := storagedistribution.DetermineIOPSForPool(instStorage, row); ok {
+		return iops
+	}
 	if instStorage.DriveType == DriveTypeGp2 {
 		return instStorage.DriveCapacityGiB * Gp2IopsMultiplier
 	} else if instStorage.DriveType == DriveTypeIo1 || instStorage.DriveType == DriveTypeGp3 {
@@ -29,8 +29,10 @@ type updateTestInput struct {
 func TestAWSStorageManager(t *testing.T) {
 	t.Run("setup", setup)
 	t.Run("storageDistribution", storageDistribution)
+	t.Run("storageDistributionDecisionMatrixRows", storageDistributionDecisionMatrixRows)
 	t.Run("storageUpdate", storageUpdate)
 	t.Run("maxDriveSize", maxDriveSize)
+	t.Run("validateStorageSpec", validateStorageSpec)
 }
 
 func setup(t *testing.T) {
@@ -414,6 +416,40 @@ func storageDistribution(t *testing.T) {
 
 }
 
+func storageDistributionDecisionMatrixRows(t *testing.T) {
+	request := &cloudops.StorageDistributionRequest{
+		UserStorageSpec: []*cloudops.StorageSpec{
+			&cloudops.StorageSpec{
+				IOPS:        1000,
+				MinCapacity: 1024,
+				MaxCapacity: 4096,
+			},
+		},
+		InstanceType:     "foo",
+		InstancesPerZone: 3,
+		ZoneCount:        2,
+	}
+
+	// Rows are not returned unless requested
+	response, err := storageManager.GetStorageDistribution(request)
+	require.NoError(t, err, "Unexpected error on GetStorageDistribution")
+	require.Empty(t, response.DecisionMatrixRows)
+
+	request.IncludeDecisionMatrixRows = true
+	response, err = storageManager.GetStorageDistribution(request)
+	require.NoError(t, err, "Unexpected error on GetStorageDistribution")
+	require.Len(t, response.DecisionMatrixRows, len(response.InstanceStorage))
+
+	row := response.DecisionMatrixRows[0]
+	require.NotNil(t, row)
+	require.Equal(t, "gp2", row.DriveType)
+	require.Equal(t, uint64(950), row.MinIOPS)
+	require.Equal(t, uint64(1000), row.MaxIOPS)
+	require.True(t, response.InstanceStorage[0].DriveCapacityGiB >= row.MinSize &&
+		response.InstanceStorage[0].DriveCapacityGiB <= row.MaxSize,
+		"selected row does not cover the returned drive size")
+}
+
 func storageUpdate(t *testing.T) {
 	testMatrix := []updateTestInput{
 		{
@@ -846,3 +882,68 @@ func logUpdateTestInput(test updateTestInput) {
 			responseInstStorage.DriveCapacityGiB, responseInstStorage.DriveType)
 	}
 }
+
+func validateStorageSpec(t *testing.T) {
+	testMatrix := []struct {
+		spec       *cloudops.StorageSpec
+		constraint string
+	}{
+		{
+			// Test1: satisfiable spec
+			spec: &cloudops.StorageSpec{
+				IOPS:        1000,
+				MinCapacity: 1024,
+				MaxCapacity: 4096,
+			},
+		},
+		{
+			// Test2: unknown drive type
+			spec: &cloudops.StorageSpec{
+				DriveType:   "invalid_drive",
+				MinCapacity: 1024,
+				MaxCapacity: 4096,
+			},
+			constraint: cloudops.StorageSpecConstraintDriveType,
+		},
+		{
+			// Test3: IOPS above what the drive type can provide
+			spec: &cloudops.StorageSpec{
+				DriveType:   "gp3",
+				IOPS:        20000,
+				MinCapacity: 1024,
+				MaxCapacity: 4096,
+			},
+			constraint: cloudops.StorageSpecConstraintIOPS,
+		},
+		{
+			// Test4: min capacity above max capacity
+			spec: &cloudops.StorageSpec{
+				MinCapacity: 4096,
+				MaxCapacity: 1024,
+			},
+			constraint: cloudops.StorageSpecConstraintCapacity,
+		},
+		{
+			// Test5: max capacity below the smallest io1 drive
+			spec: &cloudops.StorageSpec{
+				DriveType:   "io1",
+				MinCapacity: 10,
+				MaxCapacity: 20,
+			},
+			constraint: cloudops.StorageSpecConstraintCapacity,
+		},
+	}
+
+	for j, test := range testMatrix {
+		fmt.Println("Executing test case: ", j+1)
+		err := storageManager.ValidateStorageSpec(test.spec)
+		if len(test.constraint) == 0 {
+			require.NoError(t, err, "Unexpected error on ValidateStorageSpec")
+			continue
+		}
+		require.Error(t, err, "ValidateStorageSpec should have returned an error")
+		specErr, ok := err.(*cloudops.ErrUnsatisfiableStorageSpec)
+		require.True(t, ok, "received unexpected type of error: %v", err)
+		require.Equal(t, test.constraint, specErr.Constraint)
+	}
+}
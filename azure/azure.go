@@ -8,8 +8,10 @@ import (
 	"math"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -20,6 +22,7 @@ import (
 	"github.com/Azure/go-autorest/autorest/azure"
 	"github.com/Azure/go-autorest/autorest/azure/auth"
 	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/google/uuid"
 	"github.com/libopenstorage/cloudops"
 	"github.com/libopenstorage/cloudops/backoff"
 	"github.com/libopenstorage/cloudops/unsupported"
@@ -28,44 +31,91 @@ import (
 )
 
 const (
-	envInstanceID         = "AZURE_INSTANCE_ID"
-	envScaleSetName       = "AZURE_SCALE_SET_NAME"
-	envSubscriptionID     = "AZURE_SUBSCRIPTION_ID"
-	envResourceGroupName  = "AZURE_RESOURCE_GROUP_NAME"
-	envManagedClusterName = "AZURE_MANAGED_CLUSTER_NAME"
-	envAgentPoolName      = "AZURE_AGENT_POOL_NAME"
-	envUserAgent          = "AZURE_HTTP_USER_AGENT"
-	metadataAPIEndpoint   = "http://169.254.169.254/metadata/instance/compute"
-	metadataAPIVersion    = "2019-03-11"
-	scaleSetNameKey       = "vmScaleSetName"
-	resourceGroupNameKey  = "resourceGroupName"
-	subscriptionIDKey     = "subscriptionId"
-	cloudEnvironmentKey   = "azEnvironment"
-	userAgentKey          = "useAgent"
-	vmIDKey               = "vmId"
+	envInstanceID              = "AZURE_INSTANCE_ID"
+	envScaleSetName            = "AZURE_SCALE_SET_NAME"
+	envSubscriptionID          = "AZURE_SUBSCRIPTION_ID"
+	envResourceGroupName       = "AZURE_RESOURCE_GROUP_NAME"
+	envManagedClusterName      = "AZURE_MANAGED_CLUSTER_NAME"
+	envAgentPoolName           = "AZURE_AGENT_POOL_NAME"
+	envUserAgent               = "AZURE_HTTP_USER_AGENT"
+	envDiskPrefix              = "AZURE_DISK_PREFIX"
+	metadataAPIEndpoint        = "http://169.254.169.254/metadata/instance/compute"
+	metadataNetworkAPIEndpoint = "http://169.254.169.254/metadata/instance/network"
+	metadataAPIVersion         = "2019-03-11"
+	scaleSetNameKey            = "vmScaleSetName"
+	resourceGroupNameKey       = "resourceGroupName"
+	subscriptionIDKey          = "subscriptionId"
+	cloudEnvironmentKey        = "azEnvironment"
+	userAgentKey               = "useAgent"
+	vmIDKey                    = "vmId"
 )
 
+// ReplicaRetentionKey is the ConfigureReplication option setting the number
+// of replicas of the disk kept in the target region. Defaults to 1.
+const ReplicaRetentionKey = "replica-retention"
+
+// ResourceGroupKey is the option of the disk operations used to select the
+// resource group of the disk. Defaults to the resource group of the client, or
+// for disks attached to the instance, to the resource group of the data disk.
+const ResourceGroupKey = "resource-group"
+
 const (
 	name                                = "azure"
 	userAgentExtension                  = "osd"
-	azureDiskPrefix                     = "/dev/disk/azure/scsi1/lun"
 	snapNameFormat                      = "2006-01-02_15.04.05.999999"
 	clientPollingDelay                  = 5 * time.Second
 	devicePathMaxRetryCount             = 3
 	devicePathRetryInterval             = 2 * time.Second
 	errCodeAttachDiskWhileBeingDetached = "AttachDiskWhileBeingDetached"
-	maxThroughputUltra                  = 10000
-	minThroughputUltra                  = 1
-	maxIopsUltra                        = 400000
-	minIopsUltra                        = 100
-	maxThroughputV2                     = 1200
-	minThroughputV2                     = 125
-	maxIopsV2                           = 80000
-	minIopsV2                           = 3000
+	// poolNameTag and legacyPoolNameTag are the tags AKS sets on the scale
+	// set of an agent pool with the name of the agent pool
+	poolNameTag       = "aks-managed-poolName"
+	legacyPoolNameTag = "poolName"
+	// maxAttachConflictRetries is the number of detach-then-reattach cycles
+	// attempted for a disk stuck in AttachDiskWhileBeingDetached before giving up
+	maxAttachConflictRetries = 3
+	// minDiskSizeGB is the minimum size of a managed disk
+	minDiskSizeGB = 1
+	// provisioningStateSucceeded is the provisioning state of disks which are
+	// not being updated
+	provisioningStateSucceeded = "Succeeded"
+	// minUltraDiskSizeGB is the minimum size of an ultra disk
+	minUltraDiskSizeGB = 4
+	// replicationSourceTag is the snapshot tag recording the disk a
+	// replication snapshot or replica was taken of
+	replicationSourceTag = "cloudops-replication-source"
+	// replicationTargetRegionTag is the disk tag recording the region a disk is
+	// being replicated to
+	replicationTargetRegionTag = "cloudops-replication-target-region"
+	maxThroughputUltra         = 10000
+	minThroughputUltra         = 1
+	maxIopsUltra               = 400000
+	minIopsUltra               = 100
+	maxThroughputV2            = 1200
+	minThroughputV2            = 125
+	maxIopsV2                  = 80000
+	minIopsV2                  = 3000
+	// layoutDiskPrefix is the name prefix of disks created by ProvisionStorageLayout
+	layoutDiskPrefix = "cloudops"
+	// capabilities of VM size SKUs holding the uncached disk limits of the size
+	uncachedDiskIOPSCapability           = "UncachedDiskIOPS"
+	uncachedDiskBytesPerSecondCapability = "UncachedDiskBytesPerSecond"
+	// capabilities of disk SKUs holding the size and IOPS limits of a
+	// performance tier. Ultra and Premium SSD v2 disks report their IOPS limits
+	// under the ReadWrite variants.
+	minSizeGiBCapability       = "MinSizeGiB"
+	maxSizeGiBCapability       = "MaxSizeGiB"
+	minIOPSCapability          = "MinIOps"
+	maxIOPSCapability          = "MaxIOps"
+	minIOPSReadWriteCapability = "MinIOpsReadWrite"
+	maxIOPSReadWriteCapability = "MaxIOpsReadWrite"
 )
 
 var (
 	attachFailureMessageRegex = regexp.MustCompile(`^Cannot attach data disk '(.*)' to VM`)
+	// azureDiskPrefix is the default path prefix of the LUN symlinks of data
+	// disks. It is a variable so that tests can override it.
+	azureDiskPrefix = "/dev/disk/azure/scsi1/lun"
 )
 
 type azureOps struct {
@@ -78,6 +128,29 @@ type azureOps struct {
 	vmsClient          vmsClient
 	snapshotsClient    *compute.SnapshotsClient
 	agentPoolsClient   *containerservice.AgentPoolsClient
+	// managedClustersClient, scaleSetsClient and scaleSetVMsClient are used to
+	// look up the scale set instances of the AKS agent pools
+	managedClustersClient *containerservice.ManagedClustersClient
+	scaleSetsClient       *compute.VirtualMachineScaleSetsClient
+	scaleSetVMsClient     *compute.VirtualMachineScaleSetVMsClient
+	// resourceSkusClient is used to look up the disk limits of VM sizes
+	resourceSkusClient *compute.ResourceSkusClient
+	// usageClient is used to look up the disk quotas of the subscription
+	usageClient *compute.UsageClient
+	// restorePointCollectionsClient and restorePointsClient are used to take
+	// application-consistent snapshots through VM restore points
+	restorePointCollectionsClient *compute.RestorePointCollectionsClient
+	restorePointsClient           *compute.RestorePointsClient
+	// rateLimits records the remaining request budget reported by the clients
+	rateLimits *rateLimitTracker
+	// opsTimeout configures how long to wait for disk operations to complete
+	opsTimeout cloudops.OpsTimeoutConfig
+	// devicePathCache caches the resolved device paths of attached disks
+	devicePathCache *cloudops.DevicePathCache
+	// diskPrefix overrides the path prefix of the LUN symlinks of data disks
+	diskPrefix string
+	// logger if set is used for all the log lines, see SetLogger
+	logger *logrus.Entry
 }
 
 // Config contains everything needed to create an Azure client.
@@ -92,19 +165,36 @@ type Config struct {
 	ManagedClusterName string
 	AgentPoolName      string
 	UserAgent          string
+	// DiskPrefix is the path prefix of the LUN symlinks of data disks, for OS
+	// images whose udev rules create the symlinks elsewhere. Defaults to
+	// /dev/disk/azure/scsi1/lun.
+	DiskPrefix string
+	// RateLimitThreshold enables adaptive backoff, lengthening the intervals
+	// between retries once the remaining request budget reported in the Azure
+	// rate limit headers falls below it. Fixed backoff is used if it is not set.
+	RateLimitThreshold int64
+	// Backoff configures the retries of throttled requests. The retries
+	// default to backoff.DefaultExponentialBackoff if it is not set.
+	Backoff *backoff.Config
+	// OpsTimeout configures how long to wait for disk operations to complete.
+	// The default provider ops timeout is used if it is not set.
+	OpsTimeout cloudops.OpsTimeoutConfig
+	// Logger is used for all the log lines of the client, e.g. to tag them
+	// with a request ID. The global logrus logger is used if it is not set.
+	Logger *logrus.Entry
 }
 
 // updateUltraIopsThroughput - validates if the requested IOPS and throuput are in range - If not update with minimum
 func updateUltraIopsThroughput(size int32, reqIops, reqTP *int64) {
 	minAllowedIOPS := int64(math.Max(minIopsUltra, float64(size)))
-	maxAllowedIOPS := int64(math.Min(maxIopsUltra, float64(size*300)))
+	maxAllowedIOPS := maxUltraIops(size)
 	if reqIops != nil {
 		if *reqIops < minAllowedIOPS || *reqIops > maxAllowedIOPS {
 			logrus.Warnf("UltraDisk : Requested IOPS: [%v] not in range for size: [%v] - defaulting to minimum iops: [%v]", *reqIops, size, minAllowedIOPS)
 			*reqIops = minAllowedIOPS
 		}
 		minAllowedTP := int64(math.Max(minThroughputUltra, math.Ceil(float64(*reqIops)*4/1024)))
-		maxAllowedTP := int64(math.Min(maxThroughputUltra, float64(*reqIops*256/1024)))
+		maxAllowedTP := maxUltraThroughput(*reqIops)
 		if reqTP != nil && (*reqTP < minAllowedTP || *reqTP > maxAllowedTP) {
 			logrus.Warnf("UltraDisk : Requested throughput [%v] not in range for iops [%v] - defaulting to minimum throughput : [%v]", *reqTP, *reqIops, minAllowedTP)
 			*reqTP = minAllowedTP
@@ -114,13 +204,13 @@ func updateUltraIopsThroughput(size int32, reqIops, reqTP *int64) {
 
 // updatePremiumv2IopsThroughput - validates if the requested IOPS and throuput are in range - If not update with minimum
 func updatePremiumv2IopsThroughput(size int32, reqIops, reqTP *int64) {
-	maxAllowedIOPS := int64(math.Min(maxIopsV2, float64(size*500)))
+	maxAllowedIOPS := maxPremiumv2Iops(size)
 	if reqIops != nil {
 		if *reqIops < minIopsV2 || *reqIops > maxAllowedIOPS {
 			logrus.Warnf("Premiumv2 : Requested IOPS: [%v] not in range for size: [%v] - defaulting to minimum iops: [%v]", *reqIops, size, minIopsV2)
 			*reqIops = minIopsV2
 		}
-		maxAllowedTP := int64(math.Min(maxThroughputV2, float64(*reqIops/4))) // maximum TP = IOPS * 0.25
+		maxAllowedTP := maxPremiumv2Throughput(*reqIops)
 		if reqTP != nil && (*reqTP < minThroughputV2 || *reqTP > maxAllowedTP) {
 			logrus.Warnf("Premiumv2 : Requested throughput [%v] not in range for iops [%v] - defaulting to minimum throughput : [%v]", *reqTP, *reqIops, minThroughputV2)
 			*reqTP = minThroughputV2
@@ -128,6 +218,53 @@ func updatePremiumv2IopsThroughput(size int32, reqIops, reqTP *int64) {
 	}
 }
 
+// maxUltraIops returns the maximum IOPS of an ultra disk of the given size
+func maxUltraIops(size int32) int64 {
+	return int64(math.Min(maxIopsUltra, float64(size*300)))
+}
+
+// maxUltraThroughput returns the maximum throughput of an ultra disk with the given IOPS
+func maxUltraThroughput(iops int64) int64 {
+	return int64(math.Min(maxThroughputUltra, float64(iops*256/1024)))
+}
+
+// maxPremiumv2Iops returns the maximum IOPS of a premium v2 disk of the given size
+func maxPremiumv2Iops(size int32) int64 {
+	return int64(math.Min(maxIopsV2, float64(size*500)))
+}
+
+// maxPremiumv2Throughput returns the maximum throughput of a premium v2 disk
+// with the given IOPS
+func maxPremiumv2Throughput(iops int64) int64 {
+	return int64(math.Min(maxThroughputV2, float64(iops/4))) // maximum TP = IOPS * 0.25
+}
+
+// scaleIopsThroughput grows the provisioned IOPS and throughput of an ultra
+// or premium v2 disk in proportion to the growth of the disk from oldSize to
+// newSize, up to the maximum allowed for the new size
+func scaleIopsThroughput(sku compute.DiskStorageAccountTypes, oldSize, newSize int32, iops, throughput *int64) {
+	if iops == nil {
+		return
+	}
+	var maxIops func(int32) int64
+	var maxThroughput func(int64) int64
+	switch sku {
+	case compute.UltraSSDLRS:
+		maxIops, maxThroughput = maxUltraIops, maxUltraThroughput
+	case compute.PremiumV2LRS:
+		maxIops, maxThroughput = maxPremiumv2Iops, maxPremiumv2Throughput
+	default:
+		return
+	}
+
+	*iops = int64(math.Min(float64(maxIops(newSize)),
+		float64(cloudops.ScaleToSize(uint64(*iops), uint64(oldSize), uint64(newSize)))))
+	if throughput != nil {
+		*throughput = int64(math.Min(float64(maxThroughput(*iops)),
+			float64(cloudops.ScaleToSize(uint64(*throughput), uint64(oldSize), uint64(newSize)))))
+	}
+}
+
 // calculateMinThroughput calculates the minimum throughput given the IOPS for Ultra Disks
 func calculateMinThroughput(iops int64) int64 {
 	// Calculate the throughput in MB/s with a ceiling function
@@ -140,7 +277,7 @@ func calculateMinThroughput(iops int64) int64 {
 // variables or based on instance metadata info available inside Azure VM
 func NewClientFromMetadata() (cloudops.Ops, error) {
 	if onAzure, computeMetadata, err := onAzure(); onAzure && err == nil {
-		logrus.Info("Running on Azure IaaS VM")
+		cloudops.ProviderLogger(nil, string(cloudops.Azure), "NewClient", "").Info("Running on Azure IaaS VM")
 		var config Config
 
 		if resourceGroup, ok := computeMetadata[resourceGroupNameKey]; ok {
@@ -174,7 +311,7 @@ func NewClientFromMetadata() (cloudops.Ops, error) {
 		return NewClient(config)
 	}
 
-	logrus.Info("Not running on Azure IaaS VM")
+	cloudops.ProviderLogger(nil, string(cloudops.Azure), "NewClient", "").Info("Not running on Azure IaaS VM")
 	return NewEnvClient()
 }
 
@@ -250,6 +387,7 @@ func NewEnvClient() (cloudops.Ops, error) {
 		ManagedClusterName: os.Getenv(envManagedClusterName),
 		AgentPoolName:      os.Getenv(envAgentPoolName),
 		UserAgent:          os.Getenv(envUserAgent),
+		DiskPrefix:         os.Getenv(envDiskPrefix),
 	}
 
 	return NewClient(config)
@@ -271,44 +409,126 @@ func NewClient(config Config) (cloudops.Ops, error) {
 		config.UserAgent = userAgentExtension
 	}
 
+	rateLimits := &rateLimitTracker{}
+
 	disksClient := compute.NewDisksClientWithBaseURI(baseURI, config.SubscriptionID)
 	disksClient.Authorizer = authorizer
 	disksClient.PollingDelay = clientPollingDelay
 	disksClient.AddToUserAgent(config.UserAgent)
+	disksClient.ResponseInspector = rateLimits.inspector()
 
-	vmsClient := newVMsClient(config, baseURI, authorizer)
+	vmsClient := newVMsClient(config, baseURI, authorizer, rateLimits.inspector())
 
 	snapshotsClient := compute.NewSnapshotsClientWithBaseURI(baseURI, config.SubscriptionID)
 	snapshotsClient.Authorizer = authorizer
 	snapshotsClient.PollingDelay = clientPollingDelay
 	snapshotsClient.AddToUserAgent(config.UserAgent)
+	snapshotsClient.ResponseInspector = rateLimits.inspector()
 
 	agentPoolsClient := containerservice.NewAgentPoolsClientWithBaseURI(baseURI, config.SubscriptionID)
 	agentPoolsClient.Authorizer = authorizer
 	agentPoolsClient.PollingDelay = clientPollingDelay
 	agentPoolsClient.AddToUserAgent(config.UserAgent)
+	agentPoolsClient.ResponseInspector = rateLimits.inspector()
+
+	managedClustersClient := containerservice.NewManagedClustersClientWithBaseURI(baseURI, config.SubscriptionID)
+	managedClustersClient.Authorizer = authorizer
+	managedClustersClient.PollingDelay = clientPollingDelay
+	managedClustersClient.AddToUserAgent(config.UserAgent)
+	managedClustersClient.ResponseInspector = rateLimits.inspector()
+
+	scaleSetsClient := compute.NewVirtualMachineScaleSetsClientWithBaseURI(baseURI, config.SubscriptionID)
+	scaleSetsClient.Authorizer = authorizer
+	scaleSetsClient.PollingDelay = clientPollingDelay
+	scaleSetsClient.AddToUserAgent(config.UserAgent)
+	scaleSetsClient.ResponseInspector = rateLimits.inspector()
+
+	scaleSetVMsClient := compute.NewVirtualMachineScaleSetVMsClientWithBaseURI(baseURI, config.SubscriptionID)
+	scaleSetVMsClient.Authorizer = authorizer
+	scaleSetVMsClient.PollingDelay = clientPollingDelay
+	scaleSetVMsClient.AddToUserAgent(config.UserAgent)
+	scaleSetVMsClient.ResponseInspector = rateLimits.inspector()
+
+	resourceSkusClient := compute.NewResourceSkusClientWithBaseURI(baseURI, config.SubscriptionID)
+	resourceSkusClient.Authorizer = authorizer
+	resourceSkusClient.AddToUserAgent(config.UserAgent)
+	resourceSkusClient.ResponseInspector = rateLimits.inspector()
+
+	usageClient := compute.NewUsageClientWithBaseURI(baseURI, config.SubscriptionID)
+	usageClient.Authorizer = authorizer
+	usageClient.AddToUserAgent(config.UserAgent)
+	usageClient.ResponseInspector = rateLimits.inspector()
+
+	restorePointCollectionsClient := compute.NewRestorePointCollectionsClientWithBaseURI(baseURI, config.SubscriptionID)
+	restorePointCollectionsClient.Authorizer = authorizer
+	restorePointCollectionsClient.AddToUserAgent(config.UserAgent)
+	restorePointCollectionsClient.ResponseInspector = rateLimits.inspector()
+
+	restorePointsClient := compute.NewRestorePointsClientWithBaseURI(baseURI, config.SubscriptionID)
+	restorePointsClient.Authorizer = authorizer
+	restorePointsClient.PollingDelay = clientPollingDelay
+	restorePointsClient.AddToUserAgent(config.UserAgent)
+	restorePointsClient.ResponseInspector = rateLimits.inspector()
+
+	ops := &azureOps{
+		Compute:                       unsupported.NewUnsupportedCompute(),
+		instance:                      config.InstanceID,
+		resourceGroupName:             config.ResourceGroupName,
+		managedClusterName:            config.ManagedClusterName,
+		agentPoolName:                 config.AgentPoolName,
+		disksClient:                   &disksClient,
+		vmsClient:                     vmsClient,
+		snapshotsClient:               &snapshotsClient,
+		agentPoolsClient:              &agentPoolsClient,
+		managedClustersClient:         &managedClustersClient,
+		scaleSetsClient:               &scaleSetsClient,
+		scaleSetVMsClient:             &scaleSetVMsClient,
+		resourceSkusClient:            &resourceSkusClient,
+		usageClient:                   &usageClient,
+		restorePointCollectionsClient: &restorePointCollectionsClient,
+		restorePointsClient:           &restorePointsClient,
+		rateLimits:                    rateLimits,
+		opsTimeout:                    config.OpsTimeout,
+		devicePathCache:               cloudops.NewDevicePathCache(cloudops.DevicePathCacheTTL),
+		diskPrefix:                    config.DiskPrefix,
+		logger:                        config.Logger,
+	}
+	return withBackoff(ops, config)
+}
 
-	return backoff.NewExponentialBackoffOps(
-		&azureOps{
-			Compute:            unsupported.NewUnsupportedCompute(),
-			instance:           config.InstanceID,
-			resourceGroupName:  config.ResourceGroupName,
-			managedClusterName: config.ManagedClusterName,
-			agentPoolName:      config.AgentPoolName,
-			disksClient:        &disksClient,
-			vmsClient:          vmsClient,
-			snapshotsClient:    &snapshotsClient,
-			agentPoolsClient:   &agentPoolsClient,
-		},
+// withBackoff wraps the client in the retries configured by the config
+func withBackoff(ops *azureOps, config Config) (cloudops.Ops, error) {
+	return backoff.NewProviderExponentialBackoffOps(
+		ops,
 		isExponentialError,
-		backoff.DefaultExponentialBackoff,
-	), nil
+		config.Backoff,
+		config.RateLimitThreshold,
+	)
+}
+
+// RemainingRequests returns the smallest remaining request budget reported in
+// the rate limit headers of the latest Azure responses.
+func (a *azureOps) RemainingRequests() (int64, bool) {
+	if a.rateLimits == nil {
+		return 0, false
+	}
+	return a.rateLimits.remaining()
 }
 
 func (a *azureOps) Name() string {
 	return string(cloudops.Azure)
 }
 
+// SetLogger sets the logger used for all the log lines of the client
+func (a *azureOps) SetLogger(logger *logrus.Entry) {
+	a.logger = logger
+}
+
+// log returns the logger for a line logged by the given method
+func (a *azureOps) log(method, volumeID string) *logrus.Entry {
+	return cloudops.ProviderLogger(a.logger, a.Name(), method, volumeID)
+}
+
 func (a *azureOps) InstanceID() string {
 	return a.instance
 }
@@ -326,6 +546,153 @@ func (a *azureOps) InspectInstance(instanceID string) (*cloudops.InstanceInfo, e
 	return instInfo, nil
 }
 
+// InspectInstanceInZone inspects the scale set instance with the given name in
+// the given zone. The zone can either be the Azure zone number or the
+// <location>-<zone> form used by the Kubernetes topology labels. All scale sets
+// in the resource group are searched since the instance is not necessarily
+// part of the scale set of the local instance.
+func (a *azureOps) InspectInstanceInZone(instanceID, zone string) (*cloudops.InstanceInfo, error) {
+	ctx := context.Background()
+	scaleSets, err := a.scaleSetsClient.ListComplete(ctx, a.resourceGroupName)
+	if err != nil {
+		return nil, err
+	}
+	for ; scaleSets.NotDone(); err = scaleSets.Next() {
+		if err != nil {
+			return nil, err
+		}
+		scaleSet := scaleSets.Value()
+		if scaleSet.Name == nil {
+			continue
+		}
+
+		vms, err := a.scaleSetVMsClient.ListComplete(ctx, a.resourceGroupName, *scaleSet.Name, "", "", "")
+		if err != nil {
+			return nil, err
+		}
+		for ; vms.NotDone(); err = vms.Next() {
+			if err != nil {
+				return nil, err
+			}
+			vm := vms.Value()
+			if vm.Name == nil || *vm.Name != instanceID {
+				continue
+			}
+			location := to.String(vm.Location)
+			vmZone, ok := scaleSetVMZone(vm, location, zone)
+			if !ok {
+				continue
+			}
+			return &cloudops.InstanceInfo{
+				CloudResourceInfo: cloudops.CloudResourceInfo{
+					Name:   *vm.Name,
+					ID:     to.String(vm.ID),
+					Zone:   vmZone,
+					Region: location,
+					Labels: to.StringMap(vm.Tags),
+				},
+			}, nil
+		}
+	}
+	return nil, &cloudops.ErrNotFound{
+		Type: "Instance",
+		ID:   fmt.Sprintf("%s in zone %s", instanceID, zone),
+	}
+}
+
+// scaleSetVMZone returns the zone of the scale set instance if it matches the
+// requested zone
+func scaleSetVMZone(vm compute.VirtualMachineScaleSetVM, location, zone string) (string, bool) {
+	if vm.Zones == nil {
+		return "", false
+	}
+	for _, vmZone := range *vm.Zones {
+		if vmZone == zone || location+"-"+vmZone == zone {
+			return vmZone, true
+		}
+	}
+	return "", false
+}
+
+// GetNetworkInfo returns the network info of the instance. The Azure compute
+// API only references network interfaces by ID, so the info is read from the
+// instance metadata service which limits this to the local instance.
+func (a *azureOps) GetNetworkInfo(instanceID string) (*cloudops.NetworkInfo, error) {
+	if instanceID != a.instance {
+		return nil, &cloudops.ErrNotSupported{
+			Operation: "GetNetworkInfo",
+			Reason:    "network info is only available for the local instance",
+		}
+	}
+
+	req, err := http.NewRequest("GET", metadataNetworkAPIEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Metadata", "True")
+	q := req.URL.Query()
+	q.Add("format", "json")
+	q.Add("api-version", metadataAPIVersion)
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Error occured while getting network metadata from Azure Metadata API. Error:[%v]", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Error querying Azure network metadata: Code %d returned for url %s", resp.StatusCode, req.URL)
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Error while reading Azure network metadata response: [%v]", err)
+	}
+	return networkInfoFromMetadata(respBody)
+}
+
+// networkMetadata is the subset of the instance metadata network response
+// used to build the network info
+type networkMetadata struct {
+	Interface []struct {
+		IPv4 struct {
+			IPAddress []struct {
+				PrivateIPAddress string `json:"privateIpAddress"`
+			} `json:"ipAddress"`
+			Subnet []struct {
+				Address string `json:"address"`
+				Prefix  string `json:"prefix"`
+			} `json:"subnet"`
+		} `json:"ipv4"`
+	} `json:"interface"`
+}
+
+// networkInfoFromMetadata parses the instance metadata network response. The
+// metadata service does not expose the VNet, so only the subnet and private
+// IPs are populated.
+func networkInfoFromMetadata(data []byte) (*cloudops.NetworkInfo, error) {
+	var metadata networkMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("Error parsing Azure network metadata: %v", err)
+	}
+
+	networkInfo := &cloudops.NetworkInfo{
+		PrivateIPs: make([]string, 0),
+	}
+	for i, nic := range metadata.Interface {
+		if i == 0 && len(nic.IPv4.Subnet) > 0 {
+			subnet := nic.IPv4.Subnet[0]
+			networkInfo.Subnet = subnet.Address + "/" + subnet.Prefix
+		}
+		for _, ip := range nic.IPv4.IPAddress {
+			if len(ip.PrivateIPAddress) > 0 {
+				networkInfo.PrivateIPs = append(networkInfo.PrivateIPs, ip.PrivateIPAddress)
+			}
+		}
+	}
+	return networkInfo, nil
+}
+
 func (a *azureOps) InspectInstanceGroupForInstance(instanceID string) (*cloudops.InstanceGroupInfo, error) {
 
 	ctx := context.Background()
@@ -364,6 +731,38 @@ func (a *azureOps) InspectInstanceGroupForInstance(instanceID string) (*cloudops
 	return retval, nil
 }
 
+// GetClusterVersion returns the kubernetes version of the managed cluster and of
+// the agent pool of the instance
+func (a *azureOps) GetClusterVersion(instanceID string) (string, string, error) {
+	ctx := context.Background()
+	managedCluster, err := a.managedClustersClient.Get(ctx, a.resourceGroupName, a.managedClusterName)
+	if err != nil {
+		return "", "", err
+	}
+	if managedCluster.ManagedClusterProperties == nil {
+		return "", "", fmt.Errorf("managed cluster [%s] in [%s] resource group has no properties",
+			a.managedClusterName, a.resourceGroupName)
+	}
+	controlPlane := to.String(managedCluster.ManagedClusterProperties.CurrentKubernetesVersion)
+	if len(controlPlane) == 0 {
+		controlPlane = to.String(managedCluster.ManagedClusterProperties.KubernetesVersion)
+	}
+
+	agentPool, err := a.agentPoolsClient.Get(ctx, a.resourceGroupName, a.managedClusterName, a.agentPoolName)
+	if err != nil {
+		return "", "", err
+	}
+	if agentPool.ManagedClusterAgentPoolProfileProperties == nil {
+		return "", "", fmt.Errorf("node pool [%s] of cluster [%s] has no properties",
+			a.agentPoolName, a.managedClusterName)
+	}
+	nodePool := to.String(agentPool.ManagedClusterAgentPoolProfileProperties.CurrentOrchestratorVersion)
+	if len(nodePool) == 0 {
+		nodePool = to.String(agentPool.ManagedClusterAgentPoolProfileProperties.OrchestratorVersion)
+	}
+	return controlPlane, nodePool, nil
+}
+
 // GetInstanceGroupSize
 func (a *azureOps) GetInstanceGroupSize(instanceGroupID string) (int64, error) {
 
@@ -381,6 +780,65 @@ func (a *azureOps) GetInstanceGroupSize(instanceGroupID string) (int64, error) {
 
 // SetInstanceGroupSize sets desired node count per availability zone
 // for given instance group
+func (a *azureOps) ListInstanceGroupMembers(instanceGroupID string) ([]string, error) {
+	ctx := context.Background()
+	managedCluster, err := a.managedClustersClient.Get(ctx, a.resourceGroupName, a.managedClusterName)
+	if err != nil {
+		return nil, err
+	}
+	if managedCluster.ManagedClusterProperties == nil ||
+		managedCluster.ManagedClusterProperties.NodeResourceGroup == nil {
+		return nil, fmt.Errorf("got empty node resource group for cluster [%v] in [%v] resource group",
+			a.managedClusterName, a.resourceGroupName)
+	}
+	nodeResourceGroup := *managedCluster.ManagedClusterProperties.NodeResourceGroup
+
+	scaleSetName, err := a.getAgentPoolScaleSetName(nodeResourceGroup, instanceGroupID)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]string, 0)
+	it, err := a.scaleSetVMsClient.ListComplete(ctx, nodeResourceGroup, scaleSetName, "", "", "")
+	if err != nil {
+		return nil, err
+	}
+	for ; it.NotDone(); err = it.Next() {
+		if err != nil {
+			return nil, err
+		}
+		if vm := it.Value(); vm.Name != nil {
+			members = append(members, *vm.Name)
+		}
+	}
+	return members, nil
+}
+
+// getAgentPoolScaleSetName returns the name of the scale set backing the given
+// agent pool in the given node resource group
+func (a *azureOps) getAgentPoolScaleSetName(nodeResourceGroup, agentPoolName string) (string, error) {
+	it, err := a.scaleSetsClient.ListComplete(context.Background(), nodeResourceGroup)
+	if err != nil {
+		return "", err
+	}
+	for ; it.NotDone(); err = it.Next() {
+		if err != nil {
+			return "", err
+		}
+		scaleSet := it.Value()
+		for _, tag := range []string{poolNameTag, legacyPoolNameTag} {
+			if poolName, ok := scaleSet.Tags[tag]; ok && poolName != nil &&
+				*poolName == agentPoolName && scaleSet.Name != nil {
+				return *scaleSet.Name, nil
+			}
+		}
+	}
+	return "", &cloudops.ErrNoInstanceGroup{
+		Reason: fmt.Sprintf("no scale set found for agent pool [%v] in [%v] resource group",
+			agentPoolName, nodeResourceGroup),
+	}
+}
+
 func (a *azureOps) SetInstanceGroupSize(instanceGroupID string,
 	count int64,
 	timeout time.Duration) error {
@@ -430,10 +888,35 @@ func (a *azureOps) Create(
 		)
 	}
 
+	minSize := uint64(minDiskSizeGB)
+	if d.Sku != nil && d.Sku.Name == compute.UltraSSDLRS {
+		minSize = minUltraDiskSizeGB
+	}
+	size, err := cloudops.EnforceMinSize(uint64(*d.DiskProperties.DiskSizeGB), minSize, options)
+	if err != nil {
+		return nil, err
+	}
+	d.DiskProperties.DiskSizeGB = to.Int32Ptr(int32(size))
+
+	// Disks are always encrypted at rest, so only a customer managed key
+	// changes the request
+	encryption, err := cloudops.EncryptionRequested(options)
+	if err != nil {
+		return nil, err
+	}
+	if len(encryption.KmsKeyID) > 0 {
+		d.DiskProperties.Encryption = &compute.Encryption{
+			DiskEncryptionSetID: to.StringPtr(encryption.KmsKeyID),
+			Type:                compute.EncryptionTypeEncryptionAtRestWithCustomerKey,
+		}
+	}
+
+	resourceGroupName := a.resourceGroup(options)
+
 	// Check if the disk already exists; return err if it does
-	_, err := a.disksClient.Get(
+	_, err = a.disksClient.Get(
 		context.Background(),
-		a.resourceGroupName,
+		resourceGroupName,
 		*d.Name,
 	)
 	if err == nil {
@@ -447,6 +930,10 @@ func (a *azureOps) Create(
 	if !ok || code != 404 {
 		return "", err
 	}
+	creationData := d.DiskProperties.CreationData
+	if creationData == nil {
+		creationData = &compute.CreationData{CreateOption: compute.Empty}
+	}
 	// check if IOPS and throughput are in the range , If not - go to minimum and display a warning DOLLY
 	if d.Sku.Name == compute.UltraSSDLRS {
 		updateUltraIopsThroughput(*d.DiskProperties.DiskSizeGB, d.DiskProperties.DiskIOPSReadWrite, d.DiskProperties.DiskMBpsReadWrite)
@@ -456,7 +943,7 @@ func (a *azureOps) Create(
 	ctx := context.Background()
 	future, err := a.disksClient.CreateOrUpdate(
 		ctx,
-		a.resourceGroupName,
+		resourceGroupName,
 		*d.Name,
 		compute.Disk{
 			Location: d.Location,
@@ -465,9 +952,7 @@ func (a *azureOps) Create(
 			Tags:     formatTags(labels),
 			Sku:      d.Sku,
 			DiskProperties: &compute.DiskProperties{
-				CreationData: &compute.CreationData{
-					CreateOption: compute.Empty,
-				},
+				CreationData:                 creationData,
 				DiskSizeGB:                   d.DiskProperties.DiskSizeGB,
 				DiskIOPSReadWrite:            d.DiskProperties.DiskIOPSReadWrite,
 				DiskMBpsReadWrite:            d.DiskProperties.DiskMBpsReadWrite,
@@ -477,18 +962,67 @@ func (a *azureOps) Create(
 		},
 	)
 	if err != nil {
-		return nil, err
+		return nil, encryptionKeyError(err, d.DiskProperties.Encryption)
 	}
 
 	err = future.WaitForCompletionRef(ctx, a.disksClient.Client)
 	if err != nil {
-		return nil, err
+		return nil, encryptionKeyError(err, d.DiskProperties.Encryption)
 	}
 
 	dd, err := future.Result(*a.disksClient)
 	return &dd, err
 }
 
+// CloneVolume creates a disk copied from the source disk. The size, location
+// and SKU of the source disk are used unless the template sets them.
+func (a *azureOps) CloneVolume(
+	sourceVolumeID string,
+	template interface{},
+	labels map[string]string,
+) (interface{}, error) {
+	if err := cloudops.ValidateVolumeID(sourceVolumeID); err != nil {
+		return nil, err
+	}
+	d, ok := template.(*compute.Disk)
+	if !ok || d.Name == nil {
+		return nil, cloudops.NewStorageError(
+			cloudops.ErrVolInval,
+			"Invalid volume template given",
+			a.instance,
+		)
+	}
+
+	source, _, err := a.getDisk(sourceVolumeID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	clone := *d
+	props := compute.DiskProperties{}
+	if d.DiskProperties != nil {
+		props = *d.DiskProperties
+	}
+	if props.DiskSizeGB == nil && source.DiskProperties != nil {
+		props.DiskSizeGB = source.DiskProperties.DiskSizeGB
+	}
+	props.CreationData = &compute.CreationData{
+		CreateOption:     compute.Copy,
+		SourceResourceID: source.ID,
+	}
+	clone.DiskProperties = &props
+	if clone.Location == nil {
+		clone.Location = source.Location
+	}
+	if clone.Sku == nil {
+		clone.Sku = source.Sku
+	}
+	if clone.Zones == nil {
+		clone.Zones = source.Zones
+	}
+	return a.Create(&clone, labels, nil)
+}
+
 func (a *azureOps) GetDeviceID(disk interface{}) (string, error) {
 	if d, ok := disk.(*compute.Disk); ok {
 		return *d.Name, nil
@@ -503,122 +1037,314 @@ func (a *azureOps) GetDeviceID(disk interface{}) (string, error) {
 }
 
 func (a *azureOps) Attach(diskName string, options map[string]string) (string, error) {
-	disk, err := a.checkDiskAttachmentStatus(diskName)
+	if err := cloudops.ValidateVolumeID(diskName); err != nil {
+		return "", err
+	}
+
+	waitRemote, err := cloudops.WaitRemoteRequested(options)
+	if err != nil {
+		return "", err
+	}
+
+	resourceGroupName := a.resourceGroup(options)
+	disk, err := a.checkDiskAttachmentStatus(diskName, resourceGroupName)
 	if err == nil {
 		// Disk is already attached locally, return device path
-		return a.waitForAttach(diskName)
-	} else if se, ok := err.(*cloudops.StorageError); !ok ||
-		se.Code != cloudops.ErrVolDetached {
+		return a.waitForAttach(diskName, resourceGroupName)
+	} else if se, ok := err.(*cloudops.StorageError); ok &&
+		se.Code == cloudops.ErrVolAttachedOnRemoteNode && waitRemote {
+		if disk, err = a.waitForRemoteDetach(diskName, resourceGroupName); err != nil {
+			return "", err
+		}
+	} else if !ok || se.Code != cloudops.ErrVolDetached {
 		return "", err
 	}
 
-	dataDisks, err := a.vmsClient.getDataDisks(a.instance)
-	if err != nil {
+	if err := a.attachDataDisk(a.instance, diskName, disk); err != nil {
 		return "", err
 	}
 
-	nextLun := nextAvailableLun(dataDisks)
-	if nextLun < 0 {
-		return "", fmt.Errorf("No LUN available to attach the disk. "+
-			"%v disks attached to the VM instance", len(dataDisks))
+	return a.waitForAttach(diskName, resourceGroupName)
+}
+
+// AttachByInstanceID attaches the disk to the given VM at its next free LUN and
+// returns the LUN's device path on that VM
+func (a *azureOps) AttachByInstanceID(
+	instanceID, diskName string,
+	options map[string]string,
+) (string, error) {
+	if instanceID == a.instance {
+		return a.Attach(diskName, options)
+	}
+	if err := cloudops.ValidateVolumeID(diskName); err != nil {
+		return "", err
 	}
 
-	newDataDisks := append(
-		dataDisks,
-		compute.DataDisk{
-			Lun:          &nextLun,
-			Name:         to.StringPtr(diskName),
-			DiskSizeGB:   disk.DiskSizeGB,
-			CreateOption: compute.DiskCreateOptionTypesAttach,
-			ManagedDisk: &compute.ManagedDiskParameters{
-				ID: disk.ID,
-			},
+	resourceGroupName := a.resourceGroup(options)
+	disk, err := a.checkDiskAttachmentStatusOn(diskName, resourceGroupName, instanceID)
+	if err == nil {
+		// Disk is already attached to the instance
+		return a.waitForAttachTo(diskName, resourceGroupName, instanceID)
+	} else if se, ok := err.(*cloudops.StorageError); !ok || se.Code != cloudops.ErrVolDetached {
+		return "", err
+	}
+
+	if err := a.attachDataDisk(instanceID, diskName, disk); err != nil {
+		return "", err
+	}
+
+	return a.waitForAttachTo(diskName, resourceGroupName, instanceID)
+}
+
+// waitForAttachTo waits for the disk to be attached to the given VM and
+// returns the device path of its LUN on that VM
+func (a *azureOps) waitForAttachTo(diskName, resourceGroupName, instanceID string) (string, error) {
+	devicePath, err := task.DoRetryWithTimeout(
+		func() (interface{}, bool, error) {
+			disk, err := a.checkDiskAttachmentStatusOn(diskName, resourceGroupName, instanceID)
+			if se, ok := err.(*cloudops.StorageError); ok &&
+				se.Code == cloudops.ErrVolAttachedOnRemoteNode {
+				return "", false, err
+			} else if err != nil {
+				return "", true, err
+			}
+
+			if state := diskState(disk); state == compute.Unattached || state == compute.Reserved {
+				return "", true, cloudops.NewStorageError(
+					cloudops.ErrOperationInProgress,
+					fmt.Sprintf("disk %s is still in state %s", diskName, state),
+					instanceID,
+				)
+			}
+
+			dataDisks, err := a.vmsClient.getDataDisks(instanceID)
+			if err != nil {
+				return "", true, err
+			}
+			for _, d := range dataDisks {
+				if d.Name != nil && *d.Name == diskName && d.Lun != nil {
+					return a.lunPath(*d.Lun), false, nil
+				}
+			}
+			return "", true, cloudops.NewStorageError(
+				cloudops.ErrVolDetached,
+				fmt.Sprintf("disk %s is not yet a data disk of %s", diskName, instanceID),
+				instanceID,
+			)
 		},
+		a.opsTimeout.OpsTimeout(),
+		a.opsTimeout.OpsRetryInterval(),
 	)
-	if err := a.vmsClient.updateDataDisks(a.instance, newDataDisks); err != nil {
-		return "", a.handleAttachError(err)
+	if err != nil {
+		return "", err
+	}
+
+	return devicePath.(string), nil
+}
+
+// updateDataDisks updates the data disks of the instance and invalidates the
+// cached device paths if they are the local instance's, as the LUNs of the
+// disks may have changed
+func (a *azureOps) updateDataDisks(instanceID string, dataDisks []compute.DataDisk) error {
+	if instanceID == a.instance {
+		defer a.devicePathCache.InvalidateAll()
 	}
+	return a.vmsClient.updateDataDisks(instanceID, dataDisks)
+}
+
+// attachDataDisk adds the disk as a data disk of the VM at its next free LUN.
+// Azure sometimes gets stuck on a disk that it previously tried to attach but
+// did not succeed, and rejects the update with AttachDiskWhileBeingDetached
+// until that disk is explicitly removed. The stuck disk is detached and the
+// update retried, up to maxAttachConflictRetries times.
+func (a *azureOps) attachDataDisk(instanceID, diskName string, disk *compute.Disk) error {
+	for conflicts := 0; ; conflicts++ {
+		dataDisks, err := a.vmsClient.getDataDisks(instanceID)
+		if err != nil {
+			return err
+		}
+
+		nextLun := nextAvailableLun(dataDisks)
+		if nextLun < 0 {
+			return fmt.Errorf("No LUN available to attach the disk. "+
+				"%v disks attached to the VM instance %s", len(dataDisks), instanceID)
+		}
+
+		newDataDisks := append(
+			dataDisks,
+			compute.DataDisk{
+				Lun:          &nextLun,
+				Name:         to.StringPtr(diskName),
+				DiskSizeGB:   disk.DiskSizeGB,
+				CreateOption: compute.DiskCreateOptionTypesAttach,
+				ManagedDisk: &compute.ManagedDiskParameters{
+					ID: disk.ID,
+				},
+			},
+		)
+		err = a.updateDataDisks(instanceID, newDataDisks)
+		message, ok := attachConflictMessage(err)
+		if !ok {
+			return err
+		}
+		if conflicts == maxAttachConflictRetries {
+			return cloudops.NewStorageError(
+				cloudops.ErrOperationInProgress,
+				fmt.Sprintf("disk %s is still being detached after %d attach retries: %v",
+					diskName, maxAttachConflictRetries, message),
+				instanceID,
+			)
+		}
 
-	return a.waitForAttach(diskName)
+		matches := attachFailureMessageRegex.FindStringSubmatch(message)
+		if len(matches) == 2 {
+			if err := a.detachInternal(matches[1], instanceID, nil); err != nil {
+				a.log("Attach", diskName).Warnf("Failed to detach disk %v: %v", matches[1], err)
+			}
+		}
+	}
 }
 
-func (a *azureOps) handleAttachError(err error) error {
+// attachConflictMessage returns the message of an AttachDiskWhileBeingDetached
+// error and whether err is one
+func attachConflictMessage(err error) (string, bool) {
 	if de, ok := err.(autorest.DetailedError); ok {
 		if re, ok := de.Original.(azure.RequestError); ok &&
 			re.ServiceError != nil &&
 			re.ServiceError.Code == errCodeAttachDiskWhileBeingDetached {
-			// Azure sometimes gets stuck on a disk that it previously tried to attach
-			// but did not succeed. We need to explicitly remove it to proceed.
-			matches := attachFailureMessageRegex.FindStringSubmatch(re.ServiceError.Message)
-			if len(matches) == 2 {
-				detachErr := a.Detach(matches[1], nil)
-				if detachErr != nil {
-					logrus.Warnf("Failed to detach disk %v: %v", matches[1], detachErr)
-				}
-			}
+			return re.ServiceError.Message, true
 		}
 	}
-	return err
+	return "", false
 }
 
 func (a *azureOps) Detach(diskName string, options map[string]string) error {
-	return a.detachInternal(diskName, a.instance)
+	return a.detachInternal(diskName, a.instance, options)
 }
 
 func (a *azureOps) DetachFrom(diskName, instance string) error {
-	return a.detachInternal(diskName, instance)
+	return a.detachInternal(diskName, instance, nil)
 }
 
-func (a *azureOps) detachInternal(diskName, instance string) error {
-	disk, err := a.disksClient.Get(
-		context.Background(),
-		a.resourceGroupName,
-		diskName,
-	)
-	if derr, ok := err.(autorest.DetailedError); ok {
-		if code, ok := derr.StatusCode.(int); !ok {
-			return err
-		} else if code != 404 {
-			return err
-		}
-	} else if err != nil {
+// detachInternal detaches the data disk with the given name from the instance.
+// The ResourceGroupKey option selects the resource group of the disk when the
+// instance has disks of the same name from several resource groups.
+func (a *azureOps) detachInternal(diskName, instance string, options map[string]string) error {
+	if err := cloudops.ValidateVolumeID(diskName); err != nil {
 		return err
 	}
 
-	// Even if a disk is not found, Azure sometimes thinks that the disk is still
-	// attached to it even though it is not present or does not show up in the
-	// dataDisks list on the VM. The workaround for this is to update the VM
-	// irrespective of whether the disk is present or not.
-	// https://github.com/andyzhangx/demo/blob/master/issues/azuredisk-issues.md#18-detach-azure-disk-make-vm-run-into-a-limbo-state
-
-	var diskToDetach string
-	if disk.ID != nil {
-		diskToDetach = strings.ToLower(*disk.ID)
-	}
-
 	dataDisks, err := a.vmsClient.getDataDisks(instance)
-	if err != nil {
+	if isNotFoundError(err) {
+		// The disks of a deleted instance are detached along with it, so
+		// don't block the teardown of the instance on it
+		a.log("Detach", diskName).Infof("instance %s no longer exists, disk %s is detached", instance, diskName)
+		return nil
+	} else if err != nil {
 		return err
 	}
 
+	// The data disk is identified by the ID of its managed disk, which holds
+	// the resource group of the disk, rather than by looking the disk up in
+	// the resource group of the client.
+	var diskToDetach string
+	for _, d := range dataDisks {
+		if !dataDiskMatches(d, diskName, options[ResourceGroupKey]) {
+			continue
+		}
+		diskToDetach = strings.ToLower(*d.ManagedDisk.ID)
+		break
+	}
+
+	// Even if a disk is not in the dataDisks list on the VM, Azure sometimes
+	// thinks that the disk is still attached to it. The workaround for this is
+	// to update the VM irrespective of whether the disk is present or not.
+	// https://github.com/andyzhangx/demo/blob/master/issues/azuredisk-issues.md#18-detach-azure-disk-make-vm-run-into-a-limbo-state
 	newDataDisks := make([]compute.DataDisk, 0)
 	for _, d := range dataDisks {
-		if strings.ToLower(*d.ManagedDisk.ID) == diskToDetach {
+		if d.ManagedDisk != nil && d.ManagedDisk.ID != nil &&
+			strings.ToLower(*d.ManagedDisk.ID) == diskToDetach {
 			continue
 		}
 		newDataDisks = append(newDataDisks, d)
 	}
 
-	if err := a.vmsClient.updateDataDisks(instance, newDataDisks); err != nil {
+	if err := a.updateDataDisks(instance, newDataDisks); err != nil {
 		return err
 	}
 
-	return a.waitForDetach(diskName, instance)
+	return a.waitForDetach(diskToDetach, instance)
+}
+
+// dataDiskMatches returns true if the data disk is the managed disk with the
+// given name, in the given resource group if one is set
+func dataDiskMatches(d compute.DataDisk, diskName, resourceGroupName string) bool {
+	if d.Name == nil || !strings.EqualFold(*d.Name, diskName) ||
+		d.ManagedDisk == nil || d.ManagedDisk.ID == nil {
+		return false
+	}
+	if len(resourceGroupName) == 0 {
+		return true
+	}
+	resource, err := azure.ParseResourceID(*d.ManagedDisk.ID)
+	return err == nil && strings.EqualFold(resource.ResourceGroup, resourceGroupName)
+}
+
+// dataDiskResourceGroup returns the resource group of the managed disk of the
+// data disk, or defaultGroup if its ID cannot be parsed
+func dataDiskResourceGroup(d compute.DataDisk, defaultGroup string) string {
+	if d.ManagedDisk == nil || d.ManagedDisk.ID == nil {
+		return defaultGroup
+	}
+	resource, err := azure.ParseResourceID(*d.ManagedDisk.ID)
+	if err != nil {
+		return defaultGroup
+	}
+	return resource.ResourceGroup
+}
+
+// getDisk returns the disk along with its resource group. The disk is looked
+// up in the resource group selected by the ResourceGroupKey option. Without
+// the option, a disk that is not in the resource group of the client is looked
+// up in the resource group of the data disk of the same name on the Ops
+// instance, as disks from other resource groups can be attached to it.
+func (a *azureOps) getDisk(diskName string, options map[string]string) (compute.Disk, string, error) {
+	ctx := context.Background()
+	if resourceGroupName := options[ResourceGroupKey]; len(resourceGroupName) > 0 {
+		disk, err := a.disksClient.Get(ctx, resourceGroupName, diskName)
+		return disk, resourceGroupName, err
+	}
+
+	disk, err := a.disksClient.Get(ctx, a.resourceGroupName, diskName)
+	if !isNotFoundError(err) {
+		return disk, a.resourceGroupName, err
+	}
+
+	dataDisks, lookupErr := a.vmsClient.getDataDisks(a.instance)
+	if lookupErr != nil {
+		return disk, a.resourceGroupName, err
+	}
+	for _, d := range dataDisks {
+		if !dataDiskMatches(d, diskName, "") {
+			continue
+		}
+		resource, parseErr := azure.ParseResourceID(*d.ManagedDisk.ID)
+		if parseErr != nil || strings.EqualFold(resource.ResourceGroup, a.resourceGroupName) {
+			break
+		}
+		disk, err = a.disksClient.Get(ctx, resource.ResourceGroup, diskName)
+		return disk, resource.ResourceGroup, err
+	}
+	return disk, a.resourceGroupName, err
 }
 
 func (a *azureOps) Delete(diskName string, options map[string]string) error {
+	if err := cloudops.ValidateVolumeID(diskName); err != nil {
+		return err
+	}
+
 	ctx := context.Background()
-	future, err := a.disksClient.Delete(ctx, a.resourceGroupName, diskName)
+	future, err := a.disksClient.Delete(ctx, a.resourceGroup(options), diskName)
 	if err != nil {
 		return err
 	}
@@ -632,13 +1358,78 @@ func (a *azureOps) Delete(diskName string, options map[string]string) error {
 	return err
 }
 
+// resourceGroup returns the resource group selected by the ResourceGroupKey
+// option, defaulting to the resource group of the client
+func (a *azureOps) resourceGroup(options map[string]string) string {
+	if resourceGroupName := options[ResourceGroupKey]; len(resourceGroupName) > 0 {
+		return resourceGroupName
+	}
+	return a.resourceGroupName
+}
+
 func (a *azureOps) DeleteFrom(diskName, _ string) error {
 	return a.Delete(diskName, nil)
 }
 
+// AreVolumesReadyToExpand returns true if the provisioning of all the disks
+// succeeded, as disks which are still being updated, e.g. by a previous
+// resize, cannot be resized.
 func (a *azureOps) AreVolumesReadyToExpand(volumeIDs []*string) (bool, error) {
-	return true, &cloudops.ErrNotSupported{
-		Operation: "azureOps.IsVolumesReadyToExpand",
+	notReady := make(map[string]string)
+	for _, volumeID := range volumeIDs {
+		disk, _, err := a.getDisk(*volumeID, nil)
+		if err != nil {
+			return false, err
+		}
+		if disk.DiskProperties == nil {
+			return false, fmt.Errorf("disk properties of (%v) is nil", *volumeID)
+		}
+		if state := to.String(disk.DiskProperties.ProvisioningState); state != provisioningStateSucceeded {
+			notReady[*volumeID] = fmt.Sprintf("disk is in %s provisioning state", state)
+		}
+	}
+	if len(notReady) > 0 {
+		return false, &cloudops.ErrVolumesNotReadyToExpand{Volumes: notReady}
+	}
+	return true, nil
+}
+
+// WaitForVolumeState waits until the disk is in the given normalized state.
+// Reserved disks, which are attached to a deallocated VM, are attached.
+func (a *azureOps) WaitForVolumeState(volumeID string, desiredState string, timeout time.Duration) error {
+	getState := func() (string, error) {
+		disk, _, err := a.getDisk(volumeID, nil)
+		if isNotFoundError(err) {
+			return "", cloudops.NewStorageError(cloudops.ErrVolNotFound,
+				fmt.Sprintf("disk %s not found", volumeID), a.instance)
+		} else if err != nil {
+			return "", err
+		}
+		if disk.DiskProperties == nil {
+			return "", fmt.Errorf("disk properties of (%v) is nil", volumeID)
+		}
+		return volumeState(disk.DiskProperties), nil
+	}
+	return cloudops.PollVolumeState(volumeID, desiredState, getState, timeout, a.opsTimeout.OpsRetryInterval())
+}
+
+// volumeState maps the provisioning state and the attachment state of the disk
+// to the normalized volume state
+func volumeState(props *compute.DiskProperties) string {
+	switch state := to.String(props.ProvisioningState); state {
+	case "Creating":
+		return cloudops.VolumeStateCreating
+	case "Deleting":
+		return cloudops.VolumeStateDeleting
+	case "Failed":
+		return cloudops.VolumeStateError
+	case provisioningStateSucceeded:
+		if props.DiskState == compute.Attached || props.DiskState == compute.Reserved {
+			return cloudops.VolumeStateAttached
+		}
+		return cloudops.VolumeStateAvailable
+	default:
+		return state
 	}
 }
 
@@ -647,11 +1438,11 @@ func (a *azureOps) Expand(
 	newSizeInGiB uint64,
 	options map[string]string,
 ) (uint64, error) {
-	disk, err := a.disksClient.Get(
-		context.Background(),
-		a.resourceGroupName,
-		diskName,
-	)
+	if err := cloudops.ValidateVolumeID(diskName); err != nil {
+		return 0, err
+	}
+
+	disk, resourceGroupName, err := a.getDisk(diskName, options)
 	if err != nil {
 		return 0, err
 	}
@@ -670,24 +1461,42 @@ func (a *azureOps) Expand(
 	newSizeInGiBInt32 := int32(newSizeInGiB)
 	disk.DiskProperties.DiskSizeGB = &newSizeInGiBInt32
 
-	// Only for ultra disk, Setting the IOPS and throughput to a minimum Value , if IOPS in not in range.
-	//https://learn.microsoft.com/en-us/azure/virtual-machines/disks-types#ultra-disk-iops
-	if disk.Sku.Name == compute.UltraSSDLRS {
-		updateUltraIopsThroughput(*disk.DiskProperties.DiskSizeGB, disk.DiskProperties.DiskIOPSReadWrite, disk.DiskProperties.DiskMBpsReadWrite)
-		minIops := int64(newSizeInGiBInt32)
-		// Update Readonly iops and readonly throughput to minimum to avoid failure during resize.
-		if *disk.DiskProperties.DiskIOPSReadOnly < minIops {
-			disk.DiskProperties.DiskIOPSReadOnly = &minIops
-		}
-		roThroughput := calculateMinThroughput(*disk.DiskProperties.DiskIOPSReadOnly)
-		if *disk.DiskProperties.DiskMBpsReadOnly < roThroughput {
-			disk.DiskProperties.DiskMBpsReadOnly = &roThroughput
+	scaleIops, err := cloudops.ScaleIopsRequested(options)
+	if err != nil {
+		return oldSizeInGiB, err
+	}
+	if scaleIops && disk.Sku != nil {
+		scaleIopsThroughput(disk.Sku.Name, int32(oldSizeInGiB), newSizeInGiBInt32,
+			disk.DiskProperties.DiskIOPSReadWrite, disk.DiskProperties.DiskMBpsReadWrite)
+	}
+
+	// The IOPS and throughput limits of ultra and premium v2 disks depend on
+	// the size, so the provisioned values are brought within the limits of
+	// the new size, defaulting to the minimum if they are not.
+	// https://learn.microsoft.com/en-us/azure/virtual-machines/disks-types#ultra-disk-iops
+	if disk.Sku != nil {
+		switch disk.Sku.Name {
+		case compute.PremiumV2LRS:
+			updatePremiumv2IopsThroughput(newSizeInGiBInt32, disk.DiskProperties.DiskIOPSReadWrite, disk.DiskProperties.DiskMBpsReadWrite)
+		case compute.UltraSSDLRS:
+			updateUltraIopsThroughput(newSizeInGiBInt32, disk.DiskProperties.DiskIOPSReadWrite, disk.DiskProperties.DiskMBpsReadWrite)
+			minIops := int64(newSizeInGiBInt32)
+			// Update Readonly iops and readonly throughput to minimum to avoid failure during resize.
+			if disk.DiskProperties.DiskIOPSReadOnly != nil && *disk.DiskProperties.DiskIOPSReadOnly < minIops {
+				disk.DiskProperties.DiskIOPSReadOnly = &minIops
+			}
+			if disk.DiskProperties.DiskIOPSReadOnly != nil && disk.DiskProperties.DiskMBpsReadOnly != nil {
+				roThroughput := calculateMinThroughput(*disk.DiskProperties.DiskIOPSReadOnly)
+				if *disk.DiskProperties.DiskMBpsReadOnly < roThroughput {
+					disk.DiskProperties.DiskMBpsReadOnly = &roThroughput
+				}
+			}
 		}
 	}
 	ctx := context.Background()
 	future, err := a.disksClient.CreateOrUpdate(
 		ctx,
-		a.resourceGroupName,
+		resourceGroupName,
 		diskName,
 		disk,
 	)
@@ -720,15 +1529,17 @@ func (a *azureOps) FreeDevices() ([]string, error) {
 }
 
 func (a *azureOps) Inspect(diskNames []*string, options map[string]string) ([]interface{}, error) {
+	if err := cloudops.ValidateVolumeIDs(diskNames); err != nil {
+		return nil, err
+	}
+
 	var disks []interface{}
 
+	resourceGroupName := a.resourceGroup(options)
 	for _, diskName := range diskNames {
-		if diskName == nil {
-			continue
-		}
 		disk, err := a.disksClient.Get(
 			context.Background(),
-			a.resourceGroupName,
+			resourceGroupName,
 			*diskName,
 		)
 		if derr, ok := err.(autorest.DetailedError); ok {
@@ -774,7 +1585,7 @@ func (a *azureOps) DeviceMappings() (map[string]string, error) {
 
 	devMap := make(map[string]string)
 	for _, d := range dataDisks {
-		devPath, err := lunToBlockDevPath(*d.Lun)
+		devPath, err := a.lunToBlockDevPath(*d.Lun)
 		if err != nil {
 			return nil, cloudops.NewStorageError(
 				cloudops.ErrInvalidDevicePath,
@@ -793,13 +1604,12 @@ func (a *azureOps) Enumerate(
 	labels map[string]string,
 	setIdentifier string,
 ) (map[string][]interface{}, error) {
-	allDisks, err := a.getDisks(labels)
-	if err != nil {
+	if err := cloudops.ValidateVolumeIDs(diskNames); err != nil {
 		return nil, err
 	}
 
 	sets := make(map[string][]interface{})
-	for _, disk := range allDisks {
+	if err := a.forEachDisk(labels, func(disk *compute.Disk) error {
 		if len(setIdentifier) == 0 {
 			cloudops.AddElementToMap(sets, disk, cloudops.SetIdentifierNone)
 		} else {
@@ -816,57 +1626,85 @@ func (a *azureOps) Enumerate(
 				cloudops.AddElementToMap(sets, disk, cloudops.SetIdentifierNone)
 			}
 		}
+		return nil
+	}); err != nil {
+		return nil, err
 	}
 
 	return sets, nil
 }
 
 func (a *azureOps) DevicePath(diskName string) (string, error) {
-	if _, err := a.checkDiskAttachmentStatus(diskName); err != nil {
+	if err := cloudops.ValidateVolumeID(diskName); err != nil {
+		return "", err
+	}
+
+	disk, _, err := a.getDisk(diskName, nil)
+	if isNotFoundError(err) {
+		return "", diskNotFoundError(diskName, a.instance)
+	} else if err != nil {
+		return "", err
+	}
+	if err := a.diskAttachmentStatus(&disk, diskName, a.instance); err != nil {
 		return "", err
 	}
 	return a.devicePath(diskName)
 }
 
-// checkDiskAttachmentStatus returns the disk without any error if it is already
-// attached to the Ops instance. It will return errors if the disk is not attached
-// or attached on remote node.
-func (a *azureOps) checkDiskAttachmentStatus(diskName string) (*compute.Disk, error) {
+// checkDiskAttachmentStatus returns the disk in the given resource group without
+// any error if it is already attached to the Ops instance. It will return errors
+// if the disk is not attached or attached on remote node.
+func (a *azureOps) checkDiskAttachmentStatus(diskName, resourceGroupName string) (*compute.Disk, error) {
+	return a.checkDiskAttachmentStatusOn(diskName, resourceGroupName, a.instance)
+}
+
+// checkDiskAttachmentStatusOn returns the disk along with an ErrVolDetached
+// error if it is detached, or an ErrVolAttachedOnRemoteNode error if it is
+// attached to an instance other than the given one
+func (a *azureOps) checkDiskAttachmentStatusOn(
+	diskName, resourceGroupName, instance string,
+) (*compute.Disk, error) {
 	disk, err := a.disksClient.Get(
 		context.Background(),
-		a.resourceGroupName,
+		resourceGroupName,
 		diskName,
 	)
-	if derr, ok := err.(autorest.DetailedError); ok {
-		code, ok := derr.StatusCode.(int)
-		if ok && code == 404 {
-			return nil, cloudops.NewStorageError(
-				cloudops.ErrVolNotFound,
-				fmt.Sprintf("disk %s not found", diskName),
-				a.instance,
-			)
-		}
-	}
-	if err != nil {
+	if isNotFoundError(err) {
+		return nil, diskNotFoundError(diskName, instance)
+	} else if err != nil {
 		return nil, err
 	}
 
+	return &disk, a.diskAttachmentStatus(&disk, diskName, instance)
+}
+
+// diskAttachmentStatus returns an ErrVolDetached error if the disk is
+// detached, or an ErrVolAttachedOnRemoteNode error if it is attached to an
+// instance other than the given one
+func (a *azureOps) diskAttachmentStatus(disk *compute.Disk, diskName, instance string) error {
 	if disk.ManagedBy == nil || len(*disk.ManagedBy) == 0 {
-		return &disk, cloudops.NewStorageError(
+		return cloudops.NewStorageError(
 			cloudops.ErrVolDetached,
 			fmt.Sprintf("disk %s is detached", diskName),
-			a.instance,
+			instance,
 		)
 	}
-	if !strings.HasSuffix(*disk.ManagedBy, a.vmsClient.name(a.instance)) {
-		return &disk, cloudops.NewStorageError(
+	if !strings.HasSuffix(*disk.ManagedBy, a.vmsClient.name(instance)) {
+		return cloudops.NewStorageError(
 			cloudops.ErrVolAttachedOnRemoteNode,
 			fmt.Sprintf("disk %s is attached on remote node %s", diskName, *disk.ManagedBy),
-			a.instance,
+			instance,
 		)
 	}
+	return nil
+}
 
-	return &disk, nil
+func diskNotFoundError(diskName, instance string) error {
+	return cloudops.NewStorageError(
+		cloudops.ErrVolNotFound,
+		fmt.Sprintf("disk %s not found", diskName),
+		instance,
+	)
 }
 
 func (a *azureOps) devicePath(diskName string) (string, error) {
@@ -879,7 +1717,10 @@ func (a *azureOps) devicePath(diskName string) (string, error) {
 		if *d.Name == diskName {
 			// Retry to get the block dev path as it may take few seconds for the path
 			// to be created even after the disk shows attached.
-			devPath, err := lunToBlockDevPathWithRetry(*d.Lun)
+			lun := *d.Lun
+			devPath, err := a.devicePathCache.Resolve(diskName, func() (string, error) {
+				return a.lunToBlockDevPathWithRetry(lun)
+			})
 			if err == nil {
 				return devPath, nil
 			}
@@ -899,34 +1740,83 @@ func (a *azureOps) devicePath(diskName string) (string, error) {
 }
 
 func (a *azureOps) Snapshot(diskName string, readonly bool, options map[string]string) (interface{}, error) {
+	return a.SnapshotWithOptions(diskName, readonly, cloudops.SnapshotOptions{Options: options})
+}
+
+// SnapshotWithOptions snapshots the disk, applying the labels as tags of the
+// snapshot
+func (a *azureOps) SnapshotWithOptions(
+	diskName string,
+	readonly bool,
+	opts cloudops.SnapshotOptions,
+) (interface{}, error) {
+	if err := cloudops.ValidateVolumeID(diskName); err != nil {
+		return nil, err
+	}
+
 	if !readonly {
 		return nil, fmt.Errorf("read-write snapshots are not supported in Azure")
 	}
 
-	disk, err := a.disksClient.Get(context.Background(), a.resourceGroupName, diskName)
+	wait, err := cloudops.WaitForSnapshot(opts.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	appConsistent, err := cloudops.AppConsistentRequested(opts.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	name := opts.Name
+	if len(name) == 0 {
+		name = cloudops.SnapshotName()
+	}
+	tags := make(map[string]*string, len(opts.Labels))
+	for key, value := range opts.Labels {
+		tags[key] = to.StringPtr(value)
+	}
+
+	disk, _, err := a.getDisk(diskName, opts.Options)
 	if err != nil {
 		return nil, err
 	}
 
 	ctx := context.Background()
+	snapshot := compute.Snapshot{
+		Name:     to.StringPtr(name),
+		Location: disk.Location,
+		Tags:     tags,
+		SnapshotProperties: &compute.SnapshotProperties{
+			CreationData: &compute.CreationData{
+				CreateOption:     compute.Copy,
+				SourceResourceID: disk.ID,
+			},
+		},
+	}
+	if appConsistent {
+		sourceID, consistent, err := a.createDiskRestorePoint(disk, *snapshot.Name)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.CreationData.SourceResourceID = sourceID
+		snapshot.Tags[cloudops.SnapshotAppConsistentTagKey] = to.StringPtr(strconv.FormatBool(consistent))
+	}
 	future, err := a.snapshotsClient.CreateOrUpdate(
 		ctx,
 		a.resourceGroupName,
-		fmt.Sprint("snap-", time.Now().Format(snapNameFormat)),
-		compute.Snapshot{
-			Location: disk.Location,
-			SnapshotProperties: &compute.SnapshotProperties{
-				CreationData: &compute.CreationData{
-					CreateOption:     compute.Copy,
-					SourceResourceID: disk.ID,
-				},
-			},
-		},
+		*snapshot.Name,
+		snapshot,
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	if !wait {
+		// The snapshot is still being created, return the requested snapshot
+		return &snapshot, nil
+	}
+
 	err = future.WaitForCompletionRef(ctx, a.snapshotsClient.Client)
 	if err != nil {
 		return nil, err
@@ -936,155 +1826,1303 @@ func (a *azureOps) Snapshot(diskName string, readonly bool, options map[string]s
 	return &snap, err
 }
 
-func (a *azureOps) SnapshotDelete(snapName string, options map[string]string) error {
-	ctx := context.Background()
-	future, err := a.snapshotsClient.Delete(ctx, a.resourceGroupName, snapName)
+// createDiskRestorePoint creates an application-consistent restore point of
+// the VM the disk is attached to, which only includes the given disk, and
+// returns the ID of the disk restore point to snapshot along with whether
+// application consistency was achieved. The disk itself is returned as the
+// source of a crash-consistent snapshot if it is not attached to a VM which
+// supports restore points.
+func (a *azureOps) createDiskRestorePoint(disk compute.Disk, name string) (*string, bool, error) {
+	if disk.ManagedBy == nil {
+		a.log("Snapshot", to.String(disk.Name)).Warnf("disk %s is not attached to a VM, taking a crash-consistent snapshot",
+			to.String(disk.Name))
+		return disk.ID, false, nil
+	}
+	vmName := path.Base(*disk.ManagedBy)
+	desc, err := a.vmsClient.describe(vmName)
 	if err != nil {
-		return err
+		return nil, false, err
 	}
-
-	err = future.WaitForCompletionRef(ctx, a.snapshotsClient.Client)
-	if err != nil {
-		return err
+	vm, ok := desc.(compute.VirtualMachine)
+	if !ok || vm.VirtualMachineProperties == nil || vm.StorageProfile == nil {
+		a.log("Snapshot", to.String(disk.Name)).Warnf("restore points are not supported for the VM of disk %s, taking a crash-consistent snapshot",
+			to.String(disk.Name))
+		return disk.ID, false, nil
 	}
 
-	_, err = future.Result(*a.snapshotsClient)
-	return err
-}
-
-func (a *azureOps) ApplyTags(diskName string, labels map[string]string, options map[string]string) error {
-	if len(labels) == 0 {
-		return nil
+	// exclude all the other disks of the VM from the restore point
+	excludeDisks := make([]compute.APIEntityReference, 0)
+	exclude := func(managedDisk *compute.ManagedDiskParameters) {
+		if managedDisk != nil && managedDisk.ID != nil && !strings.EqualFold(*managedDisk.ID, to.String(disk.ID)) {
+			excludeDisks = append(excludeDisks, compute.APIEntityReference{ID: managedDisk.ID})
+		}
 	}
-
-	disk, err := a.disksClient.Get(
-		context.Background(),
-		a.resourceGroupName,
-		diskName,
-	)
-	if err != nil {
-		return err
+	if vm.StorageProfile.OsDisk != nil {
+		exclude(vm.StorageProfile.OsDisk.ManagedDisk)
 	}
-
-	if len(disk.Tags) == 0 {
-		disk.Tags = make(map[string]*string)
+	if vm.StorageProfile.DataDisks != nil {
+		for _, dataDisk := range *vm.StorageProfile.DataDisks {
+			exclude(dataDisk.ManagedDisk)
+		}
 	}
 
-	for k, v := range labels {
-		disk.Tags[k] = to.StringPtr(v)
+	ctx := context.Background()
+	collectionName := fmt.Sprint("cloudops-", vmName)
+	if _, err := a.restorePointCollectionsClient.CreateOrUpdate(
+		ctx,
+		a.resourceGroupName,
+		collectionName,
+		compute.RestorePointCollection{
+			Location: vm.Location,
+			RestorePointCollectionProperties: &compute.RestorePointCollectionProperties{
+				Source: &compute.RestorePointCollectionSourceProperties{ID: vm.ID},
+			},
+		},
+	); err != nil {
+		return nil, false, err
 	}
 
-	ctx := context.Background()
-	future, err := a.disksClient.Update(
+	// The consistency mode is left unset since Azure takes an
+	// application-consistent restore point by default
+	future, err := a.restorePointsClient.Create(
 		ctx,
 		a.resourceGroupName,
-		diskName,
-		compute.DiskUpdate{
-			Tags: disk.Tags,
+		collectionName,
+		name,
+		compute.RestorePoint{
+			RestorePointProperties: &compute.RestorePointProperties{
+				ExcludeDisks: &excludeDisks,
+			},
 		},
 	)
 	if err != nil {
-		return err
+		return nil, false, err
 	}
-
-	err = future.WaitForCompletionRef(ctx, a.disksClient.Client)
+	if err := future.WaitForCompletionRef(ctx, a.restorePointsClient.Client); err != nil {
+		return nil, false, err
+	}
+	restorePoint, err := future.Result(*a.restorePointsClient)
 	if err != nil {
-		return err
+		return nil, false, err
+	}
+
+	consistent := restorePoint.RestorePointProperties != nil &&
+		restorePoint.ConsistencyMode == compute.ApplicationConsistent
+	if !consistent {
+		a.log("Snapshot", to.String(disk.Name)).Warnf("restore point %s of disk %s is not application-consistent", name, to.String(disk.Name))
+	}
+	if restorePoint.RestorePointProperties != nil &&
+		restorePoint.SourceMetadata != nil &&
+		restorePoint.SourceMetadata.StorageProfile != nil &&
+		restorePoint.SourceMetadata.StorageProfile.DataDisks != nil {
+		for _, dataDisk := range *restorePoint.SourceMetadata.StorageProfile.DataDisks {
+			if dataDisk.ManagedDisk != nil && dataDisk.DiskRestorePoint != nil &&
+				strings.EqualFold(to.String(dataDisk.ManagedDisk.ID), to.String(disk.ID)) {
+				return dataDisk.DiskRestorePoint.ID, consistent, nil
+			}
+		}
 	}
-
-	_, err = future.Result(*a.disksClient)
-	return err
+	return nil, false, fmt.Errorf("restore point %s does not include disk %s", name, to.String(disk.Name))
 }
 
-func (a *azureOps) RemoveTags(diskName string, labels map[string]string, options map[string]string) error {
-	if len(labels) == 0 {
-		return nil
-	}
-
-	disk, err := a.disksClient.Get(
-		context.Background(),
-		a.resourceGroupName,
-		diskName,
-	)
-	if err != nil {
+func (a *azureOps) SnapshotDelete(snapName string, options map[string]string) error {
+	if err := cloudops.ValidateVolumeID(snapName); err != nil {
 		return err
 	}
 
-	if len(disk.Tags) == 0 {
-		return nil
-	}
-
-	for k := range labels {
-		delete(disk.Tags, k)
-	}
-
 	ctx := context.Background()
-	future, err := a.disksClient.Update(
-		ctx,
-		a.resourceGroupName,
-		diskName,
-		compute.DiskUpdate{
-			Tags: disk.Tags,
-		},
-	)
+	future, err := a.snapshotsClient.Delete(ctx, a.resourceGroupName, snapName)
 	if err != nil {
 		return err
 	}
 
-	err = future.WaitForCompletionRef(ctx, a.disksClient.Client)
+	err = future.WaitForCompletionRef(ctx, a.snapshotsClient.Client)
 	if err != nil {
 		return err
 	}
 
-	_, err = future.Result(*a.disksClient)
+	_, err = future.Result(*a.snapshotsClient)
 	return err
 }
 
-func (a *azureOps) Tags(diskName string) (map[string]string, error) {
-	disk, err := a.disksClient.Get(context.Background(), a.resourceGroupName, diskName)
-	if err != nil {
-		return nil, err
-	}
+func (a *azureOps) DeleteSnapshotChain(snapNames []string) error {
+	snaps := make([]*cloudops.SnapshotInfo, 0, len(snapNames))
+	for _, snapName := range snapNames {
+		if err := cloudops.ValidateVolumeID(snapName); err != nil {
+			return err
+		}
 
-	tags := make(map[string]string)
-	for k, v := range disk.Tags {
-		if v == nil {
-			tags[k] = ""
-		} else {
-			tags[k] = *v
+		snap, err := a.snapshotsClient.Get(context.Background(), a.resourceGroupName, snapName)
+		if err != nil {
+			return err
+		}
+		info := &cloudops.SnapshotInfo{
+			CloudResourceInfo: cloudops.CloudResourceInfo{
+				Name: snapName,
+				ID:   snapName,
+			},
+		}
+		if snap.SnapshotProperties != nil {
+			if snap.CreationData != nil {
+				info.VolumeID = strings.ToLower(to.String(snap.CreationData.SourceResourceID))
+			}
+			if snap.TimeCreated != nil {
+				info.CreationTime = snap.TimeCreated.Time
+			}
 		}
+		snaps = append(snaps, info)
 	}
-	return tags, nil
+
+	return cloudops.DeleteSnapshotChain(snaps, func(snapName string) error {
+		return a.SnapshotDelete(snapName, nil)
+	})
 }
 
-func (a *azureOps) getDisks(labels map[string]string) (map[string]*compute.Disk, error) {
-	response := make(map[string]*compute.Disk)
+func (a *azureOps) GetStoragePressure(instanceID string) ([]cloudops.VolumePressureInfo, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "GetStoragePressure",
+	}
+}
 
-	it, err := a.disksClient.ListByResourceGroupComplete(
-		context.Background(),
+// diskSkuPriorities are the decision matrix priorities of the disk SKUs, as in
+// the hand-written azure decision matrix
+var diskSkuPriorities = map[string]int{
+	string(compute.StandardLRS):    0,
+	string(compute.StandardSSDLRS): 1,
+	string(compute.StandardSSDZRS): 1,
+	string(compute.PremiumLRS):     2,
+	string(compute.PremiumZRS):     2,
+	string(compute.PremiumV2LRS):   2,
+	string(compute.UltraSSDLRS):    2,
+}
+
+// ListDiskTypes lists each performance tier of the disk SKUs available in the
+// given location
+func (a *azureOps) ListDiskTypes(region string) ([]cloudops.StorageDecisionMatrixRow, error) {
+	it, err := a.resourceSkusClient.ListComplete(
+		context.Background(),
+		fmt.Sprintf("location eq '%s'", region),
+		"",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []cloudops.StorageDecisionMatrixRow
+	for ; it.NotDone(); err = it.Next() {
+		if err != nil {
+			return nil, err
+		}
+
+		sku := it.Value()
+		if !strings.EqualFold(to.String(sku.ResourceType), "disks") ||
+			sku.Capabilities == nil || skuRestrictedInLocation(sku) {
+			continue
+		}
+
+		capabilities := make(map[string]uint64)
+		for _, c := range *sku.Capabilities {
+			value, err := strconv.ParseUint(to.String(c.Value), 10, 64)
+			if err != nil {
+				// not all disk capabilities are numbers
+				continue
+			}
+			capabilities[to.String(c.Name)] = value
+		}
+		maxSize, ok := capabilities[maxSizeGiBCapability]
+		if !ok {
+			continue
+		}
+		minIOPS, ok := capabilities[minIOPSCapability]
+		if !ok {
+			minIOPS = capabilities[minIOPSReadWriteCapability]
+		}
+		maxIOPS, ok := capabilities[maxIOPSCapability]
+		if !ok {
+			maxIOPS, ok = capabilities[maxIOPSReadWriteCapability]
+		}
+		if !ok {
+			continue
+		}
+
+		name := to.String(sku.Name)
+		rows = append(rows, cloudops.StorageDecisionMatrixRow{
+			MinIOPS:   minIOPS,
+			MaxIOPS:   maxIOPS,
+			MinSize:   capabilities[minSizeGiBCapability],
+			MaxSize:   maxSize,
+			Priority:  diskSkuPriorities[name],
+			DriveType: name,
+		})
+	}
+	return rows, nil
+}
+
+// diskSkuQuotas are the names of the compute usages the disks of each SKU are
+// counted against
+var diskSkuQuotas = map[string]string{
+	string(compute.StandardLRS):    "StandardDiskCount",
+	string(compute.StandardSSDLRS): "StandardSSDDiskCount",
+	string(compute.StandardSSDZRS): "StandardSSDDiskCount",
+	string(compute.PremiumLRS):     "PremiumDiskCount",
+	string(compute.PremiumZRS):     "PremiumDiskCount",
+	string(compute.PremiumV2LRS):   "PremiumV2DiskCount",
+	string(compute.UltraSSDLRS):    "UltraSSDDiskCount",
+}
+
+// CheckVolumeQuota checks the number of disks of the given storage pools
+// against the disk count quotas of the subscription in the location of the
+// instance. SKUs without a known quota are not checked.
+func (a *azureOps) CheckVolumeQuota(required []*cloudops.StoragePoolSpec) error {
+	requiredDisks := make(map[string]uint64)
+	for _, spec := range required {
+		quota, ok := diskSkuQuotas[spec.DriveType]
+		if !ok {
+			a.log("CheckVolumeQuota", "").Debugf("skipping disk SKU %s without a known quota", spec.DriveType)
+			continue
+		}
+		requiredDisks[quota] += spec.TotalDriveCount()
+	}
+	if len(requiredDisks) == 0 {
+		return nil
+	}
+
+	location, _, err := a.instanceLocation()
+	if err != nil {
+		return err
+	}
+	it, err := a.usageClient.ListComplete(context.Background(), to.String(location))
+	if err != nil {
+		return err
+	}
+
+	var breaches []cloudops.QuotaBreach
+	for ; it.NotDone(); err = it.Next() {
+		if err != nil {
+			return err
+		}
+
+		usage := it.Value()
+		if usage.Name == nil {
+			continue
+		}
+		quota := to.String(usage.Name.Value)
+		req, ok := requiredDisks[quota]
+		if !ok {
+			continue
+		}
+		current := uint64(to.Int32(usage.CurrentValue))
+		limit := uint64(to.Int64(usage.Limit))
+		if current+req <= limit {
+			continue
+		}
+		breaches = append(breaches, cloudops.QuotaBreach{
+			Quota:    quota,
+			Limit:    limit,
+			Usage:    current,
+			Required: req,
+		})
+	}
+	if len(breaches) > 0 {
+		return &cloudops.ErrVolumeQuotaExceeded{Quotas: breaches}
+	}
+	return nil
+}
+
+// skuRestrictedInLocation returns true if the SKU can not be used in the
+// location it was listed for
+func skuRestrictedInLocation(sku compute.ResourceSku) bool {
+	if sku.Restrictions == nil {
+		return false
+	}
+	for _, r := range *sku.Restrictions {
+		if r.Type == compute.Location {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *azureOps) ApplyTags(diskName string, labels map[string]string, options map[string]string) error {
+	if err := cloudops.ValidateVolumeID(diskName); err != nil {
+		return err
+	}
+
+	if len(labels) == 0 {
+		return nil
+	}
+
+	disk, resourceGroupName, err := a.getDisk(diskName, options)
+	if err != nil {
+		return err
+	}
+
+	if len(disk.Tags) == 0 {
+		disk.Tags = make(map[string]*string)
+	}
+
+	for k, v := range labels {
+		disk.Tags[k] = to.StringPtr(v)
+	}
+
+	ctx := context.Background()
+	future, err := a.disksClient.Update(
+		ctx,
+		resourceGroupName,
+		diskName,
+		compute.DiskUpdate{
+			Tags: disk.Tags,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	err = future.WaitForCompletionRef(ctx, a.disksClient.Client)
+	if err != nil {
+		return err
+	}
+
+	_, err = future.Result(*a.disksClient)
+	return err
+}
+
+func (a *azureOps) RemoveTags(diskName string, labels map[string]string, options map[string]string) error {
+	if err := cloudops.ValidateVolumeID(diskName); err != nil {
+		return err
+	}
+
+	if len(labels) == 0 {
+		return nil
+	}
+
+	disk, resourceGroupName, err := a.getDisk(diskName, options)
+	if err != nil {
+		return err
+	}
+
+	if len(disk.Tags) == 0 {
+		return nil
+	}
+
+	for k := range labels {
+		delete(disk.Tags, k)
+	}
+
+	ctx := context.Background()
+	future, err := a.disksClient.Update(
+		ctx,
+		resourceGroupName,
+		diskName,
+		compute.DiskUpdate{
+			Tags: disk.Tags,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	err = future.WaitForCompletionRef(ctx, a.disksClient.Client)
+	if err != nil {
+		return err
+	}
+
+	_, err = future.Result(*a.disksClient)
+	return err
+}
+
+func (a *azureOps) Tags(diskName string) (map[string]string, error) {
+	if err := cloudops.ValidateVolumeID(diskName); err != nil {
+		return nil, err
+	}
+
+	disk, _, err := a.getDisk(diskName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make(map[string]string)
+	for k, v := range disk.Tags {
+		if v == nil {
+			tags[k] = ""
+		} else {
+			tags[k] = *v
+		}
+	}
+	return tags, nil
+}
+
+func (a *azureOps) ReconcileDataDisks(instanceID string) ([]string, error) {
+	dataDisks, err := a.vmsClient.getDataDisks(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	newDataDisks := make([]compute.DataDisk, 0)
+	freedLuns := make([]string, 0)
+	for _, d := range dataDisks {
+		if d.Name == nil || d.ManagedDisk == nil || d.ManagedDisk.ID == nil {
+			newDataDisks = append(newDataDisks, d)
+			continue
+		}
+		// The disk is looked up by the ID of the managed disk, as the data
+		// disks of the instance can be in other resource groups and
+		// subscriptions than the client
+		resource, err := azure.ParseResourceID(*d.ManagedDisk.ID)
+		if err != nil {
+			a.log("ReconcileDataDisks", *d.Name).Warnf("Keeping data disk entry with unexpected ID %s: %v",
+				*d.ManagedDisk.ID, err)
+			newDataDisks = append(newDataDisks, d)
+			continue
+		}
+		_, err = a.getManagedDisk(resource)
+		if isNotFoundError(err) {
+			a.log("ReconcileDataDisks", *d.Name).Infof("Removing data disk entry for deleted disk %s from instance %s",
+				*d.ManagedDisk.ID, instanceID)
+			if d.Lun != nil {
+				freedLuns = append(freedLuns, strconv.Itoa(int(*d.Lun)))
+			}
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		newDataDisks = append(newDataDisks, d)
+	}
+
+	if len(newDataDisks) == len(dataDisks) {
+		return freedLuns, nil
+	}
+
+	if err := a.updateDataDisks(instanceID, newDataDisks); err != nil {
+		return nil, err
+	}
+	return freedLuns, nil
+}
+
+// getManagedDisk returns the managed disk with the given resource ID. The
+// data disks of an instance can be in other resource groups and subscriptions
+// than the client, so the disk is looked up where its ID points to.
+func (a *azureOps) getManagedDisk(resource azure.Resource) (compute.Disk, error) {
+	disksClient := *a.disksClient
+	disksClient.SubscriptionID = resource.SubscriptionID
+	return disksClient.Get(context.Background(), resource.ResourceGroup, resource.ResourceName)
+}
+
+// getDataDisk returns the managed disk of the given data disk
+func (a *azureOps) getDataDisk(d compute.DataDisk) (compute.Disk, error) {
+	resource, err := azure.ParseResourceID(*d.ManagedDisk.ID)
+	if err != nil {
+		return compute.Disk{}, err
+	}
+	return a.getManagedDisk(resource)
+}
+
+// GetStorageLayout returns the specs of the managed data disks attached to the
+// given instance, ordered by LUN.
+func (a *azureOps) GetStorageLayout(instanceID string) ([]cloudops.VolumeSpec, error) {
+	dataDisks, err := a.vmsClient.getDataDisks(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	managed := make([]compute.DataDisk, 0, len(dataDisks))
+	for _, d := range dataDisks {
+		if d.ManagedDisk != nil && d.ManagedDisk.ID != nil && d.Name != nil && d.Lun != nil {
+			managed = append(managed, d)
+		}
+	}
+	sort.Slice(managed, func(i, j int) bool {
+		return *managed[i].Lun < *managed[j].Lun
+	})
+
+	specs := make([]cloudops.VolumeSpec, 0, len(managed))
+	for _, d := range managed {
+		disk, err := a.getDataDisk(d)
+		if err != nil {
+			return nil, err
+		}
+		if disk.DiskProperties == nil || disk.DiskProperties.DiskSizeGB == nil {
+			return nil, fmt.Errorf("disk properties of (%v) is nil", *d.Name)
+		}
+
+		spec := cloudops.VolumeSpec{
+			SizeInGiB: uint64(*disk.DiskProperties.DiskSizeGB),
+			Labels:    make(map[string]string, len(disk.Tags)),
+		}
+		if disk.Sku != nil {
+			spec.Type = string(disk.Sku.Name)
+		}
+		if disk.DiskProperties.DiskIOPSReadWrite != nil {
+			spec.IOPS = uint64(*disk.DiskProperties.DiskIOPSReadWrite)
+		}
+		if disk.DiskProperties.DiskMBpsReadWrite != nil {
+			spec.Throughput = uint64(*disk.DiskProperties.DiskMBpsReadWrite)
+		}
+		for k, v := range disk.Tags {
+			if v != nil {
+				spec.Labels[k] = *v
+			}
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// ProvisionStorageLayout creates disks matching the given specs in the location
+// and zones of the instance of the client.
+func (a *azureOps) ProvisionStorageLayout(specs []cloudops.VolumeSpec, labels map[string]string) ([]interface{}, error) {
+	location, zones, err := a.instanceLocation()
+	if err != nil {
+		return nil, err
+	}
+
+	return cloudops.CreateVolumes(a, specs, labels, func(spec cloudops.VolumeSpec) interface{} {
+		disk := &compute.Disk{
+			Name:     to.StringPtr(fmt.Sprintf("%s-%s", layoutDiskPrefix, uuid.New())),
+			Location: location,
+			Zones:    zones,
+			Sku:      &compute.DiskSku{Name: compute.DiskStorageAccountTypes(spec.Type)},
+			DiskProperties: &compute.DiskProperties{
+				DiskSizeGB: to.Int32Ptr(int32(spec.SizeInGiB)),
+			},
+		}
+		if spec.IOPS > 0 {
+			disk.DiskProperties.DiskIOPSReadWrite = to.Int64Ptr(int64(spec.IOPS))
+		}
+		if spec.Throughput > 0 {
+			disk.DiskProperties.DiskMBpsReadWrite = to.Int64Ptr(int64(spec.Throughput))
+		}
+		return disk
+	})
+}
+
+// GetVolumeLineage returns the snapshot, image or disk the given disk was
+// created from, as recorded in the creation data of the disk. Snapshots and
+// disks are identified by name, images by their resource ID.
+func (a *azureOps) GetVolumeLineage(diskName string) (string, string, error) {
+	if err := cloudops.ValidateVolumeID(diskName); err != nil {
+		return "", "", err
+	}
+
+	disks, err := a.Inspect([]*string{&diskName}, nil)
+	if err != nil {
+		return "", "", err
+	}
+	disk := disks[0].(*compute.Disk)
+	if disk.DiskProperties == nil || disk.DiskProperties.CreationData == nil {
+		return "", "", nil
+	}
+
+	creationData := disk.DiskProperties.CreationData
+	switch creationData.CreateOption {
+	case compute.Copy, compute.CopyStart:
+		sourceID := to.String(creationData.SourceResourceID)
+		if strings.Contains(strings.ToLower(sourceID), "/microsoft.compute/snapshots/") {
+			return cloudops.VolumeSourceSnapshot, path.Base(sourceID), nil
+		}
+		return cloudops.VolumeSourceClone, path.Base(sourceID), nil
+	case compute.FromImage:
+		if creationData.GalleryImageReference != nil {
+			return cloudops.VolumeSourceImage, to.String(creationData.GalleryImageReference.ID), nil
+		}
+		if creationData.ImageReference != nil {
+			return cloudops.VolumeSourceImage, to.String(creationData.ImageReference.ID), nil
+		}
+	}
+	return "", "", nil
+}
+
+// BatchInspect returns the given disks keyed by disk name. The disks of the
+// resource group are listed with a single paged list call.
+func (a *azureOps) BatchInspect(diskNames []*string) (map[string]interface{}, error) {
+	if err := cloudops.ValidateVolumeIDs(diskNames); err != nil {
+		return nil, err
+	}
+
+	disks := make(map[string]interface{}, len(diskNames))
+	if len(diskNames) == 0 {
+		return disks, nil
+	}
+
+	wanted := make(map[string]bool, len(diskNames))
+	for _, diskName := range diskNames {
+		wanted[*diskName] = true
+	}
+	if err := a.forEachDisk(nil, func(disk *compute.Disk) error {
+		if disk.Name != nil && wanted[*disk.Name] {
+			disks[*disk.Name] = disk
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	for _, diskName := range diskNames {
+		if _, ok := disks[*diskName]; !ok {
+			return nil, cloudops.NewStorageError(cloudops.ErrVolNotFound,
+				fmt.Sprintf("disk %s not found", *diskName), a.instance)
+		}
+	}
+	return disks, nil
+}
+
+// instanceLocation returns the location and zones of the instance of the client
+func (a *azureOps) instanceLocation() (*string, *[]string, error) {
+	vm, err := a.vmsClient.describe(a.instance)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch vm := vm.(type) {
+	case compute.VirtualMachine:
+		return vm.Location, vm.Zones, nil
+	case compute.VirtualMachineScaleSetVM:
+		return vm.Location, vm.Zones, nil
+	}
+	return nil, nil, fmt.Errorf("invalid type: %T returned for instance %s", vm, a.instance)
+}
+
+// GetVMDiskBudget returns the uncached IOPS and throughput (in MiB/s) limits of
+// the size of the given instance, which the ultra disks attached to the
+// instance draw from, along with the IOPS already provisioned across the ultra
+// disks attached to the instance.
+func (a *azureOps) GetVMDiskBudget(instanceID string) (uint64, uint64, uint64, error) {
+	vm, err := a.vmsClient.describe(instanceID)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	var location, vmSize string
+	switch vm := vm.(type) {
+	case compute.VirtualMachine:
+		location = to.String(vm.Location)
+		if vm.VirtualMachineProperties != nil && vm.HardwareProfile != nil {
+			vmSize = string(vm.HardwareProfile.VMSize)
+		}
+	case compute.VirtualMachineScaleSetVM:
+		location = to.String(vm.Location)
+		if vm.VirtualMachineScaleSetVMProperties != nil && vm.HardwareProfile != nil {
+			vmSize = string(vm.HardwareProfile.VMSize)
+		} else if vm.Sku != nil {
+			vmSize = to.String(vm.Sku.Name)
+		}
+	default:
+		return 0, 0, 0, fmt.Errorf("invalid type: %T returned for instance %s", vm, instanceID)
+	}
+	if len(vmSize) == 0 {
+		return 0, 0, 0, fmt.Errorf("size of instance %s is unknown", instanceID)
+	}
+
+	iopsBudget, throughputBudget, err := a.vmSizeDiskLimits(location, vmSize)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	dataDisks, err := a.vmsClient.getDataDisks(instanceID)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	var used uint64
+	for _, d := range dataDisks {
+		if d.ManagedDisk == nil || d.ManagedDisk.ID == nil {
+			continue
+		}
+		disk, err := a.getDataDisk(d)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		if disk.Sku == nil || disk.Sku.Name != compute.UltraSSDLRS ||
+			disk.DiskProperties == nil || disk.DiskProperties.DiskIOPSReadWrite == nil {
+			continue
+		}
+		used += uint64(*disk.DiskProperties.DiskIOPSReadWrite)
+	}
+	return iopsBudget, throughputBudget, used, nil
+}
+
+// vmSizeDiskLimits returns the uncached IOPS and throughput (in MiB/s) limits
+// of the given VM size in the given location
+func (a *azureOps) vmSizeDiskLimits(location, vmSize string) (uint64, uint64, error) {
+	it, err := a.resourceSkusClient.ListComplete(
+		context.Background(),
+		fmt.Sprintf("location eq '%s'", location),
+		"",
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+	for ; it.NotDone(); err = it.Next() {
+		if err != nil {
+			return 0, 0, err
+		}
+
+		sku := it.Value()
+		if !strings.EqualFold(to.String(sku.ResourceType), "virtualMachines") ||
+			!strings.EqualFold(to.String(sku.Name), vmSize) || sku.Capabilities == nil {
+			continue
+		}
+
+		var iops, bytesPerSecond uint64
+		for _, c := range *sku.Capabilities {
+			var err error
+			switch to.String(c.Name) {
+			case uncachedDiskIOPSCapability:
+				iops, err = strconv.ParseUint(to.String(c.Value), 10, 64)
+			case uncachedDiskBytesPerSecondCapability:
+				bytesPerSecond, err = strconv.ParseUint(to.String(c.Value), 10, 64)
+			}
+			if err != nil {
+				return 0, 0, fmt.Errorf("invalid capability %s of VM size %s: %v",
+					to.String(c.Name), vmSize, err)
+			}
+		}
+		return iops, bytesPerSecond / (1024 * 1024), nil
+	}
+	return 0, 0, fmt.Errorf("VM size %s not found in location %s", vmSize, location)
+}
+
+func (a *azureOps) GetEffectivePerformance(diskName string) (uint64, uint64, error) {
+	if err := cloudops.ValidateVolumeID(diskName); err != nil {
+		return 0, 0, err
+	}
+
+	disk, _, err := a.getDisk(diskName, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if disk.DiskProperties == nil {
+		return 0, 0, fmt.Errorf("disk properties of (%v) is nil", diskName)
+	}
+
+	var iops, throughput uint64
+	if disk.DiskProperties.DiskIOPSReadWrite != nil {
+		iops = uint64(*disk.DiskProperties.DiskIOPSReadWrite)
+	}
+	if disk.DiskProperties.DiskMBpsReadWrite != nil {
+		throughput = uint64(*disk.DiskProperties.DiskMBpsReadWrite)
+	}
+	return iops, throughput, nil
+}
+
+// ConfigureReplication copies an incremental snapshot of the disk to the target
+// region and tags the disk with the target region. Azure does not replicate
+// managed disks across regions, so callers are expected to invoke this
+// periodically until StopReplication is called.
+//
+// The local snapshot a replica is copied from is deleted once the copy has
+// completed, by this or a later call, and only the newest replicas set by the
+// ReplicaRetentionKey option are kept in the target region.
+func (a *azureOps) ConfigureReplication(
+	diskName string,
+	targetRegion string,
+	options map[string]string,
+) error {
+	if err := cloudops.ValidateVolumeID(diskName); err != nil {
+		return err
+	}
+
+	retention := 1
+	if value, ok := options[ReplicaRetentionKey]; ok {
+		var err error
+		if retention, err = strconv.Atoi(value); err != nil || retention < 1 {
+			return fmt.Errorf("invalid %s option %q: must be a positive number", ReplicaRetentionKey, value)
+		}
+	}
+
+	disk, _, err := a.getDisk(diskName, options)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	snapName := fmt.Sprintf("%s-repl-%s", diskName, time.Now().Format(snapNameFormat))
+	future, err := a.snapshotsClient.CreateOrUpdate(
+		ctx,
+		a.resourceGroupName,
+		snapName,
+		compute.Snapshot{
+			Location: disk.Location,
+			Tags:     map[string]*string{replicationSourceTag: to.StringPtr(diskName)},
+			SnapshotProperties: &compute.SnapshotProperties{
+				CreationData: &compute.CreationData{
+					CreateOption:     compute.Copy,
+					SourceResourceID: disk.ID,
+				},
+				Incremental: to.BoolPtr(true),
+			},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if err = future.WaitForCompletionRef(ctx, a.snapshotsClient.Client); err != nil {
+		return err
+	}
+	snap, err := future.Result(*a.snapshotsClient)
+	if err != nil {
+		return err
+	}
+
+	replicaTags := make(map[string]*string, len(disk.Tags)+2)
+	for k, v := range disk.Tags {
+		replicaTags[k] = v
+	}
+	replicaTags[replicationSourceTag] = to.StringPtr(diskName)
+	replicaTags[replicationTargetRegionTag] = to.StringPtr(targetRegion)
+	copyFuture, err := a.snapshotsClient.CreateOrUpdate(
+		ctx,
 		a.resourceGroupName,
+		fmt.Sprintf("%s-%s", snapName, targetRegion),
+		compute.Snapshot{
+			Location: to.StringPtr(targetRegion),
+			Tags:     replicaTags,
+			SnapshotProperties: &compute.SnapshotProperties{
+				CreationData: &compute.CreationData{
+					CreateOption:     compute.CopyStart,
+					SourceResourceID: snap.ID,
+				},
+				Incremental: to.BoolPtr(true),
+			},
+		},
 	)
+	if err != nil {
+		return err
+	}
+	if err = copyFuture.WaitForCompletionRef(ctx, a.snapshotsClient.Client); err != nil {
+		return err
+	}
+
+	if err := a.pruneReplicationSnapshots(diskName, targetRegion, retention); err != nil {
+		return err
+	}
+
+	return a.ApplyTags(diskName, map[string]string{replicationTargetRegionTag: targetRegion}, options)
+}
+
+// StopReplication removes the replication tag of the disk and deletes the
+// snapshots ConfigureReplication took of it, including the replicas in the
+// target region.
+func (a *azureOps) StopReplication(diskName string) error {
+	if err := cloudops.ValidateVolumeID(diskName); err != nil {
+		return err
+	}
+
+	snaps, err := a.replicationSnapshots(diskName)
+	if err != nil {
+		return err
+	}
+	// replicas are deleted before the local snapshots they are copied from
+	sort.SliceStable(snaps, func(i, j int) bool {
+		return isReplica(&snaps[i]) && !isReplica(&snaps[j])
+	})
+	for _, snap := range snaps {
+		if err := a.SnapshotDelete(to.String(snap.Name), nil); err != nil && !isNotFoundError(err) {
+			return err
+		}
+	}
+
+	return a.RemoveTags(diskName, map[string]string{replicationTargetRegionTag: ""}, nil)
+}
+
+// pruneReplicationSnapshots deletes the local snapshots of the disk whose copy
+// to the target region has completed or failed, the failed replicas, and the
+// completed replicas beyond the given number of newest ones. Replicas that are
+// still being copied are left alone.
+func (a *azureOps) pruneReplicationSnapshots(diskName, targetRegion string, retention int) error {
+	snaps, err := a.replicationSnapshots(diskName)
+	if err != nil {
+		return err
+	}
+
+	var replicas []compute.Snapshot
+	copying := make(map[string]bool)
+	for _, snap := range snaps {
+		if !isReplica(&snap) {
+			continue
+		}
+		if snap.SnapshotProperties == nil || snap.CreationData == nil {
+			continue
+		}
+		source := strings.ToLower(to.String(snap.CreationData.SourceResourceID))
+		switch {
+		case snap.CopyCompletionError != nil:
+			a.log("ConfigureReplication", diskName).Warnf("deleting replica %s which failed to copy: %s",
+				to.String(snap.Name), to.String(snap.CopyCompletionError.ErrorMessage))
+			if err := a.SnapshotDelete(to.String(snap.Name), nil); err != nil && !isNotFoundError(err) {
+				return err
+			}
+		case snap.CompletionPercent != nil && *snap.CompletionPercent < 100:
+			copying[source] = true
+		default:
+			if strings.EqualFold(to.String(snap.Tags[replicationTargetRegionTag]), targetRegion) {
+				replicas = append(replicas, snap)
+			}
+		}
+	}
+
+	for _, snap := range snaps {
+		if isReplica(&snap) || copying[strings.ToLower(to.String(snap.ID))] {
+			continue
+		}
+		if err := a.SnapshotDelete(to.String(snap.Name), nil); err != nil && !isNotFoundError(err) {
+			return err
+		}
+	}
+
+	sort.Slice(replicas, func(i, j int) bool {
+		return snapshotCreated(&replicas[i]).After(snapshotCreated(&replicas[j]))
+	})
+	for i := retention; i < len(replicas); i++ {
+		if err := a.SnapshotDelete(to.String(replicas[i].Name), nil); err != nil && !isNotFoundError(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// replicationSnapshots returns the local snapshots and replicas
+// ConfigureReplication took of the disk
+func (a *azureOps) replicationSnapshots(diskName string) ([]compute.Snapshot, error) {
+	ctx := context.Background()
+	it, err := a.snapshotsClient.ListByResourceGroupComplete(ctx, a.resourceGroupName)
 	if err != nil {
 		return nil, err
 	}
-	for ; it.NotDone(); err = it.Next() {
+
+	var snaps []compute.Snapshot
+	for ; it.NotDone(); err = it.NextWithContext(ctx) {
+		if err != nil {
+			return nil, err
+		}
+		snap := it.Value()
+		if tagsMatch(snap.Tags, map[string]string{replicationSourceTag: diskName}) {
+			snaps = append(snaps, snap)
+		}
+	}
+	return snaps, nil
+}
+
+// isReplica returns true if the replication snapshot is the copy in the
+// target region rather than the local snapshot it is copied from
+func isReplica(snap *compute.Snapshot) bool {
+	_, ok := snap.Tags[replicationTargetRegionTag]
+	return ok
+}
+
+func snapshotCreated(snap *compute.Snapshot) time.Time {
+	if snap.SnapshotProperties == nil || snap.TimeCreated == nil {
+		return time.Time{}
+	}
+	return snap.TimeCreated.Time
+}
+
+// EnumerateSnapshots returns the snapshots of the resource group of the client
+// which have the given labels as tags and whose source disk is one of the
+// given disks
+func (a *azureOps) EnumerateSnapshots(volumeIDs []*string, labels map[string]string) ([]*cloudops.SnapshotInfo, error) {
+	if err := cloudops.ValidateVolumeIDs(volumeIDs); err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	it, err := a.snapshotsClient.ListByResourceGroupComplete(ctx, a.resourceGroupName)
+	if err != nil {
+		return nil, err
+	}
+
+	volumes := make(map[string]bool, len(volumeIDs))
+	for _, volumeID := range volumeIDs {
+		volumes[*volumeID] = true
+	}
+	snapshots := make([]*cloudops.SnapshotInfo, 0)
+	for ; it.NotDone(); err = it.NextWithContext(ctx) {
 		if err != nil {
 			return nil, err
 		}
 
-		disk := it.Value()
-		if labelsMatch(&disk, labels) {
-			response[*it.Value().Name] = &disk
+		snap := it.Value()
+		if !tagsMatch(snap.Tags, labels) {
+			continue
+		}
+		info := snapshotInfoFromSnapshot(&snap)
+		if len(volumes) > 0 && !volumes[info.VolumeID] {
+			continue
+		}
+		snapshots = append(snapshots, info)
+	}
+	return snapshots, nil
+}
+
+func (a *azureOps) ListSnapshots(labels map[string]string) ([]*cloudops.SnapshotInfo, error) {
+	return a.EnumerateSnapshots(nil, labels)
+}
+
+func (a *azureOps) InspectSnapshot(snapName string) (*cloudops.SnapshotInfo, error) {
+	if err := cloudops.ValidateVolumeID(snapName); err != nil {
+		return nil, err
+	}
+
+	snap, err := a.snapshotsClient.Get(context.Background(), a.resourceGroupName, snapName)
+	if isNotFoundError(err) {
+		return nil, cloudops.NewStorageError(cloudops.ErrVolNotFound,
+			fmt.Sprintf("snapshot %s not found", snapName), a.instance)
+	} else if err != nil {
+		return nil, err
+	}
+	return snapshotInfoFromSnapshot(&snap), nil
+}
+
+func (a *azureOps) ReportCapacityByLabel(labelKey string) (map[string]uint64, error) {
+	capacities := make(map[string]uint64)
+	if err := a.forEachDisk(nil, func(disk *compute.Disk) error {
+		value, ok := disk.Tags[labelKey]
+		if !ok || value == nil {
+			return nil
+		}
+		if disk.DiskProperties == nil || disk.DiskProperties.DiskSizeGB == nil {
+			return nil
+		}
+		capacities[*value] += uint64(*disk.DiskProperties.DiskSizeGB)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return capacities, nil
+}
+
+// UpdateAttachmentCaching changes the caching of an attached disk. Azure does not
+// allow changing the caching of an attached disk in place, so the disk is detached
+// and re-attached on its original LUN to preserve the device path.
+func (a *azureOps) UpdateAttachmentCaching(instanceID, diskName, caching string) error {
+	if err := cloudops.ValidateVolumeID(diskName); err != nil {
+		return err
+	}
+
+	cachingType, err := parseCachingType(caching)
+	if err != nil {
+		return err
+	}
+
+	dataDisks, err := a.vmsClient.getDataDisks(instanceID)
+	if err != nil {
+		return err
+	}
+
+	var attached *compute.DataDisk
+	for i := range dataDisks {
+		if dataDisks[i].Name != nil && strings.EqualFold(*dataDisks[i].Name, diskName) {
+			attached = &dataDisks[i]
+			break
+		}
+	}
+	if attached == nil {
+		return cloudops.NewStorageError(cloudops.ErrVolDetached,
+			fmt.Sprintf("disk %s is not attached to instance %s", diskName, instanceID),
+			instanceID)
+	}
+	if attached.Caching == cachingType {
+		return nil
+	}
+	origCaching := attached.Caching
+	disk := *attached
+
+	if err := a.detachInternal(diskName, instanceID, nil); err != nil {
+		return err
+	}
+
+	if err := a.reattachDataDisk(instanceID, disk, cachingType); err != nil {
+		a.log("UpdateAttachmentCaching", diskName).Errorf("Failed to re-attach disk %s to instance %s with caching %s: %v",
+			diskName, instanceID, cachingType, err)
+		if rollbackErr := a.reattachDataDisk(instanceID, disk, origCaching); rollbackErr != nil {
+			a.log("UpdateAttachmentCaching", diskName).Errorf("Failed to re-attach disk %s to instance %s with its original caching %s: %v",
+				diskName, instanceID, origCaching, rollbackErr)
+		}
+		return err
+	}
+
+	if instanceID == a.instance {
+		_, err = a.waitForAttach(diskName, dataDiskResourceGroup(disk, a.resourceGroupName))
+	}
+	return err
+}
+
+// reattachDataDisk attaches the given previously attached data disk to the
+// instance with the given caching, on the same LUN if it is still available
+func (a *azureOps) reattachDataDisk(
+	instanceID string,
+	disk compute.DataDisk,
+	caching compute.CachingTypes,
+) error {
+	dataDisks, err := a.vmsClient.getDataDisks(instanceID)
+	if err != nil {
+		return err
+	}
+
+	lun := disk.Lun
+	for _, d := range dataDisks {
+		if d.Lun != nil && lun != nil && *d.Lun == *lun {
+			a.log("UpdateAttachmentCaching", *disk.Name).Warnf("LUN %d of disk %s was taken while it was detached, "+
+				"the device path of the disk will change", *lun, *disk.Name)
+			nextLun := nextAvailableLun(dataDisks)
+			if nextLun < 0 {
+				return fmt.Errorf("No LUN available to attach the disk. "+
+					"%v disks attached to the VM instance", len(dataDisks))
+			}
+			lun = &nextLun
+			break
+		}
+	}
+
+	newDataDisks := append(
+		dataDisks,
+		compute.DataDisk{
+			Lun:          lun,
+			Name:         disk.Name,
+			DiskSizeGB:   disk.DiskSizeGB,
+			Caching:      caching,
+			CreateOption: compute.DiskCreateOptionTypesAttach,
+			ManagedDisk: &compute.ManagedDiskParameters{
+				ID: disk.ManagedDisk.ID,
+			},
+		},
+	)
+	return a.updateDataDisks(instanceID, newDataDisks)
+}
+
+// parseCachingType returns the Azure caching type matching the given caching
+// value, ignoring case
+func parseCachingType(caching string) (compute.CachingTypes, error) {
+	for _, cachingType := range compute.PossibleCachingTypesValues() {
+		if strings.EqualFold(string(cachingType), caching) {
+			return cachingType, nil
 		}
 	}
+	return "", cloudops.NewStorageError(cloudops.ErrVolInval,
+		fmt.Sprintf("invalid caching %s, supported values are %v",
+			caching, compute.PossibleCachingTypesValues()), "")
+}
+
+func (a *azureOps) ModifyVolumeType(volumeID, newType string, iops, throughput *int64) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "ModifyVolumeType",
+		Reason:    "the SKU of a managed disk can only be changed while it is detached",
+	}
+}
+
+func (a *azureOps) ExpandMany(volumeIDs []string, newSizeInGiB uint64, options map[string]string) (map[string]uint64, error) {
+	return cloudops.ExpandVolumes(a.Expand, volumeIDs, newSizeInGiB, options)
+}
+
+func (a *azureOps) DeleteVolumes(volumeIDs []string) (map[string]error, error) {
+	return cloudops.DeleteVolumes(a.Delete, isFatalError, volumeIDs)
+}
+
+// LockVolume is not supported as the disks API does not expose an ETag to
+// update the disk tags conditionally on.
+func (a *azureOps) LockVolume(diskName, owner string) (bool, error) {
+	return false, &cloudops.ErrNotSupported{
+		Operation: "LockVolume",
+		Reason:    "disk tags cannot be updated conditionally",
+	}
+}
+
+// UnlockVolume is not supported as LockVolume is not supported.
+func (a *azureOps) UnlockVolume(diskName, owner string) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "UnlockVolume",
+		Reason:    "disk tags cannot be updated conditionally",
+	}
+}
+
+// AdoptVolume tags the given disk as managed by cloudops. The disk must be
+// unattached or attached to the local instance.
+func (a *azureOps) AdoptVolume(diskName string, labels map[string]string) error {
+	if err := cloudops.ValidateVolumeID(diskName); err != nil {
+		return err
+	}
+
+	disk, resourceGroupName, err := a.getDisk(diskName, nil)
+	if isNotFoundError(err) {
+		return diskNotFoundError(diskName, a.instance)
+	} else if err != nil {
+		return err
+	}
+	err = a.diskAttachmentStatus(&disk, diskName, a.instance)
+	if se, ok := err.(*cloudops.StorageError); ok && se.Code == cloudops.ErrVolDetached {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Reserved disks are attached to a deallocated VM
+	if disk.DiskProperties != nil &&
+		disk.DiskState != compute.Unattached &&
+		disk.DiskState != compute.Attached &&
+		disk.DiskState != compute.Reserved {
+		return cloudops.NewStorageError(cloudops.ErrVolInval,
+			fmt.Sprintf("disk %s cannot be adopted in state %s", diskName, disk.DiskState), a.instance)
+	}
+
+	tags := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		tags[k] = v
+	}
+	tags[cloudops.VolumeManagedTagKey] = "true"
+	return a.ApplyTags(diskName, tags, map[string]string{ResourceGroupKey: resourceGroupName})
+}
+
+// GetVolumeQoS returns the IOPS and throughput limits of the disk. Azure reports
+// the limits it throttles the disk at in the same properties used to provision
+// them, so these match the effective performance of the disk.
+func (a *azureOps) GetVolumeQoS(diskName string) (uint64, uint64, error) {
+	if err := cloudops.ValidateVolumeID(diskName); err != nil {
+		return 0, 0, err
+	}
+
+	disk, _, err := a.getDisk(diskName, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if disk.DiskProperties == nil ||
+		disk.DiskProperties.DiskIOPSReadWrite == nil ||
+		disk.DiskProperties.DiskMBpsReadWrite == nil {
+		return 0, 0, fmt.Errorf("QoS limits of disk (%v) are not available", diskName)
+	}
+	return uint64(*disk.DiskProperties.DiskIOPSReadWrite),
+		uint64(*disk.DiskProperties.DiskMBpsReadWrite), nil
+}
+
+// waitForRemoteDetach waits for the disk to be detached from the remote
+// instance it is attached to and returns the detached disk
+func (a *azureOps) waitForRemoteDetach(diskName, resourceGroupName string) (*compute.Disk, error) {
+	disk, err := task.DoRetryWithTimeout(
+		func() (interface{}, bool, error) {
+			disk, err := a.checkDiskAttachmentStatus(diskName, resourceGroupName)
+			if err == nil {
+				return nil, false, fmt.Errorf("disk %s got attached on the local instance while waiting for it to be detached", diskName)
+			} else if se, ok := err.(*cloudops.StorageError); ok &&
+				se.Code == cloudops.ErrVolDetached {
+				return disk, false, nil
+			}
+			return nil, true, err
+		},
+		a.opsTimeout.OpsTimeout(),
+		a.opsTimeout.OpsRetryInterval(),
+	)
+	if err != nil {
+		return nil, err
+	}
 
-	return response, nil
+	return disk.(*compute.Disk), nil
 }
 
-func (a *azureOps) waitForAttach(diskName string) (string, error) {
+func (a *azureOps) waitForAttach(diskName, resourceGroupName string) (string, error) {
 	devicePath, err := task.DoRetryWithTimeout(
 		func() (interface{}, bool, error) {
-			devicePath, err := a.DevicePath(diskName)
+			disk, err := a.checkDiskAttachmentStatus(diskName, resourceGroupName)
 			if se, ok := err.(*cloudops.StorageError); ok &&
 				se.Code == cloudops.ErrVolAttachedOnRemoteNode {
 				return "", false, err
@@ -1092,10 +3130,25 @@ func (a *azureOps) waitForAttach(diskName string) (string, error) {
 				return "", true, err
 			}
 
+			// The disk is managed by the instance before its state is
+			// attached; follow-on operations fail until then.
+			if state := diskState(disk); state == compute.Unattached || state == compute.Reserved {
+				return "", true, cloudops.NewStorageError(
+					cloudops.ErrOperationInProgress,
+					fmt.Sprintf("disk %s is still in state %s", diskName, state),
+					a.instance,
+				)
+			}
+
+			devicePath, err := a.devicePath(diskName)
+			if err != nil {
+				return "", true, err
+			}
+
 			return devicePath, false, nil
 		},
-		cloudops.ProviderOpsTimeout,
-		cloudops.ProviderOpsRetryInterval,
+		a.opsTimeout.OpsTimeout(),
+		a.opsTimeout.OpsRetryInterval(),
 	)
 	if err != nil {
 		return "", err
@@ -1104,7 +3157,22 @@ func (a *azureOps) waitForAttach(diskName string) (string, error) {
 	return devicePath.(string), nil
 }
 
-func (a *azureOps) waitForDetach(diskName, instance string) error {
+// diskState returns the state of the disk, or an empty state if it is not
+// reported
+func diskState(disk *compute.Disk) compute.DiskState {
+	if disk.DiskProperties == nil {
+		return ""
+	}
+	return disk.DiskProperties.DiskState
+}
+
+// waitForDetach waits for the managed disk with the given lower cased ID to
+// leave the data disks of the instance
+func (a *azureOps) waitForDetach(diskID, instance string) error {
+	if len(diskID) == 0 {
+		return nil
+	}
+
 	_, err := task.DoRetryWithTimeout(
 		func() (interface{}, bool, error) {
 			dataDisks, err := a.vmsClient.getDataDisks(instance)
@@ -1113,25 +3181,31 @@ func (a *azureOps) waitForDetach(diskName, instance string) error {
 			}
 
 			for _, d := range dataDisks {
-				if *d.Name == diskName {
+				if d.ManagedDisk != nil && d.ManagedDisk.ID != nil &&
+					strings.ToLower(*d.ManagedDisk.ID) == diskID {
 					return nil, true,
 						fmt.Errorf("disk %s is still attached to instance %s",
-							diskName, instance)
+							diskID, instance)
 				}
 			}
 
 			return nil, false, nil
 		},
-		cloudops.ProviderOpsTimeout,
-		cloudops.ProviderOpsRetryInterval,
+		a.opsTimeout.OpsTimeout(),
+		a.opsTimeout.OpsRetryInterval(),
 	)
 
 	return err
 }
 
 func labelsMatch(disk *compute.Disk, labels map[string]string) bool {
+	return tagsMatch(disk.Tags, labels)
+}
+
+// tagsMatch returns true if the given resource tags have all the given labels
+func tagsMatch(tags map[string]*string, labels map[string]string) bool {
 	for key, expected := range labels {
-		if actual, exists := disk.Tags[key]; exists {
+		if actual, exists := tags[key]; exists {
 			// Nil values are not allowed in tags, just safety check
 			if actual == nil && expected != "" {
 				return false
@@ -1145,6 +3219,35 @@ func labelsMatch(disk *compute.Disk, labels map[string]string) bool {
 	return true
 }
 
+// snapshotInfoFromSnapshot returns the provider neutral info of the given
+// snapshot. Snapshots are identified by their name and their source disk by
+// the name of the disk.
+func snapshotInfoFromSnapshot(snap *compute.Snapshot) *cloudops.SnapshotInfo {
+	info := &cloudops.SnapshotInfo{
+		CloudResourceInfo: cloudops.CloudResourceInfo{
+			Name:   to.String(snap.Name),
+			ID:     to.String(snap.Name),
+			Labels: make(map[string]string, len(snap.Tags)),
+		},
+	}
+	for key, value := range snap.Tags {
+		info.Labels[key] = to.String(value)
+	}
+	if props := snap.SnapshotProperties; props != nil {
+		if props.CreationData != nil && props.CreationData.SourceResourceID != nil {
+			info.VolumeID = path.Base(*props.CreationData.SourceResourceID)
+		}
+		if props.DiskSizeGB != nil {
+			info.SizeInGiB = uint64(*props.DiskSizeGB)
+		}
+		info.State = to.String(props.ProvisioningState)
+		if props.TimeCreated != nil {
+			info.CreationTime = props.TimeCreated.Time
+		}
+	}
+	return info
+}
+
 func formatTags(labels map[string]string) map[string]*string {
 	tags := make(map[string]*string)
 	for k, v := range labels {
@@ -1171,7 +3274,16 @@ func nextAvailableLun(dataDisks []compute.DataDisk) int32 {
 	return nextAvailableLun
 }
 
-func lunToBlockDevPathWithRetry(lun int32) (string, error) {
+// lunPath returns the path of the symlink of the data disk at the given LUN
+func (a *azureOps) lunPath(lun int32) string {
+	prefix := a.diskPrefix
+	if len(prefix) == 0 {
+		prefix = azureDiskPrefix
+	}
+	return prefix + strconv.Itoa(int(lun))
+}
+
+func (a *azureOps) lunToBlockDevPathWithRetry(lun int32) (string, error) {
 	var (
 		retryCount int
 		path       string
@@ -1179,10 +3291,10 @@ func lunToBlockDevPathWithRetry(lun int32) (string, error) {
 	)
 
 	for {
-		if path, err = lunToBlockDevPath(lun); err == nil {
+		if path, err = a.lunToBlockDevPath(lun); err == nil {
 			return path, nil
 		}
-		logrus.Warnf(err.Error())
+		a.log("DevicePath", "").Warnf(err.Error())
 		retryCount++
 		if retryCount >= devicePathMaxRetryCount {
 			break
@@ -1192,8 +3304,8 @@ func lunToBlockDevPathWithRetry(lun int32) (string, error) {
 	return "", err
 }
 
-func lunToBlockDevPath(lun int32) (string, error) {
-	devPath := azureDiskPrefix + strconv.Itoa(int(lun))
+func (a *azureOps) lunToBlockDevPath(lun int32) (string, error) {
+	devPath := a.lunPath(lun)
 	// check if path is a sym link. If yes, return pointee
 	fi, err := os.Lstat(devPath)
 	if err != nil {
@@ -1215,6 +3327,29 @@ func lunToBlockDevPath(lun int32) (string, error) {
 	return devPath, nil
 }
 
+// isNotFoundError returns true if the error is an Azure API not found error
+// encryptionKeyError returns an ErrInvalidEncryptionKey error if err reports
+// that the disk encryption set of encryption cannot be used, else err as is
+func encryptionKeyError(err error, encryption *compute.Encryption) error {
+	if encryption == nil || encryption.DiskEncryptionSetID == nil {
+		return err
+	}
+	if !strings.Contains(strings.ToLower(err.Error()), "diskencryptionset") {
+		return err
+	}
+	return cloudops.NewStorageError(cloudops.ErrInvalidEncryptionKey,
+		fmt.Sprintf("invalid disk encryption set %s: %v", *encryption.DiskEncryptionSetID, err), "")
+}
+
+func isNotFoundError(err error) bool {
+	derr, ok := err.(autorest.DetailedError)
+	if !ok {
+		return false
+	}
+	code, ok := derr.StatusCode.(int)
+	return ok && code == http.StatusNotFound
+}
+
 func isExponentialError(err error) bool {
 	// Got the list of error codes from here
 	// https://docs.microsoft.com/en-us/rest/api/storageservices/common-rest-api-error-codes
@@ -1243,6 +3378,18 @@ func isExponentialError(err error) bool {
 	return false
 }
 
+// isFatalError returns true if the request was rejected for its credentials or
+// could not reach the resource manager endpoint
+func isFatalError(err error) bool {
+	if derr, ok := err.(autorest.DetailedError); ok {
+		if code, ok := derr.StatusCode.(int); ok &&
+			(code == http.StatusUnauthorized || code == http.StatusForbidden) {
+			return true
+		}
+	}
+	return cloudops.IsConnectionError(err)
+}
+
 func azureBaseURI(cloudEnvName string) (string, error) {
 	if value, ok := os.LookupEnv(auth.EnvironmentName); ok {
 		cloudEnvName = value
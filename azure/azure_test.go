@@ -1,15 +1,29 @@
 package azure
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2022-08-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2022-07-01/containerservice"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/libopenstorage/cloudops"
+	"github.com/libopenstorage/cloudops/backoff"
 	"github.com/libopenstorage/cloudops/test"
 	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/wait"
 )
 
 const (
@@ -163,6 +177,80 @@ func TestUpdatePremiumv2IopsThroughput(t *testing.T) {
 		}
 	}
 }
+func TestScaleIopsThroughput(t *testing.T) {
+	cases := []struct {
+		name               string
+		sku                compute.DiskStorageAccountTypes
+		oldSize, newSize   int32
+		iops, throughput   int64
+		expectedIops       int64
+		expectedThroughput int64
+	}{
+		{"ultra doubles", compute.UltraSSDLRS, 100, 200, 10000, 100, 20000, 200},
+		{"ultra clamped to iops per GiB", compute.UltraSSDLRS, 100, 200, 40000, 100, 60000, 200},
+		{"premium v2 doubles", compute.PremiumV2LRS, 100, 200, 5000, 200, 10000, 400},
+		{"premium v2 clamped to iops per GiB", compute.PremiumV2LRS, 10, 20, 6000, 200, 10000, 400},
+		{"premium v2 throughput clamped", compute.PremiumV2LRS, 100, 1000, 20000, 1000, maxIopsV2, maxThroughputV2},
+		{"standard unchanged", compute.StandardSSDLRS, 100, 200, 500, 60, 500, 60},
+	}
+	for _, c := range cases {
+		iops, throughput := c.iops, c.throughput
+		scaleIopsThroughput(c.sku, c.oldSize, c.newSize, &iops, &throughput)
+		require.Equal(t, c.expectedIops, iops, c.name)
+		require.Equal(t, c.expectedThroughput, throughput, c.name)
+	}
+}
+
+func TestExpandUpdatesIopsThroughput(t *testing.T) {
+	cases := []struct {
+		sku                compute.DiskStorageAccountTypes
+		iops, throughput   int64
+		expectedIops       int64
+		expectedThroughput int64
+	}{
+		// the IOPS of an ultra disk can't be below 1 IOPS per GiB
+		{compute.UltraSSDLRS, 100, 1, 500, 2},
+		{compute.UltraSSDLRS, 1000, 10, 1000, 10},
+		{compute.PremiumV2LRS, 1000, 100, minIopsV2, minThroughputV2},
+		{compute.PremiumV2LRS, 5000, 200, 5000, 200},
+	}
+	for _, c := range cases {
+		var updated compute.Disk
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if r.Method == http.MethodPut {
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&updated))
+				w.WriteHeader(http.StatusOK)
+				require.NoError(t, json.NewEncoder(w).Encode(&updated))
+				return
+			}
+			if updated.DiskProperties != nil {
+				require.NoError(t, json.NewEncoder(w).Encode(&updated))
+				return
+			}
+			fmt.Fprintf(w, `{"name": "disk1", "sku": {"name": %q},
+				"properties": {"creationData": {"createOption": "Empty"}, "diskSizeGB": 100,
+				"diskIOPSReadWrite": %d, "diskMBpsReadWrite": %d}}`,
+				c.sku, c.iops, c.throughput)
+		}))
+
+		disksClient := compute.NewDisksClientWithBaseURI(server.URL, "subscription")
+		disksClient.PollingDelay = 0
+		ops := &azureOps{
+			instance:          "instance",
+			resourceGroupName: "group",
+			disksClient:       &disksClient,
+		}
+
+		size, err := ops.Expand("disk1", 500, nil)
+		server.Close()
+		require.NoError(t, err, c.sku)
+		require.Equal(t, uint64(500), size, c.sku)
+		require.Equal(t, c.expectedIops, *updated.DiskIOPSReadWrite, c.sku)
+		require.Equal(t, c.expectedThroughput, *updated.DiskMBpsReadWrite, c.sku)
+	}
+}
+
 func TestCalculateMinThroughput(t *testing.T) {
 	testCases := []struct {
 		iops     int64
@@ -189,3 +277,1927 @@ func TestCalculateMinThroughput(t *testing.T) {
 		}
 	}
 }
+
+// conflictVMsClient is a vmsClient whose attach requests always fail with
+// AttachDiskWhileBeingDetached while detach requests succeed.
+type conflictVMsClient struct {
+	attachCalls int
+}
+
+func (c *conflictVMsClient) name(instanceID string) string {
+	return instanceID
+}
+
+func (c *conflictVMsClient) describe(instanceID string) (interface{}, error) {
+	return nil, nil
+}
+
+func (c *conflictVMsClient) getDataDisks(instanceID string) ([]compute.DataDisk, error) {
+	return []compute.DataDisk{}, nil
+}
+
+func (c *conflictVMsClient) updateDataDisks(instanceID string, dataDisks []compute.DataDisk) error {
+	if len(dataDisks) == 0 {
+		// detach
+		return nil
+	}
+	c.attachCalls++
+	return autorest.DetailedError{
+		Original: azure.RequestError{
+			ServiceError: &azure.ServiceError{
+				Code:    errCodeAttachDiskWhileBeingDetached,
+				Message: fmt.Sprintf("Cannot attach data disk '%s' to VM '%s'", *dataDisks[0].Name, instanceID),
+			},
+		},
+	}
+}
+
+func TestAttachConflictRetriesAreBounded(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"name": "disk1", "id": "/disks/disk1", "properties": {"diskSizeGB": 10}}`)
+	}))
+	defer server.Close()
+
+	disksClient := compute.NewDisksClientWithBaseURI(server.URL, "subscription")
+	vms := &conflictVMsClient{}
+	ops := backoff.NewExponentialBackoffOps(
+		&azureOps{
+			instance:          "instance",
+			resourceGroupName: "group",
+			disksClient:       &disksClient,
+			vmsClient:         vms,
+		},
+		isExponentialError,
+		wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 10},
+	)
+
+	_, err := ops.Attach("disk1", nil)
+	require.Error(t, err)
+	se, ok := err.(*cloudops.StorageError)
+	require.True(t, ok, "expected a storage error, got: %v", err)
+	require.Equal(t, cloudops.ErrOperationInProgress, se.Code)
+	require.Equal(t, maxAttachConflictRetries+1, vms.attachCalls)
+
+	// the retries are counted per call
+	vms.attachCalls = 0
+	_, err = ops.Attach("disk1", nil)
+	require.Error(t, err)
+	require.Equal(t, maxAttachConflictRetries+1, vms.attachCalls)
+}
+
+func TestBackoffConfig(t *testing.T) {
+	throttled := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if throttled > 0 {
+			throttled--
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"error": {"code": "TooManyRequests", "message": "throttled"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"name": "disk1", "tags": {"app": "db"}}`)
+	}))
+	defer server.Close()
+
+	disksClient := compute.NewDisksClientWithBaseURI(server.URL, "subscription")
+	// the client sends a request only once per retry attempt, so keep a single
+	// attempt without a delay and leave the retries to the backoff wrapper
+	disksClient.RetryAttempts = 1
+	disksClient.RetryDuration = 0
+	newOps := func(config *backoff.Config) (cloudops.Ops, error) {
+		return withBackoff(&azureOps{
+			instance:          "instance",
+			resourceGroupName: "group",
+			disksClient:       &disksClient,
+		}, Config{Backoff: config})
+	}
+
+	_, err := newOps(&backoff.Config{InitialInterval: time.Millisecond})
+	require.Error(t, err, "a config without a max elapsed time should be rejected")
+
+	ops, err := newOps(&backoff.Config{
+		InitialInterval: time.Millisecond,
+		Multiplier:      1,
+		MaxElapsedTime:  time.Second,
+	})
+	require.NoError(t, err)
+
+	// throttled requests are retried at the configured interval
+	throttled = 4
+	start := time.Now()
+	tags, err := ops.Tags("disk1")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"app": "db"}, tags)
+	require.Zero(t, throttled)
+	require.Less(t, int64(time.Since(start)), int64(time.Second))
+
+	// and given up on once the max elapsed time has passed
+	ops, err = newOps(&backoff.Config{
+		InitialInterval: time.Millisecond,
+		Multiplier:      1,
+		MaxElapsedTime:  20 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	throttled = math.MaxInt32
+	_, err = ops.Tags("disk1")
+	require.Error(t, err)
+	se, ok := err.(*cloudops.StorageError)
+	require.True(t, ok, "expected a storage error, got: %v", err)
+	require.Equal(t, cloudops.ErrExponentialTimeout, se.Code)
+}
+
+// fakeVMsClient is a vmsClient that serves a fixed set of data disks and
+// records the data disks the VM was last updated with.
+type fakeVMsClient struct {
+	dataDisks []compute.DataDisk
+	updates   [][]compute.DataDisk
+}
+
+func (f *fakeVMsClient) name(instanceID string) string {
+	return instanceID
+}
+
+func (f *fakeVMsClient) describe(instanceID string) (interface{}, error) {
+	return nil, nil
+}
+
+func (f *fakeVMsClient) getDataDisks(instanceID string) ([]compute.DataDisk, error) {
+	return f.dataDisks, nil
+}
+
+func (f *fakeVMsClient) updateDataDisks(instanceID string, dataDisks []compute.DataDisk) error {
+	f.updates = append(f.updates, dataDisks)
+	f.dataDisks = dataDisks
+	return nil
+}
+
+func TestReconcileDataDisks(t *testing.T) {
+	const (
+		groupPath = "/subscriptions/subscription/resourceGroups/group/providers/Microsoft.Compute/disks/"
+		otherPath = "/subscriptions/other-subscription/resourceGroups/other-group/providers/Microsoft.Compute/disks/"
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case groupPath + "live", otherPath + "other":
+			fmt.Fprintf(w, `{"name": "%s", "id": "%s", "properties": {"diskSizeGB": 10}}`,
+				path.Base(r.URL.Path), r.URL.Path)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": {"code": "ResourceNotFound", "message": "disk not found"}}`)
+		}
+	}))
+	defer server.Close()
+
+	disksClient := compute.NewDisksClientWithBaseURI(server.URL, "subscription")
+	vms := &fakeVMsClient{
+		dataDisks: []compute.DataDisk{
+			{
+				Name:        to.StringPtr("live"),
+				Lun:         to.Int32Ptr(0),
+				ManagedDisk: &compute.ManagedDiskParameters{ID: to.StringPtr(groupPath + "live")},
+			},
+			{
+				Name:        to.StringPtr("dead"),
+				Lun:         to.Int32Ptr(1),
+				ManagedDisk: &compute.ManagedDiskParameters{ID: to.StringPtr(groupPath + "dead")},
+			},
+			{
+				// only exists in the resource group and subscription of its ID
+				Name:        to.StringPtr("other"),
+				Lun:         to.Int32Ptr(2),
+				ManagedDisk: &compute.ManagedDiskParameters{ID: to.StringPtr(otherPath + "other")},
+			},
+		},
+	}
+	ops := &azureOps{
+		instance:          "instance",
+		resourceGroupName: "group",
+		disksClient:       &disksClient,
+		vmsClient:         vms,
+	}
+
+	luns, err := ops.ReconcileDataDisks("instance")
+	require.NoError(t, err)
+	require.Equal(t, []string{"1"}, luns)
+	require.Len(t, vms.updates, 1)
+	require.Len(t, vms.dataDisks, 2)
+	require.Equal(t, "live", *vms.dataDisks[0].Name)
+	require.Equal(t, "other", *vms.dataDisks[1].Name)
+
+	// nothing left to clean up, the VM should not be updated again
+	luns, err = ops.ReconcileDataDisks("instance")
+	require.NoError(t, err)
+	require.Empty(t, luns)
+	require.Len(t, vms.updates, 1)
+}
+
+func TestDetachFromDeletedInstance(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(r.URL.Path, "/virtualMachines/") {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": {"code": "ResourceNotFound", "message": "vm not found"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"name": "disk", "id": "/disks/disk", "managedBy": "/virtualMachines/deleted"}`)
+	}))
+	defer server.Close()
+
+	disksClient := compute.NewDisksClientWithBaseURI(server.URL, "subscription")
+	vmsClient := compute.NewVirtualMachinesClientWithBaseURI(server.URL, "subscription")
+	ops := &azureOps{
+		instance:          "instance",
+		resourceGroupName: "group",
+		disksClient:       &disksClient,
+		vmsClient: &baseVMsClient{
+			resourceGroupName: "group",
+			client:            &vmsClient,
+		},
+	}
+
+	require.NoError(t, ops.DetachFrom("disk", "deleted"))
+}
+
+func TestDiskInOtherResourceGroup(t *testing.T) {
+	const (
+		groupPath = "/subscriptions/subscription/resourceGroups/group/providers/Microsoft.Compute/disks/"
+		otherPath = "/subscriptions/subscription/resourceGroups/other-group/providers/Microsoft.Compute/disks/"
+		thirdPath = "/subscriptions/subscription/resourceGroups/third-group/providers/Microsoft.Compute/disks/"
+	)
+
+	var updates []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != otherPath+"disk1" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": {"code": "ResourceNotFound", "message": "disk not found"}}`)
+			return
+		}
+		if r.Method != http.MethodGet {
+			updates = append(updates, r.Method)
+		}
+		fmt.Fprintf(w, `{"id": "%s", "name": "disk1", "managedBy": "/virtualMachines/instance",
+			"tags": {"app": "db"}, "properties": {"diskSizeGB": 10, "provisioningState": "Succeeded",
+			"diskState": "Attached", "creationData": {"createOption": "Empty"}}}`,
+			otherPath+"disk1")
+	}))
+	defer server.Close()
+
+	disksClient := compute.NewDisksClientWithBaseURI(server.URL, "subscription")
+	disksClient.PollingDelay = 0
+	vms := &fakeVMsClient{
+		dataDisks: []compute.DataDisk{
+			{
+				Name:        to.StringPtr("disk1"),
+				Lun:         to.Int32Ptr(0),
+				ManagedDisk: &compute.ManagedDiskParameters{ID: to.StringPtr(otherPath + "disk1")},
+			},
+			{
+				Name:        to.StringPtr("disk1"),
+				Lun:         to.Int32Ptr(1),
+				ManagedDisk: &compute.ManagedDiskParameters{ID: to.StringPtr(thirdPath + "disk1")},
+			},
+		},
+	}
+	ops := &azureOps{
+		instance:          "instance",
+		resourceGroupName: "group",
+		disksClient:       &disksClient,
+		vmsClient:         vms,
+		opsTimeout: cloudops.OpsTimeoutConfig{
+			Timeout:       time.Second,
+			RetryInterval: time.Millisecond,
+		},
+	}
+
+	// the disk is found through the data disk of the instance
+	tags, err := ops.Tags("disk1")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"app": "db"}, tags)
+
+	require.NoError(t, ops.ApplyTags("disk1", map[string]string{"tier": "gold"}, nil))
+	require.NoError(t, ops.RemoveTags("disk1", map[string]string{"app": "db"}, nil))
+	_, err = ops.Expand("disk1", 20, nil)
+	require.NoError(t, err)
+	ready, err := ops.AreVolumesReadyToExpand([]*string{to.StringPtr("disk1")})
+	require.NoError(t, err)
+	require.True(t, ready)
+	require.NoError(t, ops.WaitForVolumeState("disk1", cloudops.VolumeStateAttached, time.Second))
+	require.NoError(t, ops.AdoptVolume("disk1", nil))
+	require.Equal(t, []string{http.MethodPatch, http.MethodPatch, http.MethodPut, http.MethodPatch}, updates)
+
+	// the option selects the resource group of the disk
+	_, err = ops.Tags("disk1")
+	require.NoError(t, err)
+	_, err = ops.Expand("disk1", 20, map[string]string{ResourceGroupKey: "group"})
+	require.True(t, isNotFoundError(err), "expected a not found error, got: %v", err)
+
+	// only the data disk from the selected resource group is detached
+	require.NoError(t, ops.Detach("disk1", map[string]string{ResourceGroupKey: "third-group"}))
+	require.Len(t, vms.dataDisks, 1)
+	require.Equal(t, otherPath+"disk1", *vms.dataDisks[0].ManagedDisk.ID)
+
+	// a disk that is not attached leaves the data disks as they are
+	require.NoError(t, ops.Detach("disk2", nil))
+	require.Len(t, vms.updates, 2)
+	require.Len(t, vms.dataDisks, 1)
+}
+
+func TestGetEffectivePerformance(t *testing.T) {
+	// The requested 500000 IOPS is above the ultra disk limit for the disk size,
+	// so the disk gets provisioned with the clamped values instead.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"name": "ultra", "sku": {"name": "UltraSSD_LRS"},
+			"properties": {"diskSizeGB": 100, "diskIOPSReadWrite": 100, "diskMBpsReadWrite": 1}}`)
+	}))
+	defer server.Close()
+
+	disksClient := compute.NewDisksClientWithBaseURI(server.URL, "subscription")
+	ops := &azureOps{
+		instance:          "instance",
+		resourceGroupName: "group",
+		disksClient:       &disksClient,
+	}
+
+	requestedIops, requestedTP := int64(500000), int64(2000)
+	updateUltraIopsThroughput(100, &requestedIops, &requestedTP)
+
+	iops, throughput, err := ops.GetEffectivePerformance("ultra")
+	require.NoError(t, err)
+	require.Equal(t, uint64(requestedIops), iops)
+	require.Equal(t, uint64(requestedTP), throughput)
+}
+
+func TestEmptyVolumeID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	disksClient := compute.NewDisksClientWithBaseURI(server.URL, "subscription")
+	ops := &azureOps{
+		instance:          "instance",
+		resourceGroupName: "group",
+		disksClient:       &disksClient,
+	}
+
+	test.RunEmptyVolumeIDTest(t, ops)
+}
+
+func TestGetVolumeLineage(t *testing.T) {
+	creationData := map[string]string{
+		"from-snap":  `{"createOption": "Copy", "sourceResourceId": "/subscriptions/subscription/resourceGroups/group/providers/Microsoft.Compute/snapshots/snap1"}`,
+		"from-disk":  `{"createOption": "Copy", "sourceResourceId": "/subscriptions/subscription/resourceGroups/group/providers/Microsoft.Compute/disks/disk1"}`,
+		"from-image": `{"createOption": "FromImage", "imageReference": {"id": "/subscriptions/subscription/providers/Microsoft.Compute/locations/eastus/publishers/p/artifacttypes/vmimage/offers/o/skus/s/versions/1"}}`,
+		"empty":      `{"createOption": "Empty"}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"name": %q, "properties": {"diskSizeGB": 100, "creationData": %s}}`, name, creationData[name])
+	}))
+	defer server.Close()
+
+	disksClient := compute.NewDisksClientWithBaseURI(server.URL, "subscription")
+	ops := &azureOps{
+		instance:          "instance",
+		resourceGroupName: "group",
+		disksClient:       &disksClient,
+	}
+
+	cases := []struct {
+		name       string
+		sourceType string
+		sourceID   string
+	}{
+		{"from-snap", cloudops.VolumeSourceSnapshot, "snap1"},
+		{"from-disk", cloudops.VolumeSourceClone, "disk1"},
+		{"from-image", cloudops.VolumeSourceImage,
+			"/subscriptions/subscription/providers/Microsoft.Compute/locations/eastus/publishers/p/artifacttypes/vmimage/offers/o/skus/s/versions/1"},
+		{"empty", "", ""},
+	}
+	for _, c := range cases {
+		sourceType, sourceID, err := ops.GetVolumeLineage(c.name)
+		require.NoError(t, err, c.name)
+		require.Equal(t, c.sourceType, sourceType, c.name)
+		require.Equal(t, c.sourceID, sourceID, c.name)
+	}
+}
+
+func TestBatchInspect(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		require.True(t, strings.HasSuffix(r.URL.Path, "/resourceGroups/group/providers/Microsoft.Compute/disks"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"value": [{"name": "disk1"}, {"name": "disk2"}, {"name": "disk3"}]}`)
+	}))
+	defer server.Close()
+
+	disksClient := compute.NewDisksClientWithBaseURI(server.URL, "subscription")
+	ops := &azureOps{
+		instance:          "instance",
+		resourceGroupName: "group",
+		disksClient:       &disksClient,
+	}
+
+	disks, err := ops.BatchInspect([]*string{to.StringPtr("disk1"), to.StringPtr("disk3")})
+	require.NoError(t, err)
+	require.Equal(t, 1, requests)
+	require.Len(t, disks, 2)
+	require.Equal(t, "disk3", *disks["disk3"].(*compute.Disk).Name)
+
+	_, err = ops.BatchInspect([]*string{to.StringPtr("disk4")})
+	require.Error(t, err)
+	se, ok := err.(*cloudops.StorageError)
+	require.True(t, ok)
+	require.Equal(t, cloudops.ErrVolNotFound, se.Code)
+}
+
+func TestGetVolumeQoS(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/disks/standard") {
+			fmt.Fprint(w, `{"name": "standard", "sku": {"name": "Standard_LRS"},
+				"properties": {"diskSizeGB": 100}}`)
+			return
+		}
+		fmt.Fprint(w, `{"name": "premiumv2", "sku": {"name": "PremiumV2_LRS"},
+			"properties": {"diskSizeGB": 100, "diskIOPSReadWrite": 5000, "diskMBpsReadWrite": 200}}`)
+	}))
+	defer server.Close()
+
+	disksClient := compute.NewDisksClientWithBaseURI(server.URL, "subscription")
+	ops := &azureOps{
+		instance:          "instance",
+		resourceGroupName: "group",
+		disksClient:       &disksClient,
+	}
+
+	iopsLimit, throughputLimit, err := ops.GetVolumeQoS("premiumv2")
+	require.NoError(t, err)
+	require.Equal(t, uint64(5000), iopsLimit)
+	require.Equal(t, uint64(200), throughputLimit)
+
+	_, _, err = ops.GetVolumeQoS("standard")
+	require.Error(t, err)
+}
+
+func TestNetworkInfoFromMetadata(t *testing.T) {
+	metadata := `{
+		"interface": [{
+			"ipv4": {
+				"ipAddress": [
+					{"privateIpAddress": "10.240.0.4", "publicIpAddress": ""},
+					{"privateIpAddress": "10.240.0.5", "publicIpAddress": ""}
+				],
+				"subnet": [{"address": "10.240.0.0", "prefix": "16"}]
+			},
+			"macAddress": "000D3A000000"
+		}]
+	}`
+
+	networkInfo, err := networkInfoFromMetadata([]byte(metadata))
+	require.NoError(t, err)
+	require.Empty(t, networkInfo.Network)
+	require.Equal(t, "10.240.0.0/16", networkInfo.Subnet)
+	require.Equal(t, []string{"10.240.0.4", "10.240.0.5"}, networkInfo.PrivateIPs)
+
+	_, err = networkInfoFromMetadata([]byte("not json"))
+	require.Error(t, err)
+}
+
+func TestGetNetworkInfoForRemoteInstance(t *testing.T) {
+	a := &azureOps{instance: "local"}
+	_, err := a.GetNetworkInfo("remote")
+	require.Error(t, err)
+	_, ok := err.(*cloudops.ErrNotSupported)
+	require.True(t, ok)
+}
+
+func TestCreateEnforcesMinSize(t *testing.T) {
+	a := &azureOps{}
+	newDisk := func(sku compute.DiskStorageAccountTypes, size int32) *compute.Disk {
+		return &compute.Disk{
+			Name: to.StringPtr("disk1"),
+			Sku:  &compute.DiskSku{Name: sku},
+			DiskProperties: &compute.DiskProperties{
+				DiskSizeGB: to.Int32Ptr(size),
+			},
+		}
+	}
+	errorPolicy := map[string]string{cloudops.MinSizePolicyOption: cloudops.MinSizePolicyError}
+
+	_, err := a.Create(newDisk(compute.UltraSSDLRS, 2), nil, errorPolicy)
+	require.Error(t, err)
+	se, ok := err.(*cloudops.StorageError)
+	require.True(t, ok)
+	require.Equal(t, cloudops.ErrVolInval, se.Code)
+
+	_, err = a.Create(newDisk(compute.PremiumLRS, 0), nil, errorPolicy)
+	require.Error(t, err)
+
+	_, err = a.Create(newDisk(compute.PremiumLRS, 0), nil, map[string]string{cloudops.MinSizePolicyOption: "truncate"})
+	require.Error(t, err)
+}
+
+func TestReportCapacityByLabel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.True(t, strings.HasSuffix(r.URL.Path, "/resourceGroups/group/providers/Microsoft.Compute/disks"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"value": [
+			{"name": "disk1", "tags": {"team": "storage"}, "properties": {"diskSizeGB": 100}},
+			{"name": "disk2", "tags": {"team": "compute"}, "properties": {"diskSizeGB": 50}},
+			{"name": "disk3", "properties": {"diskSizeGB": 10}},
+			{"name": "disk4", "tags": {"team": "storage"}, "properties": {"diskSizeGB": 200}},
+			{"name": "disk5", "tags": {"app": "db"}, "properties": {"diskSizeGB": 20}}
+		]}`)
+	}))
+	defer server.Close()
+
+	disksClient := compute.NewDisksClientWithBaseURI(server.URL, "subscription")
+	ops := &azureOps{
+		instance:          "instance",
+		resourceGroupName: "group",
+		disksClient:       &disksClient,
+	}
+
+	capacities, err := ops.ReportCapacityByLabel("team")
+	require.NoError(t, err)
+	require.Equal(t, map[string]uint64{
+		"storage": 300,
+		"compute": 50,
+	}, capacities)
+}
+
+func TestUpdateAttachmentCaching(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"name": "data", "id": "/disks/data", "properties": {"diskSizeGB": 10}}`)
+	}))
+	defer server.Close()
+
+	disksClient := compute.NewDisksClientWithBaseURI(server.URL, "subscription")
+	vms := &fakeVMsClient{
+		dataDisks: []compute.DataDisk{
+			{
+				Name:        to.StringPtr("other"),
+				Lun:         to.Int32Ptr(0),
+				ManagedDisk: &compute.ManagedDiskParameters{ID: to.StringPtr("/disks/other")},
+			},
+			{
+				Name:        to.StringPtr("data"),
+				Lun:         to.Int32Ptr(3),
+				Caching:     compute.CachingTypesNone,
+				DiskSizeGB:  to.Int32Ptr(10),
+				ManagedDisk: &compute.ManagedDiskParameters{ID: to.StringPtr("/disks/data")},
+			},
+		},
+	}
+	ops := &azureOps{
+		instance:          "instance",
+		resourceGroupName: "group",
+		disksClient:       &disksClient,
+		vmsClient:         vms,
+	}
+
+	err := ops.UpdateAttachmentCaching("remote", "data", "WriteBack")
+	require.Error(t, err)
+	require.Empty(t, vms.updates)
+
+	err = ops.UpdateAttachmentCaching("remote", "data", "readonly")
+	require.NoError(t, err)
+	// one update to detach and one to re-attach
+	require.Len(t, vms.updates, 2)
+	require.Len(t, vms.updates[0], 1)
+	require.Len(t, vms.dataDisks, 2)
+	data := vms.dataDisks[1]
+	require.Equal(t, "data", *data.Name)
+	require.Equal(t, compute.CachingTypesReadOnly, data.Caching)
+	require.Equal(t, int32(3), *data.Lun, "disk should be re-attached on its original LUN")
+	require.Equal(t, compute.DiskCreateOptionTypesAttach, data.CreateOption)
+
+	// the caching is already set, nothing to do
+	err = ops.UpdateAttachmentCaching("remote", "data", string(compute.CachingTypesReadOnly))
+	require.NoError(t, err)
+	require.Len(t, vms.updates, 2)
+}
+
+func TestListInstanceGroupMembers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/resourceGroups/group/providers/Microsoft.ContainerService/managedClusters/cluster"):
+			fmt.Fprint(w, `{"name": "cluster", "properties": {"nodeResourceGroup": "MC_group"}}`)
+		case strings.HasSuffix(r.URL.Path, "/resourceGroups/MC_group/providers/Microsoft.Compute/virtualMachineScaleSets"):
+			fmt.Fprint(w, `{"value": [
+				{"name": "aks-system-1-vmss", "tags": {"aks-managed-poolName": "system"}},
+				{"name": "aks-pool1-2-vmss", "tags": {"aks-managed-poolName": "pool1"}}
+			]}`)
+		case strings.HasSuffix(r.URL.Path, "/virtualMachineScaleSets/aks-pool1-2-vmss/virtualMachines"):
+			fmt.Fprint(w, `{"value": [
+				{"name": "aks-pool1-2-vmss_0", "instanceId": "0"},
+				{"name": "aks-pool1-2-vmss_1", "instanceId": "1"},
+				{"name": "aks-pool1-2-vmss_3", "instanceId": "3"}
+			]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": {"code": "ResourceNotFound", "message": "not found"}}`)
+		}
+	}))
+	defer server.Close()
+
+	managedClustersClient := containerservice.NewManagedClustersClientWithBaseURI(server.URL, "subscription")
+	scaleSetsClient := compute.NewVirtualMachineScaleSetsClientWithBaseURI(server.URL, "subscription")
+	scaleSetVMsClient := compute.NewVirtualMachineScaleSetVMsClientWithBaseURI(server.URL, "subscription")
+	ops := &azureOps{
+		resourceGroupName:     "group",
+		managedClusterName:    "cluster",
+		managedClustersClient: &managedClustersClient,
+		scaleSetsClient:       &scaleSetsClient,
+		scaleSetVMsClient:     &scaleSetVMsClient,
+	}
+
+	members, err := ops.ListInstanceGroupMembers("pool1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"aks-pool1-2-vmss_0", "aks-pool1-2-vmss_1", "aks-pool1-2-vmss_3"}, members)
+
+	_, err = ops.ListInstanceGroupMembers("missing")
+	require.Error(t, err)
+	_, ok := err.(*cloudops.ErrNoInstanceGroup)
+	require.True(t, ok)
+}
+
+// restorePointVMsClient describes a VM with an OS disk and two data disks
+type restorePointVMsClient struct {
+	fakeVMsClient
+}
+
+func (r *restorePointVMsClient) describe(instanceID string) (interface{}, error) {
+	return compute.VirtualMachine{
+		ID:       to.StringPtr("/virtualMachines/" + instanceID),
+		Name:     to.StringPtr(instanceID),
+		Location: to.StringPtr("eastus"),
+		VirtualMachineProperties: &compute.VirtualMachineProperties{
+			StorageProfile: &compute.StorageProfile{
+				OsDisk: &compute.OSDisk{
+					ManagedDisk: &compute.ManagedDiskParameters{ID: to.StringPtr("/disks/os")},
+				},
+				DataDisks: &[]compute.DataDisk{
+					{ManagedDisk: &compute.ManagedDiskParameters{ID: to.StringPtr("/disks/data")}},
+					{ManagedDisk: &compute.ManagedDiskParameters{ID: to.StringPtr("/disks/other")}},
+				},
+			},
+		},
+	}, nil
+}
+
+func TestSnapshotAppConsistent(t *testing.T) {
+	var (
+		restorePoint compute.RestorePoint
+		snapshot     compute.Snapshot
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/disks/data"):
+			fmt.Fprint(w, `{"id": "/disks/data", "name": "data", "location": "eastus", "managedBy": "/virtualMachines/vm1"}`)
+		case strings.HasSuffix(r.URL.Path, "/disks/detached"):
+			fmt.Fprint(w, `{"id": "/disks/detached", "name": "detached", "location": "eastus"}`)
+		case strings.HasSuffix(r.URL.Path, "/restorePointCollections/cloudops-vm1"):
+			require.Equal(t, http.MethodPut, r.Method)
+			fmt.Fprint(w, `{"name": "cloudops-vm1", "properties": {"source": {"id": "/virtualMachines/vm1"}}}`)
+		case strings.Contains(r.URL.Path, "/restorePointCollections/cloudops-vm1/restorePoints/"):
+			// the restore point is read back once it is created
+			if r.Method == http.MethodPut {
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&restorePoint))
+			}
+			fmt.Fprint(w, `{"properties": {"consistencyMode": "ApplicationConsistent", "sourceMetadata": {
+				"storageProfile": {"dataDisks": [
+					{"managedDisk": {"id": "/disks/data"}, "diskRestorePoint": {"id": "/diskRestorePoints/data"}}
+				]}}}}`)
+		case strings.Contains(r.URL.Path, "/snapshots/"):
+			require.Equal(t, http.MethodPut, r.Method)
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&snapshot))
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": {"code": "ResourceNotFound", "message": "not found"}}`)
+		}
+	}))
+	defer server.Close()
+
+	disksClient := compute.NewDisksClientWithBaseURI(server.URL, "subscription")
+	snapshotsClient := compute.NewSnapshotsClientWithBaseURI(server.URL, "subscription")
+	restorePointCollectionsClient := compute.NewRestorePointCollectionsClientWithBaseURI(server.URL, "subscription")
+	restorePointsClient := compute.NewRestorePointsClientWithBaseURI(server.URL, "subscription")
+	ops := &azureOps{
+		instance:                      "instance",
+		resourceGroupName:             "group",
+		disksClient:                   &disksClient,
+		vmsClient:                     &restorePointVMsClient{},
+		snapshotsClient:               &snapshotsClient,
+		restorePointCollectionsClient: &restorePointCollectionsClient,
+		restorePointsClient:           &restorePointsClient,
+	}
+	options := map[string]string{
+		cloudops.AppConsistentOption: "true",
+		cloudops.SnapshotWaitOption:  "false",
+	}
+
+	snap, err := ops.Snapshot("data", true, options)
+	require.NoError(t, err)
+	// the restore point only includes the snapshotted disk
+	require.NotNil(t, restorePoint.RestorePointProperties)
+	require.Empty(t, restorePoint.ConsistencyMode, "Azure defaults to application-consistent restore points")
+	require.Equal(t, []compute.APIEntityReference{
+		{ID: to.StringPtr("/disks/os")},
+		{ID: to.StringPtr("/disks/other")},
+	}, *restorePoint.ExcludeDisks)
+	// the snapshot is copied from the disk restore point
+	require.Equal(t, "/diskRestorePoints/data", *snapshot.CreationData.SourceResourceID)
+	require.Equal(t, "true", *snap.(*compute.Snapshot).Tags[cloudops.SnapshotAppConsistentTagKey])
+
+	// a detached disk falls back to a crash-consistent snapshot
+	restorePoint = compute.RestorePoint{}
+	snap, err = ops.Snapshot("detached", true, options)
+	require.NoError(t, err)
+	require.Nil(t, restorePoint.RestorePointProperties)
+	require.Equal(t, "/disks/detached", *snapshot.CreationData.SourceResourceID)
+	require.Equal(t, "false", *snap.(*compute.Snapshot).Tags[cloudops.SnapshotAppConsistentTagKey])
+}
+
+func TestAdoptVolume(t *testing.T) {
+	var update compute.DiskUpdate
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/disks/detached"):
+			if r.Method == http.MethodPatch {
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&update))
+			}
+			fmt.Fprint(w, `{"name": "detached", "tags": {"app": "db"}, "properties": {"diskState": "Unattached"}}`)
+		case strings.HasSuffix(r.URL.Path, "/disks/remote"):
+			fmt.Fprint(w, `{"name": "remote", "managedBy": "/virtualMachines/other", "properties": {"diskState": "Attached"}}`)
+		case strings.HasSuffix(r.URL.Path, "/disks/uploading"):
+			fmt.Fprint(w, `{"name": "uploading", "properties": {"diskState": "ActiveUpload"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": {"code": "ResourceNotFound", "message": "not found"}}`)
+		}
+	}))
+	defer server.Close()
+
+	disksClient := compute.NewDisksClientWithBaseURI(server.URL, "subscription")
+	ops := &azureOps{
+		instance:          "instance",
+		resourceGroupName: "group",
+		disksClient:       &disksClient,
+		vmsClient:         &fakeVMsClient{},
+	}
+
+	err := ops.AdoptVolume("detached", map[string]string{"cluster": "c1"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]*string{
+		"app":                        to.StringPtr("db"),
+		"cluster":                    to.StringPtr("c1"),
+		cloudops.VolumeManagedTagKey: to.StringPtr("true"),
+	}, update.Tags)
+
+	requireStorageErr := func(err error, code int) {
+		require.Error(t, err)
+		storageErr, ok := err.(*cloudops.StorageError)
+		require.True(t, ok, "expected a StorageError, got %T", err)
+		require.Equal(t, code, storageErr.Code)
+	}
+	requireStorageErr(ops.AdoptVolume("remote", nil), cloudops.ErrVolAttachedOnRemoteNode)
+	requireStorageErr(ops.AdoptVolume("uploading", nil), cloudops.ErrVolInval)
+	requireStorageErr(ops.AdoptVolume("missing", nil), cloudops.ErrVolNotFound)
+}
+
+func TestInspectInstanceInZone(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/resourceGroups/group/providers/Microsoft.Compute/virtualMachineScaleSets"):
+			fmt.Fprint(w, `{"value": [{"name": "vmss-a"}, {"name": "vmss-b"}]}`)
+		case strings.HasSuffix(r.URL.Path, "/virtualMachineScaleSets/vmss-a/virtualMachines"):
+			fmt.Fprint(w, `{"value": [
+				{"name": "vmss-a_0", "id": "/vmss-a/0", "location": "eastus", "zones": ["1"]}
+			]}`)
+		case strings.HasSuffix(r.URL.Path, "/virtualMachineScaleSets/vmss-b/virtualMachines"):
+			fmt.Fprint(w, `{"value": [
+				{"name": "vmss-b_0", "id": "/vmss-b/0", "location": "eastus", "zones": ["2"], "tags": {"role": "storage"}}
+			]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": {"code": "ResourceNotFound", "message": "not found"}}`)
+		}
+	}))
+	defer server.Close()
+
+	scaleSetsClient := compute.NewVirtualMachineScaleSetsClientWithBaseURI(server.URL, "subscription")
+	scaleSetVMsClient := compute.NewVirtualMachineScaleSetVMsClientWithBaseURI(server.URL, "subscription")
+	ops := &azureOps{
+		resourceGroupName: "group",
+		scaleSetsClient:   &scaleSetsClient,
+		scaleSetVMsClient: &scaleSetVMsClient,
+	}
+
+	info, err := ops.InspectInstanceInZone("vmss-b_0", "2")
+	require.NoError(t, err)
+	require.Equal(t, "vmss-b_0", info.Name)
+	require.Equal(t, "/vmss-b/0", info.ID)
+	require.Equal(t, "2", info.Zone)
+	require.Equal(t, "eastus", info.Region)
+	require.Equal(t, map[string]string{"role": "storage"}, info.Labels)
+
+	// the zone can also be given in the Kubernetes topology form
+	info, err = ops.InspectInstanceInZone("vmss-b_0", "eastus-2")
+	require.NoError(t, err)
+	require.Equal(t, "2", info.Zone)
+
+	_, err = ops.InspectInstanceInZone("vmss-b_0", "1")
+	require.Error(t, err)
+	_, ok := err.(*cloudops.ErrNotFound)
+	require.True(t, ok)
+}
+
+func TestSnapshotWithoutWait(t *testing.T) {
+	polled := false
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/disks/disk1"):
+			fmt.Fprint(w, `{"id": "/disks/disk1", "name": "disk1", "location": "eastus"}`)
+		case strings.Contains(r.URL.Path, "/snapshots/"):
+			require.Equal(t, http.MethodPut, r.Method)
+			w.Header().Set("Azure-AsyncOperation", server.URL+"/operations/snapshot")
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			// The snapshot creation must not be polled when not waiting
+			polled = true
+			fmt.Fprint(w, `{"status": "InProgress"}`)
+		}
+	}))
+	defer server.Close()
+
+	disksClient := compute.NewDisksClientWithBaseURI(server.URL, "subscription")
+	snapshotsClient := compute.NewSnapshotsClientWithBaseURI(server.URL, "subscription")
+	ops := &azureOps{
+		instance:          "instance",
+		resourceGroupName: "group",
+		disksClient:       &disksClient,
+		snapshotsClient:   &snapshotsClient,
+	}
+
+	_, err := ops.Snapshot("disk1", true, map[string]string{cloudops.SnapshotWaitOption: "maybe"})
+	require.Error(t, err)
+
+	snap, err := ops.Snapshot("disk1", true, map[string]string{cloudops.SnapshotWaitOption: "false"})
+	require.NoError(t, err)
+	require.False(t, polled)
+
+	snapshot, ok := snap.(*compute.Snapshot)
+	require.True(t, ok)
+	require.True(t, strings.HasPrefix(*snapshot.Name, "snap-"))
+	require.Equal(t, "/disks/disk1", *snapshot.CreationData.SourceResourceID)
+
+	id, err := ops.GetDeviceID(snap)
+	require.NoError(t, err)
+	require.Equal(t, *snapshot.Name, id)
+}
+
+func TestConfigureReplication(t *testing.T) {
+	const snapshotsPath = "/subscriptions/subscription/resourceGroups/group/providers/Microsoft.Compute/snapshots"
+
+	snapshots := make(map[string]map[string]interface{})
+	created := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/disks/disk1"):
+			fmt.Fprint(w, `{"id": "/disks/disk1", "name": "disk1", "location": "eastus", "tags": {"app": "db"}}`)
+		case r.URL.Path == snapshotsPath:
+			values := make([]map[string]interface{}, 0, len(snapshots))
+			for _, snap := range snapshots {
+				values = append(values, snap)
+			}
+			require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{"value": values}))
+		case strings.HasPrefix(r.URL.Path, snapshotsPath+"/"):
+			name := path.Base(r.URL.Path)
+			switch r.Method {
+			case http.MethodPut:
+				var snap map[string]interface{}
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&snap))
+				created++
+				props := snap["properties"].(map[string]interface{})
+				props["timeCreated"] = time.Date(2023, 1, 1, 0, created, 0, 0, time.UTC).Format(time.RFC3339)
+				if props["creationData"].(map[string]interface{})["createOption"] == string(compute.CopyStart) {
+					props["completionPercent"] = 0
+				}
+				snap["id"] = r.URL.Path
+				snap["name"] = name
+				snapshots[name] = snap
+				require.NoError(t, json.NewEncoder(w).Encode(snap))
+			case http.MethodDelete:
+				delete(snapshots, name)
+			default:
+				require.NoError(t, json.NewEncoder(w).Encode(snapshots[name]))
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": {"code": "ResourceNotFound", "message": "not found"}}`)
+		}
+	}))
+	defer server.Close()
+
+	// completeCopies marks the replicas as copied and returns the names of the
+	// local snapshots and replicas
+	completeCopies := func() (local []string, replicas []string) {
+		for name, snap := range snapshots {
+			props := snap["properties"].(map[string]interface{})
+			if _, ok := props["completionPercent"]; ok {
+				props["completionPercent"] = 100
+				replicas = append(replicas, name)
+			} else {
+				local = append(local, name)
+			}
+		}
+		sort.Strings(local)
+		sort.Strings(replicas)
+		return local, replicas
+	}
+
+	disksClient := compute.NewDisksClientWithBaseURI(server.URL, "subscription")
+	disksClient.PollingDelay = 0
+	snapshotsClient := compute.NewSnapshotsClientWithBaseURI(server.URL, "subscription")
+	snapshotsClient.PollingDelay = 0
+	ops := &azureOps{
+		instance:          "instance",
+		resourceGroupName: "group",
+		disksClient:       &disksClient,
+		snapshotsClient:   &snapshotsClient,
+	}
+
+	require.Error(t, ops.ConfigureReplication("disk1", "westus", map[string]string{ReplicaRetentionKey: "0"}))
+
+	// the local snapshot is kept while it is being copied
+	require.NoError(t, ops.ConfigureReplication("disk1", "westus", nil))
+	local, replicas := completeCopies()
+	require.Len(t, local, 1)
+	require.Len(t, replicas, 1)
+	replica := snapshots[replicas[0]]
+	require.Equal(t, "westus", replica["location"])
+	require.Equal(t, map[string]interface{}{
+		"app":                      "db",
+		replicationSourceTag:       "disk1",
+		replicationTargetRegionTag: "westus",
+	}, replica["tags"])
+
+	// the local snapshot of the copied replica is deleted
+	require.NoError(t, ops.ConfigureReplication("disk1", "westus", map[string]string{ReplicaRetentionKey: "2"}))
+	newLocal, newReplicas := completeCopies()
+	require.Len(t, newLocal, 1)
+	require.NotEqual(t, local, newLocal)
+	require.Len(t, newReplicas, 2)
+
+	// only the newest replica is kept by default
+	require.NoError(t, ops.ConfigureReplication("disk1", "westus", nil))
+	_, latestReplicas := completeCopies()
+	require.Len(t, latestReplicas, 2)
+	require.NotContains(t, latestReplicas, replicas[0])
+
+	// stopping the replication deletes all its snapshots
+	require.NoError(t, ops.StopReplication("disk1"))
+	require.Empty(t, snapshots)
+}
+
+func TestEnumerateSnapshots(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/resourceGroups/group/providers/Microsoft.Compute/snapshots"):
+			fmt.Fprint(w, `{"value": [
+				{"name": "snap1", "tags": {"app": "db"}, "properties": {
+					"creationData": {"createOption": "Copy", "sourceResourceId": "/disks/disk1"},
+					"diskSizeGB": 64, "provisioningState": "Succeeded",
+					"timeCreated": "2023-01-02T03:04:05Z"}},
+				{"name": "snap2", "tags": {"app": "web"}, "properties": {
+					"creationData": {"createOption": "Copy", "sourceResourceId": "/disks/disk2"}}}
+			]}`)
+		case strings.HasSuffix(r.URL.Path, "/snapshots/snap1"):
+			fmt.Fprint(w, `{"name": "snap1", "properties": {
+				"creationData": {"createOption": "Copy", "sourceResourceId": "/disks/disk1"}}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": {"code": "ResourceNotFound"}}`)
+		}
+	}))
+	defer server.Close()
+
+	snapshotsClient := compute.NewSnapshotsClientWithBaseURI(server.URL, "subscription")
+	ops := &azureOps{
+		instance:          "instance",
+		resourceGroupName: "group",
+		snapshotsClient:   &snapshotsClient,
+	}
+
+	snaps, err := ops.EnumerateSnapshots(nil, map[string]string{"app": "db"})
+	require.NoError(t, err)
+	require.Len(t, snaps, 1)
+	require.Equal(t, "snap1", snaps[0].ID)
+	require.Equal(t, "disk1", snaps[0].VolumeID)
+	require.Equal(t, uint64(64), snaps[0].SizeInGiB)
+	require.Equal(t, "Succeeded", snaps[0].State)
+	require.Equal(t, map[string]string{"app": "db"}, snaps[0].Labels)
+	require.Equal(t, time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC), snaps[0].CreationTime.UTC())
+
+	snaps, err = ops.EnumerateSnapshots(nil, nil)
+	require.NoError(t, err)
+	require.Len(t, snaps, 2)
+
+	snaps, err = ops.EnumerateSnapshots([]*string{to.StringPtr("disk2")}, nil)
+	require.NoError(t, err)
+	require.Len(t, snaps, 1)
+	require.Equal(t, "snap2", snaps[0].ID)
+
+	snap, err := ops.InspectSnapshot("snap1")
+	require.NoError(t, err)
+	require.Equal(t, "disk1", snap.VolumeID)
+
+	_, err = ops.InspectSnapshot("missing")
+	require.Error(t, err)
+	storageErr, ok := err.(*cloudops.StorageError)
+	require.True(t, ok)
+	require.Equal(t, cloudops.ErrVolNotFound, storageErr.Code)
+}
+
+func TestGetClusterVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/managedClusters/cluster"):
+			fmt.Fprint(w, `{"name": "cluster", "properties": {
+				"kubernetesVersion": "1.27", "currentKubernetesVersion": "1.27.3"}}`)
+		case strings.HasSuffix(r.URL.Path, "/managedClusters/cluster/agentPools/pool1"):
+			fmt.Fprint(w, `{"name": "pool1", "properties": {
+				"orchestratorVersion": "1.26", "currentOrchestratorVersion": "1.26.6"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": {"code": "ResourceNotFound", "message": "not found"}}`)
+		}
+	}))
+	defer server.Close()
+
+	managedClustersClient := containerservice.NewManagedClustersClientWithBaseURI(server.URL, "subscription")
+	agentPoolsClient := containerservice.NewAgentPoolsClientWithBaseURI(server.URL, "subscription")
+	ops := &azureOps{
+		resourceGroupName:     "group",
+		managedClusterName:    "cluster",
+		agentPoolName:         "pool1",
+		managedClustersClient: &managedClustersClient,
+		agentPoolsClient:      &agentPoolsClient,
+	}
+
+	controlPlane, nodePool, err := ops.GetClusterVersion("instance")
+	require.NoError(t, err)
+	require.Equal(t, "1.27.3", controlPlane)
+	require.Equal(t, "1.26.6", nodePool)
+}
+
+func TestCreateInResourceGroup(t *testing.T) {
+	const diskPath = "/subscriptions/subscription/resourceGroups/other-group/providers/Microsoft.Compute/disks/disk1"
+
+	created := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		require.Equal(t, diskPath, r.URL.Path)
+		switch r.Method {
+		case http.MethodGet:
+			if !created {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"error": {"code": "ResourceNotFound", "message": "disk not found"}}`)
+				return
+			}
+		case http.MethodPut:
+			created = true
+		case http.MethodDelete:
+			created = false
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		fmt.Fprintf(w, `{"id": "%s", "name": "disk1", "properties": {"diskSizeGB": 10, "provisioningState": "Succeeded"}}`, diskPath)
+	}))
+	defer server.Close()
+
+	disksClient := compute.NewDisksClientWithBaseURI(server.URL, "subscription")
+	disksClient.PollingDelay = 0
+	ops := &azureOps{
+		instance:          "instance",
+		resourceGroupName: "group",
+		disksClient:       &disksClient,
+	}
+	options := map[string]string{ResourceGroupKey: "other-group"}
+
+	d, err := ops.Create(&compute.Disk{
+		Name:     to.StringPtr("disk1"),
+		Location: to.StringPtr("eastus"),
+		Sku:      &compute.DiskSku{Name: compute.PremiumLRS},
+		DiskProperties: &compute.DiskProperties{
+			DiskSizeGB: to.Int32Ptr(10),
+		},
+	}, nil, options)
+	require.NoError(t, err)
+	require.True(t, created)
+	require.Equal(t, diskPath, *d.(*compute.Disk).ID)
+
+	disks, err := ops.Inspect([]*string{to.StringPtr("disk1")}, options)
+	require.NoError(t, err)
+	require.Len(t, disks, 1)
+	require.Equal(t, diskPath, *disks[0].(*compute.Disk).ID)
+
+	require.NoError(t, ops.Delete("disk1", options))
+	require.False(t, created)
+}
+
+func TestCloneVolume(t *testing.T) {
+	const (
+		disksPath  = "/subscriptions/subscription/resourceGroups/group/providers/Microsoft.Compute/disks/"
+		sourcePath = disksPath + "source"
+	)
+
+	var created compute.Disk
+	exists := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == sourcePath:
+			fmt.Fprintf(w, `{"id": "%s", "name": "source", "location": "eastus", "sku": {"name": "Premium_LRS"},
+				"properties": {"diskSizeGB": 64, "provisioningState": "Succeeded"}}`, sourcePath)
+		case r.Method == http.MethodPut || (r.Method == http.MethodGet && exists):
+			if r.Method == http.MethodPut {
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&created))
+				exists = true
+			}
+			fmt.Fprintf(w, `{"id": "%s", "name": "clone", "properties": {"diskSizeGB": 64, "provisioningState": "Succeeded"}}`,
+				r.URL.Path)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": {"code": "ResourceNotFound", "message": "disk not found"}}`)
+		}
+	}))
+	defer server.Close()
+
+	disksClient := compute.NewDisksClientWithBaseURI(server.URL, "subscription")
+	disksClient.PollingDelay = 0
+	ops := &azureOps{
+		instance:          "instance",
+		resourceGroupName: "group",
+		disksClient:       &disksClient,
+	}
+
+	d, err := ops.CloneVolume("source", &compute.Disk{Name: to.StringPtr("clone")}, map[string]string{"app": "db"})
+	require.NoError(t, err)
+	require.Equal(t, "clone", *d.(*compute.Disk).Name)
+	require.Equal(t, compute.Copy, created.CreationData.CreateOption)
+	require.Equal(t, sourcePath, *created.CreationData.SourceResourceID)
+	require.Equal(t, int32(64), *created.DiskSizeGB)
+	require.Equal(t, "eastus", *created.Location)
+	require.Equal(t, compute.PremiumLRS, created.Sku.Name)
+	require.Equal(t, "db", *created.Tags["app"])
+
+	_, err = ops.CloneVolume("missing", &compute.Disk{Name: to.StringPtr("clone")}, nil)
+	require.Error(t, err)
+}
+
+// layoutVMsClient is a vmsClient that serves the data disks of each instance
+type layoutVMsClient struct {
+	dataDisks map[string][]compute.DataDisk
+}
+
+func (l *layoutVMsClient) name(instanceID string) string {
+	return instanceID
+}
+
+func (l *layoutVMsClient) describe(instanceID string) (interface{}, error) {
+	return compute.VirtualMachine{
+		Name:     to.StringPtr(instanceID),
+		Location: to.StringPtr("eastus"),
+		Zones:    &[]string{"1"},
+		VirtualMachineProperties: &compute.VirtualMachineProperties{
+			HardwareProfile: &compute.HardwareProfile{VMSize: compute.StandardE4sV3},
+		},
+	}, nil
+}
+
+func (l *layoutVMsClient) getDataDisks(instanceID string) ([]compute.DataDisk, error) {
+	return l.dataDisks[instanceID], nil
+}
+
+func (l *layoutVMsClient) updateDataDisks(instanceID string, dataDisks []compute.DataDisk) error {
+	l.dataDisks[instanceID] = dataDisks
+	return nil
+}
+
+func TestStorageLayoutRoundTrip(t *testing.T) {
+	const (
+		groupPath = "/subscriptions/subscription/resourceGroups/group/providers/Microsoft.Compute/disks/"
+		otherPath = "/subscriptions/subscription/resourceGroups/other-group/providers/Microsoft.Compute/disks/"
+	)
+	// data-2 is in another resource group than the client
+	disks := map[string]compute.Disk{
+		groupPath + "data-1": {
+			Name: to.StringPtr("data-1"),
+			Sku:  &compute.DiskSku{Name: compute.PremiumLRS},
+			Tags: map[string]*string{"pool": to.StringPtr("0")},
+			DiskProperties: &compute.DiskProperties{
+				DiskSizeGB:        to.Int32Ptr(128),
+				DiskIOPSReadWrite: to.Int64Ptr(500),
+				DiskMBpsReadWrite: to.Int64Ptr(100),
+			},
+		},
+		otherPath + "data-2": {
+			Name: to.StringPtr("data-2"),
+			Sku:  &compute.DiskSku{Name: compute.PremiumV2LRS},
+			Tags: map[string]*string{"pool": to.StringPtr("1")},
+			DiskProperties: &compute.DiskProperties{
+				DiskSizeGB:        to.Int32Ptr(256),
+				DiskIOPSReadWrite: to.Int64Ptr(5000),
+				DiskMBpsReadWrite: to.Int64Ptr(200),
+			},
+		},
+	}
+	vms := &layoutVMsClient{dataDisks: map[string][]compute.DataDisk{
+		"old": {
+			{Name: to.StringPtr("data-2"), Lun: to.Int32Ptr(1),
+				ManagedDisk: &compute.ManagedDiskParameters{ID: to.StringPtr(otherPath + "data-2")}},
+			{Name: to.StringPtr("data-1"), Lun: to.Int32Ptr(0),
+				ManagedDisk: &compute.ManagedDiskParameters{ID: to.StringPtr(groupPath + "data-1")}},
+			{Name: to.StringPtr("unmanaged"), Lun: to.Int32Ptr(2)},
+		},
+	}}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		name := path.Base(r.URL.Path)
+		switch r.Method {
+		case http.MethodGet:
+			disk, ok := disks[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"error": {"code": "ResourceNotFound", "message": "disk not found"}}`)
+				return
+			}
+			require.NoError(t, json.NewEncoder(w).Encode(disk))
+		case http.MethodPut:
+			disk := compute.Disk{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&disk))
+			require.True(t, strings.HasPrefix(name, layoutDiskPrefix+"-"))
+			require.Equal(t, "eastus", *disk.Location)
+			require.Equal(t, []string{"1"}, *disk.Zones)
+
+			require.Equal(t, groupPath+name, r.URL.Path)
+			disk.Name = to.StringPtr(name)
+			disks[r.URL.Path] = disk
+			// Attach the new disks to the new instance in creation order
+			lun := int32(len(vms.dataDisks["new"]))
+			vms.dataDisks["new"] = append(vms.dataDisks["new"], compute.DataDisk{
+				Name: to.StringPtr(name), Lun: &lun,
+				ManagedDisk: &compute.ManagedDiskParameters{ID: to.StringPtr(r.URL.Path)},
+			})
+			require.NoError(t, json.NewEncoder(w).Encode(disk))
+		}
+	}))
+	defer server.Close()
+
+	disksClient := compute.NewDisksClientWithBaseURI(server.URL, "subscription")
+	disksClient.PollingDelay = 0
+	ops := &azureOps{
+		instance:          "new",
+		resourceGroupName: "group",
+		disksClient:       &disksClient,
+		vmsClient:         vms,
+	}
+
+	specs, err := ops.GetStorageLayout("old")
+	require.NoError(t, err)
+	require.Equal(t, []cloudops.VolumeSpec{
+		{SizeInGiB: 128, Type: "Premium_LRS", IOPS: 500, Throughput: 100, Labels: map[string]string{"pool": "0"}},
+		{SizeInGiB: 256, Type: "PremiumV2_LRS", IOPS: 5000, Throughput: 200, Labels: map[string]string{"pool": "1"}},
+	}, specs)
+
+	vols, err := ops.ProvisionStorageLayout(specs, map[string]string{"node": "new"})
+	require.NoError(t, err)
+	require.Len(t, vols, 2)
+
+	newSpecs, err := ops.GetStorageLayout("new")
+	require.NoError(t, err)
+	for i := range specs {
+		specs[i].Labels["node"] = "new"
+	}
+	require.Equal(t, specs, newSpecs)
+}
+
+func TestGetVMDiskBudget(t *testing.T) {
+	const (
+		groupPath = "/subscriptions/subscription/resourceGroups/group/providers/Microsoft.Compute/disks/"
+		otherPath = "/subscriptions/other-subscription/resourceGroups/other-group/providers/Microsoft.Compute/disks/"
+	)
+	// ultra-2 is in another subscription and resource group than the client
+	disks := map[string]string{
+		groupPath + "ultra-1":   `{"name": "ultra-1", "sku": {"name": "UltraSSD_LRS"}, "properties": {"diskSizeGB": 100, "diskIOPSReadWrite": 3000}}`,
+		otherPath + "ultra-2":   `{"name": "ultra-2", "sku": {"name": "UltraSSD_LRS"}, "properties": {"diskSizeGB": 200, "diskIOPSReadWrite": 2000}}`,
+		groupPath + "premium-1": `{"name": "premium-1", "sku": {"name": "Premium_LRS"}, "properties": {"diskSizeGB": 100, "diskIOPSReadWrite": 500}}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/providers/Microsoft.Compute/skus") {
+			require.Equal(t, "location eq 'eastus'", r.URL.Query().Get("$filter"))
+			fmt.Fprint(w, `{"value": [
+				{"resourceType": "disks", "name": "Standard_E4s_v3"},
+				{"resourceType": "virtualMachines", "name": "Standard_D4s_v3", "capabilities": [
+					{"name": "UncachedDiskIOPS", "value": "6400"}]},
+				{"resourceType": "virtualMachines", "name": "Standard_E4s_v3", "capabilities": [
+					{"name": "vCPUs", "value": "4"},
+					{"name": "UncachedDiskIOPS", "value": "6400"},
+					{"name": "UncachedDiskBytesPerSecond", "value": "100663296"}]}]}`)
+			return
+		}
+		disk, ok := disks[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": {"code": "ResourceNotFound", "message": "disk not found"}}`)
+			return
+		}
+		fmt.Fprint(w, disk)
+	}))
+	defer server.Close()
+
+	disksClient := compute.NewDisksClientWithBaseURI(server.URL, "subscription")
+	resourceSkusClient := compute.NewResourceSkusClientWithBaseURI(server.URL, "subscription")
+	ops := &azureOps{
+		instance:          "instance",
+		resourceGroupName: "group",
+		disksClient:       &disksClient,
+		vmsClient: &layoutVMsClient{dataDisks: map[string][]compute.DataDisk{
+			"instance": {
+				{Name: to.StringPtr("ultra-1"), Lun: to.Int32Ptr(0),
+					ManagedDisk: &compute.ManagedDiskParameters{ID: to.StringPtr(groupPath + "ultra-1")}},
+				{Name: to.StringPtr("ultra-2"), Lun: to.Int32Ptr(1),
+					ManagedDisk: &compute.ManagedDiskParameters{ID: to.StringPtr(otherPath + "ultra-2")}},
+				{Name: to.StringPtr("premium-1"), Lun: to.Int32Ptr(2),
+					ManagedDisk: &compute.ManagedDiskParameters{ID: to.StringPtr(groupPath + "premium-1")}},
+			},
+		}},
+		resourceSkusClient: &resourceSkusClient,
+	}
+
+	iopsBudget, throughputBudget, used, err := ops.GetVMDiskBudget("instance")
+	require.NoError(t, err)
+	require.Equal(t, uint64(6400), iopsBudget)
+	require.Equal(t, uint64(96), throughputBudget)
+	require.Equal(t, uint64(5000), used)
+}
+
+func TestCreateWithDiskEncryptionSet(t *testing.T) {
+	var created compute.Disk
+	exists := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet {
+			if !exists {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"error": {"code": "ResourceNotFound", "message": "disk not found"}}`)
+				return
+			}
+			fmt.Fprint(w, `{"name": "disk1", "id": "/disks/disk1", "properties": {"diskSizeGB": 100}}`)
+			return
+		}
+		created = compute.Disk{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&created))
+		if strings.HasSuffix(*created.Encryption.DiskEncryptionSetID, "/missing") {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error": {"code": "InvalidParameter", "message": "diskEncryptionSet missing was not found"}}`)
+			return
+		}
+		exists = true
+		fmt.Fprint(w, `{"name": "disk1", "id": "/disks/disk1", "properties": {"diskSizeGB": 100}}`)
+	}))
+	defer server.Close()
+
+	disksClient := compute.NewDisksClientWithBaseURI(server.URL, "subscription")
+	ops := &azureOps{
+		instance:          "instance",
+		resourceGroupName: "group",
+		disksClient:       &disksClient,
+	}
+	template := func() *compute.Disk {
+		return &compute.Disk{
+			Name:           to.StringPtr("disk1"),
+			Sku:            &compute.DiskSku{Name: compute.PremiumLRS},
+			DiskProperties: &compute.DiskProperties{DiskSizeGB: to.Int32Ptr(100)},
+		}
+	}
+
+	const desID = "/subscriptions/subscription/resourceGroups/group/providers/Microsoft.Compute/diskEncryptionSets/des"
+	_, err := ops.Create(template(), nil, map[string]string{cloudops.KmsKeyIDOption: desID})
+	require.NoError(t, err)
+	require.NotNil(t, created.Encryption)
+	require.Equal(t, desID, *created.Encryption.DiskEncryptionSetID)
+	require.Equal(t, compute.EncryptionTypeEncryptionAtRestWithCustomerKey, created.Encryption.Type)
+
+	exists = false
+
+	_, err = ops.Create(template(), nil, map[string]string{cloudops.KmsKeyIDOption: path.Dir(desID) + "/missing"})
+	se, ok := err.(*cloudops.StorageError)
+	require.True(t, ok, "expected a storage error, got %v", err)
+	require.Equal(t, cloudops.ErrInvalidEncryptionKey, se.Code)
+}
+
+func TestBuildDecisionMatrix(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "location eq 'eastus'", r.URL.Query().Get("$filter"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"value": [
+			{"resourceType": "virtualMachines", "name": "Standard_D4s_v3", "capabilities": [
+				{"name": "UncachedDiskIOPS", "value": "6400"}]},
+			{"resourceType": "disks", "name": "Premium_LRS", "size": "P20", "capabilities": [
+				{"name": "MaxSizeGiB", "value": "512"},
+				{"name": "MinSizeGiB", "value": "256"},
+				{"name": "MaxIOps", "value": "2300"},
+				{"name": "MinIOps", "value": "2300"}]},
+			{"resourceType": "disks", "name": "Premium_LRS", "size": "P10", "capabilities": [
+				{"name": "MaxSizeGiB", "value": "128"},
+				{"name": "MinSizeGiB", "value": "64"},
+				{"name": "MaxIOps", "value": "500"},
+				{"name": "MinIOps", "value": "500"}]},
+			{"resourceType": "disks", "name": "UltraSSD_LRS", "size": "U1024", "capabilities": [
+				{"name": "MaxSizeGiB", "value": "1024"},
+				{"name": "MinSizeGiB", "value": "512"},
+				{"name": "MaxIOpsReadWrite", "value": "160000"},
+				{"name": "MinIOpsReadWrite", "value": "100"}]},
+			{"resourceType": "disks", "name": "Premium_ZRS", "size": "P10", "capabilities": [
+				{"name": "MaxSizeGiB", "value": "128"},
+				{"name": "MinSizeGiB", "value": "64"},
+				{"name": "MaxIOps", "value": "500"},
+				{"name": "MinIOps", "value": "500"}],
+			 "restrictions": [{"type": "Location", "values": ["eastus"]}]}]}`)
+	}))
+	defer server.Close()
+
+	resourceSkusClient := compute.NewResourceSkusClientWithBaseURI(server.URL, "subscription")
+	ops := &azureOps{resourceSkusClient: &resourceSkusClient}
+
+	dm, err := cloudops.BuildDecisionMatrix(ops, "eastus")
+	require.NoError(t, err)
+	require.NoError(t, dm.Validate())
+	row := func(driveType string, minSize, maxSize, minIOPS, maxIOPS uint64) cloudops.StorageDecisionMatrixRow {
+		return cloudops.StorageDecisionMatrixRow{
+			MinIOPS:           minIOPS,
+			MaxIOPS:           maxIOPS,
+			InstanceType:      "*",
+			InstanceMaxDrives: cloudops.DefaultInstanceMaxDrives,
+			InstanceMinDrives: cloudops.DefaultInstanceMinDrives,
+			Region:            "eastus",
+			MinSize:           minSize,
+			MaxSize:           maxSize,
+			Priority:          2,
+			DriveType:         driveType,
+		}
+	}
+	require.Equal(t, []cloudops.StorageDecisionMatrixRow{
+		row("Premium_LRS", 64, 128, 500, 500),
+		row("Premium_LRS", 256, 512, 2300, 2300),
+		row("UltraSSD_LRS", 512, 1024, 100, 160000),
+	}, dm.Rows)
+}
+
+func TestAttachWaitsForAttachedState(t *testing.T) {
+	devDir := t.TempDir()
+	device := path.Join(devDir, "sdc")
+	require.NoError(t, os.WriteFile(device, nil, 0644))
+	require.NoError(t, os.Symlink(device, path.Join(devDir, "lun0")))
+	origPrefix := azureDiskPrefix
+	azureDiskPrefix = path.Join(devDir, "lun")
+	defer func() { azureDiskPrefix = origPrefix }()
+
+	vms := &fakeVMsClient{}
+	statePolls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if len(vms.updates) == 0 {
+			fmt.Fprint(w, `{"name": "disk1", "id": "/disks/disk1", "properties": {"diskSizeGB": 10, "diskState": "Unattached"}}`)
+			return
+		}
+		// the disk is managed by the instance before it is attached
+		statePolls++
+		state := compute.Unattached
+		if statePolls > 2 {
+			state = compute.Attached
+		}
+		fmt.Fprintf(w, `{"name": "disk1", "id": "/disks/disk1", "managedBy": "/vms/instance", `+
+			`"properties": {"diskSizeGB": 10, "diskState": %q}}`, state)
+	}))
+	defer server.Close()
+
+	disksClient := compute.NewDisksClientWithBaseURI(server.URL, "subscription")
+	ops := &azureOps{
+		instance:          "instance",
+		resourceGroupName: "group",
+		disksClient:       &disksClient,
+		vmsClient:         vms,
+		opsTimeout: cloudops.OpsTimeoutConfig{
+			Timeout:       5 * time.Second,
+			RetryInterval: 10 * time.Millisecond,
+		},
+	}
+
+	devicePath, err := ops.Attach("disk1", nil)
+	require.NoError(t, err)
+	require.Equal(t, device, devicePath)
+	require.Equal(t, 3, statePolls)
+}
+
+func TestAttachByInstanceID(t *testing.T) {
+	vms := &fakeVMsClient{
+		dataDisks: []compute.DataDisk{
+			{Name: to.StringPtr("existing"), Lun: to.Int32Ptr(0)},
+		},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if len(vms.updates) == 0 {
+			fmt.Fprint(w, `{"name": "disk1", "id": "/disks/disk1", "properties": {"diskSizeGB": 10, "diskState": "Unattached"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"name": "disk1", "id": "/disks/disk1", "managedBy": "/vms/other", `+
+			`"properties": {"diskSizeGB": 10, "diskState": "Attached"}}`)
+	}))
+	defer server.Close()
+
+	disksClient := compute.NewDisksClientWithBaseURI(server.URL, "subscription")
+	ops := &azureOps{
+		instance:          "instance",
+		resourceGroupName: "group",
+		disksClient:       &disksClient,
+		vmsClient:         vms,
+		opsTimeout: cloudops.OpsTimeoutConfig{
+			Timeout:       5 * time.Second,
+			RetryInterval: 10 * time.Millisecond,
+		},
+	}
+
+	devicePath, err := ops.AttachByInstanceID("other", "disk1", nil)
+	require.NoError(t, err)
+	require.Equal(t, azureDiskPrefix+"1", devicePath)
+	require.Len(t, vms.updates, 1)
+	require.Len(t, vms.updates[0], 2)
+	require.Equal(t, "disk1", *vms.updates[0][1].Name)
+
+	// attaching again returns the existing LUN without updating the VM
+	devicePath, err = ops.AttachByInstanceID("other", "disk1", nil)
+	require.NoError(t, err)
+	require.Equal(t, azureDiskPrefix+"1", devicePath)
+	require.Len(t, vms.updates, 1)
+}
+
+// newTaggedDisksServer returns a server which serves count disks of the
+// resource group "group", of which the first matching disks are tagged with
+// app=db, through both the disks list API and the resource manager tag filter
+func newTaggedDisksServer(tb testing.TB, count, matching int) (*httptest.Server, *int) {
+	disks := make(map[string][]byte, count)
+	var all, tagged []string
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("disk%d", i)
+		tags := `{"tier": "gold"}`
+		if i < matching {
+			tags = `{"app": "db", "tier": "gold"}`
+			if i == 0 {
+				tags = `{"app": "db", "tier": "silver"}`
+			}
+			tagged = append(tagged, fmt.Sprintf(`{"name": %q, "type": "Microsoft.Compute/disks"}`, name))
+		}
+		disks[name] = []byte(fmt.Sprintf(`{"name": %q, "id": "/disks/%s", "tags": %s, "properties": {"diskSizeGB": 10}}`,
+			name, name, tags))
+		all = append(all, string(disks[name]))
+	}
+	// resources other than disks may carry the tag too
+	tagged = append(tagged, `{"name": "nic0", "type": "Microsoft.Network/networkInterfaces"}`)
+	list := []byte(`{"value": [` + strings.Join(all, ",") + `]}`)
+
+	listed := 0
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		const disksPath = "/subscriptions/subscription/resourceGroups/group/providers/Microsoft.Compute/disks"
+		switch {
+		case r.URL.Path == "/subscriptions/subscription/resourceGroups/group/resources":
+			if r.URL.Query().Get("page") == "2" {
+				fmt.Fprintf(w, `{"value": [%s]}`, strings.Join(tagged[len(tagged)/2:], ","))
+				return
+			}
+			require.Equal(tb, "tagName eq 'app' and tagValue eq 'db'", r.URL.Query().Get("$filter"))
+			fmt.Fprintf(w, `{"value": [%s], "nextLink": "%s%s?page=2"}`,
+				strings.Join(tagged[:len(tagged)/2], ","), server.URL, r.URL.Path)
+		case r.URL.Path == disksPath:
+			listed++
+			_, _ = w.Write(list)
+		case strings.HasPrefix(r.URL.Path, disksPath+"/"):
+			disk, ok := disks[strings.TrimPrefix(r.URL.Path, disksPath+"/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"error": {"code": "ResourceNotFound", "message": "disk not found"}}`)
+				return
+			}
+			_, _ = w.Write(disk)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server, &listed
+}
+
+func newTaggedDisksOps(server *httptest.Server) *azureOps {
+	disksClient := compute.NewDisksClientWithBaseURI(server.URL, "subscription")
+	return &azureOps{
+		instance:          "instance",
+		resourceGroupName: "group",
+		disksClient:       &disksClient,
+	}
+}
+
+func TestTagFilter(t *testing.T) {
+	filter, ok := tagFilter(map[string]string{"tier": "gold", "app": "db"})
+	require.True(t, ok)
+	require.Equal(t, "tagName eq 'app' and tagValue eq 'db'", filter)
+
+	filter, ok = tagFilter(map[string]string{"owner": "o'brien"})
+	require.True(t, ok)
+	require.Equal(t, "tagName eq 'owner' and tagValue eq 'o''brien'", filter)
+
+	_, ok = tagFilter(map[string]string{"app": "", "a/b": "c"})
+	require.False(t, ok)
+
+	_, ok = tagFilter(nil)
+	require.False(t, ok)
+}
+
+func TestEnumerateFiltersTagsServerSide(t *testing.T) {
+	server, listed := newTaggedDisksServer(t, 20, 4)
+	defer server.Close()
+	ops := newTaggedDisksOps(server)
+
+	sets, err := ops.Enumerate(nil, map[string]string{"app": "db", "tier": "gold"}, "")
+	require.NoError(t, err)
+	require.Zero(t, *listed, "the disks of the resource group should not be listed")
+	var names []string
+	for _, disk := range sets[cloudops.SetIdentifierNone] {
+		names = append(names, *disk.(*compute.Disk).Name)
+	}
+	// disk0 has the filtered tag but not the tag matched client-side
+	require.ElementsMatch(t, []string{"disk1", "disk2", "disk3"}, names)
+
+	// labels which can't be filtered on fall back to listing every disk
+	sets, err = ops.Enumerate(nil, map[string]string{"tier": ""}, "")
+	require.NoError(t, err)
+	require.Equal(t, 1, *listed)
+	require.Empty(t, sets)
+}
+
+func BenchmarkEnumerateByLabel(b *testing.B) {
+	server, _ := newTaggedDisksServer(b, 2000, 10)
+	defer server.Close()
+	ops := newTaggedDisksOps(server)
+
+	labels := map[string]string{"app": "db", "tier": "gold"}
+
+	// the app tag is filtered on by the resource manager
+	b.Run("server-side", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, err := ops.Enumerate(nil, labels, "")
+			require.NoError(b, err)
+		}
+	})
+	// every disk of the resource group is listed and matched client-side
+	b.Run("client-side", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var matched []*compute.Disk
+			err := ops.forEachDisk(nil, func(disk *compute.Disk) error {
+				if labelsMatch(disk, labels) {
+					matched = append(matched, disk)
+				}
+				return nil
+			})
+			require.NoError(b, err)
+		}
+	})
+}
+
+func TestCustomDiskPrefix(t *testing.T) {
+	devDir := t.TempDir()
+	device := path.Join(devDir, "sdd")
+	require.NoError(t, os.WriteFile(device, nil, 0644))
+	require.NoError(t, os.Symlink(device, path.Join(devDir, "scsi-lun2")))
+
+	ops := &azureOps{}
+	require.Equal(t, "/dev/disk/azure/scsi1/lun2", ops.lunPath(2))
+
+	ops.diskPrefix = path.Join(devDir, "scsi-lun")
+	require.Equal(t, path.Join(devDir, "scsi-lun2"), ops.lunPath(2))
+	devPath, err := ops.lunToBlockDevPath(2)
+	require.NoError(t, err)
+	require.Equal(t, device, devPath)
+}
+
+func TestAreVolumesReadyToExpand(t *testing.T) {
+	states := map[string]string{
+		"disk1": "Succeeded",
+		"disk2": "Updating",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Base(r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"name": "%s", "properties": {"diskSizeGB": 10, "provisioningState": "%s"}}`,
+			name, states[name])
+	}))
+	defer server.Close()
+
+	disksClient := compute.NewDisksClientWithBaseURI(server.URL, "subscription")
+	ops := &azureOps{
+		instance:          "instance",
+		resourceGroupName: "group",
+		disksClient:       &disksClient,
+	}
+
+	ready, err := ops.AreVolumesReadyToExpand([]*string{to.StringPtr("disk1")})
+	require.NoError(t, err)
+	require.True(t, ready)
+
+	ready, err = ops.AreVolumesReadyToExpand([]*string{to.StringPtr("disk1"), to.StringPtr("disk2")})
+	require.False(t, ready)
+	notReady, ok := err.(*cloudops.ErrVolumesNotReadyToExpand)
+	require.True(t, ok, "expected ErrVolumesNotReadyToExpand, got %v", err)
+	require.Equal(t, map[string]string{"disk2": "disk is in Updating provisioning state"}, notReady.Volumes)
+}
+
+func TestWaitForVolumeState(t *testing.T) {
+	states := map[string][]string{
+		"disk1":  {`"provisioningState": "Creating"`, `"provisioningState": "Succeeded", "diskState": "Attached"`},
+		"failed": {`"provisioningState": "Failed"`},
+	}
+	gets := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Base(r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		sequence, ok := states[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": {"code": "ResourceNotFound", "message": "disk not found"}}`)
+			return
+		}
+		i := gets[name]
+		if i >= len(sequence) {
+			i = len(sequence) - 1
+		}
+		gets[name]++
+		fmt.Fprintf(w, `{"name": "%s", "properties": {"diskSizeGB": 10, %s}}`, name, sequence[i])
+	}))
+	defer server.Close()
+
+	disksClient := compute.NewDisksClientWithBaseURI(server.URL, "subscription")
+	ops := &azureOps{
+		instance:          "instance",
+		resourceGroupName: "group",
+		disksClient:       &disksClient,
+		vmsClient:         &fakeVMsClient{},
+		opsTimeout:        cloudops.OpsTimeoutConfig{RetryInterval: time.Millisecond},
+	}
+
+	require.NoError(t, ops.WaitForVolumeState("disk1", cloudops.VolumeStateAttached, time.Second))
+	require.Equal(t, 2, gets["disk1"])
+
+	require.Error(t, ops.WaitForVolumeState("failed", cloudops.VolumeStateAvailable, time.Second))
+	require.Equal(t, 1, gets["failed"])
+
+	err := ops.WaitForVolumeState("missing", cloudops.VolumeStateAvailable, time.Second)
+	se, ok := err.(*cloudops.StorageError)
+	require.True(t, ok, "expected a storage error, got %v", err)
+	require.Equal(t, cloudops.ErrVolNotFound, se.Code)
+}
+
+func TestCheckVolumeQuota(t *testing.T) {
+	usageLists := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.True(t, strings.HasSuffix(r.URL.Path, "/providers/Microsoft.Compute/locations/eastus/usages"),
+			"unexpected request %s", r.URL.Path)
+		usageLists++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"value": [
+			{"unit": "Count", "currentValue": 10, "limit": 10, "name": {"value": "cores"}},
+			{"unit": "Count", "currentValue": 40, "limit": 50, "name": {"value": "PremiumDiskCount"}},
+			{"unit": "Count", "currentValue": 5, "limit": 50, "name": {"value": "StandardSSDDiskCount"}}]}`)
+	}))
+	defer server.Close()
+
+	usageClient := compute.NewUsageClientWithBaseURI(server.URL, "subscription")
+	ops := &azureOps{
+		instance:          "instance",
+		resourceGroupName: "group",
+		vmsClient:         &layoutVMsClient{},
+		usageClient:       &usageClient,
+	}
+
+	err := ops.CheckVolumeQuota([]*cloudops.StoragePoolSpec{
+		{DriveType: string(compute.PremiumLRS), DriveCapacityGiB: 1024, DriveCount: 2, InstancesPerZone: 3},
+		{DriveType: string(compute.StandardSSDLRS), DriveCapacityGiB: 1024, DriveCount: 4, InstancesPerZone: 3},
+	})
+	require.NoError(t, err)
+
+	// the same pools in 3 zones need 3 times the disks
+	err = ops.CheckVolumeQuota([]*cloudops.StoragePoolSpec{
+		{DriveType: string(compute.PremiumLRS), DriveCapacityGiB: 1024, DriveCount: 2, InstancesPerZone: 3, ZoneCount: 3},
+		{DriveType: string(compute.StandardSSDLRS), DriveCapacityGiB: 1024, DriveCount: 4, InstancesPerZone: 3, ZoneCount: 3},
+	})
+	require.Error(t, err)
+	quotaErr, ok := err.(*cloudops.ErrVolumeQuotaExceeded)
+	require.True(t, ok, "expected a quota error, got: %v", err)
+	require.Equal(t, []cloudops.QuotaBreach{
+		{Quota: "PremiumDiskCount", Limit: 50, Usage: 40, Required: 18},
+	}, quotaErr.Quotas)
+
+	// LRS and ZRS premium disks count against the same quota
+	err = ops.CheckVolumeQuota([]*cloudops.StoragePoolSpec{
+		{DriveType: string(compute.PremiumLRS), DriveCapacityGiB: 1024, DriveCount: 2, InstancesPerZone: 3},
+		{DriveType: string(compute.PremiumZRS), DriveCapacityGiB: 1024, DriveCount: 2, InstancesPerZone: 3},
+		{DriveType: string(compute.StandardSSDLRS), DriveCapacityGiB: 1024, DriveCount: 4, InstancesPerZone: 3},
+	})
+	require.Error(t, err)
+	quotaErr, ok = err.(*cloudops.ErrVolumeQuotaExceeded)
+	require.True(t, ok, "expected a quota error, got: %v", err)
+	require.Equal(t, []cloudops.QuotaBreach{
+		{Quota: "PremiumDiskCount", Limit: 50, Usage: 40, Required: 12},
+	}, quotaErr.Quotas)
+
+	// SKUs without a known quota are not checked
+	usageLists = 0
+	err = ops.CheckVolumeQuota([]*cloudops.StoragePoolSpec{
+		{DriveType: "Unknown_LRS", DriveCapacityGiB: 1024, DriveCount: 100, InstancesPerZone: 3, ZoneCount: 3},
+	})
+	require.NoError(t, err)
+	require.Zero(t, usageLists)
+}
+
+func TestListVolumesPaged(t *testing.T) {
+	var server *httptest.Server
+	pages := 0
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pages++
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/page2" {
+			fmt.Fprint(w, `{"value": [
+				{"name": "disk3", "tags": {"app": "db"}}
+			]}`)
+			return
+		}
+		require.Equal(t, "/subscriptions/subscription/resourceGroups/group/providers/Microsoft.Compute/disks", r.URL.Path)
+		fmt.Fprintf(w, `{"value": [
+			{"name": "disk1", "tags": {"app": "db"}},
+			{"name": "disk2", "tags": {"app": "web"}}
+		], "nextLink": "%s/page2"}`, server.URL)
+	}))
+	defer server.Close()
+
+	disksClient := compute.NewDisksClientWithBaseURI(server.URL, "subscription")
+	ops := &azureOps{
+		resourceGroupName: "group",
+		disksClient:       &disksClient,
+	}
+
+	it, err := ops.ListVolumesPaged(map[string]string{"app": "db"}, 10)
+	require.NoError(t, err)
+	require.Zero(t, pages, "pages should only be fetched when iterated")
+
+	disk, ok := it.Next()
+	require.True(t, ok)
+	require.Equal(t, "disk1", *disk.(*compute.Disk).Name)
+	require.Equal(t, 1, pages)
+
+	disk, ok = it.Next()
+	require.True(t, ok)
+	require.Equal(t, "disk3", *disk.(*compute.Disk).Name)
+	require.Equal(t, 2, pages)
+
+	_, ok = it.Next()
+	require.False(t, ok)
+	require.NoError(t, it.Err())
+	require.Equal(t, 2, pages)
+}
@@ -19,11 +19,13 @@ func newBaseVMsClient(
 	config Config,
 	baseURI string,
 	authorizer autorest.Authorizer,
+	inspector autorest.RespondDecorator,
 ) vmsClient {
 	vmsClient := compute.NewVirtualMachinesClientWithBaseURI(baseURI, config.SubscriptionID)
 	vmsClient.Authorizer = authorizer
 	vmsClient.PollingDelay = clientPollingDelay
 	vmsClient.AddToUserAgent(config.UserAgent)
+	vmsClient.ResponseInspector = inspector
 	return &baseVMsClient{
 		resourceGroupName: config.ResourceGroupName,
 		client:            &vmsClient,
@@ -0,0 +1,208 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2022-08-01/compute"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/libopenstorage/cloudops"
+)
+
+const (
+	// resourcesAPIVersion is the version of the resource manager API used to
+	// list the resources of a resource group by tag
+	resourcesAPIVersion = "2021-04-01"
+	// diskResourceType is the resource manager type of managed disks
+	diskResourceType = "Microsoft.Compute/disks"
+	// unfilterableTagChars are the characters the resource manager does not
+	// allow in tag names, tags with these can only be matched client-side
+	unfilterableTagChars = `<>%&\?/`
+)
+
+// genericResource is a resource listed by the resource manager API
+type genericResource struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// resourceListResult is a page of resources listed by the resource manager API
+type resourceListResult struct {
+	Value    []genericResource `json:"value"`
+	NextLink string            `json:"nextLink"`
+}
+
+// tagFilter returns the resource manager $filter selecting the resources
+// tagged with one of the given labels, or false if none of the labels can be
+// filtered on server-side. The resource manager filters on a single tag, the
+// rest of the labels must be matched client-side.
+func tagFilter(labels map[string]string) (string, bool) {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := labels[key]
+		// labelsMatch matches a nil tag value with an empty label, which the
+		// resource manager can't express
+		if len(value) == 0 || strings.ContainsAny(key, unfilterableTagChars) {
+			continue
+		}
+		return fmt.Sprintf("tagName eq '%s' and tagValue eq '%s'",
+			strings.ReplaceAll(key, "'", "''"), strings.ReplaceAll(value, "'", "''")), true
+	}
+	return "", false
+}
+
+// forEachDisk calls fn with each disk of the resource group which has the given
+// labels. If one of the labels can be filtered on by the resource manager, only
+// the disks tagged with it are fetched. Disks are passed to fn as they are
+// listed rather than accumulated.
+func (a *azureOps) forEachDisk(labels map[string]string, fn func(disk *compute.Disk) error) error {
+	if filter, ok := tagFilter(labels); ok {
+		return a.forEachTaggedDisk(filter, labels, fn)
+	}
+
+	ctx := context.Background()
+	it, err := a.disksClient.ListByResourceGroupComplete(ctx, a.resourceGroupName)
+	if err != nil {
+		return err
+	}
+	for ; it.NotDone(); err = it.NextWithContext(ctx) {
+		if err != nil {
+			return err
+		}
+
+		disk := it.Value()
+		if !labelsMatch(&disk, labels) {
+			continue
+		}
+		if err := fn(&disk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forEachTaggedDisk calls fn with each disk selected by the given resource
+// manager filter which has the given labels
+func (a *azureOps) forEachTaggedDisk(
+	filter string,
+	labels map[string]string,
+	fn func(disk *compute.Disk) error,
+) error {
+	ctx := context.Background()
+	page, err := a.listResources(ctx, filter, "")
+	for {
+		if err != nil {
+			return err
+		}
+
+		for _, resource := range page.Value {
+			if !strings.EqualFold(resource.Type, diskResourceType) {
+				continue
+			}
+			disk, err := a.disksClient.Get(ctx, a.resourceGroupName, resource.Name)
+			if isNotFoundError(err) {
+				// deleted since it was listed
+				continue
+			} else if err != nil {
+				return err
+			}
+			if !labelsMatch(&disk, labels) {
+				continue
+			}
+			if err := fn(&disk); err != nil {
+				return err
+			}
+		}
+
+		if len(page.NextLink) == 0 {
+			return nil
+		}
+		page, err = a.listResources(ctx, "", page.NextLink)
+	}
+}
+
+// listResources returns the first page of the resources of the resource group
+// selected by the given filter, or the page at nextLink if it is set
+func (a *azureOps) listResources(ctx context.Context, filter, nextLink string) (*resourceListResult, error) {
+	var decorators []autorest.PrepareDecorator
+	if len(nextLink) != 0 {
+		decorators = []autorest.PrepareDecorator{
+			autorest.AsGet(),
+			autorest.WithBaseURL(nextLink),
+		}
+	} else {
+		pathParameters := map[string]interface{}{
+			"resourceGroupName": autorest.Encode("path", a.resourceGroupName),
+			"subscriptionId":    autorest.Encode("path", a.disksClient.SubscriptionID),
+		}
+		queryParameters := map[string]interface{}{
+			"api-version": resourcesAPIVersion,
+			"$filter":     autorest.Encode("query", filter),
+		}
+		decorators = []autorest.PrepareDecorator{
+			autorest.AsGet(),
+			autorest.WithBaseURL(a.disksClient.BaseURI),
+			autorest.WithPathParameters("/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/resources", pathParameters),
+			autorest.WithQueryParameters(queryParameters),
+		}
+	}
+
+	req, err := autorest.CreatePreparer(decorators...).Prepare((&http.Request{}).WithContext(ctx))
+	if err != nil {
+		return nil, autorest.NewErrorWithError(err, "azure", "listResources", nil, "Failure preparing request")
+	}
+	resp, err := a.disksClient.Send(req, azure.DoRetryWithRegistration(a.disksClient.Client))
+	if err != nil {
+		return nil, autorest.NewErrorWithError(err, "azure", "listResources", resp, "Failure sending request")
+	}
+
+	result := &resourceListResult{}
+	if err := autorest.Respond(
+		resp,
+		azure.WithErrorUnlessStatusCode(http.StatusOK),
+		autorest.ByUnmarshallingJSON(result),
+		autorest.ByClosing(),
+	); err != nil {
+		return nil, autorest.NewErrorWithError(err, "azure", "listResources", resp, "Failure responding to request")
+	}
+	return result, nil
+}
+
+// ListVolumesPaged returns an iterator over the disks of the resource group
+// which have the given labels, fetching a page of the disks list at a time. The
+// disks list API has no page size, so pageSize is ignored, and the labels are
+// matched client-side.
+func (a *azureOps) ListVolumesPaged(labels map[string]string, pageSize int) (cloudops.VolumeIterator, error) {
+	ctx := context.Background()
+	var page *compute.DiskListPage
+	return cloudops.NewVolumeIterator(func() ([]interface{}, bool, error) {
+		if page == nil {
+			first, err := a.disksClient.ListByResourceGroup(ctx, a.resourceGroupName)
+			if err != nil {
+				return nil, false, err
+			}
+			page = &first
+		} else if err := page.NextWithContext(ctx); err != nil {
+			return nil, false, err
+		}
+
+		var disks []interface{}
+		for _, disk := range page.Values() {
+			disk := disk
+			if labelsMatch(&disk, labels) {
+				disks = append(disks, &disk)
+			}
+		}
+		next := page.Response().NextLink
+		return disks, next != nil && len(*next) != 0, nil
+	}), nil
+}
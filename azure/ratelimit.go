@@ -0,0 +1,74 @@
+package azure
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Azure/go-autorest/autorest"
+)
+
+// rateLimitHeaderPrefix is the prefix of the headers in which Azure reports the
+// remaining request budget, e.g. x-ms-ratelimit-remaining-subscription-reads.
+// Resource provider budgets are reported as a list of policy;count pairs in
+// x-ms-ratelimit-remaining-resource.
+const rateLimitHeaderPrefix = "X-Ms-Ratelimit-Remaining-"
+
+// rateLimitTracker records the smallest remaining request budget reported in
+// the rate limit headers of the latest response
+type rateLimitTracker struct {
+	sync.Mutex
+	budget int64
+	seen   bool
+}
+
+// inspector returns a response inspector for the autorest clients which records
+// the remaining request budget of each response
+func (r *rateLimitTracker) inspector() autorest.RespondDecorator {
+	return func(responder autorest.Responder) autorest.Responder {
+		return autorest.ResponderFunc(func(resp *http.Response) error {
+			if resp != nil {
+				if budget, ok := remainingRequests(resp.Header); ok {
+					r.Lock()
+					r.budget, r.seen = budget, true
+					r.Unlock()
+				}
+			}
+			return responder.Respond(resp)
+		})
+	}
+}
+
+func (r *rateLimitTracker) remaining() (int64, bool) {
+	r.Lock()
+	defer r.Unlock()
+	return r.budget, r.seen
+}
+
+// remainingRequests returns the smallest remaining request budget found in the
+// rate limit headers
+func remainingRequests(header http.Header) (int64, bool) {
+	var (
+		budget int64
+		found  bool
+	)
+	for key, values := range header {
+		if !strings.HasPrefix(http.CanonicalHeaderKey(key), rateLimitHeaderPrefix) {
+			continue
+		}
+		for _, value := range values {
+			for _, policy := range strings.Split(value, ",") {
+				count := policy[strings.LastIndex(policy, ";")+1:]
+				remaining, err := strconv.ParseInt(strings.TrimSpace(count), 10, 64)
+				if err != nil {
+					continue
+				}
+				if !found || remaining < budget {
+					budget, found = remaining, true
+				}
+			}
+		}
+	}
+	return budget, found
+}
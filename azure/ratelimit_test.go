@@ -0,0 +1,59 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2022-08-01/compute"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemainingRequests(t *testing.T) {
+	_, ok := remainingRequests(http.Header{"Content-Type": {"application/json"}})
+	require.False(t, ok)
+
+	header := http.Header{}
+	header.Set("x-ms-ratelimit-remaining-subscription-reads", "11999")
+	header.Set("x-ms-ratelimit-remaining-resource",
+		"Microsoft.Compute/GetDisk3Min;249,Microsoft.Compute/GetDisk30Min;1999")
+	remaining, ok := remainingRequests(header)
+	require.True(t, ok)
+	require.Equal(t, int64(249), remaining)
+}
+
+func TestRateLimitTracker(t *testing.T) {
+	budgets := []int64{200, 100, 10}
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("x-ms-ratelimit-remaining-resource",
+			fmt.Sprintf("Microsoft.Compute/GetDisk3Min;%d,Microsoft.Compute/GetDisk30Min;1999", budgets[calls]))
+		calls++
+		fmt.Fprint(w, `{"name": "disk1"}`)
+	}))
+	defer server.Close()
+
+	rateLimits := &rateLimitTracker{}
+	disksClient := compute.NewDisksClientWithBaseURI(server.URL, "subscription")
+	disksClient.ResponseInspector = rateLimits.inspector()
+	ops := &azureOps{
+		resourceGroupName: "group",
+		disksClient:       &disksClient,
+		rateLimits:        rateLimits,
+	}
+
+	_, ok := ops.RemainingRequests()
+	require.False(t, ok)
+
+	for _, budget := range budgets {
+		_, err := ops.disksClient.Get(context.Background(), ops.resourceGroupName, "disk1")
+		require.NoError(t, err)
+
+		remaining, ok := ops.RemainingRequests()
+		require.True(t, ok)
+		require.Equal(t, budget, remaining)
+	}
+}
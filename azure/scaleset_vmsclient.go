@@ -17,11 +17,13 @@ func newScaleSetVMsClient(
 	config Config,
 	baseURI string,
 	authorizer autorest.Authorizer,
+	inspector autorest.RespondDecorator,
 ) vmsClient {
 	vmsClient := compute.NewVirtualMachineScaleSetVMsClientWithBaseURI(baseURI, config.SubscriptionID)
 	vmsClient.Authorizer = authorizer
 	vmsClient.PollingDelay = clientPollingDelay
 	vmsClient.AddToUserAgent(config.UserAgent)
+	vmsClient.ResponseInspector = inspector
 	return &scaleSetVMsClient{
 		scaleSetName:      config.ScaleSetName,
 		resourceGroupName: config.ResourceGroupName,
@@ -26,13 +26,14 @@ func NewAzureStorageManager(
 func (a *azureStorageManager) GetStorageDistribution(
 	request *cloudops.StorageDistributionRequest,
 ) (*cloudops.StorageDistributionResponse, error) {
+	decisionMatrix := storagedistribution.FilterForRequest(a.decisionMatrix, request)
 	response := &cloudops.StorageDistributionResponse{}
-	for _, userRequest := range request.UserStorageSpec {
+	for _, userRequest := range storagedistribution.UserStorageSpecs(request) {
 		// for request, find how many instances per zone needs to have storage
 		// and the storage spec for each of them
 		instStorage, instancePerZone, row, err :=
 			storagedistribution.GetStorageDistributionForPool(
-				a.decisionMatrix,
+				decisionMatrix,
 				userRequest,
 				request.InstancesPerZone,
 				request.ZoneCount,
@@ -48,13 +49,28 @@ func (a *azureStorageManager) GetStorageDistribution(
 				InstancesPerZone: instancePerZone,
 				DriveCount:       instStorage.DriveCount,
 				IOPS:             determineIOPSForPool(instStorage, row, userRequest.IOPS),
+				ThinProvisioning: instStorage.ThinProvisioning,
 			},
 		)
+		if request.IncludeDecisionMatrixRows {
+			response.DecisionMatrixRows = append(response.DecisionMatrixRows, row)
+		}
 
 	}
 	return response, nil
 }
 
+func (a *azureStorageManager) GetStorageDistributionWithExplanation(
+	request *cloudops.StorageDistributionRequest,
+) (*cloudops.StorageDistributionResponse, []cloudops.CandidateEvaluation, error) {
+	evaluations, err := storagedistribution.ExplainStorageDistribution(a.decisionMatrix, request)
+	if err != nil {
+		return nil, evaluations, err
+	}
+	response, err := a.GetStorageDistribution(request)
+	return response, evaluations, err
+}
+
 func (a *azureStorageManager) RecommendStoragePoolUpdate(
 	request *cloudops.StoragePoolUpdateRequest) (*cloudops.StoragePoolUpdateResponse, error) {
 	resp, row, err := storagedistribution.GetStorageUpdateConfig(request, a.decisionMatrix)
@@ -74,7 +90,14 @@ func (a *azureStorageManager) GetMaxDriveSize(
 	return resp, err
 }
 
+func (a *azureStorageManager) ValidateStorageSpec(spec *cloudops.StorageSpec) error {
+	return storagedistribution.ValidateStorageSpec(spec, a.decisionMatrix)
+}
+
 func determineIOPSForPool(instStorage *cloudops.StoragePoolSpec, row *cloudops.StorageDecisionMatrixRow, currentIOPS uint64) uint64 {
+	if iops, ok := storagedistribution.DetermineIOPSForPool(instStorage, row); ok {
+		return iops
+	}
 	if instStorage.DriveType == string(compute.UltraSSDLRS) || instStorage.DriveType == string(compute.PremiumV2LRS) {
 		// ultra SSD LRS and Premium v2 LRS IOPS are independent of the drive size and is a configurable parameter.
 		return currentIOPS
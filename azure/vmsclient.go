@@ -21,9 +21,10 @@ func newVMsClient(
 	config Config,
 	baseURI string,
 	authorizer autorest.Authorizer,
+	inspector autorest.RespondDecorator,
 ) vmsClient {
 	if config.ScaleSetName == "" {
-		return newBaseVMsClient(config, baseURI, authorizer)
+		return newBaseVMsClient(config, baseURI, authorizer, inspector)
 	}
-	return newScaleSetVMsClient(config, baseURI, authorizer)
+	return newScaleSetVMsClient(config, baseURI, authorizer, inspector)
 }
@@ -2,6 +2,7 @@ package backoff
 
 import (
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/libopenstorage/cloudops"
@@ -32,7 +33,133 @@ func NewExponentialBackoffOps(
 	errorCheck ExponentialBackoffErrorCheck,
 	backoff wait.Backoff,
 ) cloudops.Ops {
-	return &exponentialBackoff{cloudOps, errorCheck, backoff}
+	return &exponentialBackoff{
+		cloudOps:           cloudOps,
+		isExponentialError: errorCheck,
+		backoff:            backoff,
+	}
+}
+
+// RateLimitReporter is implemented by cloud providers which surface the
+// remaining request budget returned in the rate limit headers of the cloud API
+// responses.
+type RateLimitReporter interface {
+	// RemainingRequests returns the remaining request budget reported by the
+	// latest API responses, or false if no rate limit headers were seen.
+	RemainingRequests() (int64, bool)
+}
+
+// NewAdaptiveExponentialBackoffOps returns the same wrapper as
+// NewExponentialBackoffOps with adaptive backoff enabled. If cloudOps is a
+// RateLimitReporter, the intervals between retries are lengthened in
+// proportion to how far the remaining request budget has fallen below
+// rateLimitThreshold, slowing down the retries before the provider starts
+// throttling every request.
+func NewAdaptiveExponentialBackoffOps(
+	cloudOps cloudops.Ops,
+	errorCheck ExponentialBackoffErrorCheck,
+	backoff wait.Backoff,
+	rateLimitThreshold int64,
+) cloudops.Ops {
+	return &exponentialBackoff{
+		cloudOps:           cloudOps,
+		isExponentialError: errorCheck,
+		backoff:            backoff,
+		rateLimitThreshold: rateLimitThreshold,
+	}
+}
+
+// Config configures the retries of NewExponentialBackoffOpsWithConfig.
+type Config struct {
+	// InitialInterval is the interval before the first retry.
+	InitialInterval time.Duration
+	// MaxInterval caps the interval between two retries. The interval is not
+	// capped if it is not set.
+	MaxInterval time.Duration
+	// Multiplier is the factor the interval is multiplied by after each retry.
+	Multiplier float64
+	// Jitter adds a random amount of up to Jitter times the interval to
+	// each interval.
+	Jitter float64
+	// MaxElapsedTime is the time after which no more retries are made.
+	MaxElapsedTime time.Duration
+}
+
+// Validate returns an error if the config would not make the retries back
+// off or end.
+func (c Config) Validate() error {
+	if c.InitialInterval <= 0 {
+		return fmt.Errorf("initial interval must be positive, got %v", c.InitialInterval)
+	}
+	if c.MaxInterval < 0 {
+		return fmt.Errorf("max interval must not be negative, got %v", c.MaxInterval)
+	}
+	if c.MaxInterval > 0 && c.MaxInterval < c.InitialInterval {
+		return fmt.Errorf("max interval %v is shorter than the initial interval %v",
+			c.MaxInterval, c.InitialInterval)
+	}
+	if c.Multiplier < 1 {
+		return fmt.Errorf("multiplier must be at least 1, got %v", c.Multiplier)
+	}
+	if c.Jitter < 0 {
+		return fmt.Errorf("jitter must not be negative, got %v", c.Jitter)
+	}
+	if c.MaxElapsedTime <= 0 {
+		return fmt.Errorf("max elapsed time must be positive, got %v", c.MaxElapsedTime)
+	}
+	return nil
+}
+
+// NewExponentialBackoffOpsWithConfig returns the same wrapper as
+// NewExponentialBackoffOps with the retries configured by config instead of
+// a wait.Backoff. Retries stop once the next one would start after
+// config.MaxElapsedTime.
+func NewExponentialBackoffOpsWithConfig(
+	cloudOps cloudops.Ops,
+	errorCheck ExponentialBackoffErrorCheck,
+	config Config,
+) (cloudops.Ops, error) {
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid backoff config: %v", err)
+	}
+	return &exponentialBackoff{
+		cloudOps:           cloudOps,
+		isExponentialError: errorCheck,
+		backoff: wait.Backoff{
+			Duration: config.InitialInterval,
+			Factor:   config.Multiplier,
+			Jitter:   config.Jitter,
+			Steps:    math.MaxInt32,
+		},
+		maxInterval:    config.MaxInterval,
+		maxElapsedTime: config.MaxElapsedTime,
+	}, nil
+}
+
+// NewProviderExponentialBackoffOps returns the wrapper the cloud providers
+// wrap their clients in. The retries are configured by config, or by
+// DefaultExponentialBackoff if config is nil. If rateLimitThreshold is set, the
+// retries are adaptive as for NewAdaptiveExponentialBackoffOps.
+func NewProviderExponentialBackoffOps(
+	cloudOps cloudops.Ops,
+	errorCheck ExponentialBackoffErrorCheck,
+	config *Config,
+	rateLimitThreshold int64,
+) (cloudops.Ops, error) {
+	if config == nil {
+		return NewAdaptiveExponentialBackoffOps(
+			cloudOps,
+			errorCheck,
+			DefaultExponentialBackoff,
+			rateLimitThreshold,
+		), nil
+	}
+	ops, err := NewExponentialBackoffOpsWithConfig(cloudOps, errorCheck, *config)
+	if err != nil {
+		return nil, err
+	}
+	ops.(*exponentialBackoff).rateLimitThreshold = rateLimitThreshold
+	return ops, nil
 }
 
 // DefaultExponentialBackoff is the default backoff strategy that is used for doing
@@ -48,6 +175,14 @@ type exponentialBackoff struct {
 	cloudOps           cloudops.Ops
 	isExponentialError ExponentialBackoffErrorCheck
 	backoff            wait.Backoff
+	// rateLimitThreshold is the remaining request budget below which the
+	// intervals between retries are lengthened. Adaptive backoff is disabled
+	// if it is not set.
+	rateLimitThreshold int64
+	// maxInterval caps the intervals between retries if it is set
+	maxInterval time.Duration
+	// maxElapsedTime bounds the time spent retrying if it is set
+	maxElapsedTime time.Duration
 }
 
 func (e *exponentialBackoff) InstanceID() string {
@@ -64,7 +199,7 @@ func (e *exponentialBackoff) InspectInstance(instanceID string) (*cloudops.Insta
 		msg := fmt.Sprintf("Failed to inspect instance: %v.", instanceID)
 		return e.handleError(origErr, msg)
 	}
-	expErr := wait.ExponentialBackoff(e.backoff, conditionFn)
+	expErr := e.retry(conditionFn)
 	if expErr == wait.ErrWaitTimeout {
 		return nil, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
 	}
@@ -72,6 +207,23 @@ func (e *exponentialBackoff) InspectInstance(instanceID string) (*cloudops.Insta
 
 }
 
+func (e *exponentialBackoff) InspectInstanceInZone(instanceID, zone string) (*cloudops.InstanceInfo, error) {
+	var (
+		instanceInfo *cloudops.InstanceInfo
+		origErr      error
+	)
+	conditionFn := func() (bool, error) {
+		instanceInfo, origErr = e.cloudOps.InspectInstanceInZone(instanceID, zone)
+		msg := fmt.Sprintf("Failed to inspect instance: %v in zone: %v.", instanceID, zone)
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return nil, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return instanceInfo, origErr
+}
+
 func (e *exponentialBackoff) InspectInstanceGroupForInstance(instanceID string) (*cloudops.InstanceGroupInfo, error) {
 	var (
 		instanceGroupInfo *cloudops.InstanceGroupInfo
@@ -82,7 +234,7 @@ func (e *exponentialBackoff) InspectInstanceGroupForInstance(instanceID string)
 		msg := fmt.Sprintf("Failed to inspect instance-group for instance: %v.", instanceID)
 		return e.handleError(origErr, msg)
 	}
-	expErr := wait.ExponentialBackoff(e.backoff, conditionFn)
+	expErr := e.retry(conditionFn)
 	if expErr == wait.ErrWaitTimeout {
 		return nil, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
 	}
@@ -99,7 +251,7 @@ func (e *exponentialBackoff) GetInstance(displayName string) (interface{}, error
 		msg := fmt.Sprintf("Failed to get instance details for instance: %v.", displayName)
 		return e.handleError(origErr, msg)
 	}
-	expErr := wait.ExponentialBackoff(e.backoff, conditionFn)
+	expErr := e.retry(conditionFn)
 	if expErr == wait.ErrWaitTimeout {
 		return nil, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
 	}
@@ -116,7 +268,7 @@ func (e *exponentialBackoff) SetInstanceGroupSize(instanceGroupID string,
 		origErr = e.cloudOps.SetInstanceGroupSize(instanceGroupID, count, timeout)
 		return e.handleError(origErr, fmt.Sprintf("Failed to set cluster size"))
 	}
-	expErr := wait.ExponentialBackoff(e.backoff, conditionFn)
+	expErr := e.retry(conditionFn)
 	if expErr == wait.ErrWaitTimeout {
 		return cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
 	}
@@ -132,7 +284,7 @@ func (e *exponentialBackoff) SetClusterVersion(version string, timeout time.Dura
 		origErr = e.cloudOps.SetClusterVersion(version, timeout)
 		return e.handleError(origErr, fmt.Sprintf("Failed to set cluster version"))
 	}
-	expErr := wait.ExponentialBackoff(e.backoff, conditionFn)
+	expErr := e.retry(conditionFn)
 	if expErr == wait.ErrWaitTimeout {
 		return cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
 	}
@@ -140,6 +292,22 @@ func (e *exponentialBackoff) SetClusterVersion(version string, timeout time.Dura
 
 }
 
+func (e *exponentialBackoff) GetClusterVersion(instanceID string) (string, string, error) {
+	var (
+		controlPlane, nodePool string
+		origErr                error
+	)
+	conditionFn := func() (bool, error) {
+		controlPlane, nodePool, origErr = e.cloudOps.GetClusterVersion(instanceID)
+		return e.handleError(origErr, fmt.Sprintf("Failed to get cluster version"))
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return "", "", cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return controlPlane, nodePool, origErr
+}
+
 func (e *exponentialBackoff) SetInstanceGroupVersion(instanceGroupID string,
 	version string,
 	timeout time.Duration) error {
@@ -150,7 +318,7 @@ func (e *exponentialBackoff) SetInstanceGroupVersion(instanceGroupID string,
 		origErr = e.cloudOps.SetInstanceGroupVersion(instanceGroupID, version, timeout)
 		return e.handleError(origErr, fmt.Sprintf("Failed to set instance group version"))
 	}
-	expErr := wait.ExponentialBackoff(e.backoff, conditionFn)
+	expErr := e.retry(conditionFn)
 	if expErr == wait.ErrWaitTimeout {
 		return cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
 	}
@@ -169,12 +337,61 @@ func (e *exponentialBackoff) SetInstanceUpgradeStrategy(instanceGroupID string,
 		origErr = e.cloudOps.SetInstanceUpgradeStrategy(instanceGroupID, upgradeStrategy, timeout, surgeSetting)
 		return e.handleError(origErr, fmt.Sprintf("Failed to set instance group version"))
 	}
-	expErr := wait.ExponentialBackoff(e.backoff, conditionFn)
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return origErr
+
+}
+
+func (e *exponentialBackoff) RollInstanceGroup(instanceGroupID string, opts cloudops.RollOpts) error {
+	var (
+		origErr error
+	)
+	conditionFn := func() (bool, error) {
+		origErr = e.cloudOps.RollInstanceGroup(instanceGroupID, opts)
+		return e.handleError(origErr, fmt.Sprintf("Failed to roll instance group"))
+	}
+	expErr := e.retry(conditionFn)
 	if expErr == wait.ErrWaitTimeout {
 		return cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
 	}
 	return origErr
+}
 
+func (e *exponentialBackoff) SetInstanceGroupNodeLabels(instanceGroupID string,
+	labels map[string]string,
+	timeout time.Duration) error {
+	var (
+		origErr error
+	)
+	conditionFn := func() (bool, error) {
+		origErr = e.cloudOps.SetInstanceGroupNodeLabels(instanceGroupID, labels, timeout)
+		return e.handleError(origErr, fmt.Sprintf("Failed to set instance group node labels"))
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return origErr
+}
+
+func (e *exponentialBackoff) SetInstanceGroupNodeTaints(instanceGroupID string,
+	taints []cloudops.NodeTaint,
+	timeout time.Duration) error {
+	var (
+		origErr error
+	)
+	conditionFn := func() (bool, error) {
+		origErr = e.cloudOps.SetInstanceGroupNodeTaints(instanceGroupID, taints, timeout)
+		return e.handleError(origErr, fmt.Sprintf("Failed to set instance group node taints"))
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return origErr
 }
 
 func (e *exponentialBackoff) GetInstanceGroupSize(instanceGroupID string) (int64, error) {
@@ -186,13 +403,46 @@ func (e *exponentialBackoff) GetInstanceGroupSize(instanceGroupID string) (int64
 		count, origErr = e.cloudOps.GetInstanceGroupSize(instanceGroupID)
 		return e.handleError(origErr, fmt.Sprintf("Failed to get instance group size"))
 	}
-	expErr := wait.ExponentialBackoff(e.backoff, conditionFn)
+	expErr := e.retry(conditionFn)
 	if expErr == wait.ErrWaitTimeout {
 		return 0, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
 	}
 	return count, origErr
 }
 
+func (e *exponentialBackoff) GetInstanceGroupZones(instanceGroupID string) ([]string, error) {
+	var (
+		zones   []string
+		origErr error
+	)
+	conditionFn := func() (bool, error) {
+		zones, origErr = e.cloudOps.GetInstanceGroupZones(instanceGroupID)
+		msg := fmt.Sprintf("Failed to get zones of instance group: %v.", instanceGroupID)
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return nil, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return zones, origErr
+}
+
+func (e *exponentialBackoff) ListInstanceGroupMembers(instanceGroupID string) ([]string, error) {
+	var (
+		members []string
+		origErr error
+	)
+	conditionFn := func() (bool, error) {
+		members, origErr = e.cloudOps.ListInstanceGroupMembers(instanceGroupID)
+		return e.handleError(origErr, fmt.Sprintf("Failed to list instance group members"))
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return nil, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return members, origErr
+}
+
 func (e *exponentialBackoff) GetClusterSizeForInstance(instanceID string) (int64, error) {
 	var (
 		count   int64
@@ -202,7 +452,7 @@ func (e *exponentialBackoff) GetClusterSizeForInstance(instanceID string) (int64
 		count, origErr = e.cloudOps.GetClusterSizeForInstance(instanceID)
 		return e.handleError(origErr, fmt.Sprintf("Failed to get cluster size for instance: %v.", instanceID))
 	}
-	expErr := wait.ExponentialBackoff(e.backoff, conditionFn)
+	expErr := e.retry(conditionFn)
 	if expErr == wait.ErrWaitTimeout {
 		return 0, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
 	}
@@ -219,7 +469,7 @@ func (e *exponentialBackoff) DeleteInstance(instanceID string, zone string, time
 		msg := fmt.Sprintf("Failed to delete instance: %v.", instanceID)
 		return e.handleError(origErr, msg)
 	}
-	expErr := wait.ExponentialBackoff(e.backoff, conditionFn)
+	expErr := e.retry(conditionFn)
 	if expErr == wait.ErrWaitTimeout {
 		return cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
 	}
@@ -227,6 +477,40 @@ func (e *exponentialBackoff) DeleteInstance(instanceID string, zone string, time
 
 }
 
+func (e *exponentialBackoff) GetNetworkInfo(instanceID string) (*cloudops.NetworkInfo, error) {
+	var (
+		networkInfo *cloudops.NetworkInfo
+		origErr     error
+	)
+	conditionFn := func() (bool, error) {
+		networkInfo, origErr = e.cloudOps.GetNetworkInfo(instanceID)
+		msg := fmt.Sprintf("Failed to get network info for instance: %v.", instanceID)
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return nil, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return networkInfo, origErr
+}
+
+func (e *exponentialBackoff) GetVMDiskBudget(instanceID string) (uint64, uint64, uint64, error) {
+	var (
+		iopsBudget, throughputBudget, used uint64
+		origErr                            error
+	)
+	conditionFn := func() (bool, error) {
+		iopsBudget, throughputBudget, used, origErr = e.cloudOps.GetVMDiskBudget(instanceID)
+		msg := fmt.Sprintf("Failed to get disk budget for instance: %v.", instanceID)
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return 0, 0, 0, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return iopsBudget, throughputBudget, used, origErr
+}
+
 // Create volume based on input template volume and also apply given labels.
 func (e *exponentialBackoff) Create(template interface{}, labels map[string]string, options map[string]string) (interface{}, error) {
 	var (
@@ -238,7 +522,7 @@ func (e *exponentialBackoff) Create(template interface{}, labels map[string]stri
 		msg := fmt.Sprintf("Failed to create drive.")
 		return e.handleError(origErr, msg)
 	}
-	expErr := wait.ExponentialBackoff(e.backoff, conditionFn)
+	expErr := e.retry(conditionFn)
 	if expErr == wait.ErrWaitTimeout {
 		return nil, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
 	}
@@ -246,6 +530,23 @@ func (e *exponentialBackoff) Create(template interface{}, labels map[string]stri
 
 }
 
+func (e *exponentialBackoff) CloneVolume(sourceVolumeID string, template interface{}, labels map[string]string) (interface{}, error) {
+	var (
+		drive   interface{}
+		origErr error
+	)
+	conditionFn := func() (bool, error) {
+		drive, origErr = e.cloudOps.CloneVolume(sourceVolumeID, template, labels)
+		msg := fmt.Sprintf("Failed to clone drive: %v.", sourceVolumeID)
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return nil, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return drive, origErr
+}
+
 // GetDeviceID returns ID/Name of the given device/disk or snapshot
 func (e *exponentialBackoff) GetDeviceID(template interface{}) (string, error) {
 	return e.cloudOps.GetDeviceID(template)
@@ -263,7 +564,7 @@ func (e *exponentialBackoff) Attach(volumeID string, options map[string]string)
 		msg := fmt.Sprintf("Failed to attach drive (%v).", volumeID)
 		return e.handleError(origErr, msg)
 	}
-	expErr := wait.ExponentialBackoff(e.backoff, conditionFn)
+	expErr := e.retry(conditionFn)
 	if expErr == wait.ErrWaitTimeout {
 		return "", cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
 	}
@@ -280,7 +581,7 @@ func (e *exponentialBackoff) Detach(volumeID string, options map[string]string)
 		msg := fmt.Sprintf("Failed to detach drive (%v).", volumeID)
 		return e.handleError(origErr, msg)
 	}
-	expErr := wait.ExponentialBackoff(e.backoff, conditionFn)
+	expErr := e.retry(conditionFn)
 	if expErr == wait.ErrWaitTimeout {
 		return cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
 	}
@@ -297,7 +598,7 @@ func (e *exponentialBackoff) DetachFrom(volumeID, instanceID string) error {
 		msg := fmt.Sprintf("Failed to detach drive (%v) from instance (%v).", volumeID, instanceID)
 		return e.handleError(origErr, msg)
 	}
-	expErr := wait.ExponentialBackoff(e.backoff, conditionFn)
+	expErr := e.retry(conditionFn)
 	if expErr == wait.ErrWaitTimeout {
 		return cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
 	}
@@ -314,7 +615,7 @@ func (e *exponentialBackoff) Delete(volumeID string, options map[string]string)
 		msg := fmt.Sprintf("Failed to delete drive (%v).", volumeID)
 		return e.handleError(origErr, msg)
 	}
-	expErr := wait.ExponentialBackoff(e.backoff, conditionFn)
+	expErr := e.retry(conditionFn)
 	if expErr == wait.ErrWaitTimeout {
 		return cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
 	}
@@ -331,7 +632,7 @@ func (e *exponentialBackoff) DeleteFrom(volumeID, instanceID string) error {
 		msg := fmt.Sprintf("Failed to delete drive (%v) from instance %v.", volumeID, instanceID)
 		return e.handleError(origErr, msg)
 	}
-	expErr := wait.ExponentialBackoff(e.backoff, conditionFn)
+	expErr := e.retry(conditionFn)
 	if expErr == wait.ErrWaitTimeout {
 		return cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
 	}
@@ -349,7 +650,7 @@ func (e *exponentialBackoff) Describe() (interface{}, error) {
 		msg := fmt.Sprintf("Failed to describe instance.")
 		return e.handleError(origErr, msg)
 	}
-	expErr := wait.ExponentialBackoff(e.backoff, conditionFn)
+	expErr := e.retry(conditionFn)
 	if expErr == wait.ErrWaitTimeout {
 		return nil, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
 	}
@@ -374,7 +675,7 @@ func (e *exponentialBackoff) Inspect(volumeIds []*string, options map[string]str
 		msg := fmt.Sprintf("Failed to inspect drives (%v).", volumeIds)
 		return e.handleError(origErr, msg)
 	}
-	expErr := wait.ExponentialBackoff(e.backoff, conditionFn)
+	expErr := e.retry(conditionFn)
 	if expErr == wait.ErrWaitTimeout {
 		return nil, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
 	}
@@ -392,7 +693,7 @@ func (e *exponentialBackoff) DeviceMappings() (map[string]string, error) {
 		msg := fmt.Sprintf("Failed to get device mappings.")
 		return e.handleError(origErr, msg)
 	}
-	expErr := wait.ExponentialBackoff(e.backoff, conditionFn)
+	expErr := e.retry(conditionFn)
 	if expErr == wait.ErrWaitTimeout {
 		return nil, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
 	}
@@ -418,7 +719,7 @@ func (e *exponentialBackoff) Enumerate(volumeIds []*string,
 		msg := fmt.Sprintf("Failed to enumerate drives (%v).", volumeIdsStr)
 		return e.handleError(origErr, msg)
 	}
-	expErr := wait.ExponentialBackoff(e.backoff, conditionFn)
+	expErr := e.retry(conditionFn)
 	if expErr == wait.ErrWaitTimeout {
 		return nil, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
 	}
@@ -448,13 +749,29 @@ func (e *exponentialBackoff) DevicePath(volumeID string) (string, error) {
 		msg := fmt.Sprintf("Failed to get device path for drive (%v).", volumeID)
 		return e.handleError(origErr, msg)
 	}
-	expErr := wait.ExponentialBackoff(e.backoff, conditionFn)
+	expErr := e.retry(conditionFn)
 	if expErr == wait.ErrWaitTimeout {
 		return "", cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
 	}
 	return devicePath, origErr
 }
 
+func (e *exponentialBackoff) WaitForVolumeState(volumeID string, desiredState string, timeout time.Duration) error {
+	var (
+		origErr error
+	)
+	conditionFn := func() (bool, error) {
+		origErr = e.cloudOps.WaitForVolumeState(volumeID, desiredState, timeout)
+		msg := fmt.Sprintf("Failed to wait for drive (%v) to be %v.", volumeID, desiredState)
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return origErr
+}
+
 func (e *exponentialBackoff) AreVolumesReadyToExpand(volumeIDs []*string) (bool, error) {
 	return e.cloudOps.AreVolumesReadyToExpand(volumeIDs)
 }
@@ -469,13 +786,30 @@ func (e *exponentialBackoff) Expand(volumeID string, targetSize uint64, options
 		msg := fmt.Sprintf("Failed to get device path for drive (%v).", volumeID)
 		return e.handleError(origErr, msg)
 	}
-	expErr := wait.ExponentialBackoff(e.backoff, conditionFn)
+	expErr := e.retry(conditionFn)
 	if expErr == wait.ErrWaitTimeout {
 		return 0, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
 	}
 	return actualSize, origErr
 }
 
+// ModifyVolumeType changes the type of the given volume in place
+func (e *exponentialBackoff) ModifyVolumeType(volumeID, newType string, iops, throughput *int64) error {
+	var (
+		origErr error
+	)
+	conditionFn := func() (bool, error) {
+		origErr = e.cloudOps.ModifyVolumeType(volumeID, newType, iops, throughput)
+		msg := fmt.Sprintf("Failed to modify type of drive (%v) to (%v).", volumeID, newType)
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return origErr
+}
+
 // Snapshot the volume with given volumeID
 func (e *exponentialBackoff) Snapshot(volumeID string, readonly bool, options map[string]string) (interface{}, error) {
 	var (
@@ -487,7 +821,25 @@ func (e *exponentialBackoff) Snapshot(volumeID string, readonly bool, options ma
 		msg := fmt.Sprintf("Failed to snapshot drive (%v).", volumeID)
 		return e.handleError(origErr, msg)
 	}
-	expErr := wait.ExponentialBackoff(e.backoff, conditionFn)
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return nil, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return snapshot, origErr
+}
+
+// SnapshotWithOptions snapshots the volume with given volumeID
+func (e *exponentialBackoff) SnapshotWithOptions(volumeID string, readonly bool, opts cloudops.SnapshotOptions) (interface{}, error) {
+	var (
+		snapshot interface{}
+		origErr  error
+	)
+	conditionFn := func() (bool, error) {
+		snapshot, origErr = e.cloudOps.SnapshotWithOptions(volumeID, readonly, opts)
+		msg := fmt.Sprintf("Failed to snapshot drive (%v).", volumeID)
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
 	if expErr == wait.ErrWaitTimeout {
 		return nil, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
 	}
@@ -504,7 +856,7 @@ func (e *exponentialBackoff) SnapshotDelete(snapID string, options map[string]st
 		msg := fmt.Sprintf("Failed to delete snapshot (%v).", snapID)
 		return e.handleError(origErr, msg)
 	}
-	expErr := wait.ExponentialBackoff(e.backoff, conditionFn)
+	expErr := e.retry(conditionFn)
 	if expErr == wait.ErrWaitTimeout {
 		return cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
 	}
@@ -521,7 +873,7 @@ func (e *exponentialBackoff) ApplyTags(volumeID string, labels map[string]string
 		msg := fmt.Sprintf("Failed to apply tags on drive (%v).", volumeID)
 		return e.handleError(origErr, msg)
 	}
-	expErr := wait.ExponentialBackoff(e.backoff, conditionFn)
+	expErr := e.retry(conditionFn)
 	if expErr == wait.ErrWaitTimeout {
 		return cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
 	}
@@ -538,7 +890,7 @@ func (e *exponentialBackoff) RemoveTags(volumeID string, labels map[string]strin
 		msg := fmt.Sprintf("Failed to remove tags from drive (%v).", volumeID)
 		return e.handleError(origErr, msg)
 	}
-	expErr := wait.ExponentialBackoff(e.backoff, conditionFn)
+	expErr := e.retry(conditionFn)
 	if expErr == wait.ErrWaitTimeout {
 		return cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
 	}
@@ -556,17 +908,516 @@ func (e *exponentialBackoff) Tags(volumeID string) (map[string]string, error) {
 		msg := fmt.Sprintf("Failed to get tags of drive (%v).", volumeID)
 		return e.handleError(origErr, msg)
 	}
-	expErr := wait.ExponentialBackoff(e.backoff, conditionFn)
+	expErr := e.retry(conditionFn)
 	if expErr == wait.ErrWaitTimeout {
 		return nil, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
 	}
 	return labels, origErr
 }
 
+// ReconcileDataDisks removes stale data disk entries from the given instance
+func (e *exponentialBackoff) ReconcileDataDisks(instanceID string) ([]string, error) {
+	var (
+		origErr error
+		luns    []string
+	)
+	conditionFn := func() (bool, error) {
+		luns, origErr = e.cloudOps.ReconcileDataDisks(instanceID)
+		msg := fmt.Sprintf("Failed to reconcile data disks on instance (%v).", instanceID)
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return nil, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return luns, origErr
+}
+
+// GetEffectivePerformance returns the IOPS and throughput provisioned for the given volume
+func (e *exponentialBackoff) GetEffectivePerformance(volumeID string) (uint64, uint64, error) {
+	var (
+		origErr    error
+		iops       uint64
+		throughput uint64
+	)
+	conditionFn := func() (bool, error) {
+		iops, throughput, origErr = e.cloudOps.GetEffectivePerformance(volumeID)
+		msg := fmt.Sprintf("Failed to get effective performance of drive (%v).", volumeID)
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return 0, 0, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return iops, throughput, origErr
+}
+
+// ConfigureReplication configures replication of the given volume to the target region
+func (e *exponentialBackoff) ConfigureReplication(volumeID, targetRegion string, options map[string]string) error {
+	var (
+		origErr error
+	)
+	conditionFn := func() (bool, error) {
+		origErr = e.cloudOps.ConfigureReplication(volumeID, targetRegion, options)
+		msg := fmt.Sprintf("Failed to configure replication of drive (%v) to region (%v).", volumeID, targetRegion)
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return origErr
+}
+
+// StopReplication stops the replication of the given volume
+func (e *exponentialBackoff) StopReplication(volumeID string) error {
+	var (
+		origErr error
+	)
+	conditionFn := func() (bool, error) {
+		origErr = e.cloudOps.StopReplication(volumeID)
+		msg := fmt.Sprintf("Failed to stop replication of drive (%v).", volumeID)
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return origErr
+}
+
+// EnumerateSnapshots returns the snapshots of the given volumes that match the given labels
+func (e *exponentialBackoff) EnumerateSnapshots(volumeIDs []*string, labels map[string]string) ([]*cloudops.SnapshotInfo, error) {
+	var (
+		snapshots []*cloudops.SnapshotInfo
+		origErr   error
+	)
+	conditionFn := func() (bool, error) {
+		snapshots, origErr = e.cloudOps.EnumerateSnapshots(volumeIDs, labels)
+		msg := fmt.Sprintf("Failed to enumerate snapshots of drives (%v).", volumeIDs)
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return nil, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return snapshots, origErr
+}
+
+// ListSnapshots returns the snapshots that match the given labels
+func (e *exponentialBackoff) ListSnapshots(labels map[string]string) ([]*cloudops.SnapshotInfo, error) {
+	return e.EnumerateSnapshots(nil, labels)
+}
+
+// InspectSnapshot returns the snapshot with given ID
+func (e *exponentialBackoff) InspectSnapshot(snapID string) (*cloudops.SnapshotInfo, error) {
+	var (
+		snapshot *cloudops.SnapshotInfo
+		origErr  error
+	)
+	conditionFn := func() (bool, error) {
+		snapshot, origErr = e.cloudOps.InspectSnapshot(snapID)
+		msg := fmt.Sprintf("Failed to inspect snapshot (%v).", snapID)
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return nil, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return snapshot, origErr
+}
+
+// ReportCapacityByLabel returns the provisioned capacity of the disks grouped by the given label
+func (e *exponentialBackoff) ReportCapacityByLabel(labelKey string) (map[string]uint64, error) {
+	var (
+		capacities map[string]uint64
+		origErr    error
+	)
+	conditionFn := func() (bool, error) {
+		capacities, origErr = e.cloudOps.ReportCapacityByLabel(labelKey)
+		msg := fmt.Sprintf("Failed to report capacity by label (%v).", labelKey)
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return nil, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return capacities, origErr
+}
+
+// UpdateAttachmentCaching changes the caching mode of the given disk attached to the given instance
+func (e *exponentialBackoff) UpdateAttachmentCaching(instanceID, diskName, caching string) error {
+	var (
+		origErr error
+	)
+	conditionFn := func() (bool, error) {
+		origErr = e.cloudOps.UpdateAttachmentCaching(instanceID, diskName, caching)
+		msg := fmt.Sprintf("Failed to update caching of drive (%v) on instance (%v).", diskName, instanceID)
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return origErr
+}
+
+// GetVolumeQoS returns the IOPS and throughput limits enforced on the given volume
+func (e *exponentialBackoff) GetVolumeQoS(volumeID string) (uint64, uint64, error) {
+	var (
+		iopsLimit, throughputLimit uint64
+		origErr                    error
+	)
+	conditionFn := func() (bool, error) {
+		iopsLimit, throughputLimit, origErr = e.cloudOps.GetVolumeQoS(volumeID)
+		msg := fmt.Sprintf("Failed to get QoS limits of drive (%v).", volumeID)
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return 0, 0, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return iopsLimit, throughputLimit, origErr
+}
+
+// ExpandMany expands the given volumes to the new size
+func (e *exponentialBackoff) ExpandMany(volumeIDs []string, newSizeInGiB uint64, options map[string]string) (map[string]uint64, error) {
+	var (
+		sizes   map[string]uint64
+		origErr error
+	)
+	conditionFn := func() (bool, error) {
+		sizes, origErr = e.cloudOps.ExpandMany(volumeIDs, newSizeInGiB, options)
+		msg := fmt.Sprintf("Failed to expand drives (%v).", volumeIDs)
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return nil, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return sizes, origErr
+}
+
+// DeleteVolumes deletes the given volumes, retrying the deletes which failed
+// with an exponential backoff error
+func (e *exponentialBackoff) DeleteVolumes(volumeIDs []string) (map[string]error, error) {
+	var (
+		origErr error
+		pending = volumeIDs
+		errs    = make(map[string]error)
+	)
+	conditionFn := func() (bool, error) {
+		var volErrs map[string]error
+		volErrs, origErr = e.cloudOps.DeleteVolumes(pending)
+		if origErr != nil {
+			for volumeID, err := range volErrs {
+				errs[volumeID] = err
+			}
+			return true, origErr
+		}
+		pending = nil
+		for volumeID, err := range volErrs {
+			if e.isExponentialError(err) {
+				pending = append(pending, volumeID)
+				continue
+			}
+			errs[volumeID] = err
+		}
+		if len(pending) > 0 {
+			logrus.WithFields(logrus.Fields{
+				e.cloudOps.Name() + "-error": volErrs[pending[0]],
+			}).Errorf("Failed to delete drives (%v). Retrying after a backoff.", pending)
+			return false, nil
+		}
+		return true, nil
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		for _, volumeID := range pending {
+			errs[volumeID] = cloudops.NewStorageError(cloudops.ErrExponentialTimeout,
+				fmt.Sprintf("timed out deleting drive %s", volumeID), "")
+		}
+		return errs, nil
+	}
+	return errs, origErr
+}
+
+// LockVolume locks the given volume for the given owner
+func (e *exponentialBackoff) LockVolume(volumeID, owner string) (bool, error) {
+	var (
+		locked  bool
+		origErr error
+	)
+	conditionFn := func() (bool, error) {
+		locked, origErr = e.cloudOps.LockVolume(volumeID, owner)
+		msg := fmt.Sprintf("Failed to lock drive (%v) for %v.", volumeID, owner)
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return false, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return locked, origErr
+}
+
+// UnlockVolume releases the lock the given owner holds on the volume
+func (e *exponentialBackoff) UnlockVolume(volumeID, owner string) error {
+	var origErr error
+	conditionFn := func() (bool, error) {
+		origErr = e.cloudOps.UnlockVolume(volumeID, owner)
+		msg := fmt.Sprintf("Failed to unlock drive (%v) for %v.", volumeID, owner)
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return origErr
+}
+
+// GetStorageLayout returns the specs of the volumes attached to the given instance
+func (e *exponentialBackoff) GetStorageLayout(instanceID string) ([]cloudops.VolumeSpec, error) {
+	var (
+		specs   []cloudops.VolumeSpec
+		origErr error
+	)
+	conditionFn := func() (bool, error) {
+		specs, origErr = e.cloudOps.GetStorageLayout(instanceID)
+		msg := fmt.Sprintf("Failed to get storage layout of instance (%v).", instanceID)
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return nil, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return specs, origErr
+}
+
+// ProvisionStorageLayout creates a volume matching each of the given specs
+func (e *exponentialBackoff) ProvisionStorageLayout(specs []cloudops.VolumeSpec, labels map[string]string) ([]interface{}, error) {
+	var (
+		vols    []interface{}
+		origErr error
+	)
+	conditionFn := func() (bool, error) {
+		vols, origErr = e.cloudOps.ProvisionStorageLayout(specs, labels)
+		msg := fmt.Sprintf("Failed to provision storage layout with labels (%v).", labels)
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return nil, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return vols, origErr
+}
+
+// GetVolumeLineage returns the source the given volume was created from
+func (e *exponentialBackoff) GetVolumeLineage(volumeID string) (string, string, error) {
+	var (
+		sourceType, sourceID string
+		origErr              error
+	)
+	conditionFn := func() (bool, error) {
+		sourceType, sourceID, origErr = e.cloudOps.GetVolumeLineage(volumeID)
+		msg := fmt.Sprintf("Failed to get lineage of volume (%v).", volumeID)
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return "", "", cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return sourceType, sourceID, origErr
+}
+
+// BatchInspect returns the given volumes keyed by volume ID
+func (e *exponentialBackoff) BatchInspect(volumeIds []*string) (map[string]interface{}, error) {
+	var (
+		vols    map[string]interface{}
+		origErr error
+	)
+	conditionFn := func() (bool, error) {
+		vols, origErr = e.cloudOps.BatchInspect(volumeIds)
+		msg := fmt.Sprintf("Failed to batch inspect volumes (%v).", volumeIds)
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return nil, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return vols, origErr
+}
+
+// AdoptVolume brings the given volume under cloudops management
+func (e *exponentialBackoff) AdoptVolume(volumeID string, labels map[string]string) error {
+	var (
+		origErr error
+	)
+	conditionFn := func() (bool, error) {
+		origErr = e.cloudOps.AdoptVolume(volumeID, labels)
+		msg := fmt.Sprintf("Failed to adopt drive (%v).", volumeID)
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return origErr
+}
+
+// DeleteSnapshotChain deletes the given snapshots in dependency order
+func (e *exponentialBackoff) DeleteSnapshotChain(snapIDs []string) error {
+	var (
+		origErr error
+	)
+	conditionFn := func() (bool, error) {
+		origErr = e.cloudOps.DeleteSnapshotChain(snapIDs)
+		msg := fmt.Sprintf("Failed to delete snapshots (%v).", snapIDs)
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return origErr
+}
+
+// GetStoragePressure returns the provisioned and consumed size of the volumes
+// attached to the given instance
+func (e *exponentialBackoff) GetStoragePressure(instanceID string) ([]cloudops.VolumePressureInfo, error) {
+	var (
+		pressure []cloudops.VolumePressureInfo
+		origErr  error
+	)
+	conditionFn := func() (bool, error) {
+		pressure, origErr = e.cloudOps.GetStoragePressure(instanceID)
+		msg := fmt.Sprintf("Failed to get storage pressure of instance (%v).", instanceID)
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return nil, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return pressure, origErr
+}
+
+// CheckVolumeQuota checks the given storage pools against the cloud
+// provider's quotas
+func (e *exponentialBackoff) CheckVolumeQuota(required []*cloudops.StoragePoolSpec) error {
+	var (
+		origErr error
+	)
+	conditionFn := func() (bool, error) {
+		origErr = e.cloudOps.CheckVolumeQuota(required)
+		msg := "Failed to check volume quota."
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return origErr
+}
+
+// ListVolumesPaged returns an iterator over the volumes with the given labels.
+// Only the call returning the iterator is retried, not the page fetches of the
+// iterator.
+func (e *exponentialBackoff) ListVolumesPaged(labels map[string]string, pageSize int) (cloudops.VolumeIterator, error) {
+	var (
+		it      cloudops.VolumeIterator
+		origErr error
+	)
+	conditionFn := func() (bool, error) {
+		it, origErr = e.cloudOps.ListVolumesPaged(labels, pageSize)
+		msg := "Failed to list volumes."
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return nil, cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), "")
+	}
+	return it, origErr
+}
+
+// AttachByInstanceID attaches the volume to the given instance
+func (e *exponentialBackoff) AttachByInstanceID(instanceID, volumeID string, options map[string]string) (string, error) {
+	var (
+		devicePath string
+		origErr    error
+	)
+	conditionFn := func() (bool, error) {
+		devicePath, origErr = e.cloudOps.AttachByInstanceID(instanceID, volumeID, options)
+		msg := fmt.Sprintf("Failed to attach drive (%v) to instance (%v).", volumeID, instanceID)
+		return e.handleError(origErr, msg)
+	}
+	expErr := e.retry(conditionFn)
+	if expErr == wait.ErrWaitTimeout {
+		return "", cloudops.NewStorageError(cloudops.ErrExponentialTimeout, origErr.Error(), instanceID)
+	}
+	return devicePath, origErr
+}
+
+// Unwrap returns the cloudops.Ops wrapped with exponential backoff
+func (e *exponentialBackoff) Unwrap() cloudops.Ops {
+	return e.cloudOps
+}
+
 func (e *exponentialBackoff) Name() string {
 	return "exponential-backoff"
 }
 
+// retry runs the condition with exponential backoff. In adaptive mode the
+// intervals are lengthened as the remaining request budget shrinks.
+func (e *exponentialBackoff) retry(condition wait.ConditionFunc) error {
+	reporter, ok := e.cloudOps.(RateLimitReporter)
+	adaptive := e.rateLimitThreshold > 0 && ok
+	if !adaptive && e.maxInterval <= 0 && e.maxElapsedTime <= 0 {
+		return wait.ExponentialBackoff(e.backoff, condition)
+	}
+
+	start := time.Now()
+	backoff := e.backoff
+	for backoff.Steps > 0 {
+		if ok, err := condition(); err != nil || ok {
+			return err
+		}
+		if backoff.Steps == 1 {
+			break
+		}
+		interval := backoff.Step()
+		if e.maxInterval > 0 {
+			// clamp the base duration as well so it cannot overflow
+			if backoff.Duration > e.maxInterval {
+				backoff.Duration = e.maxInterval
+			}
+			if interval > e.maxInterval {
+				interval = e.maxInterval
+			}
+		}
+		if adaptive {
+			if remaining, ok := reporter.RemainingRequests(); ok {
+				interval = adaptiveInterval(interval, remaining, e.rateLimitThreshold)
+			}
+		}
+		if e.maxElapsedTime > 0 && time.Since(start)+interval > e.maxElapsedTime {
+			break
+		}
+		time.Sleep(interval)
+	}
+	return wait.ErrWaitTimeout
+}
+
+// adaptiveInterval scales the interval by threshold/remaining once the
+// remaining request budget falls below the threshold
+func adaptiveInterval(interval time.Duration, remaining, threshold int64) time.Duration {
+	if remaining >= threshold {
+		return interval
+	}
+	if remaining < 1 {
+		remaining = 1
+	}
+	return interval * time.Duration(threshold) / time.Duration(remaining)
+}
+
 func (e *exponentialBackoff) handleError(origErr error, msg string) (bool, error) {
 	if origErr != nil {
 		if e.isExponentialError(origErr) {
@@ -1,8 +1,14 @@
 package backoff
 
 import (
+	"errors"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/libopenstorage/cloudops"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/wait"
 )
 
 func TestVolumeIdsToString(t *testing.T) {
@@ -18,3 +24,138 @@ func TestVolumeIdsToString(t *testing.T) {
 	}
 
 }
+
+var errThrottled = errors.New("throttled")
+
+// rateLimitedOps fails DeviceMappings as throttled until it has been called
+// once per remaining request budget and reports the budget of the latest call
+type rateLimitedOps struct {
+	cloudops.Ops
+	remaining []int64
+	calls     int
+}
+
+func (r *rateLimitedOps) Name() string {
+	return "rate-limited"
+}
+
+func (r *rateLimitedOps) DeviceMappings() (map[string]string, error) {
+	r.calls++
+	if r.calls < len(r.remaining) {
+		return nil, errThrottled
+	}
+	return map[string]string{}, nil
+}
+
+func (r *rateLimitedOps) RemainingRequests() (int64, bool) {
+	return r.remaining[r.calls-1], true
+}
+
+func TestAdaptiveInterval(t *testing.T) {
+	const threshold = 100
+	interval := time.Second
+
+	require.Equal(t, interval, adaptiveInterval(interval, 500, threshold))
+	require.Equal(t, interval, adaptiveInterval(interval, threshold, threshold))
+
+	prev := interval
+	for _, remaining := range []int64{80, 40, 10, 1} {
+		next := adaptiveInterval(interval, remaining, threshold)
+		require.True(t, next > prev, "interval %v with %d remaining must be longer than %v", next, remaining, prev)
+		prev = next
+	}
+	require.Equal(t, 100*interval, prev)
+	require.Equal(t, 100*interval, adaptiveInterval(interval, 0, threshold))
+}
+
+func TestAdaptiveBackoff(t *testing.T) {
+	isThrottled := func(err error) bool { return err == errThrottled }
+	fixed := wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 5}
+
+	// With fixed backoff the reported budget is ignored
+	ops := &rateLimitedOps{remaining: []int64{10, 1, 100}}
+	start := time.Now()
+	_, err := NewExponentialBackoffOps(ops, isThrottled, fixed).DeviceMappings()
+	require.NoError(t, err)
+	require.Equal(t, 3, ops.calls)
+	require.True(t, time.Since(start) < 50*time.Millisecond)
+
+	// With adaptive backoff the intervals are lengthened to 10ms and 100ms
+	ops = &rateLimitedOps{remaining: []int64{10, 1, 100}}
+	start = time.Now()
+	_, err = NewAdaptiveExponentialBackoffOps(ops, isThrottled, fixed, 100).DeviceMappings()
+	require.NoError(t, err)
+	require.Equal(t, 3, ops.calls)
+	require.True(t, time.Since(start) >= 110*time.Millisecond)
+}
+
+// throttledOps always fails DeviceMappings as throttled
+type throttledOps struct {
+	cloudops.Ops
+	calls int
+}
+
+func (o *throttledOps) Name() string {
+	return "throttled"
+}
+
+func (o *throttledOps) DeviceMappings() (map[string]string, error) {
+	o.calls++
+	return nil, errThrottled
+}
+
+func TestConfigValidate(t *testing.T) {
+	valid := Config{
+		InitialInterval: time.Second,
+		MaxInterval:     time.Minute,
+		Multiplier:      2,
+		MaxElapsedTime:  time.Hour,
+	}
+	require.NoError(t, valid.Validate())
+
+	invalid := valid
+	invalid.InitialInterval = 0
+	require.Error(t, invalid.Validate())
+
+	invalid = valid
+	invalid.MaxInterval = time.Millisecond
+	require.Error(t, invalid.Validate())
+
+	invalid = valid
+	invalid.Multiplier = 0.5
+	require.Error(t, invalid.Validate())
+
+	invalid = valid
+	invalid.Jitter = -1
+	require.Error(t, invalid.Validate())
+
+	invalid = valid
+	invalid.MaxElapsedTime = 0
+	require.Error(t, invalid.Validate())
+
+	_, err := NewExponentialBackoffOpsWithConfig(&throttledOps{}, nil, invalid)
+	require.Error(t, err)
+}
+
+func TestConfigMaxElapsedTime(t *testing.T) {
+	isThrottled := func(err error) bool { return err == errThrottled }
+	ops := &throttledOps{}
+	backoffOps, err := NewExponentialBackoffOpsWithConfig(ops, isThrottled, Config{
+		InitialInterval: 10 * time.Millisecond,
+		MaxInterval:     40 * time.Millisecond,
+		Multiplier:      2,
+		MaxElapsedTime:  220 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	// Calls are made after 0, 10, 30, 70, 110, 150 and 190ms, the next one
+	// would be made after 230ms, past the max elapsed time
+	start := time.Now()
+	_, err = backoffOps.DeviceMappings()
+	require.Error(t, err)
+	se, ok := err.(*cloudops.StorageError)
+	require.True(t, ok, "expected a storage error, got %v", err)
+	require.Equal(t, cloudops.ErrExponentialTimeout, se.Code)
+	require.Equal(t, 7, ops.calls)
+	require.True(t, time.Since(start) < 220*time.Millisecond)
+}
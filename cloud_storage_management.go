@@ -19,6 +19,15 @@ var (
 	ErrCurrentCapacitySameAsDesired = errors.New("current capacity is already equal to new capacity")
 )
 
+const (
+	// DefaultInstanceMaxDrives is the instance_max_drives of the decision
+	// matrix rows built by BuildDecisionMatrix
+	DefaultInstanceMaxDrives = 8
+	// DefaultInstanceMinDrives is the instance_min_drives of the decision
+	// matrix rows built by BuildDecisionMatrix
+	DefaultInstanceMinDrives = 1
+)
+
 // ErrStorageDistributionCandidateNotFound is returned when the storage manager fails to
 // determine the right storage distribution candidate
 type ErrStorageDistributionCandidateNotFound struct {
@@ -39,6 +48,11 @@ type StorageDecisionMatrixRow struct {
 	MinIOPS uint64 `json:"min_iops" yaml:"min_iops"`
 	// MaxIOPS is the maximum desired iops from the underlying cloud storage.
 	MaxIOPS uint64 `json:"max_iops" yaml:"max_iops"`
+	// IOPSPerGiB is the iops provisioned per GiB of capacity for drive types
+	// whose iops scale with their size. If set, the iops of a recommended
+	// drive is its size times IOPSPerGiB, bounded by MinIOPS and MaxIOPS,
+	// instead of the cloud provider's built-in iops model.
+	IOPSPerGiB float64 `json:"iops_per_gib,omitempty" yaml:"iops_per_gib,omitempty"`
 	// InstanceType is the type of instance on which the cloud storage can
 	// be attached.
 	InstanceType string `json:"instance_type" yaml:"instance_type"`
@@ -93,12 +107,29 @@ type StorageDistributionRequest struct {
 	// UserStorageSpec is a list of user's storage requirements.
 	UserStorageSpec []*StorageSpec `json:"user_storage_spec" yaml:"user_storage_spec"`
 	// InstanceType is the type of instance where user needs to provision storage.
+	// Only the decision matrix rows for this instance type, or for all
+	// instance types, are considered.
 	InstanceType string `json:"instance_type" yaml:"instance_type"`
+	// Region is the region of the instances. Only the decision matrix rows for
+	// this region, or for all regions, are considered.
+	Region string `json:"region,omitempty" yaml:"region,omitempty"`
 	// InstancesPerZone is the number of instances in each zone.
 	InstancesPerZone uint64 `json:"instances_per_zone" yaml:"instances_per_zone"`
 	// ZoneCount is the number of zones across which the instances are
 	// distributed in the cluster.
 	ZoneCount uint64 `json:"zone_count" yaml:"zone_count"`
+	// IncludeDecisionMatrixRows if set returns the decision matrix row selected
+	// for each of the storage pools in the response.
+	IncludeDecisionMatrixRows bool `json:"include_decision_matrix_rows,omitempty" yaml:"include_decision_matrix_rows,omitempty"`
+	// ZoneDriveTypes optionally maps each of the zones across which the
+	// instances are distributed to the drive types available in it. If set,
+	// only drive types available in all the zones are recommended.
+	ZoneDriveTypes map[string][]string `json:"zone_drive_types,omitempty" yaml:"zone_drive_types,omitempty"`
+	// MergeCompatibleSpecs if set merges the user storage specs with the
+	// same drive type and IOPS into a single spec, and hence a single storage
+	// pool, whose capacities are the sum of the capacities of the merged
+	// specs. By default each user storage spec gets its own storage pool.
+	MergeCompatibleSpecs bool `json:"merge_compatible_specs,omitempty" yaml:"merge_compatible_specs,omitempty"`
 }
 
 // StoragePoolSpec defines the type, capacity and number of storage drive that needs
@@ -115,6 +146,23 @@ type StoragePoolSpec struct {
 	InstancesPerZone uint64 `json:"instances_per_zone" yaml:"instances_per_zone"`
 	// IOPS is the IOPS of the drive
 	IOPS uint64 `json:"iops" yaml:"iops"`
+	// ThinProvisioning is set if the drives should be thinly provisioned, as
+	// requested by the decision matrix row. Pass it to Create through the
+	// ThinProvisioningOption.
+	ThinProvisioning bool `json:"thin_provisioning,omitempty" yaml:"thin_provisioning,omitempty"`
+	// ZoneCount is the number of zones the storage pool is provisioned in,
+	// as counted by CheckVolumeQuota. 0 counts as a single zone.
+	ZoneCount uint64 `json:"zone_count,omitempty" yaml:"zone_count,omitempty"`
+}
+
+// TotalDriveCount returns the number of drives of the storage pool across
+// all its instances and zones
+func (s *StoragePoolSpec) TotalDriveCount() uint64 {
+	zoneCount := s.ZoneCount
+	if zoneCount == 0 {
+		zoneCount = 1
+	}
+	return s.DriveCount * s.InstancesPerZone * zoneCount
 }
 
 // StorageDistributionResponse is the result returned the CloudStorage Decision Matrix
@@ -123,6 +171,60 @@ type StorageDistributionResponse struct {
 	// InstanceStorage defines a list of storage pool specs that need to be
 	// provisioned on an instance.
 	InstanceStorage []*StoragePoolSpec `json:"instance_storage" yaml:"instance_storage"`
+	// DecisionMatrixRows are the decision matrix rows selected for the storage
+	// pools in InstanceStorage, in the same order. It is only populated if
+	// IncludeDecisionMatrixRows is set in the request.
+	DecisionMatrixRows []*StorageDecisionMatrixRow `json:"decision_matrix_rows,omitempty" yaml:"decision_matrix_rows,omitempty"`
+}
+
+// CandidateRejectionReason is the reason a decision matrix row was not picked
+// for a user storage spec
+type CandidateRejectionReason string
+
+const (
+	// CandidateRejectedDriveType is the reason for rows of a different drive
+	// type than the requested one
+	CandidateRejectedDriveType CandidateRejectionReason = "drive_type_mismatch"
+	// CandidateRejectedZoneAvailability is the reason for rows whose drive
+	// type is not available in all the zones of the request
+	CandidateRejectedZoneAvailability CandidateRejectionReason = "drive_type_unavailable_in_zone"
+	// CandidateRejectedInstanceType is the reason for rows of a different
+	// instance type than the one of the request
+	CandidateRejectedInstanceType CandidateRejectionReason = "instance_type_mismatch"
+	// CandidateRejectedRegion is the reason for rows of a different region
+	// than the one of the request
+	CandidateRejectedRegion CandidateRejectionReason = "region_mismatch"
+	// CandidateRejectedIOPS is the reason for rows whose max IOPS is lower than
+	// the requested IOPS
+	CandidateRejectedIOPS CandidateRejectionReason = "iops_too_low"
+	// CandidateRejectedSize is the reason for rows whose drive sizes cannot
+	// hold the requested capacity within the drive count limits
+	CandidateRejectedSize CandidateRejectionReason = "size_out_of_range"
+	// CandidateRejectedDistribution is the reason for rows which could hold
+	// the requested capacity but not split evenly across the instances and
+	// drives of a zone
+	CandidateRejectedDistribution CandidateRejectionReason = "uneven_distribution"
+	// CandidateRejectedOutranked is the reason for viable rows ranked below
+	// the picked row
+	CandidateRejectedOutranked CandidateRejectionReason = "outranked"
+)
+
+// CandidateEvaluation is the evaluation of a decision matrix row as the source
+// of the storage pool of a user storage spec
+type CandidateEvaluation struct {
+	// UserStorageSpec is the user storage spec the row was evaluated for
+	UserStorageSpec *StorageSpec `json:"user_storage_spec" yaml:"user_storage_spec"`
+	// Row is the evaluated decision matrix row
+	Row StorageDecisionMatrixRow `json:"row" yaml:"row"`
+	// Selected is true for the row the storage pool was picked from
+	Selected bool `json:"selected" yaml:"selected"`
+	// Candidate is the storage pool spec the row yields, if it is viable
+	Candidate *StoragePoolSpec `json:"candidate,omitempty" yaml:"candidate,omitempty"`
+	// RejectionReason is the reason the row was not picked. It is empty for
+	// the selected row.
+	RejectionReason CandidateRejectionReason `json:"rejection_reason,omitempty" yaml:"rejection_reason,omitempty"`
+	// Details explains the rejection in terms of the row and the request
+	Details string `json:"details,omitempty" yaml:"details,omitempty"`
 }
 
 // StoragePoolUpdateRequest is the required changes for updating the storage on a given
@@ -153,6 +255,10 @@ type StoragePoolUpdateResponse struct {
 	// ResizeOperationType is the operation caller should perform on the disks in
 	// the above InstanceStorage for the storage update on the instance
 	ResizeOperationType api.SdkStoragePool_ResizeOperationType
+	// ResizeRejectedReason is the reason resizing the existing disks was
+	// rejected when no ResizeOperationType was requested and disks are added
+	// instead
+	ResizeRejectedReason string `json:"resize_rejected_reason,omitempty" yaml:"resize_rejected_reason,omitempty"`
 }
 
 type MaxDriveSizeRequest struct {
@@ -171,11 +277,21 @@ type MaxDriveSizeResponse struct {
 type StorageManager interface {
 	// GetStorageDistribution returns the storage distribution for the provided request
 	GetStorageDistribution(request *StorageDistributionRequest) (*StorageDistributionResponse, error)
+	// GetStorageDistributionWithExplanation returns the storage distribution for
+	// the provided request along with the evaluation of every decision matrix
+	// row for each of the user storage specs. The evaluations of a spec are
+	// ranked in the order the rows are considered, followed by the rows the
+	// request filtered out. The evaluations are returned even if no
+	// distribution is found.
+	GetStorageDistributionWithExplanation(request *StorageDistributionRequest) (*StorageDistributionResponse, []CandidateEvaluation, error)
 	// RecommendStoragePoolUpdate returns the recommended storage configuration on
 	// the instance based on the given request
 	RecommendStoragePoolUpdate(request *StoragePoolUpdateRequest) (*StoragePoolUpdateResponse, error)
 	// GetMaxDriveSize returns the maximum size a drive can expand to for given cloud drive type
 	GetMaxDriveSize(request *MaxDriveSizeRequest) (*MaxDriveSizeResponse, error)
+	// ValidateStorageSpec checks if the given spec can be satisfied by the decision
+	// matrix and returns an error naming the unsatisfiable constraint if not
+	ValidateStorageSpec(spec *StorageSpec) error
 }
 
 var (
@@ -219,6 +335,118 @@ func RegisterStorageManager(
 	return nil
 }
 
+// DiskTypeLister is implemented by the cloud providers which can list the disk
+// types available in a region
+type DiskTypeLister interface {
+	// ListDiskTypes returns a decision matrix row for each disk type, or size
+	// tier of a disk type, available in the given region. Only the drive type,
+	// the size and IOPS limits and the priority of the rows are set.
+	ListDiskTypes(region string) ([]StorageDecisionMatrixRow, error)
+}
+
+// BuildDecisionMatrix builds a storage decision matrix for the given region from
+// the disk types listed by the cloud provider of ops, looking through any
+// wrappers, and validates it. The matrix can seed or cross-check a hand-written
+// decision matrix.
+func BuildDecisionMatrix(ops Ops, region string) (*StorageDecisionMatrix, error) {
+	lister, ok := diskTypeLister(ops)
+	if !ok {
+		return nil, &ErrNotSupported{
+			Operation: "BuildDecisionMatrix",
+			Reason:    fmt.Sprintf("the disk types of %s cannot be listed", ops.Name()),
+		}
+	}
+	rows, err := lister.ListDiskTypes(region)
+	if err != nil {
+		return nil, err
+	}
+
+	dm := &StorageDecisionMatrix{}
+	for _, row := range rows {
+		row.InstanceType = "*"
+		row.InstanceMaxDrives = DefaultInstanceMaxDrives
+		row.InstanceMinDrives = DefaultInstanceMinDrives
+		row.Region = region
+		dm.Rows = append(dm.Rows, row)
+	}
+	sort.SliceStable(dm.Rows, func(i, j int) bool {
+		if dm.Rows[i].DriveType != dm.Rows[j].DriveType {
+			return dm.Rows[i].DriveType < dm.Rows[j].DriveType
+		}
+		return dm.Rows[i].MaxSize < dm.Rows[j].MaxSize
+	})
+	if err := dm.Validate(); err != nil {
+		return nil, fmt.Errorf("built an invalid decision matrix for region %s: %v", region, err)
+	}
+	return dm, nil
+}
+
+// diskTypeLister returns the cloud provider of ops if it can list its disk
+// types, looking through any wrappers
+func diskTypeLister(ops Ops) (DiskTypeLister, bool) {
+	for {
+		if l, ok := ops.(DiskTypeLister); ok {
+			return l, true
+		}
+		w, ok := ops.(unwrapper)
+		if !ok {
+			return nil, false
+		}
+		ops = w.Unwrap()
+	}
+}
+
+// Validate returns an error describing the first row of the decision matrix
+// which can never be selected or which duplicates the drive type and IOPS
+// range of an earlier row. Rows with the same drive type and IOPS range are
+// only duplicates if they also cover the same sizes, instance type and region,
+// as matrices list the size tiers of a drive type as separate rows.
+func (dm *StorageDecisionMatrix) Validate() error {
+	type rowKey struct {
+		driveType, instanceType, region    string
+		minIOPS, maxIOPS, minSize, maxSize uint64
+	}
+	seen := make(map[rowKey]int, len(dm.Rows))
+	for i, row := range dm.Rows {
+		if len(row.DriveType) == 0 {
+			return fmt.Errorf("row %d: drive_type is empty", i)
+		}
+		if row.MaxSize == 0 {
+			return fmt.Errorf("row %d (%s): max_size is 0", i, row.DriveType)
+		}
+		if row.MinSize > row.MaxSize {
+			return fmt.Errorf("row %d (%s): min_size %d is greater than max_size %d",
+				i, row.DriveType, row.MinSize, row.MaxSize)
+		}
+		if row.MaxIOPS > 0 && row.MinIOPS > row.MaxIOPS {
+			return fmt.Errorf("row %d (%s): min_iops %d is greater than max_iops %d",
+				i, row.DriveType, row.MinIOPS, row.MaxIOPS)
+		}
+		if row.InstanceMinDrives > row.InstanceMaxDrives {
+			return fmt.Errorf("row %d (%s): instance_min_drives %d is greater than instance_max_drives %d",
+				i, row.DriveType, row.InstanceMinDrives, row.InstanceMaxDrives)
+		}
+		if row.Priority < 0 {
+			return fmt.Errorf("row %d (%s): priority %d is negative", i, row.DriveType, row.Priority)
+		}
+		key := rowKey{
+			driveType:    row.DriveType,
+			instanceType: row.InstanceType,
+			region:       row.Region,
+			minIOPS:      row.MinIOPS,
+			maxIOPS:      row.MaxIOPS,
+			minSize:      row.MinSize,
+			maxSize:      row.MaxSize,
+		}
+		if j, ok := seen[key]; ok {
+			return fmt.Errorf("row %d (%s): duplicates the drive type and IOPS range %d-%d of row %d",
+				i, row.DriveType, row.MinIOPS, row.MaxIOPS, j)
+		}
+		seen[key] = i
+	}
+	return nil
+}
+
 // FilterByDriveType filters out the rows which do not match the requested drive type.
 func (dm *StorageDecisionMatrix) FilterByDriveType(requestedDriveType string) *StorageDecisionMatrix {
 	var filteredRows []StorageDecisionMatrixRow
@@ -233,6 +461,76 @@ func (dm *StorageDecisionMatrix) FilterByDriveType(requestedDriveType string) *S
 	return dm
 }
 
+// FilterByZoneAvailability filters out the rows whose drive type is not
+// available in all of the given zones. zoneDriveTypes maps each zone to the
+// drive types available in it. No rows are filtered out if it is empty.
+func (dm *StorageDecisionMatrix) FilterByZoneAvailability(zoneDriveTypes map[string][]string) *StorageDecisionMatrix {
+	var filteredRows []StorageDecisionMatrixRow
+	if len(zoneDriveTypes) > 0 {
+		for _, row := range dm.Rows {
+			if driveTypeAvailableInZones(row.DriveType, zoneDriveTypes) {
+				filteredRows = append(filteredRows, row)
+			}
+		}
+		dm.Rows = filteredRows
+	}
+	return dm
+}
+
+func driveTypeAvailableInZones(driveType string, zoneDriveTypes map[string][]string) bool {
+	for _, driveTypes := range zoneDriveTypes {
+		available := false
+		for _, t := range driveTypes {
+			if t == driveType {
+				available = true
+				break
+			}
+		}
+		if !available {
+			return false
+		}
+	}
+	return true
+}
+
+// FilterByInstanceType filters out the rows for a different instance type than
+// the requested one. Rows with an empty or "*" instance type apply to all
+// instance types. No rows are filtered out if requestedInstanceType is empty.
+func (dm *StorageDecisionMatrix) FilterByInstanceType(requestedInstanceType string) *StorageDecisionMatrix {
+	var filteredRows []StorageDecisionMatrixRow
+	if len(requestedInstanceType) > 0 {
+		for _, row := range dm.Rows {
+			if columnMatches(row.InstanceType, requestedInstanceType) {
+				filteredRows = append(filteredRows, row)
+			}
+		}
+		dm.Rows = filteredRows
+	}
+	return dm
+}
+
+// FilterByRegion filters out the rows for a different region than the
+// requested one. Rows with an empty or "*" region apply to all regions. No rows
+// are filtered out if requestedRegion is empty.
+func (dm *StorageDecisionMatrix) FilterByRegion(requestedRegion string) *StorageDecisionMatrix {
+	var filteredRows []StorageDecisionMatrixRow
+	if len(requestedRegion) > 0 {
+		for _, row := range dm.Rows {
+			if columnMatches(row.Region, requestedRegion) {
+				filteredRows = append(filteredRows, row)
+			}
+		}
+		dm.Rows = filteredRows
+	}
+	return dm
+}
+
+// columnMatches returns true if the value of a decision matrix column applies
+// to the requested value
+func columnMatches(value, requested string) bool {
+	return len(value) == 0 || value == "*" || value == requested
+}
+
 // FilterByMinIOPS filters out the rows whose minIOPS are less than the requested IOPS.
 func (dm *StorageDecisionMatrix) FilterByMinIOPS(requestedIOPS uint64) *StorageDecisionMatrix {
 	var filteredRows []StorageDecisionMatrixRow
@@ -32,9 +32,69 @@ const (
 	Pure = "pure"
 	// Oracle provider
 	Oracle = "oracle"
+	// Fake is the in-memory provider for testing
+	Fake = "fake"
 
 	// DryRunOption is the key to tell if dry run the request
 	DryRunOption = "dry-run"
+	// SnapshotWaitOption is the key to tell if Snapshot should block until the
+	// snapshot is ready. Defaults to true.
+	SnapshotWaitOption = "wait"
+	// ThinProvisioningOption is the key to tell if Create should thinly
+	// provision the drive. It carries StoragePoolSpec.ThinProvisioning and is
+	// ignored by providers which do not support thin provisioning.
+	ThinProvisioningOption = "thin-provisioning"
+	// ThroughputOption is the key to request a new provisioned throughput in
+	// MiB/s when expanding a volume. It is only honoured by providers and
+	// volume types which allow adjusting throughput independently.
+	ThroughputOption = "throughput"
+	// ScaleIopsOption is the key to tell Expand to grow the provisioned IOPS
+	// and throughput of the volume in proportion to its size, keeping the
+	// per-GiB performance of the volume within the limits of its type.
+	// Defaults to false, which leaves the provisioned performance unchanged.
+	ScaleIopsOption = "scaleIops"
+	// AppConsistentOption is the key to tell Snapshot to coordinate with the
+	// guest agent of the instance the volume is attached to for an
+	// application-consistent snapshot. Providers which cannot coordinate with
+	// the guest fall back to a crash-consistent snapshot. Defaults to false.
+	AppConsistentOption = "appConsistent"
+	// EncryptedOption is the key to tell Create to encrypt the drive at rest.
+	// Providers which always encrypt drives at rest accept it as is. Defaults
+	// to false, which leaves the encryption of the template unchanged.
+	EncryptedOption = "encrypted"
+	// KmsKeyIDOption is the key to give Create the customer managed key to
+	// encrypt the drive with: a KMS key ID or ARN on AWS, a disk encryption
+	// set ID on Azure or a Cloud KMS key name on GCE. It implies
+	// EncryptedOption.
+	KmsKeyIDOption = "kmsKeyID"
+	// WaitRemoteOption is the key to tell Attach to wait for a drive attached
+	// to a remote instance to be detached from it, e.g. as the instance is
+	// failing over, instead of failing with ErrVolAttachedOnRemoteNode. The
+	// wait is bounded by the operations timeout. Defaults to false.
+	WaitRemoteOption = "waitRemote"
+	// MultiAttachOption is the key to tell Create to enable multi-attach on
+	// the drive so it can be attached to several instances at once. Only
+	// providers and drive types which support it honour it, e.g. io1 and io2
+	// volumes on AWS. Defaults to false.
+	MultiAttachOption = "multiAttach"
+
+	// VolumeLockTagKey is the tag LockVolume applies to a volume to record the
+	// owner holding the lock. It is a valid label key on every provider.
+	VolumeLockTagKey = "cloudops-locked-by"
+	// SnapshotAppConsistentTagKey is the tag Snapshot applies to a snapshot
+	// taken with AppConsistentOption to record whether application consistency
+	// was achieved. Its value is "true" or "false".
+	SnapshotAppConsistentTagKey = "cloudops-app-consistent"
+	// VolumeManagedTagKey is the tag AdoptVolume applies to a volume created
+	// outside cloudops to mark it as managed by cloudops. Its value is "true".
+	VolumeManagedTagKey = "cloudops-managed"
+
+	// VolumeSourceSnapshot is the source type of volumes created from a snapshot
+	VolumeSourceSnapshot = "snapshot"
+	// VolumeSourceImage is the source type of volumes created from an image
+	VolumeSourceImage = "image"
+	// VolumeSourceClone is the source type of volumes cloned from another volume
+	VolumeSourceClone = "clone"
 )
 
 // CloudResourceInfo provides metadata information on a cloud resource.
@@ -65,6 +125,43 @@ type InstanceGroupInfo struct {
 	Zones []string
 }
 
+// RollOpts are the options to roll the instances of an instance group
+type RollOpts struct {
+	// MaxSurge is the number of additional instances which can be created
+	// while rolling the instance group
+	MaxSurge int64
+	// MaxUnavailable is the number of instances which can be unavailable
+	// while rolling the instance group
+	MaxUnavailable int64
+	// MinHealthyPercentage is the percentage of the instance group which must
+	// remain healthy while rolling the instance group. Only used for AWS.
+	MinHealthyPercentage int64
+	// Timeout is the time to wait for the roll to complete
+	Timeout time.Duration
+}
+
+// TaintEffect is the effect of a node taint on pods which do not tolerate it
+type TaintEffect string
+
+const (
+	// TaintEffectNoSchedule does not schedule new pods on the node
+	TaintEffectNoSchedule TaintEffect = "NoSchedule"
+	// TaintEffectPreferNoSchedule avoids scheduling new pods on the node
+	TaintEffectPreferNoSchedule TaintEffect = "PreferNoSchedule"
+	// TaintEffectNoExecute evicts running pods from the node
+	TaintEffectNoExecute TaintEffect = "NoExecute"
+)
+
+// NodeTaint is a kubernetes taint applied to the nodes of an instance group
+type NodeTaint struct {
+	// Key of the taint
+	Key string
+	// Value of the taint
+	Value string
+	// Effect of the taint
+	Effect TaintEffect
+}
+
 // InstanceInfo encapsulates info for a cloud instance
 type InstanceInfo struct {
 	CloudResourceInfo
@@ -72,6 +169,80 @@ type InstanceInfo struct {
 	State InstanceState
 }
 
+// NetworkInfo encapsulates the network configuration of a cloud instance
+type NetworkInfo struct {
+	// Network is the VPC/VNet/VCN of the instance's primary network interface
+	Network string
+	// Subnet is the subnet of the instance's primary network interface
+	Subnet string
+	// PrivateIPs are the private IP addresses assigned to the instance
+	PrivateIPs []string
+}
+
+// SnapshotInfo encapsulates info for a cloud volume snapshot
+type SnapshotInfo struct {
+	CloudResourceInfo
+	// VolumeID is the ID of the volume the snapshot was taken from
+	VolumeID string
+	// SizeInGiB is the size of the source volume in GiB
+	SizeInGiB uint64
+	// State is the provider specific state of the snapshot
+	State string
+	// CreationTime is the time when the snapshot was started
+	CreationTime time.Time
+}
+
+// SnapshotOptions are the options of a snapshot taken with SnapshotWithOptions
+type SnapshotOptions struct {
+	// Name of the snapshot. A unique name is generated if it is empty.
+	Name string
+	// Labels to apply to the snapshot
+	Labels map[string]string
+	// Options are the provider options of the snapshot, e.g. SnapshotWaitOption
+	Options map[string]string
+}
+
+// VolumeSpec is the provider neutral description of a volume in the storage
+// layout of an instance
+type VolumeSpec struct {
+	// SizeInGiB is the size of the volume in GiB
+	SizeInGiB uint64
+	// Type is the provider specific type of the volume, e.g. pd-ssd or Premium_LRS
+	Type string
+	// IOPS is the provisioned IOPS of the volume, if the type supports it
+	IOPS uint64
+	// Throughput is the provisioned throughput of the volume in MiB/s, if the
+	// type supports it
+	Throughput uint64
+	// Labels are the labels of the volume
+	Labels map[string]string
+}
+
+// VolumePressureInfo is the provisioned and consumed size of a volume attached
+// to an instance, to be used as a trigger to expand the volume
+type VolumePressureInfo struct {
+	// VolumeID is the ID of the volume
+	VolumeID string
+	// ProvisionedBytes is the size of the volume in bytes
+	ProvisionedBytes uint64
+	// ConsumedBytes is the space the volume consumes on the backing storage,
+	// or nil if the cloud provider does not report it
+	ConsumedBytes *uint64
+	// ThinProvisioned is true if the volume is thin provisioned
+	ThinProvisioned bool
+}
+
+// VolumeIterator iterates over the volumes listed by ListVolumesPaged, fetching
+// them from the cloud provider a page at a time.
+type VolumeIterator interface {
+	// Next returns the next volume, in the same form as the volumes returned
+	// by Enumerate. It returns false once all the volumes have been returned
+	// or fetching a page failed, in which case Err returns the error.
+	Next() (interface{}, bool)
+	// Err returns the error which ended the iteration, if any.
+	Err() error
+}
+
 // InstanceState is an enum for the current state of a compute instance
 type InstanceState uint64
 
@@ -88,15 +259,33 @@ const (
 	InstanceStateStarting
 )
 
+// Normalized states of a volume. The provider specific states of volumes are
+// mapped to these by WaitForVolumeState.
+const (
+	// VolumeStateCreating volume is being created, or restored from a snapshot
+	VolumeStateCreating = "Creating"
+	// VolumeStateAvailable volume is ready and not attached to an instance
+	VolumeStateAvailable = "Available"
+	// VolumeStateAttached volume is ready and attached to an instance
+	VolumeStateAttached = "Attached"
+	// VolumeStateDeleting volume is being deleted or has been deleted
+	VolumeStateDeleting = "Deleting"
+	// VolumeStateError volume failed and cannot be used
+	VolumeStateError = "Error"
+)
+
 // Compute interface to manage compute instances.
 type Compute interface {
 	// DeleteInstance deletes the instance
 	DeleteInstance(instanceID string, zone string, timeout time.Duration) error
 	// InstanceID of instance where command is executed.
 	InstanceID() string
-	// InspectInstance inspects the node with the given instance ID
-	// TODO: Add support for taking zone as input to inspect instance in any zone
+	// InspectInstance inspects the node with the given instance ID in the zone
+	// of the local instance
 	InspectInstance(instanceID string) (*InstanceInfo, error)
+	// InspectInstanceInZone inspects the node with the given instance ID in the
+	// given zone
+	InspectInstanceInZone(instanceID, zone string) (*InstanceInfo, error)
 	// InspectInstanceGroupForInstance inspects the instance group to which the
 	// cloud instance with given ID belongs
 	InspectInstanceGroupForInstance(instanceID string) (*InstanceGroupInfo, error)
@@ -109,11 +298,19 @@ type Compute interface {
 		timeout time.Duration) error
 	// GetInstanceGroupSize returns current node count of given instance group
 	GetInstanceGroupSize(instanceGroupID string) (int64, error)
+	// GetInstanceGroupZones returns the zones the given instance group spans
+	GetInstanceGroupZones(instanceGroupID string) ([]string, error)
+	// ListInstanceGroupMembers returns the IDs of the instances in the given
+	// instance group
+	ListInstanceGroupMembers(instanceGroupID string) ([]string, error)
 	// GetClusterSizeForInstance returns current node count in given cluster
 	// This count is total node count across all availability zones
 	GetClusterSizeForInstance(instanceID string) (int64, error)
 	// SetClusterVersion sets desired version for the cluster
 	SetClusterVersion(version string, timeout time.Duration) error
+	// GetClusterVersion returns the control plane version of the cluster and
+	// the version of the node pool of the instance with the given ID
+	GetClusterVersion(instanceID string) (controlPlane, nodePool string, err error)
 	// SetInstanceGroupVersion sets desired node group version
 	SetInstanceGroupVersion(instanceGroupID string,
 		version string,
@@ -123,25 +320,66 @@ type Compute interface {
 		upgradeStrategy string,
 		timeout time.Duration,
 		surgeSetting string) error
+	// RollInstanceGroup replaces the instances of the given instance group with
+	// new instances from its current template and waits for the roll to complete
+	RollInstanceGroup(instanceGroupID string, opts RollOpts) error
+	// SetInstanceGroupNodeLabels sets the kubernetes labels of the nodes in the
+	// given instance group
+	SetInstanceGroupNodeLabels(instanceGroupID string,
+		labels map[string]string,
+		timeout time.Duration) error
+	// SetInstanceGroupNodeTaints sets the kubernetes taints of the nodes in the
+	// given instance group
+	SetInstanceGroupNodeTaints(instanceGroupID string,
+		taints []NodeTaint,
+		timeout time.Duration) error
+	// GetNetworkInfo returns the network, subnet and private IPs of the instance
+	// with the given ID
+	GetNetworkInfo(instanceID string) (*NetworkInfo, error)
+	// GetVMDiskBudget returns the IOPS and throughput (in MiB/s) budget of the
+	// instance with the given ID which the disks with provisioned performance
+	// attached to it draw from, and the IOPS of the budget already provisioned
+	// to the attached disks.
+	GetVMDiskBudget(instanceID string) (iopsBudget, throughputBudget uint64, used uint64, err error)
 }
 
 // Storage interface to manage storage operations.
 type Storage interface {
 	// Create volume based on input template volume and also apply given labels.
 	Create(template interface{}, labels map[string]string, options map[string]string) (interface{}, error)
+	// CloneVolume creates a volume from the current contents of the source
+	// volume based on input template volume and also applies given labels.
+	CloneVolume(sourceVolumeID string, template interface{}, labels map[string]string) (interface{}, error)
 	// GetDeviceID returns ID/Name of the given device/disk or snapshot
 	GetDeviceID(template interface{}) (string, error)
 	// Attach volumeID, accepts attachoOptions as opaque data
-	// Return attach path.
+	// Return attach path. Attach returns once the cloud provider reports the
+	// attachment as attached, not just once the device path resolves.
 	Attach(volumeID string, options map[string]string) (string, error)
-	// IsVolumeReadyToExpand pre-checks if a pool of volumes are in a state that can
+	// AttachByInstanceID attaches volumeID to the given instance, accepts
+	// attach options as opaque data. Returns the path the volume's device is
+	// expected at on that instance, which is only resolved locally if the
+	// instance is the local one.
+	AttachByInstanceID(instanceID, volumeID string, options map[string]string) (string, error)
+	// WaitForVolumeState waits until the volume is in the given normalized
+	// state, one of the VolumeState constants. It fails early if the volume
+	// goes into VolumeStateError while waiting for another state.
+	WaitForVolumeState(volumeID string, desiredState string, timeout time.Duration) error
+	// AreVolumesReadyToExpand pre-checks if a pool of volumes are in a state that can
 	// be modified. Should be called before sending an expand request to the cloud provider.
+	// If some of the volumes are not ready, it returns false with an
+	// ErrVolumesNotReadyToExpand giving the reason for each of them.
 	AreVolumesReadyToExpand(volumeIDs []*string) (bool, error)
 	// Expand expands the provided device from the existing size to the new size
 	// It returns the new size of the device. It is a blocking API where it will
 	// only return once the requested size is validated with the cloud provider or
 	// the number of retries prescribed by the cloud provider are exhausted.
 	Expand(volumeID string, newSizeInGiB uint64, options map[string]string) (uint64, error)
+	// ModifyVolumeType changes the type of the given volume in place, keeping
+	// its size, e.g. to migrate a gp2 volume to gp3. iops and throughput (in
+	// MiB/s) are optional and are only set if the new type supports them. It
+	// blocks until the modification has taken effect.
+	ModifyVolumeType(volumeID, newType string, iops, throughput *int64) error
 	// Detach volumeID.
 	Detach(volumeID string, options map[string]string) error
 	// DetachFrom detaches the disk/volume with given ID from the given instance ID
@@ -171,6 +409,9 @@ type Storage interface {
 	DevicePath(volumeID string) (string, error)
 	// Snapshot the volume with given volumeID
 	Snapshot(volumeID string, readonly bool, options map[string]string) (interface{}, error)
+	// SnapshotWithOptions snapshots the volume with given volumeID, naming and
+	// labeling the snapshot as given in opts
+	SnapshotWithOptions(volumeID string, readonly bool, opts SnapshotOptions) (interface{}, error)
 	// SnapshotDelete deletes the snapshot with given ID
 	SnapshotDelete(snapID string, options map[string]string) error
 	// ApplyTags will apply given labels/tags on the given volume
@@ -179,6 +420,98 @@ type Storage interface {
 	RemoveTags(volumeID string, labels map[string]string, options map[string]string) error
 	// Tags will list the existing labels/tags on the given volume
 	Tags(volumeID string) (map[string]string, error)
+	// ReconcileDataDisks removes the data disk entries on the given instance that
+	// point at disks which no longer exist and returns the LUNs that were freed
+	ReconcileDataDisks(instanceID string) ([]string, error)
+	// GetEffectivePerformance returns the IOPS and throughput (in MiB/s) actually
+	// provisioned for the given volume, which may differ from the requested values
+	// if the cloud provider clamped them. These are the limits the cloud provider
+	// throttles the volume at.
+	GetEffectivePerformance(volumeID string) (iops, throughput uint64, err error)
+	// ConfigureReplication configures asynchronous replication of the given volume
+	// to the target region
+	ConfigureReplication(volumeID, targetRegion string, options map[string]string) error
+	// StopReplication stops the replication of the given volume
+	StopReplication(volumeID string) error
+	// EnumerateSnapshots returns the snapshots of the given volumes that match
+	// the given labels. volumeIDs and labels can be nil.
+	EnumerateSnapshots(volumeIDs []*string, labels map[string]string) ([]*SnapshotInfo, error)
+	// ListSnapshots returns the snapshots that match the given labels. labels
+	// can be nil.
+	//
+	// Deprecated: Use EnumerateSnapshots(nil, labels) instead.
+	ListSnapshots(labels map[string]string) ([]*SnapshotInfo, error)
+	// InspectSnapshot returns the snapshot with given ID. An ErrVolNotFound
+	// StorageError is returned if the snapshot does not exist.
+	InspectSnapshot(snapID string) (*SnapshotInfo, error)
+	// ReportCapacityByLabel returns the provisioned capacity in GiB of the disks
+	// grouped by the value of the given label. Disks without the label are skipped.
+	ReportCapacityByLabel(labelKey string) (map[string]uint64, error)
+	// UpdateAttachmentCaching changes the caching mode of the given disk attached
+	// to the given instance by detaching and re-attaching it
+	UpdateAttachmentCaching(instanceID, diskName, caching string) error
+	// GetVolumeQoS returns the IOPS and throughput (in MiB/s) limits the cloud
+	// provider enforces on the given volume
+	GetVolumeQoS(volumeID string) (iopsLimit, throughputLimit uint64, err error)
+	// ExpandMany expands the given volumes to the new size concurrently and
+	// returns the new size of each expanded volume. If some of the volumes fail
+	// to expand, the sizes of the other volumes are returned along with an
+	// ErrExpandMany.
+	ExpandMany(volumeIDs []string, newSizeInGiB uint64, options map[string]string) (map[string]uint64, error)
+	// DeleteVolumes deletes the given volumes concurrently and returns the
+	// errors of the volumes which failed to delete keyed by volume ID. The
+	// returned error is only non-nil if the provider could not be reached or
+	// rejected the credentials, in which case the remaining deletes are skipped.
+	DeleteVolumes(volumeIDs []string) (map[string]error, error)
+	// LockVolume locks the given volume for the given owner by tagging it with
+	// VolumeLockTagKey. It returns false if the volume is already locked by a
+	// different owner. Locking a volume the owner already holds succeeds.
+	LockVolume(volumeID, owner string) (bool, error)
+	// UnlockVolume releases the lock the given owner holds on the volume. It
+	// fails with ErrVolumeLocked if the volume is locked by a different owner.
+	UnlockVolume(volumeID, owner string) error
+	// GetStorageLayout returns the specs of the managed data volumes attached
+	// to the given instance, in the order they are attached
+	GetStorageLayout(instanceID string) ([]VolumeSpec, error)
+	// ProvisionStorageLayout creates a volume matching each of the given specs
+	// and returns the created volumes. The given labels are applied on top of
+	// the labels of each spec. If any of the volumes fail to create, the volumes
+	// created so far are deleted.
+	ProvisionStorageLayout(specs []VolumeSpec, labels map[string]string) ([]interface{}, error)
+	// GetVolumeLineage returns the type and ID of the source the given volume
+	// was created from. sourceType is one of VolumeSourceSnapshot,
+	// VolumeSourceImage or VolumeSourceClone, and is empty if the volume was
+	// not created from a source.
+	GetVolumeLineage(volumeID string) (sourceType string, sourceID string, err error)
+	// BatchInspect returns the given volumes keyed by volume ID. Providers list
+	// the volumes in bulk where possible instead of fetching each volume. An
+	// error is returned if any of the volumes does not exist.
+	BatchInspect(volumeIds []*string) (map[string]interface{}, error)
+	// AdoptVolume brings a volume created outside cloudops under its
+	// management by applying VolumeManagedTagKey along with the given labels.
+	// The volume must be detached or attached to the local instance, otherwise
+	// ErrVolAttachedOnRemoteNode is returned.
+	AdoptVolume(volumeID string, labels map[string]string) error
+	// DeleteSnapshotChain deletes the given snapshots, deleting the
+	// incremental snapshots which depend on a snapshot before it. If some of
+	// the snapshots fail to delete, an ErrSnapshotChainDelete is returned.
+	DeleteSnapshotChain(snapIDs []string) error
+	// GetStoragePressure returns the provisioned and, where the cloud
+	// provider reports it, consumed size of the volumes attached to the given
+	// instance.
+	GetStoragePressure(instanceID string) ([]VolumePressureInfo, error)
+	// CheckVolumeQuota checks that the given storage pools can be created
+	// without exceeding the cloud provider's quotas in the region of the
+	// instance. Each spec needs DriveCount drives of DriveCapacityGiB on each
+	// of InstancesPerZone instances in each of its ZoneCount zones. An
+	// ErrVolumeQuotaExceeded listing the quotas which would be exceeded is
+	// returned if they cannot.
+	CheckVolumeQuota(required []*StoragePoolSpec) error
+	// ListVolumesPaged returns an iterator over the volumes with the given
+	// labels, which fetches pageSize volumes at a time instead of loading all
+	// of them like Enumerate. pageSize is a hint, providers bound it to what
+	// their API supports and a pageSize of 0 uses the provider's default.
+	ListVolumesPaged(labels map[string]string, pageSize int) (VolumeIterator, error)
 }
 
 // Ops interface to perform basic cloud operations.
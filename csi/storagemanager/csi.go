@@ -30,13 +30,14 @@ func newCSIStorageManager(
 func (a *csiStorageManager) GetStorageDistribution(
 	request *cloudops.StorageDistributionRequest,
 ) (*cloudops.StorageDistributionResponse, error) {
+	decisionMatrix := storagedistribution.FilterForRequest(a.decisionMatrix, request)
 	response := &cloudops.StorageDistributionResponse{}
-	for _, userRequest := range request.UserStorageSpec {
+	for _, userRequest := range storagedistribution.UserStorageSpecs(request) {
 		// for for request, find how many instances per zone needs to have storage
 		// and the storage spec for each of them
-		instStorage, instancesPerZone, _, err :=
+		instStorage, instancesPerZone, row, err :=
 			storagedistribution.GetStorageDistributionForPool(
-				a.decisionMatrix,
+				decisionMatrix,
 				userRequest,
 				request.InstancesPerZone,
 				request.ZoneCount,
@@ -51,8 +52,12 @@ func (a *csiStorageManager) GetStorageDistribution(
 				DriveType:        instStorage.DriveType,
 				InstancesPerZone: instancesPerZone,
 				DriveCount:       instStorage.DriveCount,
+				ThinProvisioning: instStorage.ThinProvisioning,
 			},
 		)
+		if request.IncludeDecisionMatrixRows {
+			response.DecisionMatrixRows = append(response.DecisionMatrixRows, row)
+		}
 	}
 	return response, nil
 }
@@ -69,6 +74,10 @@ func (a *csiStorageManager) GetMaxDriveSize(
 	return resp, err
 }
 
+func (a *csiStorageManager) ValidateStorageSpec(spec *cloudops.StorageSpec) error {
+	return storagedistribution.ValidateStorageSpec(spec, a.decisionMatrix)
+}
+
 func init() {
 	cloudops.RegisterStorageManager(cloudops.CSI, newCSIStorageManager)
 }
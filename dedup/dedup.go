@@ -0,0 +1,169 @@
+// Package dedup collapses concurrent identical cloud operations made through
+// cloudops.Ops into a single cloud provider API call.
+package dedup
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/libopenstorage/cloudops"
+)
+
+// call is an in-flight or completed operation
+type call struct {
+	wg   sync.WaitGroup
+	val  interface{}
+	err  error
+	dups int
+}
+
+// group runs at most one operation per key at a time. Callers of an operation
+// already in flight wait for it and share its result.
+type group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// do runs fn for the given key unless an operation for the key is already in
+// flight, in which case it waits for that operation and returns its result
+func (g *group) do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	if c, ok := g.calls[key]; ok {
+		c.dups++
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err
+	}
+	c := &call{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	c.val, c.err = fn()
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	c.wg.Done()
+
+	return c.val, c.err
+}
+
+type dedupOps struct {
+	cloudops.Ops
+	calls group
+}
+
+// NewDedupOps returns a wrapper for the given cloudops.Ops which collapses
+// concurrent identical mutating operations, such as two creates of the same
+// disk or two attaches of the same volume, into one call to the wrapped ops.
+// The callers of the collapsed operations share its result. Operations are
+// identical if they are the same method called with the same arguments.
+// Read-only operations are passed through as is.
+func NewDedupOps(ops cloudops.Ops) cloudops.Ops {
+	return &dedupOps{Ops: ops}
+}
+
+// key returns the key of the operation with the given arguments
+func key(operation string, args ...interface{}) (string, error) {
+	parts := []string{operation}
+	for _, arg := range args {
+		b, err := json.Marshal(arg)
+		if err != nil {
+			return "", fmt.Errorf("failed to build the key of %s: %v", operation, err)
+		}
+		parts = append(parts, string(b))
+	}
+	return strings.Join(parts, "/"), nil
+}
+
+func (d *dedupOps) Create(
+	template interface{},
+	labels map[string]string,
+	options map[string]string,
+) (interface{}, error) {
+	k, err := key("Create", template, labels, options)
+	if err != nil {
+		// the operation can't be told apart from others, don't collapse it
+		return d.Ops.Create(template, labels, options)
+	}
+	return d.calls.do(k, func() (interface{}, error) {
+		return d.Ops.Create(template, labels, options)
+	})
+}
+
+func (d *dedupOps) Attach(volumeID string, options map[string]string) (string, error) {
+	k, err := key("Attach", volumeID, options)
+	if err != nil {
+		return d.Ops.Attach(volumeID, options)
+	}
+	devicePath, err := d.calls.do(k, func() (interface{}, error) {
+		return d.Ops.Attach(volumeID, options)
+	})
+	return devicePath.(string), err
+}
+
+func (d *dedupOps) AttachByInstanceID(instanceID, volumeID string, options map[string]string) (string, error) {
+	k, err := key("AttachByInstanceID", instanceID, volumeID, options)
+	if err != nil {
+		return d.Ops.AttachByInstanceID(instanceID, volumeID, options)
+	}
+	devicePath, err := d.calls.do(k, func() (interface{}, error) {
+		return d.Ops.AttachByInstanceID(instanceID, volumeID, options)
+	})
+	return devicePath.(string), err
+}
+
+func (d *dedupOps) Expand(volumeID string, newSizeInGiB uint64, options map[string]string) (uint64, error) {
+	k, err := key("Expand", volumeID, newSizeInGiB, options)
+	if err != nil {
+		return d.Ops.Expand(volumeID, newSizeInGiB, options)
+	}
+	size, err := d.calls.do(k, func() (interface{}, error) {
+		return d.Ops.Expand(volumeID, newSizeInGiB, options)
+	})
+	return size.(uint64), err
+}
+
+func (d *dedupOps) Detach(volumeID string, options map[string]string) error {
+	k, err := key("Detach", volumeID, options)
+	if err != nil {
+		return d.Ops.Detach(volumeID, options)
+	}
+	_, err = d.calls.do(k, func() (interface{}, error) {
+		return nil, d.Ops.Detach(volumeID, options)
+	})
+	return err
+}
+
+func (d *dedupOps) DetachFrom(volumeID, instanceID string) error {
+	k, err := key("DetachFrom", volumeID, instanceID)
+	if err != nil {
+		return d.Ops.DetachFrom(volumeID, instanceID)
+	}
+	_, err = d.calls.do(k, func() (interface{}, error) {
+		return nil, d.Ops.DetachFrom(volumeID, instanceID)
+	})
+	return err
+}
+
+func (d *dedupOps) Delete(volumeID string, options map[string]string) error {
+	k, err := key("Delete", volumeID, options)
+	if err != nil {
+		return d.Ops.Delete(volumeID, options)
+	}
+	_, err = d.calls.do(k, func() (interface{}, error) {
+		return nil, d.Ops.Delete(volumeID, options)
+	})
+	return err
+}
+
+// Unwrap returns the cloudops.Ops wrapped by the dedup wrapper
+func (d *dedupOps) Unwrap() cloudops.Ops {
+	return d.Ops
+}
@@ -0,0 +1,90 @@
+package dedup
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/libopenstorage/cloudops/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type disk struct {
+	Name   string
+	SizeGB int64
+}
+
+// waiters returns the number of callers waiting on the in-flight operation
+// with the given key
+func waiters(d *dedupOps, k string) int {
+	d.calls.mu.Lock()
+	defer d.calls.mu.Unlock()
+	if c, ok := d.calls.calls[k]; ok {
+		return c.dups
+	}
+	return 0
+}
+
+func TestConcurrentCreate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	template := &disk{Name: "disk1", SizeGB: 10}
+	created := &disk{Name: "disk1", SizeGB: 10}
+	release := make(chan struct{})
+
+	ops := mock.NewMockOps(ctrl)
+	ops.EXPECT().Create(template, nil, nil).DoAndReturn(
+		func(interface{}, map[string]string, map[string]string) (interface{}, error) {
+			<-release
+			return created, nil
+		}).Times(1)
+
+	d := NewDedupOps(ops).(*dedupOps)
+	k, err := key("Create", template, nil, nil)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	results := make([]interface{}, 2)
+	errs := make([]error, 2)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = d.Create(template, nil, nil)
+		}(i)
+	}
+
+	// release the cloud create once the second call waits on the first
+	require.Eventually(t, func() bool { return waiters(d, k) == 1 }, 5*time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for i := range results {
+		require.NoError(t, errs[i])
+		require.Equal(t, created, results[i])
+	}
+
+	// calls after the operation completed reach the cloud again
+	ops.EXPECT().Create(template, nil, nil).Return(created, nil).Times(1)
+	_, err = d.Create(template, nil, nil)
+	require.NoError(t, err)
+}
+
+func TestDifferentResourcesAreNotCollapsed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ops := mock.NewMockOps(ctrl)
+	ops.EXPECT().Attach("vol-1", nil).Return("/dev/xvdf", nil).Times(1)
+	ops.EXPECT().Attach("vol-2", nil).Return("/dev/xvdg", nil).Times(1)
+
+	d := NewDedupOps(ops)
+	devicePath, err := d.Attach("vol-1", nil)
+	require.NoError(t, err)
+	require.Equal(t, "/dev/xvdf", devicePath)
+	devicePath, err = d.Attach("vol-2", nil)
+	require.NoError(t, err)
+	require.Equal(t, "/dev/xvdg", devicePath)
+}
@@ -1,6 +1,10 @@
 package cloudops
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
 
 // Custom storage operation error codes.
 const (
@@ -23,6 +27,23 @@ const (
 	ErrDiskGreaterOrEqualToExpandSize
 	// ErrVolumeAttachedOnMultipleNodes is code when a volume is attached to multiple nodes
 	ErrVolumeAttachedOnMultipleNodes
+	// ErrOperationInProgress is code when an operation cannot proceed because
+	// a conflicting operation on the same resource is still in progress
+	ErrOperationInProgress
+	// ErrNoAttachSlotAvailable is code when there are no free device names or
+	// slots left on the instance to attach a volume
+	ErrNoAttachSlotAvailable
+	// ErrVolumeLocked is code when a volume is locked by a different owner
+	ErrVolumeLocked
+	// ErrInvalidStorageRequest is code when the requested storage configuration
+	// is not supported by the cloud provider
+	ErrInvalidStorageRequest
+	// ErrInvalidEncryptionKey is code when the key given to encrypt a volume
+	// does not exist or cannot be used by the cloud provider
+	ErrInvalidEncryptionKey
+	// ErrQuotaExceeded is code when the cloud provider's quota or available
+	// capacity for a resource has been exhausted
+	ErrQuotaExceeded
 )
 
 // ErrNotFound is error type when an object of Type with ID is not found
@@ -127,6 +148,26 @@ func (e *ErrCloudProviderRequestFailure) Error() string {
 	return fmt.Sprintf("Request %s returns %s", e.Request, e.Message)
 }
 
+// ErrVolumesNotReadyToExpand is returned by AreVolumesReadyToExpand when some
+// of the volumes are in a state that does not permit a resize.
+type ErrVolumesNotReadyToExpand struct {
+	// Volumes maps the IDs of the volumes which are not ready to the reason
+	Volumes map[string]string
+}
+
+func (e *ErrVolumesNotReadyToExpand) Error() string {
+	ids := make([]string, 0, len(e.Volumes))
+	for id := range e.Volumes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	reasons := make([]string, 0, len(ids))
+	for _, id := range ids {
+		reasons = append(reasons, fmt.Sprintf("%s: %s", id, e.Volumes[id]))
+	}
+	return fmt.Sprintf("volumes are not ready to expand: %s", strings.Join(reasons, ", "))
+}
+
 // ErrInvalidMaxDriveSizeRequest is returned when an unsupported or invalid request
 // is sent to get the max drive size
 type ErrInvalidMaxDriveSizeRequest struct {
@@ -154,3 +195,99 @@ func (e *ErrMaxDriveSizeCandidateNotFound) Error() string {
 	return fmt.Sprintf("could not find a suitable max drive size candidate: %s Request: %v",
 		e.Reason, e.Request)
 }
+
+const (
+	// StorageSpecConstraintCapacity is the capacity constraint of a StorageSpec
+	StorageSpecConstraintCapacity = "capacity"
+	// StorageSpecConstraintDriveType is the drive type constraint of a StorageSpec
+	StorageSpecConstraintDriveType = "drive type"
+	// StorageSpecConstraintIOPS is the IOPS constraint of a StorageSpec
+	StorageSpecConstraintIOPS = "IOPS"
+)
+
+// ErrUnsatisfiableStorageSpec is returned when a StorageSpec cannot be satisfied
+// by the storage decision matrix
+type ErrUnsatisfiableStorageSpec struct {
+	// Spec is the spec that cannot be satisfied
+	Spec *StorageSpec
+	// Constraint is the constraint of the spec that cannot be satisfied
+	Constraint string
+	// Reason is the reason why the constraint cannot be satisfied
+	Reason string
+}
+
+func (e *ErrUnsatisfiableStorageSpec) Error() string {
+	return fmt.Sprintf("unsatisfiable %s in storage spec: %s Spec: %v",
+		e.Constraint, e.Reason, e.Spec)
+}
+
+// ErrExpandMany is returned when some of the volumes of a bulk expand failed
+// to expand
+type ErrExpandMany struct {
+	// Errors are the expand errors keyed by volume ID
+	Errors map[string]error
+}
+
+func (e *ErrExpandMany) Error() string {
+	volumeIDs := make([]string, 0, len(e.Errors))
+	for volumeID := range e.Errors {
+		volumeIDs = append(volumeIDs, volumeID)
+	}
+	sort.Strings(volumeIDs)
+
+	errs := make([]string, 0, len(volumeIDs))
+	for _, volumeID := range volumeIDs {
+		errs = append(errs, fmt.Sprintf("%s: %v", volumeID, e.Errors[volumeID]))
+	}
+	return fmt.Sprintf("failed to expand %d volume(s): %s", len(errs), strings.Join(errs, "; "))
+}
+
+// ErrSnapshotChainDelete is returned when some of the snapshots of a
+// DeleteSnapshotChain failed to delete
+type ErrSnapshotChainDelete struct {
+	// Errors are the delete errors keyed by snapshot ID
+	Errors map[string]error
+}
+
+func (e *ErrSnapshotChainDelete) Error() string {
+	snapIDs := make([]string, 0, len(e.Errors))
+	for snapID := range e.Errors {
+		snapIDs = append(snapIDs, snapID)
+	}
+	sort.Strings(snapIDs)
+
+	errs := make([]string, 0, len(snapIDs))
+	for _, snapID := range snapIDs {
+		errs = append(errs, fmt.Sprintf("%s: %v", snapID, e.Errors[snapID]))
+	}
+	return fmt.Sprintf("failed to delete %d snapshot(s): %s", len(errs), strings.Join(errs, "; "))
+}
+
+// QuotaBreach is a cloud provider quota which would be exceeded by the
+// requested storage
+type QuotaBreach struct {
+	// Quota is the cloud provider's name of the quota, e.g. SSD_TOTAL_GB
+	Quota string
+	// Limit is the limit of the quota
+	Limit uint64
+	// Usage is the current usage of the quota
+	Usage uint64
+	// Required is the amount of the quota needed by the requested storage
+	Required uint64
+}
+
+// ErrVolumeQuotaExceeded is returned by CheckVolumeQuota when the requested
+// storage would exceed some of the cloud provider's quotas
+type ErrVolumeQuotaExceeded struct {
+	// Quotas are the quotas which would be exceeded
+	Quotas []QuotaBreach
+}
+
+func (e *ErrVolumeQuotaExceeded) Error() string {
+	quotas := make([]string, 0, len(e.Quotas))
+	for _, q := range e.Quotas {
+		quotas = append(quotas, fmt.Sprintf("%s (limit: %d, usage: %d, required: %d)",
+			q.Quota, q.Limit, q.Usage, q.Required))
+	}
+	return fmt.Sprintf("volume quota exceeded: %s", strings.Join(quotas, ", "))
+}
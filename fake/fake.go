@@ -0,0 +1,603 @@
+// Package fake provides an in-memory implementation of cloudops.Ops to test
+// the layers above cloudops without a cloud account.
+package fake
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libopenstorage/cloudops"
+	"github.com/libopenstorage/cloudops/unsupported"
+)
+
+// devicePathPrefix is the prefix of the device path of attached disks, which
+// are named after the disk ID
+const devicePathPrefix = "/dev/disk/by-id/fake-"
+
+// Disk is a disk of the fake provider. It is the template given to Create and
+// the volume returned by Create, Inspect and Enumerate.
+type Disk struct {
+	// ID of the disk. Create generates one if it is empty.
+	ID string
+	// Name of the disk
+	Name string
+	// SizeInGiB is the size of the disk in GiB
+	SizeInGiB uint64
+	// Type is the type of the disk
+	Type string
+	// Labels are the labels/tags of the disk
+	Labels map[string]string
+	// AttachedTo is the ID of the instance the disk is attached to, or empty
+	// if the disk is detached
+	AttachedTo string
+	// SnapshotID is the ID of the snapshot the disk was created from
+	SnapshotID string
+}
+
+// Snapshot is a snapshot of the fake provider
+type Snapshot struct {
+	// ID of the snapshot. Snapshot generates one.
+	ID string
+	// Name of the snapshot
+	Name string
+	// VolumeID is the ID of the disk the snapshot was taken from
+	VolumeID string
+	// SizeInGiB is the size of the source disk in GiB
+	SizeInGiB uint64
+	// Labels are the labels of the snapshot
+	Labels map[string]string
+	// CreationTime is the time when the snapshot was taken
+	CreationTime time.Time
+}
+
+// Ops is an in-memory cloudops.Ops. It keeps disks, their attachments and
+// tags, and snapshots in maps, which tests can seed and assert through its
+// exported methods. The compute operations and the storage operations not
+// implemented below are not supported.
+type Ops struct {
+	cloudops.Compute
+	cloudops.Storage
+	instanceID string
+	mutex      sync.Mutex
+	disks      map[string]*Disk
+	snapshots  map[string]*Snapshot
+	// lastID is used to generate the IDs of disks and snapshots
+	lastID int
+}
+
+// NewOps returns an empty fake provider running on the instance with the
+// given ID
+func NewOps(instanceID string) *Ops {
+	return &Ops{
+		Compute:    unsupported.NewUnsupportedCompute(),
+		Storage:    unsupported.NewUnsupportedStorage(),
+		instanceID: instanceID,
+		disks:      make(map[string]*Disk),
+		snapshots:  make(map[string]*Snapshot),
+	}
+}
+
+// SeedDisk adds the given disk to the provider as is, replacing any disk with
+// the same ID
+func (f *Ops) SeedDisk(disk *Disk) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.disks[disk.ID] = copyDisk(disk)
+}
+
+// SeedSnapshot adds the given snapshot to the provider as is, replacing any
+// snapshot with the same ID
+func (f *Ops) SeedSnapshot(snap *Snapshot) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.snapshots[snap.ID] = copySnapshot(snap)
+}
+
+// GetDisk returns a copy of the disk with the given ID, or false if it does
+// not exist
+func (f *Ops) GetDisk(volumeID string) (*Disk, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	disk, ok := f.disks[volumeID]
+	if !ok {
+		return nil, false
+	}
+	return copyDisk(disk), true
+}
+
+// Disks returns a copy of all the disks sorted by ID
+func (f *Ops) Disks() []*Disk {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	disks := make([]*Disk, 0, len(f.disks))
+	for _, disk := range f.disks {
+		disks = append(disks, copyDisk(disk))
+	}
+	sort.Slice(disks, func(i, j int) bool { return disks[i].ID < disks[j].ID })
+	return disks
+}
+
+// GetSnapshot returns a copy of the snapshot with the given ID, or false if
+// it does not exist
+func (f *Ops) GetSnapshot(snapID string) (*Snapshot, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	snap, ok := f.snapshots[snapID]
+	if !ok {
+		return nil, false
+	}
+	return copySnapshot(snap), true
+}
+
+// Snapshots returns a copy of all the snapshots sorted by ID
+func (f *Ops) Snapshots() []*Snapshot {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	snaps := make([]*Snapshot, 0, len(f.snapshots))
+	for _, snap := range f.snapshots {
+		snaps = append(snaps, copySnapshot(snap))
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].ID < snaps[j].ID })
+	return snaps
+}
+
+func (f *Ops) Name() string { return string(cloudops.Fake) }
+
+func (f *Ops) InstanceID() string { return f.instanceID }
+
+func (f *Ops) Create(
+	template interface{},
+	labels map[string]string,
+	options map[string]string,
+) (interface{}, error) {
+	disk, ok := template.(*Disk)
+	if !ok {
+		return nil, cloudops.NewStorageError(cloudops.ErrVolInval,
+			"Invalid volume template given", "")
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if len(disk.SnapshotID) > 0 {
+		if _, ok := f.snapshots[disk.SnapshotID]; !ok {
+			return nil, snapshotNotFound(disk.SnapshotID)
+		}
+	}
+
+	newDisk := copyDisk(disk)
+	if len(newDisk.ID) == 0 {
+		newDisk.ID = f.nextID("vol")
+	} else if _, ok := f.disks[newDisk.ID]; ok {
+		return nil, cloudops.NewStorageError(cloudops.ErrVolInval,
+			fmt.Sprintf("volume %s already exists", newDisk.ID), "")
+	}
+	newDisk.AttachedTo = ""
+	for k, v := range labels {
+		newDisk.Labels[k] = v
+	}
+	f.disks[newDisk.ID] = newDisk
+	return copyDisk(newDisk), nil
+}
+
+func (f *Ops) GetDeviceID(template interface{}) (string, error) {
+	switch t := template.(type) {
+	case *Disk:
+		return t.ID, nil
+	case *Snapshot:
+		return t.ID, nil
+	default:
+		return "", cloudops.NewStorageError(cloudops.ErrVolInval,
+			"Invalid volume given", "")
+	}
+}
+
+func (f *Ops) Attach(volumeID string, options map[string]string) (string, error) {
+	return f.AttachByInstanceID(f.instanceID, volumeID, options)
+}
+
+// AttachByInstanceID attaches the disk to the given instance. It fails with
+// ErrVolAttachedOnRemoteNode if the disk is attached to a different instance.
+func (f *Ops) AttachByInstanceID(instanceID, volumeID string, options map[string]string) (string, error) {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return "", err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	disk, err := f.getDisk(volumeID)
+	if err != nil {
+		return "", err
+	}
+	if len(disk.AttachedTo) > 0 && disk.AttachedTo != instanceID {
+		return "", cloudops.NewStorageError(cloudops.ErrVolAttachedOnRemoteNode,
+			fmt.Sprintf("volume %s is attached on instance %s", volumeID, disk.AttachedTo),
+			instanceID)
+	}
+	disk.AttachedTo = instanceID
+	return devicePathPrefix + volumeID, nil
+}
+
+func (f *Ops) Detach(volumeID string, options map[string]string) error {
+	return f.DetachFrom(volumeID, f.instanceID)
+}
+
+// DetachFrom detaches the disk from the given instance. Detaching a disk
+// which is not attached to the instance succeeds.
+func (f *Ops) DetachFrom(volumeID, instanceID string) error {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	disk, err := f.getDisk(volumeID)
+	if err != nil {
+		return err
+	}
+	if disk.AttachedTo == instanceID {
+		disk.AttachedTo = ""
+	}
+	return nil
+}
+
+// Delete deletes the disk. Attached disks cannot be deleted.
+func (f *Ops) Delete(volumeID string, options map[string]string) error {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	disk, err := f.getDisk(volumeID)
+	if err != nil {
+		return err
+	}
+	if len(disk.AttachedTo) > 0 {
+		return fmt.Errorf("volume %s is attached on instance %s", volumeID, disk.AttachedTo)
+	}
+	delete(f.disks, volumeID)
+	return nil
+}
+
+func (f *Ops) DeleteFrom(volumeID, _ string) error {
+	return f.Delete(volumeID, nil)
+}
+
+// Expand grows the disk to the given size. Disks cannot be shrunk, so
+// requests for a size smaller than or equal to the current size of the disk
+// fail with ErrDiskGreaterOrEqualToExpandSize.
+func (f *Ops) Expand(volumeID string, newSizeInGiB uint64, options map[string]string) (uint64, error) {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return 0, err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	disk, err := f.getDisk(volumeID)
+	if err != nil {
+		return 0, err
+	}
+	if disk.SizeInGiB >= newSizeInGiB {
+		return disk.SizeInGiB, cloudops.NewStorageError(cloudops.ErrDiskGreaterOrEqualToExpandSize,
+			fmt.Sprintf("disk is already has a size: %d greater than or equal "+
+				"requested size: %d", disk.SizeInGiB, newSizeInGiB), "")
+	}
+	disk.SizeInGiB = newSizeInGiB
+	return newSizeInGiB, nil
+}
+
+func (f *Ops) DevicePath(volumeID string) (string, error) {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return "", err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	disk, err := f.getDisk(volumeID)
+	if err != nil {
+		return "", err
+	}
+	if len(disk.AttachedTo) == 0 {
+		return "", cloudops.NewStorageError(cloudops.ErrVolDetached,
+			fmt.Sprintf("volume %s is detached", volumeID), f.instanceID)
+	}
+	if disk.AttachedTo != f.instanceID {
+		return "", cloudops.NewStorageError(cloudops.ErrVolAttachedOnRemoteNode,
+			fmt.Sprintf("volume %s is not attached on: %s (Attached on: %s)",
+				volumeID, f.instanceID, disk.AttachedTo),
+			f.instanceID)
+	}
+	return devicePathPrefix + volumeID, nil
+}
+
+func (f *Ops) DeviceMappings() (map[string]string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	m := make(map[string]string)
+	for _, disk := range f.disks {
+		if disk.AttachedTo == f.instanceID {
+			m[devicePathPrefix+disk.ID] = disk.ID
+		}
+	}
+	return m, nil
+}
+
+func (f *Ops) Inspect(volumeIds []*string, options map[string]string) ([]interface{}, error) {
+	if err := cloudops.ValidateVolumeIDs(volumeIds); err != nil {
+		return nil, err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	disks := make([]interface{}, 0, len(volumeIds))
+	for _, id := range volumeIds {
+		disk, err := f.getDisk(*id)
+		if err != nil {
+			return nil, err
+		}
+		disks = append(disks, copyDisk(disk))
+	}
+	return disks, nil
+}
+
+func (f *Ops) Enumerate(
+	volumeIds []*string,
+	labels map[string]string,
+	setIdentifier string,
+) (map[string][]interface{}, error) {
+	if err := cloudops.ValidateVolumeIDs(volumeIds); err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]bool, len(volumeIds))
+	for _, id := range volumeIds {
+		ids[*id] = true
+	}
+
+	sets := make(map[string][]interface{})
+	for _, disk := range f.Disks() {
+		if len(ids) > 0 && !ids[disk.ID] {
+			continue
+		}
+		if !hasLabels(disk.Labels, labels) {
+			continue
+		}
+
+		if _, ok := disk.Labels[setIdentifier]; ok && len(setIdentifier) > 0 {
+			cloudops.AddElementToMap(sets, disk, disk.Labels[setIdentifier])
+		} else {
+			cloudops.AddElementToMap(sets, disk, cloudops.SetIdentifierNone)
+		}
+	}
+	return sets, nil
+}
+
+func (f *Ops) Snapshot(volumeID string, readonly bool, options map[string]string) (interface{}, error) {
+	return f.SnapshotWithOptions(volumeID, readonly, cloudops.SnapshotOptions{Options: options})
+}
+
+// SnapshotWithOptions takes a snapshot of the disk, naming and labeling it as
+// given in opts. Snapshots are ready as soon as they are taken.
+func (f *Ops) SnapshotWithOptions(volumeID string, readonly bool, opts cloudops.SnapshotOptions) (interface{}, error) {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return nil, err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	disk, err := f.getDisk(volumeID)
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &Snapshot{
+		ID:           f.nextID("snap"),
+		Name:         opts.Name,
+		VolumeID:     volumeID,
+		SizeInGiB:    disk.SizeInGiB,
+		Labels:       copyLabels(opts.Labels),
+		CreationTime: time.Now(),
+	}
+	if len(snap.Name) == 0 {
+		snap.Name = cloudops.SnapshotName()
+	}
+	f.snapshots[snap.ID] = snap
+	return copySnapshot(snap), nil
+}
+
+func (f *Ops) SnapshotDelete(snapID string, options map[string]string) error {
+	if err := cloudops.ValidateVolumeID(snapID); err != nil {
+		return err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if _, ok := f.snapshots[snapID]; !ok {
+		return snapshotNotFound(snapID)
+	}
+	delete(f.snapshots, snapID)
+	return nil
+}
+
+// EnumerateSnapshots returns the snapshots of the given disks which have the
+// given labels, sorted by ID
+func (f *Ops) EnumerateSnapshots(volumeIDs []*string, labels map[string]string) ([]*cloudops.SnapshotInfo, error) {
+	if err := cloudops.ValidateVolumeIDs(volumeIDs); err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]bool, len(volumeIDs))
+	for _, id := range volumeIDs {
+		ids[*id] = true
+	}
+
+	snapshots := make([]*cloudops.SnapshotInfo, 0)
+	for _, snap := range f.Snapshots() {
+		if len(ids) > 0 && !ids[snap.VolumeID] {
+			continue
+		}
+		if hasLabels(snap.Labels, labels) {
+			snapshots = append(snapshots, snapshotInfo(snap))
+		}
+	}
+	return snapshots, nil
+}
+
+func (f *Ops) ListSnapshots(labels map[string]string) ([]*cloudops.SnapshotInfo, error) {
+	return f.EnumerateSnapshots(nil, labels)
+}
+
+func (f *Ops) InspectSnapshot(snapID string) (*cloudops.SnapshotInfo, error) {
+	if err := cloudops.ValidateVolumeID(snapID); err != nil {
+		return nil, err
+	}
+
+	snap, ok := f.GetSnapshot(snapID)
+	if !ok {
+		return nil, snapshotNotFound(snapID)
+	}
+	return snapshotInfo(snap), nil
+}
+
+func (f *Ops) ApplyTags(volumeID string, labels map[string]string, options map[string]string) error {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	disk, err := f.getDisk(volumeID)
+	if err != nil {
+		return err
+	}
+	for k, v := range labels {
+		disk.Labels[k] = v
+	}
+	return nil
+}
+
+func (f *Ops) RemoveTags(volumeID string, labels map[string]string, options map[string]string) error {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	disk, err := f.getDisk(volumeID)
+	if err != nil {
+		return err
+	}
+	for k := range labels {
+		delete(disk.Labels, k)
+	}
+	return nil
+}
+
+func (f *Ops) Tags(volumeID string) (map[string]string, error) {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return nil, err
+	}
+
+	disk, ok := f.GetDisk(volumeID)
+	if !ok {
+		return nil, volumeNotFound(volumeID)
+	}
+	return disk.Labels, nil
+}
+
+// getDisk returns the stored disk with the given ID, or an ErrVolNotFound
+// error if it does not exist. The caller must hold the mutex.
+func (f *Ops) getDisk(volumeID string) (*Disk, error) {
+	disk, ok := f.disks[volumeID]
+	if !ok {
+		return nil, volumeNotFound(volumeID)
+	}
+	return disk, nil
+}
+
+// nextID returns a new unique ID with the given prefix. The caller must hold
+// the mutex.
+func (f *Ops) nextID(prefix string) string {
+	for {
+		f.lastID++
+		id := fmt.Sprintf("%s-%d", prefix, f.lastID)
+		_, diskExists := f.disks[id]
+		_, snapExists := f.snapshots[id]
+		if !diskExists && !snapExists {
+			return id
+		}
+	}
+}
+
+func volumeNotFound(volumeID string) error {
+	return cloudops.NewStorageError(cloudops.ErrVolNotFound,
+		fmt.Sprintf("volume %s not found", volumeID), "")
+}
+
+func snapshotNotFound(snapID string) error {
+	return cloudops.NewStorageError(cloudops.ErrVolNotFound,
+		fmt.Sprintf("snapshot %s not found", snapID), "")
+}
+
+func snapshotInfo(snap *Snapshot) *cloudops.SnapshotInfo {
+	return &cloudops.SnapshotInfo{
+		CloudResourceInfo: cloudops.CloudResourceInfo{
+			Name:   snap.Name,
+			ID:     snap.ID,
+			Labels: snap.Labels,
+		},
+		VolumeID:     snap.VolumeID,
+		SizeInGiB:    snap.SizeInGiB,
+		State:        "ready",
+		CreationTime: snap.CreationTime,
+	}
+}
+
+func copyDisk(disk *Disk) *Disk {
+	c := *disk
+	c.Labels = copyLabels(disk.Labels)
+	return &c
+}
+
+func copySnapshot(snap *Snapshot) *Snapshot {
+	c := *snap
+	c.Labels = copyLabels(snap.Labels)
+	return &c
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	c := make(map[string]string, len(labels))
+	for k, v := range labels {
+		c[k] = v
+	}
+	return c
+}
+
+func hasLabels(labels, want map[string]string) bool {
+	for k, v := range want {
+		if value, ok := labels[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
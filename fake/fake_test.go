@@ -0,0 +1,178 @@
+package fake
+
+import (
+	"testing"
+
+	"github.com/libopenstorage/cloudops"
+	"github.com/stretchr/testify/require"
+)
+
+func requireStorageError(t *testing.T, err error, code int) {
+	require.Error(t, err)
+	se, ok := err.(*cloudops.StorageError)
+	require.True(t, ok, "expected a StorageError, got %v", err)
+	require.Equal(t, code, se.Code)
+}
+
+var _ cloudops.Ops = NewOps("node-1")
+
+func TestCreateAttachDetachDelete(t *testing.T) {
+	f := NewOps("node-1")
+
+	vol, err := f.Create(&Disk{Name: "data", SizeInGiB: 10, Type: "ssd"},
+		map[string]string{"app": "db"}, nil)
+	require.NoError(t, err)
+	id, err := f.GetDeviceID(vol)
+	require.NoError(t, err)
+
+	disk, ok := f.GetDisk(id)
+	require.True(t, ok)
+	require.Equal(t, "data", disk.Name)
+	require.Equal(t, map[string]string{"app": "db"}, disk.Labels)
+
+	devicePath, err := f.Attach(id, nil)
+	require.NoError(t, err)
+	mappings, err := f.DeviceMappings()
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{devicePath: id}, mappings)
+	path, err := f.DevicePath(id)
+	require.NoError(t, err)
+	require.Equal(t, devicePath, path)
+
+	// attaching again to the same instance is a no-op
+	_, err = f.Attach(id, nil)
+	require.NoError(t, err)
+
+	// the disk cannot be attached elsewhere nor deleted while it is attached
+	_, err = f.AttachByInstanceID("node-2", id, nil)
+	requireStorageError(t, err, cloudops.ErrVolAttachedOnRemoteNode)
+	require.Error(t, f.Delete(id, nil))
+
+	// detaching from another instance leaves the disk attached
+	require.NoError(t, f.DetachFrom(id, "node-2"))
+	disk, _ = f.GetDisk(id)
+	require.Equal(t, "node-1", disk.AttachedTo)
+
+	require.NoError(t, f.Detach(id, nil))
+	_, err = f.DevicePath(id)
+	requireStorageError(t, err, cloudops.ErrVolDetached)
+
+	require.NoError(t, f.Delete(id, nil))
+	require.Empty(t, f.Disks())
+	requireStorageError(t, f.Delete(id, nil), cloudops.ErrVolNotFound)
+}
+
+func TestDevicePathRemote(t *testing.T) {
+	f := NewOps("node-1")
+	f.SeedDisk(&Disk{ID: "vol-a", SizeInGiB: 10, AttachedTo: "node-2"})
+
+	_, err := f.DevicePath("vol-a")
+	requireStorageError(t, err, cloudops.ErrVolAttachedOnRemoteNode)
+	mappings, err := f.DeviceMappings()
+	require.NoError(t, err)
+	require.Empty(t, mappings)
+}
+
+func TestExpand(t *testing.T) {
+	f := NewOps("node-1")
+	f.SeedDisk(&Disk{ID: "vol-a", SizeInGiB: 10})
+
+	size, err := f.Expand("vol-a", 20, nil)
+	require.NoError(t, err)
+	require.Equal(t, uint64(20), size)
+
+	size, err = f.Expand("vol-a", 15, nil)
+	requireStorageError(t, err, cloudops.ErrDiskGreaterOrEqualToExpandSize)
+	require.Equal(t, uint64(20), size)
+
+	_, err = f.Expand("vol-b", 15, nil)
+	requireStorageError(t, err, cloudops.ErrVolNotFound)
+}
+
+func TestEnumerate(t *testing.T) {
+	f := NewOps("node-1")
+	f.SeedDisk(&Disk{ID: "vol-a", Labels: map[string]string{"app": "db", "set": "1"}})
+	f.SeedDisk(&Disk{ID: "vol-b", Labels: map[string]string{"app": "db"}})
+	f.SeedDisk(&Disk{ID: "vol-c", Labels: map[string]string{"app": "web"}})
+
+	sets, err := f.Enumerate(nil, map[string]string{"app": "db"}, "set")
+	require.NoError(t, err)
+	require.Len(t, sets, 2)
+	require.Equal(t, "vol-a", sets["1"][0].(*Disk).ID)
+	require.Equal(t, "vol-b", sets[cloudops.SetIdentifierNone][0].(*Disk).ID)
+
+	id := "vol-c"
+	sets, err = f.Enumerate([]*string{&id}, nil, "")
+	require.NoError(t, err)
+	require.Len(t, sets[cloudops.SetIdentifierNone], 1)
+
+	vols, err := f.Inspect([]*string{&id}, nil)
+	require.NoError(t, err)
+	require.Equal(t, "web", vols[0].(*Disk).Labels["app"])
+}
+
+func TestTags(t *testing.T) {
+	f := NewOps("node-1")
+	f.SeedDisk(&Disk{ID: "vol-a", Labels: map[string]string{"app": "db"}})
+
+	require.NoError(t, f.ApplyTags("vol-a", map[string]string{"owner": "me"}, nil))
+	require.NoError(t, f.RemoveTags("vol-a", map[string]string{"app": ""}, nil))
+	tags, err := f.Tags("vol-a")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"owner": "me"}, tags)
+
+	// the returned tags do not alias the stored ones
+	tags["owner"] = "you"
+	disk, _ := f.GetDisk("vol-a")
+	require.Equal(t, "me", disk.Labels["owner"])
+}
+
+func TestSnapshots(t *testing.T) {
+	f := NewOps("node-1")
+	f.SeedDisk(&Disk{ID: "vol-a", SizeInGiB: 10})
+
+	snap, err := f.SnapshotWithOptions("vol-a", true, cloudops.SnapshotOptions{
+		Name:   "backup",
+		Labels: map[string]string{"policy": "daily"},
+	})
+	require.NoError(t, err)
+	snapID, err := f.GetDeviceID(snap)
+	require.NoError(t, err)
+
+	info, err := f.InspectSnapshot(snapID)
+	require.NoError(t, err)
+	require.Equal(t, "backup", info.Name)
+	require.Equal(t, "vol-a", info.VolumeID)
+	require.Equal(t, uint64(10), info.SizeInGiB)
+
+	snaps, err := f.EnumerateSnapshots(nil, map[string]string{"policy": "daily"})
+	require.NoError(t, err)
+	require.Len(t, snaps, 1)
+	snaps, err = f.EnumerateSnapshots(nil, map[string]string{"policy": "weekly"})
+	require.NoError(t, err)
+	require.Empty(t, snaps)
+	snaps, err = f.ListSnapshots(map[string]string{"policy": "daily"})
+	require.NoError(t, err)
+	require.Len(t, snaps, 1)
+	_, err = f.EnumerateSnapshots([]*string{nil}, nil)
+	requireStorageError(t, err, cloudops.ErrVolInval)
+
+	// disks can be created from the snapshot
+	vol, err := f.Create(&Disk{SizeInGiB: 10, SnapshotID: snapID}, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, snapID, vol.(*Disk).SnapshotID)
+
+	require.NoError(t, f.SnapshotDelete(snapID, nil))
+	requireStorageError(t, f.SnapshotDelete(snapID, nil), cloudops.ErrVolNotFound)
+	_, err = f.Create(&Disk{SizeInGiB: 10, SnapshotID: snapID}, nil, nil)
+	requireStorageError(t, err, cloudops.ErrVolNotFound)
+}
+
+func TestUnsupported(t *testing.T) {
+	f := NewOps("node-1")
+
+	_, err := f.InspectInstance("node-1")
+	require.IsType(t, &cloudops.ErrNotSupported{}, err)
+	_, _, err = f.GetVolumeLineage("vol-a")
+	require.IsType(t, &cloudops.ErrNotSupported{}, err)
+}
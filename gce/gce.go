@@ -1,19 +1,27 @@
 package gce
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode"
 
 	"cloud.google.com/go/compute/metadata"
+	"github.com/google/uuid"
 	"github.com/libopenstorage/cloudops"
 	"github.com/libopenstorage/cloudops/backoff"
 	"github.com/libopenstorage/cloudops/unsupported"
@@ -30,9 +38,31 @@ import (
 
 var notFoundRegex = regexp.MustCompile(`.*notFound`)
 
-const googleDiskPrefix = "/dev/disk/by-id/google-"
 const retrySeconds = 15
 
+var (
+	// googleDiskPrefix is the by-id prefix of disks attached over SCSI
+	googleDiskPrefix = "/dev/disk/by-id/google-"
+	// googleNvmeDiskPrefix is the by-id prefix of disks attached over NVMe.
+	// GCE sets the NVMe namespace serial to the disk's device name.
+	googleNvmeDiskPrefix = "/dev/disk/by-id/nvme-Google_PersistentDisk_"
+)
+
+func init() {
+	devicePrefixesFromEnv()
+}
+
+// devicePrefixesFromEnv overrides the by-id prefixes of attached disks with the
+// prefixes set in DiskPrefixEnvKey and NvmeDiskPrefixEnvKey
+func devicePrefixesFromEnv() {
+	if prefix := os.Getenv(DiskPrefixEnvKey); len(prefix) != 0 {
+		googleDiskPrefix = prefix
+	}
+	if prefix := os.Getenv(NvmeDiskPrefixEnvKey); len(prefix) != 0 {
+		googleNvmeDiskPrefix = prefix
+	}
+}
+
 // StatusReady ready status
 const StatusReady = "ready"
 
@@ -45,6 +75,54 @@ const (
 	nodePoolKey             = "cloud.google.com/gke-nodepool"
 	instanceTemplateKey     = "instance-template"
 	doneStatus              = "DONE"
+	// AttachInterfaceKey is the Attach option used to select the interface
+	// (SCSI or NVME) over which the disk is attached. Defaults to SCSI.
+	AttachInterfaceKey = "interface"
+	interfaceSCSI      = "SCSI"
+	interfaceNVME      = "NVME"
+	// ReplicationTargetZoneKey is the ConfigureReplication option used to select
+	// the zone of the secondary disk. Defaults to the same zone suffix as the
+	// primary disk in the target region.
+	ReplicationTargetZoneKey = "target-zone"
+	// ReplicationTargetDiskKey is the ConfigureReplication option used to name
+	// the secondary disk. Defaults to "<disk>-replica".
+	ReplicationTargetDiskKey = "target-disk"
+	// minDiskSizeGB is the minimum size of a persistent disk
+	minDiskSizeGB = 10
+	// minExtremeDiskSizeGB is the minimum size of a pd-extreme disk
+	minExtremeDiskSizeGB = 500
+	// hyperdiskPrefix is the prefix of the disk types with provisioned performance
+	hyperdiskPrefix = "hyperdisk-"
+	// maxLabelUpdateAttempts is the number of times a disk label update is
+	// retried when it races with another update of the disk labels
+	maxLabelUpdateAttempts = 5
+	// maxLabelValueLength is the maximum length of a label value
+	maxLabelValueLength = 63
+	// layoutDiskPrefix is the name prefix of disks created by ProvisionStorageLayout
+	layoutDiskPrefix = "cloudops"
+	// GuestAttributesDevicePathsEnvKey is the env variable which if set to
+	// true makes DevicePath and DeviceMappings read the device path of the
+	// attached disks from the guest attributes published by the guest agent,
+	// falling back to the by-id symlinks if it is not published.
+	GuestAttributesDevicePathsEnvKey = "GCE_GUEST_ATTRIBUTES_DEVICE_PATHS"
+	// DiskPrefixEnvKey is the env variable which overrides the path prefix of
+	// the symlinks of disks attached over SCSI, for OS images whose udev rules
+	// create them elsewhere. Defaults to /dev/disk/by-id/google-.
+	DiskPrefixEnvKey = "GCE_DISK_PREFIX"
+	// NvmeDiskPrefixEnvKey is the env variable which overrides the path prefix
+	// of the symlinks of disks attached over NVMe. Defaults to
+	// /dev/disk/by-id/nvme-Google_PersistentDisk_.
+	NvmeDiskPrefixEnvKey = "GCE_NVME_DISK_PREFIX"
+	// guestAttributesDiskNamespace is the guest attributes namespace in which
+	// the guest agent publishes the device path of each disk keyed by its
+	// device name
+	guestAttributesDiskNamespace = "disks"
+	// PreserveLabelCaseEnvKey is the env variable which if set to true keeps
+	// the case of label values applied to disks and snapshots. By default
+	// label values are lower cased.
+	PreserveLabelCaseEnvKey = "GCE_PRESERVE_LABEL_CASE"
+	// maxListPageSize is the largest page the compute API list calls return
+	maxListPageSize = 500
 )
 
 type gceOps struct {
@@ -52,7 +130,29 @@ type gceOps struct {
 	inst             *instance
 	computeService   *compute.Service
 	containerService *container.Service
-	mutex            sync.Mutex
+	// httpClient is used for compute and container API calls which are not
+	// available in the client libraries
+	httpClient *http.Client
+	// opsTimeout configures how long to wait for operations to complete
+	opsTimeout cloudops.OpsTimeoutConfig
+	// devicePathCache caches the resolved device paths of attached disks
+	devicePathCache *cloudops.DevicePathCache
+	// guestAttributes if set is used to read the device paths of attached
+	// disks from the guest attributes
+	guestAttributes *metadata.Client
+	// preserveLabelCase if set keeps the case of label values
+	preserveLabelCase bool
+	// logger if set is used for all the log lines, see SetLogger
+	logger *logrus.Entry
+	mutex  sync.Mutex
+}
+
+// hyperdiskPerformance is the subset of a disk resource which holds the
+// provisioned performance of hyperdisks
+type hyperdiskPerformance struct {
+	Type                  string `json:"type"`
+	ProvisionedIops       int64  `json:"provisionedIops,string"`
+	ProvisionedThroughput int64  `json:"provisionedThroughput,string"`
 }
 
 // instance stores the metadata of the running GCE instance
@@ -78,7 +178,23 @@ func IsDevMode() bool {
 
 // NewClient creates a new GCE operations client
 func NewClient() (cloudops.Ops, error) {
+	return NewClientWithOpsTimeout(cloudops.DefaultOpsTimeoutConfig())
+}
+
+// NewClientWithOpsTimeout creates a new GCE operations client which waits for
+// operations to complete as configured by opsTimeout
+func NewClientWithOpsTimeout(opsTimeout cloudops.OpsTimeoutConfig) (cloudops.Ops, error) {
+	return NewClientWithBackoff(opsTimeout, nil)
+}
 
+// NewClientWithBackoff creates a new GCE operations client which waits for
+// operations to complete as configured by opsTimeout and retries throttled
+// requests as configured by backoffConfig. The retries default to
+// backoff.DefaultExponentialBackoff if backoffConfig is nil.
+func NewClientWithBackoff(
+	opsTimeout cloudops.OpsTimeoutConfig,
+	backoffConfig *backoff.Config,
+) (cloudops.Ops, error) {
 	var i = new(instance)
 	ctx := context.Background()
 	var err error
@@ -104,24 +220,53 @@ func NewClient() (cloudops.Ops, error) {
 		return nil, fmt.Errorf("unable to create Container service: %v", err)
 	}
 
-	return backoff.NewExponentialBackoffOps(
+	httpClient, err := google.DefaultClient(ctx, compute.ComputeScope, compute.CloudPlatformScope)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create http client: %v", err)
+	}
+
+	var guestAttributes *metadata.Client
+	if useGuestAttributes, _ := strconv.ParseBool(os.Getenv(GuestAttributesDevicePathsEnvKey)); useGuestAttributes {
+		guestAttributes = metadata.NewClient(nil)
+	}
+	preserveLabelCase, _ := strconv.ParseBool(os.Getenv(PreserveLabelCaseEnvKey))
+
+	return backoff.NewProviderExponentialBackoffOps(
 		&gceOps{
-			Compute:          unsupported.NewUnsupportedCompute(),
-			inst:             i,
-			computeService:   computeService,
-			containerService: containerService,
+			Compute:           unsupported.NewUnsupportedCompute(),
+			inst:              i,
+			computeService:    computeService,
+			containerService:  containerService,
+			httpClient:        httpClient,
+			opsTimeout:        opsTimeout,
+			devicePathCache:   cloudops.NewDevicePathCache(cloudops.DevicePathCacheTTL),
+			guestAttributes:   guestAttributes,
+			preserveLabelCase: preserveLabelCase,
 		},
 		isExponentialError,
-		backoff.DefaultExponentialBackoff,
-	), nil
+		backoffConfig,
+		0,
+	)
 }
 
 func (s *gceOps) Name() string { return string(cloudops.GCE) }
 
+// SetLogger sets the logger used for all the log lines of the client
+func (s *gceOps) SetLogger(logger *logrus.Entry) { s.logger = logger }
+
+// log returns the logger for a line logged by the given method
+func (s *gceOps) log(method, volumeID string) *logrus.Entry {
+	return cloudops.ProviderLogger(s.logger, s.Name(), method, volumeID)
+}
+
 func (s *gceOps) InstanceID() string { return s.inst.name }
 
 func (s *gceOps) InspectInstance(instanceID string) (*cloudops.InstanceInfo, error) {
-	inst, err := s.computeService.Instances.Get(s.inst.project, s.inst.zone, instanceID).Do()
+	return s.InspectInstanceInZone(instanceID, s.inst.zone)
+}
+
+func (s *gceOps) InspectInstanceInZone(instanceID, zone string) (*cloudops.InstanceInfo, error) {
+	inst, err := s.computeService.Instances.Get(s.inst.project, zone, instanceID).Do()
 	if err != nil {
 		return nil, err
 	}
@@ -139,6 +284,36 @@ func (s *gceOps) InspectInstance(instanceID string) (*cloudops.InstanceInfo, err
 	return instInfo, nil
 }
 
+// GetNetworkInfo returns the network info of the given instance in any zone of
+// the local instance's region
+func (s *gceOps) GetNetworkInfo(instanceID string) (*cloudops.NetworkInfo, error) {
+	inst, _, err := s.getInstance(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	return networkInfoFromInstance(inst), nil
+}
+
+// networkInfoFromInstance returns the network info of the given compute instance
+func networkInfoFromInstance(inst *compute.Instance) *cloudops.NetworkInfo {
+	networkInfo := &cloudops.NetworkInfo{
+		PrivateIPs: make([]string, 0),
+	}
+	for i, nic := range inst.NetworkInterfaces {
+		if nic == nil {
+			continue
+		}
+		if i == 0 {
+			networkInfo.Network = path.Base(nic.Network)
+			networkInfo.Subnet = path.Base(nic.Subnetwork)
+		}
+		if len(nic.NetworkIP) > 0 {
+			networkInfo.PrivateIPs = append(networkInfo.PrivateIPs, nic.NetworkIP)
+		}
+	}
+	return networkInfo
+}
+
 // https://cloud.google.com/compute/docs/instances/instance-life-cycle
 func mapState(status string) cloudops.InstanceState {
 	switch status {
@@ -240,7 +415,7 @@ func (s *gceOps) InspectInstanceGroupForInstance(instanceID string) (*cloudops.I
 				s.inst.project, clusterLocation, gkeClusterName, labelValue)
 			nodePool, err := s.containerService.Projects.Locations.Clusters.NodePools.Get(nodePoolPath).Do()
 			if err != nil {
-				logrus.Errorf("failed to get node pool at path: %s", nodePoolPath)
+				s.log("InspectInstanceGroupForInstance", "").Errorf("failed to get node pool at path: %s", nodePoolPath)
 				return nil, err
 			}
 
@@ -304,7 +479,11 @@ func (s *gceOps) ApplyTags(
 	labels map[string]string,
 	options map[string]string,
 ) error {
-	d, err := s.computeService.Disks.Get(s.inst.project, s.inst.zone, diskName).Do()
+	if err := cloudops.ValidateVolumeID(diskName); err != nil {
+		return err
+	}
+
+	d, err := s.getDisk(diskName)
 	if err != nil {
 		return err
 	}
@@ -316,40 +495,54 @@ func (s *gceOps) ApplyTags(
 		currentLabels = d.Labels
 	}
 
-	for k, v := range formatLabels(labels) {
+	for k, v := range s.formatLabels(labels) {
 		currentLabels[k] = v
 	}
 
-	rb := &compute.ZoneSetLabelsRequest{
-		LabelFingerprint: d.LabelFingerprint,
-		Labels:           currentLabels,
+	return s.setDiskLabels(d, currentLabels)
+}
+
+func (s *gceOps) Attach(diskName string, options map[string]string) (string, error) {
+	if err := cloudops.ValidateVolumeID(diskName); err != nil {
+		return "", err
 	}
 
-	operation, err := s.computeService.Disks.SetLabels(s.inst.project, s.inst.zone, d.Name, rb).Do()
+	diskInterface, err := attachInterface(options)
 	if err != nil {
-		return err
+		return "", err
+	}
+
+	waitRemote, err := cloudops.WaitRemoteRequested(options)
+	if err != nil {
+		return "", err
 	}
-	return s.waitForOpCompletion("disk.ApplyTags", s.inst.zone, operation)
-}
 
-func (s *gceOps) Attach(diskName string, options map[string]string) (string, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	var d *compute.Disk
-	d, err := s.computeService.Disks.Get(s.inst.project, s.inst.zone, diskName).Do()
+	getDisk := func() (*compute.Disk, error) {
+		return s.getDisk(diskName)
+	}
+
+	d, err := getDisk()
 	if err != nil {
 		return "", err
 	}
 
 	if len(d.Users) != 0 {
-		return "", fmt.Errorf("disk %s is already in use by %s", diskName, d.Users)
+		if !waitRemote || s.isLocalUser(d.Users) {
+			return "", fmt.Errorf("disk %s is already in use by %s", diskName, d.Users)
+		}
+		if d, err = s.waitForRemoteDetach(getDisk); err != nil {
+			return "", err
+		}
 	}
 
 	diskURL := d.SelfLink
 	rb := &compute.AttachedDisk{
 		DeviceName: d.Name,
 		Source:     diskURL,
+		Interface:  diskInterface,
 	}
 
 	operation, err := s.computeService.Instances.AttachDisk(
@@ -364,6 +557,8 @@ func (s *gceOps) Attach(diskName string, options map[string]string) (string, err
 	if opErr := s.waitForOpCompletion("disk.Attach", s.inst.zone, operation); opErr != nil {
 		return "", opErr
 	}
+	// device names may have been reassigned
+	s.devicePathCache.InvalidateAll()
 
 	devicePath, err := s.waitForAttach(d, time.Minute)
 	if err != nil {
@@ -373,6 +568,160 @@ func (s *gceOps) Attach(diskName string, options map[string]string) (string, err
 	return devicePath, nil
 }
 
+// AttachByInstanceID attaches the disk to the given instance in any zone of the
+// local instance's region and returns the by-id path of the disk on that
+// instance
+func (s *gceOps) AttachByInstanceID(
+	instanceID, diskName string,
+	options map[string]string,
+) (string, error) {
+	if instanceID == s.inst.name {
+		return s.Attach(diskName, options)
+	}
+	if err := cloudops.ValidateVolumeID(diskName); err != nil {
+		return "", err
+	}
+
+	diskInterface, err := attachInterface(options)
+	if err != nil {
+		return "", err
+	}
+
+	d, err := s.getDisk(diskName)
+	if err != nil {
+		return "", err
+	}
+
+	_, zone, err := s.getInstance(instanceID)
+	if err != nil {
+		return "", err
+	}
+	if err := diskZoneError(d, instanceID, zone); err != nil {
+		return "", err
+	}
+
+	if len(d.Users) != 0 {
+		if !hasUser(d.Users, instanceID) {
+			return "", fmt.Errorf("disk %s is already in use by %s", diskName, d.Users)
+		}
+		// already attached to the instance
+		return s.waitForAttachTo(instanceID, zone, d)
+	}
+
+	operation, err := s.computeService.Instances.AttachDisk(
+		s.inst.project,
+		zone,
+		instanceID,
+		&compute.AttachedDisk{
+			DeviceName: d.Name,
+			Source:     d.SelfLink,
+			Interface:  diskInterface,
+		}).Do()
+	if err != nil {
+		return "", err
+	}
+
+	if opErr := s.waitForOpCompletion("disk.AttachByInstanceID", zone, operation); opErr != nil {
+		return "", opErr
+	}
+
+	return s.waitForAttachTo(instanceID, zone, d)
+}
+
+// getInstance returns the given instance and its zone. The instance is looked
+// up in the zone of the client first and then in the other zones of its region.
+func (s *gceOps) getInstance(instanceID string) (*compute.Instance, string, error) {
+	inst, err := s.computeService.Instances.Get(s.inst.project, s.inst.zone, instanceID).Do()
+	if err == nil {
+		return inst, s.inst.zone, nil
+	} else if !isNotFoundError(err) {
+		return nil, "", err
+	}
+
+	region, err := s.computeService.Regions.Get(s.inst.project, s.inst.region).Do()
+	if err != nil {
+		return nil, "", err
+	}
+	for _, zoneURL := range region.Zones {
+		zone := path.Base(zoneURL)
+		if zone == s.inst.zone {
+			continue
+		}
+		inst, err := s.computeService.Instances.Get(s.inst.project, zone, instanceID).Do()
+		if err == nil {
+			return inst, zone, nil
+		} else if !isNotFoundError(err) {
+			return nil, "", err
+		}
+	}
+	return nil, "", &cloudops.ErrNotFound{
+		Type: "Instance",
+		ID:   fmt.Sprintf("%s in region %s", instanceID, s.inst.region),
+	}
+}
+
+// diskZoneError returns an error if the disk cannot be attached to an instance
+// in the given zone. Zonal disks have to be in the same zone as the instance
+// and regional disks have to be replicated to it.
+func diskZoneError(d *compute.Disk, instanceID, zone string) error {
+	if len(d.Zone) > 0 {
+		if diskZone := path.Base(d.Zone); diskZone != zone {
+			return cloudops.NewStorageError(cloudops.ErrVolInval,
+				fmt.Sprintf("disk %s in zone %s cannot be attached to instance %s in zone %s",
+					d.Name, diskZone, instanceID, zone), instanceID)
+		}
+		return nil
+	}
+	if len(d.ReplicaZones) > 0 {
+		for _, replicaZone := range d.ReplicaZones {
+			if path.Base(replicaZone) == zone {
+				return nil
+			}
+		}
+		return cloudops.NewStorageError(cloudops.ErrVolInval,
+			fmt.Sprintf("regional disk %s is not replicated to zone %s of instance %s",
+				d.Name, zone, instanceID), instanceID)
+	}
+	return nil
+}
+
+// waitForAttachTo waits for the disk to be listed among the disks of the given
+// instance and returns the by-id path of the disk on that instance
+func (s *gceOps) waitForAttachTo(instanceID, zone string, d *compute.Disk) (string, error) {
+	devicePath, err := task.DoRetryWithTimeout(
+		func() (interface{}, bool, error) {
+			inst, err := s.computeService.Instances.Get(s.inst.project, zone, instanceID).Do()
+			if err != nil {
+				return "", true, err
+			}
+			for _, instDisk := range inst.Disks {
+				if instDisk.Source == d.SelfLink {
+					return diskPathByID(instDisk), false, nil
+				}
+			}
+			return "", true, cloudops.NewStorageError(
+				cloudops.ErrVolDetached,
+				fmt.Sprintf("disk %s is not yet attached on: %s", d.Name, instanceID),
+				instanceID)
+		},
+		s.opsTimeout.OpsTimeout(),
+		s.opsTimeout.OpsRetryInterval())
+	if err != nil {
+		return "", err
+	}
+
+	return devicePath.(string), nil
+}
+
+// getDisk returns the zonal disk with the given name in the local instance's
+// zone or the regional disk if the name is of a regional disk
+func (s *gceOps) getDisk(diskName string) (*compute.Disk, error) {
+	if region, name, ok := parseRegionalDisk(diskName); ok {
+		return s.computeService.RegionDisks.Get(s.inst.project, region, name).Do()
+	}
+	return s.computeService.Disks.Get(s.inst.project, s.inst.zone, diskName).Do()
+}
+
 func (s *gceOps) Create(
 	template interface{},
 	labels map[string]string,
@@ -384,30 +733,60 @@ func (s *gceOps) Create(
 			"Invalid volume template given", "")
 	}
 
+	// Disks are always encrypted at rest, so only a customer managed key
+	// changes the request
+	encryption, err := cloudops.EncryptionRequested(options)
+	if err != nil {
+		return nil, err
+	}
+	if len(encryption.KmsKeyID) > 0 {
+		key := &compute.CustomerEncryptionKey{}
+		if v.DiskEncryptionKey != nil {
+			key.KmsKeyServiceAccount = v.DiskEncryptionKey.KmsKeyServiceAccount
+		}
+		key.KmsKeyName = encryption.KmsKeyID
+		v.DiskEncryptionKey = key
+	}
+
 	if isDiskEncryptedWithDefaultAccount(v) {
-		logrus.Infof("Default service account to be used as disk encryption kms service account")
+		s.log("Create", v.Name).Infof("Default service account to be used as disk encryption kms service account")
 		v.DiskEncryptionKey.KmsKeyServiceAccount = s.inst.serviceAccount
 	}
 
+	// Disks created from an image, snapshot or disk default to the source's size
+	sizeGb := v.SizeGb
+	if sizeGb > 0 || (len(v.SourceImage) == 0 && len(v.SourceSnapshot) == 0 && len(v.SourceDisk) == 0) {
+		size, err := cloudops.EnforceMinSize(uint64(sizeGb), minDiskSize(v.Type), options)
+		if err != nil {
+			return nil, err
+		}
+		sizeGb = int64(size)
+	}
+
 	newDisk := &compute.Disk{
 		Description:       "Disk created by openstorage",
-		Labels:            formatLabels(labels),
+		Labels:            s.formatLabels(labels),
 		Name:              v.Name,
-		SizeGb:            v.SizeGb,
+		SizeGb:            sizeGb,
 		SourceImage:       v.SourceImage,
 		SourceSnapshot:    v.SourceSnapshot,
+		SourceDisk:        v.SourceDisk,
 		Type:              v.Type,
 		DiskEncryptionKey: v.DiskEncryptionKey,
 		Zone:              path.Base(v.Zone),
 	}
 
+	if len(v.Zone) == 0 && len(v.ReplicaZones) > 0 {
+		return s.createRegional(v, newDisk)
+	}
+
 	operation, err := s.computeService.Disks.Insert(s.inst.project, newDisk.Zone, newDisk).Do()
 	if err != nil {
-		return nil, err
+		return nil, encryptionKeyError(err, newDisk)
 	}
 
 	if opErr := s.waitForOpCompletion("disk.Create", newDisk.Zone, operation); opErr != nil {
-		return nil, opErr
+		return nil, encryptionKeyError(opErr, newDisk)
 	}
 
 	if err = s.checkDiskStatus(newDisk.Name, newDisk.Zone, StatusReady); err != nil {
@@ -422,6 +801,159 @@ func (s *gceOps) Create(
 	return d, err
 }
 
+// CloneVolume creates a disk from the source disk. The disk is created in the
+// zone, or the replica zones, of the source disk unless the template sets them.
+func (s *gceOps) CloneVolume(
+	sourceVolumeID string,
+	template interface{},
+	labels map[string]string,
+) (interface{}, error) {
+	if err := cloudops.ValidateVolumeID(sourceVolumeID); err != nil {
+		return nil, err
+	}
+	v, ok := template.(*compute.Disk)
+	if !ok {
+		return nil, cloudops.NewStorageError(cloudops.ErrVolInval,
+			"Invalid volume template given", "")
+	}
+
+	source, err := s.getDisk(sourceVolumeID)
+	if isNotFoundError(err) {
+		return nil, cloudops.NewStorageError(cloudops.ErrVolNotFound,
+			fmt.Sprintf("disk %s not found", sourceVolumeID), s.inst.name)
+	} else if err != nil {
+		return nil, err
+	}
+
+	clone := *v
+	clone.SourceDisk = source.SelfLink
+	if len(clone.Zone) == 0 && len(clone.ReplicaZones) == 0 {
+		clone.Zone = source.Zone
+		clone.Region = source.Region
+		clone.ReplicaZones = source.ReplicaZones
+	}
+	return s.Create(&clone, labels, nil)
+}
+
+// WaitForVolumeState waits until the disk is in the given normalized state.
+// A ready disk is attached if it is used by any instance.
+func (s *gceOps) WaitForVolumeState(volumeID string, desiredState string, timeout time.Duration) error {
+	getState := func() (string, error) {
+		d, err := s.getDisk(volumeID)
+		if isNotFoundError(err) {
+			return "", cloudops.NewStorageError(cloudops.ErrVolNotFound,
+				fmt.Sprintf("disk %s not found", volumeID), s.inst.name)
+		} else if err != nil {
+			return "", err
+		}
+		return volumeState(d), nil
+	}
+	return cloudops.PollVolumeState(volumeID, desiredState, getState, timeout, s.opsTimeout.OpsRetryInterval())
+}
+
+// volumeState maps the status of the disk to the normalized volume state
+func volumeState(d *compute.Disk) string {
+	switch d.Status {
+	case "CREATING", "RESTORING":
+		return cloudops.VolumeStateCreating
+	case "READY":
+		if len(d.Users) > 0 {
+			return cloudops.VolumeStateAttached
+		}
+		return cloudops.VolumeStateAvailable
+	case "DELETING":
+		return cloudops.VolumeStateDeleting
+	case "FAILED":
+		return cloudops.VolumeStateError
+	}
+	return d.Status
+}
+
+// createRegional creates a regional persistent disk replicated across the
+// template's ReplicaZones. The region defaults to the local instance's region.
+func (s *gceOps) createRegional(v, newDisk *compute.Disk) (interface{}, error) {
+	region := s.inst.region
+	if len(v.Region) != 0 {
+		region = path.Base(v.Region)
+	}
+	newDisk.Zone = ""
+	newDisk.Region = region
+	newDisk.ReplicaZones = make([]string, 0, len(v.ReplicaZones))
+	for _, z := range v.ReplicaZones {
+		if !strings.Contains(z, "/") {
+			z = fmt.Sprintf("projects/%s/zones/%s", s.inst.project, z)
+		}
+		newDisk.ReplicaZones = append(newDisk.ReplicaZones, z)
+	}
+	if len(newDisk.Type) != 0 && !strings.Contains(newDisk.Type, "/") {
+		newDisk.Type = fmt.Sprintf("projects/%s/regions/%s/diskTypes/%s",
+			s.inst.project, region, newDisk.Type)
+	}
+
+	operation, err := s.computeService.RegionDisks.Insert(s.inst.project, region, newDisk).Do()
+	if err != nil {
+		return nil, encryptionKeyError(err, newDisk)
+	}
+
+	if opErr := s.waitForRegionOpCompletion("disk.Create", region, operation); opErr != nil {
+		return nil, encryptionKeyError(opErr, newDisk)
+	}
+
+	if err = s.checkRegionalDiskStatus(newDisk.Name, region, StatusReady); err != nil {
+		return nil, s.rollbackCreate(regionalDiskID(region, newDisk.Name), err)
+	}
+
+	d, err := s.computeService.RegionDisks.Get(s.inst.project, region, newDisk.Name).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// regionalDiskID returns the ID used by cloudops for a regional disk.
+func regionalDiskID(region, name string) string {
+	return fmt.Sprintf("regions/%s/disks/%s", region, name)
+}
+
+// parseRegionalDisk returns the region and name of a regional disk given its
+// ID or self-link. ok is false if the input does not refer to a regional disk.
+func parseRegionalDisk(id string) (region, name string, ok bool) {
+	parts := strings.Split(id, "/")
+	for i := 0; i+3 < len(parts); i++ {
+		if parts[i] == "regions" && parts[i+2] == "disks" && i+4 == len(parts) {
+			return parts[i+1], parts[i+3], true
+		}
+	}
+	return "", "", false
+}
+
+// diskURLPath returns the path of the zonal or regional disk relative to the
+// base path of the compute API
+func (s *gceOps) diskURLPath(diskName string) string {
+	if region, name, ok := parseRegionalDisk(diskName); ok {
+		return fmt.Sprintf("%s/regions/%s/disks/%s", s.inst.project, region, name)
+	}
+	return fmt.Sprintf("%s/zones/%s/disks/%s", s.inst.project, s.inst.zone, diskName)
+}
+
+// diskIDFromSource returns the ID used by cloudops for the disk with the given
+// self-link.
+func diskIDFromSource(source string) string {
+	if region, name, ok := parseRegionalDisk(source); ok {
+		return regionalDiskID(region, name)
+	}
+	return path.Base(source)
+}
+
+// minDiskSize returns the minimum size in GB of the given disk type
+func minDiskSize(diskType string) uint64 {
+	if path.Base(diskType) == "pd-extreme" {
+		return minExtremeDiskSizeGB
+	}
+	return minDiskSizeGB
+}
+
 func (s *gceOps) DeleteFrom(id, _ string) error {
 	return s.Delete(id, nil)
 }
@@ -461,6 +993,18 @@ func (s *gceOps) DeleteInstance(instanceID string, zone string, timeout time.Dur
 }
 
 func (s *gceOps) Delete(id string, options map[string]string) error {
+	if err := cloudops.ValidateVolumeID(id); err != nil {
+		return err
+	}
+
+	if region, name, ok := parseRegionalDisk(id); ok {
+		operation, err := s.computeService.RegionDisks.Delete(s.inst.project, region, name).Do()
+		if err != nil {
+			return err
+		}
+		return s.waitForRegionOpCompletion("disk.Delete", region, operation)
+	}
+
 	ctx := context.Background()
 	found := false
 	req := s.computeService.Disks.AggregatedList(s.inst.project)
@@ -469,6 +1013,9 @@ func (s *gceOps) Delete(id string, options map[string]string) error {
 			for _, disk := range diskScopedList.Disks {
 				if disk.Name == id {
 					found = true
+					if len(disk.Region) != 0 {
+						return s.Delete(regionalDiskID(path.Base(disk.Region), id), options)
+					}
 					operation, err := s.computeService.Disks.Delete(s.inst.project, path.Base(disk.Zone), id).Do()
 					if err != nil {
 						return err
@@ -479,7 +1026,7 @@ func (s *gceOps) Delete(id string, options map[string]string) error {
 		}
 		return nil
 	}); err != nil {
-		logrus.Errorf("failed to list disks: %v", err)
+		s.log("Delete", id).Errorf("failed to list disks: %v", err)
 		return err
 	}
 
@@ -499,18 +1046,38 @@ func (s *gceOps) DetachFrom(devicePath, instanceName string) error {
 }
 
 func (s *gceOps) detachInternal(devicePath, instanceName string) error {
+	if err := cloudops.ValidateVolumeID(devicePath); err != nil {
+		return err
+	}
+
 	operation, err := s.computeService.Instances.DetachDisk(
 		s.inst.project,
 		s.inst.zone,
 		instanceName,
 		devicePath).Do()
-	if err != nil {
+	if isNotFoundError(err) {
+		// The disks of a deleted instance are detached along with it, so
+		// don't block the teardown of the instance on it
+		if _, ierr := s.computeService.Instances.Get(
+			s.inst.project, s.inst.zone, instanceName).Do(); isNotFoundError(ierr) {
+			s.log("Detach", devicePath).Infof("instance %s no longer exists, disk %s is detached",
+				instanceName, devicePath)
+			s.devicePathCache.Invalidate(devicePath)
+			return nil
+		}
+		return err
+	} else if err != nil {
 		return err
 	}
 
 	if opErr := s.waitForOpCompletion("disk.Detach", s.inst.zone, operation); opErr != nil {
 		return opErr
 	}
+	if instanceName == s.inst.name {
+		s.devicePathCache.InvalidateAll()
+	} else {
+		s.devicePathCache.Invalidate(devicePath)
+	}
 
 	var d *compute.Disk
 	d, err = s.computeService.Disks.Get(s.inst.project, s.inst.zone, devicePath).Do()
@@ -554,7 +1121,12 @@ func (s *gceOps) DeviceMappings() (map[string]string, error) {
 			continue
 		}
 
-		pathByID := fmt.Sprintf("%s%s", googleDiskPrefix, d.DeviceName)
+		if devPath, ok := s.guestAttributeDevicePath(d); ok {
+			m[devPath] = path.Base(d.Source)
+			continue
+		}
+
+		pathByID := diskPathByID(d)
 		devPath, err := s.diskIDToBlockDevPath(pathByID)
 		if err != nil {
 			return nil, cloudops.NewStorageError(
@@ -569,7 +1141,11 @@ func (s *gceOps) DeviceMappings() (map[string]string, error) {
 }
 
 func (s *gceOps) DevicePath(diskName string) (string, error) {
-	d, err := s.computeService.Disks.Get(s.inst.project, s.inst.zone, diskName).Do()
+	if err := cloudops.ValidateVolumeID(diskName); err != nil {
+		return "", err
+	}
+
+	d, err := s.getDisk(diskName)
 	if gerr, ok := err.(*googleapi.Error); ok &&
 		gerr.Code == http.StatusNotFound {
 		return "", cloudops.NewStorageError(
@@ -594,8 +1170,13 @@ func (s *gceOps) DevicePath(diskName string) (string, error) {
 
 	for _, instDisk := range inst.Disks {
 		if instDisk.Source == d.SelfLink {
-			pathByID := fmt.Sprintf("%s%s", googleDiskPrefix, instDisk.DeviceName)
-			devPath, err := s.diskIDToBlockDevPathWithRetry(pathByID)
+			pathByID := diskPathByID(instDisk)
+			devPath, err := s.devicePathCache.Resolve(diskName, func() (string, error) {
+				if devPath, ok := s.guestAttributeDevicePath(instDisk); ok {
+					return devPath, nil
+				}
+				return s.diskIDToBlockDevPathWithRetry(pathByID)
+			})
 			if err == nil {
 				return devPath, nil
 			}
@@ -618,9 +1199,13 @@ func (s *gceOps) Enumerate(
 	labels map[string]string,
 	setIdentifier string,
 ) (map[string][]interface{}, error) {
+	if err := cloudops.ValidateVolumeIDs(volumeIds); err != nil {
+		return nil, err
+	}
+
 	sets := make(map[string][]interface{})
 
-	allDisks, err := s.getDisksFromAllZones(formatLabels(labels))
+	allDisks, err := s.getDisksFromAllZones(s.formatLabels(labels))
 	if err != nil {
 		return nil, err
 	}
@@ -647,12 +1232,55 @@ func (s *gceOps) Enumerate(
 	return sets, nil
 }
 
+func (s *gceOps) ListVolumesPaged(labels map[string]string, pageSize int) (cloudops.VolumeIterator, error) {
+	req := s.computeService.Disks.AggregatedList(s.inst.project)
+	if len(labels) > 0 {
+		req = req.Filter(generateListFilterFromLabels(s.formatLabels(labels)))
+	}
+	if pageSize > maxListPageSize {
+		pageSize = maxListPageSize
+	}
+	if pageSize > 0 {
+		req = req.MaxResults(int64(pageSize))
+	}
+
+	return cloudops.NewVolumeIterator(func() ([]interface{}, bool, error) {
+		page, err := req.Do()
+		if err != nil {
+			s.log("ListVolumesPaged", "").Errorf("failed to list disks: %v", err)
+			return nil, false, err
+		}
+
+		// the disks are grouped by zone and region, return them in a stable order
+		scopes := make([]string, 0, len(page.Items))
+		for scope := range page.Items {
+			scopes = append(scopes, scope)
+		}
+		sort.Strings(scopes)
+		var disks []interface{}
+		for _, scope := range scopes {
+			for _, disk := range page.Items[scope].Disks {
+				disks = append(disks, disk)
+			}
+		}
+
+		if len(page.NextPageToken) == 0 {
+			return disks, false, nil
+		}
+		req = req.PageToken(page.NextPageToken)
+		return disks, true, nil
+	}), nil
+}
+
 func (s *gceOps) FreeDevices() ([]string, error) {
 	return nil, fmt.Errorf("function not implemented")
 }
 
 func (s *gceOps) GetDeviceID(disk interface{}) (string, error) {
 	if d, ok := disk.(*compute.Disk); ok {
+		if len(d.Region) != 0 {
+			return regionalDiskID(path.Base(d.Region), d.Name), nil
+		}
 		return d.Name, nil
 	} else if d, ok := disk.(*compute.Snapshot); ok {
 		return d.Name, nil
@@ -661,10 +1289,23 @@ func (s *gceOps) GetDeviceID(disk interface{}) (string, error) {
 	}
 }
 
+// AreVolumesReadyToExpand returns true if all the disks are READY, as disks
+// which are still being created or restored cannot be resized.
 func (s *gceOps) AreVolumesReadyToExpand(volumeIDs []*string) (bool, error) {
-	return true, &cloudops.ErrNotSupported{
-		Operation: "gceOps:IsVolumesReadyToExpand",
-	}
+	notReady := make(map[string]string)
+	for _, volumeID := range volumeIDs {
+		disk, err := s.getDisk(*volumeID)
+		if err != nil {
+			return false, err
+		}
+		if disk.Status != "READY" {
+			notReady[*volumeID] = fmt.Sprintf("disk is in %s state", disk.Status)
+		}
+	}
+	if len(notReady) > 0 {
+		return false, &cloudops.ErrVolumesNotReadyToExpand{Volumes: notReady}
+	}
+	return true, nil
 }
 
 func (s *gceOps) Expand(
@@ -672,8 +1313,11 @@ func (s *gceOps) Expand(
 	newSizeInGiB uint64,
 	options map[string]string,
 ) (uint64, error) {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return 0, err
+	}
 
-	vol, err := s.computeService.Disks.Get(s.inst.project, s.inst.zone, volumeID).Do()
+	vol, err := s.getDisk(volumeID)
 	if err != nil {
 		return 0, err
 	}
@@ -685,13 +1329,37 @@ func (s *gceOps) Expand(
 				"requested size: %d", currentSizeInGiB, newSizeInGiB), "")
 	}
 
-	op, err := s.computeService.Disks.Resize(s.inst.project, s.inst.zone, volumeID, &compute.DisksResizeRequest{
-		SizeGb:          int64(newSizeInGiB),
-		ForceSendFields: nil,
-		NullFields:      nil,
-	}).Do()
+	// the performance of persistent disks follows from their size, only the
+	// provisioned performance of hyperdisks has to be scaled
+	scaleIops, err := cloudops.ScaleIopsRequested(options)
 	if err != nil {
-		return 0, err
+		return currentSizeInGiB, err
+	}
+	scaleIops = scaleIops && strings.HasPrefix(path.Base(vol.Type), hyperdiskPrefix)
+
+	var getOperation func() (*compute.Operation, error)
+	if region, name, ok := parseRegionalDisk(volumeID); ok {
+		op, err := s.computeService.RegionDisks.Resize(s.inst.project, region, name, &compute.RegionDisksResizeRequest{
+			SizeGb: int64(newSizeInGiB),
+		}).Do()
+		if err != nil {
+			return 0, err
+		}
+		getOperation = func() (*compute.Operation, error) {
+			return s.computeService.RegionOperations.Get(s.inst.project, region, op.Name).Do()
+		}
+	} else {
+		op, err := s.computeService.Disks.Resize(s.inst.project, s.inst.zone, volumeID, &compute.DisksResizeRequest{
+			SizeGb:          int64(newSizeInGiB),
+			ForceSendFields: nil,
+			NullFields:      nil,
+		}).Do()
+		if err != nil {
+			return 0, err
+		}
+		getOperation = func() (*compute.Operation, error) {
+			return s.computeService.ZoneOperations.Get(s.inst.project, s.inst.zone, fmt.Sprintf("%d", op.Id)).Do()
+		}
 	}
 
 	// Taken from https://github.com/kubernetes/legacy-cloud-providers/blob/cebac2e3367faa71a39050bf5563fa7406006e76/gce/gce.go#L869
@@ -703,7 +1371,7 @@ func (s *gceOps) Expand(
 	}
 
 	checkForResize := func() (bool, error) {
-		newOp, err := s.computeService.ZoneOperations.Get(s.inst.project, s.inst.zone, fmt.Sprintf("%d", op.Id)).Do()
+		newOp, err := getOperation()
 		if err != nil {
 			return false, err
 		}
@@ -713,17 +1381,72 @@ func (s *gceOps) Expand(
 		return false, nil
 	}
 	waitWithErr := wait.ExponentialBackoff(backoff, checkForResize)
-	return newSizeInGiB, waitWithErr
+	if waitWithErr != nil || !scaleIops {
+		return newSizeInGiB, waitWithErr
+	}
+	return newSizeInGiB, s.scaleHyperdiskPerformance(volumeID, currentSizeInGiB, newSizeInGiB)
 }
 
-func (s *gceOps) Inspect(diskNames []*string, options map[string]string) ([]interface{}, error) {
-	allDisks, err := s.getDisksFromAllZones(nil)
+// scaleHyperdiskPerformance scales the provisioned IOPS and throughput of the
+// hyperdisk by the ratio of its new size to its old size. The provisioned
+// performance of hyperdisks is not available in the compute client library.
+func (s *gceOps) scaleHyperdiskPerformance(diskName string, oldSizeInGiB, newSizeInGiB uint64) error {
+	disk := &hyperdiskPerformance{}
+	if err := s.doRequest(http.MethodGet,
+		s.computeService.BasePath+s.diskURLPath(diskName), nil, disk); err != nil {
+		return err
+	}
+
+	update := make(map[string]string)
+	paths := url.Values{}
+	if disk.ProvisionedIops > 0 {
+		update["provisionedIops"] = strconv.FormatUint(
+			cloudops.ScaleToSize(uint64(disk.ProvisionedIops), oldSizeInGiB, newSizeInGiB), 10)
+		paths.Add("paths", "provisionedIops")
+	}
+	if disk.ProvisionedThroughput > 0 {
+		update["provisionedThroughput"] = strconv.FormatUint(
+			cloudops.ScaleToSize(uint64(disk.ProvisionedThroughput), oldSizeInGiB, newSizeInGiB), 10)
+		paths.Add("paths", "provisionedThroughput")
+	}
+	if len(update) == 0 {
+		return nil
+	}
+
+	operation, err := s.doComputeRequest(http.MethodPatch,
+		s.diskURLPath(diskName)+"?"+paths.Encode(), update)
 	if err != nil {
+		return err
+	}
+	if region, _, ok := parseRegionalDisk(diskName); ok {
+		return s.waitForRegionOpCompletion("disk.Update", region, operation)
+	}
+	return s.waitForOpCompletion("disk.Update", s.inst.zone, operation)
+}
+
+func (s *gceOps) Inspect(diskNames []*string, options map[string]string) ([]interface{}, error) {
+	if err := cloudops.ValidateVolumeIDs(diskNames); err != nil {
 		return nil, err
 	}
 
+	var allDisks map[string]*compute.Disk
 	var disks []interface{}
 	for _, id := range diskNames {
+		if region, name, ok := parseRegionalDisk(*id); ok {
+			d, err := s.computeService.RegionDisks.Get(s.inst.project, region, name).Do()
+			if err != nil {
+				return nil, err
+			}
+			disks = append(disks, d)
+			continue
+		}
+
+		if allDisks == nil {
+			var err error
+			if allDisks, err = s.getDisksFromAllZones(nil); err != nil {
+				return nil, err
+			}
+		}
 		if d, ok := allDisks[*id]; ok {
 			disks = append(disks, d)
 		} else {
@@ -733,32 +1456,60 @@ func (s *gceOps) Inspect(diskNames []*string, options map[string]string) ([]inte
 	return disks, nil
 }
 
+// BatchInspect returns the given disks keyed by disk ID. The disks of all
+// zones and regions are listed with a single paged aggregated list call.
+func (s *gceOps) BatchInspect(diskNames []*string) (map[string]interface{}, error) {
+	if err := cloudops.ValidateVolumeIDs(diskNames); err != nil {
+		return nil, err
+	}
+
+	disks := make(map[string]interface{}, len(diskNames))
+	if len(diskNames) == 0 {
+		return disks, nil
+	}
+
+	allDisks, err := s.getDisksFromAllZones(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range diskNames {
+		name := *id
+		region, regionalName, regional := parseRegionalDisk(name)
+		if regional {
+			name = regionalName
+		}
+		d, ok := allDisks[name]
+		if !ok || (regional && path.Base(d.Region) != region) {
+			return nil, cloudops.NewStorageError(cloudops.ErrVolNotFound,
+				fmt.Sprintf("disk %s not found", *id), s.inst.name)
+		}
+		disks[*id] = d
+	}
+	return disks, nil
+}
+
 func (s *gceOps) RemoveTags(
 	diskName string,
 	labels map[string]string,
 	options map[string]string,
 ) error {
-	d, err := s.computeService.Disks.Get(s.inst.project, s.inst.zone, diskName).Do()
+	if err := cloudops.ValidateVolumeID(diskName); err != nil {
+		return err
+	}
+
+	d, err := s.getDisk(diskName)
 	if err != nil {
 		return err
 	}
 
 	if len(d.Labels) != 0 {
 		currentLabels := d.Labels
-		for k := range formatLabels(labels) {
+		for k := range s.formatLabels(labels) {
 			delete(currentLabels, k)
 		}
 
-		rb := &compute.ZoneSetLabelsRequest{
-			LabelFingerprint: d.LabelFingerprint,
-			Labels:           currentLabels,
-		}
-
-		operation, err := s.computeService.Disks.SetLabels(s.inst.project, s.inst.zone, d.Name, rb).Do()
-		if err != nil {
-			return err
-		}
-		return s.waitForOpCompletion("disk.SetLabels", s.inst.zone, operation)
+		return s.setDiskLabels(d, currentLabels)
 	}
 
 	return err
@@ -800,43 +1551,44 @@ func (s *gceOps) SetClusterVersion(version string, timeout time.Duration) error
 	return s.WaitForOperationCompletion(operation, zonalCluster, timeout)
 }
 
-// SetInstanceGroupVersion sets desired version for the node group
-func (s *gceOps) SetInstanceGroupVersion(instanceGroupID string,
-	version string,
-	timeout time.Duration) error {
-	clusterPath := fmt.Sprintf("projects/%s/locations/%s/clusters/%s",
-		s.inst.project, s.inst.clusterLocation, s.inst.clusterName)
-
-	updateClusterRequest := &container.UpdateClusterRequest{
-		Name: clusterPath,
-		Update: &container.ClusterUpdate{
-			DesiredNodeVersion: version,
-			DesiredNodePoolId:  instanceGroupID,
-		},
+// GetClusterVersion returns the master version of the cluster and the version of
+// the node pool of the given instance
+func (s *gceOps) GetClusterVersion(instanceID string) (string, string, error) {
+	groupInfo, err := s.InspectInstanceGroupForInstance(instanceID)
+	if err != nil {
+		return "", "", err
 	}
 
-	zonalCluster, err := isZonalCluster(s.inst.clusterLocation)
+	nodePool, err := s.getNodePool(groupInfo.Name)
 	if err != nil {
-		return err
+		return "", "", err
 	}
 
-	var operation *container.Operation
-	if zonalCluster {
-		operation, err = s.containerService.Projects.Zones.Clusters.Update(s.inst.project,
-			s.inst.clusterLocation,
-			s.inst.clusterName,
-			updateClusterRequest).Do()
-	} else {
-		operation, err = s.containerService.Projects.Locations.Clusters.Update(
-			clusterPath,
-			updateClusterRequest).Do()
+	cluster, err := s.getCluster()
+	if err != nil {
+		return "", "", err
 	}
+	return cluster.CurrentMasterVersion, nodePool.Version, nil
+}
 
+// SetInstanceGroupVersion upgrades the nodes of the node pool to the given
+// version and waits for the cluster to be RUNNING again, unless timeout is 0.
+func (s *gceOps) SetInstanceGroupVersion(instanceGroupID string,
+	version string,
+	timeout time.Duration) error {
+	nodePool, err := s.getNodePool(instanceGroupID)
 	if err != nil {
 		return err
 	}
 
-	return s.WaitForOperationCompletion(operation, zonalCluster, timeout)
+	updateNodePoolRequest := &container.UpdateNodePoolRequest{
+		NodeVersion: version,
+	}
+	// the image type is required by the API, keep the current one
+	if nodePool.Config != nil {
+		updateNodePoolRequest.ImageType = nodePool.Config.ImageType
+	}
+	return s.updateNodePool(instanceGroupID, updateNodePoolRequest, timeout)
 }
 
 // SetInstanceUpgradeStrategy sets desired Upgrade strategy & respective parameters for the node group
@@ -873,7 +1625,7 @@ func (s *gceOps) SetInstanceUpgradeStrategy(instanceGroupID string,
 		return fmt.Errorf("invalid surge setting: %s", surgeSetting)
 	}
 
-	logrus.Infof("Setting upgrade strategy for instance group [%s] to [%s] with MaxSurge [%d] & MaxUnavailable [%d]",
+	s.log("SetInstanceUpgradeStrategy", "").Infof("Setting upgrade strategy for instance group [%s] to [%s] with MaxSurge [%d] & MaxUnavailable [%d]",
 		instanceGroupID, upgradeStrategy, MaxSurge, MaxUnavailable)
 
 	nodePoolPath := fmt.Sprintf("projects/%s/locations/%s/clusters/%s/nodePools/%s",
@@ -912,6 +1664,149 @@ func (s *gceOps) SetInstanceUpgradeStrategy(instanceGroupID string,
 	return s.WaitForOperationCompletion(operation, zonalCluster, timeout)
 }
 
+// SetInstanceGroupNodeLabels sets the kubernetes labels of the nodes in the node pool
+func (s *gceOps) SetInstanceGroupNodeLabels(instanceGroupID string,
+	labels map[string]string,
+	timeout time.Duration) error {
+	// node labels are not available in the container client library
+	return s.updateNodePoolConfig(instanceGroupID, map[string]interface{}{
+		"labels": map[string]interface{}{
+			"labels": labels,
+		},
+	}, timeout)
+}
+
+// SetInstanceGroupNodeTaints sets the kubernetes taints of the nodes in the node pool
+func (s *gceOps) SetInstanceGroupNodeTaints(instanceGroupID string,
+	taints []cloudops.NodeTaint,
+	timeout time.Duration) error {
+	nodeTaints := make([]*container.NodeTaint, 0, len(taints))
+	for _, taint := range taints {
+		effect, err := nodeTaintEffect(taint.Effect)
+		if err != nil {
+			return err
+		}
+		nodeTaints = append(nodeTaints, &container.NodeTaint{
+			Key:    taint.Key,
+			Value:  taint.Value,
+			Effect: effect,
+		})
+	}
+
+	// node taints are not available in the container client library
+	return s.updateNodePoolConfig(instanceGroupID, map[string]interface{}{
+		"taints": map[string]interface{}{
+			"taints": nodeTaints,
+		},
+	}, timeout)
+}
+
+// updateNodePoolConfig applies the given update to the node config of the node
+// pool and waits for the update to complete
+func (s *gceOps) updateNodePoolConfig(instanceGroupID string,
+	update map[string]interface{},
+	timeout time.Duration) error {
+	nodePool, err := s.getNodePool(instanceGroupID)
+	if err != nil {
+		return err
+	}
+
+	nodePoolPath := fmt.Sprintf("projects/%s/locations/%s/clusters/%s/nodePools/%s",
+		s.inst.project, s.inst.clusterLocation, s.inst.clusterName, instanceGroupID)
+
+	// the node version and image type are required by the update
+	update["name"] = nodePoolPath
+	update["nodeVersion"] = nodePool.Version
+	if nodePool.Config != nil {
+		update["imageType"] = nodePool.Config.ImageType
+	}
+
+	zonalCluster, err := isZonalCluster(s.inst.clusterLocation)
+	if err != nil {
+		return err
+	}
+
+	operation, err := s.doContainerRequest(http.MethodPut, "v1/"+nodePoolPath, update)
+	if err != nil {
+		return err
+	}
+
+	return s.WaitForOperationCompletion(operation, zonalCluster, timeout)
+}
+
+// nodeTaintEffect returns the GKE taint effect of the given kubernetes taint effect
+func nodeTaintEffect(effect cloudops.TaintEffect) (string, error) {
+	switch effect {
+	case cloudops.TaintEffectNoSchedule:
+		return "NO_SCHEDULE", nil
+	case cloudops.TaintEffectPreferNoSchedule:
+		return "PREFER_NO_SCHEDULE", nil
+	case cloudops.TaintEffectNoExecute:
+		return "NO_EXECUTE", nil
+	default:
+		return "", fmt.Errorf("invalid taint effect: %s", effect)
+	}
+}
+
+// RollInstanceGroup recreates the nodes of the node pool by upgrading it to its
+// current version with the given surge settings
+func (s *gceOps) RollInstanceGroup(instanceGroupID string, opts cloudops.RollOpts) error {
+	nodePool, err := s.getNodePool(instanceGroupID)
+	if err != nil {
+		return err
+	}
+
+	s.log("RollInstanceGroup", "").Infof("Rolling instance group [%s] at version [%s] with MaxSurge [%d] & MaxUnavailable [%d]",
+		instanceGroupID, nodePool.Version, opts.MaxSurge, opts.MaxUnavailable)
+
+	updateNodePoolRequest := &container.UpdateNodePoolRequest{
+		NodeVersion: nodePool.Version,
+		UpgradeSettings: &container.UpgradeSettings{
+			MaxSurge:        opts.MaxSurge,
+			MaxUnavailable:  opts.MaxUnavailable,
+			ForceSendFields: []string{"MaxSurge", "MaxUnavailable"},
+		},
+	}
+	if nodePool.Config != nil {
+		updateNodePoolRequest.ImageType = nodePool.Config.ImageType
+	}
+	return s.updateNodePool(instanceGroupID, updateNodePoolRequest, opts.Timeout)
+}
+
+// updateNodePool sends the update request for the node pool and waits for the
+// operation to complete, unless timeout is 0
+func (s *gceOps) updateNodePool(instanceGroupID string,
+	updateNodePoolRequest *container.UpdateNodePoolRequest,
+	timeout time.Duration) error {
+	nodePoolPath := fmt.Sprintf("projects/%s/locations/%s/clusters/%s/nodePools/%s",
+		s.inst.project, s.inst.clusterLocation, s.inst.clusterName, instanceGroupID)
+	updateNodePoolRequest.Name = nodePoolPath
+
+	zonalCluster, err := isZonalCluster(s.inst.clusterLocation)
+	if err != nil {
+		return err
+	}
+
+	var operation *container.Operation
+	if zonalCluster {
+		operation, err = s.containerService.Projects.Zones.Clusters.NodePools.Update(s.inst.project,
+			s.inst.clusterLocation,
+			s.inst.clusterName,
+			instanceGroupID,
+			updateNodePoolRequest).Do()
+	} else {
+		operation, err = s.containerService.Projects.Locations.Clusters.NodePools.Update(
+			nodePoolPath,
+			updateNodePoolRequest).Do()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return s.WaitForOperationCompletion(operation, zonalCluster, timeout)
+}
+
 // SetInstanceGroupSize sets node count for a instance group.
 // Count here is per availability zone
 func (s *gceOps) SetInstanceGroupSize(instanceGroupID string,
@@ -1029,170 +1924,920 @@ func (s *gceOps) WaitForOperationCompletion(operation *container.Operation,
 					s.inst.clusterName, cluster.Status)
 		}
 
-		_, err = task.DoRetryWithTimeout(f, timeout, retrySeconds*time.Second)
+		_, err = task.DoRetryWithTimeout(f, timeout, retrySeconds*time.Second)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetInstanceGroupZones returns the zones of the instance groups of the node pool
+func (s *gceOps) GetInstanceGroupZones(instanceGroupID string) ([]string, error) {
+	nodePool, err := s.getNodePool(instanceGroupID)
+	if err != nil {
+		return nil, err
+	}
+
+	zones := make([]string, 0, len(nodePool.InstanceGroupUrls))
+	seen := make(map[string]bool)
+	for _, instanceGroupURL := range nodePool.InstanceGroupUrls {
+		zone, _, err := parseInstanceGroupURL(instanceGroupURL)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[zone] {
+			seen[zone] = true
+			zones = append(zones, zone)
+		}
+	}
+	return zones, nil
+}
+
+func (s *gceOps) GetInstanceGroupSize(instanceGroupID string) (int64, error) {
+	nodePool, err := s.getNodePool(instanceGroupID)
+	if err != nil {
+		return 0, err
+	}
+
+	nodeCount := int64(0)
+	for _, instanceGroupURL := range nodePool.InstanceGroupUrls {
+		zone, nodeGrpName, err := parseInstanceGroupURL(instanceGroupURL)
+		if err != nil {
+			return int64(0), err
+		}
+
+		instGroup, err := s.computeService.InstanceGroups.Get(s.inst.project, zone, nodeGrpName).Do()
+		if err != nil {
+			return 0, err
+		}
+		nodeCount = nodeCount + instGroup.Size
+	}
+
+	return nodeCount, nil
+}
+
+func (s *gceOps) ListInstanceGroupMembers(instanceGroupID string) ([]string, error) {
+	nodePool, err := s.getNodePool(instanceGroupID)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]string, 0)
+	for _, instanceGroupURL := range nodePool.InstanceGroupUrls {
+		zone, nodeGrpName, err := parseInstanceGroupURL(instanceGroupURL)
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.computeService.InstanceGroupManagers.ListManagedInstances(s.inst.project, zone, nodeGrpName).Pages(
+			context.Background(),
+			func(resp *compute.InstanceGroupManagersListManagedInstancesResponse) error {
+				for _, managedInstance := range resp.ManagedInstances {
+					members = append(members, path.Base(managedInstance.Instance))
+				}
+				return nil
+			})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return members, nil
+}
+
+// getNodePool returns the node pool with the given name of the cluster
+func (s *gceOps) getNodePool(nodePoolName string) (*container.NodePool, error) {
+	zonalCluster, err := isZonalCluster(s.inst.clusterLocation)
+	if err != nil {
+		return nil, err
+	}
+
+	if zonalCluster {
+		return s.containerService.Projects.Zones.Clusters.NodePools.Get(
+			s.inst.project, s.inst.clusterLocation, s.inst.clusterName, nodePoolName).Do()
+	}
+	nodePoolPath := fmt.Sprintf("projects/%s/locations/%s/clusters/%s/nodePools/%s",
+		s.inst.project, s.inst.clusterLocation, s.inst.clusterName, nodePoolName)
+	return s.containerService.Projects.Locations.Clusters.NodePools.Get(nodePoolPath).Do()
+}
+
+func (s *gceOps) getCluster() (*container.Cluster, error) {
+	zonalCluster, err := isZonalCluster(s.inst.clusterLocation)
+	if err != nil {
+		return nil, err
+	}
+
+	if zonalCluster {
+		return s.containerService.Projects.Zones.Clusters.Get(
+			s.inst.project, s.inst.clusterLocation, s.inst.clusterName).Do()
+	}
+	clusterPath := fmt.Sprintf("projects/%s/locations/%s/clusters/%s",
+		s.inst.project, s.inst.clusterLocation, s.inst.clusterName)
+	return s.containerService.Projects.Locations.Clusters.Get(clusterPath).Do()
+}
+
+// parseInstanceGroupURL returns the zone and name of the instance group from
+// the given instance group URL
+func parseInstanceGroupURL(instanceGroupURL string) (string, string, error) {
+	var zoneInfo, zone string
+	nodeGrpName := strings.TrimSpace(filepath.Base(instanceGroupURL))
+
+	temp := strings.SplitAfter(instanceGroupURL, "zones")
+	if len(temp) > 1 {
+		zoneInfo = temp[1]
+	} else {
+		return "", "", fmt.Errorf("no zone information found from instance group url")
+	}
+
+	temp = strings.Split(zoneInfo, "/")
+	if len(temp) > 1 {
+		zone = temp[1]
+	} else {
+		return "", "", fmt.Errorf("no zone information found from instance group url")
+	}
+	return zone, nodeGrpName, nil
+}
+
+func (s *gceOps) GetClusterSizeForInstance(instanceID string) (int64, error) {
+	groupInfo, err := s.InspectInstanceGroupForInstance(instanceID)
+	if err != nil {
+		return int64(0), err
+	}
+
+	clusterName, err := s.getClusterName()
+	if err != nil {
+		return int64(0), err
+	}
+
+	var cluster *container.Cluster
+	if groupInfo.Zone != "" {
+		// Zonal cluster
+		cluster, err = s.containerService.Projects.Zones.Clusters.Get(s.inst.project,
+			groupInfo.Zone,
+			clusterName).Do()
+	} else {
+		// Regional cluster
+		clusterPath := fmt.Sprintf("projects/%s/locations/%s/clusters/%s",
+			s.inst.project, groupInfo.Region, clusterName)
+
+		cluster, err = s.containerService.Projects.Locations.Clusters.Get(clusterPath).Do()
+	}
+
+	if err != nil {
+		return int64(0), err
+	}
+
+	return cluster.CurrentNodeCount, nil
+}
+
+func (s *gceOps) getClusterName() (string, error) {
+
+	inst, err := s.computeService.Instances.Get(s.inst.project, s.inst.zone, s.inst.name).Do()
+	if err != nil {
+		return "", err
+	}
+
+	meta := inst.Metadata
+	if meta == nil {
+		return "", fmt.Errorf("instance doesn't have metadata set")
+	}
+
+	for _, item := range meta.Items {
+		if item == nil {
+			continue
+		}
+
+		if item.Key == clusterNameKey {
+			if item.Value == nil {
+				return "", fmt.Errorf("instance has %s key in metadata but has invalid value", clusterNameKey)
+			}
+
+			return *item.Value, nil
+		}
+	}
+	return "", fmt.Errorf("cluster name not found for instance [%s]", s.inst.name)
+}
+
+func (s *gceOps) Snapshot(
+	disk string,
+	readonly bool,
+	options map[string]string,
+) (interface{}, error) {
+	return s.SnapshotWithOptions(disk, readonly, cloudops.SnapshotOptions{Options: options})
+}
+
+func (s *gceOps) SnapshotWithOptions(
+	disk string,
+	readonly bool,
+	opts cloudops.SnapshotOptions,
+) (interface{}, error) {
+	if err := cloudops.ValidateVolumeID(disk); err != nil {
+		return nil, err
+	}
+
+	wait, err := cloudops.WaitForSnapshot(opts.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	// the snapshot carries the labels of the disk so that it can be found by
+	// the same labels, the given labels take precedence
+	vol, err := s.getDisk(disk)
+	if err != nil {
+		return nil, err
+	}
+	labels := s.formatLabels(vol.Labels)
+	for k, v := range s.formatLabels(opts.Labels) {
+		labels[k] = v
+	}
+
+	rb := &compute.Snapshot{
+		Name:   opts.Name,
+		Labels: labels,
+	}
+	if len(rb.Name) == 0 {
+		rb.Name = cloudops.SnapshotName()
+	}
+
+	operation, err := s.computeService.Disks.CreateSnapshot(s.inst.project, s.inst.zone, disk, rb).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	if !wait {
+		// The snapshot is still being created, return it as soon as it exists
+		return s.getCreatingSnapshot(rb.Name)
+	}
+
+	if opErr := s.waitForOpCompletion("disk.CreateSnapshot", s.inst.zone, operation); opErr != nil {
+		return nil, opErr
+	}
+
+	if err = s.checkSnapStatus(rb.Name, StatusReady); err != nil {
+		return nil, err
+	}
+
+	snap, err := s.computeService.Snapshots.Get(s.inst.project, rb.Name).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	return snap, err
+}
+
+func (s *gceOps) SnapshotDelete(snapID string, options map[string]string) error {
+	if err := cloudops.ValidateVolumeID(snapID); err != nil {
+		return err
+	}
+
+	operation, err := s.computeService.Snapshots.Delete(s.inst.project, snapID).Do()
+	if err != nil {
+		return err
+	}
+	return s.waitForOpCompletion("snapshot.Delete", s.inst.zone, operation)
+}
+
+func (s *gceOps) DeleteSnapshotChain(snapIDs []string) error {
+	snaps := make([]*cloudops.SnapshotInfo, 0, len(snapIDs))
+	for _, snapID := range snapIDs {
+		if err := cloudops.ValidateVolumeID(snapID); err != nil {
+			return err
+		}
+
+		snap, err := s.computeService.Snapshots.Get(s.inst.project, snapID).Do()
+		if err != nil {
+			return err
+		}
+		info, err := snapshotInfoFromSnapshot(snap)
+		if err != nil {
+			return err
+		}
+		snaps = append(snaps, info)
+	}
+
+	return cloudops.DeleteSnapshotChain(snaps, func(snapID string) error {
+		return s.SnapshotDelete(snapID, nil)
+	})
+}
+
+func (s *gceOps) GetStoragePressure(instanceID string) ([]cloudops.VolumePressureInfo, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "GetStoragePressure",
+	}
+}
+
+// gceDiskTypeLimits are the documented IOPS limits of the persistent disk
+// types. The disk type catalog only reports the valid disk sizes.
+var gceDiskTypeLimits = map[string]cloudops.StorageDecisionMatrixRow{
+	"pd-standard": {MinIOPS: 0, MaxIOPS: 7500, IOPSPerGiB: 0.75},
+	"pd-balanced": {MinIOPS: 3000, MaxIOPS: 80000, IOPSPerGiB: 6, Priority: 1},
+	"pd-ssd":      {MinIOPS: 6000, MaxIOPS: 100000, IOPSPerGiB: 30, Priority: 1},
+	"pd-extreme":  {MinIOPS: 10000, MaxIOPS: 120000, Priority: 2},
+}
+
+// validDiskSizeRegex matches the valid disk sizes of a disk type, such as
+// "10GB-65536GB"
+var validDiskSizeRegex = regexp.MustCompile(`^(\d+)GB-(\d+)GB$`)
+
+// ListDiskTypes lists the disk types available in any of the zones of the
+// given region. Disk types without documented IOPS limits, such as the
+// hyperdisk types, are skipped.
+func (s *gceOps) ListDiskTypes(region string) ([]cloudops.StorageDecisionMatrixRow, error) {
+	diskTypes := make(map[string]*compute.DiskType)
+	zonePrefix := "zones/" + region + "-"
+	err := s.computeService.DiskTypes.AggregatedList(s.inst.project).Pages(
+		context.Background(),
+		func(page *compute.DiskTypeAggregatedList) error {
+			for scope, list := range page.Items {
+				if !strings.HasPrefix(scope, zonePrefix) {
+					continue
+				}
+				for _, diskType := range list.DiskTypes {
+					if diskType.Deprecated == nil {
+						diskTypes[diskType.Name] = diskType
+					}
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []cloudops.StorageDecisionMatrixRow
+	for name, diskType := range diskTypes {
+		row, ok := gceDiskTypeLimits[name]
+		if !ok {
+			s.log("ListDiskTypes", "").Debugf("skipping disk type %s without documented IOPS limits", name)
+			continue
+		}
+		sizes := validDiskSizeRegex.FindStringSubmatch(diskType.ValidDiskSize)
+		if sizes == nil {
+			return nil, fmt.Errorf("invalid size range %q of disk type %s", diskType.ValidDiskSize, name)
+		}
+		row.MinSize, _ = strconv.ParseUint(sizes[1], 10, 64)
+		row.MaxSize, _ = strconv.ParseUint(sizes[2], 10, 64)
+		if minIOPS := uint64(row.IOPSPerGiB * float64(row.MinSize)); minIOPS > row.MinIOPS {
+			row.MinIOPS = minIOPS
+		}
+		row.DriveType = name
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// gceDiskQuotaMetrics are the regional quota metrics the capacity of the
+// persistent disk types is accounted against
+var gceDiskQuotaMetrics = map[string]string{
+	"pd-standard": "DISKS_TOTAL_GB",
+	"pd-balanced": "SSD_TOTAL_GB",
+	"pd-ssd":      "SSD_TOTAL_GB",
+}
+
+// CheckVolumeQuota checks the capacity of the given storage pools against the
+// disk quotas of the region of the instance. Disk types without a known quota
+// metric are not checked.
+func (s *gceOps) CheckVolumeQuota(required []*cloudops.StoragePoolSpec) error {
+	requiredGB := make(map[string]uint64)
+	for _, spec := range required {
+		// drive types can be given as the URL of the disk type
+		metric, ok := gceDiskQuotaMetrics[path.Base(spec.DriveType)]
+		if !ok {
+			s.log("CheckVolumeQuota", "").Debugf("skipping disk type %s without a known quota", spec.DriveType)
+			continue
+		}
+		requiredGB[metric] += spec.DriveCapacityGiB * spec.TotalDriveCount()
+	}
+	if len(requiredGB) == 0 {
+		return nil
+	}
+
+	region, err := s.computeService.Regions.Get(s.inst.project, s.inst.region).Do()
+	if err != nil {
+		return err
+	}
+
+	var breaches []cloudops.QuotaBreach
+	for _, quota := range region.Quotas {
+		req, ok := requiredGB[quota.Metric]
+		if !ok || quota.Usage+float64(req) <= quota.Limit {
+			continue
+		}
+		breaches = append(breaches, cloudops.QuotaBreach{
+			Quota:    quota.Metric,
+			Limit:    uint64(quota.Limit),
+			Usage:    uint64(quota.Usage),
+			Required: req,
+		})
+	}
+	if len(breaches) > 0 {
+		return &cloudops.ErrVolumeQuotaExceeded{Quotas: breaches}
+	}
+	return nil
+}
+
+func (s *gceOps) Tags(diskName string) (map[string]string, error) {
+	if err := cloudops.ValidateVolumeID(diskName); err != nil {
+		return nil, err
+	}
+
+	d, err := s.getDisk(diskName)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.Labels, nil
+}
+
+func (s *gceOps) ReconcileDataDisks(instanceID string) ([]string, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "ReconcileDataDisks",
+	}
+}
+
+// GetEffectivePerformance returns the IOPS and throughput provisioned for the
+// hyperdisk. The performance of persistent disks follows from their type and
+// size rather than being provisioned, so it is not supported for them.
+func (s *gceOps) GetEffectivePerformance(diskName string) (uint64, uint64, error) {
+	if err := cloudops.ValidateVolumeID(diskName); err != nil {
+		return 0, 0, err
+	}
+
+	// the provisioned performance of hyperdisks is not available in the
+	// compute client library
+	disk := &hyperdiskPerformance{}
+	if err := s.doRequest(http.MethodGet,
+		s.computeService.BasePath+s.diskURLPath(diskName), nil, disk); err != nil {
+		return 0, 0, err
+	}
+
+	if !strings.HasPrefix(path.Base(disk.Type), hyperdiskPrefix) {
+		return 0, 0, &cloudops.ErrNotSupported{
+			Operation: "GetEffectivePerformance",
+			Reason:    fmt.Sprintf("disk type %s has no provisioned performance", path.Base(disk.Type)),
+		}
+	}
+	return uint64(disk.ProvisionedIops), uint64(disk.ProvisionedThroughput), nil
+}
+
+func (s *gceOps) ConfigureReplication(
+	diskName string,
+	targetRegion string,
+	options map[string]string,
+) error {
+	if err := cloudops.ValidateVolumeID(diskName); err != nil {
+		return err
+	}
+
+	d, err := s.computeService.Disks.Get(s.inst.project, s.inst.zone, diskName).Do()
+	if err != nil {
+		return err
+	}
+
+	targetZone := options[ReplicationTargetZoneKey]
+	if len(targetZone) == 0 {
+		zoneSuffix := s.inst.zone[strings.LastIndex(s.inst.zone, "-")+1:]
+		targetZone = fmt.Sprintf("%s-%s", targetRegion, zoneSuffix)
+	}
+	if !strings.HasPrefix(targetZone, targetRegion) {
+		return cloudops.NewStorageError(cloudops.ErrVolInval,
+			fmt.Sprintf("target zone %s is not in target region %s", targetZone, targetRegion), "")
+	}
+
+	secondaryName := options[ReplicationTargetDiskKey]
+	if len(secondaryName) == 0 {
+		secondaryName = fmt.Sprintf("%s-replica", diskName)
+	}
+
+	// The secondary disk has to be created with the primary disk as its async
+	// primary before replication can be started.
+	secondary := map[string]interface{}{
+		"name":   secondaryName,
+		"sizeGb": strconv.FormatInt(d.SizeGb, 10),
+		"type": fmt.Sprintf("projects/%s/zones/%s/diskTypes/%s",
+			s.inst.project, targetZone, path.Base(d.Type)),
+		"labels": d.Labels,
+		"asyncPrimaryDisk": map[string]string{
+			"disk": d.SelfLink,
+		},
+	}
+	operation, err := s.doComputeRequest(http.MethodPost,
+		fmt.Sprintf("%s/zones/%s/disks", s.inst.project, targetZone), secondary)
+	if err != nil {
+		return err
+	}
+	if opErr := s.waitForOpCompletion("disk.CreateSecondary", targetZone, operation); opErr != nil {
+		return opErr
+	}
+
+	operation, err = s.doComputeRequest(http.MethodPost,
+		fmt.Sprintf("%s/zones/%s/disks/%s/startAsyncReplication", s.inst.project, s.inst.zone, diskName),
+		map[string]string{
+			"asyncSecondaryDisk": fmt.Sprintf("projects/%s/zones/%s/disks/%s",
+				s.inst.project, targetZone, secondaryName),
+		})
+	if err != nil {
+		return err
+	}
+	return s.waitForOpCompletion("disk.StartAsyncReplication", s.inst.zone, operation)
+}
+
+func (s *gceOps) StopReplication(diskName string) error {
+	if err := cloudops.ValidateVolumeID(diskName); err != nil {
+		return err
+	}
+
+	operation, err := s.doComputeRequest(http.MethodPost,
+		fmt.Sprintf("%s/zones/%s/disks/%s/stopAsyncReplication", s.inst.project, s.inst.zone, diskName),
+		nil)
+	if err != nil {
+		return err
+	}
+	return s.waitForOpCompletion("disk.StopAsyncReplication", s.inst.zone, operation)
+}
+
+// EnumerateSnapshots returns the snapshots matching the labels whose source
+// disk is one of the given disks
+func (s *gceOps) EnumerateSnapshots(volumeIDs []*string, labels map[string]string) ([]*cloudops.SnapshotInfo, error) {
+	if err := cloudops.ValidateVolumeIDs(volumeIDs); err != nil {
+		return nil, err
+	}
+
+	req := s.computeService.Snapshots.List(s.inst.project)
+	if len(labels) > 0 {
+		// labels are stored formatted, see formatLabels
+		req = req.Filter(generateListFilterFromLabels(s.formatLabels(labels)))
+	}
+
+	volumes := make(map[string]bool, len(volumeIDs))
+	for _, volumeID := range volumeIDs {
+		volumes[*volumeID] = true
+	}
+	snapshots := make([]*cloudops.SnapshotInfo, 0)
+	if err := req.Pages(context.Background(), func(page *compute.SnapshotList) error {
+		for _, snap := range page.Items {
+			info, err := snapshotInfoFromSnapshot(snap)
+			if err != nil {
+				return err
+			}
+			if len(volumes) > 0 && !volumes[info.VolumeID] {
+				continue
+			}
+			snapshots = append(snapshots, info)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+func (s *gceOps) ListSnapshots(labels map[string]string) ([]*cloudops.SnapshotInfo, error) {
+	return s.EnumerateSnapshots(nil, labels)
+}
+
+func (s *gceOps) InspectSnapshot(snapID string) (*cloudops.SnapshotInfo, error) {
+	if err := cloudops.ValidateVolumeID(snapID); err != nil {
+		return nil, err
+	}
+
+	snap, err := s.computeService.Snapshots.Get(s.inst.project, snapID).Do()
+	if isNotFoundError(err) {
+		return nil, cloudops.NewStorageError(cloudops.ErrVolNotFound,
+			fmt.Sprintf("snapshot %s not found", snapID), s.inst.name)
+	} else if err != nil {
+		return nil, err
+	}
+	return snapshotInfoFromSnapshot(snap)
+}
+
+func (s *gceOps) ReportCapacityByLabel(labelKey string) (map[string]uint64, error) {
+	disks, err := s.getDisksFromAllZones(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// label keys are stored formatted, see formatLabelKey
+	labelKey = formatLabelKey(labelKey)
+	capacities := make(map[string]uint64)
+	for _, disk := range disks {
+		value, ok := disk.Labels[labelKey]
+		if !ok {
+			continue
+		}
+		capacities[value] += uint64(disk.SizeGb)
+	}
+	return capacities, nil
+}
+
+func (s *gceOps) UpdateAttachmentCaching(instanceID, diskName, caching string) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "UpdateAttachmentCaching",
+	}
+}
+
+func (s *gceOps) ModifyVolumeType(volumeID, newType string, iops, throughput *int64) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "ModifyVolumeType",
+		Reason:    "the type of a persistent disk cannot be changed in place",
+	}
+}
+
+func (s *gceOps) ExpandMany(volumeIDs []string, newSizeInGiB uint64, options map[string]string) (map[string]uint64, error) {
+	return cloudops.ExpandVolumes(s.Expand, volumeIDs, newSizeInGiB, options)
+}
+
+func (s *gceOps) DeleteVolumes(volumeIDs []string) (map[string]error, error) {
+	return cloudops.DeleteVolumes(s.Delete, isFatalError, volumeIDs)
+}
+
+// GetVolumeQoS returns the IOPS and throughput limits of the hyperdisk, which
+// are the performance provisioned for it
+func (s *gceOps) GetVolumeQoS(diskName string) (uint64, uint64, error) {
+	return s.GetEffectivePerformance(diskName)
+}
+
+// LockVolume locks the disk by labeling it with the owner, in the form
+// returned by lockOwnerLabel. The labels are set conditionally on the label
+// fingerprint of the disk, so when owners race only the first update succeeds
+// and the others find the disk locked when they re-read it.
+func (s *gceOps) LockVolume(diskName, owner string) (bool, error) {
+	if err := cloudops.ValidateVolumeID(diskName); err != nil {
+		return false, err
+	}
+
+	owner = lockOwnerLabel(owner)
+	for attempt := 1; ; attempt++ {
+		d, err := s.getDisk(diskName)
+		if err != nil {
+			return false, err
+		}
+
+		if holder, ok := d.Labels[cloudops.VolumeLockTagKey]; ok {
+			return holder == owner, nil
+		}
+
+		labels := make(map[string]string, len(d.Labels)+1)
+		for k, v := range d.Labels {
+			labels[k] = v
+		}
+		labels[cloudops.VolumeLockTagKey] = owner
+
+		err = s.setDiskLabels(d, labels)
+		if isLabelFingerprintMismatch(err) && attempt < maxLabelUpdateAttempts {
+			continue
+		}
+		return err == nil, err
+	}
+}
+
+func (s *gceOps) UnlockVolume(diskName, owner string) error {
+	if err := cloudops.ValidateVolumeID(diskName); err != nil {
+		return err
+	}
+
+	owner = lockOwnerLabel(owner)
+	for attempt := 1; ; attempt++ {
+		d, err := s.getDisk(diskName)
 		if err != nil {
 			return err
 		}
-	}
-	return nil
-}
 
-func (s *gceOps) GetInstanceGroupSize(instanceGroupID string) (int64, error) {
+		holder, ok := d.Labels[cloudops.VolumeLockTagKey]
+		if !ok {
+			return nil
+		}
+		if holder != owner {
+			return cloudops.NewStorageError(cloudops.ErrVolumeLocked,
+				fmt.Sprintf("disk %s is locked by %s", diskName, holder), s.inst.name)
+		}
 
-	zonalCluster, err := isZonalCluster(s.inst.clusterLocation)
-	if err != nil {
-		return 0, err
-	}
+		labels := make(map[string]string, len(d.Labels))
+		for k, v := range d.Labels {
+			labels[k] = v
+		}
+		delete(labels, cloudops.VolumeLockTagKey)
 
-	var nodePool *container.NodePool
-	if zonalCluster {
-		nodePool, err = s.containerService.Projects.Zones.Clusters.NodePools.Get(
-			s.inst.project, s.inst.clusterLocation, s.inst.clusterName, instanceGroupID).Do()
-	} else {
-		nodePoolPath := fmt.Sprintf("projects/%s/locations/%s/clusters/%s/nodePools/%s",
-			s.inst.project, s.inst.clusterLocation, s.inst.clusterName, instanceGroupID)
-		nodePool, err = s.containerService.Projects.Locations.Clusters.NodePools.Get(nodePoolPath).Do()
+		err = s.setDiskLabels(d, labels)
+		if isLabelFingerprintMismatch(err) && attempt < maxLabelUpdateAttempts {
+			continue
+		}
+		return err
 	}
+}
 
-	if err != nil {
-		return 0, err
+// AdoptVolume labels the given disk as managed by cloudops. The disk must be
+// ready and either detached or only attached to the local instance.
+func (s *gceOps) AdoptVolume(diskName string, labels map[string]string) error {
+	if err := cloudops.ValidateVolumeID(diskName); err != nil {
+		return err
 	}
 
-	nodeCount := int64(0)
-	for _, instanceGroupURL := range nodePool.InstanceGroupUrls {
-
-		var zoneInfo, zone string
-		nodeGrpName := strings.TrimSpace(filepath.Base(instanceGroupURL))
+	for attempt := 1; ; attempt++ {
+		d, err := s.getDisk(diskName)
+		if gerr, ok := err.(*googleapi.Error); ok &&
+			gerr.Code == http.StatusNotFound {
+			return cloudops.NewStorageError(
+				cloudops.ErrVolNotFound,
+				fmt.Sprintf("Disk: %s not found in zone %s", diskName, s.inst.zone),
+				s.inst.name)
+		} else if err != nil {
+			return err
+		}
 
-		temp := strings.SplitAfter(instanceGroupURL, "zones")
-		if len(temp) > 1 {
-			zoneInfo = temp[1]
-		} else {
-			return int64(0), fmt.Errorf("no zone information found from instance group url")
+		if d.Status != "READY" {
+			return cloudops.NewStorageError(cloudops.ErrVolInval,
+				fmt.Sprintf("disk %s cannot be adopted in state %s", d.Name, d.Status), s.inst.name)
+		}
+		for _, user := range d.Users {
+			if path.Base(user) != s.inst.name {
+				return cloudops.NewStorageError(
+					cloudops.ErrVolAttachedOnRemoteNode,
+					fmt.Sprintf("disk %s is attached on: %v", d.Name, d.Users),
+					s.inst.name)
+			}
 		}
 
-		temp = strings.Split(zoneInfo, "/")
-		if len(temp) > 1 {
-			zone = temp[1]
-		} else {
-			return int64(0), fmt.Errorf("no zone information found from instance group url")
+		newLabels := make(map[string]string, len(d.Labels)+len(labels)+1)
+		for k, v := range d.Labels {
+			newLabels[k] = v
+		}
+		for k, v := range s.formatLabels(labels) {
+			newLabels[k] = v
 		}
+		newLabels[cloudops.VolumeManagedTagKey] = "true"
 
-		instGroup, err := s.computeService.InstanceGroups.Get(s.inst.project, zone, nodeGrpName).Do()
-		if err != nil {
-			return 0, err
+		err = s.setDiskLabels(d, newLabels)
+		if isLabelFingerprintMismatch(err) && attempt < maxLabelUpdateAttempts {
+			continue
 		}
-		nodeCount = nodeCount + instGroup.Size
+		return err
 	}
-
-	return nodeCount, nil
 }
 
-func (s *gceOps) GetClusterSizeForInstance(instanceID string) (int64, error) {
-	groupInfo, err := s.InspectInstanceGroupForInstance(instanceID)
-	if err != nil {
-		return int64(0), nil
-	}
-
-	clusterName, err := s.getClusterName()
+// GetStorageLayout returns the specs of the data disks attached to the given
+// instance in the zone of the client
+func (s *gceOps) GetStorageLayout(instanceID string) ([]cloudops.VolumeSpec, error) {
+	inst, err := s.computeService.Instances.Get(s.inst.project, s.inst.zone, instanceID).Do()
 	if err != nil {
-		return int64(0), nil
+		return nil, err
 	}
 
-	var cluster *container.Cluster
-	if groupInfo.Zone != "" {
-		// Zonal cluster
-		cluster, err = s.containerService.Projects.Zones.Clusters.Get(s.inst.project,
-			groupInfo.Zone,
-			clusterName).Do()
-	} else {
-		// Regional cluster
-		clusterPath := fmt.Sprintf("projects/%s/locations/%s/clusters/%s",
-			s.inst.project, groupInfo.Region, clusterName)
-
-		cluster, err = s.containerService.Projects.Locations.Clusters.Get(clusterPath).Do()
+	attached := make([]*compute.AttachedDisk, 0, len(inst.Disks))
+	for _, d := range inst.Disks {
+		if !d.Boot && d.Type == "PERSISTENT" {
+			attached = append(attached, d)
+		}
 	}
+	sort.Slice(attached, func(i, j int) bool {
+		return attached[i].Index < attached[j].Index
+	})
 
-	if err != nil {
-		return int64(0), err
+	specs := make([]cloudops.VolumeSpec, 0, len(attached))
+	for _, a := range attached {
+		d, err := s.getDisk(diskIDFromSource(a.Source))
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, cloudops.VolumeSpec{
+			SizeInGiB: uint64(d.SizeGb),
+			Type:      path.Base(d.Type),
+			Labels:    d.Labels,
+		})
 	}
+	return specs, nil
+}
 
-	return cluster.CurrentNodeCount, nil
+// ProvisionStorageLayout creates disks matching the given specs in the zone of
+// the client
+func (s *gceOps) ProvisionStorageLayout(specs []cloudops.VolumeSpec, labels map[string]string) ([]interface{}, error) {
+	return cloudops.CreateVolumes(s, specs, labels, func(spec cloudops.VolumeSpec) interface{} {
+		return &compute.Disk{
+			Name:   fmt.Sprintf("%s-%s", layoutDiskPrefix, uuid.New()),
+			SizeGb: int64(spec.SizeInGiB),
+			Type: fmt.Sprintf("projects/%s/zones/%s/diskTypes/%s",
+				s.inst.project, s.inst.zone, spec.Type),
+			Zone: s.inst.zone,
+		}
+	})
 }
 
-func (s *gceOps) getClusterName() (string, error) {
+// GetVolumeLineage returns the snapshot, image or disk the given disk was
+// created from. Snapshots and disks are identified by name as elsewhere in
+// cloudops, while images are identified by URL as they may belong to another
+// project.
+func (s *gceOps) GetVolumeLineage(volumeID string) (string, string, error) {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return "", "", err
+	}
 
-	inst, err := s.computeService.Instances.Get(s.inst.project, s.inst.zone, s.inst.name).Do()
+	disks, err := s.Inspect([]*string{&volumeID}, nil)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
+	d := disks[0].(*compute.Disk)
 
-	meta := inst.Metadata
-	if meta == nil {
-		return "", fmt.Errorf("instance doesn't have metadata set")
+	switch {
+	case len(d.SourceSnapshotId) != 0:
+		return cloudops.VolumeSourceSnapshot, path.Base(d.SourceSnapshot), nil
+	case len(d.SourceImageId) != 0:
+		return cloudops.VolumeSourceImage, d.SourceImage, nil
+	case len(d.SourceDiskId) != 0:
+		return cloudops.VolumeSourceClone, path.Base(d.SourceDisk), nil
 	}
+	return "", "", nil
+}
 
-	for _, item := range meta.Items {
-		if item == nil {
-			continue
+// setDiskLabels replaces the labels of the disk if they have not changed since
+// the disk was read
+func (s *gceOps) setDiskLabels(d *compute.Disk, labels map[string]string) error {
+	if len(d.Region) > 0 {
+		region := path.Base(d.Region)
+		rb := &compute.RegionSetLabelsRequest{
+			LabelFingerprint: d.LabelFingerprint,
+			Labels:           labels,
 		}
 
-		if item.Key == clusterNameKey {
-			if item.Value == nil {
-				return "", fmt.Errorf("instance has %s key in metadata but has invalid value", clusterNameKey)
-			}
-
-			return *item.Value, nil
+		operation, err := s.computeService.RegionDisks.SetLabels(s.inst.project, region, d.Name, rb).Do()
+		if err != nil {
+			return err
 		}
+		return s.waitForRegionOpCompletion("disk.SetLabels", region, operation)
 	}
-	return "", fmt.Errorf("cluster name not found for instance [%s]", s.inst.name)
-}
 
-func (s *gceOps) Snapshot(
-	disk string,
-	readonly bool,
-	options map[string]string,
-) (interface{}, error) {
-	rb := &compute.Snapshot{
-		Name: fmt.Sprintf("snap-%d%02d%02d", time.Now().Year(), time.Now().Month(), time.Now().Day()),
+	rb := &compute.ZoneSetLabelsRequest{
+		LabelFingerprint: d.LabelFingerprint,
+		Labels:           labels,
 	}
 
-	operation, err := s.computeService.Disks.CreateSnapshot(s.inst.project, s.inst.zone, disk, rb).Do()
+	operation, err := s.computeService.Disks.SetLabels(s.inst.project, s.inst.zone, d.Name, rb).Do()
 	if err != nil {
-		return nil, err
-	}
-
-	if opErr := s.waitForOpCompletion("disk.CreateSnapshot", s.inst.zone, operation); opErr != nil {
-		return nil, opErr
+		return err
 	}
+	return s.waitForOpCompletion("disk.SetLabels", s.inst.zone, operation)
+}
 
-	if err = s.checkSnapStatus(rb.Name, StatusReady); err != nil {
+// doComputeRequest issues a request against the compute API for calls that are
+// not available in the compute client library and returns the resulting operation
+func (s *gceOps) doComputeRequest(
+	method string,
+	urlPath string,
+	body interface{},
+) (*compute.Operation, error) {
+	operation := &compute.Operation{}
+	if err := s.doRequest(method, s.computeService.BasePath+urlPath, body, operation); err != nil {
 		return nil, err
 	}
+	return operation, nil
+}
 
-	snap, err := s.computeService.Snapshots.Get(s.inst.project, rb.Name).Do()
-	if err != nil {
+// doContainerRequest issues a request against the container API for calls that
+// are not available in the container client library and returns the resulting
+// operation
+func (s *gceOps) doContainerRequest(
+	method string,
+	urlPath string,
+	body interface{},
+) (*container.Operation, error) {
+	operation := &container.Operation{}
+	if err := s.doRequest(method, s.containerService.BasePath+urlPath, body, operation); err != nil {
 		return nil, err
 	}
-
-	return snap, err
+	return operation, nil
 }
 
-func (s *gceOps) SnapshotDelete(snapID string, options map[string]string) error {
-	operation, err := s.computeService.Snapshots.Delete(s.inst.project, snapID).Do()
+// doRequest issues a request against the given google API URL and decodes the
+// response into the given value
+func (s *gceOps) doRequest(
+	method string,
+	requestURL string,
+	body interface{},
+	out interface{},
+) error {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, requestURL, &reqBody)
 	if err != nil {
 		return err
 	}
-	return s.waitForOpCompletion("snapshot.Delete", s.inst.zone, operation)
-}
+	req.Header.Set("Content-Type", "application/json")
 
-func (s *gceOps) Tags(diskName string) (map[string]string, error) {
-	d, err := s.computeService.Disks.Get(s.inst.project, s.inst.zone, diskName).Do()
+	resp, err := s.httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	defer resp.Body.Close()
+	if err := googleapi.CheckResponse(resp); err != nil {
+		return err
 	}
 
-	return d.Labels, nil
+	return json.NewDecoder(resp.Body).Decode(out)
 }
 
 func (s *gceOps) available(v *compute.Disk) bool {
@@ -1220,12 +2865,56 @@ func (s *gceOps) checkDiskStatus(id string, zone string, desired string) error {
 
 			return nil, false, nil
 		},
-		cloudops.ProviderOpsTimeout,
-		cloudops.ProviderOpsRetryInterval)
+		s.opsTimeout.OpsTimeout(),
+		s.opsTimeout.OpsRetryInterval())
+
+	return err
+}
+
+func (s *gceOps) checkRegionalDiskStatus(id string, region string, desired string) error {
+	_, err := task.DoRetryWithTimeout(
+		func() (interface{}, bool, error) {
+			d, err := s.computeService.RegionDisks.Get(s.inst.project, region, id).Do()
+			if err != nil {
+				return nil, true, err
+			}
+
+			actual := strings.ToLower(d.Status)
+			if actual != desired {
+				return nil, true,
+					fmt.Errorf("invalid status: %s for disk: %s. expected: %s",
+						actual, id, desired)
+			}
+
+			return nil, false, nil
+		},
+		s.opsTimeout.OpsTimeout(),
+		s.opsTimeout.OpsRetryInterval())
 
 	return err
 }
 
+// getCreatingSnapshot returns the snapshot with the given name once it has been
+// created by its pending insert operation, regardless of its status
+func (s *gceOps) getCreatingSnapshot(name string) (*compute.Snapshot, error) {
+	snap, err := task.DoRetryWithTimeout(
+		func() (interface{}, bool, error) {
+			snap, err := s.computeService.Snapshots.Get(s.inst.project, name).Do()
+			if isNotFoundError(err) {
+				return nil, true, err
+			} else if err != nil {
+				return nil, false, err
+			}
+			return snap, false, nil
+		},
+		s.opsTimeout.OpsTimeout(),
+		s.opsTimeout.OpsRetryInterval())
+	if err != nil {
+		return nil, err
+	}
+	return snap.(*compute.Snapshot), nil
+}
+
 func (s *gceOps) checkSnapStatus(id string, desired string) error {
 	_, err := task.DoRetryWithTimeout(
 		func() (interface{}, bool, error) {
@@ -1247,8 +2936,8 @@ func (s *gceOps) checkSnapStatus(id string, desired string) error {
 
 			return nil, false, nil
 		},
-		cloudops.ProviderOpsTimeout,
-		cloudops.ProviderOpsRetryInterval)
+		s.opsTimeout.OpsTimeout(),
+		s.opsTimeout.OpsRetryInterval())
 
 	return err
 }
@@ -1262,8 +2951,26 @@ func (s *gceOps) describeinstance() (*compute.Instance, error) {
 	return s.computeService.Instances.Get(s.inst.project, s.inst.zone, s.inst.name).Do()
 }
 
-// gceInfo fetches the GCE instance metadata from the metadata server
+// gceInfo fetches the GCE instance metadata from the metadata server, or from
+// the process-wide metadata cache if it was fetched recently
 func gceInfo(ctx context.Context, inst *instance) error {
+	cached, err := cloudops.CachedMetadata(cloudops.GCE, func() (interface{}, error) {
+		i := new(instance)
+		if err := gceInfoFromMetadata(ctx, i); err != nil {
+			return nil, err
+		}
+		return i, nil
+	})
+	if err != nil {
+		return err
+	}
+	*inst = *cached.(*instance)
+	return nil
+}
+
+// gceInfoFromMetadata fetches the GCE instance metadata from the metadata server
+func gceInfoFromMetadata(ctx context.Context, inst *instance) error {
+	newClientLog := cloudops.ProviderLogger(nil, string(cloudops.GCE), "NewClient", "")
 	var err error
 	inst.zone, err = metadata.Zone()
 	if err != nil {
@@ -1290,19 +2997,19 @@ func gceInfo(ctx context.Context, inst *instance) error {
 	inst.clusterName, err = metadata.InstanceAttributeValue(clusterNameKey)
 	if err != nil {
 		// No need to error out for non-GKE compute instances
-		logrus.Warnf("no '%s' instance attribute found", clusterNameKey)
+		newClientLog.Warnf("no '%s' instance attribute found", clusterNameKey)
 	}
 
 	inst.clusterLocation, err = metadata.InstanceAttributeValue(clusterLocationKey)
 	if err != nil {
 		// No need to error out for non-GKE compute instances
-		logrus.Warnf("no '%s' instance attribute found", clusterLocationKey)
+		newClientLog.Warnf("no '%s' instance attribute found", clusterLocationKey)
 	}
 
 	kubeLabels, err := metadata.InstanceAttributeValue(kubeLabelsKey)
 	if err != nil {
 		// No need to error out for non-GKE compute instances
-		logrus.Warnf("no '%s' instance attribute found", kubeLabelsKey)
+		newClientLog.Warnf("no '%s' instance attribute found", kubeLabelsKey)
 	} else {
 		kubeLabelList, err := parser.LabelsFromString(kubeLabels)
 		if err != nil {
@@ -1328,7 +3035,7 @@ func gceInfo(ctx context.Context, inst *instance) error {
 		inst.serviceAccount, err = metadata.Email("")
 		if err != nil {
 			// No need to error out for non-GKE compute instances
-			logrus.Warnf("unable to get gce instance service account")
+			newClientLog.Warnf("unable to get gce instance service account")
 		}
 	}
 	return nil
@@ -1362,10 +3069,10 @@ func gceInfoFromEnv(inst *instance) error {
 }
 
 func (s *gceOps) rollbackCreate(id string, createErr error) error {
-	logrus.Warnf("Rollback create volume %v, Error %v", id, createErr)
+	s.log("Create", id).Warnf("Rollback create volume %v, Error %v", id, createErr)
 	err := s.Delete(id, nil)
 	if err != nil {
-		logrus.Warnf("Rollback failed volume %v, Error %v", id, err)
+		s.log("Create", id).Warnf("Rollback failed volume %v, Error %v", id, err)
 	}
 	return createErr
 }
@@ -1394,12 +3101,53 @@ func (s *gceOps) waitForDetach(
 			return nil, false, nil
 
 		},
-		cloudops.ProviderOpsTimeout,
-		cloudops.ProviderOpsRetryInterval)
+		s.opsTimeout.OpsTimeout(),
+		s.opsTimeout.OpsRetryInterval())
 
 	return err
 }
 
+// isLocalUser returns true if the local instance is one of the given disk users
+func (s *gceOps) isLocalUser(users []string) bool {
+	return hasUser(users, s.inst.name)
+}
+
+// hasUser returns true if one of the given disk users is the instance
+func hasUser(users []string, instanceName string) bool {
+	for _, user := range users {
+		if path.Base(user) == instanceName {
+			return true
+		}
+	}
+	return false
+}
+
+// waitForRemoteDetach waits for the disk returned by getDisk to be detached
+// from the remote instances it is attached to and returns the detached disk
+func (s *gceOps) waitForRemoteDetach(getDisk func() (*compute.Disk, error)) (*compute.Disk, error) {
+	d, err := task.DoRetryWithTimeout(
+		func() (interface{}, bool, error) {
+			d, err := getDisk()
+			if err != nil {
+				return nil, true, err
+			}
+			if len(d.Users) != 0 {
+				return nil, true, cloudops.NewStorageError(
+					cloudops.ErrVolAttachedOnRemoteNode,
+					fmt.Sprintf("disk %s is still attached on: %v", d.Name, d.Users),
+					s.inst.name)
+			}
+			return d, false, nil
+		},
+		s.opsTimeout.OpsTimeout(),
+		s.opsTimeout.OpsRetryInterval())
+	if err != nil {
+		return nil, err
+	}
+
+	return d.(*compute.Disk), nil
+}
+
 // waitForAttach checks if given disk is attached to the local instance
 func (s *gceOps) waitForAttach(
 	disk *compute.Disk,
@@ -1417,8 +3165,8 @@ func (s *gceOps) waitForAttach(
 
 			return devicePath, false, nil
 		},
-		cloudops.ProviderOpsTimeout,
-		cloudops.ProviderOpsRetryInterval)
+		s.opsTimeout.OpsTimeout(),
+		s.opsTimeout.OpsRetryInterval())
 	if err != nil {
 		return "", err
 	}
@@ -1437,11 +3185,33 @@ func (s *gceOps) waitForOpCompletion(
 	cloudopsOperationName string,
 	opZone string,
 	operation *compute.Operation,
+) error {
+	return s.waitForOperation(cloudopsOperationName, operation, func() (*compute.Operation, error) {
+		return s.computeService.ZoneOperations.Get(s.inst.project, opZone, operation.Name).Do()
+	})
+}
+
+// waitForRegionOpCompletion is the regional counterpart of waitForOpCompletion
+// and is used for operations on regional resources such as regional disks.
+func (s *gceOps) waitForRegionOpCompletion(
+	cloudopsOperationName string,
+	opRegion string,
+	operation *compute.Operation,
+) error {
+	return s.waitForOperation(cloudopsOperationName, operation, func() (*compute.Operation, error) {
+		return s.computeService.RegionOperations.Get(s.inst.project, opRegion, operation.Name).Do()
+	})
+}
+
+func (s *gceOps) waitForOperation(
+	cloudopsOperationName string,
+	operation *compute.Operation,
+	getOp func() (*compute.Operation, error),
 ) error {
 	_, gceOpErr := task.DoRetryWithTimeout(
 		func() (interface{}, bool, error) {
 			// get the status of the operation
-			op, err := s.computeService.ZoneOperations.Get(s.inst.project, opZone, operation.Name).Do()
+			op, err := getOp()
 			if err != nil {
 				// failed to get operation status
 				// check again later
@@ -1460,24 +3230,50 @@ func (s *gceOps) waitForOpCompletion(
 				return nil, true, fmt.Errorf("gce operation %v for %v not completed", operation.Name, cloudopsOperationName)
 			}
 
-			if op.Error != nil && len(op.Error.Errors) > 0 && op.Error.Errors[0] != nil {
+			if err := s.operationError(op); err != nil {
 				// operation is done
 				// and we got an error
-				return nil, false, &googleapi.Error{
-					Code:    int(op.HttpErrorStatusCode),
-					Message: fmt.Sprintf("%v - %v", op.Error.Errors[0].Code, op.Error.Errors[0].Message),
-				}
+				return nil, false, err
 			}
 			// operation is done with no error
-			logrus.Infof("gce operation %v for %v successfully completed", operation.Name, cloudopsOperationName)
+			s.log(cloudopsOperationName, "").Infof("gce operation %v for %v successfully completed", operation.Name, cloudopsOperationName)
 			return nil, false, nil
 		},
-		cloudops.ProviderOpsTimeout,
-		cloudops.ProviderOpsRetryInterval,
+		s.opsTimeout.OpsTimeout(),
+		s.opsTimeout.OpsRetryInterval(),
 	)
 	return gceOpErr
 }
 
+// operationErrorCodes maps the error codes of failed operations to cloudops
+// error codes
+var operationErrorCodes = map[string]int{
+	"QUOTA_EXCEEDED":                            cloudops.ErrQuotaExceeded,
+	"ZONE_RESOURCE_POOL_EXHAUSTED":              cloudops.ErrQuotaExceeded,
+	"ZONE_RESOURCE_POOL_EXHAUSTED_WITH_DETAILS": cloudops.ErrQuotaExceeded,
+	"RESOURCE_NOT_READY":                        cloudops.ErrOperationInProgress,
+	"RESOURCE_NOT_FOUND":                        cloudops.ErrVolNotFound,
+	"NOT_FOUND":                                 cloudops.ErrVolNotFound,
+}
+
+// operationError returns the error of a completed operation, or nil if it
+// succeeded. Errors with a code in operationErrorCodes are returned as a
+// cloudops.StorageError, and any other error as a googleapi.Error.
+func (s *gceOps) operationError(op *compute.Operation) error {
+	if op.Error == nil || len(op.Error.Errors) == 0 || op.Error.Errors[0] == nil {
+		return nil
+	}
+	opErr := op.Error.Errors[0]
+	msg := fmt.Sprintf("%v - %v", opErr.Code, opErr.Message)
+	if code, ok := operationErrorCodes[opErr.Code]; ok {
+		return cloudops.NewStorageError(code, msg, s.inst.name)
+	}
+	return &googleapi.Error{
+		Code:    int(op.HttpErrorStatusCode),
+		Message: msg,
+	}
+}
+
 // generateListFilterFromLabels create a filter string based off --filter documentation at
 // https://cloud.google.com/sdk/gcloud/reference/compute/disks/list
 func generateListFilterFromLabels(labels map[string]string) string {
@@ -1510,7 +3306,7 @@ func (s *gceOps) getDisksFromAllZones(labels map[string]string) (map[string]*com
 
 		return nil
 	}); err != nil {
-		logrus.Errorf("failed to list disks: %v", err)
+		s.log("Enumerate", "").Errorf("failed to list disks: %v", err)
 		return nil, err
 	}
 
@@ -1528,7 +3324,7 @@ func (s *gceOps) diskIDToBlockDevPathWithRetry(devPath string) (string, error) {
 		if path, err = s.diskIDToBlockDevPath(devPath); err == nil {
 			return path, nil
 		}
-		logrus.Warnf(err.Error())
+		s.log("DevicePath", devPath).Warnf(err.Error())
 		retryCount++
 		if retryCount >= devicePathMaxRetryCount {
 			break
@@ -1560,36 +3356,198 @@ func (s *gceOps) diskIDToBlockDevPath(devPath string) (string, error) {
 	return devPath, nil
 }
 
-func formatLabels(labels map[string]string) map[string]string {
+// guestAttributeDevicePath returns the device path of the given attached disk
+// published in the guest attributes, if reading them is enabled
+func (s *gceOps) guestAttributeDevicePath(d *compute.AttachedDisk) (string, bool) {
+	if s.guestAttributes == nil || len(d.DeviceName) == 0 {
+		return "", false
+	}
+
+	devPath, err := s.guestAttributes.Get(fmt.Sprintf("instance/guest-attributes/%s/%s",
+		guestAttributesDiskNamespace, d.DeviceName))
+	if err != nil {
+		if _, ok := err.(metadata.NotDefinedError); !ok {
+			s.log("DevicePath", d.DeviceName).Warnf("failed to read device path of disk %s from guest attributes: %v", d.DeviceName, err)
+		}
+		return "", false
+	}
+
+	devPath = strings.TrimSpace(devPath)
+	return devPath, len(devPath) > 0
+}
+
+// attachInterface returns the disk interface requested in the attach options
+func attachInterface(options map[string]string) (string, error) {
+	diskInterface, ok := options[AttachInterfaceKey]
+	if !ok || len(diskInterface) == 0 {
+		return interfaceSCSI, nil
+	}
+
+	diskInterface = strings.ToUpper(diskInterface)
+	if diskInterface != interfaceSCSI && diskInterface != interfaceNVME {
+		return "", cloudops.NewStorageError(cloudops.ErrVolInval,
+			fmt.Sprintf("invalid disk interface %s, must be one of %s or %s",
+				options[AttachInterfaceKey], interfaceSCSI, interfaceNVME), "")
+	}
+	return diskInterface, nil
+}
+
+// diskPathByID returns the by-id path of the given attached disk based on the
+// interface it is attached over
+func diskPathByID(d *compute.AttachedDisk) string {
+	if d.Interface == interfaceNVME {
+		return fmt.Sprintf("%s%s", googleNvmeDiskPrefix, d.DeviceName)
+	}
+	return fmt.Sprintf("%s%s", googleDiskPrefix, d.DeviceName)
+}
+
+// formatLabels returns the labels in the form stored by GCE. Keys are lower
+// cased with the characters not allowed in keys replaced by underscores.
+// Values are lower cased unless the case of label values is preserved.
+func (s *gceOps) formatLabels(labels map[string]string) map[string]string {
 	newLabels := make(map[string]string)
 	for k, v := range labels {
-		newLabels[strings.ToLower(k)] = strings.ToLower(v)
+		if !s.preserveLabelCase {
+			v = strings.ToLower(v)
+		}
+		newLabels[formatLabelKey(k)] = v
 	}
 	return newLabels
 }
 
+// formatLabelKey lower cases the label key and replaces the characters other
+// than letters, digits, underscores and dashes by underscores
+func formatLabelKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(key))
+}
+
+// lockOwnerLabel returns the lock owner as a label value. Label values may only
+// contain lower case letters, digits, underscores and dashes and are at most 63
+// characters long, so the other characters are replaced by dashes. If that
+// changes the owner or the owner is too long, the value is suffixed with a hash
+// of the owner to keep distinct owners distinct.
+func lockOwnerLabel(owner string) string {
+	value := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' || r == '-' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(owner))
+	if value == owner && len(value) <= maxLabelValueLength {
+		return value
+	}
+
+	sum := sha256.Sum256([]byte(owner))
+	suffix := "-" + hex.EncodeToString(sum[:])[:8]
+	if len(value) > maxLabelValueLength-len(suffix) {
+		value = value[:maxLabelValueLength-len(suffix)]
+	}
+	return value + suffix
+}
+
+// snapshotInfoFromSnapshot returns the provider neutral info of the given
+// snapshot. Snapshots are identified by their name and their source disk by
+// the name of the disk.
+func snapshotInfoFromSnapshot(snap *compute.Snapshot) (*cloudops.SnapshotInfo, error) {
+	creationTime, err := time.Parse(time.RFC3339, snap.CreationTimestamp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse creation time of snapshot %s: %v", snap.Name, err)
+	}
+	return &cloudops.SnapshotInfo{
+		CloudResourceInfo: cloudops.CloudResourceInfo{
+			Name:   snap.Name,
+			ID:     snap.Name,
+			Labels: snap.Labels,
+		},
+		VolumeID:     path.Base(snap.SourceDisk),
+		SizeInGiB:    uint64(snap.DiskSizeGb),
+		State:        snap.Status,
+		CreationTime: creationTime,
+	}, nil
+}
+
+// isNotFoundError returns true if the error is a googleapi not found error
+func isNotFoundError(err error) bool {
+	gerr, ok := err.(*googleapi.Error)
+	return ok && gerr.Code == http.StatusNotFound
+}
+
+// isLabelFingerprintMismatch returns true if a label update was rejected as the
+// labels changed since they were read
+func isLabelFingerprintMismatch(err error) bool {
+	gerr, ok := err.(*googleapi.Error)
+	return ok && gerr.Code == http.StatusPreconditionFailed
+}
+
 func isExponentialError(err error) bool {
 	// Got the list of error codes from here
 	// https://cloud.google.com/apis/design/errors#handling_errors
 	gceCodes := map[int]struct{}{
-		int(429): {},
+		http.StatusTooManyRequests:     {},
+		http.StatusInternalServerError: {},
+		http.StatusServiceUnavailable:  {},
 	}
 	if err != nil {
 		if gceErr, ok := err.(*googleapi.Error); ok {
 			if _, exist := gceCodes[gceErr.Code]; exist {
 				return true
 			}
+			return isRateLimitError(gceErr)
+		}
+	}
+	return false
+}
+
+// isRateLimitError returns true if the request was rejected for exceeding a
+// rate limit quota, which GCE reports as a 403 with one of the reasons below
+// https://cloud.google.com/compute/docs/api/how-tos/api-rate-limits
+func isRateLimitError(gceErr *googleapi.Error) bool {
+	for _, item := range gceErr.Errors {
+		switch item.Reason {
+		case "rateLimitExceeded", "userRateLimitExceeded":
+			return true
 		}
 	}
 	return false
 }
 
+// isFatalError returns true if the request was rejected for its credentials or
+// could not reach the compute API endpoint
+func isFatalError(err error) bool {
+	if gerr, ok := err.(*googleapi.Error); ok {
+		if isRateLimitError(gerr) {
+			return false
+		}
+		return gerr.Code == http.StatusUnauthorized || gerr.Code == http.StatusForbidden
+	}
+	return cloudops.IsConnectionError(err)
+}
+
 func isZonalCluster(clusterLocation string) (bool, error) {
 	// Zone e.g. us-central1-a
 	zoneRegex := "[a-zA-z0-9]+-[a-zA-Z0-9]+-[a-zA-Z]"
 	return regexp.MatchString(zoneRegex, clusterLocation)
 }
 
+// encryptionKeyError returns an ErrInvalidEncryptionKey error if err reports
+// that the Cloud KMS key of disk cannot be used, else err as is
+func encryptionKeyError(err error, disk *compute.Disk) error {
+	if disk.DiskEncryptionKey == nil || len(disk.DiskEncryptionKey.KmsKeyName) == 0 {
+		return err
+	}
+	msg := strings.ToLower(err.Error())
+	if !strings.Contains(msg, "kms") && !strings.Contains(msg, "cryptokey") {
+		return err
+	}
+	return cloudops.NewStorageError(cloudops.ErrInvalidEncryptionKey,
+		fmt.Sprintf("invalid encryption key %s: %v", disk.DiskEncryptionKey.KmsKeyName, err), "")
+}
+
 func isDiskEncryptedWithDefaultAccount(d *compute.Disk) bool {
 	return d.DiskEncryptionKey != nil &&
 		len(d.DiskEncryptionKey.KmsKeyName) > 0 &&
@@ -1,18 +1,35 @@
-package gce_test
+package gce
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
-	"github.com/sirupsen/logrus"
-
+	"cloud.google.com/go/compute/metadata"
 	"github.com/libopenstorage/cloudops"
-	"github.com/libopenstorage/cloudops/gce"
+	"github.com/libopenstorage/cloudops/backoff"
+	"github.com/libopenstorage/cloudops/fake"
 	"github.com/libopenstorage/cloudops/test"
 	"github.com/pborman/uuid"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 	compute "google.golang.org/api/compute/v1"
+	container "google.golang.org/api/container/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
 )
 
 const (
@@ -24,7 +41,7 @@ const (
 var diskName = fmt.Sprintf("%s-%s", newDiskPrefix, uuid.New())
 
 func initGCE(t *testing.T) (cloudops.Ops, map[string]interface{}) {
-	driver, err := gce.NewClient()
+	driver, err := NewClient()
 	require.NoError(t, err, "failed to instantiate storage ops driver")
 
 	template := &compute.Disk{
@@ -40,7 +57,7 @@ func initGCE(t *testing.T) (cloudops.Ops, map[string]interface{}) {
 }
 
 func TestAll(t *testing.T) {
-	if gce.IsDevMode() {
+	if IsDevMode() {
 		drivers := make(map[string]cloudops.Ops)
 		diskTemplates := make(map[string]map[string]interface{})
 
@@ -55,7 +72,7 @@ func TestAll(t *testing.T) {
 }
 
 func TestInspectInstance(t *testing.T) {
-	if gce.IsDevMode() {
+	if IsDevMode() {
 		d, _ := initGCE(t)
 		info, err := d.InspectInstance(os.Getenv("GCE_INSTANCE_NAME"))
 		require.NoError(t, err)
@@ -78,3 +95,2610 @@ func sizeCheck(template interface{}, targetSize uint64) bool {
 	}
 	return targetSize == uint64(disk.SizeGb)
 }
+
+const (
+	testProject  = "project"
+	testZone     = "zone"
+	testInstance = "instance"
+)
+
+// newTestGCEOps returns a gceOps whose compute service talks to the given handler
+func newTestGCEOps(t *testing.T, handler http.Handler) *gceOps {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	computeService, err := compute.NewService(
+		context.Background(),
+		option.WithEndpoint(server.URL+"/projects/"),
+		option.WithoutAuthentication(),
+	)
+	require.NoError(t, err)
+
+	return &gceOps{
+		inst: &instance{
+			name:    testInstance,
+			zone:    testZone,
+			region:  "region",
+			project: testProject,
+		},
+		computeService: computeService,
+		httpClient:     server.Client(),
+	}
+}
+
+func writeJSON(t *testing.T, w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	require.NoError(t, json.NewEncoder(w).Encode(v))
+}
+
+func TestAttachInterface(t *testing.T) {
+	diskInterface, err := attachInterface(nil)
+	require.NoError(t, err)
+	require.Equal(t, interfaceSCSI, diskInterface)
+
+	diskInterface, err = attachInterface(map[string]string{AttachInterfaceKey: "nvme"})
+	require.NoError(t, err)
+	require.Equal(t, interfaceNVME, diskInterface)
+
+	_, err = attachInterface(map[string]string{AttachInterfaceKey: "virtio"})
+	require.Error(t, err)
+}
+
+func TestAttachNVMe(t *testing.T) {
+	const diskName = "disk1"
+	diskURL := "https://www.googleapis.com/compute/v1/projects/project/zones/zone/disks/" + diskName
+
+	// Fake the udev managed by-id symlink for the NVMe namespace
+	devDir := t.TempDir()
+	nvmeDevice := filepath.Join(devDir, "nvme0n2")
+	require.NoError(t, os.WriteFile(nvmeDevice, nil, 0644))
+	require.NoError(t, os.Symlink(nvmeDevice, filepath.Join(devDir, "nvme-Google_PersistentDisk_"+diskName)))
+	origPrefix := googleNvmeDiskPrefix
+	googleNvmeDiskPrefix = filepath.Join(devDir, "nvme-Google_PersistentDisk_")
+	defer func() { googleNvmeDiskPrefix = origPrefix }()
+
+	var attached *compute.AttachedDisk
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/zones/zone/disks/"+diskName, func(w http.ResponseWriter, r *http.Request) {
+		disk := &compute.Disk{Name: diskName, SelfLink: diskURL}
+		if attached != nil {
+			disk.Users = []string{testInstance}
+		}
+		writeJSON(t, w, disk)
+	})
+	mux.HandleFunc("/projects/project/zones/zone/instances/instance/attachDisk", func(w http.ResponseWriter, r *http.Request) {
+		attached = &compute.AttachedDisk{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(attached))
+		writeJSON(t, w, &compute.Operation{Name: "attach-op"})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/operations/attach-op", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Operation{Name: "attach-op", Status: doneStatus})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/instances/instance", func(w http.ResponseWriter, r *http.Request) {
+		inst := &compute.Instance{Name: testInstance}
+		if attached != nil {
+			inst.Disks = append(inst.Disks, attached)
+		}
+		writeJSON(t, w, inst)
+	})
+
+	s := newTestGCEOps(t, mux)
+	devicePath, err := s.Attach(diskName, map[string]string{AttachInterfaceKey: interfaceNVME})
+	require.NoError(t, err)
+	require.NotNil(t, attached)
+	require.Equal(t, interfaceNVME, attached.Interface)
+	require.Equal(t, nvmeDevice, devicePath)
+}
+
+func TestAttachWaitsForRemoteDetach(t *testing.T) {
+	const diskName = "disk1"
+	diskURL := "https://www.googleapis.com/compute/v1/projects/project/zones/zone/disks/" + diskName
+	remoteInstance := "https://www.googleapis.com/compute/v1/projects/project/zones/zone/instances/remote"
+
+	devDir := t.TempDir()
+	device := filepath.Join(devDir, "sdb")
+	require.NoError(t, os.WriteFile(device, nil, 0644))
+	require.NoError(t, os.Symlink(device, filepath.Join(devDir, "google-"+diskName)))
+	origPrefix := googleDiskPrefix
+	googleDiskPrefix = filepath.Join(devDir, "google-")
+	defer func() { googleDiskPrefix = origPrefix }()
+
+	// the disk is detached from the remote instance after a few polls
+	remotePolls := 0
+	var attached *compute.AttachedDisk
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/zones/zone/disks/"+diskName, func(w http.ResponseWriter, r *http.Request) {
+		disk := &compute.Disk{Name: diskName, SelfLink: diskURL}
+		if attached != nil {
+			disk.Users = []string{testInstance}
+		} else if remotePolls < 3 {
+			remotePolls++
+			disk.Users = []string{remoteInstance}
+		}
+		writeJSON(t, w, disk)
+	})
+	mux.HandleFunc("/projects/project/zones/zone/instances/instance/attachDisk", func(w http.ResponseWriter, r *http.Request) {
+		attached = &compute.AttachedDisk{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(attached))
+		writeJSON(t, w, &compute.Operation{Name: "attach-op"})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/operations/attach-op", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Operation{Name: "attach-op", Status: doneStatus})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/instances/instance", func(w http.ResponseWriter, r *http.Request) {
+		inst := &compute.Instance{Name: testInstance}
+		if attached != nil {
+			inst.Disks = append(inst.Disks, attached)
+		}
+		writeJSON(t, w, inst)
+	})
+
+	s := newTestGCEOps(t, mux)
+	s.opsTimeout = cloudops.OpsTimeoutConfig{
+		Timeout:       5 * time.Second,
+		RetryInterval: 10 * time.Millisecond,
+	}
+
+	// by default the remote attachment fails fast
+	_, err := s.Attach(diskName, nil)
+	require.Error(t, err)
+	require.Nil(t, attached)
+
+	devicePath, err := s.Attach(diskName, map[string]string{cloudops.WaitRemoteOption: "true"})
+	require.NoError(t, err)
+	require.Equal(t, 3, remotePolls)
+	require.NotNil(t, attached)
+	require.Equal(t, device, devicePath)
+}
+
+func TestAttachByInstanceID(t *testing.T) {
+	const diskName = "disk1"
+	diskURL := "https://www.googleapis.com/compute/v1/projects/project/zones/zone/disks/" + diskName
+
+	var attached *compute.AttachedDisk
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/zones/zone/disks/"+diskName, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Disk{Name: diskName, SelfLink: diskURL})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/instances/other/attachDisk", func(w http.ResponseWriter, r *http.Request) {
+		attached = &compute.AttachedDisk{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(attached))
+		writeJSON(t, w, &compute.Operation{Name: "attach-op"})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/operations/attach-op", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Operation{Name: "attach-op", Status: doneStatus})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/instances/other", func(w http.ResponseWriter, r *http.Request) {
+		inst := &compute.Instance{Name: "other"}
+		if attached != nil {
+			inst.Disks = append(inst.Disks, attached)
+		}
+		writeJSON(t, w, inst)
+	})
+
+	s := newTestGCEOps(t, mux)
+	s.opsTimeout = cloudops.OpsTimeoutConfig{
+		Timeout:       5 * time.Second,
+		RetryInterval: 10 * time.Millisecond,
+	}
+	devicePath, err := s.AttachByInstanceID("other", diskName, nil)
+	require.NoError(t, err)
+	require.NotNil(t, attached)
+	require.Equal(t, diskURL, attached.Source)
+	require.Equal(t, googleDiskPrefix+diskName, devicePath)
+}
+
+func TestAttachByInstanceIDOtherZone(t *testing.T) {
+	regionalURL := "https://www.googleapis.com/compute/v1/projects/project/regions/region/disks/regional"
+	zonalURL := "https://www.googleapis.com/compute/v1/projects/project/zones/zone/disks/zonal"
+
+	var attached *compute.AttachedDisk
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/regions/region/disks/regional", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Disk{
+			Name:     "regional",
+			SelfLink: regionalURL,
+			Region:   "https://www.googleapis.com/compute/v1/projects/project/regions/region",
+			ReplicaZones: []string{
+				"https://www.googleapis.com/compute/v1/projects/project/zones/zone",
+				"https://www.googleapis.com/compute/v1/projects/project/zones/zone-b",
+			},
+		})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/disks/zonal", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Disk{
+			Name:     "zonal",
+			SelfLink: zonalURL,
+			Zone:     "https://www.googleapis.com/compute/v1/projects/project/zones/zone",
+		})
+	})
+	mux.HandleFunc("/projects/project/regions/region", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Region{
+			Name: "region",
+			Zones: []string{
+				"https://www.googleapis.com/compute/v1/projects/project/zones/zone",
+				"https://www.googleapis.com/compute/v1/projects/project/zones/zone-b",
+			},
+		})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/instances/other", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": {"code": 404, "message": "notFound"}}`, http.StatusNotFound)
+	})
+	mux.HandleFunc("/projects/project/zones/zone-b/instances/other", func(w http.ResponseWriter, r *http.Request) {
+		inst := &compute.Instance{Name: "other"}
+		if attached != nil {
+			inst.Disks = append(inst.Disks, attached)
+		}
+		writeJSON(t, w, inst)
+	})
+	mux.HandleFunc("/projects/project/zones/zone-b/instances/other/attachDisk", func(w http.ResponseWriter, r *http.Request) {
+		attached = &compute.AttachedDisk{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(attached))
+		writeJSON(t, w, &compute.Operation{Name: "attach-op"})
+	})
+	mux.HandleFunc("/projects/project/zones/zone-b/operations/attach-op", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Operation{Name: "attach-op", Status: doneStatus})
+	})
+
+	s := newTestGCEOps(t, mux)
+	s.opsTimeout = cloudops.OpsTimeoutConfig{
+		Timeout:       5 * time.Second,
+		RetryInterval: 10 * time.Millisecond,
+	}
+
+	// a zonal disk cannot be attached to an instance in another zone
+	_, err := s.AttachByInstanceID("other", "zonal", nil)
+	require.Error(t, err)
+	storageErr, ok := err.(*cloudops.StorageError)
+	require.True(t, ok, "expected a StorageError, got %v", err)
+	require.Equal(t, cloudops.ErrVolInval, storageErr.Code)
+	require.Contains(t, storageErr.Msg, "zone-b")
+	require.Nil(t, attached)
+
+	devicePath, err := s.AttachByInstanceID("other", regionalDiskID("region", "regional"), nil)
+	require.NoError(t, err)
+	require.NotNil(t, attached)
+	require.Equal(t, regionalURL, attached.Source)
+	require.Equal(t, googleDiskPrefix+"regional", devicePath)
+
+	_, err = s.AttachByInstanceID("missing", "zonal", nil)
+	_, ok = err.(*cloudops.ErrNotFound)
+	require.True(t, ok, "expected an ErrNotFound, got %v", err)
+}
+
+func TestAdoptVolume(t *testing.T) {
+	var labels map[string]string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/zones/zone/disks/detached", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Disk{
+			Name:   "detached",
+			Status: "READY",
+			Labels: map[string]string{"app": "db"},
+		})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/disks/detached/setLabels", func(w http.ResponseWriter, r *http.Request) {
+		rb := &compute.ZoneSetLabelsRequest{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(rb))
+		labels = rb.Labels
+		writeJSON(t, w, &compute.Operation{Name: "labels-op", Status: doneStatus})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/operations/labels-op", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Operation{Name: "labels-op", Status: doneStatus})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/disks/remote", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Disk{
+			Name:   "remote",
+			Status: "READY",
+			Users:  []string{"https://www.googleapis.com/compute/v1/projects/project/zones/zone/instances/other"},
+		})
+	})
+	s := newTestGCEOps(t, mux)
+
+	err := s.AdoptVolume("detached", map[string]string{"Cluster": "c1"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"app":                        "db",
+		"cluster":                    "c1",
+		cloudops.VolumeManagedTagKey: "true",
+	}, labels)
+
+	err = s.AdoptVolume("remote", nil)
+	require.Error(t, err)
+	storageErr, ok := err.(*cloudops.StorageError)
+	require.True(t, ok)
+	require.Equal(t, cloudops.ErrVolAttachedOnRemoteNode, storageErr.Code)
+
+	err = s.AdoptVolume("missing", nil)
+	require.Error(t, err)
+	storageErr, ok = err.(*cloudops.StorageError)
+	require.True(t, ok)
+	require.Equal(t, cloudops.ErrVolNotFound, storageErr.Code)
+}
+
+func TestCreateEnforcesMinSize(t *testing.T) {
+	var created *compute.Disk
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/zones/zone/disks", func(w http.ResponseWriter, r *http.Request) {
+		created = &compute.Disk{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(created))
+		writeJSON(t, w, &compute.Operation{Name: "create-op"})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/operations/create-op", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Operation{Name: "create-op", Status: doneStatus})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/disks/", func(w http.ResponseWriter, r *http.Request) {
+		disk := *created
+		disk.Status = "READY"
+		writeJSON(t, w, &disk)
+	})
+	s := newTestGCEOps(t, mux)
+
+	cases := []struct {
+		diskType     string
+		expectedSize int64
+	}{
+		{"pd-balanced", minDiskSizeGB},
+		{"projects/project/zones/zone/diskTypes/pd-extreme", minExtremeDiskSizeGB},
+	}
+	for _, c := range cases {
+		d, err := s.Create(&compute.Disk{
+			Name:   "disk1",
+			SizeGb: 1,
+			Type:   c.diskType,
+			Zone:   testZone,
+		}, nil, nil)
+		require.NoError(t, err)
+		require.Equal(t, c.expectedSize, created.SizeGb)
+		require.Equal(t, c.expectedSize, d.(*compute.Disk).SizeGb)
+	}
+
+	created = nil
+	_, err := s.Create(&compute.Disk{
+		Name:   "disk1",
+		SizeGb: 1,
+		Type:   "pd-balanced",
+		Zone:   testZone,
+	}, nil, map[string]string{cloudops.MinSizePolicyOption: cloudops.MinSizePolicyError})
+	require.Error(t, err)
+	se, ok := err.(*cloudops.StorageError)
+	require.True(t, ok)
+	require.Equal(t, cloudops.ErrVolInval, se.Code)
+	require.Nil(t, created, "disk below the minimum size should not be created")
+}
+
+func TestCreateWithKmsKey(t *testing.T) {
+	const badKey = "projects/project/locations/global/keyRings/ring/cryptoKeys/missing"
+	var created *compute.Disk
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/zones/zone/disks", func(w http.ResponseWriter, r *http.Request) {
+		created = &compute.Disk{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(created))
+		if created.DiskEncryptionKey != nil && created.DiskEncryptionKey.KmsKeyName == badKey {
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(t, w, map[string]interface{}{
+				"error": map[string]interface{}{
+					"code":    http.StatusBadRequest,
+					"message": "Cloud KMS key " + badKey + " not found",
+				},
+			})
+			return
+		}
+		writeJSON(t, w, &compute.Operation{Name: "create-op"})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/operations/create-op", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Operation{Name: "create-op", Status: doneStatus})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/disks/", func(w http.ResponseWriter, r *http.Request) {
+		disk := *created
+		disk.Status = "READY"
+		writeJSON(t, w, &disk)
+	})
+	s := newTestGCEOps(t, mux)
+	s.inst.serviceAccount = "default@project.iam.gserviceaccount.com"
+
+	const key = "projects/project/locations/global/keyRings/ring/cryptoKeys/key"
+	_, err := s.Create(&compute.Disk{
+		Name:   "disk1",
+		SizeGb: 100,
+		Type:   "pd-balanced",
+		Zone:   testZone,
+	}, nil, map[string]string{cloudops.KmsKeyIDOption: key})
+	require.NoError(t, err)
+	require.NotNil(t, created.DiskEncryptionKey)
+	require.Equal(t, key, created.DiskEncryptionKey.KmsKeyName)
+	require.Equal(t, s.inst.serviceAccount, created.DiskEncryptionKey.KmsKeyServiceAccount)
+
+	_, err = s.Create(&compute.Disk{
+		Name:   "disk1",
+		SizeGb: 100,
+		Type:   "pd-balanced",
+		Zone:   testZone,
+	}, nil, map[string]string{cloudops.KmsKeyIDOption: badKey})
+	se, ok := err.(*cloudops.StorageError)
+	require.True(t, ok, "expected a storage error, got %v", err)
+	require.Equal(t, cloudops.ErrInvalidEncryptionKey, se.Code)
+}
+
+func TestCloneVolume(t *testing.T) {
+	source := &compute.Disk{
+		Name:     "source",
+		SizeGb:   20,
+		Zone:     "projects/project/zones/zone",
+		SelfLink: "https://www.googleapis.com/compute/v1/projects/project/zones/zone/disks/source",
+		Status:   "READY",
+	}
+	var created *compute.Disk
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/zones/zone/disks", func(w http.ResponseWriter, r *http.Request) {
+		created = &compute.Disk{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(created))
+		writeJSON(t, w, &compute.Operation{Name: "create-op"})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/operations/create-op", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Operation{Name: "create-op", Status: doneStatus})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/disks/source", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, source)
+	})
+	mux.HandleFunc("/projects/project/zones/zone/disks/clone", func(w http.ResponseWriter, r *http.Request) {
+		disk := *created
+		disk.Status = "READY"
+		writeJSON(t, w, &disk)
+	})
+	s := newTestGCEOps(t, mux)
+
+	d, err := s.CloneVolume("source", &compute.Disk{
+		Name: "clone",
+		Type: "pd-balanced",
+	}, map[string]string{"app": "db"})
+	require.NoError(t, err)
+	require.Equal(t, source.SelfLink, created.SourceDisk)
+	require.Zero(t, created.SizeGb, "clone should default to the size of the source disk")
+	require.Equal(t, map[string]string{"app": "db"}, created.Labels)
+	require.Equal(t, "clone", d.(*compute.Disk).Name)
+
+	_, err = s.CloneVolume("missing", &compute.Disk{Name: "clone"}, nil)
+	require.Error(t, err)
+	se, ok := err.(*cloudops.StorageError)
+	require.True(t, ok)
+	require.Equal(t, cloudops.ErrVolNotFound, se.Code)
+}
+
+func TestDeleteVolumes(t *testing.T) {
+	var (
+		mutex   sync.Mutex
+		deleted []string
+	)
+	disks := []*compute.Disk{
+		{Name: "disk1", Zone: "zones/zone"},
+		{Name: "disk2", Zone: "zones/zone"},
+		{Name: "disk3", Zone: "zones/zone"},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/aggregated/disks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.DiskAggregatedList{
+			Items: map[string]compute.DisksScopedList{"zones/zone": {Disks: disks}},
+		})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/disks/", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodDelete, r.Method)
+		name := strings.TrimPrefix(r.URL.Path, "/projects/project/zones/zone/disks/")
+		if name == "disk2" {
+			http.Error(w, `{"error": {"code": 400, "message": "resourceInUseByAnotherResource"}}`, http.StatusBadRequest)
+			return
+		}
+		mutex.Lock()
+		deleted = append(deleted, name)
+		mutex.Unlock()
+		writeJSON(t, w, &compute.Operation{Id: 1, Name: "delete-op"})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/operations/1", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Operation{Id: 1, Name: "delete-op", Status: doneStatus})
+	})
+
+	s := newTestGCEOps(t, mux)
+	errs, err := s.DeleteVolumes([]string{"disk1", "disk2", "disk3", "missing"})
+	require.NoError(t, err)
+	require.Len(t, errs, 2)
+	require.Contains(t, errs, "disk2")
+	require.Contains(t, errs, "missing")
+	require.ElementsMatch(t, []string{"disk1", "disk3"}, deleted)
+}
+
+func TestDeleteVolumesUnauthorized(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/aggregated/disks", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": {"code": 401, "message": "unauthorized"}}`, http.StatusUnauthorized)
+	})
+
+	s := newTestGCEOps(t, mux)
+	errs, err := s.DeleteVolumes([]string{"disk1", "disk2"})
+	require.Error(t, err)
+	require.True(t, isFatalError(err))
+	require.Len(t, errs, 2)
+}
+
+func TestDetachFromDeletedInstance(t *testing.T) {
+	notFound := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(t, w, map[string]interface{}{
+			"error": &googleapi.Error{Code: http.StatusNotFound, Message: "not found"},
+		})
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/zones/zone/instances/deleted/detachDisk", notFound)
+	mux.HandleFunc("/projects/project/zones/zone/instances/deleted", notFound)
+	mux.HandleFunc("/projects/project/zones/zone/instances/other/detachDisk", notFound)
+	mux.HandleFunc("/projects/project/zones/zone/instances/other", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, map[string]string{"name": "other"})
+	})
+	s := newTestGCEOps(t, mux)
+
+	require.NoError(t, s.DetachFrom("disk1", "deleted"))
+
+	// A not found error for a disk of an existing instance is returned
+	err := s.DetachFrom("disk1", "other")
+	require.Error(t, err)
+	require.True(t, isNotFoundError(err))
+}
+
+func TestDevicePathCache(t *testing.T) {
+	const diskName = "disk1"
+	diskURL := "https://www.googleapis.com/compute/v1/projects/project/zones/zone/disks/" + diskName
+
+	devDir := t.TempDir()
+	firstDevice := filepath.Join(devDir, "nvme0n2")
+	secondDevice := filepath.Join(devDir, "nvme0n3")
+	require.NoError(t, os.WriteFile(firstDevice, nil, 0644))
+	require.NoError(t, os.WriteFile(secondDevice, nil, 0644))
+	link := filepath.Join(devDir, "nvme-Google_PersistentDisk_"+diskName)
+	require.NoError(t, os.Symlink(firstDevice, link))
+	origPrefix := googleNvmeDiskPrefix
+	googleNvmeDiskPrefix = filepath.Join(devDir, "nvme-Google_PersistentDisk_")
+	defer func() { googleNvmeDiskPrefix = origPrefix }()
+
+	attached := true
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/zones/zone/disks/"+diskName, func(w http.ResponseWriter, r *http.Request) {
+		disk := &compute.Disk{Name: diskName, SelfLink: diskURL}
+		if attached {
+			disk.Users = []string{testInstance}
+		}
+		writeJSON(t, w, disk)
+	})
+	mux.HandleFunc("/projects/project/zones/zone/instances/instance", func(w http.ResponseWriter, r *http.Request) {
+		inst := &compute.Instance{Name: testInstance}
+		if attached {
+			inst.Disks = append(inst.Disks, &compute.AttachedDisk{
+				Source:     diskURL,
+				DeviceName: diskName,
+				Interface:  interfaceNVME,
+			})
+		}
+		writeJSON(t, w, inst)
+	})
+	mux.HandleFunc("/projects/project/zones/zone/instances/instance/detachDisk", func(w http.ResponseWriter, r *http.Request) {
+		attached = false
+		writeJSON(t, w, &compute.Operation{Name: "detach-op"})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/operations/detach-op", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Operation{Name: "detach-op", Status: doneStatus})
+	})
+
+	s := newTestGCEOps(t, mux)
+	s.devicePathCache = cloudops.NewDevicePathCache(time.Minute)
+	s.opsTimeout = cloudops.OpsTimeoutConfig{
+		Timeout:       5 * time.Second,
+		RetryInterval: 10 * time.Millisecond,
+	}
+
+	devicePath, err := s.DevicePath(diskName)
+	require.NoError(t, err)
+	require.Equal(t, firstDevice, devicePath)
+
+	// The symlink is not resolved again within the TTL
+	require.NoError(t, os.Remove(link))
+	require.NoError(t, os.Symlink(secondDevice, link))
+	devicePath, err = s.DevicePath(diskName)
+	require.NoError(t, err)
+	require.Equal(t, firstDevice, devicePath)
+
+	// A detach invalidates the cached device path
+	require.NoError(t, s.Detach(diskName, nil))
+	attached = true
+	devicePath, err = s.DevicePath(diskName)
+	require.NoError(t, err)
+	require.Equal(t, secondDevice, devicePath)
+}
+
+func TestDevicePathFromGuestAttributes(t *testing.T) {
+	diskURL := func(name string) string {
+		return "https://www.googleapis.com/compute/v1/projects/project/zones/zone/disks/" + name
+	}
+
+	// only disk2 has a by-id symlink, disk1 is published in the guest attributes
+	devDir := t.TempDir()
+	device := filepath.Join(devDir, "sdc")
+	require.NoError(t, os.WriteFile(device, nil, 0644))
+	require.NoError(t, os.Symlink(device, filepath.Join(devDir, "google-disk2")))
+	origPrefix := googleDiskPrefix
+	googleDiskPrefix = filepath.Join(devDir, "google-")
+	defer func() { googleDiskPrefix = origPrefix }()
+
+	metadataServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Google", r.Header.Get("Metadata-Flavor"))
+		if r.URL.Path != "/computeMetadata/v1/instance/guest-attributes/disks/disk1" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintln(w, "/dev/sdb")
+	}))
+	defer metadataServer.Close()
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(metadataServer.URL, "http://"))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/zones/zone/disks/", func(w http.ResponseWriter, r *http.Request) {
+		name := filepath.Base(r.URL.Path)
+		writeJSON(t, w, &compute.Disk{Name: name, SelfLink: diskURL(name), Users: []string{testInstance}})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/instances/instance", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Instance{
+			Name: testInstance,
+			Disks: []*compute.AttachedDisk{
+				{Source: diskURL("disk1"), DeviceName: "disk1"},
+				{Source: diskURL("disk2"), DeviceName: "disk2"},
+			},
+		})
+	})
+
+	s := newTestGCEOps(t, mux)
+	s.guestAttributes = metadata.NewClient(metadataServer.Client())
+
+	devicePath, err := s.DevicePath("disk1")
+	require.NoError(t, err)
+	require.Equal(t, "/dev/sdb", devicePath)
+
+	devicePath, err = s.DevicePath("disk2")
+	require.NoError(t, err)
+	require.Equal(t, device, devicePath)
+
+	mappings, err := s.DeviceMappings()
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"/dev/sdb": "disk1", device: "disk2"}, mappings)
+}
+
+func TestDevicePrefixesFromEnv(t *testing.T) {
+	origPrefix, origNvmePrefix := googleDiskPrefix, googleNvmeDiskPrefix
+	defer func() { googleDiskPrefix, googleNvmeDiskPrefix = origPrefix, origNvmePrefix }()
+
+	// unset env variables keep the default prefixes
+	devicePrefixesFromEnv()
+	require.Equal(t, "/dev/disk/by-id/google-disk1", diskPathByID(&compute.AttachedDisk{DeviceName: "disk1"}))
+
+	t.Setenv(DiskPrefixEnvKey, "/dev/disk/by-id/scsi-0Google_PersistentDisk_")
+	t.Setenv(NvmeDiskPrefixEnvKey, "/dev/disk/by-id/google-nvme-")
+	devicePrefixesFromEnv()
+	require.Equal(t, "/dev/disk/by-id/scsi-0Google_PersistentDisk_disk1",
+		diskPathByID(&compute.AttachedDisk{DeviceName: "disk1", Interface: interfaceSCSI}))
+	require.Equal(t, "/dev/disk/by-id/google-nvme-disk1",
+		diskPathByID(&compute.AttachedDisk{DeviceName: "disk1", Interface: interfaceNVME}))
+}
+
+func TestExpandMany(t *testing.T) {
+	var mutex sync.Mutex
+	sizes := map[string]int64{
+		"disk1": 100,
+		"disk2": 100,
+		"disk3": 100,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/zones/zone/disks/", func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		name := strings.TrimPrefix(r.URL.Path, "/projects/project/zones/zone/disks/")
+		if strings.HasSuffix(name, "/resize") {
+			name = strings.TrimSuffix(name, "/resize")
+			req := &compute.DisksResizeRequest{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(req))
+			sizes[name] = req.SizeGb
+			writeJSON(t, w, &compute.Operation{Id: 1, Name: "resize-op"})
+			return
+		}
+
+		size, ok := sizes[name]
+		if !ok {
+			http.Error(w, `{"error": {"code": 404, "message": "notFound"}}`, http.StatusNotFound)
+			return
+		}
+		writeJSON(t, w, &compute.Disk{Name: name, SizeGb: size})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/operations/1", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Operation{Id: 1, Name: "resize-op", Status: doneStatus})
+	})
+
+	s := newTestGCEOps(t, mux)
+	newSizes, err := s.ExpandMany([]string{"disk1", "disk2", "disk3"}, 200, nil)
+	require.NoError(t, err)
+	require.Equal(t, map[string]uint64{"disk1": 200, "disk2": 200, "disk3": 200}, newSizes)
+	require.Equal(t, map[string]int64{"disk1": 200, "disk2": 200, "disk3": 200}, sizes)
+
+	newSizes, err = s.ExpandMany([]string{"disk1", "missing"}, 300, nil)
+	require.Error(t, err)
+	expandErr, ok := err.(*cloudops.ErrExpandMany)
+	require.True(t, ok)
+	require.Len(t, expandErr.Errors, 1)
+	require.Contains(t, expandErr.Errors, "missing")
+	require.Equal(t, map[string]uint64{"disk1": 300}, newSizes)
+}
+
+func TestAreVolumesReadyToExpand(t *testing.T) {
+	statuses := map[string]string{
+		"disk1": "READY",
+		"disk2": "RESTORING",
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/zones/zone/disks/", func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/projects/project/zones/zone/disks/")
+		writeJSON(t, w, &compute.Disk{Name: name, Status: statuses[name]})
+	})
+	s := newTestGCEOps(t, mux)
+
+	disk1, disk2 := "disk1", "disk2"
+	ready, err := s.AreVolumesReadyToExpand([]*string{&disk1})
+	require.NoError(t, err)
+	require.True(t, ready)
+
+	ready, err = s.AreVolumesReadyToExpand([]*string{&disk1, &disk2})
+	require.False(t, ready)
+	notReady, ok := err.(*cloudops.ErrVolumesNotReadyToExpand)
+	require.True(t, ok, "expected ErrVolumesNotReadyToExpand, got %v", err)
+	require.Equal(t, map[string]string{"disk2": "disk is in RESTORING state"}, notReady.Volumes)
+}
+
+func TestExpandScaleHyperdiskPerformance(t *testing.T) {
+	disk := map[string]string{
+		"name":                  "hyperdisk",
+		"type":                  "https://www.googleapis.com/compute/v1/projects/project/zones/zone/diskTypes/hyperdisk-balanced",
+		"sizeGb":                "100",
+		"provisionedIops":       "3000",
+		"provisionedThroughput": "140",
+	}
+	var updatePaths []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/zones/zone/disks/hyperdisk", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPatch {
+			updatePaths = r.URL.Query()["paths"]
+			update := make(map[string]string)
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&update))
+			for k, v := range update {
+				disk[k] = v
+			}
+			writeJSON(t, w, &compute.Operation{Name: "update-op"})
+			return
+		}
+		writeJSON(t, w, disk)
+	})
+	mux.HandleFunc("/projects/project/zones/zone/disks/hyperdisk/resize", func(w http.ResponseWriter, r *http.Request) {
+		req := &compute.DisksResizeRequest{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(req))
+		disk["sizeGb"] = fmt.Sprintf("%d", req.SizeGb)
+		writeJSON(t, w, &compute.Operation{Id: 1, Name: "resize-op"})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/operations/", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Operation{Status: doneStatus})
+	})
+	s := newTestGCEOps(t, mux)
+
+	// the provisioned performance is left as is unless requested
+	size, err := s.Expand("hyperdisk", 150, nil)
+	require.NoError(t, err)
+	require.Equal(t, uint64(150), size)
+	require.Nil(t, updatePaths)
+	require.Equal(t, "3000", disk["provisionedIops"])
+
+	size, err = s.Expand("hyperdisk", 300, map[string]string{cloudops.ScaleIopsOption: "true"})
+	require.NoError(t, err)
+	require.Equal(t, uint64(300), size)
+	require.Equal(t, []string{"provisionedIops", "provisionedThroughput"}, updatePaths)
+	require.Equal(t, "300", disk["sizeGb"])
+	require.Equal(t, "6000", disk["provisionedIops"])
+	require.Equal(t, "280", disk["provisionedThroughput"])
+}
+
+func TestBatchInspect(t *testing.T) {
+	var requests int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/aggregated/disks", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		writeJSON(t, w, &compute.DiskAggregatedList{
+			Items: map[string]compute.DisksScopedList{
+				"zones/zone-a": {
+					Disks: []*compute.Disk{{Name: "disk1"}, {Name: "disk2"}},
+				},
+				"regions/region": {
+					Disks: []*compute.Disk{{Name: "disk3", Region: "projects/project/regions/region"}},
+				},
+			},
+		})
+	})
+	s := newTestGCEOps(t, mux)
+
+	disk1, disk3 := "disk1", "regions/region/disks/disk3"
+	disks, err := s.BatchInspect([]*string{&disk1, &disk3})
+	require.NoError(t, err)
+	require.Equal(t, 1, requests)
+	require.Len(t, disks, 2)
+	require.Equal(t, "disk1", disks[disk1].(*compute.Disk).Name)
+	require.Equal(t, "disk3", disks[disk3].(*compute.Disk).Name)
+
+	missing := "regions/other/disks/disk3"
+	_, err = s.BatchInspect([]*string{&missing})
+	require.Error(t, err)
+	se, ok := err.(*cloudops.StorageError)
+	require.True(t, ok)
+	require.Equal(t, cloudops.ErrVolNotFound, se.Code)
+}
+
+func TestInspectInstanceInZone(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/zones/zone/instances/local", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Instance{Name: "local", Id: 1, Zone: "zone", Status: "RUNNING"})
+	})
+	mux.HandleFunc("/projects/project/zones/other-zone/instances/remote", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Instance{
+			Name:   "remote",
+			Id:     2,
+			Zone:   "other-zone",
+			Status: "TERMINATED",
+			Labels: map[string]string{"role": "storage"},
+		})
+	})
+	ops := newTestGCEOps(t, mux)
+
+	info, err := ops.InspectInstanceInZone("remote", "other-zone")
+	require.NoError(t, err)
+	require.Equal(t, "remote", info.Name)
+	require.Equal(t, "2", info.ID)
+	require.Equal(t, "other-zone", info.Zone)
+	require.Equal(t, map[string]string{"role": "storage"}, info.Labels)
+	require.Equal(t, cloudops.InstanceStateOffline, info.State)
+
+	// InspectInstance only looks in the local zone
+	info, err = ops.InspectInstance("local")
+	require.NoError(t, err)
+	require.Equal(t, "zone", info.Zone)
+	_, err = ops.InspectInstance("remote")
+	require.Error(t, err)
+}
+
+func TestListInstanceGroupMembers(t *testing.T) {
+	const clusterZone = "us-central1-a"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/projects/project/zones/"+clusterZone+"/clusters/cluster/nodePools/pool", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &container.NodePool{
+			Name: "pool",
+			InstanceGroupUrls: []string{
+				"https://www.googleapis.com/compute/v1/projects/project/zones/us-central1-a/instanceGroupManagers/gke-pool-a-grp",
+				"https://www.googleapis.com/compute/v1/projects/project/zones/us-central1-b/instanceGroupManagers/gke-pool-b-grp",
+			},
+		})
+	})
+	mux.HandleFunc("/projects/project/zones/us-central1-a/instanceGroupManagers/gke-pool-a-grp/listManagedInstances", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.InstanceGroupManagersListManagedInstancesResponse{
+			ManagedInstances: []*compute.ManagedInstance{
+				{Instance: "https://www.googleapis.com/compute/v1/projects/project/zones/us-central1-a/instances/gke-pool-a-1"},
+				{Instance: "https://www.googleapis.com/compute/v1/projects/project/zones/us-central1-a/instances/gke-pool-a-2"},
+			},
+		})
+	})
+	mux.HandleFunc("/projects/project/zones/us-central1-b/instanceGroupManagers/gke-pool-b-grp/listManagedInstances", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.InstanceGroupManagersListManagedInstancesResponse{
+			ManagedInstances: []*compute.ManagedInstance{
+				{Instance: "https://www.googleapis.com/compute/v1/projects/project/zones/us-central1-b/instances/gke-pool-b-1"},
+			},
+		})
+	})
+
+	s := newTestGCEOps(t, mux)
+	s.inst.clusterName = "cluster"
+	s.inst.clusterLocation = clusterZone
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	containerService, err := container.NewService(
+		context.Background(),
+		option.WithEndpoint(server.URL+"/"),
+		option.WithoutAuthentication(),
+	)
+	require.NoError(t, err)
+	s.containerService = containerService
+
+	members, err := s.ListInstanceGroupMembers("pool")
+	require.NoError(t, err)
+	require.Equal(t, []string{"gke-pool-a-1", "gke-pool-a-2", "gke-pool-b-1"}, members)
+}
+
+func TestGetInstanceGroupZones(t *testing.T) {
+	const clusterRegion = "us-central1"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/projects/project/locations/"+clusterRegion+"/clusters/cluster/nodePools/pool", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &container.NodePool{
+			Name: "pool",
+			InstanceGroupUrls: []string{
+				"https://www.googleapis.com/compute/v1/projects/project/zones/us-central1-a/instanceGroupManagers/gke-pool-a-grp",
+				"https://www.googleapis.com/compute/v1/projects/project/zones/us-central1-b/instanceGroupManagers/gke-pool-b-grp",
+				"https://www.googleapis.com/compute/v1/projects/project/zones/us-central1-b/instanceGroupManagers/gke-pool-b2-grp",
+			},
+		})
+	})
+
+	s := newTestGCEOps(t, mux)
+	s.inst.clusterName = "cluster"
+	s.inst.clusterLocation = clusterRegion
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	containerService, err := container.NewService(
+		context.Background(),
+		option.WithEndpoint(server.URL+"/"),
+		option.WithoutAuthentication(),
+	)
+	require.NoError(t, err)
+	s.containerService = containerService
+
+	zones, err := s.GetInstanceGroupZones("pool")
+	require.NoError(t, err)
+	require.Equal(t, []string{"us-central1-a", "us-central1-b"}, zones)
+}
+
+func TestRollInstanceGroup(t *testing.T) {
+	const clusterZone = "us-central1-a"
+
+	var update *container.UpdateNodePoolRequest
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/projects/project/zones/"+clusterZone+"/clusters/cluster/nodePools/pool", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &container.NodePool{
+			Name:    "pool",
+			Version: "1.27.3-gke.100",
+			Config:  &container.NodeConfig{ImageType: "COS_CONTAINERD"},
+		})
+	})
+	mux.HandleFunc("/v1/projects/project/zones/"+clusterZone+"/clusters/cluster/nodePools/pool/update", func(w http.ResponseWriter, r *http.Request) {
+		update = &container.UpdateNodePoolRequest{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(update))
+		writeJSON(t, w, &container.Operation{Name: "roll-op"})
+	})
+	mux.HandleFunc("/v1/projects/project/zones/"+clusterZone+"/operations/roll-op", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &container.Operation{Name: "roll-op", Status: doneStatus})
+	})
+	mux.HandleFunc("/v1/projects/project/zones/"+clusterZone+"/clusters/cluster", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &container.Cluster{Name: "cluster", Status: "RUNNING"})
+	})
+
+	s := newTestGCEOps(t, mux)
+	s.inst.clusterName = "cluster"
+	s.inst.clusterLocation = clusterZone
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	containerService, err := container.NewService(
+		context.Background(),
+		option.WithEndpoint(server.URL+"/"),
+		option.WithoutAuthentication(),
+	)
+	require.NoError(t, err)
+	s.containerService = containerService
+
+	err = s.RollInstanceGroup("pool", cloudops.RollOpts{
+		MaxSurge:       2,
+		MaxUnavailable: 0,
+		Timeout:        time.Minute,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, update)
+	require.Equal(t, "1.27.3-gke.100", update.NodeVersion)
+	require.Equal(t, "COS_CONTAINERD", update.ImageType)
+	require.Equal(t, int64(2), update.UpgradeSettings.MaxSurge)
+	require.Equal(t, int64(0), update.UpgradeSettings.MaxUnavailable)
+}
+
+func TestSetInstanceGroupVersion(t *testing.T) {
+	const clusterRegion = "us-central1"
+	nodePoolPath := "/v1/projects/project/locations/" + clusterRegion + "/clusters/cluster/nodePools/pool"
+
+	var (
+		update   *container.UpdateNodePoolRequest
+		opPolled bool
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc(nodePoolPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			update = &container.UpdateNodePoolRequest{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(update))
+			writeJSON(t, w, &container.Operation{Name: "upgrade-op"})
+			return
+		}
+		writeJSON(t, w, &container.NodePool{
+			Name:    "pool",
+			Version: "1.27.3-gke.100",
+			Config:  &container.NodeConfig{ImageType: "COS_CONTAINERD"},
+		})
+	})
+	mux.HandleFunc("/v1/projects/project/locations/"+clusterRegion+"/operations/upgrade-op", func(w http.ResponseWriter, r *http.Request) {
+		opPolled = true
+		writeJSON(t, w, &container.Operation{Name: "upgrade-op", Status: doneStatus})
+	})
+	mux.HandleFunc("/v1/projects/project/locations/"+clusterRegion+"/clusters/cluster", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &container.Cluster{Name: "cluster", Status: "RUNNING"})
+	})
+
+	s := newTestGCEOps(t, mux)
+	s.inst.clusterName = "cluster"
+	s.inst.clusterLocation = clusterRegion
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	containerService, err := container.NewService(
+		context.Background(),
+		option.WithEndpoint(server.URL+"/"),
+		option.WithoutAuthentication(),
+	)
+	require.NoError(t, err)
+	s.containerService = containerService
+
+	// without a timeout the upgrade is not waited for
+	require.NoError(t, s.SetInstanceGroupVersion("pool", "1.28.1-gke.200", 0))
+	require.NotNil(t, update)
+	require.Equal(t, "1.28.1-gke.200", update.NodeVersion)
+	require.Equal(t, "COS_CONTAINERD", update.ImageType)
+	require.Equal(t, strings.TrimPrefix(nodePoolPath, "/v1/"), update.Name)
+	require.False(t, opPolled)
+
+	require.NoError(t, s.SetInstanceGroupVersion("pool", "1.28.1-gke.200", time.Minute))
+	require.True(t, opPolled)
+}
+
+func TestSetInstanceGroupNodeLabelsAndTaints(t *testing.T) {
+	const clusterZone = "us-central1-a"
+
+	var update map[string]interface{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/projects/project/zones/"+clusterZone+"/clusters/cluster/nodePools/pool", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &container.NodePool{
+			Name:    "pool",
+			Version: "1.27.3-gke.100",
+			Config:  &container.NodeConfig{ImageType: "COS_CONTAINERD"},
+		})
+	})
+	mux.HandleFunc("/v1/projects/project/locations/"+clusterZone+"/clusters/cluster/nodePools/pool", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPut, r.Method)
+		update = make(map[string]interface{})
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&update))
+		writeJSON(t, w, &container.Operation{Name: "update-op"})
+	})
+	mux.HandleFunc("/v1/projects/project/zones/"+clusterZone+"/operations/update-op", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &container.Operation{Name: "update-op", Status: doneStatus})
+	})
+	mux.HandleFunc("/v1/projects/project/zones/"+clusterZone+"/clusters/cluster", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &container.Cluster{Name: "cluster", Status: "RUNNING"})
+	})
+
+	s := newTestGCEOps(t, mux)
+	s.inst.clusterName = "cluster"
+	s.inst.clusterLocation = clusterZone
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	containerService, err := container.NewService(
+		context.Background(),
+		option.WithEndpoint(server.URL+"/"),
+		option.WithoutAuthentication(),
+	)
+	require.NoError(t, err)
+	s.containerService = containerService
+
+	err = s.SetInstanceGroupNodeLabels("pool", map[string]string{"storage": "true"}, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"name":        "projects/project/locations/" + clusterZone + "/clusters/cluster/nodePools/pool",
+		"nodeVersion": "1.27.3-gke.100",
+		"imageType":   "COS_CONTAINERD",
+		"labels": map[string]interface{}{
+			"labels": map[string]interface{}{"storage": "true"},
+		},
+	}, update)
+
+	err = s.SetInstanceGroupNodeTaints("pool", []cloudops.NodeTaint{
+		{Key: "storage", Value: "true", Effect: cloudops.TaintEffectNoSchedule},
+	}, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"taints": []interface{}{
+			map[string]interface{}{"key": "storage", "value": "true", "effect": "NO_SCHEDULE"},
+		},
+	}, update["taints"])
+
+	err = s.SetInstanceGroupNodeTaints("pool", []cloudops.NodeTaint{
+		{Key: "storage", Value: "true", Effect: "Invalid"},
+	}, time.Minute)
+	require.Error(t, err)
+}
+
+func TestGetClusterVersion(t *testing.T) {
+	const clusterRegion = "us-central1"
+
+	metadataItem := func(key, value string) *compute.MetadataItems {
+		return &compute.MetadataItems{Key: key, Value: &value}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/zones/zone/instances/node-1", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Instance{
+			Name: "node-1",
+			Metadata: &compute.Metadata{
+				Items: []*compute.MetadataItems{
+					metadataItem(clusterNameKey, "cluster"),
+					metadataItem(clusterLocationKey, clusterRegion),
+					metadataItem(instanceTemplateKey, "gke-cluster-pool-template"),
+					metadataItem(kubeLabelsKey, nodePoolKey+"=pool"),
+				},
+			},
+		})
+	})
+	mux.HandleFunc("/v1/projects/project/locations/"+clusterRegion+"/clusters/cluster/nodePools/pool", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &container.NodePool{Name: "pool", Version: "1.26.5-gke.1200"})
+	})
+	mux.HandleFunc("/v1/projects/project/locations/"+clusterRegion+"/clusters/cluster", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &container.Cluster{Name: "cluster", CurrentMasterVersion: "1.27.3-gke.100"})
+	})
+
+	s := newTestGCEOps(t, mux)
+	s.inst.clusterName = "cluster"
+	s.inst.clusterLocation = clusterRegion
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	containerService, err := container.NewService(
+		context.Background(),
+		option.WithEndpoint(server.URL+"/"),
+		option.WithoutAuthentication(),
+	)
+	require.NoError(t, err)
+	s.containerService = containerService
+
+	controlPlane, nodePool, err := s.GetClusterVersion("node-1")
+	require.NoError(t, err)
+	require.Equal(t, "1.27.3-gke.100", controlPlane)
+	require.Equal(t, "1.26.5-gke.1200", nodePool)
+}
+
+func TestGetInstanceGroupSizeNodePoolPath(t *testing.T) {
+	for _, tc := range []struct {
+		clusterLocation string
+		nodePoolPath    string
+	}{
+		{"us-central1-a", "/v1/projects/project/zones/us-central1-a/clusters/cluster/nodePools/pool"},
+		{"us-central1", "/v1/projects/project/locations/us-central1/clusters/cluster/nodePools/pool"},
+	} {
+		t.Run(tc.clusterLocation, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc(tc.nodePoolPath, func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(t, w, &container.NodePool{
+					Name: "pool",
+					InstanceGroupUrls: []string{
+						"https://www.googleapis.com/compute/v1/projects/project/zones/us-central1-a/instanceGroupManagers/gke-pool-a-grp",
+						"https://www.googleapis.com/compute/v1/projects/project/zones/us-central1-b/instanceGroupManagers/gke-pool-b-grp",
+					},
+				})
+			})
+			mux.HandleFunc("/projects/project/zones/us-central1-a/instanceGroups/gke-pool-a-grp", func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(t, w, &compute.InstanceGroup{Name: "gke-pool-a-grp", Size: 2})
+			})
+			mux.HandleFunc("/projects/project/zones/us-central1-b/instanceGroups/gke-pool-b-grp", func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(t, w, &compute.InstanceGroup{Name: "gke-pool-b-grp", Size: 3})
+			})
+			mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+				t.Errorf("unexpected request path %s", r.URL.Path)
+				w.WriteHeader(http.StatusNotFound)
+			})
+
+			s := newTestGCEOps(t, mux)
+			s.inst.clusterName = "cluster"
+			s.inst.clusterLocation = tc.clusterLocation
+
+			server := httptest.NewServer(mux)
+			defer server.Close()
+			containerService, err := container.NewService(
+				context.Background(),
+				option.WithEndpoint(server.URL+"/"),
+				option.WithoutAuthentication(),
+			)
+			require.NoError(t, err)
+			s.containerService = containerService
+
+			size, err := s.GetInstanceGroupSize("pool")
+			require.NoError(t, err)
+			require.Equal(t, int64(5), size)
+		})
+	}
+}
+
+func TestGetClusterSizeForInstanceErrors(t *testing.T) {
+	metadataItem := func(key, value string) *compute.MetadataItems {
+		return &compute.MetadataItems{Key: key, Value: &value}
+	}
+	gkeMetadata := &compute.Metadata{
+		Items: []*compute.MetadataItems{
+			metadataItem(clusterNameKey, "cluster"),
+			metadataItem(clusterLocationKey, "us-central1"),
+			metadataItem(instanceTemplateKey, "gke-cluster-pool-template"),
+			metadataItem(kubeLabelsKey, nodePoolKey+"=pool"),
+		},
+	}
+
+	for _, tc := range []struct {
+		name string
+		// instanceMetadata is the metadata of the local instance, nil fails
+		// its lookup
+		instanceMetadata *compute.Metadata
+		nodePoolStatus   int
+	}{
+		{"instance lookup fails", nil, http.StatusOK},
+		{"node pool lookup fails", gkeMetadata, http.StatusInternalServerError},
+		{"cluster name not found", &compute.Metadata{}, http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/projects/project/zones/zone/instances/"+testInstance, func(w http.ResponseWriter, r *http.Request) {
+				if tc.instanceMetadata == nil {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				writeJSON(t, w, &compute.Instance{Name: testInstance, Metadata: tc.instanceMetadata})
+			})
+			mux.HandleFunc("/projects/project/zones/zone/instances/worker", func(w http.ResponseWriter, r *http.Request) {
+				if tc.instanceMetadata == nil {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				writeJSON(t, w, &compute.Instance{Name: "worker", Metadata: gkeMetadata})
+			})
+			mux.HandleFunc("/v1/projects/project/locations/us-central1/clusters/cluster/nodePools/pool", func(w http.ResponseWriter, r *http.Request) {
+				if tc.nodePoolStatus != http.StatusOK {
+					w.WriteHeader(tc.nodePoolStatus)
+					return
+				}
+				writeJSON(t, w, &container.NodePool{Name: "pool"})
+			})
+			mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+				t.Errorf("unexpected request path %s", r.URL.Path)
+				w.WriteHeader(http.StatusNotFound)
+			})
+
+			s := newTestGCEOps(t, mux)
+			server := httptest.NewServer(mux)
+			defer server.Close()
+			containerService, err := container.NewService(
+				context.Background(),
+				option.WithEndpoint(server.URL+"/"),
+				option.WithoutAuthentication(),
+			)
+			require.NoError(t, err)
+			s.containerService = containerService
+
+			size, err := s.GetClusterSizeForInstance("worker")
+			require.Error(t, err)
+			require.Zero(t, size)
+		})
+	}
+}
+
+func TestFormatLabels(t *testing.T) {
+	labels := map[string]string{
+		"Test":      "UPPER_CASE",
+		"team.name": "Storage",
+	}
+
+	s := &gceOps{}
+	require.Equal(t, map[string]string{
+		"test":      "upper_case",
+		"team_name": "storage",
+	}, s.formatLabels(labels))
+
+	s.preserveLabelCase = true
+	require.Equal(t, map[string]string{
+		"test":      "UPPER_CASE",
+		"team_name": "Storage",
+	}, s.formatLabels(labels))
+}
+
+func TestTagsPreserveLabelCase(t *testing.T) {
+	const diskName = "disk1"
+
+	labels := map[string]string{"app": "px"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/zones/zone/disks/"+diskName, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Disk{Name: diskName, Labels: labels})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/disks/"+diskName+"/setLabels", func(w http.ResponseWriter, r *http.Request) {
+		rb := &compute.ZoneSetLabelsRequest{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(rb))
+		labels = rb.Labels
+		writeJSON(t, w, &compute.Operation{Name: "labels-op", Status: doneStatus})
+	})
+	s := newTestGCEOps(t, mux)
+	s.preserveLabelCase = true
+
+	tags := map[string]string{"Test": "UPPER_CASE"}
+	require.NoError(t, s.ApplyTags(diskName, tags, nil))
+	got, err := s.Tags(diskName)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"app": "px", "test": "UPPER_CASE"}, got)
+
+	require.NoError(t, s.RemoveTags(diskName, tags, nil))
+	got, err = s.Tags(diskName)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"app": "px"}, got)
+}
+
+func TestStorageLayoutRoundTrip(t *testing.T) {
+	const diskTypeURL = "https://www.googleapis.com/compute/v1/projects/project/zones/zone/diskTypes/"
+
+	disks := map[string]*compute.Disk{
+		"data-1": {Name: "data-1", SizeGb: 100, Type: diskTypeURL + "pd-ssd", Labels: map[string]string{"pool": "0"}},
+		"data-2": {Name: "data-2", SizeGb: 500, Type: diskTypeURL + "pd-balanced", Labels: map[string]string{"pool": "1"}},
+	}
+	instances := map[string]*compute.Instance{
+		"old": {
+			Name: "old",
+			Disks: []*compute.AttachedDisk{
+				{Boot: true, Index: 0, Type: "PERSISTENT", Source: "zones/zone/disks/boot"},
+				{Index: 2, Type: "PERSISTENT", Source: "zones/zone/disks/data-2"},
+				{Index: 1, Type: "PERSISTENT", Source: "zones/zone/disks/data-1"},
+				{Index: 3, Type: "SCRATCH"},
+			},
+		},
+		"new": {Name: "new"},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/zones/zone/instances/", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, instances[path.Base(r.URL.Path)])
+	})
+	mux.HandleFunc("/projects/project/zones/zone/disks", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		d := &compute.Disk{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(d))
+		require.True(t, strings.HasPrefix(d.Name, layoutDiskPrefix+"-"))
+		require.True(t, strings.HasPrefix(d.Type, "projects/project/zones/zone/diskTypes/"))
+
+		d.Type = diskTypeURL + path.Base(d.Type)
+		d.Status = "READY"
+		disks[d.Name] = d
+		// Attach the new disks to the new instance in creation order
+		inst := instances["new"]
+		inst.Disks = append(inst.Disks, &compute.AttachedDisk{
+			Index:  int64(len(inst.Disks) + 1),
+			Type:   "PERSISTENT",
+			Source: "zones/zone/disks/" + d.Name,
+		})
+		writeJSON(t, w, &compute.Operation{Name: "create-op", Status: doneStatus})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/disks/", func(w http.ResponseWriter, r *http.Request) {
+		d, ok := disks[path.Base(r.URL.Path)]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(t, w, d)
+	})
+	mux.HandleFunc("/projects/project/zones/zone/operations/create-op", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Operation{Name: "create-op", Status: doneStatus})
+	})
+
+	s := newTestGCEOps(t, mux)
+
+	specs, err := s.GetStorageLayout("old")
+	require.NoError(t, err)
+	require.Len(t, specs, 2)
+	require.Equal(t, uint64(100), specs[0].SizeInGiB)
+	require.Equal(t, "pd-ssd", specs[0].Type)
+	require.Equal(t, map[string]string{"pool": "0"}, specs[0].Labels)
+	require.Equal(t, uint64(500), specs[1].SizeInGiB)
+	require.Equal(t, "pd-balanced", specs[1].Type)
+
+	vols, err := s.ProvisionStorageLayout(specs, map[string]string{"node": "new"})
+	require.NoError(t, err)
+	require.Len(t, vols, 2)
+
+	newSpecs, err := s.GetStorageLayout("new")
+	require.NoError(t, err)
+	for i := range specs {
+		specs[i].Labels["node"] = "new"
+	}
+	require.Equal(t, specs, newSpecs)
+}
+
+func TestGetVolumeLineage(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/aggregated/disks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.DiskAggregatedList{
+			Items: map[string]compute.DisksScopedList{
+				"zones/zone": {
+					Disks: []*compute.Disk{
+						{
+							Name:             "from-snap",
+							SourceSnapshot:   "https://www.googleapis.com/compute/v1/projects/project/global/snapshots/snap1",
+							SourceSnapshotId: "1234",
+						},
+						{
+							Name:          "from-image",
+							SourceImage:   "https://www.googleapis.com/compute/v1/projects/debian-cloud/global/images/debian-11",
+							SourceImageId: "5678",
+						},
+						{
+							Name:         "from-disk",
+							SourceDisk:   "projects/project/zones/zone/disks/disk1",
+							SourceDiskId: "9012",
+						},
+						{Name: "empty"},
+					},
+				},
+			},
+		})
+	})
+	s := newTestGCEOps(t, mux)
+
+	cases := []struct {
+		id         string
+		sourceType string
+		sourceID   string
+	}{
+		{"from-snap", cloudops.VolumeSourceSnapshot, "snap1"},
+		{"from-image", cloudops.VolumeSourceImage,
+			"https://www.googleapis.com/compute/v1/projects/debian-cloud/global/images/debian-11"},
+		{"from-disk", cloudops.VolumeSourceClone, "disk1"},
+		{"empty", "", ""},
+	}
+	for _, c := range cases {
+		sourceType, sourceID, err := s.GetVolumeLineage(c.id)
+		require.NoError(t, err, c.id)
+		require.Equal(t, c.sourceType, sourceType, c.id)
+		require.Equal(t, c.sourceID, sourceID, c.id)
+	}
+
+	_, _, err := s.GetVolumeLineage("missing")
+	require.Error(t, err)
+}
+
+func TestListVolumesPaged(t *testing.T) {
+	var queries []map[string]string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/aggregated/disks", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		queries = append(queries, map[string]string{
+			"filter":     query.Get("filter"),
+			"maxResults": query.Get("maxResults"),
+			"pageToken":  query.Get("pageToken"),
+		})
+		switch query.Get("pageToken") {
+		case "":
+			writeJSON(t, w, &compute.DiskAggregatedList{
+				Items: map[string]compute.DisksScopedList{
+					"zones/zone-b": {Disks: []*compute.Disk{{Name: "disk-3"}}},
+					"zones/zone-a": {Disks: []*compute.Disk{{Name: "disk-1"}, {Name: "disk-2"}}},
+				},
+				NextPageToken: "page-2",
+			})
+		case "page-2":
+			writeJSON(t, w, &compute.DiskAggregatedList{
+				Items: map[string]compute.DisksScopedList{
+					"regions/region": {Disks: []*compute.Disk{{Name: "disk-4"}}},
+				},
+				NextPageToken: "page-3",
+			})
+		default:
+			http.Error(w, "backend error", http.StatusInternalServerError)
+		}
+	})
+	ops := newTestGCEOps(t, mux)
+
+	it, err := ops.ListVolumesPaged(map[string]string{"app": "DB"}, 1000)
+	require.NoError(t, err)
+
+	var names []string
+	for disk, ok := it.Next(); ok; disk, ok = it.Next() {
+		names = append(names, disk.(*compute.Disk).Name)
+	}
+	require.Equal(t, []string{"disk-1", "disk-2", "disk-3", "disk-4"}, names)
+	require.Error(t, it.Err(), "the failed fetch of the third page should end the iteration")
+
+	require.Len(t, queries, 3)
+	// label values are lower cased and the page size is bounded by the API limit
+	require.Equal(t, "(labels.app eq db)", queries[0]["filter"])
+	require.Equal(t, "500", queries[0]["maxResults"])
+	require.Equal(t, "page-2", queries[1]["pageToken"])
+	require.Equal(t, "(labels.app eq db)", queries[1]["filter"])
+}
+
+func TestLockVolumeContention(t *testing.T) {
+	const diskName = "disk1"
+
+	var (
+		mu          sync.Mutex
+		labels      = map[string]string{"app": "px"}
+		fingerprint = 0
+		reads       int32
+		bothRead    sync.WaitGroup
+	)
+	bothRead.Add(2)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/zones/zone/disks/"+diskName, func(w http.ResponseWriter, r *http.Request) {
+		// Hold the first read of each owner until both have read the disk so
+		// that their label updates race
+		if atomic.AddInt32(&reads, 1) <= 2 {
+			bothRead.Done()
+			bothRead.Wait()
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		writeJSON(t, w, &compute.Disk{
+			Name:             diskName,
+			Labels:           labels,
+			LabelFingerprint: strconv.Itoa(fingerprint),
+		})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/disks/"+diskName+"/setLabels", func(w http.ResponseWriter, r *http.Request) {
+		rb := &compute.ZoneSetLabelsRequest{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(rb))
+
+		mu.Lock()
+		defer mu.Unlock()
+		if rb.LabelFingerprint != strconv.Itoa(fingerprint) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusPreconditionFailed)
+			w.Write([]byte(`{"error": {"code": 412, "message": "Labels fingerprint either invalid or resource labels have changed"}}`))
+			return
+		}
+		labels = rb.Labels
+		fingerprint++
+		writeJSON(t, w, &compute.Operation{Name: "labels-op", Status: doneStatus})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/operations/labels-op", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Operation{Name: "labels-op", Status: doneStatus})
+	})
+
+	s := newTestGCEOps(t, mux)
+
+	owners := []string{"node-a", "node-b"}
+	locked := make([]bool, len(owners))
+	errs := make([]error, len(owners))
+	var wg sync.WaitGroup
+	for i, owner := range owners {
+		wg.Add(1)
+		go func(i int, owner string) {
+			defer wg.Done()
+			locked[i], errs[i] = s.LockVolume(diskName, owner)
+		}(i, owner)
+	}
+	wg.Wait()
+
+	require.NoError(t, errs[0])
+	require.NoError(t, errs[1])
+	require.True(t, locked[0] != locked[1], "exactly one owner must acquire the lock")
+
+	winner, loser := owners[0], owners[1]
+	if locked[1] {
+		winner, loser = loser, winner
+	}
+	require.Equal(t, map[string]string{"app": "px", cloudops.VolumeLockTagKey: winner}, labels)
+
+	// Locking again is idempotent for the winner
+	ok, err := s.LockVolume(diskName, winner)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	err = s.UnlockVolume(diskName, loser)
+	require.Error(t, err)
+	storageErr, isStorageErr := err.(*cloudops.StorageError)
+	require.True(t, isStorageErr)
+	require.Equal(t, cloudops.ErrVolumeLocked, storageErr.Code)
+
+	require.NoError(t, s.UnlockVolume(diskName, winner))
+	require.Equal(t, map[string]string{"app": "px"}, labels)
+
+	ok, err = s.LockVolume(diskName, loser)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func TestLockOwnerLabel(t *testing.T) {
+	long := strings.Repeat("node", 20)
+	testCases := []struct {
+		owner    string
+		expected string
+	}{
+		{owner: "node-a", expected: "node-a"},
+		{owner: "node_1", expected: "node_1"},
+		{owner: "Node-A", expected: "node-a-"},
+		{owner: "ip-10-0-0-1.ec2.internal", expected: "ip-10-0-0-1-ec2-internal-"},
+		{owner: long, expected: long[:54] + "-"},
+	}
+	for _, tc := range testCases {
+		label := lockOwnerLabel(tc.owner)
+		require.True(t, strings.HasPrefix(label, tc.expected), "owner %s: %s", tc.owner, label)
+		require.Regexp(t, "^[a-z0-9_-]{1,63}$", label)
+		require.Equal(t, label, lockOwnerLabel(tc.owner), "the label must be stable")
+	}
+
+	// owners that only differ in the replaced characters or past the length
+	// limit are kept distinct
+	require.NotEqual(t, lockOwnerLabel("node.a"), lockOwnerLabel("node/a"))
+	require.NotEqual(t, lockOwnerLabel("Node-A"), lockOwnerLabel("node-a"))
+	require.NotEqual(t, lockOwnerLabel(long+"1"), lockOwnerLabel(long+"2"))
+}
+
+func TestLockVolumeOwnerLabel(t *testing.T) {
+	const diskName = "disk1"
+
+	var (
+		labels      = map[string]string{}
+		fingerprint = 0
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/zones/zone/disks/"+diskName, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Disk{
+			Name:             diskName,
+			Labels:           labels,
+			LabelFingerprint: strconv.Itoa(fingerprint),
+		})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/disks/"+diskName+"/setLabels", func(w http.ResponseWriter, r *http.Request) {
+		rb := &compute.ZoneSetLabelsRequest{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(rb))
+		labels = rb.Labels
+		fingerprint++
+		writeJSON(t, w, &compute.Operation{Name: "labels-op", Status: doneStatus})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/operations/labels-op", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Operation{Name: "labels-op", Status: doneStatus})
+	})
+	s := newTestGCEOps(t, mux)
+
+	owner := "ip-10-0-0-1.us-central1-a.c.my-project.internal/portworx-api-0"
+	ok, err := s.LockVolume(diskName, owner)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, lockOwnerLabel(owner), labels[cloudops.VolumeLockTagKey])
+
+	// the owner is compared with the stored label
+	ok, err = s.LockVolume(diskName, owner)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = s.LockVolume(diskName, strings.ToUpper(owner))
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, s.UnlockVolume(diskName, owner))
+	require.NotContains(t, labels, cloudops.VolumeLockTagKey)
+}
+
+func TestSetLogger(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/aggregated/disks", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": {"code": 400, "message": "invalid filter"}}`))
+	})
+	ops := backoff.NewExponentialBackoffOps(newTestGCEOps(t, mux), isExponentialError, backoff.DefaultExponentialBackoff)
+
+	var out bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&out)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	require.True(t, cloudops.SetLogger(ops, logger.WithField(cloudops.LogFieldRequestID, "req-1")))
+
+	_, err := ops.Enumerate(nil, nil, cloudops.SetIdentifierNone)
+	require.Error(t, err)
+
+	line := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &line))
+	require.Equal(t, "req-1", line[cloudops.LogFieldRequestID])
+	require.Equal(t, string(cloudops.GCE), line[cloudops.LogFieldProvider])
+	require.Equal(t, "Enumerate", line[cloudops.LogFieldMethod])
+}
+
+func TestGCEInfoIsCached(t *testing.T) {
+	zoneRequests := 0
+	values := map[string]string{
+		"/computeMetadata/v1/instance/zone":                           "projects/1234/zones/us-east1-b",
+		"/computeMetadata/v1/instance/name":                           testInstance,
+		"/computeMetadata/v1/instance/hostname":                       "instance.c.project.internal",
+		"/computeMetadata/v1/project/project-id":                      testProject,
+		"/computeMetadata/v1/instance/service-accounts/default/email": "sa@project.iam.gserviceaccount.com",
+	}
+	metadataServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/computeMetadata/v1/instance/zone" {
+			zoneRequests++
+		}
+		value, ok := values[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, value)
+	}))
+	defer metadataServer.Close()
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(metadataServer.URL, "http://"))
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	t.Setenv("HOME", t.TempDir())
+	cloudops.RefreshMetadata()
+	defer cloudops.RefreshMetadata()
+
+	for n := 0; n < 2; n++ {
+		inst := new(instance)
+		require.NoError(t, gceInfo(context.Background(), inst))
+		require.Equal(t, "us-east1-b", inst.zone)
+		require.Equal(t, "us-east1", inst.region)
+		require.Equal(t, testInstance, inst.name)
+		require.Equal(t, testProject, inst.project)
+	}
+	require.Equal(t, 1, zoneRequests, "metadata should be served from the cache")
+
+	cloudops.RefreshMetadata()
+	require.NoError(t, gceInfo(context.Background(), new(instance)))
+	require.Equal(t, 2, zoneRequests, "metadata should be fetched again after a refresh")
+}
+
+func TestGetNetworkInfo(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/zones/zone/instances/instance", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Instance{
+			Name: testInstance,
+			NetworkInterfaces: []*compute.NetworkInterface{
+				{
+					Network:    "https://www.googleapis.com/compute/v1/projects/project/global/networks/default",
+					Subnetwork: "https://www.googleapis.com/compute/v1/projects/project/regions/region/subnetworks/subnet-1",
+					NetworkIP:  "10.128.0.2",
+				},
+				{
+					Network:    "https://www.googleapis.com/compute/v1/projects/project/global/networks/other",
+					Subnetwork: "https://www.googleapis.com/compute/v1/projects/project/regions/region/subnetworks/subnet-2",
+					NetworkIP:  "10.130.0.2",
+				},
+			},
+		})
+	})
+
+	s := newTestGCEOps(t, mux)
+	networkInfo, err := s.GetNetworkInfo(testInstance)
+	require.NoError(t, err)
+	require.Equal(t, "default", networkInfo.Network)
+	require.Equal(t, "subnet-1", networkInfo.Subnet)
+	require.Equal(t, []string{"10.128.0.2", "10.130.0.2"}, networkInfo.PrivateIPs)
+}
+
+func TestGetNetworkInfoOtherZone(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/regions/region", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Region{
+			Name: "region",
+			Zones: []string{
+				"https://www.googleapis.com/compute/v1/projects/project/zones/zone",
+				"https://www.googleapis.com/compute/v1/projects/project/zones/zone-b",
+			},
+		})
+	})
+	mux.HandleFunc("/projects/project/zones/zone-b/instances/other", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Instance{
+			Name: "other",
+			NetworkInterfaces: []*compute.NetworkInterface{
+				{
+					Network:    "https://www.googleapis.com/compute/v1/projects/project/global/networks/default",
+					Subnetwork: "https://www.googleapis.com/compute/v1/projects/project/regions/region/subnetworks/subnet-1",
+					NetworkIP:  "10.128.0.3",
+				},
+			},
+		})
+	})
+
+	s := newTestGCEOps(t, mux)
+	networkInfo, err := s.GetNetworkInfo("other")
+	require.NoError(t, err)
+	require.Equal(t, "default", networkInfo.Network)
+	require.Equal(t, []string{"10.128.0.3"}, networkInfo.PrivateIPs)
+}
+
+func TestOperationError(t *testing.T) {
+	failed := func(code, message string) *compute.Operation {
+		return &compute.Operation{
+			Name:                "op",
+			Status:              doneStatus,
+			HttpErrorStatusCode: http.StatusForbidden,
+			Error: &compute.OperationError{
+				Errors: []*compute.OperationErrorErrors{{Code: code, Message: message}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		op      *compute.Operation
+		code    int
+		message string
+	}{
+		{
+			name:    "quota exceeded",
+			op:      failed("QUOTA_EXCEEDED", "Quota 'SSD_TOTAL_GB' exceeded."),
+			code:    cloudops.ErrQuotaExceeded,
+			message: "QUOTA_EXCEEDED - Quota 'SSD_TOTAL_GB' exceeded.",
+		},
+		{
+			name:    "zone resource pool exhausted",
+			op:      failed("ZONE_RESOURCE_POOL_EXHAUSTED", "The zone does not have enough resources."),
+			code:    cloudops.ErrQuotaExceeded,
+			message: "ZONE_RESOURCE_POOL_EXHAUSTED - The zone does not have enough resources.",
+		},
+		{
+			name:    "resource not ready",
+			op:      failed("RESOURCE_NOT_READY", "The resource 'disk1' is not ready"),
+			code:    cloudops.ErrOperationInProgress,
+			message: "RESOURCE_NOT_READY - The resource 'disk1' is not ready",
+		},
+		{
+			name:    "resource not found",
+			op:      failed("RESOURCE_NOT_FOUND", "The resource 'disk1' was not found"),
+			code:    cloudops.ErrVolNotFound,
+			message: "RESOURCE_NOT_FOUND - The resource 'disk1' was not found",
+		},
+		{
+			name:    "unmapped error",
+			op:      failed("INTERNAL_ERROR", "Internal error."),
+			message: "INTERNAL_ERROR - Internal error.",
+		},
+		{
+			name: "success",
+			op:   &compute.Operation{Name: "op", Status: doneStatus},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/projects/project/zones/zone/operations/op", func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(t, w, test.op)
+			})
+			s := newTestGCEOps(t, mux)
+			s.opsTimeout = cloudops.OpsTimeoutConfig{
+				Timeout:       time.Second,
+				RetryInterval: 10 * time.Millisecond,
+			}
+
+			err := s.waitForOpCompletion("test", testZone, &compute.Operation{Name: "op"})
+			if len(test.message) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			if test.code == 0 {
+				gerr, ok := err.(*googleapi.Error)
+				require.True(t, ok, "expected a googleapi error, got: %v", err)
+				require.Equal(t, http.StatusForbidden, gerr.Code)
+				require.Equal(t, test.message, gerr.Message)
+				return
+			}
+			se, ok := err.(*cloudops.StorageError)
+			require.True(t, ok, "expected a storage error, got: %v", err)
+			require.Equal(t, test.code, se.Code)
+			require.Equal(t, test.message, se.Msg)
+			require.Equal(t, testInstance, se.Instance)
+		})
+	}
+}
+
+func TestGetEffectivePerformance(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/zones/zone/disks/hyperdisk", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"name": "hyperdisk",
+			"type": "https://www.googleapis.com/compute/v1/projects/project/zones/zone/diskTypes/hyperdisk-balanced",
+			"provisionedIops": "6000", "provisionedThroughput": "290"}`)
+	})
+	mux.HandleFunc("/projects/project/zones/zone/disks/pd", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"name": "pd",
+			"type": "https://www.googleapis.com/compute/v1/projects/project/zones/zone/diskTypes/pd-ssd"}`)
+	})
+
+	s := newTestGCEOps(t, mux)
+	iops, throughput, err := s.GetEffectivePerformance("hyperdisk")
+	require.NoError(t, err)
+	require.Equal(t, uint64(6000), iops)
+	require.Equal(t, uint64(290), throughput)
+
+	_, _, err = s.GetEffectivePerformance("pd")
+	require.Error(t, err)
+	_, ok := err.(*cloudops.ErrNotSupported)
+	require.True(t, ok)
+
+	_, _, err = s.GetEffectivePerformance("missing")
+	require.Error(t, err)
+}
+
+func TestCheckVolumeQuota(t *testing.T) {
+	regionGets := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/regions/region", func(w http.ResponseWriter, r *http.Request) {
+		regionGets++
+		writeJSON(t, w, &compute.Region{
+			Name: "region",
+			Quotas: []*compute.Quota{
+				{Metric: "CPUS", Limit: 24, Usage: 24},
+				{Metric: "DISKS_TOTAL_GB", Limit: 4096, Usage: 1024},
+				{Metric: "SSD_TOTAL_GB", Limit: 2048, Usage: 1024},
+			},
+		})
+	})
+	ops := newTestGCEOps(t, mux)
+
+	// 3 instances with 2 drives of 128 GiB each fit in the SSD quota
+	err := ops.CheckVolumeQuota([]*cloudops.StoragePoolSpec{
+		{DriveType: "pd-ssd", DriveCapacityGiB: 128, DriveCount: 2, InstancesPerZone: 3},
+		{DriveType: "pd-standard", DriveCapacityGiB: 1024, DriveCount: 1, InstancesPerZone: 3},
+	})
+	require.NoError(t, err)
+
+	// the same pools in 3 zones need 3 times the capacity
+	err = ops.CheckVolumeQuota([]*cloudops.StoragePoolSpec{
+		{DriveType: "pd-ssd", DriveCapacityGiB: 128, DriveCount: 2, InstancesPerZone: 3, ZoneCount: 3},
+		{DriveType: "pd-standard", DriveCapacityGiB: 1024, DriveCount: 1, InstancesPerZone: 3, ZoneCount: 3},
+	})
+	require.Error(t, err)
+	quotaErr, ok := err.(*cloudops.ErrVolumeQuotaExceeded)
+	require.True(t, ok, "expected a quota error, got: %v", err)
+	require.Equal(t, []cloudops.QuotaBreach{
+		{Quota: "DISKS_TOTAL_GB", Limit: 4096, Usage: 1024, Required: 9216},
+		{Quota: "SSD_TOTAL_GB", Limit: 2048, Usage: 1024, Required: 2304},
+	}, quotaErr.Quotas)
+
+	// pd-balanced and pd-ssd both count against the SSD quota
+	err = ops.CheckVolumeQuota([]*cloudops.StoragePoolSpec{
+		{DriveType: "pd-ssd", DriveCapacityGiB: 512, DriveCount: 2, InstancesPerZone: 1},
+		{DriveType: "pd-balanced", DriveCapacityGiB: 512, DriveCount: 2, InstancesPerZone: 1},
+		{DriveType: "pd-standard", DriveCapacityGiB: 1024, DriveCount: 1, InstancesPerZone: 3},
+	})
+	require.Error(t, err)
+	quotaErr, ok = err.(*cloudops.ErrVolumeQuotaExceeded)
+	require.True(t, ok, "expected a quota error, got: %v", err)
+	require.Equal(t, []cloudops.QuotaBreach{
+		{Quota: "SSD_TOTAL_GB", Limit: 2048, Usage: 1024, Required: 2048},
+	}, quotaErr.Quotas)
+
+	// disk types given as URLs are checked against the quota of the type
+	err = ops.CheckVolumeQuota([]*cloudops.StoragePoolSpec{
+		{
+			DriveType:        "https://www.googleapis.com/compute/v1/projects/project/zones/zone/diskTypes/pd-ssd",
+			DriveCapacityGiB: 1024,
+			DriveCount:       2,
+			InstancesPerZone: 1,
+		},
+	})
+	require.Error(t, err)
+	quotaErr, ok = err.(*cloudops.ErrVolumeQuotaExceeded)
+	require.True(t, ok, "expected a quota error, got: %v", err)
+	require.Equal(t, []cloudops.QuotaBreach{
+		{Quota: "SSD_TOTAL_GB", Limit: 2048, Usage: 1024, Required: 2048},
+	}, quotaErr.Quotas)
+
+	// disk types without a known quota are not checked
+	regionGets = 0
+	err = ops.CheckVolumeQuota([]*cloudops.StoragePoolSpec{
+		{DriveType: "hyperdisk-balanced", DriveCapacityGiB: 65536, DriveCount: 8, InstancesPerZone: 3, ZoneCount: 3},
+	})
+	require.NoError(t, err)
+	require.Zero(t, regionGets)
+}
+
+func TestParseRegionalDisk(t *testing.T) {
+	cases := []struct {
+		id     string
+		region string
+		name   string
+		ok     bool
+	}{
+		{"regions/region/disks/disk1", "region", "disk1", true},
+		{"https://www.googleapis.com/compute/v1/projects/project/regions/region/disks/disk1", "region", "disk1", true},
+		{"projects/project/zones/zone/disks/disk1", "", "", false},
+		{"disk1", "", "", false},
+	}
+	for _, c := range cases {
+		region, name, ok := parseRegionalDisk(c.id)
+		require.Equal(t, c.ok, ok, c.id)
+		require.Equal(t, c.region, region, c.id)
+		require.Equal(t, c.name, name, c.id)
+	}
+}
+
+func TestRegionalDiskLifecycle(t *testing.T) {
+	var created *compute.Disk
+	deleted := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/regions/region/disks", func(w http.ResponseWriter, r *http.Request) {
+		created = &compute.Disk{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(created))
+		created.SelfLink = "https://www.googleapis.com/compute/v1/projects/project/regions/region/disks/" + created.Name
+		writeJSON(t, w, &compute.Operation{Name: "create-op"})
+	})
+	mux.HandleFunc("/projects/project/regions/region/disks/disk1", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			deleted = true
+			writeJSON(t, w, &compute.Operation{Name: "delete-op"})
+			return
+		}
+		disk := *created
+		disk.Status = "READY"
+		writeJSON(t, w, &disk)
+	})
+	mux.HandleFunc("/projects/project/regions/region/operations/", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Operation{Status: doneStatus})
+	})
+	s := newTestGCEOps(t, mux)
+
+	d, err := s.Create(&compute.Disk{
+		Name:         "disk1",
+		SizeGb:       200,
+		Type:         "pd-balanced",
+		ReplicaZones: []string{"zone-a", "zone-b"},
+	}, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, "region", created.Region)
+	require.Empty(t, created.Zone)
+	require.Equal(t, []string{
+		"projects/project/zones/zone-a",
+		"projects/project/zones/zone-b",
+	}, created.ReplicaZones)
+	require.Equal(t, "projects/project/regions/region/diskTypes/pd-balanced", created.Type)
+
+	id, err := s.GetDeviceID(d)
+	require.NoError(t, err)
+	require.Equal(t, "regions/region/disks/disk1", id)
+
+	disks, err := s.Inspect([]*string{&id}, nil)
+	require.NoError(t, err)
+	require.Len(t, disks, 1)
+	require.Equal(t, "disk1", disks[0].(*compute.Disk).Name)
+
+	require.NoError(t, s.Delete(created.SelfLink, nil))
+	require.True(t, deleted)
+}
+
+func TestRegionalDiskExpandAndTags(t *testing.T) {
+	disk := &compute.Disk{
+		Name:             "disk1",
+		Region:           "https://www.googleapis.com/compute/v1/projects/project/regions/region",
+		SizeGb:           100,
+		Status:           "READY",
+		Labels:           map[string]string{"app": "db"},
+		LabelFingerprint: "fp-1",
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/regions/region/disks/disk1", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, disk)
+	})
+	mux.HandleFunc("/projects/project/regions/region/disks/disk1/resize", func(w http.ResponseWriter, r *http.Request) {
+		req := &compute.RegionDisksResizeRequest{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(req))
+		disk.SizeGb = req.SizeGb
+		writeJSON(t, w, &compute.Operation{Name: "resize-op"})
+	})
+	mux.HandleFunc("/projects/project/regions/region/disks/disk1/setLabels", func(w http.ResponseWriter, r *http.Request) {
+		req := &compute.RegionSetLabelsRequest{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(req))
+		require.Equal(t, disk.LabelFingerprint, req.LabelFingerprint)
+		disk.Labels = req.Labels
+		disk.LabelFingerprint = "fp-2"
+		writeJSON(t, w, &compute.Operation{Name: "labels-op"})
+	})
+	mux.HandleFunc("/projects/project/regions/region/operations/", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Operation{Status: doneStatus})
+	})
+	mux.HandleFunc("/projects/project/zones/", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected zonal request %s %s", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	s := newTestGCEOps(t, mux)
+	id := regionalDiskID("region", "disk1")
+
+	size, err := s.Expand(id, 200, nil)
+	require.NoError(t, err)
+	require.Equal(t, uint64(200), size)
+	require.Equal(t, int64(200), disk.SizeGb)
+
+	require.NoError(t, s.ApplyTags(id, map[string]string{"tier": "gold"}, nil))
+	labels, err := s.Tags(id)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"app": "db", "tier": "gold"}, labels)
+
+	require.NoError(t, s.RemoveTags(id, map[string]string{"app": ""}, nil))
+	labels, err = s.Tags(id)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"tier": "gold"}, labels)
+}
+
+func TestConfigureReplication(t *testing.T) {
+	const diskName = "disk1"
+	primaryURL := "https://www.googleapis.com/compute/v1/projects/project/zones/zone-a/disks/" + diskName
+
+	var (
+		secondary   map[string]interface{}
+		replication map[string]string
+		stopped     bool
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/zones/us-central1-a/disks/"+diskName, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Disk{
+			Name:     diskName,
+			SelfLink: primaryURL,
+			SizeGb:   10,
+			Type:     "https://www.googleapis.com/compute/v1/projects/project/zones/us-central1-a/diskTypes/pd-balanced",
+		})
+	})
+	mux.HandleFunc("/projects/project/zones/us-east1-a/disks", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&secondary))
+		writeJSON(t, w, &compute.Operation{Name: "create-op"})
+	})
+	mux.HandleFunc("/projects/project/zones/us-central1-a/disks/"+diskName+"/startAsyncReplication", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&replication))
+		writeJSON(t, w, &compute.Operation{Name: "start-op"})
+	})
+	mux.HandleFunc("/projects/project/zones/us-central1-a/disks/"+diskName+"/stopAsyncReplication", func(w http.ResponseWriter, r *http.Request) {
+		stopped = true
+		writeJSON(t, w, &compute.Operation{Name: "stop-op"})
+	})
+	doneOp := func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Operation{Status: doneStatus})
+	}
+	mux.HandleFunc("/projects/project/zones/us-east1-a/operations/create-op", doneOp)
+	mux.HandleFunc("/projects/project/zones/us-central1-a/operations/start-op", doneOp)
+	mux.HandleFunc("/projects/project/zones/us-central1-a/operations/stop-op", doneOp)
+
+	s := newTestGCEOps(t, mux)
+	s.inst.zone = "us-central1-a"
+
+	err := s.ConfigureReplication(diskName, "us-west1", map[string]string{ReplicationTargetZoneKey: "us-east1-a"})
+	require.Error(t, err, "target zone outside of the target region should be rejected")
+
+	err = s.ConfigureReplication(diskName, "us-east1", nil)
+	require.NoError(t, err)
+	require.Equal(t, diskName+"-replica", secondary["name"])
+	require.Equal(t, "10", secondary["sizeGb"])
+	require.Equal(t, "projects/project/zones/us-east1-a/diskTypes/pd-balanced", secondary["type"])
+	require.Equal(t, map[string]interface{}{"disk": primaryURL}, secondary["asyncPrimaryDisk"])
+	require.Equal(t, "projects/project/zones/us-east1-a/disks/"+diskName+"-replica", replication["asyncSecondaryDisk"])
+
+	require.NoError(t, s.StopReplication(diskName))
+	require.True(t, stopped)
+}
+
+func TestIsExponentialError(t *testing.T) {
+	rateLimited := func(code int, reason string) error {
+		return &googleapi.Error{
+			Code:   code,
+			Errors: []googleapi.ErrorItem{{Reason: reason}},
+		}
+	}
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+		fatal     bool
+	}{
+		{"nil", nil, false, false},
+		{"not a googleapi error", errors.New("failed"), false, false},
+		{"too many requests", &googleapi.Error{Code: http.StatusTooManyRequests}, true, false},
+		{"internal error", &googleapi.Error{Code: http.StatusInternalServerError}, true, false},
+		{"service unavailable", &googleapi.Error{Code: http.StatusServiceUnavailable}, true, false},
+		{"rate limit exceeded", rateLimited(http.StatusForbidden, "rateLimitExceeded"), true, false},
+		{"user rate limit exceeded", rateLimited(http.StatusForbidden, "userRateLimitExceeded"), true, false},
+		{"forbidden", rateLimited(http.StatusForbidden, "forbidden"), false, true},
+		{"unauthorized", &googleapi.Error{Code: http.StatusUnauthorized}, false, true},
+		{"not found", rateLimited(http.StatusNotFound, "notFound"), false, false},
+		{"bad request", &googleapi.Error{Code: http.StatusBadRequest}, false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.retryable, isExponentialError(test.err))
+			if test.err != nil {
+				require.Equal(t, test.fatal, isFatalError(test.err))
+			}
+		})
+	}
+}
+
+func TestSnapshotWithoutWait(t *testing.T) {
+	const diskName = "disk1"
+
+	polled := false
+	gets := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/zones/zone/disks/"+diskName, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Disk{Name: diskName})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/disks/"+diskName+"/createSnapshot", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Operation{Name: "snapshot-op", Status: "RUNNING"})
+	})
+	mux.HandleFunc("/projects/project/global/snapshots/", func(w http.ResponseWriter, r *http.Request) {
+		// the snapshot only shows up once the insert operation has started
+		gets++
+		if gets == 1 {
+			http.Error(w, `{"error": {"code": 404, "message": "notFound"}}`, http.StatusNotFound)
+			return
+		}
+		writeJSON(t, w, &compute.Snapshot{
+			Name:   strings.TrimPrefix(r.URL.Path, "/projects/project/global/snapshots/"),
+			Status: "CREATING",
+		})
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		// The operation must not be polled when not waiting
+		polled = true
+		writeJSON(t, w, &compute.Operation{Name: "snapshot-op", Status: "RUNNING"})
+	})
+
+	s := newTestGCEOps(t, mux)
+	s.opsTimeout = cloudops.OpsTimeoutConfig{
+		Timeout:       5 * time.Second,
+		RetryInterval: 10 * time.Millisecond,
+	}
+	_, err := s.Snapshot(diskName, false, map[string]string{cloudops.SnapshotWaitOption: "maybe"})
+	require.Error(t, err)
+
+	snap, err := s.Snapshot(diskName, false, map[string]string{cloudops.SnapshotWaitOption: "false"})
+	require.NoError(t, err)
+	require.False(t, polled)
+	require.Equal(t, "CREATING", snap.(*compute.Snapshot).Status)
+
+	id, err := s.GetDeviceID(snap)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(id, "snap-"))
+}
+
+func TestSnapshotWithOptions(t *testing.T) {
+	const diskName = "disk1"
+
+	var requested []*compute.Snapshot
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/zones/zone/disks/"+diskName, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.Disk{
+			Name:   diskName,
+			Labels: map[string]string{"pvc": "data", "schedule": "weekly"},
+		})
+	})
+	mux.HandleFunc("/projects/project/zones/zone/disks/"+diskName+"/createSnapshot", func(w http.ResponseWriter, r *http.Request) {
+		snap := &compute.Snapshot{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(snap))
+		requested = append(requested, snap)
+		writeJSON(t, w, &compute.Operation{Name: "snapshot-op", Status: "RUNNING"})
+	})
+	mux.HandleFunc("/projects/project/global/snapshots/", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, requested[len(requested)-1])
+	})
+
+	s := newTestGCEOps(t, mux)
+	noWait := map[string]string{cloudops.SnapshotWaitOption: "false"}
+	_, err := s.SnapshotWithOptions(diskName, false, cloudops.SnapshotOptions{
+		Name:    "nightly",
+		Labels:  map[string]string{"Schedule": "Daily"},
+		Options: noWait,
+	})
+	require.NoError(t, err)
+	require.Len(t, requested, 1)
+	require.Equal(t, "nightly", requested[0].Name)
+	// the labels of the disk are copied and overridden by the given ones
+	require.Equal(t, map[string]string{"pvc": "data", "schedule": "daily"}, requested[0].Labels)
+
+	// snapshots taken without a name in quick succession must not collide
+	_, err = s.Snapshot(diskName, false, noWait)
+	require.NoError(t, err)
+	_, err = s.Snapshot(diskName, false, noWait)
+	require.NoError(t, err)
+	require.Len(t, requested, 3)
+	require.True(t, strings.HasPrefix(requested[1].Name, "snap-"))
+	require.NotEqual(t, requested[1].Name, requested[2].Name)
+	require.LessOrEqual(t, len(requested[1].Name), 63)
+}
+
+func TestInspectSnapshot(t *testing.T) {
+	snaps := []*compute.Snapshot{
+		{
+			Name:              "snap1",
+			SourceDisk:        "https://www.googleapis.com/compute/v1/projects/project/zones/zone/disks/disk1",
+			DiskSizeGb:        64,
+			Status:            StatusReady,
+			Labels:            map[string]string{"app": "db"},
+			CreationTimestamp: "2023-01-02T03:04:05-00:00",
+		},
+	}
+
+	var filter string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/global/snapshots", func(w http.ResponseWriter, r *http.Request) {
+		filter = r.URL.Query().Get("filter")
+		writeJSON(t, w, &compute.SnapshotList{Items: snaps})
+	})
+	mux.HandleFunc("/projects/project/global/snapshots/snap1", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, snaps[0])
+	})
+	mux.HandleFunc("/projects/project/global/snapshots/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	s := newTestGCEOps(t, mux)
+	list, err := s.EnumerateSnapshots(nil, map[string]string{"App": "DB"})
+	require.NoError(t, err)
+	require.Equal(t, "(labels.app eq db)", filter)
+	require.Len(t, list, 1)
+	require.Equal(t, "snap1", list[0].ID)
+	require.Equal(t, "disk1", list[0].VolumeID)
+	require.Equal(t, uint64(64), list[0].SizeInGiB)
+	require.Equal(t, StatusReady, list[0].State)
+	require.Equal(t, map[string]string{"app": "db"}, list[0].Labels)
+
+	snap, err := s.InspectSnapshot("snap1")
+	require.NoError(t, err)
+	require.Equal(t, list[0], snap)
+
+	_, err = s.InspectSnapshot("missing")
+	require.Error(t, err)
+	storageErr, ok := err.(*cloudops.StorageError)
+	require.True(t, ok)
+	require.Equal(t, cloudops.ErrVolNotFound, storageErr.Code)
+}
+
+func TestEnumerateSnapshots(t *testing.T) {
+	snaps := []*compute.Snapshot{
+		{
+			Name:              "snap1",
+			SourceDisk:        "https://www.googleapis.com/compute/v1/projects/project/zones/zone/disks/disk1",
+			Labels:            map[string]string{"app": "db"},
+			CreationTimestamp: "2023-01-02T03:04:05-00:00",
+		},
+		{
+			Name:              "snap2",
+			SourceDisk:        "https://www.googleapis.com/compute/v1/projects/project/zones/zone/disks/disk2",
+			Labels:            map[string]string{"app": "db"},
+			CreationTimestamp: "2023-01-02T03:04:05-00:00",
+		},
+	}
+
+	var filter string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/global/snapshots", func(w http.ResponseWriter, r *http.Request) {
+		filter = r.URL.Query().Get("filter")
+		writeJSON(t, w, &compute.SnapshotList{Items: snaps})
+	})
+
+	s := newTestGCEOps(t, mux)
+	list, err := s.EnumerateSnapshots(nil, map[string]string{"app": "db"})
+	require.NoError(t, err)
+	require.Equal(t, "(labels.app eq db)", filter)
+	require.Len(t, list, 2)
+
+	disk2 := "disk2"
+	list, err = s.EnumerateSnapshots([]*string{&disk2}, nil)
+	require.NoError(t, err)
+	require.Empty(t, filter)
+	require.Len(t, list, 1)
+	require.Equal(t, "snap2", list[0].ID)
+}
+
+func TestDeleteSnapshotChain(t *testing.T) {
+	const diskURL = "https://www.googleapis.com/compute/v1/projects/project/zones/zone/disks/disk1"
+	snaps := map[string]*compute.Snapshot{
+		"base": {Name: "base", SourceDisk: diskURL, CreationTimestamp: "2023-03-01T10:00:00.000-08:00"},
+		"incr": {Name: "incr", SourceDisk: diskURL, CreationTimestamp: "2023-03-02T10:00:00.000-08:00"},
+	}
+
+	var deleted []string
+	failDelete := ""
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/global/snapshots/", func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/projects/project/global/snapshots/")
+		if r.Method != http.MethodDelete {
+			writeJSON(t, w, snaps[name])
+			return
+		}
+		if name == failDelete {
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(t, w, map[string]interface{}{"error": map[string]interface{}{"code": 400, "message": "failed"}})
+			return
+		}
+		deleted = append(deleted, name)
+		writeJSON(t, w, &compute.Operation{Name: "delete-op", Status: doneStatus})
+	})
+	s := newTestGCEOps(t, mux)
+
+	// The incremental snapshot is deleted before its base
+	require.NoError(t, s.DeleteSnapshotChain([]string{"base", "incr"}))
+	require.Equal(t, []string{"incr", "base"}, deleted)
+
+	// The base is kept if the incremental snapshot fails to delete
+	deleted = nil
+	failDelete = "incr"
+	err := s.DeleteSnapshotChain([]string{"base", "incr"})
+	chainErr, ok := err.(*cloudops.ErrSnapshotChainDelete)
+	require.True(t, ok, "expected ErrSnapshotChainDelete, got %v", err)
+	require.Len(t, chainErr.Errors, 2)
+	require.Empty(t, deleted)
+}
+
+func TestWaitForVolumeState(t *testing.T) {
+	disks := map[string][]*compute.Disk{
+		"disk1": {
+			{Name: "disk1", Status: "CREATING"},
+			{Name: "disk1", Status: "READY"},
+			{Name: "disk1", Status: "READY", Users: []string{"instances/instance"}},
+		},
+		"failed": {
+			{Name: "failed", Status: "CREATING"},
+			{Name: "failed", Status: "FAILED"},
+		},
+	}
+	gets := map[string]int{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/zones/zone/disks/", func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Path[len("/projects/project/zones/zone/disks/"):]
+		states, ok := disks[name]
+		if !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": {"code": 404, "message": "not found"}}`))
+			return
+		}
+		i := gets[name]
+		if i >= len(states) {
+			i = len(states) - 1
+		}
+		gets[name]++
+		writeJSON(t, w, states[i])
+	})
+	s := newTestGCEOps(t, mux)
+	s.opsTimeout = cloudops.OpsTimeoutConfig{RetryInterval: time.Millisecond}
+
+	require.NoError(t, s.WaitForVolumeState("disk1", cloudops.VolumeStateAvailable, time.Second))
+	require.Equal(t, 2, gets["disk1"])
+	require.NoError(t, s.WaitForVolumeState("disk1", cloudops.VolumeStateAttached, time.Second))
+
+	err := s.WaitForVolumeState("failed", cloudops.VolumeStateAvailable, time.Second)
+	require.Error(t, err)
+	require.Equal(t, 2, gets["failed"])
+
+	err = s.WaitForVolumeState("missing", cloudops.VolumeStateAvailable, time.Second)
+	se, ok := err.(*cloudops.StorageError)
+	require.True(t, ok, "expected a storage error, got %v", err)
+	require.Equal(t, cloudops.ErrVolNotFound, se.Code)
+}
+
+func TestOpsTimeoutConfig(t *testing.T) {
+	var polls int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/zones/zone/disks/disk1", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&polls, 1)
+		writeJSON(t, w, &compute.Disk{Name: "disk1", Status: "CREATING"})
+	})
+	s := newTestGCEOps(t, mux)
+	s.opsTimeout = cloudops.OpsTimeoutConfig{
+		Timeout:       100 * time.Millisecond,
+		RetryInterval: 10 * time.Millisecond,
+	}
+
+	start := time.Now()
+	err := s.checkDiskStatus("disk1", testZone, StatusReady)
+	require.Error(t, err)
+	require.Less(t, int64(time.Since(start)), int64(cloudops.ProviderOpsTimeout),
+		"the configured timeout should be used instead of the default")
+	require.Greater(t, atomic.LoadInt32(&polls), int32(1))
+}
+
+func TestEmptyVolumeID(t *testing.T) {
+	ops := newTestGCEOps(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+
+	test.RunEmptyVolumeIDTest(t, ops)
+}
+
+func TestReportCapacityByLabel(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/aggregated/disks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.DiskAggregatedList{
+			Items: map[string]compute.DisksScopedList{
+				"zones/zone-a": {
+					Disks: []*compute.Disk{
+						{Name: "disk1", SizeGb: 100, Labels: map[string]string{"team": "storage"}},
+						{Name: "disk2", SizeGb: 50, Labels: map[string]string{"team": "compute"}},
+						{Name: "disk3", SizeGb: 10},
+					},
+				},
+				"zones/zone-b": {
+					Disks: []*compute.Disk{
+						{Name: "disk4", SizeGb: 200, Labels: map[string]string{"team": "storage"}},
+						{Name: "disk5", SizeGb: 20, Labels: map[string]string{"app": "db"}},
+					},
+				},
+			},
+		})
+	})
+
+	s := newTestGCEOps(t, mux)
+	capacities, err := s.ReportCapacityByLabel("Team")
+	require.NoError(t, err)
+	require.Equal(t, map[string]uint64{
+		"storage": 300,
+		"compute": 50,
+	}, capacities)
+}
+
+func TestBuildDecisionMatrix(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects/project/aggregated/diskTypes", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &compute.DiskTypeAggregatedList{
+			Items: map[string]compute.DiskTypesScopedList{
+				"zones/us-east1-b": {
+					DiskTypes: []*compute.DiskType{
+						{Name: "pd-standard", ValidDiskSize: "10GB-65536GB"},
+						{Name: "pd-ssd", ValidDiskSize: "10GB-65536GB"},
+						{Name: "hyperdisk-throughput", ValidDiskSize: "2048GB-32768GB"},
+					},
+				},
+				"zones/us-east1-c": {
+					DiskTypes: []*compute.DiskType{
+						{Name: "pd-ssd", ValidDiskSize: "10GB-65536GB"},
+						{Name: "local-ssd", ValidDiskSize: "375GB-375GB",
+							Deprecated: &compute.DeprecationStatus{State: "DEPRECATED"}},
+					},
+				},
+				"zones/us-west1-a": {
+					DiskTypes: []*compute.DiskType{
+						{Name: "pd-balanced", ValidDiskSize: "10GB-65536GB"},
+					},
+				},
+			},
+		})
+	})
+
+	// the disk types are listed by the provider behind any wrappers
+	ops := backoff.NewExponentialBackoffOps(newTestGCEOps(t, mux), isExponentialError, backoff.DefaultExponentialBackoff)
+	dm, err := cloudops.BuildDecisionMatrix(ops, "us-east1")
+	require.NoError(t, err)
+	require.NoError(t, dm.Validate())
+	require.Len(t, dm.Rows, 2)
+
+	require.Equal(t, "pd-ssd", dm.Rows[0].DriveType)
+	require.Equal(t, uint64(10), dm.Rows[0].MinSize)
+	require.Equal(t, uint64(65536), dm.Rows[0].MaxSize)
+	require.Equal(t, uint64(6000), dm.Rows[0].MinIOPS)
+	require.Equal(t, uint64(100000), dm.Rows[0].MaxIOPS)
+	require.Equal(t, "us-east1", dm.Rows[0].Region)
+
+	require.Equal(t, "pd-standard", dm.Rows[1].DriveType)
+	require.Equal(t, uint64(7), dm.Rows[1].MinIOPS)
+	require.Equal(t, 0.75, dm.Rows[1].IOPSPerGiB)
+	require.Equal(t, "*", dm.Rows[1].InstanceType)
+	require.Equal(t, uint64(cloudops.DefaultInstanceMaxDrives), dm.Rows[1].InstanceMaxDrives)
+
+	_, err = cloudops.BuildDecisionMatrix(fake.NewOps("instance"), "us-east1")
+	_, ok := err.(*cloudops.ErrNotSupported)
+	require.True(t, ok, "expected a not supported error, got %v", err)
+}
@@ -53,8 +53,9 @@ func NewStorageManager(decisionMatrix cloudops.StorageDecisionMatrix) (cloudops.
 }
 
 func (g *gceStorageManager) GetStorageDistribution(request *cloudops.StorageDistributionRequest) (*cloudops.StorageDistributionResponse, error) {
+	decisionMatrix := storagedistribution.FilterForRequest(g.decisionMatrix, request)
 	response := &cloudops.StorageDistributionResponse{}
-	for _, userRequest := range request.UserStorageSpec {
+	for _, userRequest := range storagedistribution.UserStorageSpecs(request) {
 		// this hack is required because the gce drive type comes as urls:
 		// https://www.googleapis.com/compute/v1/projects/portworx-eng/zones/us-east1-b/diskTypes/pd-standard
 		// or  https://www.googleapis.com/compute/v1/projects/portworx-eng/zones/us-east1-b/diskTypes/pd-ssd
@@ -72,7 +73,7 @@ func (g *gceStorageManager) GetStorageDistribution(request *cloudops.StorageDist
 		// and the storage spec for each of them
 		instStorage, instancePerZone, row, err :=
 			storagedistribution.GetStorageDistributionForPool(
-				g.decisionMatrix,
+				decisionMatrix,
 				userRequest,
 				request.InstancesPerZone,
 				request.ZoneCount,
@@ -91,13 +92,42 @@ func (g *gceStorageManager) GetStorageDistribution(request *cloudops.StorageDist
 				InstancesPerZone: instancePerZone,
 				DriveCount:       instStorage.DriveCount,
 				IOPS:             determineIOPSForPool(instStorage, row),
+				ThinProvisioning: instStorage.ThinProvisioning,
 			},
 		)
+		if request.IncludeDecisionMatrixRows {
+			response.DecisionMatrixRows = append(response.DecisionMatrixRows, row)
+		}
 
 	}
 	return response, nil
 }
 
+func (g *gceStorageManager) GetStorageDistributionWithExplanation(
+	request *cloudops.StorageDistributionRequest,
+) (*cloudops.StorageDistributionResponse, []cloudops.CandidateEvaluation, error) {
+	evaluations, err := storagedistribution.ExplainStorageDistribution(g.decisionMatrix, withDriveTypeNames(request))
+	if err != nil {
+		return nil, evaluations, err
+	}
+	response, err := g.GetStorageDistribution(request)
+	return response, evaluations, err
+}
+
+// withDriveTypeNames returns a copy of the request whose user storage specs
+// name their drive types by the last part of the drive type url
+func withDriveTypeNames(request *cloudops.StorageDistributionRequest) *cloudops.StorageDistributionRequest {
+	named := *request
+	named.UserStorageSpec = make([]*cloudops.StorageSpec, 0, len(request.UserStorageSpec))
+	for _, spec := range request.UserStorageSpec {
+		specCopy := *spec
+		split := strings.Split(spec.DriveType, "/")
+		specCopy.DriveType = split[len(split)-1]
+		named.UserStorageSpec = append(named.UserStorageSpec, &specCopy)
+	}
+	return &named
+}
+
 func (g *gceStorageManager) RecommendStoragePoolUpdate(request *cloudops.StoragePoolUpdateRequest) (*cloudops.StoragePoolUpdateResponse, error) {
 	// this hack is required because the gce drive type comes as urls:
 	// https://www.googleapis.com/compute/v1/projects/portworx-eng/zones/us-east1-b/diskTypes/pd-standard
@@ -137,7 +167,21 @@ func (g *gceStorageManager) GetMaxDriveSize(request *cloudops.MaxDriveSizeReques
 	return resp, err
 }
 
+func (g *gceStorageManager) ValidateStorageSpec(spec *cloudops.StorageSpec) error {
+	// the gce drive type comes as urls, use the last part like GetStorageDistribution
+	if spec != nil && spec.DriveType != "" {
+		specCopy := *spec
+		split := strings.Split(spec.DriveType, "/")
+		specCopy.DriveType = split[len(split)-1]
+		spec = &specCopy
+	}
+	return storagedistribution.ValidateStorageSpec(spec, g.decisionMatrix)
+}
+
 func determineIOPSForPool(instStorage *cloudops.StoragePoolSpec, row *cloudops.StorageDecisionMatrixRow) uint64 {
+	if iops, ok := storagedistribution.DetermineIOPSForPool(instStorage, row); ok {
+		return iops
+	}
 	iops := uint64(0)
 	maxIops := uint64(0)
 	if instStorage.DriveType == GCEDriveTypeStandard {
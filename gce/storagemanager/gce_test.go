@@ -898,3 +898,17 @@ func genDriveType(dType string) string {
 	// or  https://www.googleapis.com/compute/v1/projects/portworx-eng/zones/us-east1-b/diskTypes/pd-ssd
 	return fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/portworx-eng/zones/us-east1-b/diskTypes/%s", dType)
 }
+
+func TestDetermineIOPSForPoolFromDecisionMatrix(t *testing.T) {
+	instStorage := &cloudops.StoragePoolSpec{
+		DriveType:        GCEDriveTypeBalanced,
+		DriveCapacityGiB: 100,
+	}
+	row := &cloudops.StorageDecisionMatrixRow{DriveType: GCEDriveTypeBalanced}
+
+	// Falls back to the built-in multiplier
+	require.Equal(t, uint64(100*GCEBalancedIopsMultiplier), determineIOPSForPool(instStorage, row))
+
+	row.IOPSPerGiB = 10
+	require.Equal(t, uint64(1000), determineIOPSForPool(instStorage, row))
+}
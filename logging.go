@@ -0,0 +1,73 @@
+package cloudops
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// Structured fields set on the log lines of the cloud providers
+const (
+	// LogFieldProvider is the name of the cloud provider
+	LogFieldProvider = "provider"
+	// LogFieldMethod is the cloudops method which logged the line
+	LogFieldMethod = "method"
+	// LogFieldVolumeID is the volume the method was called for
+	LogFieldVolumeID = "volume_id"
+	// LogFieldRequestID is the caller supplied ID correlating all the calls
+	// belonging to a single request
+	LogFieldRequestID = "request_id"
+)
+
+// LoggerSetter is implemented by the cloud providers which log through a
+// caller supplied logger
+type LoggerSetter interface {
+	// SetLogger sets the logger used for all the log lines of the provider.
+	// The fields of the logger, such as a request ID, are included in each
+	// line. It must be called before the provider is used.
+	SetLogger(logger *logrus.Entry)
+}
+
+// unwrapper is implemented by the Ops wrappers, such as the exponential
+// backoff wrapper, to expose the Ops they wrap
+type unwrapper interface {
+	Unwrap() Ops
+}
+
+// NewRequestLogger returns a logger of the global logrus logger whose lines
+// carry the given request ID
+func NewRequestLogger(requestID string) *logrus.Entry {
+	return logrus.WithField(LogFieldRequestID, requestID)
+}
+
+// SetLogger sets the logger of the cloud provider of ops, looking through any
+// wrappers. It returns false if the provider does not support a logger, in
+// which case it keeps logging through the global logrus logger.
+func SetLogger(ops Ops, logger *logrus.Entry) bool {
+	for {
+		if s, ok := ops.(LoggerSetter); ok {
+			s.SetLogger(logger)
+			return true
+		}
+		w, ok := ops.(unwrapper)
+		if !ok {
+			return false
+		}
+		ops = w.Unwrap()
+	}
+}
+
+// ProviderLogger returns the logger for a line logged by the given method of
+// the provider. The global logrus logger is used if logger is nil. The volume
+// ID field is omitted if volumeID is empty.
+func ProviderLogger(logger *logrus.Entry, provider, method, volumeID string) *logrus.Entry {
+	if logger == nil {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	fields := logrus.Fields{
+		LogFieldProvider: provider,
+		LogFieldMethod:   method,
+	}
+	if len(volumeID) > 0 {
+		fields[LogFieldVolumeID] = volumeID
+	}
+	return logger.WithFields(fields)
+}
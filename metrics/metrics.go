@@ -0,0 +1,572 @@
+// Package metrics instruments the cloud provider API calls made through
+// cloudops.Ops with prometheus metrics.
+package metrics
+
+import (
+	"time"
+
+	"github.com/libopenstorage/cloudops"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	namespace = "cloudops"
+	subsystem = "api"
+)
+
+// wrapper is implemented by the cloudops.Ops wrappers, such as the exponential
+// backoff wrapper, to expose the cloudops.Ops they wrap
+type wrapper interface {
+	Unwrap() cloudops.Ops
+}
+
+type instrumentedOps struct {
+	ops      cloudops.Ops
+	provider string
+	calls    *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+}
+
+// NewInstrumentedOps returns a wrapper for the given cloudops.Ops which records
+// the number of calls, the number of failed calls and the latency of each
+// method into collectors registered with reg. The metrics are labeled with the
+// name of the cloud provider and the method. Ops wrapped with the same
+// registry share the collectors.
+func NewInstrumentedOps(ops cloudops.Ops, reg prometheus.Registerer) cloudops.Ops {
+	labels := []string{"provider", "method"}
+	return &instrumentedOps{
+		ops:      ops,
+		provider: providerName(ops),
+		calls: register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "calls_total",
+			Help:      "Number of cloud provider API calls.",
+		}, labels)).(*prometheus.CounterVec),
+		errors: register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "errors_total",
+			Help:      "Number of cloud provider API calls which returned an error.",
+		}, labels)).(*prometheus.CounterVec),
+		latency: register(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "call_duration_seconds",
+			Help:      "Latency of cloud provider API calls.",
+			Buckets:   prometheus.DefBuckets,
+		}, labels)).(*prometheus.HistogramVec),
+	}
+}
+
+// register registers the given collector with reg and returns it, or returns
+// the identical collector registered before
+func register(reg prometheus.Registerer, c prometheus.Collector) prometheus.Collector {
+	if err := reg.Register(c); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector
+		}
+		logrus.Warnf("failed to register cloudops metrics: %v", err)
+	}
+	return c
+}
+
+// providerName returns the name of the cloud provider of the given
+// cloudops.Ops, looking through any wrappers
+func providerName(ops cloudops.Ops) string {
+	for {
+		w, ok := ops.(wrapper)
+		if !ok {
+			return ops.Name()
+		}
+		ops = w.Unwrap()
+	}
+}
+
+// observe records a call to the given method which started at start and
+// returned err
+func (i *instrumentedOps) observe(method string, start time.Time, err error) {
+	i.latency.WithLabelValues(i.provider, method).Observe(time.Since(start).Seconds())
+	i.calls.WithLabelValues(i.provider, method).Inc()
+	if err != nil {
+		i.errors.WithLabelValues(i.provider, method).Inc()
+	}
+}
+
+// Unwrap returns the instrumented cloudops.Ops
+func (i *instrumentedOps) Unwrap() cloudops.Ops {
+	return i.ops
+}
+
+func (i *instrumentedOps) Name() string {
+	return i.ops.Name()
+}
+
+func (i *instrumentedOps) Create(template interface{}, labels map[string]string, options map[string]string) (interface{}, error) {
+	start := time.Now()
+	r0, err := i.ops.Create(template, labels, options)
+	i.observe("Create", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) CloneVolume(sourceVolumeID string, template interface{}, labels map[string]string) (interface{}, error) {
+	start := time.Now()
+	r0, err := i.ops.CloneVolume(sourceVolumeID, template, labels)
+	i.observe("CloneVolume", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) GetDeviceID(template interface{}) (string, error) {
+	return i.ops.GetDeviceID(template)
+}
+
+func (i *instrumentedOps) Attach(volumeID string, options map[string]string) (string, error) {
+	start := time.Now()
+	r0, err := i.ops.Attach(volumeID, options)
+	i.observe("Attach", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) AttachByInstanceID(instanceID, volumeID string, options map[string]string) (string, error) {
+	start := time.Now()
+	r0, err := i.ops.AttachByInstanceID(instanceID, volumeID, options)
+	i.observe("AttachByInstanceID", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) DeleteVolumes(volumeIDs []string) (map[string]error, error) {
+	start := time.Now()
+	r0, err := i.ops.DeleteVolumes(volumeIDs)
+	i.observe("DeleteVolumes", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) ListSnapshots(labels map[string]string) ([]*cloudops.SnapshotInfo, error) {
+	return i.EnumerateSnapshots(nil, labels)
+}
+
+func (i *instrumentedOps) InspectSnapshot(snapID string) (*cloudops.SnapshotInfo, error) {
+	start := time.Now()
+	r0, err := i.ops.InspectSnapshot(snapID)
+	i.observe("InspectSnapshot", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) ModifyVolumeType(volumeID, newType string, iops, throughput *int64) error {
+	start := time.Now()
+	err := i.ops.ModifyVolumeType(volumeID, newType, iops, throughput)
+	i.observe("ModifyVolumeType", start, err)
+	return err
+}
+
+func (i *instrumentedOps) WaitForVolumeState(volumeID string, desiredState string, timeout time.Duration) error {
+	start := time.Now()
+	err := i.ops.WaitForVolumeState(volumeID, desiredState, timeout)
+	i.observe("WaitForVolumeState", start, err)
+	return err
+}
+
+func (i *instrumentedOps) AreVolumesReadyToExpand(volumeIDs []*string) (bool, error) {
+	start := time.Now()
+	r0, err := i.ops.AreVolumesReadyToExpand(volumeIDs)
+	i.observe("AreVolumesReadyToExpand", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) Expand(volumeID string, newSizeInGiB uint64, options map[string]string) (uint64, error) {
+	start := time.Now()
+	r0, err := i.ops.Expand(volumeID, newSizeInGiB, options)
+	i.observe("Expand", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) Detach(volumeID string, options map[string]string) error {
+	start := time.Now()
+	err := i.ops.Detach(volumeID, options)
+	i.observe("Detach", start, err)
+	return err
+}
+
+func (i *instrumentedOps) DetachFrom(volumeID string, instanceID string) error {
+	start := time.Now()
+	err := i.ops.DetachFrom(volumeID, instanceID)
+	i.observe("DetachFrom", start, err)
+	return err
+}
+
+func (i *instrumentedOps) Delete(volumeID string, options map[string]string) error {
+	start := time.Now()
+	err := i.ops.Delete(volumeID, options)
+	i.observe("Delete", start, err)
+	return err
+}
+
+func (i *instrumentedOps) DeleteFrom(volumeID string, instanceID string) error {
+	start := time.Now()
+	err := i.ops.DeleteFrom(volumeID, instanceID)
+	i.observe("DeleteFrom", start, err)
+	return err
+}
+
+func (i *instrumentedOps) Describe() (interface{}, error) {
+	start := time.Now()
+	r0, err := i.ops.Describe()
+	i.observe("Describe", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) FreeDevices() ([]string, error) {
+	start := time.Now()
+	r0, err := i.ops.FreeDevices()
+	i.observe("FreeDevices", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) Inspect(volumeIds []*string, options map[string]string) ([]interface{}, error) {
+	start := time.Now()
+	r0, err := i.ops.Inspect(volumeIds, options)
+	i.observe("Inspect", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) DeviceMappings() (map[string]string, error) {
+	start := time.Now()
+	r0, err := i.ops.DeviceMappings()
+	i.observe("DeviceMappings", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) Enumerate(volumeIds []*string, labels map[string]string, setIdentifier string) (map[string][]interface{}, error) {
+	start := time.Now()
+	r0, err := i.ops.Enumerate(volumeIds, labels, setIdentifier)
+	i.observe("Enumerate", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) DevicePath(volumeID string) (string, error) {
+	start := time.Now()
+	r0, err := i.ops.DevicePath(volumeID)
+	i.observe("DevicePath", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) Snapshot(volumeID string, readonly bool, options map[string]string) (interface{}, error) {
+	start := time.Now()
+	r0, err := i.ops.Snapshot(volumeID, readonly, options)
+	i.observe("Snapshot", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) SnapshotWithOptions(volumeID string, readonly bool, opts cloudops.SnapshotOptions) (interface{}, error) {
+	start := time.Now()
+	r0, err := i.ops.SnapshotWithOptions(volumeID, readonly, opts)
+	i.observe("SnapshotWithOptions", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) SnapshotDelete(snapID string, options map[string]string) error {
+	start := time.Now()
+	err := i.ops.SnapshotDelete(snapID, options)
+	i.observe("SnapshotDelete", start, err)
+	return err
+}
+
+func (i *instrumentedOps) ApplyTags(volumeID string, labels map[string]string, options map[string]string) error {
+	start := time.Now()
+	err := i.ops.ApplyTags(volumeID, labels, options)
+	i.observe("ApplyTags", start, err)
+	return err
+}
+
+func (i *instrumentedOps) RemoveTags(volumeID string, labels map[string]string, options map[string]string) error {
+	start := time.Now()
+	err := i.ops.RemoveTags(volumeID, labels, options)
+	i.observe("RemoveTags", start, err)
+	return err
+}
+
+func (i *instrumentedOps) Tags(volumeID string) (map[string]string, error) {
+	start := time.Now()
+	r0, err := i.ops.Tags(volumeID)
+	i.observe("Tags", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) ReconcileDataDisks(instanceID string) ([]string, error) {
+	start := time.Now()
+	r0, err := i.ops.ReconcileDataDisks(instanceID)
+	i.observe("ReconcileDataDisks", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) GetEffectivePerformance(volumeID string) (uint64, uint64, error) {
+	start := time.Now()
+	r0, r1, err := i.ops.GetEffectivePerformance(volumeID)
+	i.observe("GetEffectivePerformance", start, err)
+	return r0, r1, err
+}
+
+func (i *instrumentedOps) ConfigureReplication(volumeID string, targetRegion string, options map[string]string) error {
+	start := time.Now()
+	err := i.ops.ConfigureReplication(volumeID, targetRegion, options)
+	i.observe("ConfigureReplication", start, err)
+	return err
+}
+
+func (i *instrumentedOps) StopReplication(volumeID string) error {
+	start := time.Now()
+	err := i.ops.StopReplication(volumeID)
+	i.observe("StopReplication", start, err)
+	return err
+}
+
+func (i *instrumentedOps) EnumerateSnapshots(volumeIDs []*string, labels map[string]string) ([]*cloudops.SnapshotInfo, error) {
+	start := time.Now()
+	r0, err := i.ops.EnumerateSnapshots(volumeIDs, labels)
+	i.observe("EnumerateSnapshots", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) ReportCapacityByLabel(labelKey string) (map[string]uint64, error) {
+	start := time.Now()
+	r0, err := i.ops.ReportCapacityByLabel(labelKey)
+	i.observe("ReportCapacityByLabel", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) UpdateAttachmentCaching(instanceID string, diskName string, caching string) error {
+	start := time.Now()
+	err := i.ops.UpdateAttachmentCaching(instanceID, diskName, caching)
+	i.observe("UpdateAttachmentCaching", start, err)
+	return err
+}
+
+func (i *instrumentedOps) GetVolumeQoS(volumeID string) (uint64, uint64, error) {
+	start := time.Now()
+	r0, r1, err := i.ops.GetVolumeQoS(volumeID)
+	i.observe("GetVolumeQoS", start, err)
+	return r0, r1, err
+}
+
+func (i *instrumentedOps) ExpandMany(volumeIDs []string, newSizeInGiB uint64, options map[string]string) (map[string]uint64, error) {
+	start := time.Now()
+	r0, err := i.ops.ExpandMany(volumeIDs, newSizeInGiB, options)
+	i.observe("ExpandMany", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) LockVolume(volumeID string, owner string) (bool, error) {
+	start := time.Now()
+	r0, err := i.ops.LockVolume(volumeID, owner)
+	i.observe("LockVolume", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) UnlockVolume(volumeID string, owner string) error {
+	start := time.Now()
+	err := i.ops.UnlockVolume(volumeID, owner)
+	i.observe("UnlockVolume", start, err)
+	return err
+}
+
+func (i *instrumentedOps) GetStorageLayout(instanceID string) ([]cloudops.VolumeSpec, error) {
+	start := time.Now()
+	r0, err := i.ops.GetStorageLayout(instanceID)
+	i.observe("GetStorageLayout", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) ProvisionStorageLayout(specs []cloudops.VolumeSpec, labels map[string]string) ([]interface{}, error) {
+	start := time.Now()
+	r0, err := i.ops.ProvisionStorageLayout(specs, labels)
+	i.observe("ProvisionStorageLayout", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) GetVolumeLineage(volumeID string) (string, string, error) {
+	start := time.Now()
+	r0, r1, err := i.ops.GetVolumeLineage(volumeID)
+	i.observe("GetVolumeLineage", start, err)
+	return r0, r1, err
+}
+
+func (i *instrumentedOps) BatchInspect(volumeIds []*string) (map[string]interface{}, error) {
+	start := time.Now()
+	r0, err := i.ops.BatchInspect(volumeIds)
+	i.observe("BatchInspect", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) DeleteInstance(instanceID string, zone string, timeout time.Duration) error {
+	start := time.Now()
+	err := i.ops.DeleteInstance(instanceID, zone, timeout)
+	i.observe("DeleteInstance", start, err)
+	return err
+}
+
+func (i *instrumentedOps) InstanceID() string {
+	return i.ops.InstanceID()
+}
+
+func (i *instrumentedOps) InspectInstance(instanceID string) (*cloudops.InstanceInfo, error) {
+	start := time.Now()
+	r0, err := i.ops.InspectInstance(instanceID)
+	i.observe("InspectInstance", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) InspectInstanceInZone(instanceID, zone string) (*cloudops.InstanceInfo, error) {
+	start := time.Now()
+	r0, err := i.ops.InspectInstanceInZone(instanceID, zone)
+	i.observe("InspectInstanceInZone", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) InspectInstanceGroupForInstance(instanceID string) (*cloudops.InstanceGroupInfo, error) {
+	start := time.Now()
+	r0, err := i.ops.InspectInstanceGroupForInstance(instanceID)
+	i.observe("InspectInstanceGroupForInstance", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) GetInstance(displayName string) (interface{}, error) {
+	start := time.Now()
+	r0, err := i.ops.GetInstance(displayName)
+	i.observe("GetInstance", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) SetInstanceGroupSize(instanceGroupID string, count int64, timeout time.Duration) error {
+	start := time.Now()
+	err := i.ops.SetInstanceGroupSize(instanceGroupID, count, timeout)
+	i.observe("SetInstanceGroupSize", start, err)
+	return err
+}
+
+func (i *instrumentedOps) GetInstanceGroupSize(instanceGroupID string) (int64, error) {
+	start := time.Now()
+	r0, err := i.ops.GetInstanceGroupSize(instanceGroupID)
+	i.observe("GetInstanceGroupSize", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) GetInstanceGroupZones(instanceGroupID string) ([]string, error) {
+	start := time.Now()
+	r0, err := i.ops.GetInstanceGroupZones(instanceGroupID)
+	i.observe("GetInstanceGroupZones", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) ListInstanceGroupMembers(instanceGroupID string) ([]string, error) {
+	start := time.Now()
+	r0, err := i.ops.ListInstanceGroupMembers(instanceGroupID)
+	i.observe("ListInstanceGroupMembers", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) GetClusterSizeForInstance(instanceID string) (int64, error) {
+	start := time.Now()
+	r0, err := i.ops.GetClusterSizeForInstance(instanceID)
+	i.observe("GetClusterSizeForInstance", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) SetClusterVersion(version string, timeout time.Duration) error {
+	start := time.Now()
+	err := i.ops.SetClusterVersion(version, timeout)
+	i.observe("SetClusterVersion", start, err)
+	return err
+}
+
+func (i *instrumentedOps) GetClusterVersion(instanceID string) (string, string, error) {
+	start := time.Now()
+	r0, r1, err := i.ops.GetClusterVersion(instanceID)
+	i.observe("GetClusterVersion", start, err)
+	return r0, r1, err
+}
+
+func (i *instrumentedOps) SetInstanceGroupVersion(instanceGroupID string, version string, timeout time.Duration) error {
+	start := time.Now()
+	err := i.ops.SetInstanceGroupVersion(instanceGroupID, version, timeout)
+	i.observe("SetInstanceGroupVersion", start, err)
+	return err
+}
+
+func (i *instrumentedOps) SetInstanceUpgradeStrategy(instanceGroupID string, upgradeStrategy string, timeout time.Duration, surgeSetting string) error {
+	start := time.Now()
+	err := i.ops.SetInstanceUpgradeStrategy(instanceGroupID, upgradeStrategy, timeout, surgeSetting)
+	i.observe("SetInstanceUpgradeStrategy", start, err)
+	return err
+}
+
+func (i *instrumentedOps) RollInstanceGroup(instanceGroupID string, opts cloudops.RollOpts) error {
+	start := time.Now()
+	err := i.ops.RollInstanceGroup(instanceGroupID, opts)
+	i.observe("RollInstanceGroup", start, err)
+	return err
+}
+
+func (i *instrumentedOps) SetInstanceGroupNodeLabels(instanceGroupID string, labels map[string]string, timeout time.Duration) error {
+	start := time.Now()
+	err := i.ops.SetInstanceGroupNodeLabels(instanceGroupID, labels, timeout)
+	i.observe("SetInstanceGroupNodeLabels", start, err)
+	return err
+}
+
+func (i *instrumentedOps) SetInstanceGroupNodeTaints(instanceGroupID string, taints []cloudops.NodeTaint, timeout time.Duration) error {
+	start := time.Now()
+	err := i.ops.SetInstanceGroupNodeTaints(instanceGroupID, taints, timeout)
+	i.observe("SetInstanceGroupNodeTaints", start, err)
+	return err
+}
+
+func (i *instrumentedOps) GetNetworkInfo(instanceID string) (*cloudops.NetworkInfo, error) {
+	start := time.Now()
+	r0, err := i.ops.GetNetworkInfo(instanceID)
+	i.observe("GetNetworkInfo", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) GetVMDiskBudget(instanceID string) (uint64, uint64, uint64, error) {
+	start := time.Now()
+	r0, r1, r2, err := i.ops.GetVMDiskBudget(instanceID)
+	i.observe("GetVMDiskBudget", start, err)
+	return r0, r1, r2, err
+}
+
+func (i *instrumentedOps) AdoptVolume(volumeID string, labels map[string]string) error {
+	start := time.Now()
+	err := i.ops.AdoptVolume(volumeID, labels)
+	i.observe("AdoptVolume", start, err)
+	return err
+}
+
+func (i *instrumentedOps) DeleteSnapshotChain(snapIDs []string) error {
+	start := time.Now()
+	err := i.ops.DeleteSnapshotChain(snapIDs)
+	i.observe("DeleteSnapshotChain", start, err)
+	return err
+}
+
+func (i *instrumentedOps) GetStoragePressure(instanceID string) ([]cloudops.VolumePressureInfo, error) {
+	start := time.Now()
+	r0, err := i.ops.GetStoragePressure(instanceID)
+	i.observe("GetStoragePressure", start, err)
+	return r0, err
+}
+
+func (i *instrumentedOps) CheckVolumeQuota(required []*cloudops.StoragePoolSpec) error {
+	start := time.Now()
+	err := i.ops.CheckVolumeQuota(required)
+	i.observe("CheckVolumeQuota", start, err)
+	return err
+}
+
+func (i *instrumentedOps) ListVolumesPaged(labels map[string]string, pageSize int) (cloudops.VolumeIterator, error) {
+	start := time.Now()
+	r0, err := i.ops.ListVolumesPaged(labels, pageSize)
+	i.observe("ListVolumesPaged", start, err)
+	return r0, err
+}
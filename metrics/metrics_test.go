@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/libopenstorage/cloudops"
+	"github.com/libopenstorage/cloudops/backoff"
+	"github.com/libopenstorage/cloudops/mock"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+// gather returns the metrics of the given family in reg keyed by method
+func gather(t *testing.T, reg *prometheus.Registry, name string) map[string]*dto.Metric {
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	metrics := make(map[string]*dto.Metric)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			require.Equal(t, string(cloudops.AWS), labels["provider"])
+			metrics[labels["method"]] = m
+		}
+	}
+	return metrics
+}
+
+func TestInstrumentedOps(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ops := mock.NewMockOps(ctrl)
+	ops.EXPECT().Name().Return(string(cloudops.AWS)).AnyTimes()
+	ops.EXPECT().Attach("vol-1", nil).Return("/dev/xvdf", nil).Times(2)
+	ops.EXPECT().Detach("vol-1", nil).Return(fmt.Errorf("detach failed"))
+
+	reg := prometheus.NewRegistry()
+	// the provider is named after the wrapped ops rather than the backoff wrapper
+	instrumented := NewInstrumentedOps(
+		backoff.NewExponentialBackoffOps(ops, func(error) bool { return false }, backoff.DefaultExponentialBackoff),
+		reg,
+	)
+
+	for n := 0; n < 2; n++ {
+		devicePath, err := instrumented.Attach("vol-1", nil)
+		require.NoError(t, err)
+		require.Equal(t, "/dev/xvdf", devicePath)
+	}
+	require.Error(t, instrumented.Detach("vol-1", nil))
+
+	latency := gather(t, reg, "cloudops_api_call_duration_seconds")
+	require.Len(t, latency, 2)
+	require.Equal(t, uint64(2), latency["Attach"].GetHistogram().GetSampleCount())
+	require.Equal(t, uint64(1), latency["Detach"].GetHistogram().GetSampleCount())
+
+	calls := gather(t, reg, "cloudops_api_calls_total")
+	require.Equal(t, float64(2), calls["Attach"].GetCounter().GetValue())
+	require.Equal(t, float64(1), calls["Detach"].GetCounter().GetValue())
+
+	errors := gather(t, reg, "cloudops_api_errors_total")
+	require.Len(t, errors, 1)
+	require.Equal(t, float64(1), errors["Detach"].GetCounter().GetValue())
+
+	// wrapping again with the same registry shares the collectors
+	ops.EXPECT().Delete("vol-1", nil).Return(nil)
+	require.NoError(t, NewInstrumentedOps(ops, reg).Delete("vol-1", nil))
+	latency = gather(t, reg, "cloudops_api_call_duration_seconds")
+	require.Equal(t, uint64(1), latency["Delete"].GetHistogram().GetSampleCount())
+}
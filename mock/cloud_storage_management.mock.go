@@ -63,6 +63,22 @@ func (mr *MockStorageManagerMockRecorder) GetStorageDistribution(arg0 interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStorageDistribution", reflect.TypeOf((*MockStorageManager)(nil).GetStorageDistribution), arg0)
 }
 
+// GetStorageDistributionWithExplanation mocks base method
+func (m *MockStorageManager) GetStorageDistributionWithExplanation(arg0 *cloudops.StorageDistributionRequest) (*cloudops.StorageDistributionResponse, []cloudops.CandidateEvaluation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStorageDistributionWithExplanation", arg0)
+	ret0, _ := ret[0].(*cloudops.StorageDistributionResponse)
+	ret1, _ := ret[1].([]cloudops.CandidateEvaluation)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetStorageDistributionWithExplanation indicates an expected call of GetStorageDistributionWithExplanation
+func (mr *MockStorageManagerMockRecorder) GetStorageDistributionWithExplanation(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStorageDistributionWithExplanation", reflect.TypeOf((*MockStorageManager)(nil).GetStorageDistributionWithExplanation), arg0)
+}
+
 // RecommendStoragePoolUpdate mocks base method
 func (m *MockStorageManager) RecommendStoragePoolUpdate(arg0 *cloudops.StoragePoolUpdateRequest) (*cloudops.StoragePoolUpdateResponse, error) {
 	m.ctrl.T.Helper()
@@ -77,3 +93,17 @@ func (mr *MockStorageManagerMockRecorder) RecommendStoragePoolUpdate(arg0 interf
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecommendStoragePoolUpdate", reflect.TypeOf((*MockStorageManager)(nil).RecommendStoragePoolUpdate), arg0)
 }
+
+// ValidateStorageSpec mocks base method
+func (m *MockStorageManager) ValidateStorageSpec(arg0 *cloudops.StorageSpec) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ValidateStorageSpec", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ValidateStorageSpec indicates an expected call of ValidateStorageSpec
+func (mr *MockStorageManagerMockRecorder) ValidateStorageSpec(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ValidateStorageSpec", reflect.TypeOf((*MockStorageManager)(nil).ValidateStorageSpec), arg0)
+}
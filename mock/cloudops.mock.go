@@ -34,6 +34,20 @@ func (m *MockOps) EXPECT() *MockOpsMockRecorder {
 	return m.recorder
 }
 
+// AdoptVolume mocks base method
+func (m *MockOps) AdoptVolume(arg0 string, arg1 map[string]string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdoptVolume", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AdoptVolume indicates an expected call of AdoptVolume
+func (mr *MockOpsMockRecorder) AdoptVolume(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdoptVolume", reflect.TypeOf((*MockOps)(nil).AdoptVolume), arg0, arg1)
+}
+
 // ApplyTags mocks base method
 func (m *MockOps) ApplyTags(arg0 string, arg1, arg2 map[string]string) error {
 	m.ctrl.T.Helper()
@@ -78,6 +92,79 @@ func (mr *MockOpsMockRecorder) Attach(arg0, arg1 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Attach", reflect.TypeOf((*MockOps)(nil).Attach), arg0, arg1)
 }
 
+// AttachByInstanceID mocks base method
+func (m *MockOps) AttachByInstanceID(arg0, arg1 string, arg2 map[string]string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AttachByInstanceID", arg0, arg1, arg2)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AttachByInstanceID indicates an expected call of AttachByInstanceID
+func (mr *MockOpsMockRecorder) AttachByInstanceID(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AttachByInstanceID", reflect.TypeOf((*MockOps)(nil).AttachByInstanceID), arg0, arg1, arg2)
+}
+
+// BatchInspect mocks base method
+func (m *MockOps) BatchInspect(arg0 []*string) (map[string]interface{}, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BatchInspect", arg0)
+	ret0, _ := ret[0].(map[string]interface{})
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BatchInspect indicates an expected call of BatchInspect
+func (mr *MockOpsMockRecorder) BatchInspect(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchInspect", reflect.TypeOf((*MockOps)(nil).BatchInspect), arg0)
+}
+
+// CheckVolumeQuota mocks base method
+func (m *MockOps) CheckVolumeQuota(arg0 []*cloudops.StoragePoolSpec) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckVolumeQuota", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckVolumeQuota indicates an expected call of CheckVolumeQuota
+func (mr *MockOpsMockRecorder) CheckVolumeQuota(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckVolumeQuota", reflect.TypeOf((*MockOps)(nil).CheckVolumeQuota), arg0)
+}
+
+// CloneVolume mocks base method
+func (m *MockOps) CloneVolume(arg0 string, arg1 interface{}, arg2 map[string]string) (interface{}, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CloneVolume", arg0, arg1, arg2)
+	ret0, _ := ret[0].(interface{})
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CloneVolume indicates an expected call of CloneVolume
+func (mr *MockOpsMockRecorder) CloneVolume(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CloneVolume", reflect.TypeOf((*MockOps)(nil).CloneVolume), arg0, arg1, arg2)
+}
+
+// ConfigureReplication mocks base method
+func (m *MockOps) ConfigureReplication(arg0, arg1 string, arg2 map[string]string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfigureReplication", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ConfigureReplication indicates an expected call of ConfigureReplication
+func (mr *MockOpsMockRecorder) ConfigureReplication(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfigureReplication", reflect.TypeOf((*MockOps)(nil).ConfigureReplication), arg0, arg1, arg2)
+}
+
 // Create mocks base method
 func (m *MockOps) Create(arg0 interface{}, arg1, arg2 map[string]string) (interface{}, error) {
 	m.ctrl.T.Helper()
@@ -135,6 +222,35 @@ func (mr *MockOpsMockRecorder) DeleteInstance(arg0, arg1, arg2 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteInstance", reflect.TypeOf((*MockOps)(nil).DeleteInstance), arg0, arg1, arg2)
 }
 
+// DeleteSnapshotChain mocks base method
+func (m *MockOps) DeleteSnapshotChain(arg0 []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSnapshotChain", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteSnapshotChain indicates an expected call of DeleteSnapshotChain
+func (mr *MockOpsMockRecorder) DeleteSnapshotChain(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSnapshotChain", reflect.TypeOf((*MockOps)(nil).DeleteSnapshotChain), arg0)
+}
+
+// DeleteVolumes mocks base method
+func (m *MockOps) DeleteVolumes(arg0 []string) (map[string]error, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteVolumes", arg0)
+	ret0, _ := ret[0].(map[string]error)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteVolumes indicates an expected call of DeleteVolumes
+func (mr *MockOpsMockRecorder) DeleteVolumes(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteVolumes", reflect.TypeOf((*MockOps)(nil).DeleteVolumes), arg0)
+}
+
 // Describe mocks base method
 func (m *MockOps) Describe() (interface{}, error) {
 	m.ctrl.T.Helper()
@@ -223,6 +339,21 @@ func (mr *MockOpsMockRecorder) Enumerate(arg0, arg1, arg2 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Enumerate", reflect.TypeOf((*MockOps)(nil).Enumerate), arg0, arg1, arg2)
 }
 
+// EnumerateSnapshots mocks base method
+func (m *MockOps) EnumerateSnapshots(arg0 []*string, arg1 map[string]string) ([]*cloudops.SnapshotInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnumerateSnapshots", arg0, arg1)
+	ret0, _ := ret[0].([]*cloudops.SnapshotInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnumerateSnapshots indicates an expected call of EnumerateSnapshots
+func (mr *MockOpsMockRecorder) EnumerateSnapshots(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnumerateSnapshots", reflect.TypeOf((*MockOps)(nil).EnumerateSnapshots), arg0, arg1)
+}
+
 // Expand mocks base method
 func (m *MockOps) Expand(arg0 string, arg1 uint64, arg2 map[string]string) (uint64, error) {
 	m.ctrl.T.Helper()
@@ -238,6 +369,21 @@ func (mr *MockOpsMockRecorder) Expand(arg0, arg1, arg2 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Expand", reflect.TypeOf((*MockOps)(nil).Expand), arg0, arg1, arg2)
 }
 
+// ExpandMany mocks base method
+func (m *MockOps) ExpandMany(arg0 []string, arg1 uint64, arg2 map[string]string) (map[string]uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpandMany", arg0, arg1, arg2)
+	ret0, _ := ret[0].(map[string]uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpandMany indicates an expected call of ExpandMany
+func (mr *MockOpsMockRecorder) ExpandMany(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpandMany", reflect.TypeOf((*MockOps)(nil).ExpandMany), arg0, arg1, arg2)
+}
+
 // FreeDevices mocks base method
 func (m *MockOps) FreeDevices() ([]string, error) {
 	m.ctrl.T.Helper()
@@ -268,6 +414,22 @@ func (mr *MockOpsMockRecorder) GetClusterSizeForInstance(arg0 interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetClusterSizeForInstance", reflect.TypeOf((*MockOps)(nil).GetClusterSizeForInstance), arg0)
 }
 
+// GetClusterVersion mocks base method
+func (m *MockOps) GetClusterVersion(arg0 string) (string, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetClusterVersion", arg0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetClusterVersion indicates an expected call of GetClusterVersion
+func (mr *MockOpsMockRecorder) GetClusterVersion(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetClusterVersion", reflect.TypeOf((*MockOps)(nil).GetClusterVersion), arg0)
+}
+
 // GetDeviceID mocks base method
 func (m *MockOps) GetDeviceID(arg0 interface{}) (string, error) {
 	m.ctrl.T.Helper()
@@ -283,6 +445,22 @@ func (mr *MockOpsMockRecorder) GetDeviceID(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeviceID", reflect.TypeOf((*MockOps)(nil).GetDeviceID), arg0)
 }
 
+// GetEffectivePerformance mocks base method
+func (m *MockOps) GetEffectivePerformance(arg0 string) (uint64, uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEffectivePerformance", arg0)
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(uint64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetEffectivePerformance indicates an expected call of GetEffectivePerformance
+func (mr *MockOpsMockRecorder) GetEffectivePerformance(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEffectivePerformance", reflect.TypeOf((*MockOps)(nil).GetEffectivePerformance), arg0)
+}
+
 // GetInstance mocks base method
 func (m *MockOps) GetInstance(arg0 string) (interface{}, error) {
 	m.ctrl.T.Helper()
@@ -313,6 +491,115 @@ func (mr *MockOpsMockRecorder) GetInstanceGroupSize(arg0 interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInstanceGroupSize", reflect.TypeOf((*MockOps)(nil).GetInstanceGroupSize), arg0)
 }
 
+// GetInstanceGroupZones mocks base method
+func (m *MockOps) GetInstanceGroupZones(arg0 string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetInstanceGroupZones", arg0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetInstanceGroupZones indicates an expected call of GetInstanceGroupZones
+func (mr *MockOpsMockRecorder) GetInstanceGroupZones(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInstanceGroupZones", reflect.TypeOf((*MockOps)(nil).GetInstanceGroupZones), arg0)
+}
+
+// GetNetworkInfo mocks base method
+func (m *MockOps) GetNetworkInfo(arg0 string) (*cloudops.NetworkInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetNetworkInfo", arg0)
+	ret0, _ := ret[0].(*cloudops.NetworkInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetNetworkInfo indicates an expected call of GetNetworkInfo
+func (mr *MockOpsMockRecorder) GetNetworkInfo(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNetworkInfo", reflect.TypeOf((*MockOps)(nil).GetNetworkInfo), arg0)
+}
+
+// GetStorageLayout mocks base method
+func (m *MockOps) GetStorageLayout(arg0 string) ([]cloudops.VolumeSpec, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStorageLayout", arg0)
+	ret0, _ := ret[0].([]cloudops.VolumeSpec)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStorageLayout indicates an expected call of GetStorageLayout
+func (mr *MockOpsMockRecorder) GetStorageLayout(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStorageLayout", reflect.TypeOf((*MockOps)(nil).GetStorageLayout), arg0)
+}
+
+// GetStoragePressure mocks base method
+func (m *MockOps) GetStoragePressure(arg0 string) ([]cloudops.VolumePressureInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStoragePressure", arg0)
+	ret0, _ := ret[0].([]cloudops.VolumePressureInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStoragePressure indicates an expected call of GetStoragePressure
+func (mr *MockOpsMockRecorder) GetStoragePressure(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStoragePressure", reflect.TypeOf((*MockOps)(nil).GetStoragePressure), arg0)
+}
+
+// GetVMDiskBudget mocks base method
+func (m *MockOps) GetVMDiskBudget(arg0 string) (uint64, uint64, uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVMDiskBudget", arg0)
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(uint64)
+	ret2, _ := ret[2].(uint64)
+	ret3, _ := ret[3].(error)
+	return ret0, ret1, ret2, ret3
+}
+
+// GetVMDiskBudget indicates an expected call of GetVMDiskBudget
+func (mr *MockOpsMockRecorder) GetVMDiskBudget(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVMDiskBudget", reflect.TypeOf((*MockOps)(nil).GetVMDiskBudget), arg0)
+}
+
+// GetVolumeLineage mocks base method
+func (m *MockOps) GetVolumeLineage(arg0 string) (string, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVolumeLineage", arg0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetVolumeLineage indicates an expected call of GetVolumeLineage
+func (mr *MockOpsMockRecorder) GetVolumeLineage(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVolumeLineage", reflect.TypeOf((*MockOps)(nil).GetVolumeLineage), arg0)
+}
+
+// GetVolumeQoS mocks base method
+func (m *MockOps) GetVolumeQoS(arg0 string) (uint64, uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVolumeQoS", arg0)
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(uint64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetVolumeQoS indicates an expected call of GetVolumeQoS
+func (mr *MockOpsMockRecorder) GetVolumeQoS(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVolumeQoS", reflect.TypeOf((*MockOps)(nil).GetVolumeQoS), arg0)
+}
+
 // Inspect mocks base method
 func (m *MockOps) Inspect(arg0 []*string, arg1 map[string]string) ([]interface{}, error) {
 	m.ctrl.T.Helper()
@@ -358,6 +645,36 @@ func (mr *MockOpsMockRecorder) InspectInstanceGroupForInstance(arg0 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InspectInstanceGroupForInstance", reflect.TypeOf((*MockOps)(nil).InspectInstanceGroupForInstance), arg0)
 }
 
+// InspectInstanceInZone mocks base method
+func (m *MockOps) InspectInstanceInZone(arg0, arg1 string) (*cloudops.InstanceInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InspectInstanceInZone", arg0, arg1)
+	ret0, _ := ret[0].(*cloudops.InstanceInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InspectInstanceInZone indicates an expected call of InspectInstanceInZone
+func (mr *MockOpsMockRecorder) InspectInstanceInZone(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InspectInstanceInZone", reflect.TypeOf((*MockOps)(nil).InspectInstanceInZone), arg0, arg1)
+}
+
+// InspectSnapshot mocks base method
+func (m *MockOps) InspectSnapshot(arg0 string) (*cloudops.SnapshotInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InspectSnapshot", arg0)
+	ret0, _ := ret[0].(*cloudops.SnapshotInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InspectSnapshot indicates an expected call of InspectSnapshot
+func (mr *MockOpsMockRecorder) InspectSnapshot(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InspectSnapshot", reflect.TypeOf((*MockOps)(nil).InspectSnapshot), arg0)
+}
+
 // InstanceID mocks base method
 func (m *MockOps) InstanceID() string {
 	m.ctrl.T.Helper()
@@ -372,6 +689,80 @@ func (mr *MockOpsMockRecorder) InstanceID() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InstanceID", reflect.TypeOf((*MockOps)(nil).InstanceID))
 }
 
+// ListInstanceGroupMembers mocks base method
+func (m *MockOps) ListInstanceGroupMembers(arg0 string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListInstanceGroupMembers", arg0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListInstanceGroupMembers indicates an expected call of ListInstanceGroupMembers
+func (mr *MockOpsMockRecorder) ListInstanceGroupMembers(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListInstanceGroupMembers", reflect.TypeOf((*MockOps)(nil).ListInstanceGroupMembers), arg0)
+}
+
+// ListSnapshots mocks base method
+func (m *MockOps) ListSnapshots(arg0 map[string]string) ([]*cloudops.SnapshotInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSnapshots", arg0)
+	ret0, _ := ret[0].([]*cloudops.SnapshotInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSnapshots indicates an expected call of ListSnapshots
+func (mr *MockOpsMockRecorder) ListSnapshots(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSnapshots", reflect.TypeOf((*MockOps)(nil).ListSnapshots), arg0)
+}
+
+// ListVolumesPaged mocks base method
+func (m *MockOps) ListVolumesPaged(arg0 map[string]string, arg1 int) (cloudops.VolumeIterator, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListVolumesPaged", arg0, arg1)
+	ret0, _ := ret[0].(cloudops.VolumeIterator)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListVolumesPaged indicates an expected call of ListVolumesPaged
+func (mr *MockOpsMockRecorder) ListVolumesPaged(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListVolumesPaged", reflect.TypeOf((*MockOps)(nil).ListVolumesPaged), arg0, arg1)
+}
+
+// LockVolume mocks base method
+func (m *MockOps) LockVolume(arg0, arg1 string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockVolume", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LockVolume indicates an expected call of LockVolume
+func (mr *MockOpsMockRecorder) LockVolume(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockVolume", reflect.TypeOf((*MockOps)(nil).LockVolume), arg0, arg1)
+}
+
+// ModifyVolumeType mocks base method
+func (m *MockOps) ModifyVolumeType(arg0, arg1 string, arg2, arg3 *int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ModifyVolumeType", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ModifyVolumeType indicates an expected call of ModifyVolumeType
+func (mr *MockOpsMockRecorder) ModifyVolumeType(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ModifyVolumeType", reflect.TypeOf((*MockOps)(nil).ModifyVolumeType), arg0, arg1, arg2, arg3)
+}
+
 // Name mocks base method
 func (m *MockOps) Name() string {
 	m.ctrl.T.Helper()
@@ -386,6 +777,36 @@ func (mr *MockOpsMockRecorder) Name() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Name", reflect.TypeOf((*MockOps)(nil).Name))
 }
 
+// ProvisionStorageLayout mocks base method
+func (m *MockOps) ProvisionStorageLayout(arg0 []cloudops.VolumeSpec, arg1 map[string]string) ([]interface{}, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProvisionStorageLayout", arg0, arg1)
+	ret0, _ := ret[0].([]interface{})
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ProvisionStorageLayout indicates an expected call of ProvisionStorageLayout
+func (mr *MockOpsMockRecorder) ProvisionStorageLayout(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProvisionStorageLayout", reflect.TypeOf((*MockOps)(nil).ProvisionStorageLayout), arg0, arg1)
+}
+
+// ReconcileDataDisks mocks base method
+func (m *MockOps) ReconcileDataDisks(arg0 string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReconcileDataDisks", arg0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReconcileDataDisks indicates an expected call of ReconcileDataDisks
+func (mr *MockOpsMockRecorder) ReconcileDataDisks(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReconcileDataDisks", reflect.TypeOf((*MockOps)(nil).ReconcileDataDisks), arg0)
+}
+
 // RemoveTags mocks base method
 func (m *MockOps) RemoveTags(arg0 string, arg1, arg2 map[string]string) error {
 	m.ctrl.T.Helper()
@@ -400,6 +821,35 @@ func (mr *MockOpsMockRecorder) RemoveTags(arg0, arg1, arg2 interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveTags", reflect.TypeOf((*MockOps)(nil).RemoveTags), arg0, arg1, arg2)
 }
 
+// ReportCapacityByLabel mocks base method
+func (m *MockOps) ReportCapacityByLabel(arg0 string) (map[string]uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReportCapacityByLabel", arg0)
+	ret0, _ := ret[0].(map[string]uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReportCapacityByLabel indicates an expected call of ReportCapacityByLabel
+func (mr *MockOpsMockRecorder) ReportCapacityByLabel(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReportCapacityByLabel", reflect.TypeOf((*MockOps)(nil).ReportCapacityByLabel), arg0)
+}
+
+// RollInstanceGroup mocks base method
+func (m *MockOps) RollInstanceGroup(arg0 string, arg1 cloudops.RollOpts) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RollInstanceGroup", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RollInstanceGroup indicates an expected call of RollInstanceGroup
+func (mr *MockOpsMockRecorder) RollInstanceGroup(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RollInstanceGroup", reflect.TypeOf((*MockOps)(nil).RollInstanceGroup), arg0, arg1)
+}
+
 // SetClusterVersion mocks base method
 func (m *MockOps) SetClusterVersion(arg0 string, arg1 time.Duration) error {
 	m.ctrl.T.Helper()
@@ -414,6 +864,34 @@ func (mr *MockOpsMockRecorder) SetClusterVersion(arg0, arg1 interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetClusterVersion", reflect.TypeOf((*MockOps)(nil).SetClusterVersion), arg0, arg1)
 }
 
+// SetInstanceGroupNodeLabels mocks base method
+func (m *MockOps) SetInstanceGroupNodeLabels(arg0 string, arg1 map[string]string, arg2 time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetInstanceGroupNodeLabels", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetInstanceGroupNodeLabels indicates an expected call of SetInstanceGroupNodeLabels
+func (mr *MockOpsMockRecorder) SetInstanceGroupNodeLabels(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetInstanceGroupNodeLabels", reflect.TypeOf((*MockOps)(nil).SetInstanceGroupNodeLabels), arg0, arg1, arg2)
+}
+
+// SetInstanceGroupNodeTaints mocks base method
+func (m *MockOps) SetInstanceGroupNodeTaints(arg0 string, arg1 []cloudops.NodeTaint, arg2 time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetInstanceGroupNodeTaints", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetInstanceGroupNodeTaints indicates an expected call of SetInstanceGroupNodeTaints
+func (mr *MockOpsMockRecorder) SetInstanceGroupNodeTaints(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetInstanceGroupNodeTaints", reflect.TypeOf((*MockOps)(nil).SetInstanceGroupNodeTaints), arg0, arg1, arg2)
+}
+
 // SetInstanceGroupSize mocks base method
 func (m *MockOps) SetInstanceGroupSize(arg0 string, arg1 int64, arg2 time.Duration) error {
 	m.ctrl.T.Helper()
@@ -471,6 +949,35 @@ func (mr *MockOpsMockRecorder) SnapshotDelete(arg0, arg1 interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SnapshotDelete", reflect.TypeOf((*MockOps)(nil).SnapshotDelete), arg0, arg1)
 }
 
+// SnapshotWithOptions mocks base method
+func (m *MockOps) SnapshotWithOptions(arg0 string, arg1 bool, arg2 cloudops.SnapshotOptions) (interface{}, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SnapshotWithOptions", arg0, arg1, arg2)
+	ret0, _ := ret[0].(interface{})
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SnapshotWithOptions indicates an expected call of SnapshotWithOptions
+func (mr *MockOpsMockRecorder) SnapshotWithOptions(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SnapshotWithOptions", reflect.TypeOf((*MockOps)(nil).SnapshotWithOptions), arg0, arg1, arg2)
+}
+
+// StopReplication mocks base method
+func (m *MockOps) StopReplication(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StopReplication", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StopReplication indicates an expected call of StopReplication
+func (mr *MockOpsMockRecorder) StopReplication(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StopReplication", reflect.TypeOf((*MockOps)(nil).StopReplication), arg0)
+}
+
 // Tags mocks base method
 func (m *MockOps) Tags(arg0 string) (map[string]string, error) {
 	m.ctrl.T.Helper()
@@ -498,4 +1005,46 @@ func (m *MockOps) SetInstanceUpgradeStrategy(arg0 string, arg1 string, arg2 time
 func (mr *MockOpsMockRecorder) SetInstanceUpgradeStrategy(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetInstanceUpgradeStrategy", reflect.TypeOf((*MockOps)(nil).SetInstanceUpgradeStrategy), arg0, arg1, arg2, arg3)
-}
\ No newline at end of file
+}
+
+// UnlockVolume mocks base method
+func (m *MockOps) UnlockVolume(arg0, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnlockVolume", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnlockVolume indicates an expected call of UnlockVolume
+func (mr *MockOpsMockRecorder) UnlockVolume(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnlockVolume", reflect.TypeOf((*MockOps)(nil).UnlockVolume), arg0, arg1)
+}
+
+// UpdateAttachmentCaching mocks base method
+func (m *MockOps) UpdateAttachmentCaching(arg0, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAttachmentCaching", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateAttachmentCaching indicates an expected call of UpdateAttachmentCaching
+func (mr *MockOpsMockRecorder) UpdateAttachmentCaching(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAttachmentCaching", reflect.TypeOf((*MockOps)(nil).UpdateAttachmentCaching), arg0, arg1, arg2)
+}
+
+// WaitForVolumeState mocks base method
+func (m *MockOps) WaitForVolumeState(arg0, arg1 string, arg2 time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WaitForVolumeState", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WaitForVolumeState indicates an expected call of WaitForVolumeState
+func (mr *MockOpsMockRecorder) WaitForVolumeState(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WaitForVolumeState", reflect.TypeOf((*MockOps)(nil).WaitForVolumeState), arg0, arg1, arg2)
+}
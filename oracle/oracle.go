@@ -8,12 +8,13 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
-	"strconv"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/libopenstorage/cloudops"
+	"github.com/libopenstorage/cloudops/backoff"
 	"github.com/oracle/oci-go-sdk/v65/common"
 	"github.com/oracle/oci-go-sdk/v65/containerengine"
 	"github.com/oracle/oci-go-sdk/v65/core"
@@ -50,6 +51,10 @@ const (
 	envPoolID             = "POOL_ID"
 	envClusterID          = "CLUSTER_ID"
 	defaultTimeout        = 5 * time.Minute
+	// minVolumeSizeInGBs is the minimum size of a block volume
+	minVolumeSizeInGBs = 50
+	// bytesPerGB is the number of bytes in a GB of block volume size
+	bytesPerGB = 1 << 30
 )
 
 type oracleOps struct {
@@ -65,13 +70,34 @@ type oracleOps struct {
 	volumeAttachmentMapping map[string]*string
 	storage                 core.BlockstorageClient
 	compute                 core.ComputeClient
+	virtualNetwork          core.VirtualNetworkClient
 	containerEngine         containerengine.ContainerEngineClient
-	mutex                   sync.Mutex
+	opsTimeout              cloudops.OpsTimeoutConfig
+	// logger if set is used for all the log lines, see SetLogger
+	logger *logrus.Entry
+	mutex  sync.Mutex
 }
 
 // NewClient creates a new cloud operations client for Oracle cloud
 func NewClient() (cloudops.Ops, error) {
-	oracleOps := &oracleOps{}
+	return NewClientWithOpsTimeout(cloudops.DefaultOpsTimeoutConfig())
+}
+
+// NewClientWithOpsTimeout creates a new cloud operations client for Oracle
+// cloud which waits for operations to complete as configured by opsTimeout
+func NewClientWithOpsTimeout(opsTimeout cloudops.OpsTimeoutConfig) (cloudops.Ops, error) {
+	return NewClientWithBackoff(opsTimeout, nil)
+}
+
+// NewClientWithBackoff creates a new cloud operations client for Oracle cloud
+// which waits for operations to complete as configured by opsTimeout and
+// retries throttled requests as configured by backoffConfig. The retries
+// default to backoff.DefaultExponentialBackoff if backoffConfig is nil.
+func NewClientWithBackoff(
+	opsTimeout cloudops.OpsTimeoutConfig,
+	backoffConfig *backoff.Config,
+) (cloudops.Ops, error) {
+	oracleOps := &oracleOps{opsTimeout: opsTimeout}
 	err := getInfoFromMetadata(oracleOps)
 	if err != nil {
 		err = getInfoFromEnv(oracleOps)
@@ -90,14 +116,44 @@ func NewClient() (cloudops.Ops, error) {
 	if err != nil {
 		return nil, err
 	}
+	oracleOps.virtualNetwork, err = core.NewVirtualNetworkClientWithConfigurationProvider(configProvider)
+	if err != nil {
+		return nil, err
+	}
 	oracleOps.containerEngine, err = containerengine.NewContainerEngineClientWithConfigurationProvider(configProvider)
 	if err != nil {
 		return nil, err
 	}
 
 	oracleOps.volumeAttachmentMapping = map[string]*string{}
-	// TODO: [PWX-18717] wrap around exponentialBackoffOps
-	return oracleOps, nil
+	return backoff.NewProviderExponentialBackoffOps(
+		oracleOps,
+		isExponentialError,
+		backoffConfig,
+		0,
+	)
+}
+
+// isExponentialError returns true if the OCI service throttled the request or
+// failed with a server error
+func isExponentialError(err error) bool {
+	// Got the list of error codes from here
+	// https://docs.oracle.com/en-us/iaas/Content/API/References/apierrors.htm
+	if serviceErr, ok := common.IsServiceError(err); ok {
+		code := serviceErr.GetHTTPStatusCode()
+		return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+	}
+	return false
+}
+
+// isFatalError returns true if the request was rejected for its credentials or
+// could not reach the OCI endpoint
+func isFatalError(err error) bool {
+	if serviceErr, ok := common.IsServiceError(err); ok {
+		code := serviceErr.GetHTTPStatusCode()
+		return code == http.StatusUnauthorized || code == http.StatusForbidden
+	}
+	return cloudops.IsConnectionError(err)
 }
 
 func getInfoFromEnv(oracleOps *oracleOps) error {
@@ -187,8 +243,18 @@ func getRequest(endpoint string, headers map[string]string) (map[string]interfac
 	return metadata, resp.StatusCode, nil
 }
 
-// GetMetadata returns metadata from IMDS
+// GetMetadata returns metadata from IMDS, or from the process-wide metadata
+// cache if it was fetched recently. The returned map must not be modified.
 func GetMetadata() (map[string]interface{}, error) {
+	metadata, err := cloudops.CachedMetadata(cloudops.Oracle, func() (interface{}, error) {
+		return getMetadata()
+	})
+	m, _ := metadata.(map[string]interface{})
+	return m, err
+}
+
+// getMetadata queries IMDS for the instance metadata
+func getMetadata() (map[string]interface{}, error) {
 	httpHeaders := map[string]string{}
 	httpHeaders["Authorization"] = "Bearer Oracle"
 	var httpStatusCode int
@@ -200,7 +266,7 @@ func GetMetadata() (map[string]interface{}, error) {
 	}
 
 	if httpStatusCode != http.StatusOK {
-		logrus.Warnf("Trying %s endpoint as got %d http response from %s\n",
+		cloudops.ProviderLogger(nil, string(cloudops.Oracle), "NewClient", "").Warnf("Trying %s endpoint as got %d http response from %s\n",
 			v1MetadataAPIEndpoint, httpStatusCode, v2MetadataAPIEndpoint)
 		metadata, httpStatusCode, err = getRequest(v1MetadataAPIEndpoint, map[string]string{})
 		if err != nil {
@@ -258,6 +324,14 @@ func getInfoFromMetadata(oracleOps *oracleOps) error {
 
 func (o *oracleOps) Name() string { return string(cloudops.Oracle) }
 
+// SetLogger sets the logger used for all the log lines of the client
+func (o *oracleOps) SetLogger(logger *logrus.Entry) { o.logger = logger }
+
+// log returns the logger for a line logged by the given method
+func (o *oracleOps) log(method, volumeID string) *logrus.Entry {
+	return cloudops.ProviderLogger(o.logger, o.Name(), method, volumeID)
+}
+
 func (o *oracleOps) InstanceID() string { return o.instance }
 
 func (o *oracleOps) InspectInstance(instanceID string) (*cloudops.InstanceInfo, error) {
@@ -280,6 +354,69 @@ func (o *oracleOps) InspectInstance(instanceID string) (*cloudops.InstanceInfo,
 	}, nil
 }
 
+func (o *oracleOps) InspectInstanceInZone(instanceID, zone string) (*cloudops.InstanceInfo, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "InspectInstanceInZone",
+	}
+}
+
+func (o *oracleOps) GetNetworkInfo(instanceID string) (*cloudops.NetworkInfo, error) {
+	listVnicAttachmentsReq := core.ListVnicAttachmentsRequest{
+		CompartmentId: common.String(o.compartmentID),
+		InstanceId:    common.String(instanceID),
+	}
+	listVnicAttachmentsResp, err := o.compute.ListVnicAttachments(context.Background(), listVnicAttachmentsReq)
+	if err != nil {
+		return nil, err
+	}
+
+	vnics := []core.Vnic{}
+	for _, vnicAttachment := range listVnicAttachmentsResp.Items {
+		if vnicAttachment.LifecycleState != core.VnicAttachmentLifecycleStateAttached ||
+			vnicAttachment.VnicId == nil {
+			continue
+		}
+		vnicResp, err := o.virtualNetwork.GetVnic(context.Background(), core.GetVnicRequest{
+			VnicId: vnicAttachment.VnicId,
+		})
+		if err != nil {
+			return nil, err
+		}
+		vnics = append(vnics, vnicResp.Vnic)
+	}
+
+	networkInfo := networkInfoFromVnics(vnics)
+	if len(networkInfo.Subnet) > 0 {
+		subnetResp, err := o.virtualNetwork.GetSubnet(context.Background(), core.GetSubnetRequest{
+			SubnetId: common.String(networkInfo.Subnet),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if subnetResp.VcnId != nil {
+			networkInfo.Network = *subnetResp.VcnId
+		}
+	}
+	return networkInfo, nil
+}
+
+// networkInfoFromVnics returns the subnet of the primary VNIC and the private
+// IPs of all the given VNICs. The VCN has to be looked up from the subnet.
+func networkInfoFromVnics(vnics []core.Vnic) *cloudops.NetworkInfo {
+	networkInfo := &cloudops.NetworkInfo{
+		PrivateIPs: make([]string, 0),
+	}
+	for _, vnic := range vnics {
+		if vnic.IsPrimary != nil && *vnic.IsPrimary && vnic.SubnetId != nil {
+			networkInfo.Subnet = *vnic.SubnetId
+		}
+		if vnic.PrivateIp != nil {
+			networkInfo.PrivateIPs = append(networkInfo.PrivateIPs, *vnic.PrivateIp)
+		}
+	}
+	return networkInfo
+}
+
 func (o *oracleOps) GetInstance(displayName string) (interface{}, error) {
 	listInstanceReq := core.ListInstancesRequest{
 		DisplayName:   common.String(displayName),
@@ -356,7 +493,7 @@ func (o *oracleOps) DeviceMappings() (map[string]string, error) {
 				devicePath = *va.GetDevice()
 				volID = *va.GetVolumeId()
 			} else {
-				logrus.Warnf("Device path or volume id for [%+v] volume attachment not found", va)
+				o.log("DeviceMappings", "").Warnf("Device path or volume id for [%+v] volume attachment not found", va)
 				continue
 			}
 		}
@@ -366,6 +503,10 @@ func (o *oracleOps) DeviceMappings() (map[string]string, error) {
 }
 
 func (o *oracleOps) DevicePath(volumeID string) (string, error) {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return "", err
+	}
+
 	volumeAttachmentReq := core.ListVolumeAttachmentsRequest{
 		CompartmentId: common.String(o.compartmentID),
 		VolumeId:      common.String(volumeID),
@@ -466,6 +607,10 @@ func (o *oracleOps) DevicePath(volumeID string) (string, error) {
 
 // Inspect volumes specified by volumeID
 func (o *oracleOps) Inspect(volumeIds []*string, options map[string]string) ([]interface{}, error) {
+	if err := cloudops.ValidateVolumeIDs(volumeIds); err != nil {
+		return nil, err
+	}
+
 	oracleVols := []interface{}{}
 	for _, volID := range volumeIds {
 		getVolReq := core.GetVolumeRequest{
@@ -488,14 +633,25 @@ func (o *oracleOps) Create(template interface{}, labels map[string]string, optio
 			"Invalid volume template given", "")
 	}
 
+	// The volume size defaults to 1 TB if not set
+	sizeInGBs := vol.SizeInGBs
+	if sizeInGBs != nil {
+		size, err := cloudops.EnforceMinSize(uint64(*sizeInGBs), minVolumeSizeInGBs, options)
+		if err != nil {
+			return nil, err
+		}
+		sizeInGBs = common.Int64(int64(size))
+	}
+
 	createVolReq := core.CreateVolumeRequest{
 		CreateVolumeDetails: core.CreateVolumeDetails{
 			CompartmentId:      &o.compartmentID,
 			AvailabilityDomain: &o.availabilityDomain,
-			SizeInGBs:          vol.SizeInGBs,
+			SizeInGBs:          sizeInGBs,
 			VpusPerGB:          vol.VpusPerGB,
 			DisplayName:        vol.DisplayName,
 			KmsKeyId:           vol.KmsKeyId,
+			SourceDetails:      vol.SourceDetails,
 			FreeformTags:       labels,
 		},
 	}
@@ -515,6 +671,24 @@ func (o *oracleOps) Create(template interface{}, labels map[string]string, optio
 	return oracleVol, nil
 }
 
+// CloneVolume creates a volume cloned from the source volume. The clone is
+// created in the availability domain of the instance, which has to be the
+// availability domain of the source volume.
+func (o *oracleOps) CloneVolume(sourceVolumeID string, template interface{}, labels map[string]string) (interface{}, error) {
+	if err := cloudops.ValidateVolumeID(sourceVolumeID); err != nil {
+		return nil, err
+	}
+	vol, ok := template.(*core.Volume)
+	if !ok {
+		return nil, cloudops.NewStorageError(cloudops.ErrVolInval,
+			"Invalid volume template given", "")
+	}
+
+	clone := *vol
+	clone.SourceDetails = core.VolumeSourceFromVolumeDetails{Id: &sourceVolumeID}
+	return o.Create(&clone, labels, nil)
+}
+
 func (o *oracleOps) waitVolumeStatus(volID string, desiredStatus core.VolumeLifecycleStateEnum) (interface{}, error) {
 	getVolReq := core.GetVolumeRequest{
 		VolumeId: &volID,
@@ -528,30 +702,202 @@ func (o *oracleOps) waitVolumeStatus(volID string, desiredStatus core.VolumeLife
 			return &getVolResp.Volume, false, nil
 		}
 
-		logrus.Debugf("volume [%s] is still in [%s] state", volID, getVolResp.Volume.LifecycleState)
+		o.log("waitVolumeStatus", volID).Debugf("volume [%s] is still in [%s] state", volID, getVolResp.Volume.LifecycleState)
 		return nil, true, fmt.Errorf("volume [%s] is still in [%s] state", volID, getVolResp.Volume.LifecycleState)
 	}
-	oracleVol, err := task.DoRetryWithTimeout(f, cloudops.ProviderOpsTimeout, cloudops.ProviderOpsRetryInterval)
+	oracleVol, err := task.DoRetryWithTimeout(f, o.opsTimeout.OpsTimeout(), o.opsTimeout.OpsRetryInterval())
 	return oracleVol, err
 }
 
 func (o *oracleOps) rollbackCreate(id string, createErr error) error {
-	logrus.Warnf("Rollback create volume %v, Error %v", id, createErr)
+	o.log("Create", id).Warnf("Rollback create volume %v, Error %v", id, createErr)
 	err := o.Delete(id, nil)
 	if err != nil {
-		logrus.Warnf("Rollback failed volume %v, Error %v", id, err)
+		o.log("Create", id).Warnf("Rollback failed volume %v, Error %v", id, err)
 	}
 	return createErr
 }
 
 // Delete volumeID.
 func (o *oracleOps) Delete(volumeID string, options map[string]string) error {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return err
+	}
+
 	delVolReq := core.DeleteVolumeRequest{
 		VolumeId: &volumeID,
 	}
 	delVolResp, err := o.storage.DeleteVolume(context.Background(), delVolReq)
 	if err != nil {
-		logrus.Errorf("failed to delete volume [%s]. Response: [%v], Error: [%v]", volumeID, delVolResp, err)
+		o.log("Delete", volumeID).Errorf("failed to delete volume [%s]. Response: [%v], Error: [%v]", volumeID, delVolResp, err)
+		return err
+	}
+	return nil
+}
+
+// Snapshot creates a backup of the given volume. Volume backups cannot be
+// attached, so the readonly flag has no effect.
+func (o *oracleOps) Snapshot(volumeID string, readonly bool, options map[string]string) (interface{}, error) {
+	return o.SnapshotWithOptions(volumeID, readonly, cloudops.SnapshotOptions{Options: options})
+}
+
+// SnapshotWithOptions creates a backup of the given volume, using the name as
+// the display name of the backup and the labels as its freeform tags.
+func (o *oracleOps) SnapshotWithOptions(volumeID string, readonly bool, opts cloudops.SnapshotOptions) (interface{}, error) {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return nil, err
+	}
+
+	wait, err := cloudops.WaitForSnapshot(opts.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	name := opts.Name
+	if len(name) == 0 {
+		name = cloudops.SnapshotName()
+	}
+	createBackupReq := core.CreateVolumeBackupRequest{
+		CreateVolumeBackupDetails: core.CreateVolumeBackupDetails{
+			VolumeId:     &volumeID,
+			DisplayName:  &name,
+			FreeformTags: opts.Labels,
+		},
+	}
+	createBackupResp, err := o.storage.CreateVolumeBackup(context.Background(), createBackupReq)
+	if err != nil {
+		return nil, err
+	}
+	if !wait {
+		return &createBackupResp.VolumeBackup, nil
+	}
+
+	return o.waitVolumeBackupStatus(*createBackupResp.Id, core.VolumeBackupLifecycleStateAvailable)
+}
+
+// EnumerateSnapshots returns the volume backups of the compartment which have
+// the given labels as freeform tags and are backups of one of the given
+// volumes.
+func (o *oracleOps) EnumerateSnapshots(volumeIDs []*string, labels map[string]string) ([]*cloudops.SnapshotInfo, error) {
+	if err := cloudops.ValidateVolumeIDs(volumeIDs); err != nil {
+		return nil, err
+	}
+
+	volumes := make(map[string]bool, len(volumeIDs))
+	for _, volumeID := range volumeIDs {
+		volumes[*volumeID] = true
+	}
+	snapshots := make([]*cloudops.SnapshotInfo, 0)
+	req := core.ListVolumeBackupsRequest{
+		CompartmentId: common.String(o.compartmentID),
+	}
+	for {
+		resp, err := o.storage.ListVolumeBackups(context.Background(), req)
+		if err != nil {
+			return nil, err
+		}
+		for _, backup := range resp.Items {
+			if backup.LifecycleState == core.VolumeBackupLifecycleStateTerminating ||
+				backup.LifecycleState == core.VolumeBackupLifecycleStateTerminated {
+				continue
+			}
+			if !containsMap(backup.FreeformTags, labels) {
+				continue
+			}
+			info := snapshotInfoFromBackup(backup)
+			if len(volumes) > 0 && !volumes[info.VolumeID] {
+				continue
+			}
+			snapshots = append(snapshots, info)
+		}
+		if resp.OpcNextPage == nil {
+			return snapshots, nil
+		}
+		req.Page = resp.OpcNextPage
+	}
+}
+
+// ListSnapshots returns the volume backups that match the given labels.
+func (o *oracleOps) ListSnapshots(labels map[string]string) ([]*cloudops.SnapshotInfo, error) {
+	return o.EnumerateSnapshots(nil, labels)
+}
+
+// InspectSnapshot returns the volume backup with the given ID.
+func (o *oracleOps) InspectSnapshot(snapID string) (*cloudops.SnapshotInfo, error) {
+	if err := cloudops.ValidateVolumeID(snapID); err != nil {
+		return nil, err
+	}
+
+	resp, err := o.storage.GetVolumeBackup(context.Background(), core.GetVolumeBackupRequest{
+		VolumeBackupId: &snapID,
+	})
+	if serviceErr, ok := common.IsServiceError(err); ok && serviceErr.GetHTTPStatusCode() == http.StatusNotFound {
+		return nil, cloudops.NewStorageError(cloudops.ErrVolNotFound,
+			fmt.Sprintf("volume backup %s not found", snapID), o.instance)
+	} else if err != nil {
+		return nil, err
+	}
+	return snapshotInfoFromBackup(resp.VolumeBackup), nil
+}
+
+// snapshotInfoFromBackup returns the provider neutral info of the given
+// volume backup
+func snapshotInfoFromBackup(backup core.VolumeBackup) *cloudops.SnapshotInfo {
+	info := &cloudops.SnapshotInfo{
+		CloudResourceInfo: cloudops.CloudResourceInfo{
+			Labels: backup.FreeformTags,
+		},
+		State: string(backup.LifecycleState),
+	}
+	if backup.Id != nil {
+		info.ID = *backup.Id
+	}
+	if backup.DisplayName != nil {
+		info.Name = *backup.DisplayName
+	}
+	if backup.VolumeId != nil {
+		info.VolumeID = *backup.VolumeId
+	}
+	if backup.SizeInGBs != nil {
+		info.SizeInGiB = uint64(*backup.SizeInGBs)
+	}
+	if backup.TimeCreated != nil {
+		info.CreationTime = backup.TimeCreated.Time
+	}
+	return info
+}
+
+func (o *oracleOps) waitVolumeBackupStatus(backupID string, desiredStatus core.VolumeBackupLifecycleStateEnum) (interface{}, error) {
+	getBackupReq := core.GetVolumeBackupRequest{
+		VolumeBackupId: &backupID,
+	}
+	f := func() (interface{}, bool, error) {
+		getBackupResp, err := o.storage.GetVolumeBackup(context.Background(), getBackupReq)
+		if err != nil {
+			return nil, true, err
+		}
+		if getBackupResp.VolumeBackup.LifecycleState == desiredStatus {
+			return &getBackupResp.VolumeBackup, false, nil
+		}
+
+		o.log("Snapshot", "").Debugf("volume backup [%s] is still in [%s] state", backupID, getBackupResp.VolumeBackup.LifecycleState)
+		return nil, true, fmt.Errorf("volume backup [%s] is still in [%s] state", backupID, getBackupResp.VolumeBackup.LifecycleState)
+	}
+	return task.DoRetryWithTimeout(f, o.opsTimeout.OpsTimeout(), o.opsTimeout.OpsRetryInterval())
+}
+
+// SnapshotDelete deletes the volume backup with the given ID.
+func (o *oracleOps) SnapshotDelete(snapID string, options map[string]string) error {
+	if err := cloudops.ValidateVolumeID(snapID); err != nil {
+		return err
+	}
+
+	delBackupReq := core.DeleteVolumeBackupRequest{
+		VolumeBackupId: &snapID,
+	}
+	delBackupResp, err := o.storage.DeleteVolumeBackup(context.Background(), delBackupReq)
+	if err != nil {
+		o.log("SnapshotDelete", "").Errorf("failed to delete volume backup [%s]. Response: [%v], Error: [%v]", snapID, delBackupResp, err)
 		return err
 	}
 	return nil
@@ -577,7 +923,7 @@ func (o *oracleOps) SetInstanceGroupSize(instanceGroupID string, count int64, ti
 	}
 	numberOfDomains := len(nodePools.Items[0].NodeConfigDetails.PlacementConfigs)
 	totalClusterSize := numberOfDomains * instanceGroupSize
-	logrus.Println("Setting instanceGroupSize to ", totalClusterSize, " in total ", numberOfDomains, " regions.")
+	o.log("SetInstanceGroupSize", "").Println("Setting instanceGroupSize to ", totalClusterSize, " in total ", numberOfDomains, " regions.")
 
 	//get all availabliity domain
 	nodePoolPlacementConfigDetails := make([]containerengine.NodePoolPlacementConfigDetails, numberOfDomains)
@@ -625,13 +971,36 @@ func (o *oracleOps) waitTillWorkStatusIsSucceeded(opcRequestID, opcWorkRequestID
 			return workResp.Status, false, nil
 		}
 
-		logrus.Debugf("Work status is in [%s] state", workResp.Status)
+		o.log("waitTillWorkStatusIsSucceeded", "").Debugf("Work status is in [%s] state", workResp.Status)
 		return nil, true, fmt.Errorf("Work status is in [%s] state", workResp.Status)
 	}
 	_, err := task.DoRetryWithTimeout(f, timeout, 10*time.Second)
 	return err
 }
 
+// GetInstanceGroupZones returns the availability domains of the placement
+// configs of the node pool
+func (o *oracleOps) GetInstanceGroupZones(instanceGroupID string) ([]string, error) {
+	nodePoolReq := containerengine.ListNodePoolsRequest{CompartmentId: &o.compartmentID, Name: &instanceGroupID, ClusterId: &o.clusterID}
+	nodePools, err := o.containerEngine.ListNodePools(context.Background(), nodePoolReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(nodePools.Items) == 0 {
+		return nil, errors.New("No node pool found with name " + instanceGroupID)
+	}
+
+	zones := []string{}
+	if nodePools.Items[0].NodeConfigDetails == nil {
+		return zones, nil
+	}
+	for _, placementConfig := range nodePools.Items[0].NodeConfigDetails.PlacementConfigs {
+		zones = append(zones, *placementConfig.AvailabilityDomain)
+	}
+	return zones, nil
+}
+
 func (o *oracleOps) GetInstanceGroupSize(instanceGroupID string) (int64, error) {
 
 	var count int64
@@ -662,9 +1031,48 @@ func (o *oracleOps) GetInstanceGroupSize(instanceGroupID string) (int64, error)
 	return count, nil
 }
 
+// ListInstanceGroupMembers returns the instance IDs of the given node pool.
+func (o *oracleOps) ListInstanceGroupMembers(instanceGroupID string) ([]string, error) {
+	nodePoolReq := containerengine.ListNodePoolsRequest{CompartmentId: &o.compartmentID, Name: &instanceGroupID, ClusterId: &o.clusterID}
+	nodePools, err := o.containerEngine.ListNodePools(context.Background(), nodePoolReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(nodePools.Items) == 0 {
+		return nil, errors.New("No node pool found with name " + instanceGroupID)
+	}
+
+	req := containerengine.GetNodePoolRequest{NodePoolId: nodePools.Items[0].Id}
+	resp, err := o.containerEngine.GetNodePool(context.Background(), req)
+	if err != nil {
+		return nil, err
+	}
+	return nodePoolMembers(resp.Nodes), nil
+}
+
+// nodePoolMembers returns the instance IDs of the given node pool nodes which
+// are not being deleted
+func nodePoolMembers(nodes []containerengine.Node) []string {
+	members := make([]string, 0)
+	for _, node := range nodes {
+		if node.Id == nil ||
+			node.LifecycleState == containerengine.NodeLifecycleStateDeleting ||
+			node.LifecycleState == containerengine.NodeLifecycleStateDeleted {
+			continue
+		}
+		members = append(members, *node.Id)
+	}
+	return members
+}
+
 // Attach volumeID, accepts attachOptions as opaque data
 // Return attach path.
 func (o *oracleOps) Attach(volumeID string, options map[string]string) (string, error) {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return "", err
+	}
+
 	o.mutex.Lock()
 	defer o.mutex.Unlock()
 
@@ -687,31 +1095,22 @@ func (o *oracleOps) Attach(volumeID string, options map[string]string) (string,
 		attachVolResp, err := o.compute.AttachVolume(context.Background(), attachVolReq)
 		if err != nil {
 			if strings.Contains(err.Error(), "is already in use") {
-				logrus.Infof("Skipping device: %s as it's in use. Will try next free device", device)
+				o.log("Attach", volumeID).Infof("Skipping device: %s as it's in use. Will try next free device", device)
 				continue
 			}
 			return "", err
 		}
 
-		var devicePath string
-		if attachVolResp.GetLifecycleState() != core.VolumeAttachmentLifecycleStateAttached {
-			devicePath, err = o.waitVolumeAttachmentStatus(
-				attachVolResp.GetId(),
-				core.VolumeAttachmentLifecycleStateAttached,
-			)
-			if err != nil {
-				devicePath, err := o.DevicePath(volumeID)
-				if err != nil {
-					return "", err
-				}
-				o.volumeAttachmentMapping[volumeID] = attachVolResp.GetId()
-				return devicePath, err
-			}
-		} else {
-			devicePath = *attachVolResp.GetDevice()
-		}
 		o.volumeAttachmentMapping[volumeID] = attachVolResp.GetId()
-		return devicePath, err
+		if attachVolResp.GetLifecycleState() == core.VolumeAttachmentLifecycleStateAttached {
+			return *attachVolResp.GetDevice(), nil
+		}
+		// The device of an attachment is known while it is still attaching,
+		// so wait for the attachment itself before follow-on operations
+		return o.waitVolumeAttachmentStatus(
+			attachVolResp.GetId(),
+			core.VolumeAttachmentLifecycleStateAttached,
+		)
 	}
 	return "", fmt.Errorf("failed to attach any of the free devices. Attempted: %v", devices)
 }
@@ -729,10 +1128,10 @@ func (o *oracleOps) waitVolumeAttachmentStatus(volumeAttachmentID *string, desir
 			return getVolAttachmentResp.GetDevice(), false, nil
 		}
 
-		logrus.Debugf("volume [%s] is still in [%s] state", *getVolAttachmentResp.GetVolumeId(), getVolAttachmentResp.GetLifecycleState())
+		o.log("waitVolumeAttachmentStatus", *getVolAttachmentResp.GetVolumeId()).Debugf("volume [%s] is still in [%s] state", *getVolAttachmentResp.GetVolumeId(), getVolAttachmentResp.GetLifecycleState())
 		return nil, true, fmt.Errorf("volume [%s] is still in [%s] state", *getVolAttachmentResp.GetVolumeId(), getVolAttachmentResp.GetLifecycleState())
 	}
-	devicePathRaw, err := task.DoRetryWithTimeout(f, cloudops.ProviderOpsTimeout, cloudops.ProviderOpsRetryInterval)
+	devicePathRaw, err := task.DoRetryWithTimeout(f, o.opsTimeout.OpsTimeout(), o.opsTimeout.OpsRetryInterval())
 	if err != nil {
 		return "", err
 	}
@@ -754,9 +1153,13 @@ func (o *oracleOps) DetachFrom(volumeID, instanceID string) error {
 }
 
 func (o *oracleOps) detachInternal(volumeID, instanceID string) error {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return err
+	}
+
 	attachmentID, ok := o.volumeAttachmentMapping[volumeID]
 	if !ok {
-		logrus.Warnf("could not find volume attachment ID for volume [%s] locally", volumeID)
+		o.log("Detach", volumeID).Warnf("could not find volume attachment ID for volume [%s] locally", volumeID)
 		listVolAttachmentReq := core.ListVolumeAttachmentsRequest{
 			VolumeId:           common.String(volumeID),
 			InstanceId:         common.String(instanceID),
@@ -765,7 +1168,7 @@ func (o *oracleOps) detachInternal(volumeID, instanceID string) error {
 		}
 		listVolAttachmentResp, err := o.compute.ListVolumeAttachments(context.Background(), listVolAttachmentReq)
 		if err != nil {
-			logrus.Errorf("error while getting attachments for volume [%s]. Response: [%+v]. Error: [%v]",
+			o.log("Detach", volumeID).Errorf("error while getting attachments for volume [%s]. Response: [%+v]. Error: [%v]",
 				volumeID, listVolAttachmentResp, err)
 			return err
 		}
@@ -780,7 +1183,7 @@ func (o *oracleOps) detachInternal(volumeID, instanceID string) error {
 	}
 	detachVolResp, err := o.compute.DetachVolume(context.Background(), detachVolReq)
 	if err != nil {
-		logrus.Errorf("error while detaching volume [%s] from instance [%s]. Response: [%+v]. Error: [%v]",
+		o.log("Detach", volumeID).Errorf("error while detaching volume [%s] from instance [%s]. Response: [%+v]. Error: [%v]",
 			volumeID, instanceID, detachVolResp, err)
 		return err
 	}
@@ -816,7 +1219,10 @@ func (o *oracleOps) FreeDevices() ([]string, error) {
 }
 
 func (o *oracleOps) GetDeviceID(vol interface{}) (string, error) {
-	if d, ok := vol.(*core.Volume); ok {
+	switch d := vol.(type) {
+	case *core.Volume:
+		return *d.Id, nil
+	case *core.VolumeBackup:
 		return *d.Id, nil
 	}
 	return "", fmt.Errorf("invalid type: %v given to GetDeviceID", vol)
@@ -845,7 +1251,7 @@ func (o *oracleOps) DeleteInstance(instanceID string, zone string, timeout time.
 				return err
 			}
 			if ok := nodePoolContainsNode(poolResp.Nodes, instanceID); ok {
-				logrus.Println("Instance is in pool ", *pool.Name)
+				o.log("DeleteInstance", "").Println("Instance is in pool ", *pool.Name)
 				nodePoolID = pool.Id
 				break
 			}
@@ -890,18 +1296,78 @@ func (o *oracleOps) AreVolumesReadyToExpand(volumeIDs []*string) (bool, error) {
 	}
 }
 
+// WaitForVolumeState waits until the volume is in the given normalized state.
+// An available volume is attached if it has an attached volume attachment.
+func (o *oracleOps) WaitForVolumeState(volumeID string, desiredState string, timeout time.Duration) error {
+	getState := func() (string, error) {
+		resp, err := o.storage.GetVolume(context.Background(), core.GetVolumeRequest{VolumeId: &volumeID})
+		if serviceErr, ok := common.IsServiceError(err); ok && serviceErr.GetHTTPStatusCode() == http.StatusNotFound {
+			return "", cloudops.NewStorageError(cloudops.ErrVolNotFound,
+				fmt.Sprintf("volume %s not found", volumeID), o.instance)
+		} else if err != nil {
+			return "", err
+		}
+		state := volumeState(resp.Volume.LifecycleState)
+		if state != cloudops.VolumeStateAvailable {
+			return state, nil
+		}
+
+		attachments, err := o.compute.ListVolumeAttachments(context.Background(), core.ListVolumeAttachmentsRequest{
+			VolumeId:      common.String(volumeID),
+			CompartmentId: common.String(o.compartmentID),
+		})
+		if err != nil {
+			return "", err
+		}
+		for _, va := range attachments.Items {
+			if va.GetLifecycleState() == core.VolumeAttachmentLifecycleStateAttached {
+				return cloudops.VolumeStateAttached, nil
+			}
+		}
+		return state, nil
+	}
+	return cloudops.PollVolumeState(volumeID, desiredState, getState, timeout, o.opsTimeout.OpsRetryInterval())
+}
+
+// volumeState maps the lifecycle state of the volume to the normalized volume
+// state
+func volumeState(state core.VolumeLifecycleStateEnum) string {
+	switch state {
+	case core.VolumeLifecycleStateProvisioning, core.VolumeLifecycleStateRestoring:
+		return cloudops.VolumeStateCreating
+	case core.VolumeLifecycleStateAvailable:
+		return cloudops.VolumeStateAvailable
+	case core.VolumeLifecycleStateTerminating, core.VolumeLifecycleStateTerminated:
+		return cloudops.VolumeStateDeleting
+	case core.VolumeLifecycleStateFaulty:
+		return cloudops.VolumeStateError
+	}
+	return string(state)
+}
+
+// Expand resizes the volume online to the given size. Block volumes cannot be
+// shrunk, so requests for a size smaller than or equal to the current size of
+// the volume fail with ErrDiskGreaterOrEqualToExpandSize.
 func (o *oracleOps) Expand(volumeID string, newSizeInGiB uint64, options map[string]string) (uint64, error) {
-	logrus.Debug("Expand volume to size ", newSizeInGiB, " GiB")
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return 0, err
+	}
+
+	o.log("Expand", volumeID).Debug("Expand volume to size ", newSizeInGiB, " GiB")
 
 	volume, err := o.storage.GetVolume(context.Background(), core.GetVolumeRequest{VolumeId: &volumeID})
 	if err != nil {
 		return 0, err
 	}
+	if volume.SizeInGBs == nil {
+		return 0, fmt.Errorf("size of volume [%s] is not set", volumeID)
+	}
 
 	currentsize := uint64(*volume.SizeInGBs)
-
-	if (currentsize > newSizeInGiB) || (currentsize == newSizeInGiB) {
-		return currentsize, errors.New("Can not change Volume size from " + strconv.Itoa(int(currentsize)) + " GiB to " + strconv.Itoa(int(newSizeInGiB)) + " GiB")
+	if currentsize >= newSizeInGiB {
+		return currentsize, cloudops.NewStorageError(cloudops.ErrDiskGreaterOrEqualToExpandSize,
+			fmt.Sprintf("disk already has a size: %d greater than or equal "+
+				"requested size: %d", currentsize, newSizeInGiB), "")
 	}
 
 	req := core.UpdateVolumeRequest{
@@ -929,7 +1395,7 @@ func (o *oracleOps) Expand(volumeID string, newSizeInGiB uint64, options map[str
 }
 
 func (o *oracleOps) SetClusterVersion(version string, timeout time.Duration) error {
-	logrus.Println("Setting Cluster version to", version)
+	o.log("SetClusterVersion", "").Println("Setting Cluster version to", version)
 	req := containerengine.UpdateClusterRequest{
 		ClusterId: &o.clusterID,
 		UpdateClusterDetails: containerengine.UpdateClusterDetails{
@@ -945,8 +1411,33 @@ func (o *oracleOps) SetClusterVersion(version string, timeout time.Duration) err
 	return o.waitTillWorkStatusIsSucceeded(resp.OpcRequestId, resp.OpcWorkRequestId, timeout)
 }
 
+func (o *oracleOps) GetClusterVersion(instanceID string) (string, string, error) {
+	cluster, err := o.containerEngine.GetCluster(context.Background(), containerengine.GetClusterRequest{
+		ClusterId: &o.clusterID,
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	nodePool, err := o.containerEngine.GetNodePool(context.Background(), containerengine.GetNodePoolRequest{
+		NodePoolId: &o.poolID,
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	var controlPlane, nodePoolVersion string
+	if cluster.KubernetesVersion != nil {
+		controlPlane = *cluster.KubernetesVersion
+	}
+	if nodePool.KubernetesVersion != nil {
+		nodePoolVersion = *nodePool.KubernetesVersion
+	}
+	return controlPlane, nodePoolVersion, nil
+}
+
 func (o *oracleOps) SetInstanceGroupVersion(instanceGroupName string, version string, timeout time.Duration) error {
-	logrus.Println("Setting Instance group version to", version)
+	o.log("SetInstanceGroupVersion", "").Println("Setting Instance group version to", version)
 	//get nodepool ID from name
 	var instanceGroupID *string
 	nodePoolsReq := containerengine.ListNodePoolsRequest{CompartmentId: &o.compartmentID, Name: &instanceGroupName, ClusterId: &o.clusterID}
@@ -991,6 +1482,73 @@ func (o *oracleOps) SetInstanceGroupVersion(instanceGroupName string, version st
 	return o.waitTillWorkStatusIsSucceeded(updateResp.OpcRequestId, updateResp.OpcWorkRequestId, timeout)
 }
 
+// SetInstanceGroupNodeLabels sets the initial kubernetes labels of the node pool.
+// OKE only applies the labels to nodes created after the update.
+func (o *oracleOps) SetInstanceGroupNodeLabels(instanceGroupName string,
+	labels map[string]string,
+	timeout time.Duration) error {
+	nodePoolsReq := containerengine.ListNodePoolsRequest{CompartmentId: &o.compartmentID, Name: &instanceGroupName, ClusterId: &o.clusterID}
+	nodePools, err := o.containerEngine.ListNodePools(context.Background(), nodePoolsReq)
+	if err != nil {
+		return err
+	}
+
+	if len(nodePools.Items) == 0 {
+		return errors.New("No node pool found with name" + instanceGroupName)
+	}
+
+	resp, err := o.containerEngine.UpdateNodePool(context.Background(), containerengine.UpdateNodePoolRequest{
+		NodePoolId: nodePools.Items[0].Id,
+		UpdateNodePoolDetails: containerengine.UpdateNodePoolDetails{
+			InitialNodeLabels: initialNodeLabels(labels),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return o.waitTillWorkStatusIsSucceeded(resp.OpcRequestId, resp.OpcWorkRequestId, timeout)
+}
+
+// SetInstanceGroupNodeTaints sets the kubernetes taints of the node pool
+func (o *oracleOps) SetInstanceGroupNodeTaints(instanceGroupName string,
+	taints []cloudops.NodeTaint,
+	timeout time.Duration) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "SetInstanceGroupNodeTaints",
+		Reason:    "OKE node pools do not support node taints",
+	}
+}
+
+// initialNodeLabels returns the given labels as OKE key value pairs sorted by key
+func initialNodeLabels(labels map[string]string) []containerengine.KeyValue {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	nodeLabels := make([]containerengine.KeyValue, 0, len(labels))
+	for _, key := range keys {
+		nodeLabels = append(nodeLabels, containerengine.KeyValue{
+			Key:   common.String(key),
+			Value: common.String(labels[key]),
+		})
+	}
+	return nodeLabels
+}
+
+// RollInstanceGroup is not supported as the vendored OKE API cannot cycle the
+// nodes of a node pool. Scaling the node pool down and back up would replace
+// all the nodes at once instead of honoring the surge and unavailability
+// limits of a roll.
+func (o *oracleOps) RollInstanceGroup(instanceGroupName string, opts cloudops.RollOpts) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "RollInstanceGroup",
+		Reason:    "OKE node pools cannot be cycled without taking all their nodes down",
+	}
+}
+
 func (o *oracleOps) scaleDownToZeroThenScaleUp(instanceGroupName, instanceGroupID string,
 	nodePools containerengine.ListNodePoolsResponse, timeout time.Duration) (containerengine.UpdateNodePoolResponse, error) {
 
@@ -1042,6 +1600,10 @@ func (o *oracleOps) Enumerate(volumeIds []*string,
 	labels map[string]string,
 	setIdentifier string,
 ) (map[string][]interface{}, error) {
+	if err := cloudops.ValidateVolumeIDs(volumeIds); err != nil {
+		return nil, err
+	}
+
 	sets := make(map[string][]interface{})
 	req := core.ListVolumesRequest{
 		CompartmentId: common.String(o.compartmentID),
@@ -1123,6 +1685,10 @@ func (o *oracleOps) deleted(v core.Volume) bool {
 
 // ApplyTags will overwrite the existing tags with newly provided tags
 func (o *oracleOps) ApplyTags(volumeID string, labels map[string]string, options map[string]string) error {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return err
+	}
+
 	req := core.UpdateVolumeRequest{
 		VolumeId: common.String(volumeID),
 		UpdateVolumeDetails: core.UpdateVolumeDetails{
@@ -1131,13 +1697,17 @@ func (o *oracleOps) ApplyTags(volumeID string, labels map[string]string, options
 	}
 	resp, err := o.storage.UpdateVolume(context.Background(), req)
 	if err != nil {
-		logrus.Errorf("failed to apply tag to %s. response: %v", volumeID, resp)
+		o.log("ApplyTags", volumeID).Errorf("failed to apply tag to %s. response: %v", volumeID, resp)
 	}
 	return err
 }
 
 // Tags will list the existing labels/tags on the given volume
 func (o *oracleOps) Tags(volumeID string) (map[string]string, error) {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return nil, err
+	}
+
 	vols, err := o.Inspect([]*string{&volumeID}, nil)
 	if err != nil {
 		return nil, err
@@ -1155,6 +1725,10 @@ func (o *oracleOps) Tags(volumeID string) (map[string]string, error) {
 
 // RemoveTags removes labels/tags from the given volume
 func (o *oracleOps) RemoveTags(volumeID string, labels map[string]string, options map[string]string) error {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return err
+	}
+
 	currentTags, err := o.Tags(volumeID)
 	if err != nil {
 		return nil
@@ -1164,3 +1738,101 @@ func (o *oracleOps) RemoveTags(volumeID string, labels map[string]string, option
 	}
 	return o.ApplyTags(volumeID, currentTags, options)
 }
+
+// GetVolumeLineage returns the volume backup or volume the given volume was
+// created from
+func (o *oracleOps) GetVolumeLineage(volumeID string) (string, string, error) {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return "", "", err
+	}
+
+	vols, err := o.Inspect([]*string{&volumeID}, nil)
+	if err != nil {
+		return "", "", err
+	}
+
+	switch source := vols[0].(*core.Volume).SourceDetails.(type) {
+	case core.VolumeSourceFromVolumeBackupDetails:
+		return cloudops.VolumeSourceSnapshot, *source.Id, nil
+	case core.VolumeSourceFromVolumeDetails:
+		return cloudops.VolumeSourceClone, *source.Id, nil
+	case core.VolumeSourceFromBlockVolumeReplicaDetails:
+		return cloudops.VolumeSourceClone, *source.Id, nil
+	}
+	return "", "", nil
+}
+
+// BatchInspect returns the given volumes keyed by volume ID. The block storage
+// API cannot filter volumes by ID, so each volume is fetched separately.
+func (o *oracleOps) BatchInspect(volumeIds []*string) (map[string]interface{}, error) {
+	return cloudops.InspectVolumes(o, volumeIds)
+}
+
+func (o *oracleOps) AdoptVolume(volumeID string, labels map[string]string) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "AdoptVolume",
+	}
+}
+
+func (o *oracleOps) DeleteSnapshotChain(snapIDs []string) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "DeleteSnapshotChain",
+	}
+}
+
+// GetStoragePressure returns the size of the volumes attached to the given
+// instance. Block volumes do not report the space they consume, so only the
+// provisioned size is known.
+func (o *oracleOps) GetStoragePressure(instanceID string) ([]cloudops.VolumePressureInfo, error) {
+	volumeAttachmentReq := core.ListVolumeAttachmentsRequest{
+		CompartmentId: common.String(o.compartmentID),
+		InstanceId:    common.String(instanceID),
+	}
+	volumeAttachmentResp, err := o.compute.ListVolumeAttachments(context.Background(), volumeAttachmentReq)
+	if err != nil {
+		return nil, err
+	}
+
+	pressure := []cloudops.VolumePressureInfo{}
+	for _, va := range volumeAttachmentResp.Items {
+		if va.GetLifecycleState() != core.VolumeAttachmentLifecycleStateAttached || va.GetVolumeId() == nil {
+			continue
+		}
+		getVolResp, err := o.storage.GetVolume(context.Background(), core.GetVolumeRequest{
+			VolumeId: va.GetVolumeId(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		info := cloudops.VolumePressureInfo{
+			VolumeID: *va.GetVolumeId(),
+		}
+		if getVolResp.SizeInGBs != nil {
+			info.ProvisionedBytes = uint64(*getVolResp.SizeInGBs) * bytesPerGB
+		}
+		pressure = append(pressure, info)
+	}
+	return pressure, nil
+}
+
+func (o *oracleOps) CheckVolumeQuota(required []*cloudops.StoragePoolSpec) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "CheckVolumeQuota",
+	}
+}
+
+func (o *oracleOps) ListVolumesPaged(labels map[string]string, pageSize int) (cloudops.VolumeIterator, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "ListVolumesPaged",
+	}
+}
+
+func (o *oracleOps) AttachByInstanceID(instanceID, volumeID string, options map[string]string) (string, error) {
+	return "", &cloudops.ErrNotSupported{
+		Operation: "AttachByInstanceID",
+	}
+}
+
+func (o *oracleOps) DeleteVolumes(volumeIDs []string) (map[string]error, error) {
+	return cloudops.DeleteVolumes(o.Delete, isFatalError, volumeIDs)
+}
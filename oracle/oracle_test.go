@@ -1,14 +1,20 @@
 package oracle
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/libopenstorage/cloudops"
 	"github.com/libopenstorage/cloudops/test"
 	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/containerengine"
 	"github.com/oracle/oci-go-sdk/v65/core"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -52,3 +58,433 @@ func sizeCheck(template interface{}, targetSize uint64) bool {
 	// TODO: implement it right way
 	return true
 }
+
+func TestNetworkInfoFromVnics(t *testing.T) {
+	vnics := []core.Vnic{
+		{
+			IsPrimary: common.Bool(false),
+			SubnetId:  common.String("ocid1.subnet.secondary"),
+			PrivateIp: common.String("10.0.1.2"),
+		},
+		{
+			IsPrimary: common.Bool(true),
+			SubnetId:  common.String("ocid1.subnet.primary"),
+			PrivateIp: common.String("10.0.0.2"),
+		},
+	}
+
+	networkInfo := networkInfoFromVnics(vnics)
+	require.Equal(t, "ocid1.subnet.primary", networkInfo.Subnet)
+	require.Equal(t, []string{"10.0.1.2", "10.0.0.2"}, networkInfo.PrivateIPs)
+}
+
+func TestCreateEnforcesMinSize(t *testing.T) {
+	o := &oracleOps{}
+	_, err := o.Create(&core.Volume{
+		SizeInGBs: common.Int64(10),
+	}, nil, map[string]string{cloudops.MinSizePolicyOption: cloudops.MinSizePolicyError})
+	require.Error(t, err)
+	se, ok := err.(*cloudops.StorageError)
+	require.True(t, ok)
+	require.Equal(t, cloudops.ErrVolInval, se.Code)
+}
+
+func TestNodePoolMembers(t *testing.T) {
+	nodes := []containerengine.Node{
+		{Id: common.String("ocid1.instance.1"), LifecycleState: containerengine.NodeLifecycleStateActive},
+		{Id: common.String("ocid1.instance.2"), LifecycleState: containerengine.NodeLifecycleStateUpdating},
+		{Id: common.String("ocid1.instance.3"), LifecycleState: containerengine.NodeLifecycleStateDeleted},
+		{Id: common.String("ocid1.instance.4"), LifecycleState: containerengine.NodeLifecycleStateCreating},
+	}
+
+	require.Equal(t, []string{"ocid1.instance.1", "ocid1.instance.2", "ocid1.instance.4"}, nodePoolMembers(nodes))
+}
+
+func TestInitialNodeLabels(t *testing.T) {
+	labels := initialNodeLabels(map[string]string{
+		"storage": "true",
+		"app":     "px",
+	})
+	require.Equal(t, []containerengine.KeyValue{
+		{Key: common.String("app"), Value: common.String("px")},
+		{Key: common.String("storage"), Value: common.String("true")},
+	}, labels)
+}
+
+// fakeServiceError is an OCI service error with the given HTTP status code
+type fakeServiceError struct {
+	statusCode int
+	code       string
+}
+
+func (f fakeServiceError) GetHTTPStatusCode() int  { return f.statusCode }
+func (f fakeServiceError) GetMessage() string      { return "fake service error" }
+func (f fakeServiceError) GetCode() string         { return f.code }
+func (f fakeServiceError) GetOpcRequestID() string { return "request" }
+func (f fakeServiceError) Error() string           { return f.GetMessage() }
+
+func TestIsExponentialError(t *testing.T) {
+	require.True(t, isExponentialError(fakeServiceError{statusCode: 429, code: "TooManyRequests"}))
+	require.True(t, isExponentialError(fakeServiceError{statusCode: 500, code: "InternalServerError"}))
+	require.True(t, isExponentialError(fakeServiceError{statusCode: 503, code: "ServiceUnavailable"}))
+	require.False(t, isExponentialError(fakeServiceError{statusCode: 404, code: "NotAuthorizedOrNotFound"}))
+	require.False(t, isExponentialError(fmt.Errorf("TooManyRequests")))
+	require.False(t, isExponentialError(nil))
+}
+
+func TestAttachWaitsForAttachedState(t *testing.T) {
+	const (
+		volumeID     = "ocid1.volume.test"
+		attachmentID = "ocid1.volumeattachment.test"
+		device       = "/dev/oracleoci/oraclevdb"
+	)
+
+	polls := 0
+	attachment := func(state string) string {
+		return fmt.Sprintf(`{"attachmentType": "paravirtualized", "id": %q, "instanceId": "instance",
+			"volumeId": %q, "device": %q, "lifecycleState": %q}`, attachmentID, volumeID, device, state)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/20160918/instances/instance/devices", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `[{"name": %q, "isAvailable": true}]`, device)
+	})
+	mux.HandleFunc("/20160918/volumeAttachments", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		// the device is reported while the attachment is still attaching
+		fmt.Fprint(w, attachment("ATTACHING"))
+	})
+	mux.HandleFunc("/20160918/volumeAttachments/"+attachmentID, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		polls++
+		state := "ATTACHING"
+		if polls > 2 {
+			state = "ATTACHED"
+		}
+		fmt.Fprint(w, attachment(state))
+	})
+
+	o := newTestOracleOps(t, mux)
+	o.instance = "instance"
+	o.opsTimeout = cloudops.OpsTimeoutConfig{
+		Timeout:       5 * time.Second,
+		RetryInterval: 10 * time.Millisecond,
+	}
+
+	devicePath, err := o.Attach(volumeID, nil)
+	require.NoError(t, err)
+	require.Equal(t, device, devicePath)
+	require.Equal(t, 3, polls)
+
+	// an attachment which never gets attached fails the attach instead of
+	// returning the device path
+	polls = -1000
+	o.opsTimeout.Timeout = 50 * time.Millisecond
+	_, err = o.Attach(volumeID, nil)
+	require.Error(t, err)
+}
+
+func TestCloneVolume(t *testing.T) {
+	const (
+		sourceID = "ocid1.volume.source"
+		cloneID  = "ocid1.volume.clone"
+	)
+
+	var details core.CreateVolumeDetails
+	mux := http.NewServeMux()
+	mux.HandleFunc("/20160918/volumes", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&details))
+		writeJSON(t, w, &core.Volume{
+			Id:             common.String(cloneID),
+			LifecycleState: core.VolumeLifecycleStateProvisioning,
+		})
+	})
+	mux.HandleFunc("/20160918/volumes/"+cloneID, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, &core.Volume{
+			Id:             common.String(cloneID),
+			LifecycleState: core.VolumeLifecycleStateAvailable,
+		})
+	})
+
+	o := newTestOracleOps(t, mux)
+	o.compartmentID = "compartment"
+	o.availabilityDomain = "ad-1"
+	o.opsTimeout = cloudops.OpsTimeoutConfig{
+		Timeout:       5 * time.Second,
+		RetryInterval: 10 * time.Millisecond,
+	}
+
+	vol, err := o.CloneVolume(sourceID, &core.Volume{
+		DisplayName: common.String("clone"),
+	}, map[string]string{"app": "db"})
+	require.NoError(t, err)
+	require.Equal(t, cloneID, *vol.(*core.Volume).Id)
+
+	source, ok := details.SourceDetails.(core.VolumeSourceFromVolumeDetails)
+	require.True(t, ok, "expected a volume source, got %T", details.SourceDetails)
+	require.Equal(t, sourceID, *source.Id)
+	require.Nil(t, details.SizeInGBs, "clone should default to the size of the source volume")
+	require.Equal(t, "clone", *details.DisplayName)
+	require.Equal(t, map[string]string{"app": "db"}, details.FreeformTags)
+}
+
+func TestExpand(t *testing.T) {
+	const volumeID = "ocid1.volume.test"
+
+	sizeInGBs := int64(100)
+	updates := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/20160918/volumes/"+volumeID, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			details := &core.UpdateVolumeDetails{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(details))
+			sizeInGBs = *details.SizeInGBs
+			updates++
+		default:
+			t.Errorf("unexpected method %s", r.Method)
+		}
+		writeJSON(t, w, &core.Volume{
+			Id:             common.String(volumeID),
+			SizeInGBs:      common.Int64(sizeInGBs),
+			LifecycleState: core.VolumeLifecycleStateAvailable,
+		})
+	})
+
+	o := newTestOracleOps(t, mux)
+
+	for _, size := range []uint64{50, 100} {
+		newSize, err := o.Expand(volumeID, size, nil)
+		require.Error(t, err)
+		storageErr, ok := err.(*cloudops.StorageError)
+		require.True(t, ok)
+		require.Equal(t, cloudops.ErrDiskGreaterOrEqualToExpandSize, storageErr.Code)
+		require.Equal(t, uint64(100), newSize)
+	}
+	require.Zero(t, updates)
+
+	newSize, err := o.Expand(volumeID, 150, nil)
+	require.NoError(t, err)
+	require.Equal(t, uint64(150), newSize)
+	require.Equal(t, 1, updates)
+}
+
+func TestGetInstanceGroupZones(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/20180222/nodePools", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "cluster", r.URL.Query().Get("clusterId"))
+		if r.URL.Query().Get("name") != "pool" {
+			writeJSON(t, w, []containerengine.NodePoolSummary{})
+			return
+		}
+		writeJSON(t, w, []containerengine.NodePoolSummary{{
+			Id:   common.String("ocid1.nodepool.test"),
+			Name: common.String("pool"),
+			NodeConfigDetails: &containerengine.NodePoolNodeConfigDetails{
+				PlacementConfigs: []containerengine.NodePoolPlacementConfigDetails{
+					{AvailabilityDomain: common.String("AD-1"), SubnetId: common.String("subnet")},
+					{AvailabilityDomain: common.String("AD-2"), SubnetId: common.String("subnet")},
+				},
+			},
+		}})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	o := &oracleOps{
+		compartmentID: "compartment",
+		clusterID:     "cluster",
+		containerEngine: containerengine.ContainerEngineClient{BaseClient: common.BaseClient{
+			HTTPClient: server.Client(),
+			Signer:     unsignedSigner{},
+			Host:       server.URL,
+			BasePath:   "20180222",
+			UserAgent:  "cloudops-test",
+		}},
+	}
+
+	zones, err := o.GetInstanceGroupZones("pool")
+	require.NoError(t, err)
+	require.Equal(t, []string{"AD-1", "AD-2"}, zones)
+
+	_, err = o.GetInstanceGroupZones("missing")
+	require.Error(t, err)
+}
+
+func TestGetVolumeLineage(t *testing.T) {
+	sources := map[string]string{
+		"from-backup": `{"type": "volumeBackup", "id": "ocid1.volumebackup.test"}`,
+		"from-volume": `{"type": "volume", "id": "ocid1.volume.source"}`,
+		"empty":       `null`,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/20160918/volumes/", func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Path[len("/20160918/volumes/"):]
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id": %q, "sourceDetails": %s}`, id, sources[id])
+	})
+	o := newTestOracleOps(t, mux)
+
+	cases := []struct {
+		id         string
+		sourceType string
+		sourceID   string
+	}{
+		{"from-backup", cloudops.VolumeSourceSnapshot, "ocid1.volumebackup.test"},
+		{"from-volume", cloudops.VolumeSourceClone, "ocid1.volume.source"},
+		{"empty", "", ""},
+	}
+	for _, c := range cases {
+		sourceType, sourceID, err := o.GetVolumeLineage(c.id)
+		require.NoError(t, err, c.id)
+		require.Equal(t, c.sourceType, sourceType, c.id)
+		require.Equal(t, c.sourceID, sourceID, c.id)
+	}
+}
+
+// unsignedSigner skips request signing for requests sent to a test server
+type unsignedSigner struct{}
+
+func (unsignedSigner) Sign(r *http.Request) error { return nil }
+
+// newTestOracleOps returns an oracleOps whose block storage and compute
+// clients talk to the given handler
+func newTestOracleOps(t *testing.T, handler http.Handler) *oracleOps {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	baseClient := common.BaseClient{
+		HTTPClient: server.Client(),
+		Signer:     unsignedSigner{},
+		Host:       server.URL,
+		BasePath:   "20160918",
+		UserAgent:  "cloudops-test",
+	}
+	return &oracleOps{
+		storage:                 core.BlockstorageClient{BaseClient: baseClient},
+		compute:                 core.ComputeClient{BaseClient: baseClient},
+		volumeAttachmentMapping: map[string]*string{},
+	}
+}
+
+func writeJSON(t *testing.T, w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	require.NoError(t, json.NewEncoder(w).Encode(v))
+}
+
+func TestSnapshot(t *testing.T) {
+	const (
+		volumeID = "ocid1.volume.test"
+		backupID = "ocid1.volumebackup.test"
+	)
+
+	var (
+		polls   int
+		deleted bool
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/20160918/volumeBackups", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		details := &core.CreateVolumeBackupDetails{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(details))
+		require.Equal(t, volumeID, *details.VolumeId)
+
+		writeJSON(t, w, &core.VolumeBackup{
+			Id:             common.String(backupID),
+			VolumeId:       common.String(volumeID),
+			LifecycleState: core.VolumeBackupLifecycleStateCreating,
+		})
+	})
+	mux.HandleFunc("/20160918/volumeBackups/"+backupID, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			polls++
+			writeJSON(t, w, &core.VolumeBackup{
+				Id:             common.String(backupID),
+				VolumeId:       common.String(volumeID),
+				LifecycleState: core.VolumeBackupLifecycleStateAvailable,
+			})
+		case http.MethodDelete:
+			deleted = true
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected method %s", r.Method)
+		}
+	})
+
+	o := newTestOracleOps(t, mux)
+
+	snap, err := o.Snapshot(volumeID, true, map[string]string{cloudops.SnapshotWaitOption: "false"})
+	require.NoError(t, err)
+	require.Zero(t, polls)
+	require.Equal(t, core.VolumeBackupLifecycleStateCreating, snap.(*core.VolumeBackup).LifecycleState)
+
+	snap, err = o.Snapshot(volumeID, true, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, polls)
+	require.Equal(t, core.VolumeBackupLifecycleStateAvailable, snap.(*core.VolumeBackup).LifecycleState)
+
+	snapID, err := o.GetDeviceID(snap)
+	require.NoError(t, err)
+	require.Equal(t, backupID, snapID)
+
+	require.NoError(t, o.SnapshotDelete(snapID, nil))
+	require.True(t, deleted)
+}
+
+func TestWaitForVolumeState(t *testing.T) {
+	const volumeID = "ocid1.volume.test"
+
+	states := []core.VolumeLifecycleStateEnum{
+		core.VolumeLifecycleStateProvisioning,
+		core.VolumeLifecycleStateAvailable,
+	}
+	var gets int
+	attached := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/20160918/volumes/"+volumeID, func(w http.ResponseWriter, r *http.Request) {
+		state := states[len(states)-1]
+		if gets < len(states) {
+			state = states[gets]
+		}
+		gets++
+		writeJSON(t, w, &core.Volume{Id: common.String(volumeID), LifecycleState: state})
+	})
+	mux.HandleFunc("/20160918/volumeAttachments/", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, volumeID, r.URL.Query().Get("volumeId"))
+		state := "DETACHED"
+		if attached {
+			state = "ATTACHED"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"attachmentType": "paravirtualized", "id": "ocid1.volumeattachment.test",
+			"volumeId": "` + volumeID + `", "lifecycleState": "` + state + `"}]`))
+	})
+
+	o := newTestOracleOps(t, mux)
+	o.compartmentID = "compartment"
+	o.opsTimeout = cloudops.OpsTimeoutConfig{RetryInterval: time.Millisecond}
+
+	require.NoError(t, o.WaitForVolumeState(volumeID, cloudops.VolumeStateAvailable, time.Second))
+	require.Equal(t, 2, gets)
+
+	require.Error(t, o.WaitForVolumeState(volumeID, cloudops.VolumeStateAttached, 20*time.Millisecond))
+	attached = true
+	require.NoError(t, o.WaitForVolumeState(volumeID, cloudops.VolumeStateAttached, time.Second))
+
+	states = []core.VolumeLifecycleStateEnum{core.VolumeLifecycleStateFaulty}
+	gets = 0
+	require.Error(t, o.WaitForVolumeState(volumeID, cloudops.VolumeStateAvailable, time.Second))
+	require.Equal(t, 1, gets)
+}
+
+func TestEmptyVolumeID(t *testing.T) {
+	ops := newTestOracleOps(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+
+	test.RunEmptyVolumeIDTest(t, ops)
+}
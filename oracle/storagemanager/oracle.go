@@ -23,15 +23,16 @@ func NewStorageManager(decisionMatrix cloudops.StorageDecisionMatrix) (cloudops.
 func (o *oracleStorageManager) GetStorageDistribution(
 	request *cloudops.StorageDistributionRequest,
 ) (*cloudops.StorageDistributionResponse, error) {
+	decisionMatrix := storagedistribution.FilterForRequest(o.decisionMatrix, request)
 	response := &cloudops.StorageDistributionResponse{}
 	var currentDriveType string
-	for _, userRequest := range request.UserStorageSpec {
+	for _, userRequest := range storagedistribution.UserStorageSpecs(request) {
 		currentDriveType = userRequest.DriveType
 		// for request, find how many instances per zone needs to have storage
 		// and the storage spec for each of them
 		instStorage, instancePerZone, row, err :=
 			storagedistribution.GetStorageDistributionForPool(
-				o.decisionMatrix,
+				decisionMatrix,
 				userRequest,
 				request.InstancesPerZone,
 				request.ZoneCount,
@@ -50,8 +51,12 @@ func (o *oracleStorageManager) GetStorageDistribution(
 				InstancesPerZone: instancePerZone,
 				DriveCount:       instStorage.DriveCount,
 				IOPS:             determineIOPSForPool(instStorage, row),
+				ThinProvisioning: instStorage.ThinProvisioning,
 			},
 		)
+		if request.IncludeDecisionMatrixRows {
+			response.DecisionMatrixRows = append(response.DecisionMatrixRows, row)
+		}
 	}
 	return response, nil
 }
@@ -76,7 +81,14 @@ func (o *oracleStorageManager) GetMaxDriveSize(
 	return resp, err
 }
 
+func (o *oracleStorageManager) ValidateStorageSpec(spec *cloudops.StorageSpec) error {
+	return storagedistribution.ValidateStorageSpec(spec, o.decisionMatrix)
+}
+
 func determineIOPSForPool(instStorage *cloudops.StoragePoolSpec, row *cloudops.StorageDecisionMatrixRow) uint64 {
+	if iops, ok := storagedistribution.DetermineIOPSForPool(instStorage, row); ok {
+		return iops
+	}
 	var iopsPerGB, maxIopsPerVol int64
 	switch row.DriveType {
 	case "pv-0":
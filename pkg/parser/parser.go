@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 
 	"github.com/libopenstorage/cloudops"
@@ -8,7 +10,7 @@ import (
 )
 
 // StorageDecisionMatrixParser parses a cloud storage decision matrix from yamls
-// to StorageDecisionMatrix objects defined in cloudops
+// or jsons to StorageDecisionMatrix objects defined in cloudops
 type StorageDecisionMatrixParser interface {
 	// MarshalToYaml marshals the provided StorageDecisionMatrix
 	// to a yaml file at the provided path
@@ -20,6 +22,16 @@ type StorageDecisionMatrixParser interface {
 	MarshalToBytes(*cloudops.StorageDecisionMatrix) ([]byte, error)
 	// UnmarshalFromBytes unmarshals the given yaml bytes into a StorageDecisionMatrix
 	UnmarshalFromBytes([]byte) (*cloudops.StorageDecisionMatrix, error)
+	// MarshalToJSON marshals the provided StorageDecisionMatrix
+	// to a json file at the provided path
+	MarshalToJSON(*cloudops.StorageDecisionMatrix, string) error
+	// UnmarshalFromJSONFile unmarshals the json file at the provided path
+	// into a StorageDecisionMatrix
+	UnmarshalFromJSONFile(string) (*cloudops.StorageDecisionMatrix, error)
+	// MarshalToJSONBytes marshals the provided StorageDecisionMatrix to json bytes
+	MarshalToJSONBytes(*cloudops.StorageDecisionMatrix) ([]byte, error)
+	// UnmarshalFromJSON unmarshals the given json bytes into a StorageDecisionMatrix
+	UnmarshalFromJSON([]byte) (*cloudops.StorageDecisionMatrix, error)
 }
 
 // NewStorageDecisionMatrixParser returns an implementation of StorageDecisionMatrixParser
@@ -59,5 +71,46 @@ func (s *sdmParser) UnmarshalFromBytes(yamlBytes []byte) (*cloudops.StorageDecis
 	if err := yaml.Unmarshal(yamlBytes, matrix); err != nil {
 		return nil, err
 	}
+	return validate(matrix)
+}
+
+func (s *sdmParser) MarshalToJSON(
+	matrix *cloudops.StorageDecisionMatrix,
+	filePath string,
+) error {
+	jsonBytes, err := s.MarshalToJSONBytes(matrix)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filePath, jsonBytes, 0777)
+}
+
+func (s *sdmParser) UnmarshalFromJSONFile(
+	filePath string,
+) (*cloudops.StorageDecisionMatrix, error) {
+	jsonBytes, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return s.UnmarshalFromJSON(jsonBytes)
+}
+
+func (s *sdmParser) MarshalToJSONBytes(matrix *cloudops.StorageDecisionMatrix) ([]byte, error) {
+	return json.MarshalIndent(matrix, "", "  ")
+}
+
+func (s *sdmParser) UnmarshalFromJSON(jsonBytes []byte) (*cloudops.StorageDecisionMatrix, error) {
+	matrix := &cloudops.StorageDecisionMatrix{}
+	if err := json.Unmarshal(jsonBytes, matrix); err != nil {
+		return nil, err
+	}
+	return validate(matrix)
+}
+
+// validate returns the given matrix if its rows are valid
+func validate(matrix *cloudops.StorageDecisionMatrix) (*cloudops.StorageDecisionMatrix, error) {
+	if err := matrix.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid storage decision matrix: %v", err)
+	}
 	return matrix, nil
 }
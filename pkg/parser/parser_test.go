@@ -12,6 +12,7 @@ import (
 
 const (
 	testYamlFilePath     = "/tmp/cloudops-test.yaml"
+	testJSONFilePath     = "/tmp/cloudops-test.json"
 	existingYamlFilePath = "testspecs/test.yaml"
 )
 
@@ -23,12 +24,16 @@ func TestStorageDecisionMatrixParser(t *testing.T) {
 				MinSize:      uint64(100),
 				MaxSize:      uint64(200),
 				InstanceType: "foo",
+				DriveType:    "gp2",
 			},
 			cloudops.StorageDecisionMatrixRow{
 				MinIOPS:      uint64(2000),
+				MaxIOPS:      uint64(8000),
+				IOPSPerGiB:   float64(6),
 				MinSize:      uint64(200),
 				MaxSize:      uint64(400),
 				InstanceType: "bar",
+				DriveType:    "io1",
 			},
 		},
 	}
@@ -41,6 +46,32 @@ func TestStorageDecisionMatrixParser(t *testing.T) {
 	require.True(t, reflect.DeepEqual(inputMatrix, *actualMatrix), "Unequal matrices %v %v", inputMatrix, *actualMatrix)
 }
 
+func TestStorageDecisionMatrixParserJSON(t *testing.T) {
+	p := NewStorageDecisionMatrixParser()
+	inputMatrix, err := p.UnmarshalFromYaml(existingYamlFilePath)
+	require.NoError(t, err, "Unexpected error on UnmarshalFromYaml")
+
+	err = p.MarshalToJSON(inputMatrix, testJSONFilePath)
+	require.NoError(t, err, "Unexpected error on MarshalToJSON")
+	jsonMatrix, err := p.UnmarshalFromJSONFile(testJSONFilePath)
+	require.NoError(t, err, "Unexpected error on UnmarshalFromJSONFile")
+	require.True(t, reflect.DeepEqual(inputMatrix, jsonMatrix), "Unequal matrices %v %v", inputMatrix, jsonMatrix)
+
+	// json -> yaml -> json round-trip
+	yamlBytes, err := p.MarshalToBytes(jsonMatrix)
+	require.NoError(t, err, "Unexpected error on MarshalToBytes")
+	yamlMatrix, err := p.UnmarshalFromBytes(yamlBytes)
+	require.NoError(t, err, "Unexpected error on UnmarshalFromBytes")
+	jsonBytes, err := p.MarshalToJSONBytes(yamlMatrix)
+	require.NoError(t, err, "Unexpected error on MarshalToJSONBytes")
+	actualMatrix, err := p.UnmarshalFromJSON(jsonBytes)
+	require.NoError(t, err, "Unexpected error on UnmarshalFromJSON")
+	require.True(t, reflect.DeepEqual(inputMatrix, actualMatrix), "Unequal matrices %v %v", inputMatrix, actualMatrix)
+
+	_, err = p.UnmarshalFromJSON([]byte(`{"rows": [{"min_size": 200, "max_size": 100}]}`))
+	require.Error(t, err, "Expected an error for an invalid matrix")
+}
+
 func TestStorageDecisionMatrixParserWithExistingYaml(t *testing.T) {
 
 	expectedMatrix := cloudops.StorageDecisionMatrix{
@@ -89,3 +120,82 @@ func TestStorageDecisionMatrixParserWithExistingYaml(t *testing.T) {
 	require.True(t, reflect.DeepEqual(expectedMatrix, *actualMatrix), "Unequal matrices %v %v", expectedMatrix, *actualMatrix)
 
 }
+
+func TestUnmarshalInvalidMatrix(t *testing.T) {
+	const validRow = `
+  - drive_type: gp3
+    min_iops: 3000
+    max_iops: 16000
+    min_size: 32
+    max_size: 16000
+    instance_min_drives: 1
+    instance_max_drives: 8
+    priority: 0
+`
+	testCases := []struct {
+		name  string
+		row   string
+		error string
+	}{
+		{
+			name: "min size greater than max size",
+			row: `
+  - drive_type: gp2
+    min_size: 200
+    max_size: 100
+`,
+			error: "min_size 200 is greater than max_size 100",
+		},
+		{
+			name: "zero max size",
+			row: `
+  - drive_type: gp2
+    min_size: 0
+`,
+			error: "max_size is 0",
+		},
+		{
+			name: "min drives greater than max drives",
+			row: `
+  - drive_type: gp2
+    max_size: 100
+    instance_min_drives: 4
+    instance_max_drives: 2
+`,
+			error: "instance_min_drives 4 is greater than instance_max_drives 2",
+		},
+		{
+			name: "negative priority",
+			row: `
+  - drive_type: gp2
+    max_size: 100
+    priority: -1
+`,
+			error: "priority -1 is negative",
+		},
+		{
+			name:  "duplicate drive type and iops",
+			row:   validRow,
+			error: "row 1 (gp3): duplicates the drive type and IOPS range 3000-16000 of row 0",
+		},
+	}
+
+	p := NewStorageDecisionMatrixParser()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := p.UnmarshalFromBytes([]byte("rows:" + validRow + tc.row))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.error)
+		})
+	}
+
+	// the same drive type and iops over another size range is a separate tier
+	_, err := p.UnmarshalFromBytes([]byte("rows:" + validRow + `
+  - drive_type: gp3
+    min_iops: 3000
+    max_iops: 16000
+    min_size: 24
+    max_size: 32
+`))
+	require.NoError(t, err)
+}
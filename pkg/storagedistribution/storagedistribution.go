@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 	"sort"
+	"strings"
 
 	"github.com/libopenstorage/cloudops"
 	"github.com/libopenstorage/cloudops/pkg/utils"
@@ -22,10 +23,10 @@ import (
   - Minimum capacity for the whole cluster.
   - Number of zones in the cluster.
   - Number of instances in the cluster.
+  - Instance type and region of the instances.
   - A storage decision matrix.
 
   TODO:
-   - Take into account instance types and their supported drives
    - Take into account the effect on the overall throughput when multiple drives are attached
      on the same instance.
 */
@@ -36,7 +37,8 @@ import (
 // - Resize existing disks
 // - Add more disks
 // This is based of the ResizeOperationType input argument. If no such input is
-// provided then this function tries Resize first and then an Add.
+// provided then this function tries Resize first and then an Add, in which case
+// the response carries the reason Resize was rejected.
 // The algorithms for Resize and Add are explained with their respective function
 // definitions.
 func GetStorageUpdateConfig(
@@ -55,10 +57,16 @@ func GetStorageUpdateConfig(
 	default:
 		// Auto-mode. Try resize first then add
 		resp, row, err := ResizeDisk(request, decisionMatrix)
+		if err == nil {
+			return resp, row, err
+		}
+		resizeErr := err
+		resp, row, err = AddDisk(request, decisionMatrix)
 		if err != nil {
-			return AddDisk(request, decisionMatrix)
+			return resp, row, err
 		}
-		return resp, row, err
+		resp.ResizeRejectedReason = resizeRejectedReason(resizeErr)
+		return resp, row, nil
 	}
 }
 
@@ -154,6 +162,7 @@ func AddDisk(
 		DriveType:        row.DriveType,
 		DriveCapacityGiB: currentDriveSize,
 		DriveCount:       uint64(requiredDriveCount),
+		ThinProvisioning: row.ThinProvisioning,
 	}
 	prettyPrintStoragePoolSpec(instStorage, "AddDisk")
 	resp := &cloudops.StoragePoolUpdateResponse{
@@ -241,6 +250,7 @@ func ResizeDisk(
 			DriveType:        row.DriveType,
 			DriveCapacityGiB: request.CurrentDriveSize + deltaCapacityPerDrive,
 			DriveCount:       request.CurrentDriveCount,
+			ThinProvisioning: row.ThinProvisioning,
 		}
 		prettyPrintStoragePoolSpec(instStorage, "ResizeDisk")
 		resp := &cloudops.StoragePoolUpdateResponse{
@@ -255,6 +265,15 @@ func ResizeDisk(
 	}
 }
 
+// resizeRejectedReason returns the reason in the given ResizeDisk error
+func resizeRejectedReason(err error) string {
+	if notFoundErr, ok := err.(*cloudops.ErrStorageDistributionCandidateNotFound); ok &&
+		len(notFoundErr.Reason) > 0 {
+		return notFoundErr.Reason
+	}
+	return err.Error()
+}
+
 func calculateDriveCapacity(request *cloudops.StoragePoolUpdateRequest) uint64 {
 	currentCapacity := request.CurrentDriveCount * request.CurrentDriveSize
 	deltaCapacity := request.DesiredCapacity - currentCapacity
@@ -321,65 +340,344 @@ func GetStorageDistributionForPool(
 	// Calculate min and max capacity per zone
 	minCapacityPerZone := request.MinCapacity / uint64(zoneCount)
 	maxCapacityPerZone := request.MaxCapacity / uint64(zoneCount)
+
+	for _, row := range dm.Rows {
+		instStorage, instancesPerZone, found := storageDistributionForRow(
+			row,
+			requestedInstancesPerZone,
+			minCapacityPerZone,
+			maxCapacityPerZone,
+		)
+		if !found {
+			continue
+		}
+		prettyPrintStoragePoolSpec(instStorage, "getStorageDistributionCandidate returning")
+		return instStorage, instancesPerZone, &row, nil
+	}
+
+	// row_loop failed
+	return nil, 0, nil, &cloudops.ErrStorageDistributionCandidateNotFound{}
+}
+
+// GetStorageDistributionCandidates returns all the viable storage pool specs
+// for each of the user storage specs in the request, in the same order as the
+// user storage specs. The candidates of a user storage spec are ranked in the
+// order GetStorageDistributionForPool considers the decision matrix rows, so
+// the first candidate is its pick. The IOPS of a candidate is the minimum IOPS
+// of its decision matrix row.
+func GetStorageDistributionCandidates(
+	decisionMatrix *cloudops.StorageDecisionMatrix,
+	request *cloudops.StorageDistributionRequest,
+) ([][]*cloudops.StoragePoolSpec, error) {
+	if request.ZoneCount <= 0 {
+		return nil, cloudops.ErrNumOfZonesCannotBeZero
+	}
+
+	userStorageSpecs := UserStorageSpecs(request)
+	candidates := make([][]*cloudops.StoragePoolSpec, 0, len(userStorageSpecs))
+	for _, userRequest := range userStorageSpecs {
+		logDistributionRequest(userRequest, request.InstancesPerZone, request.ZoneCount)
+
+		dm := FilterForRequest(decisionMatrix, request)
+		dm.FilterByDriveType(userRequest.DriveType).
+			FilterByIOPS(userRequest.IOPS).
+			SortByIOPS().
+			SortByPriority()
+
+		minCapacityPerZone := userRequest.MinCapacity / request.ZoneCount
+		maxCapacityPerZone := userRequest.MaxCapacity / request.ZoneCount
+		specCandidates := make([]*cloudops.StoragePoolSpec, 0)
+		for _, row := range dm.Rows {
+			instStorage, instancesPerZone, found := storageDistributionForRow(
+				row,
+				request.InstancesPerZone,
+				minCapacityPerZone,
+				maxCapacityPerZone,
+			)
+			if !found {
+				continue
+			}
+			instStorage.InstancesPerZone = instancesPerZone
+			instStorage.IOPS = row.MinIOPS
+			specCandidates = append(specCandidates, instStorage)
+		}
+
+		if len(specCandidates) == 0 {
+			return nil, &cloudops.ErrStorageDistributionCandidateNotFound{}
+		}
+		candidates = append(candidates, specCandidates)
+	}
+	return candidates, nil
+}
+
+// ExplainStorageDistribution evaluates every row of the decision matrix for
+// each of the user storage specs in the request. The evaluations of a spec are
+// ranked in the order GetStorageDistributionForPool considers the rows, so the
+// row it picks is the selected one, followed by the rows the request filters
+// out in decision matrix order. If no row can be picked for one of the specs,
+// the evaluations are returned along with an
+// ErrStorageDistributionCandidateNotFound naming the specs.
+func ExplainStorageDistribution(
+	decisionMatrix *cloudops.StorageDecisionMatrix,
+	request *cloudops.StorageDistributionRequest,
+) ([]cloudops.CandidateEvaluation, error) {
+	if request.ZoneCount <= 0 {
+		return nil, cloudops.ErrNumOfZonesCannotBeZero
+	}
+
 	var (
-		capacityPerNode, instancesPerZone, driveCount, driveSize uint64
-		row                                                      cloudops.StorageDecisionMatrixRow
-		rowIndex                                                 uint64
+		evaluations []cloudops.CandidateEvaluation
+		notFound    []string
 	)
+	for _, userRequest := range UserStorageSpecs(request) {
+		specEvaluations, selected := explainStorageDistributionForPool(decisionMatrix, userRequest, request)
+		if !selected {
+			notFound = append(notFound, fmt.Sprintf("%d GiB of %q drives with %d IOPS",
+				userRequest.MinCapacity, userRequest.DriveType, userRequest.IOPS))
+		}
+		evaluations = append(evaluations, specEvaluations...)
+	}
 
-row_loop:
-	for rowIndex = uint64(0); rowIndex < uint64(len(dm.Rows)); rowIndex++ {
-		row = dm.Rows[rowIndex]
-		// Favour maximum instances per zone
-	instances_per_zone_loop:
-		for instancesPerZone = requestedInstancesPerZone; instancesPerZone > 0; instancesPerZone-- {
-			capacityPerNode = minCapacityPerZone / uint64(instancesPerZone)
-			printCandidates("Candidate", []cloudops.StorageDecisionMatrixRow{row}, instancesPerZone, capacityPerNode)
-			// Favour maximum drive count
-			// drive_count_loop:
-			foundCandidate := false
-			for driveCount = row.InstanceMaxDrives; driveCount >= row.InstanceMinDrives; driveCount-- {
-				driveSize = capacityPerNode / driveCount
-				if driveSize >= row.MinSize && driveSize <= row.MaxSize {
-					// Found a candidate
-					foundCandidate = true
-					break
-				}
-				if driveCount == row.InstanceMinDrives {
-					// We have exhausted the drive_count_loop
-					if driveSize < row.MinSize {
-						// If the last calculated driveSize is less than row.MinSize
-						// that indicates none of the driveSizes in the drive_count_loop
-						// were greater than row.MinSize. Lets try with row.MinSize
-						driveSize = row.MinSize
-						driveCount = row.InstanceMinDrives
-						if driveSize*instancesPerZone < maxCapacityPerZone {
-							// Found a candidate
-							foundCandidate = true
-							break
-						}
+	if len(notFound) > 0 {
+		return evaluations, &cloudops.ErrStorageDistributionCandidateNotFound{
+			Reason: fmt.Sprintf("no decision matrix row can provide %s", strings.Join(notFound, ", ")),
+		}
+	}
+	return evaluations, nil
+}
+
+// explainStorageDistributionForPool evaluates every row of the decision matrix
+// for the given user storage spec and returns whether a row was selected
+func explainStorageDistributionForPool(
+	decisionMatrix *cloudops.StorageDecisionMatrix,
+	userRequest *cloudops.StorageSpec,
+	request *cloudops.StorageDistributionRequest,
+) ([]cloudops.CandidateEvaluation, bool) {
+	evaluations := make([]cloudops.CandidateEvaluation, 0, len(decisionMatrix.Rows))
+
+	// Rank the rows the same way GetStorageDistributionForPool does
+	dm := FilterForRequest(decisionMatrix, request)
+	dm.FilterByDriveType(userRequest.DriveType).
+		FilterByIOPS(userRequest.IOPS).
+		SortByIOPS().
+		SortByPriority()
+
+	minCapacityPerZone := userRequest.MinCapacity / request.ZoneCount
+	maxCapacityPerZone := userRequest.MaxCapacity / request.ZoneCount
+	selected := false
+	for _, row := range dm.Rows {
+		evaluation := cloudops.CandidateEvaluation{
+			UserStorageSpec: userRequest,
+			Row:             row,
+		}
+		instStorage, instancesPerZone, found := storageDistributionForRow(
+			row,
+			request.InstancesPerZone,
+			minCapacityPerZone,
+			maxCapacityPerZone,
+		)
+		switch {
+		case !found:
+			evaluation.RejectionReason, evaluation.Details = capacityRejection(
+				row, request.InstancesPerZone, minCapacityPerZone, maxCapacityPerZone)
+		case selected:
+			evaluation.RejectionReason = cloudops.CandidateRejectedOutranked
+			evaluation.Details = "a higher ranked row was selected"
+		default:
+			selected = true
+			evaluation.Selected = true
+		}
+		if found {
+			instStorage.InstancesPerZone = instancesPerZone
+			instStorage.IOPS = row.MinIOPS
+			evaluation.Candidate = instStorage
+		}
+		evaluations = append(evaluations, evaluation)
+	}
+
+	// The rows filtered out by the request, in decision matrix order
+	for _, row := range decisionMatrix.Rows {
+		reason, details, rejected := filterRejection(row, userRequest, request)
+		if !rejected {
+			continue
+		}
+		evaluations = append(evaluations, cloudops.CandidateEvaluation{
+			UserStorageSpec: userRequest,
+			Row:             row,
+			RejectionReason: reason,
+			Details:         details,
+		})
+	}
+	return evaluations, selected
+}
+
+// filterRejection returns why the given row is filtered out for the user
+// storage spec, or false if the row passes the filters
+func filterRejection(
+	row cloudops.StorageDecisionMatrixRow,
+	userRequest *cloudops.StorageSpec,
+	request *cloudops.StorageDistributionRequest,
+) (cloudops.CandidateRejectionReason, string, bool) {
+	single := func() *cloudops.StorageDecisionMatrix {
+		return &cloudops.StorageDecisionMatrix{Rows: []cloudops.StorageDecisionMatrixRow{row}}
+	}
+	if len(single().FilterByInstanceType(request.InstanceType).Rows) == 0 {
+		return cloudops.CandidateRejectedInstanceType,
+			fmt.Sprintf("instance type %s is not the instance type %s of the request", row.InstanceType, request.InstanceType), true
+	}
+	if len(single().FilterByRegion(request.Region).Rows) == 0 {
+		return cloudops.CandidateRejectedRegion,
+			fmt.Sprintf("region %s is not the region %s of the request", row.Region, request.Region), true
+	}
+	if len(single().FilterByZoneAvailability(request.ZoneDriveTypes).Rows) == 0 {
+		return cloudops.CandidateRejectedZoneAvailability,
+			fmt.Sprintf("drive type %s is not available in all the zones", row.DriveType), true
+	}
+	if len(single().FilterByDriveType(userRequest.DriveType).Rows) == 0 {
+		return cloudops.CandidateRejectedDriveType,
+			fmt.Sprintf("drive type %s is not the requested drive type %s", row.DriveType, userRequest.DriveType), true
+	}
+	if len(single().FilterByIOPS(userRequest.IOPS).Rows) == 0 {
+		return cloudops.CandidateRejectedIOPS,
+			fmt.Sprintf("max IOPS %d is lower than the requested IOPS %d", row.MaxIOPS, userRequest.IOPS), true
+	}
+	return "", "", false
+}
+
+// capacityRejection returns why storageDistributionForRow found no drive
+// configuration of the given row for the capacity of a zone
+func capacityRejection(
+	row cloudops.StorageDecisionMatrixRow,
+	requestedInstancesPerZone uint64,
+	minCapacityPerZone uint64,
+	maxCapacityPerZone uint64,
+) (cloudops.CandidateRejectionReason, string) {
+	if requestedInstancesPerZone == 0 {
+		return cloudops.CandidateRejectedDistribution, "no instances per zone were requested"
+	}
+	if maxRowCapacity := row.MaxSize * row.InstanceMaxDrives * requestedInstancesPerZone; minCapacityPerZone > maxRowCapacity {
+		return cloudops.CandidateRejectedSize, fmt.Sprintf(
+			"%d GiB per zone exceeds the %d GiB of %d instances with %d drives of at most %d GiB",
+			minCapacityPerZone, maxRowCapacity, requestedInstancesPerZone, row.InstanceMaxDrives, row.MaxSize)
+	}
+	if row.InstanceMinDrives > 0 && minCapacityPerZone/row.InstanceMinDrives < row.MinSize {
+		return cloudops.CandidateRejectedSize, fmt.Sprintf(
+			"%d GiB per zone is too small for %d drives of at least %d GiB within the max capacity of %d GiB per zone",
+			minCapacityPerZone, row.InstanceMinDrives, row.MinSize, maxCapacityPerZone)
+	}
+	return cloudops.CandidateRejectedDistribution, fmt.Sprintf(
+		"%d GiB per zone cannot be split across up to %d instances with %d to %d drives of %d to %d GiB",
+		minCapacityPerZone, requestedInstancesPerZone, row.InstanceMinDrives, row.InstanceMaxDrives,
+		row.MinSize, row.MaxSize)
+}
+
+// FilterByZoneAvailability returns a copy of the decision matrix without the
+// rows whose drive type is not available in all of the zones of the
+// StorageDistributionRequest ZoneDriveTypes.
+func FilterByZoneAvailability(
+	decisionMatrix *cloudops.StorageDecisionMatrix,
+	zoneDriveTypes map[string][]string,
+) *cloudops.StorageDecisionMatrix {
+	return utils.CopyDecisionMatrix(decisionMatrix).FilterByZoneAvailability(zoneDriveTypes)
+}
+
+// FilterForRequest returns a copy of the decision matrix with only the rows
+// which apply to the instance type and region of the request, and whose drive
+// type is available in all of the zones of the request. Rows which do not set
+// an instance type or region apply to all of them, so matrices without these
+// columns are only filtered by zone availability.
+func FilterForRequest(
+	decisionMatrix *cloudops.StorageDecisionMatrix,
+	request *cloudops.StorageDistributionRequest,
+) *cloudops.StorageDecisionMatrix {
+	return FilterByZoneAvailability(decisionMatrix, request.ZoneDriveTypes).
+		FilterByInstanceType(request.InstanceType).
+		FilterByRegion(request.Region)
+}
+
+// UserStorageSpecs returns the user storage specs of the request to find a
+// storage pool for. If MergeCompatibleSpecs is set, the specs with the same
+// drive type and IOPS are merged into a single spec, in the order of their
+// first occurrence, with the sum of their capacities.
+func UserStorageSpecs(request *cloudops.StorageDistributionRequest) []*cloudops.StorageSpec {
+	if !request.MergeCompatibleSpecs {
+		return request.UserStorageSpec
+	}
+
+	type specKey struct {
+		driveType string
+		iops      uint64
+	}
+	merged := make([]*cloudops.StorageSpec, 0, len(request.UserStorageSpec))
+	mergedByKey := make(map[specKey]*cloudops.StorageSpec)
+	for _, spec := range request.UserStorageSpec {
+		key := specKey{driveType: spec.DriveType, iops: spec.IOPS}
+		if existing, ok := mergedByKey[key]; ok {
+			existing.MinCapacity += spec.MinCapacity
+			existing.MaxCapacity += spec.MaxCapacity
+			continue
+		}
+		specCopy := *spec
+		mergedByKey[key] = &specCopy
+		merged = append(merged, &specCopy)
+	}
+	return merged
+}
+
+// storageDistributionForRow tries to find a drive configuration from the given
+// decision matrix row which meets the capacity requirements of a zone. It
+// returns the storage pool spec and the optimized number of instances per zone
+// if one is found.
+func storageDistributionForRow(
+	row cloudops.StorageDecisionMatrixRow,
+	requestedInstancesPerZone uint64,
+	minCapacityPerZone uint64,
+	maxCapacityPerZone uint64,
+) (*cloudops.StoragePoolSpec, uint64, bool) {
+	var capacityPerNode, instancesPerZone, driveCount, driveSize uint64
+
+	// Favour maximum instances per zone
+instances_per_zone_loop:
+	for instancesPerZone = requestedInstancesPerZone; instancesPerZone > 0; instancesPerZone-- {
+		capacityPerNode = minCapacityPerZone / uint64(instancesPerZone)
+		printCandidates("Candidate", []cloudops.StorageDecisionMatrixRow{row}, instancesPerZone, capacityPerNode)
+		// Favour maximum drive count
+		// drive_count_loop:
+		foundCandidate := false
+		for driveCount = row.InstanceMaxDrives; driveCount >= row.InstanceMinDrives; driveCount-- {
+			driveSize = capacityPerNode / driveCount
+			if driveSize >= row.MinSize && driveSize <= row.MaxSize {
+				// Found a candidate
+				foundCandidate = true
+				break
+			}
+			if driveCount == row.InstanceMinDrives {
+				// We have exhausted the drive_count_loop
+				if driveSize < row.MinSize {
+					// If the last calculated driveSize is less than row.MinSize
+					// that indicates none of the driveSizes in the drive_count_loop
+					// were greater than row.MinSize. Lets try with row.MinSize
+					driveSize = row.MinSize
+					driveCount = row.InstanceMinDrives
+					if driveSize*instancesPerZone < maxCapacityPerZone {
+						// Found a candidate
+						foundCandidate = true
+						break
 					}
 				}
 			}
-
-			if !foundCandidate {
-				// drive_count_loop failed
-				continue instances_per_zone_loop
-			}
-			break instances_per_zone_loop
 		}
 
-		if instancesPerZone == 0 {
-			// instances_per_zone_loop failed
-			continue row_loop
+		if !foundCandidate {
+			// drive_count_loop failed
+			continue instances_per_zone_loop
 		}
-		// break row_loop
-		break row_loop
+		break instances_per_zone_loop
 	}
 
-	if int(rowIndex) == len(dm.Rows) {
-		// row_loop failed
-		return nil, 0, nil, &cloudops.ErrStorageDistributionCandidateNotFound{}
+	if instancesPerZone == 0 {
+		// instances_per_zone_loop failed
+		return nil, 0, false
 	}
 
 	// optimize instances per zone
@@ -392,14 +690,35 @@ row_loop:
 		}
 		break
 	}
-	instStorage := &cloudops.StoragePoolSpec{
+	return &cloudops.StoragePoolSpec{
 		DriveType:        row.DriveType,
 		DriveCapacityGiB: driveSize,
 		DriveCount:       driveCount,
+		ThinProvisioning: row.ThinProvisioning,
+	}, optimizedInstancesPerZone, true
+}
+
+// DetermineIOPSForPool returns the iops of the drives of the storage pool spec
+// based on the IOPSPerGiB of the decision matrix row it was picked from,
+// bounded by the MinIOPS and MaxIOPS of the row. It returns false if the row
+// does not set IOPSPerGiB, in which case the cloud provider's own iops model
+// should be used.
+func DetermineIOPSForPool(
+	instStorage *cloudops.StoragePoolSpec,
+	row *cloudops.StorageDecisionMatrixRow,
+) (uint64, bool) {
+	if row == nil || row.IOPSPerGiB <= 0 {
+		return 0, false
 	}
-	prettyPrintStoragePoolSpec(instStorage, "getStorageDistributionCandidate returning")
-	return instStorage, optimizedInstancesPerZone, &row, nil
 
+	iops := uint64(math.Ceil(float64(instStorage.DriveCapacityGiB) * row.IOPSPerGiB))
+	if iops < row.MinIOPS {
+		iops = row.MinIOPS
+	}
+	if row.MaxIOPS > 0 && iops > row.MaxIOPS {
+		iops = row.MaxIOPS
+	}
+	return iops, true
 }
 
 // GetMaxDriveSize returns the max drive size given an input
@@ -435,6 +754,94 @@ func GetMaxDriveSize(
 	}, nil
 }
 
+// ValidateStorageSpec checks if the given spec can be satisfied by the decision
+// matrix and returns an ErrUnsatisfiableStorageSpec naming the first constraint
+// which cannot be met. The checks are:
+//   - At least one row must match the requested drive type
+//   - At least one of those rows must provide the requested IOPS
+//   - MinCapacity must not exceed MaxCapacity and the smallest drive
+//     configuration of the matching rows must fit within MaxCapacity
+//   - MinCapacity must not exceed the largest drive configuration of the
+//     matching rows, i.e. MaxSize * InstanceMaxDrives
+func ValidateStorageSpec(
+	spec *cloudops.StorageSpec,
+	decisionMatrix *cloudops.StorageDecisionMatrix,
+) error {
+	if spec == nil {
+		return &cloudops.ErrUnsatisfiableStorageSpec{
+			Reason: "empty storage spec",
+		}
+	}
+
+	if spec.MaxCapacity > 0 && spec.MinCapacity > spec.MaxCapacity {
+		return &cloudops.ErrUnsatisfiableStorageSpec{
+			Spec:       spec,
+			Constraint: cloudops.StorageSpecConstraintCapacity,
+			Reason: fmt.Sprintf("min capacity %d GiB is greater than max capacity %d GiB",
+				spec.MinCapacity, spec.MaxCapacity),
+		}
+	}
+
+	dm := utils.CopyDecisionMatrix(decisionMatrix)
+	dm.FilterByDriveType(spec.DriveType)
+	if len(dm.Rows) == 0 {
+		return &cloudops.ErrUnsatisfiableStorageSpec{
+			Spec:       spec,
+			Constraint: cloudops.StorageSpecConstraintDriveType,
+			Reason:     fmt.Sprintf("no decision matrix rows found for drive type %s", spec.DriveType),
+		}
+	}
+
+	maxIOPS := uint64(0)
+	for _, row := range dm.Rows {
+		if row.MaxIOPS > maxIOPS {
+			maxIOPS = row.MaxIOPS
+		}
+	}
+	dm.FilterByIOPS(spec.IOPS)
+	if len(dm.Rows) == 0 {
+		return &cloudops.ErrUnsatisfiableStorageSpec{
+			Spec:       spec,
+			Constraint: cloudops.StorageSpecConstraintIOPS,
+			Reason: fmt.Sprintf("requested IOPS %d is greater than the max achievable IOPS %d",
+				spec.IOPS, maxIOPS),
+		}
+	}
+
+	maxCapacity := uint64(0)
+	for _, row := range dm.Rows {
+		if rowMaxCapacity := row.MaxSize * row.InstanceMaxDrives; rowMaxCapacity > maxCapacity {
+			maxCapacity = rowMaxCapacity
+		}
+	}
+	if spec.MinCapacity > maxCapacity {
+		return &cloudops.ErrUnsatisfiableStorageSpec{
+			Spec:       spec,
+			Constraint: cloudops.StorageSpecConstraintCapacity,
+			Reason: fmt.Sprintf("min capacity %d GiB is greater than the largest drive configuration of %d GiB",
+				spec.MinCapacity, maxCapacity),
+		}
+	}
+
+	if spec.MaxCapacity > 0 {
+		minCapacity := uint64(math.MaxUint64)
+		for _, row := range dm.Rows {
+			if rowMinCapacity := row.MinSize * row.InstanceMinDrives; rowMinCapacity < minCapacity {
+				minCapacity = rowMinCapacity
+			}
+		}
+		if spec.MaxCapacity < minCapacity {
+			return &cloudops.ErrUnsatisfiableStorageSpec{
+				Spec:       spec,
+				Constraint: cloudops.StorageSpecConstraintCapacity,
+				Reason: fmt.Sprintf("max capacity %d GiB is less than the smallest drive configuration of %d GiB",
+					spec.MaxCapacity, minCapacity),
+			}
+		}
+	}
+	return nil
+}
+
 // validateUpdateRequest validates the StoragePoolUpdateRequest
 func validateUpdateRequest(
 	request *cloudops.StoragePoolUpdateRequest,
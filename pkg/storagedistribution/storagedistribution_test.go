@@ -2,6 +2,7 @@ package storagedistribution
 
 import (
 	"github.com/libopenstorage/cloudops"
+	"github.com/libopenstorage/openstorage/api"
 	"github.com/stretchr/testify/require"
 	"testing"
 )
@@ -41,3 +42,434 @@ func TestCalculateDriveCapacity(t *testing.T) {
 		}
 	}
 }
+
+func TestGetStorageUpdateConfigResizeRejectedReason(t *testing.T) {
+	decisionMatrix := &cloudops.StorageDecisionMatrix{
+		Rows: []cloudops.StorageDecisionMatrixRow{
+			{
+				DriveType:         "pd-ssd",
+				MinIOPS:           0,
+				MaxIOPS:           1000,
+				MinSize:           100,
+				MaxSize:           200,
+				InstanceMinDrives: 1,
+				InstanceMaxDrives: 8,
+			},
+		},
+	}
+	request := &cloudops.StoragePoolUpdateRequest{
+		DesiredCapacity:     300,
+		ResizeOperationType: api.SdkStoragePool_RESIZE_TYPE_AUTO,
+		CurrentDriveCount:   1,
+		CurrentIOPS:         500,
+		CurrentDriveSize:    150,
+		CurrentDriveType:    "pd-ssd",
+		TotalDrivesOnNode:   1,
+	}
+
+	// Resizing the drive to 300 GiB exceeds the MaxSize of the row, so a drive is added
+	resp, _, err := GetStorageUpdateConfig(request, decisionMatrix)
+	require.NoError(t, err)
+	require.Equal(t, api.SdkStoragePool_RESIZE_TYPE_ADD_DISK, resp.ResizeOperationType)
+	require.Len(t, resp.InstanceStorage, 1)
+	require.Equal(t, uint64(1), resp.InstanceStorage[0].DriveCount)
+	require.Equal(t, uint64(150), resp.InstanceStorage[0].DriveCapacityGiB)
+	require.Contains(t, resp.ResizeRejectedReason, "cannot reach target drive size of 300")
+	require.Contains(t, resp.ResizeRejectedReason, "max supported drive size for drive type pd-ssd: 200")
+
+	// No reason is given when the resize succeeds
+	request.DesiredCapacity = 200
+	resp, _, err = GetStorageUpdateConfig(request, decisionMatrix)
+	require.NoError(t, err)
+	require.Equal(t, api.SdkStoragePool_RESIZE_TYPE_RESIZE_DISK, resp.ResizeOperationType)
+	require.Empty(t, resp.ResizeRejectedReason)
+}
+
+func TestGetStorageDistributionCandidates(t *testing.T) {
+	decisionMatrix := &cloudops.StorageDecisionMatrix{
+		Rows: []cloudops.StorageDecisionMatrixRow{
+			{
+				DriveType:         "pd-balanced",
+				MinIOPS:           1000,
+				MaxIOPS:           10000,
+				MinSize:           50,
+				MaxSize:           1000,
+				InstanceMinDrives: 1,
+				InstanceMaxDrives: 2,
+				Priority:          1,
+			},
+			{
+				DriveType:         "pd-ssd",
+				MinIOPS:           3000,
+				MaxIOPS:           30000,
+				MinSize:           100,
+				MaxSize:           500,
+				InstanceMinDrives: 1,
+				InstanceMaxDrives: 4,
+				Priority:          0,
+			},
+			{
+				// Drives are too large for the requested capacity
+				DriveType:         "pd-standard",
+				MinIOPS:           500,
+				MaxIOPS:           5000,
+				MinSize:           2000,
+				MaxSize:           4000,
+				InstanceMinDrives: 1,
+				InstanceMaxDrives: 1,
+				Priority:          2,
+			},
+		},
+	}
+	request := &cloudops.StorageDistributionRequest{
+		UserStorageSpec: []*cloudops.StorageSpec{
+			{
+				MinCapacity: 600,
+				MaxCapacity: 1200,
+			},
+		},
+		InstancesPerZone: 3,
+		ZoneCount:        1,
+	}
+
+	candidates, err := GetStorageDistributionCandidates(decisionMatrix, request)
+	require.NoError(t, err)
+	require.Len(t, candidates, 1)
+	require.Len(t, candidates[0], 2)
+	require.Equal(t, "pd-ssd", candidates[0][0].DriveType)
+	require.Equal(t, uint64(3000), candidates[0][0].IOPS)
+	require.Equal(t, "pd-balanced", candidates[0][1].DriveType)
+	require.Equal(t, uint64(1000), candidates[0][1].IOPS)
+	for _, candidate := range candidates[0] {
+		require.GreaterOrEqual(t,
+			candidate.InstancesPerZone*candidate.DriveCount*candidate.DriveCapacityGiB,
+			request.UserStorageSpec[0].MinCapacity)
+	}
+
+	// The top candidate is the pick of GetStorageDistributionForPool
+	instStorage, instancesPerZone, _, err := GetStorageDistributionForPool(
+		decisionMatrix, request.UserStorageSpec[0], request.InstancesPerZone, request.ZoneCount)
+	require.NoError(t, err)
+	require.Equal(t, instStorage.DriveType, candidates[0][0].DriveType)
+	require.Equal(t, instStorage.DriveCount, candidates[0][0].DriveCount)
+	require.Equal(t, instStorage.DriveCapacityGiB, candidates[0][0].DriveCapacityGiB)
+	require.Equal(t, instancesPerZone, candidates[0][0].InstancesPerZone)
+
+	// No candidates for a drive type which cannot satisfy the request
+	request.UserStorageSpec[0].DriveType = "pd-standard"
+	_, err = GetStorageDistributionCandidates(decisionMatrix, request)
+	require.Error(t, err)
+	_, ok := err.(*cloudops.ErrStorageDistributionCandidateNotFound)
+	require.True(t, ok)
+}
+
+func TestZoneDriveTypeAvailability(t *testing.T) {
+	decisionMatrix := &cloudops.StorageDecisionMatrix{
+		Rows: []cloudops.StorageDecisionMatrixRow{
+			{
+				DriveType:         "Premium_LRS",
+				MinIOPS:           3000,
+				MaxIOPS:           20000,
+				MinSize:           100,
+				MaxSize:           1000,
+				InstanceMinDrives: 1,
+				InstanceMaxDrives: 4,
+				Priority:          0,
+			},
+			{
+				DriveType:         "StandardSSD_LRS",
+				MinIOPS:           500,
+				MaxIOPS:           6000,
+				MinSize:           100,
+				MaxSize:           1000,
+				InstanceMinDrives: 1,
+				InstanceMaxDrives: 4,
+				Priority:          1,
+			},
+		},
+	}
+	request := &cloudops.StorageDistributionRequest{
+		UserStorageSpec: []*cloudops.StorageSpec{
+			{
+				MinCapacity: 900,
+				MaxCapacity: 1800,
+			},
+		},
+		InstancesPerZone: 1,
+		ZoneCount:        3,
+		// Premium_LRS is not available in zone 3
+		ZoneDriveTypes: map[string][]string{
+			"1": {"Premium_LRS", "StandardSSD_LRS"},
+			"2": {"Premium_LRS", "StandardSSD_LRS"},
+			"3": {"StandardSSD_LRS"},
+		},
+	}
+
+	// Without the zone availability Premium_LRS is recommended
+	instStorage, _, _, err := GetStorageDistributionForPool(
+		decisionMatrix, request.UserStorageSpec[0], request.InstancesPerZone, request.ZoneCount)
+	require.NoError(t, err)
+	require.Equal(t, "Premium_LRS", instStorage.DriveType)
+
+	dm := FilterByZoneAvailability(decisionMatrix, request.ZoneDriveTypes)
+	require.Len(t, decisionMatrix.Rows, 2, "the decision matrix should not be modified")
+	instStorage, _, _, err = GetStorageDistributionForPool(
+		dm, request.UserStorageSpec[0], request.InstancesPerZone, request.ZoneCount)
+	require.NoError(t, err)
+	require.Equal(t, "StandardSSD_LRS", instStorage.DriveType)
+
+	candidates, err := GetStorageDistributionCandidates(decisionMatrix, request)
+	require.NoError(t, err)
+	require.Len(t, candidates, 1)
+	require.Len(t, candidates[0], 1)
+	require.Equal(t, "StandardSSD_LRS", candidates[0][0].DriveType)
+
+	// Requesting a drive type missing in a zone has no candidates
+	request.UserStorageSpec[0].DriveType = "Premium_LRS"
+	_, err = GetStorageDistributionCandidates(decisionMatrix, request)
+	_, ok := err.(*cloudops.ErrStorageDistributionCandidateNotFound)
+	require.True(t, ok, "expected ErrStorageDistributionCandidateNotFound, got %v", err)
+}
+
+func TestDetermineIOPSForPool(t *testing.T) {
+	row := &cloudops.StorageDecisionMatrixRow{
+		DriveType: "pd-extreme",
+		MinIOPS:   1000,
+		MaxIOPS:   6000,
+	}
+
+	// The provider's model applies if the row has no iops per GiB
+	_, ok := DetermineIOPSForPool(&cloudops.StoragePoolSpec{DriveCapacityGiB: 100}, row)
+	require.False(t, ok)
+	_, ok = DetermineIOPSForPool(&cloudops.StoragePoolSpec{DriveCapacityGiB: 100}, nil)
+	require.False(t, ok)
+
+	row.IOPSPerGiB = 7.5
+	iops, ok := DetermineIOPSForPool(&cloudops.StoragePoolSpec{DriveCapacityGiB: 201}, row)
+	require.True(t, ok)
+	require.Equal(t, uint64(1508), iops)
+
+	// The iops are bounded by the row's min and max iops
+	iops, _ = DetermineIOPSForPool(&cloudops.StoragePoolSpec{DriveCapacityGiB: 10}, row)
+	require.Equal(t, row.MinIOPS, iops)
+	iops, _ = DetermineIOPSForPool(&cloudops.StoragePoolSpec{DriveCapacityGiB: 1000}, row)
+	require.Equal(t, row.MaxIOPS, iops)
+}
+
+func TestMergeCompatibleSpecs(t *testing.T) {
+	decisionMatrix := &cloudops.StorageDecisionMatrix{
+		Rows: []cloudops.StorageDecisionMatrixRow{
+			{
+				DriveType:         "Premium_LRS",
+				MinIOPS:           3000,
+				MaxIOPS:           20000,
+				MinSize:           100,
+				MaxSize:           1000,
+				InstanceMinDrives: 1,
+				InstanceMaxDrives: 4,
+			},
+		},
+	}
+	request := &cloudops.StorageDistributionRequest{
+		UserStorageSpec: []*cloudops.StorageSpec{
+			{DriveType: "Premium_LRS", IOPS: 3000, MinCapacity: 300, MaxCapacity: 600},
+			{DriveType: "Premium_LRS", IOPS: 3000, MinCapacity: 600, MaxCapacity: 1200},
+		},
+		InstancesPerZone: 1,
+		ZoneCount:        3,
+	}
+
+	// By default each spec is kept separate
+	specs := UserStorageSpecs(request)
+	require.Len(t, specs, 2)
+	candidates, err := GetStorageDistributionCandidates(decisionMatrix, request)
+	require.NoError(t, err)
+	require.Len(t, candidates, 2)
+
+	request.MergeCompatibleSpecs = true
+	specs = UserStorageSpecs(request)
+	require.Len(t, specs, 1)
+	require.Equal(t, uint64(900), specs[0].MinCapacity)
+	require.Equal(t, uint64(1800), specs[0].MaxCapacity)
+	require.Equal(t, uint64(300), request.UserStorageSpec[0].MinCapacity, "the request should not be modified")
+
+	candidates, err = GetStorageDistributionCandidates(decisionMatrix, request)
+	require.NoError(t, err)
+	require.Len(t, candidates, 1)
+	pool := candidates[0][0]
+	require.Equal(t, "Premium_LRS", pool.DriveType)
+	require.Equal(t, uint64(300), pool.DriveCapacityGiB*pool.DriveCount*pool.InstancesPerZone)
+
+	// Specs with a different IOPS are not merged
+	request.UserStorageSpec[1].IOPS = 5000
+	require.Len(t, UserStorageSpecs(request), 2)
+}
+
+func TestExplainStorageDistribution(t *testing.T) {
+	decisionMatrix := &cloudops.StorageDecisionMatrix{
+		Rows: []cloudops.StorageDecisionMatrixRow{
+			{DriveType: "slow", MaxIOPS: 300, MinSize: 100, MaxSize: 1000, InstanceMinDrives: 1, InstanceMaxDrives: 8},
+			{DriveType: "small", MaxIOPS: 1000, MinSize: 10, MaxSize: 50, InstanceMinDrives: 1, InstanceMaxDrives: 2},
+			{DriveType: "good", MaxIOPS: 1000, MinSize: 100, MaxSize: 200, InstanceMinDrives: 1, InstanceMaxDrives: 4, Priority: 1},
+			{DriveType: "better", MaxIOPS: 2000, MinSize: 100, MaxSize: 1000, InstanceMinDrives: 1, InstanceMaxDrives: 8, Priority: 1},
+			{DriveType: "remote", MaxIOPS: 2000, MinSize: 100, MaxSize: 1000, InstanceMinDrives: 1, InstanceMaxDrives: 8},
+		},
+	}
+	request := &cloudops.StorageDistributionRequest{
+		UserStorageSpec: []*cloudops.StorageSpec{
+			{IOPS: 500, MinCapacity: 600, MaxCapacity: 6000},
+		},
+		InstancesPerZone: 3,
+		ZoneCount:        1,
+		ZoneDriveTypes:   map[string][]string{"zone": {"slow", "small", "good", "better"}},
+	}
+
+	evaluations, err := ExplainStorageDistribution(decisionMatrix, request)
+	require.NoError(t, err)
+	require.Len(t, evaluations, 5)
+
+	type result struct {
+		driveType string
+		selected  bool
+		reason    cloudops.CandidateRejectionReason
+	}
+	results := make([]result, 0, len(evaluations))
+	for _, e := range evaluations {
+		results = append(results, result{e.Row.DriveType, e.Selected, e.RejectionReason})
+	}
+	require.Equal(t, []result{
+		{"small", false, cloudops.CandidateRejectedSize},
+		{"good", true, ""},
+		{"better", false, cloudops.CandidateRejectedOutranked},
+		{"slow", false, cloudops.CandidateRejectedIOPS},
+		{"remote", false, cloudops.CandidateRejectedZoneAvailability},
+	}, results)
+
+	// the selected row is the one GetStorageDistributionForPool picks
+	instStorage, instancesPerZone, row, err := GetStorageDistributionForPool(
+		FilterByZoneAvailability(decisionMatrix, request.ZoneDriveTypes),
+		request.UserStorageSpec[0], request.InstancesPerZone, request.ZoneCount)
+	require.NoError(t, err)
+	require.Equal(t, *row, evaluations[1].Row)
+	require.Equal(t, instStorage.DriveCount, evaluations[1].Candidate.DriveCount)
+	require.Equal(t, instStorage.DriveCapacityGiB, evaluations[1].Candidate.DriveCapacityGiB)
+	require.Equal(t, instancesPerZone, evaluations[1].Candidate.InstancesPerZone)
+
+	// no row can hold the capacity, the evaluations explain why
+	request.UserStorageSpec[0].MinCapacity = 100000
+	request.UserStorageSpec[0].MaxCapacity = 200000
+	evaluations, err = ExplainStorageDistribution(decisionMatrix, request)
+	require.Error(t, err)
+	_, ok := err.(*cloudops.ErrStorageDistributionCandidateNotFound)
+	require.True(t, ok)
+	require.Len(t, evaluations, 5)
+	for _, e := range evaluations[:3] {
+		require.False(t, e.Selected)
+		require.Equal(t, cloudops.CandidateRejectedSize, e.RejectionReason, e.Row.DriveType)
+		require.NotEmpty(t, e.Details)
+	}
+
+	request.ZoneCount = 0
+	_, err = ExplainStorageDistribution(decisionMatrix, request)
+	require.Equal(t, cloudops.ErrNumOfZonesCannotBeZero, err)
+}
+
+func TestRegionScopedDecisionMatrix(t *testing.T) {
+	decisionMatrix := &cloudops.StorageDecisionMatrix{
+		Rows: []cloudops.StorageDecisionMatrixRow{
+			{DriveType: "gp2", Region: "us-east-1", InstanceType: "*", MaxIOPS: 3000,
+				MinSize: 100, MaxSize: 1000, InstanceMinDrives: 1, InstanceMaxDrives: 4},
+			{DriveType: "gp3", Region: "eu-west-1", InstanceType: "*", MaxIOPS: 3000,
+				MinSize: 100, MaxSize: 1000, InstanceMinDrives: 1, InstanceMaxDrives: 4},
+			{DriveType: "io2", Region: "*", InstanceType: "m5.large", MaxIOPS: 3000,
+				MinSize: 100, MaxSize: 1000, InstanceMinDrives: 1, InstanceMaxDrives: 4, Priority: 1},
+			{DriveType: "st1", MaxIOPS: 3000,
+				MinSize: 100, MaxSize: 1000, InstanceMinDrives: 1, InstanceMaxDrives: 4, Priority: 2},
+		},
+	}
+	request := &cloudops.StorageDistributionRequest{
+		UserStorageSpec: []*cloudops.StorageSpec{
+			{MinCapacity: 900, MaxCapacity: 1800},
+		},
+		InstanceType:     "m5.large",
+		Region:           "eu-west-1",
+		InstancesPerZone: 1,
+		ZoneCount:        3,
+	}
+
+	driveTypes := func(dm *cloudops.StorageDecisionMatrix) []string {
+		var types []string
+		for _, row := range dm.Rows {
+			types = append(types, row.DriveType)
+		}
+		return types
+	}
+
+	// rows of other regions are filtered out, rows without a region or with
+	// the "*" wildcard apply to all of them
+	dm := FilterForRequest(decisionMatrix, request)
+	require.Len(t, decisionMatrix.Rows, 4, "the decision matrix should not be modified")
+	require.Equal(t, []string{"gp3", "io2", "st1"}, driveTypes(dm))
+
+	candidates, err := GetStorageDistributionCandidates(decisionMatrix, request)
+	require.NoError(t, err)
+	require.Len(t, candidates, 1)
+	require.Equal(t, "gp3", candidates[0][0].DriveType)
+
+	request.Region = "us-east-1"
+	request.InstanceType = "c5.large"
+	require.Equal(t, []string{"gp2", "st1"}, driveTypes(FilterForRequest(decisionMatrix, request)))
+
+	// without a region and instance type in the request no rows are filtered out
+	request.Region = ""
+	request.InstanceType = ""
+	require.Equal(t, []string{"gp2", "gp3", "io2", "st1"}, driveTypes(FilterForRequest(decisionMatrix, request)))
+
+	// the rows filtered out by region or instance type are explained
+	request.Region = "ap-south-1"
+	request.InstanceType = "c5.large"
+	evaluations, err := ExplainStorageDistribution(decisionMatrix, request)
+	require.NoError(t, err)
+	reasons := make(map[string]cloudops.CandidateRejectionReason)
+	for _, e := range evaluations {
+		reasons[e.Row.DriveType] = e.RejectionReason
+		if e.Selected {
+			require.Equal(t, "st1", e.Row.DriveType)
+		}
+	}
+	require.Equal(t, map[string]cloudops.CandidateRejectionReason{
+		"gp2": cloudops.CandidateRejectedRegion,
+		"gp3": cloudops.CandidateRejectedRegion,
+		"io2": cloudops.CandidateRejectedInstanceType,
+		"st1": "",
+	}, reasons)
+
+	// no row applies to the region of the request
+	decisionMatrix.Rows = decisionMatrix.Rows[:2]
+	_, err = GetStorageDistributionCandidates(decisionMatrix, request)
+	_, ok := err.(*cloudops.ErrStorageDistributionCandidateNotFound)
+	require.True(t, ok, "expected ErrStorageDistributionCandidateNotFound, got %v", err)
+}
+
+func TestValidateStorageSpecMaxCapacity(t *testing.T) {
+	decisionMatrix := &cloudops.StorageDecisionMatrix{
+		Rows: []cloudops.StorageDecisionMatrixRow{
+			{DriveType: "small", MaxIOPS: 1000, MinSize: 10, MaxSize: 500, InstanceMinDrives: 1, InstanceMaxDrives: 4},
+			{DriveType: "large", MaxIOPS: 1000, MinSize: 100, MaxSize: 1000, InstanceMinDrives: 1, InstanceMaxDrives: 8},
+		},
+	}
+
+	// the largest configuration is 8 drives of 1000 GiB
+	require.NoError(t, ValidateStorageSpec(&cloudops.StorageSpec{MinCapacity: 8000}, decisionMatrix))
+
+	err := ValidateStorageSpec(&cloudops.StorageSpec{MinCapacity: 8001}, decisionMatrix)
+	specErr, ok := err.(*cloudops.ErrUnsatisfiableStorageSpec)
+	require.True(t, ok, "received unexpected type of error: %v", err)
+	require.Equal(t, cloudops.StorageSpecConstraintCapacity, specErr.Constraint)
+
+	// only the rows of the requested drive type are considered
+	err = ValidateStorageSpec(&cloudops.StorageSpec{DriveType: "small", MinCapacity: 2001}, decisionMatrix)
+	specErr, ok = err.(*cloudops.ErrUnsatisfiableStorageSpec)
+	require.True(t, ok, "received unexpected type of error: %v", err)
+	require.Equal(t, cloudops.StorageSpecConstraintCapacity, specErr.Constraint)
+}
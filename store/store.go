@@ -70,6 +70,10 @@ type Store interface {
 	Unlock(storeLock *Lock) error
 	// LockWithKey locks the cloud drive store with an arbitrary key
 	LockWithKey(owner, key string) (*Lock, error)
+	// LockWithTTL locks the cloud drive store with an arbitrary key for at most
+	// ttl. Once the ttl has passed the lock is treated as free, so a crashed
+	// owner does not hold the key forever.
+	LockWithTTL(owner, key string, ttl time.Duration) (*Lock, error)
 	// IsKeyLocked checks if the specified key is currently locked
 	IsKeyLocked(key string) (bool, string, error)
 	// CreateKey creates the given key with the value. lockAs argument is used as an owner to lock the key.
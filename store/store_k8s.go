@@ -1,6 +1,7 @@
 package store
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
 	"regexp"
@@ -19,6 +20,11 @@ const (
 	waitDuration   = 2 * time.Second
 	waitFactor     = 1.5
 	waitSteps      = 5
+	// ttlLockKeyPrefix prefixes the configmap key holding the lease for a
+	// lock taken with LockWithTTL.
+	ttlLockKeyPrefix = "px-ttl-lock-"
+	// ttlLockRetryInterval is the time between attempts to take a ttl lock
+	ttlLockRetryInterval = 1 * time.Second
 )
 
 // GetSanitizedK8sName will sanitize the name conforming to RFC 1123 standards so that it's a "qualified name" per k8s
@@ -66,7 +72,15 @@ var (
 )
 
 type k8sStore struct {
-	cm configmap.ConfigMap
+	cm              configmap.ConfigMap
+	lockTryDuration time.Duration
+}
+
+// ttlLock is the lease stamped into the configmap for a lock taken with
+// LockWithTTL.
+type ttlLock struct {
+	Owner      string    `json:"owner"`
+	Expiration time.Time `json:"expiration"`
 }
 
 // NewK8sStore returns a Store implementation which uses
@@ -102,7 +116,7 @@ func newK8sStoreWithParams(
 	if err != nil {
 		return nil, nil, err
 	}
-	return &k8sStore{cm}, cm, nil
+	return &k8sStore{cm: cm, lockTryDuration: lockTryDuration}, cm, nil
 }
 
 func (k8s *k8sStore) Lock(owner string) (*Lock, error) {
@@ -119,7 +133,120 @@ func (k8s *k8sStore) LockWithKey(owner, key string) (*Lock, error) {
 	return &Lock{Key: key, Owner: owner, LockedWithKey: true}, nil
 }
 
+// LockWithTTL locks the given key by stamping a lease with an expiration time
+// into the configmap. A lease that has expired is treated as free and is
+// reclaimed by the next caller, so a crashed owner holds the key for at most
+// ttl. Calling it again as the same owner extends the lease.
+//
+// The lease is not refreshed while it is held. An owner that is still running
+// past ttl (for example because it was paused or partitioned) can lose the
+// key to another owner while it is still acting on it; it only finds out when
+// Unlock returns configmap.ErrConfigMapLockLost. Expiration times are also
+// compared against the local clock, so clock skew between nodes shortens or
+// lengthens the effective ttl. Callers should pick a ttl well above the
+// expected hold time.
+func (k8s *k8sStore) LockWithTTL(owner, key string, ttl time.Duration) (*Lock, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("ttl for lock on key %s must be positive", key)
+	}
+	leaseKey := ttlLockKey(key)
+	deadline := time.Now().Add(k8s.lockTryDuration)
+	for {
+		acquired, err := k8s.tryLockWithTTL(owner, leaseKey, ttl)
+		if err != nil {
+			return nil, err
+		}
+		if acquired {
+			return &Lock{Key: key, Owner: owner, LockedWithKey: true, internalLock: leaseKey}, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, configmap.ErrConfigMapLocked
+		}
+		time.Sleep(ttlLockRetryInterval)
+	}
+}
+
+// tryLockWithTTL makes a single attempt at stamping the lease for owner. The
+// configmap key lock on leaseKey guards the read-modify-write of the lease.
+func (k8s *k8sStore) tryLockWithTTL(owner, leaseKey string, ttl time.Duration) (bool, error) {
+	if err := k8s.cm.LockWithKey(owner, leaseKey); err != nil {
+		return false, err
+	}
+	defer func() {
+		if err := k8s.cm.UnlockWithKey(leaseKey); err != nil {
+			logrus.Warnf("Failed to unlock with key %s: %v", leaseKey, err)
+		}
+	}()
+
+	lease, err := k8s.getTTLLock(leaseKey)
+	if err != nil {
+		return false, err
+	}
+	if lease != nil && lease.Owner != owner {
+		if time.Now().Before(lease.Expiration) {
+			return false, nil
+		}
+		logrus.Infof("Lock %s from owner %s expired at %v, now claiming for owner %s",
+			leaseKey, lease.Owner, lease.Expiration, owner)
+	}
+
+	value, err := json.Marshal(&ttlLock{Owner: owner, Expiration: time.Now().Add(ttl)})
+	if err != nil {
+		return false, err
+	}
+	if err := k8s.patchWithRetries(false, owner, leaseKey, string(value)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// unlockTTL removes the lease taken by LockWithTTL if it is still held by the
+// owner of storeLock.
+func (k8s *k8sStore) unlockTTL(storeLock *Lock, leaseKey string) error {
+	if err := k8s.cm.LockWithKey(storeLock.Owner, leaseKey); err != nil {
+		return err
+	}
+	defer func() {
+		if err := k8s.cm.UnlockWithKey(leaseKey); err != nil {
+			logrus.Warnf("Failed to unlock with key %s: %v", leaseKey, err)
+		}
+	}()
+
+	lease, err := k8s.getTTLLock(leaseKey)
+	if err != nil {
+		return err
+	}
+	if lease == nil || lease.Owner != storeLock.Owner {
+		return configmap.ErrConfigMapLockLost
+	}
+	return k8s.cm.DeleteKeyLocked(false, storeLock.Owner, leaseKey)
+}
+
+// getTTLLock returns the lease stored under leaseKey, or nil if there is none.
+func (k8s *k8sStore) getTTLLock(leaseKey string) (*ttlLock, error) {
+	data, err := k8s.cm.Get()
+	if err != nil {
+		return nil, err
+	}
+	value, ok := data[leaseKey]
+	if !ok || len(value) == 0 {
+		return nil, nil
+	}
+	lease := &ttlLock{}
+	if err := json.Unmarshal([]byte(value), lease); err != nil {
+		return nil, fmt.Errorf("failed to parse lock %s: %v", leaseKey, err)
+	}
+	return lease, nil
+}
+
+func ttlLockKey(key string) string {
+	return ttlLockKeyPrefix + GetSanitizedK8sName(key)
+}
+
 func (k8s *k8sStore) Unlock(storeLock *Lock) error {
+	if leaseKey, ok := storeLock.internalLock.(string); ok {
+		return k8s.unlockTTL(storeLock, leaseKey)
+	}
 	if storeLock.LockedWithKey {
 		return k8s.cm.UnlockWithKey(storeLock.Key)
 	}
@@ -127,7 +254,18 @@ func (k8s *k8sStore) Unlock(storeLock *Lock) error {
 }
 
 func (k8s *k8sStore) IsKeyLocked(key string) (bool, string, error) {
-	return k8s.cm.IsKeyLocked(key)
+	locked, owner, err := k8s.cm.IsKeyLocked(key)
+	if err != nil || locked {
+		return locked, owner, err
+	}
+	lease, err := k8s.getTTLLock(ttlLockKey(key))
+	if err != nil {
+		return false, "", err
+	}
+	if lease != nil && time.Now().Before(lease.Expiration) {
+		return true, lease.Owner, nil
+	}
+	return false, "", nil
 }
 
 func (k8s *k8sStore) CreateKey(lockAs, key string, value []byte) error {
@@ -0,0 +1,50 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/portworx/sched-ops/k8s/core"
+	"github.com/portworx/sched-ops/k8s/core/configmap"
+	"github.com/stretchr/testify/require"
+	fakek8sclient "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLockWithTTLReclaimsStaleLock(t *testing.T) {
+	core.SetInstance(core.New(fakek8sclient.NewSimpleClientset()))
+	cm, err := configmap.New("px-cloud-drive-ttl-test", nil, time.Minute, 5, 0, 0)
+	require.NoError(t, err)
+	s := &k8sStore{cm: cm}
+
+	// node1 takes the lock and then "crashes" without unlocking it.
+	staleLock, err := s.LockWithTTL("node1", "pool", 500*time.Millisecond)
+	require.NoError(t, err)
+
+	_, err = s.LockWithTTL("node2", "pool", time.Minute)
+	require.Equal(t, configmap.ErrConfigMapLocked, err)
+	locked, owner, err := s.IsKeyLocked("pool")
+	require.NoError(t, err)
+	require.True(t, locked)
+	require.Equal(t, "node1", owner)
+
+	time.Sleep(time.Second)
+
+	locked, _, err = s.IsKeyLocked("pool")
+	require.NoError(t, err)
+	require.False(t, locked, "expired lock should be treated as free")
+
+	newLock, err := s.LockWithTTL("node2", "pool", time.Minute)
+	require.NoError(t, err)
+	locked, owner, err = s.IsKeyLocked("pool")
+	require.NoError(t, err)
+	require.True(t, locked)
+	require.Equal(t, "node2", owner)
+
+	// node1 comes back and must not release node2's lock.
+	require.Equal(t, configmap.ErrConfigMapLockLost, s.Unlock(staleLock))
+	require.NoError(t, s.Unlock(newLock))
+
+	locked, _, err = s.IsKeyLocked("pool")
+	require.NoError(t, err)
+	require.False(t, locked)
+}
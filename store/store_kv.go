@@ -111,6 +111,13 @@ func (kv *kvStore) LockWithKey(owner, key string) (*Lock, error) {
 	return kvPair, err
 }
 
+// LockWithTTL takes a regular key lock. kvdb locks are backed by keys with a
+// ttl that the owner keeps refreshing, so a crashed owner's lock already
+// expires on its own.
+func (kv *kvStore) LockWithTTL(owner, key string, _ time.Duration) (*Lock, error) {
+	return kv.LockWithKey(owner, key)
+}
+
 func (kv *kvStore) lockWithKeyHelper(owner, key string) (*Lock, error) {
 	kvLock, err := kv.k.LockWithTimeout(key, owner, kv.lockTryDuration, kv.lockHoldDuration)
 	if err != nil {
@@ -64,6 +64,68 @@ func RunTest(
 	}
 }
 
+// RunEmptyVolumeIDTest checks that the driver rejects empty and nil volume
+// IDs with ErrVolInval. The driver should be backed by a client that fails
+// the test if it is called since no request must reach the cloud provider.
+func RunEmptyVolumeIDTest(t *testing.T, driver cloudops.Ops) {
+	empty := ""
+	checks := map[string]func() error{
+		"Attach": func() error {
+			_, err := driver.Attach("", nil)
+			return err
+		},
+		"Detach": func() error {
+			return driver.Detach("", nil)
+		},
+		"Delete": func() error {
+			return driver.Delete("", nil)
+		},
+		"Expand": func() error {
+			_, err := driver.Expand("", targetDiskSizeInGiB, nil)
+			return err
+		},
+		"DevicePath": func() error {
+			_, err := driver.DevicePath("")
+			return err
+		},
+		"Snapshot": func() error {
+			_, err := driver.Snapshot("", true, nil)
+			return err
+		},
+		"ApplyTags": func() error {
+			return driver.ApplyTags("", diskLabels, nil)
+		},
+		"Tags": func() error {
+			_, err := driver.Tags("")
+			return err
+		},
+		"Inspect/empty": func() error {
+			_, err := driver.Inspect([]*string{&empty}, nil)
+			return err
+		},
+		"Inspect/nil": func() error {
+			_, err := driver.Inspect([]*string{nil}, nil)
+			return err
+		},
+		"BatchInspect/nil": func() error {
+			_, err := driver.BatchInspect([]*string{nil})
+			return err
+		},
+		"EnumerateSnapshots/nil": func() error {
+			_, err := driver.EnumerateSnapshots([]*string{nil}, nil)
+			return err
+		},
+	}
+
+	for method, check := range checks {
+		err := check()
+		require.Error(t, err, "%s accepted an empty volume ID", method)
+		storageErr, ok := err.(*cloudops.StorageError)
+		require.True(t, ok, "%s returned %T instead of a StorageError", method, err)
+		require.Equal(t, cloudops.ErrVolInval, storageErr.Code, "%s returned wrong error code", method)
+	}
+}
+
 func name(t *testing.T, driver cloudops.Ops) {
 	name := driver.Name()
 	require.NotEmpty(t, name, "driver returned empty name")
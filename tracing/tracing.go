@@ -0,0 +1,215 @@
+// Package tracing traces the decisions made by a cloudops.StorageManager as
+// spans. It does not depend on a tracing library: callers inject a Tracer,
+// which is typically a thin adapter over an OpenTelemetry trace.Tracer.
+package tracing
+
+import (
+	"context"
+	"errors"
+
+	"github.com/libopenstorage/cloudops"
+)
+
+// Attribute keys set on the storage manager spans
+const (
+	// InstanceTypeKey is the instance type of the request
+	InstanceTypeKey = "cloudops.instance_type"
+	// RequestedIOPSKey lists the IOPS of each of the user storage specs
+	RequestedIOPSKey = "cloudops.requested.iops"
+	// RequestedMinCapacityKey lists the minimum capacity of each of the user
+	// storage specs
+	RequestedMinCapacityKey = "cloudops.requested.min_capacity"
+	// RequestedMaxCapacityKey lists the maximum capacity of each of the user
+	// storage specs
+	RequestedMaxCapacityKey = "cloudops.requested.max_capacity"
+	// RequestedDriveTypeKey lists the drive type of each of the user storage
+	// specs
+	RequestedDriveTypeKey = "cloudops.requested.drive_type"
+	// RequestedCapacityKey is the desired capacity of a storage pool update
+	RequestedCapacityKey = "cloudops.requested.capacity"
+	// RequestedOperationKey is the resize operation of a storage pool update
+	RequestedOperationKey = "cloudops.requested.operation"
+	// CurrentDriveTypeKey is the current drive type of a storage pool update
+	CurrentDriveTypeKey = "cloudops.current.drive_type"
+	// CurrentDriveCountKey is the current drive count of a storage pool update
+	CurrentDriveCountKey = "cloudops.current.drive_count"
+	// CurrentDriveSizeKey is the current drive size of a storage pool update
+	CurrentDriveSizeKey = "cloudops.current.drive_size"
+	// ChosenDriveTypeKey lists the drive type of each of the chosen storage
+	// pools
+	ChosenDriveTypeKey = "cloudops.chosen.drive_type"
+	// ChosenDriveCountKey lists the drive count of each of the chosen storage
+	// pools
+	ChosenDriveCountKey = "cloudops.chosen.drive_count"
+	// ChosenDriveCapacityKey lists the drive capacity in GiB of each of the
+	// chosen storage pools
+	ChosenDriveCapacityKey = "cloudops.chosen.drive_capacity_gib"
+	// ChosenOperationKey is the resize operation chosen for a storage pool
+	// update
+	ChosenOperationKey = "cloudops.chosen.operation"
+	// RejectionReasonKey is the reason no candidate was found, or the reason
+	// resizing the existing drives was rejected in favour of adding drives
+	RejectionReasonKey = "cloudops.rejection_reason"
+)
+
+// Attribute is a key value pair describing a span. Value is one of bool,
+// int64, string, []int64 or []string.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Span is a single traced operation
+type Span interface {
+	// SetAttributes sets the given attributes on the span
+	SetAttributes(attrs ...Attribute)
+	// RecordError records err as having occurred during the span
+	RecordError(err error)
+	// End completes the span
+	End()
+}
+
+// Tracer starts spans
+type Tracer interface {
+	// Start starts a span with the given name as a child of the span in ctx,
+	// if any, and returns a context carrying the new span
+	Start(ctx context.Context, spanName string) (context.Context, Span)
+}
+
+// StorageManager is a cloudops.StorageManager whose decisions can be traced
+// as children of the caller's span
+type StorageManager interface {
+	cloudops.StorageManager
+	// GetStorageDistributionWithContext is GetStorageDistribution traced as a
+	// child of the span in ctx
+	GetStorageDistributionWithContext(
+		ctx context.Context,
+		request *cloudops.StorageDistributionRequest,
+	) (*cloudops.StorageDistributionResponse, error)
+	// RecommendStoragePoolUpdateWithContext is RecommendStoragePoolUpdate
+	// traced as a child of the span in ctx
+	RecommendStoragePoolUpdateWithContext(
+		ctx context.Context,
+		request *cloudops.StoragePoolUpdateRequest,
+	) (*cloudops.StoragePoolUpdateResponse, error)
+}
+
+type tracedStorageManager struct {
+	cloudops.StorageManager
+	tracer Tracer
+}
+
+// NewTracedStorageManager returns a wrapper for the given
+// cloudops.StorageManager which records a span with the request and the
+// chosen storage for each GetStorageDistribution and
+// RecommendStoragePoolUpdate call. The methods of cloudops.StorageManager
+// start root spans; use the WithContext variants to correlate the spans with
+// an enclosing request.
+func NewTracedStorageManager(manager cloudops.StorageManager, tracer Tracer) StorageManager {
+	return &tracedStorageManager{
+		StorageManager: manager,
+		tracer:         tracer,
+	}
+}
+
+func (t *tracedStorageManager) GetStorageDistribution(
+	request *cloudops.StorageDistributionRequest,
+) (*cloudops.StorageDistributionResponse, error) {
+	return t.GetStorageDistributionWithContext(context.Background(), request)
+}
+
+func (t *tracedStorageManager) GetStorageDistributionWithContext(
+	ctx context.Context,
+	request *cloudops.StorageDistributionRequest,
+) (*cloudops.StorageDistributionResponse, error) {
+	_, span := t.tracer.Start(ctx, "cloudops.GetStorageDistribution")
+	defer span.End()
+
+	var (
+		iops, minCapacity, maxCapacity []int64
+		driveTypes                     []string
+	)
+	for _, spec := range request.UserStorageSpec {
+		iops = append(iops, int64(spec.IOPS))
+		minCapacity = append(minCapacity, int64(spec.MinCapacity))
+		maxCapacity = append(maxCapacity, int64(spec.MaxCapacity))
+		driveTypes = append(driveTypes, spec.DriveType)
+	}
+	span.SetAttributes(
+		Attribute{Key: InstanceTypeKey, Value: request.InstanceType},
+		Attribute{Key: RequestedIOPSKey, Value: iops},
+		Attribute{Key: RequestedMinCapacityKey, Value: minCapacity},
+		Attribute{Key: RequestedMaxCapacityKey, Value: maxCapacity},
+		Attribute{Key: RequestedDriveTypeKey, Value: driveTypes},
+	)
+
+	response, err := t.StorageManager.GetStorageDistribution(request)
+	if err != nil {
+		recordError(span, err)
+		return nil, err
+	}
+	span.SetAttributes(chosenStorage(response.InstanceStorage)...)
+	return response, nil
+}
+
+func (t *tracedStorageManager) RecommendStoragePoolUpdate(
+	request *cloudops.StoragePoolUpdateRequest,
+) (*cloudops.StoragePoolUpdateResponse, error) {
+	return t.RecommendStoragePoolUpdateWithContext(context.Background(), request)
+}
+
+func (t *tracedStorageManager) RecommendStoragePoolUpdateWithContext(
+	ctx context.Context,
+	request *cloudops.StoragePoolUpdateRequest,
+) (*cloudops.StoragePoolUpdateResponse, error) {
+	_, span := t.tracer.Start(ctx, "cloudops.RecommendStoragePoolUpdate")
+	defer span.End()
+
+	span.SetAttributes(
+		Attribute{Key: RequestedCapacityKey, Value: int64(request.DesiredCapacity)},
+		Attribute{Key: RequestedOperationKey, Value: request.ResizeOperationType.String()},
+		Attribute{Key: CurrentDriveTypeKey, Value: request.CurrentDriveType},
+		Attribute{Key: CurrentDriveCountKey, Value: int64(request.CurrentDriveCount)},
+		Attribute{Key: CurrentDriveSizeKey, Value: int64(request.CurrentDriveSize)},
+	)
+
+	response, err := t.StorageManager.RecommendStoragePoolUpdate(request)
+	if err != nil {
+		recordError(span, err)
+		return nil, err
+	}
+	span.SetAttributes(chosenStorage(response.InstanceStorage)...)
+	span.SetAttributes(Attribute{Key: ChosenOperationKey, Value: response.ResizeOperationType.String()})
+	if len(response.ResizeRejectedReason) > 0 {
+		span.SetAttributes(Attribute{Key: RejectionReasonKey, Value: response.ResizeRejectedReason})
+	}
+	return response, nil
+}
+
+// chosenStorage returns the attributes describing the given storage pools
+func chosenStorage(pools []*cloudops.StoragePoolSpec) []Attribute {
+	var (
+		driveTypes              []string
+		driveCounts, capacities []int64
+	)
+	for _, pool := range pools {
+		driveTypes = append(driveTypes, pool.DriveType)
+		driveCounts = append(driveCounts, int64(pool.DriveCount))
+		capacities = append(capacities, int64(pool.DriveCapacityGiB))
+	}
+	return []Attribute{
+		{Key: ChosenDriveTypeKey, Value: driveTypes},
+		{Key: ChosenDriveCountKey, Value: driveCounts},
+		{Key: ChosenDriveCapacityKey, Value: capacities},
+	}
+}
+
+// recordError records err on span, along with the reason no storage
+// distribution candidate was found if that is the cause
+func recordError(span Span, err error) {
+	span.RecordError(err)
+	var notFound *cloudops.ErrStorageDistributionCandidateNotFound
+	if errors.As(err, &notFound) && len(notFound.Reason) > 0 {
+		span.SetAttributes(Attribute{Key: RejectionReasonKey, Value: notFound.Reason})
+	}
+}
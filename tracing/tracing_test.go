@@ -0,0 +1,133 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/libopenstorage/cloudops"
+	"github.com/libopenstorage/cloudops/mock"
+	"github.com/libopenstorage/openstorage/api"
+	"github.com/stretchr/testify/require"
+)
+
+type spanKey struct{}
+
+// memorySpan is a span recorded by memoryTracer
+type memorySpan struct {
+	name       string
+	parent     *memorySpan
+	attributes map[string]interface{}
+	errors     []error
+	ended      bool
+}
+
+func (s *memorySpan) SetAttributes(attrs ...Attribute) {
+	for _, attr := range attrs {
+		s.attributes[attr.Key] = attr.Value
+	}
+}
+
+func (s *memorySpan) RecordError(err error) {
+	s.errors = append(s.errors, err)
+}
+
+func (s *memorySpan) End() {
+	s.ended = true
+}
+
+// memoryTracer keeps the spans it starts in memory
+type memoryTracer struct {
+	sync.Mutex
+	spans []*memorySpan
+}
+
+func (m *memoryTracer) Start(ctx context.Context, spanName string) (context.Context, Span) {
+	m.Lock()
+	defer m.Unlock()
+	parent, _ := ctx.Value(spanKey{}).(*memorySpan)
+	span := &memorySpan{name: spanName, parent: parent, attributes: make(map[string]interface{})}
+	m.spans = append(m.spans, span)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func TestTracedStorageManager(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	manager := mock.NewMockStorageManager(ctrl)
+	tracer := &memoryTracer{}
+	traced := NewTracedStorageManager(manager, tracer)
+
+	distributionRequest := &cloudops.StorageDistributionRequest{
+		UserStorageSpec: []*cloudops.StorageSpec{
+			{DriveType: "gp2", IOPS: 1000, MinCapacity: 1024, MaxCapacity: 4096},
+		},
+		InstanceType: "m5.large",
+	}
+	manager.EXPECT().GetStorageDistribution(distributionRequest).Return(
+		&cloudops.StorageDistributionResponse{
+			InstanceStorage: []*cloudops.StoragePoolSpec{
+				{DriveType: "gp2", DriveCount: 2, DriveCapacityGiB: 512},
+			},
+		}, nil)
+
+	provisioningCtx, provisioningSpan := tracer.Start(context.Background(), "provision")
+	_, err := traced.GetStorageDistributionWithContext(provisioningCtx, distributionRequest)
+	require.NoError(t, err)
+
+	span := tracer.spans[1]
+	require.Equal(t, "cloudops.GetStorageDistribution", span.name)
+	require.Equal(t, provisioningSpan, span.parent)
+	require.True(t, span.ended)
+	require.Equal(t, "m5.large", span.attributes[InstanceTypeKey])
+	require.Equal(t, []int64{1000}, span.attributes[RequestedIOPSKey])
+	require.Equal(t, []int64{1024}, span.attributes[RequestedMinCapacityKey])
+	require.Equal(t, []int64{4096}, span.attributes[RequestedMaxCapacityKey])
+	require.Equal(t, []string{"gp2"}, span.attributes[RequestedDriveTypeKey])
+	require.Equal(t, []string{"gp2"}, span.attributes[ChosenDriveTypeKey])
+	require.Equal(t, []int64{2}, span.attributes[ChosenDriveCountKey])
+	require.Equal(t, []int64{512}, span.attributes[ChosenDriveCapacityKey])
+
+	// the rejection reason is recorded when no candidate is found
+	notFound := &cloudops.ErrStorageDistributionCandidateNotFound{Reason: "no row for gp3"}
+	manager.EXPECT().GetStorageDistribution(distributionRequest).Return(nil, notFound)
+	_, err = traced.GetStorageDistribution(distributionRequest)
+	require.Equal(t, notFound, err)
+
+	span = tracer.spans[2]
+	require.Nil(t, span.parent)
+	require.True(t, span.ended)
+	require.Equal(t, []error{notFound}, span.errors)
+	require.Equal(t, "no row for gp3", span.attributes[RejectionReasonKey])
+	require.NotContains(t, span.attributes, ChosenDriveTypeKey)
+
+	updateRequest := &cloudops.StoragePoolUpdateRequest{
+		DesiredCapacity:   2048,
+		CurrentDriveType:  "gp2",
+		CurrentDriveCount: 2,
+		CurrentDriveSize:  512,
+	}
+	manager.EXPECT().RecommendStoragePoolUpdate(updateRequest).Return(
+		&cloudops.StoragePoolUpdateResponse{
+			InstanceStorage: []*cloudops.StoragePoolSpec{
+				{DriveType: "gp2", DriveCount: 2, DriveCapacityGiB: 512},
+			},
+			ResizeOperationType:  api.SdkStoragePool_RESIZE_TYPE_ADD_DISK,
+			ResizeRejectedReason: "drives are at their maximum size",
+		}, nil)
+	_, err = traced.RecommendStoragePoolUpdateWithContext(provisioningCtx, updateRequest)
+	require.NoError(t, err)
+
+	span = tracer.spans[3]
+	require.Equal(t, "cloudops.RecommendStoragePoolUpdate", span.name)
+	require.Equal(t, provisioningSpan, span.parent)
+	require.Equal(t, int64(2048), span.attributes[RequestedCapacityKey])
+	require.Equal(t, "gp2", span.attributes[CurrentDriveTypeKey])
+	require.Equal(t, int64(2), span.attributes[CurrentDriveCountKey])
+	require.Equal(t, int64(512), span.attributes[CurrentDriveSizeKey])
+	require.Equal(t, api.SdkStoragePool_RESIZE_TYPE_ADD_DISK.String(), span.attributes[ChosenOperationKey])
+	require.Equal(t, "drives are at their maximum size", span.attributes[RejectionReasonKey])
+	require.Equal(t, []int64{2}, span.attributes[ChosenDriveCountKey])
+}
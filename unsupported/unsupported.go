@@ -29,6 +29,12 @@ func (u *unsupportedCompute) InspectInstance(instanceID string) (*cloudops.Insta
 	}
 }
 
+func (u *unsupportedCompute) InspectInstanceInZone(instanceID, zone string) (*cloudops.InstanceInfo, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "InspectInstanceInZone",
+	}
+}
+
 func (u *unsupportedCompute) InspectInstanceGroupForInstance(instanceID string) (*cloudops.InstanceGroupInfo, error) {
 	return nil, &cloudops.ErrNotSupported{
 		Operation: "InspectInstanceGroupForInstance",
@@ -55,6 +61,18 @@ func (u *unsupportedCompute) GetInstanceGroupSize(instanceGroupID string) (int64
 	}
 }
 
+func (u *unsupportedCompute) GetInstanceGroupZones(instanceGroupID string) ([]string, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "GetInstanceGroupZones",
+	}
+}
+
+func (u *unsupportedCompute) ListInstanceGroupMembers(instanceGroupID string) ([]string, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "ListInstanceGroupMembers",
+	}
+}
+
 func (u *unsupportedCompute) GetClusterSizeForInstance(instanceID string) (int64, error) {
 	return int64(0), &cloudops.ErrNotSupported{
 		Operation: "GetClusterSizeForInstance",
@@ -67,6 +85,12 @@ func (u *unsupportedCompute) SetClusterVersion(version string, timeout time.Dura
 	}
 }
 
+func (u *unsupportedCompute) GetClusterVersion(instanceID string) (string, string, error) {
+	return "", "", &cloudops.ErrNotSupported{
+		Operation: "GetClusterVersion",
+	}
+}
+
 func (u *unsupportedCompute) SetInstanceGroupVersion(instanceGroupID string,
 	version string,
 	timeout time.Duration) error {
@@ -84,6 +108,40 @@ func (u *unsupportedCompute) SetInstanceUpgradeStrategy(instanceGroupID string,
 	}
 }
 
+func (u *unsupportedCompute) RollInstanceGroup(instanceGroupID string, opts cloudops.RollOpts) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "RollInstanceGroup",
+	}
+}
+
+func (u *unsupportedCompute) SetInstanceGroupNodeLabels(instanceGroupID string,
+	labels map[string]string,
+	timeout time.Duration) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "SetInstanceGroupNodeLabels",
+	}
+}
+
+func (u *unsupportedCompute) SetInstanceGroupNodeTaints(instanceGroupID string,
+	taints []cloudops.NodeTaint,
+	timeout time.Duration) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "SetInstanceGroupNodeTaints",
+	}
+}
+
+func (u *unsupportedCompute) GetNetworkInfo(instanceID string) (*cloudops.NetworkInfo, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "GetNetworkInfo",
+	}
+}
+
+func (u *unsupportedCompute) GetVMDiskBudget(instanceID string) (uint64, uint64, uint64, error) {
+	return 0, 0, 0, &cloudops.ErrNotSupported{
+		Operation: "GetVMDiskBudget",
+	}
+}
+
 type unsupportedStorage struct {
 }
 
@@ -98,6 +156,12 @@ func (u *unsupportedStorage) Create(template interface{}, labels map[string]stri
 	}
 }
 
+func (u *unsupportedStorage) CloneVolume(sourceVolumeID string, template interface{}, labels map[string]string) (interface{}, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "CloneVolume",
+	}
+}
+
 func (u *unsupportedStorage) GetDeviceID(template interface{}) (string, error) {
 	return "", &cloudops.ErrNotSupported{
 		Operation: "GetDeviceID",
@@ -109,6 +173,12 @@ func (u *unsupportedStorage) Attach(volumeID string, options map[string]string)
 	}
 }
 
+func (u *unsupportedStorage) WaitForVolumeState(volumeID string, desiredState string, timeout time.Duration) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "WaitForVolumeState",
+	}
+}
+
 func (u *unsupportedStorage) AreVolumesReadyToExpand(volumeIDs []*string) (bool, error) {
 	return true, &cloudops.ErrNotSupported{
 		Operation: "unsupportedStorage:IsVolumesReadyToExpand",
@@ -180,6 +250,12 @@ func (u *unsupportedStorage) Snapshot(volumeID string, readonly bool, options ma
 	}
 }
 
+func (u *unsupportedStorage) SnapshotWithOptions(volumeID string, readonly bool, opts cloudops.SnapshotOptions) (interface{}, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "SnapshotWithOptions",
+	}
+}
+
 func (u *unsupportedStorage) SnapshotDelete(snapID string, options map[string]string) error {
 	return &cloudops.ErrNotSupported{
 		Operation: "SnapshotDelete",
@@ -204,6 +280,154 @@ func (u *unsupportedStorage) Tags(volumeID string) (map[string]string, error) {
 	}
 }
 
+func (u *unsupportedStorage) ReconcileDataDisks(instanceID string) ([]string, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "ReconcileDataDisks",
+	}
+}
+
+func (u *unsupportedStorage) GetEffectivePerformance(volumeID string) (uint64, uint64, error) {
+	return 0, 0, &cloudops.ErrNotSupported{
+		Operation: "GetEffectivePerformance",
+	}
+}
+
+func (u *unsupportedStorage) ConfigureReplication(volumeID, targetRegion string, options map[string]string) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "ConfigureReplication",
+	}
+}
+
+func (u *unsupportedStorage) StopReplication(volumeID string) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "StopReplication",
+	}
+}
+
+func (u *unsupportedStorage) EnumerateSnapshots(volumeIDs []*string, labels map[string]string) ([]*cloudops.SnapshotInfo, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "EnumerateSnapshots",
+	}
+}
+
+func (u *unsupportedStorage) ReportCapacityByLabel(labelKey string) (map[string]uint64, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "ReportCapacityByLabel",
+	}
+}
+
+func (u *unsupportedStorage) UpdateAttachmentCaching(instanceID, diskName, caching string) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "UpdateAttachmentCaching",
+	}
+}
+
+func (u *unsupportedStorage) GetVolumeQoS(volumeID string) (uint64, uint64, error) {
+	return 0, 0, &cloudops.ErrNotSupported{
+		Operation: "GetVolumeQoS",
+	}
+}
+
+func (u *unsupportedStorage) ExpandMany(volumeIDs []string, newSizeInGiB uint64, options map[string]string) (map[string]uint64, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "ExpandMany",
+	}
+}
+
+func (u *unsupportedStorage) LockVolume(volumeID, owner string) (bool, error) {
+	return false, &cloudops.ErrNotSupported{
+		Operation: "LockVolume",
+	}
+}
+
+func (u *unsupportedStorage) UnlockVolume(volumeID, owner string) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "UnlockVolume",
+	}
+}
+
+func (u *unsupportedStorage) GetStorageLayout(instanceID string) ([]cloudops.VolumeSpec, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "GetStorageLayout",
+	}
+}
+
+func (u *unsupportedStorage) ProvisionStorageLayout(specs []cloudops.VolumeSpec, labels map[string]string) ([]interface{}, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "ProvisionStorageLayout",
+	}
+}
+
+func (u *unsupportedStorage) GetVolumeLineage(volumeID string) (string, string, error) {
+	return "", "", &cloudops.ErrNotSupported{
+		Operation: "GetVolumeLineage",
+	}
+}
+
+func (u *unsupportedStorage) BatchInspect(volumeIds []*string) (map[string]interface{}, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "BatchInspect",
+	}
+}
+
+func (u *unsupportedStorage) AdoptVolume(volumeID string, labels map[string]string) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "AdoptVolume",
+	}
+}
+
+func (u *unsupportedStorage) DeleteSnapshotChain(snapIDs []string) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "DeleteSnapshotChain",
+	}
+}
+
+func (u *unsupportedStorage) GetStoragePressure(instanceID string) ([]cloudops.VolumePressureInfo, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "GetStoragePressure",
+	}
+}
+
+func (u *unsupportedStorage) CheckVolumeQuota(required []*cloudops.StoragePoolSpec) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "CheckVolumeQuota",
+	}
+}
+
+func (u *unsupportedStorage) ListVolumesPaged(labels map[string]string, pageSize int) (cloudops.VolumeIterator, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "ListVolumesPaged",
+	}
+}
+
+func (u *unsupportedStorage) AttachByInstanceID(instanceID, volumeID string, options map[string]string) (string, error) {
+	return "", &cloudops.ErrNotSupported{
+		Operation: "AttachByInstanceID",
+	}
+}
+
+func (u *unsupportedStorage) DeleteVolumes(volumeIDs []string) (map[string]error, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "DeleteVolumes",
+	}
+}
+
+func (u *unsupportedStorage) ListSnapshots(labels map[string]string) ([]*cloudops.SnapshotInfo, error) {
+	return u.EnumerateSnapshots(nil, labels)
+}
+
+func (u *unsupportedStorage) InspectSnapshot(snapID string) (*cloudops.SnapshotInfo, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "InspectSnapshot",
+	}
+}
+
+func (u *unsupportedStorage) ModifyVolumeType(volumeID, newType string, iops, throughput *int64) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "ModifyVolumeType",
+	}
+}
+
 type unsupportedStorageManager struct {
 }
 
@@ -220,6 +444,14 @@ func (u *unsupportedStorageManager) GetStorageDistribution(
 	}
 }
 
+func (u *unsupportedStorageManager) GetStorageDistributionWithExplanation(
+	request *cloudops.StorageDistributionRequest,
+) (*cloudops.StorageDistributionResponse, []cloudops.CandidateEvaluation, error) {
+	return nil, nil, &cloudops.ErrNotSupported{
+		Operation: "GetStorageDistributionWithExplanation",
+	}
+}
+
 func (u *unsupportedStorageManager) RecommendStoragePoolUpdate(
 	request *cloudops.StoragePoolUpdateRequest) (*cloudops.StoragePoolUpdateResponse, error) {
 	return nil, &cloudops.ErrNotSupported{
@@ -233,3 +465,10 @@ func (u *unsupportedStorageManager) GetMaxDriveSize(
 		Operation: "GetMaxDriveSize",
 	}
 }
+
+func (u *unsupportedStorageManager) ValidateStorageSpec(
+	spec *cloudops.StorageSpec) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "ValidateStorageSpec",
+	}
+}
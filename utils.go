@@ -1,9 +1,14 @@
 package cloudops
 
 import (
+	"errors"
 	"fmt"
+	"net"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -16,6 +21,568 @@ const ProviderOpsRetryInterval = 3 * time.Second
 // ProviderOpsTimeout is the default timeout of storage provider ops
 const ProviderOpsTimeout = time.Minute
 
+// OpsTimeoutConfig configures how long providers wait for cloud operations,
+// such as disk creation or attachment, to complete. Unset values fall back to
+// ProviderOpsTimeout and ProviderOpsRetryInterval.
+type OpsTimeoutConfig struct {
+	// Timeout is the time to wait for an operation to complete
+	Timeout time.Duration
+	// RetryInterval is the time to wait before each poll of an operation
+	RetryInterval time.Duration
+}
+
+// DefaultOpsTimeoutConfig returns the OpsTimeoutConfig with the default
+// provider ops timeout and retry interval
+func DefaultOpsTimeoutConfig() OpsTimeoutConfig {
+	return OpsTimeoutConfig{
+		Timeout:       ProviderOpsTimeout,
+		RetryInterval: ProviderOpsRetryInterval,
+	}
+}
+
+// OpsTimeout returns the configured timeout or ProviderOpsTimeout if unset
+func (c OpsTimeoutConfig) OpsTimeout() time.Duration {
+	if c.Timeout <= 0 {
+		return ProviderOpsTimeout
+	}
+	return c.Timeout
+}
+
+// OpsRetryInterval returns the configured retry interval or
+// ProviderOpsRetryInterval if unset
+func (c OpsTimeoutConfig) OpsRetryInterval() time.Duration {
+	if c.RetryInterval <= 0 {
+		return ProviderOpsRetryInterval
+	}
+	return c.RetryInterval
+}
+
+// DevicePathCacheTTL is the default time a resolved device path is cached for
+const DevicePathCacheTTL = 30 * time.Second
+
+// DevicePathCache caches the block device paths that attached volumes' by-id
+// symlinks resolve to, so repeated device path lookups don't stat the symlinks
+// again. Providers must invalidate it when disks are attached or detached as
+// the kernel may reuse device names. A nil cache caches nothing.
+type DevicePathCache struct {
+	ttl     time.Duration
+	lock    sync.Mutex
+	entries map[string]devicePathCacheEntry
+}
+
+type devicePathCacheEntry struct {
+	devicePath string
+	expiry     time.Time
+}
+
+// NewDevicePathCache returns a DevicePathCache whose entries expire after ttl
+func NewDevicePathCache(ttl time.Duration) *DevicePathCache {
+	return &DevicePathCache{
+		ttl:     ttl,
+		entries: make(map[string]devicePathCacheEntry),
+	}
+}
+
+// Resolve returns the cached device path of the volume or calls resolve to
+// look it up and caches the result if it succeeds
+func (c *DevicePathCache) Resolve(
+	volumeID string,
+	resolve func() (string, error),
+) (string, error) {
+	if c == nil {
+		return resolve()
+	}
+
+	c.lock.Lock()
+	entry, ok := c.entries[volumeID]
+	c.lock.Unlock()
+	if ok && time.Now().Before(entry.expiry) {
+		return entry.devicePath, nil
+	}
+
+	devicePath, err := resolve()
+	if err != nil {
+		return "", err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries[volumeID] = devicePathCacheEntry{
+		devicePath: devicePath,
+		expiry:     time.Now().Add(c.ttl),
+	}
+	return devicePath, nil
+}
+
+// Invalidate removes the cached device path of the volume
+func (c *DevicePathCache) Invalidate(volumeID string) {
+	if c == nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.entries, volumeID)
+}
+
+// InvalidateAll removes all the cached device paths
+func (c *DevicePathCache) InvalidateAll() {
+	if c == nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries = make(map[string]devicePathCacheEntry)
+}
+
+// MetadataCacheTTL is the default time instance metadata is cached for
+const MetadataCacheTTL = 5 * time.Minute
+
+// metadataCache caches the instance metadata fetched by each provider for the
+// whole process, so clients created repeatedly don't query the instance
+// metadata server every time
+var metadataCache = struct {
+	sync.Mutex
+	ttl     time.Duration
+	entries map[ProviderType]metadataCacheEntry
+}{
+	ttl:     MetadataCacheTTL,
+	entries: make(map[ProviderType]metadataCacheEntry),
+}
+
+type metadataCacheEntry struct {
+	metadata interface{}
+	expiry   time.Time
+}
+
+// SetMetadataCacheTTL sets the time instance metadata is cached for. A ttl of
+// zero or less disables the cache.
+func SetMetadataCacheTTL(ttl time.Duration) {
+	metadataCache.Lock()
+	defer metadataCache.Unlock()
+	metadataCache.ttl = ttl
+}
+
+// CachedMetadata returns the instance metadata cached for the provider or
+// calls fetch to query the metadata server and caches the result if it
+// succeeds. Only metadata which does not change over the instance's lifetime,
+// such as its ID, zone, region and project, should be cached.
+func CachedMetadata(
+	provider ProviderType,
+	fetch func() (interface{}, error),
+) (interface{}, error) {
+	metadataCache.Lock()
+	entry, ok := metadataCache.entries[provider]
+	ttl := metadataCache.ttl
+	metadataCache.Unlock()
+	if ok && time.Now().Before(entry.expiry) {
+		return entry.metadata, nil
+	}
+
+	metadata, err := fetch()
+	if err != nil || ttl <= 0 {
+		return metadata, err
+	}
+
+	metadataCache.Lock()
+	defer metadataCache.Unlock()
+	metadataCache.entries[provider] = metadataCacheEntry{
+		metadata: metadata,
+		expiry:   time.Now().Add(ttl),
+	}
+	return metadata, nil
+}
+
+// RefreshMetadata drops the cached instance metadata of all providers so it is
+// queried from the metadata server again
+func RefreshMetadata() {
+	metadataCache.Lock()
+	defer metadataCache.Unlock()
+	metadataCache.entries = make(map[ProviderType]metadataCacheEntry)
+}
+
+const (
+	// MinSizePolicyOption is the Create option which sets how a drive size below
+	// the cloud provider's minimum drive size is handled. Defaults to
+	// MinSizePolicyRoundUp.
+	MinSizePolicyOption = "min-size-policy"
+	// MinSizePolicyRoundUp rounds the drive size up to the provider's minimum
+	MinSizePolicyRoundUp = "round-up"
+	// MinSizePolicyError fails the create if the drive size is below the provider's minimum
+	MinSizePolicyError = "error"
+)
+
+// EnforceMinSize returns the drive size to create for the requested size given
+// the cloud provider's minimum drive size. Based on the MinSizePolicyOption in
+// options, a size below the minimum is either rounded up or rejected.
+func EnforceMinSize(size, minSize uint64, options map[string]string) (uint64, error) {
+	if size >= minSize {
+		return size, nil
+	}
+
+	switch policy := options[MinSizePolicyOption]; policy {
+	case "", MinSizePolicyRoundUp:
+		return minSize, nil
+	case MinSizePolicyError:
+		return 0, NewStorageError(ErrVolInval,
+			fmt.Sprintf("requested drive size %d GiB is less than the minimum drive size %d GiB",
+				size, minSize), "")
+	default:
+		return 0, NewStorageError(ErrVolInval,
+			fmt.Sprintf("invalid %s: %s", MinSizePolicyOption, policy), "")
+	}
+}
+
+// WaitForSnapshot returns if Snapshot should block until the snapshot is ready
+// based on the SnapshotWaitOption in options
+func WaitForSnapshot(options map[string]string) (bool, error) {
+	return boolOption(options, SnapshotWaitOption, true)
+}
+
+// AppConsistentRequested returns if Snapshot should take an
+// application-consistent snapshot based on the AppConsistentOption in options
+func AppConsistentRequested(options map[string]string) (bool, error) {
+	return boolOption(options, AppConsistentOption, false)
+}
+
+// SnapshotName returns a unique snapshot name. The nanosecond suffix keeps
+// snapshots taken in the same second apart. It is a valid snapshot name on
+// every provider.
+func SnapshotName() string {
+	now := time.Now().UTC()
+	return fmt.Sprintf("snap-%s-%09d", now.Format("20060102-150405"), now.Nanosecond())
+}
+
+// ThinProvisioningRequested returns if the drive should be thinly provisioned
+// based on the ThinProvisioningOption in options
+func ThinProvisioningRequested(options map[string]string) (bool, error) {
+	return boolOption(options, ThinProvisioningOption, false)
+}
+
+// WaitRemoteRequested returns if Attach should wait for the drive to be
+// detached from a remote instance based on the WaitRemoteOption in options
+func WaitRemoteRequested(options map[string]string) (bool, error) {
+	return boolOption(options, WaitRemoteOption, false)
+}
+
+// MultiAttachRequested returns if the drive should be created with
+// multi-attach enabled based on the MultiAttachOption in options
+func MultiAttachRequested(options map[string]string) (bool, error) {
+	return boolOption(options, MultiAttachOption, false)
+}
+
+// EncryptionOptions are the drive encryption settings requested through the
+// EncryptedOption and KmsKeyIDOption Create options
+type EncryptionOptions struct {
+	// Encrypted is set if the drive should be encrypted at rest
+	Encrypted bool
+	// KmsKeyID is the customer managed key to encrypt the drive with, if any
+	KmsKeyID string
+}
+
+// EncryptionRequested returns the drive encryption settings requested through
+// the EncryptedOption and KmsKeyIDOption in options
+func EncryptionRequested(options map[string]string) (EncryptionOptions, error) {
+	encrypted, err := boolOption(options, EncryptedOption, false)
+	if err != nil {
+		return EncryptionOptions{}, err
+	}
+
+	kmsKeyID := options[KmsKeyIDOption]
+	if len(kmsKeyID) > 0 {
+		if value := options[EncryptedOption]; len(value) > 0 && !encrypted {
+			return EncryptionOptions{}, NewStorageError(ErrVolInval,
+				fmt.Sprintf("%s cannot be given when %s is false", KmsKeyIDOption, EncryptedOption), "")
+		}
+		encrypted = true
+	}
+	return EncryptionOptions{Encrypted: encrypted, KmsKeyID: kmsKeyID}, nil
+}
+
+// ValidateVolumeID returns an ErrVolInval error if the given volume ID is
+// empty, so that providers fail fast instead of calling the cloud API with it
+func ValidateVolumeID(volumeID string) error {
+	if len(volumeID) == 0 {
+		return NewStorageError(ErrVolInval, "volume ID must not be empty", "")
+	}
+	return nil
+}
+
+// ValidateVolumeIDs returns an ErrVolInval error if any of the given volume
+// IDs is nil or empty
+func ValidateVolumeIDs(volumeIDs []*string) error {
+	for _, id := range volumeIDs {
+		if id == nil {
+			return NewStorageError(ErrVolInval, "volume ID must not be nil", "")
+		}
+		if err := ValidateVolumeID(*id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateVolumeState returns an ErrVolInval error if the given state is not
+// one of the normalized volume states
+func ValidateVolumeState(state string) error {
+	switch state {
+	case VolumeStateCreating, VolumeStateAvailable, VolumeStateAttached,
+		VolumeStateDeleting, VolumeStateError:
+		return nil
+	}
+	return NewStorageError(ErrVolInval, fmt.Sprintf("invalid volume state %q", state), "")
+}
+
+// PollVolumeState calls getState every interval until it returns the desired
+// normalized state of the volume. It fails if the volume goes into
+// VolumeStateError while waiting for another state, or if the volume is not
+// in the desired state within timeout. Providers use it to implement
+// WaitForVolumeState.
+func PollVolumeState(
+	volumeID string,
+	desiredState string,
+	getState func() (string, error),
+	timeout time.Duration,
+	interval time.Duration,
+) error {
+	if err := ValidateVolumeID(volumeID); err != nil {
+		return err
+	}
+	if err := ValidateVolumeState(desiredState); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		state, err := getState()
+		if err != nil {
+			return err
+		}
+		if state == desiredState {
+			return nil
+		}
+		if state == VolumeStateError {
+			return NewStorageError(ErrVolInval,
+				fmt.Sprintf("volume %s is in %s state", volumeID, state), "")
+		}
+		if !time.Now().Add(interval).Before(deadline) {
+			return fmt.Errorf("timed out waiting for volume %s to be %s, it is %q",
+				volumeID, desiredState, state)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// ScaleIopsRequested returns if Expand should grow the provisioned IOPS and
+// throughput of the volume based on the ScaleIopsOption in options
+func ScaleIopsRequested(options map[string]string) (bool, error) {
+	return boolOption(options, ScaleIopsOption, false)
+}
+
+// ScaleToSize returns the given value scaled by the ratio of the new size to
+// the old size, e.g. the IOPS which keep the IOPS per GiB of a volume after it
+// is expanded
+func ScaleToSize(value, oldSizeInGiB, newSizeInGiB uint64) uint64 {
+	if oldSizeInGiB == 0 {
+		return value
+	}
+	return value * newSizeInGiB / oldSizeInGiB
+}
+
+// boolOption parses the boolean option with the given key, returning
+// defaultValue if it is not set
+func boolOption(options map[string]string, key string, defaultValue bool) (bool, error) {
+	value, ok := options[key]
+	if !ok || len(value) == 0 {
+		return defaultValue, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, NewStorageError(ErrVolInval,
+			fmt.Sprintf("invalid %s option: %s", key, value), "")
+	}
+	return b, nil
+}
+
+// maxConcurrentExpands is the maximum number of volumes ExpandVolumes expands
+// at the same time
+const maxConcurrentExpands = 8
+
+// ExpandVolumes expands the given volumes concurrently using the given expand
+// function and returns the new sizes of the expanded volumes. If any of the
+// volumes fail to expand, the sizes of the volumes that expanded are returned
+// along with an ErrExpandMany.
+func ExpandVolumes(
+	expand func(volumeID string, newSizeInGiB uint64, options map[string]string) (uint64, error),
+	volumeIDs []string,
+	newSizeInGiB uint64,
+	options map[string]string,
+) (map[string]uint64, error) {
+	var (
+		wg     sync.WaitGroup
+		mutex  sync.Mutex
+		sizes  = make(map[string]uint64)
+		errs   = make(map[string]error)
+		tokens = make(chan struct{}, maxConcurrentExpands)
+	)
+	for _, volumeID := range volumeIDs {
+		wg.Add(1)
+		go func(volumeID string) {
+			defer wg.Done()
+			tokens <- struct{}{}
+			defer func() { <-tokens }()
+
+			size, err := expand(volumeID, newSizeInGiB, options)
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				errs[volumeID] = err
+				return
+			}
+			sizes[volumeID] = size
+		}(volumeID)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return sizes, &ErrExpandMany{Errors: errs}
+	}
+	return sizes, nil
+}
+
+// maxConcurrentDeletes is the maximum number of volumes DeleteVolumes deletes
+// at the same time
+const maxConcurrentDeletes = 10
+
+// DeleteVolumes deletes the given volumes concurrently using the given delete
+// function and returns the delete errors keyed by volume ID. If a delete fails
+// with an error for which isFatal returns true, such as an authentication or
+// connection failure, the deletes not yet started are skipped with that error
+// and the error is also returned.
+func DeleteVolumes(
+	deleteFn func(volumeID string, options map[string]string) error,
+	isFatal func(err error) bool,
+	volumeIDs []string,
+) (map[string]error, error) {
+	var (
+		wg       sync.WaitGroup
+		mutex    sync.Mutex
+		fatalErr error
+		errs     = make(map[string]error)
+		tokens   = make(chan struct{}, maxConcurrentDeletes)
+	)
+	for _, volumeID := range volumeIDs {
+		wg.Add(1)
+		go func(volumeID string) {
+			defer wg.Done()
+			tokens <- struct{}{}
+			defer func() { <-tokens }()
+
+			mutex.Lock()
+			if fatalErr != nil {
+				errs[volumeID] = fatalErr
+				mutex.Unlock()
+				return
+			}
+			mutex.Unlock()
+
+			err := deleteFn(volumeID, nil)
+			if err == nil {
+				return
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			errs[volumeID] = err
+			if fatalErr == nil && isFatal(err) {
+				fatalErr = err
+			}
+		}(volumeID)
+	}
+	wg.Wait()
+
+	return errs, fatalErr
+}
+
+// IsConnectionError returns true if the given error, or an error it wraps, is
+// a network error such as a failure to connect to the cloud API endpoint
+func IsConnectionError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// CreateVolumes creates a volume for each of the given specs from the template
+// returned by the given function and applies the given labels on top of the
+// labels of each spec. If any of the volumes fail to create, the volumes
+// created so far are deleted.
+func CreateVolumes(
+	ops Storage,
+	specs []VolumeSpec,
+	labels map[string]string,
+	template func(spec VolumeSpec) interface{},
+) ([]interface{}, error) {
+	vols := make([]interface{}, 0, len(specs))
+	for _, spec := range specs {
+		volLabels := make(map[string]string, len(spec.Labels)+len(labels))
+		for k, v := range spec.Labels {
+			volLabels[k] = v
+		}
+		for k, v := range labels {
+			volLabels[k] = v
+		}
+
+		vol, err := ops.Create(template(spec), volLabels, nil)
+		if err != nil {
+			return nil, deleteVolumes(ops, vols, err)
+		}
+		vols = append(vols, vol)
+	}
+	return vols, nil
+}
+
+// InspectVolumes returns the given volumes keyed by volume ID by inspecting
+// them one at a time. It implements BatchInspect for providers which cannot
+// list volumes in bulk.
+func InspectVolumes(ops Storage, volumeIds []*string) (map[string]interface{}, error) {
+	if err := ValidateVolumeIDs(volumeIds); err != nil {
+		return nil, err
+	}
+
+	vols := make(map[string]interface{}, len(volumeIds))
+	for _, id := range volumeIds {
+		inspected, err := ops.Inspect([]*string{id}, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(inspected) == 0 {
+			return nil, NewStorageError(ErrVolNotFound,
+				fmt.Sprintf("volume %s not found", *id), "")
+		}
+		vols[*id] = inspected[0]
+	}
+	return vols, nil
+}
+
+// deleteVolumes deletes the given volumes after the given error and returns
+// the error along with any failures to delete the volumes
+func deleteVolumes(ops Storage, vols []interface{}, cause error) error {
+	var failed []string
+	for _, vol := range vols {
+		id, err := ops.GetDeviceID(vol)
+		if err == nil {
+			err = ops.Delete(id, nil)
+		}
+		if err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%v. Failed to delete the volumes created so far: %s",
+			cause, strings.Join(failed, "; "))
+	}
+	return cause
+}
+
 // AddElementToMap adds to the given 'elem' to the 'sets' map with given 'key'
 func AddElementToMap(
 	sets map[string][]interface{},
@@ -30,6 +597,44 @@ func AddElementToMap(
 	}
 }
 
+// NewVolumeIterator returns a VolumeIterator over the volumes returned by
+// fetchPage. fetchPage is called for the next page of volumes once the current
+// page is exhausted, and returns false when there are no pages after the one
+// it returns. Pages may be empty.
+func NewVolumeIterator(fetchPage func() ([]interface{}, bool, error)) VolumeIterator {
+	return &volumeIterator{fetchPage: fetchPage, more: true}
+}
+
+type volumeIterator struct {
+	fetchPage func() ([]interface{}, bool, error)
+	page      []interface{}
+	more      bool
+	err       error
+}
+
+func (it *volumeIterator) Next() (interface{}, bool) {
+	for len(it.page) == 0 {
+		if !it.more || it.err != nil {
+			return nil, false
+		}
+		it.page, it.more, it.err = it.fetchPage()
+		if it.err != nil {
+			it.page = nil
+			return nil, false
+		}
+	}
+
+	vol := it.page[0]
+	// release the volume so the page does not keep it alive
+	it.page[0] = nil
+	it.page = it.page[1:]
+	return vol, true
+}
+
+func (it *volumeIterator) Err() error {
+	return it.err
+}
+
 // GetEnvValueStrict fetches value for env variable "key". Returns error if not found or empty
 func GetEnvValueStrict(key string) (string, error) {
 	if val := os.Getenv(key); len(val) != 0 {
@@ -38,3 +643,45 @@ func GetEnvValueStrict(key string) (string, error) {
 
 	return "", fmt.Errorf("env variable %s is not set", key)
 }
+
+// DeleteSnapshotChain deletes the given snapshots with deleteFn, deleting the
+// snapshots which depend on a snapshot before it. Incremental snapshots depend
+// on the earlier snapshots of the same volume, so the snapshots of each volume
+// are deleted newest first. If a snapshot fails to delete, the older snapshots
+// of its volume are left in place. The snapshots of other volumes are still
+// deleted and an ErrSnapshotChainDelete is returned.
+func DeleteSnapshotChain(snaps []*SnapshotInfo, deleteFn func(snapID string) error) error {
+	chains := make(map[string][]*SnapshotInfo)
+	volumeIDs := make([]string, 0)
+	for _, snap := range snaps {
+		if _, ok := chains[snap.VolumeID]; !ok {
+			volumeIDs = append(volumeIDs, snap.VolumeID)
+		}
+		chains[snap.VolumeID] = append(chains[snap.VolumeID], snap)
+	}
+
+	errs := make(map[string]error)
+	for _, volumeID := range volumeIDs {
+		chain := chains[volumeID]
+		sort.SliceStable(chain, func(i, j int) bool {
+			return chain[i].CreationTime.After(chain[j].CreationTime)
+		})
+
+		var failed string
+		for _, snap := range chain {
+			if len(failed) > 0 {
+				errs[snap.ID] = fmt.Errorf("not deleted as dependent snapshot %s failed to delete", failed)
+				continue
+			}
+			if err := deleteFn(snap.ID); err != nil {
+				errs[snap.ID] = err
+				failed = snap.ID
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return &ErrSnapshotChainDelete{Errors: errs}
+	}
+	return nil
+}
@@ -23,13 +23,14 @@ func newVsphereStorageManager(
 func (a *vsphereStorageManager) GetStorageDistribution(
 	request *cloudops.StorageDistributionRequest,
 ) (*cloudops.StorageDistributionResponse, error) {
+	decisionMatrix := storagedistribution.FilterForRequest(a.decisionMatrix, request)
 	response := &cloudops.StorageDistributionResponse{}
-	for _, userRequest := range request.UserStorageSpec {
+	for _, userRequest := range storagedistribution.UserStorageSpecs(request) {
 		// for for request, find how many instances per zone needs to have storage
 		// and the storage spec for each of them
-		instStorage, instancesPerZone, _, err :=
+		instStorage, instancesPerZone, row, err :=
 			storagedistribution.GetStorageDistributionForPool(
-				a.decisionMatrix,
+				decisionMatrix,
 				userRequest,
 				request.InstancesPerZone,
 				request.ZoneCount,
@@ -44,12 +45,27 @@ func (a *vsphereStorageManager) GetStorageDistribution(
 				DriveType:        instStorage.DriveType,
 				InstancesPerZone: instancesPerZone,
 				DriveCount:       instStorage.DriveCount,
+				ThinProvisioning: instStorage.ThinProvisioning,
 			},
 		)
+		if request.IncludeDecisionMatrixRows {
+			response.DecisionMatrixRows = append(response.DecisionMatrixRows, row)
+		}
 	}
 	return response, nil
 }
 
+func (a *vsphereStorageManager) GetStorageDistributionWithExplanation(
+	request *cloudops.StorageDistributionRequest,
+) (*cloudops.StorageDistributionResponse, []cloudops.CandidateEvaluation, error) {
+	evaluations, err := storagedistribution.ExplainStorageDistribution(a.decisionMatrix, request)
+	if err != nil {
+		return nil, evaluations, err
+	}
+	response, err := a.GetStorageDistribution(request)
+	return response, evaluations, err
+}
+
 func (a *vsphereStorageManager) RecommendStoragePoolUpdate(
 	request *cloudops.StoragePoolUpdateRequest) (*cloudops.StoragePoolUpdateResponse, error) {
 	resp, _, err := storagedistribution.GetStorageUpdateConfig(request, a.decisionMatrix)
@@ -62,6 +78,10 @@ func (a *vsphereStorageManager) GetMaxDriveSize(
 	return resp, err
 }
 
+func (a *vsphereStorageManager) ValidateStorageSpec(spec *cloudops.StorageSpec) error {
+	return storagedistribution.ValidateStorageSpec(spec, a.decisionMatrix)
+}
+
 func init() {
 	cloudops.RegisterStorageManager(cloudops.Vsphere, newVsphereStorageManager)
 }
@@ -70,6 +70,7 @@ func storageDistribution(t *testing.T) {
 					&cloudops.StoragePoolSpec{
 						DriveCapacityGiB: 85,
 						DriveType:        "thin",
+						ThinProvisioning: true,
 						InstancesPerZone: 3,
 						DriveCount:       12,
 					},
@@ -95,6 +96,7 @@ func storageDistribution(t *testing.T) {
 					&cloudops.StoragePoolSpec{
 						DriveCapacityGiB: 170,
 						DriveType:        "thin",
+						ThinProvisioning: true,
 						InstancesPerZone: 3,
 						DriveCount:       12,
 					},
@@ -146,6 +148,7 @@ func storageDistribution(t *testing.T) {
 					&cloudops.StoragePoolSpec{
 						DriveCapacityGiB: 682,
 						DriveType:        "thin",
+						ThinProvisioning: true,
 						InstancesPerZone: 1,
 						DriveCount:       12,
 					},
@@ -190,6 +193,7 @@ func storageUpdate(t *testing.T) {
 					&cloudops.StoragePoolSpec{
 						DriveCapacityGiB: 200,
 						DriveType:        "thin",
+						ThinProvisioning: true,
 						DriveCount:       3,
 					},
 				},
@@ -214,6 +218,7 @@ func storageUpdate(t *testing.T) {
 					&cloudops.StoragePoolSpec{
 						DriveCapacityGiB: 200,
 						DriveType:        "thin",
+						ThinProvisioning: true,
 						DriveCount:       2,
 					},
 				},
@@ -238,6 +243,7 @@ func storageUpdate(t *testing.T) {
 					&cloudops.StoragePoolSpec{
 						DriveCapacityGiB: 200,
 						DriveType:        "thin",
+						ThinProvisioning: true,
 						DriveCount:       3,
 					},
 				},
@@ -262,6 +268,7 @@ func storageUpdate(t *testing.T) {
 					&cloudops.StoragePoolSpec{
 						DriveCapacityGiB: 1024,
 						DriveType:        "thin",
+						ThinProvisioning: true,
 						DriveCount:       2,
 					},
 				},
@@ -286,6 +293,7 @@ func storageUpdate(t *testing.T) {
 					&cloudops.StoragePoolSpec{
 						DriveCapacityGiB: 1024,
 						DriveType:        "thin",
+						ThinProvisioning: true,
 						DriveCount:       1,
 					},
 				},
@@ -310,6 +318,7 @@ func storageUpdate(t *testing.T) {
 					&cloudops.StoragePoolSpec{
 						DriveCapacityGiB: 600,
 						DriveType:        "thin",
+						ThinProvisioning: true,
 						DriveCount:       1,
 					},
 				},
@@ -331,6 +340,7 @@ func storageUpdate(t *testing.T) {
 					&cloudops.StoragePoolSpec{
 						DriveCapacityGiB: 700,
 						DriveType:        "thin",
+						ThinProvisioning: true,
 						DriveCount:       1,
 					},
 				},
@@ -353,6 +363,7 @@ func storageUpdate(t *testing.T) {
 					&cloudops.StoragePoolSpec{
 						DriveCapacityGiB: 4098,
 						DriveType:        "thin",
+						ThinProvisioning: true,
 						DriveCount:       2,
 					},
 				},
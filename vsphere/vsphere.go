@@ -6,7 +6,9 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/go-version"
@@ -23,6 +25,7 @@ import (
 	"github.com/vmware/govmomi/units"
 	"github.com/vmware/govmomi/vim25"
 	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/soap"
 	"github.com/vmware/govmomi/vim25/types"
 	"github.com/vmware/govmomi/vslm"
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -31,6 +34,7 @@ import (
 const (
 	vSphereDataStoreLock = "vsphere-ds-lock"
 	configProperty       = "config.hardware"
+	layoutExProperty     = "layoutEx"
 	permissionError      = "Permission to perform this operation was denied"
 	svmotionErrorMsg     = "retry pool expansion, if a storage vMotion operation was in progress during expansion"
 	vmdkNotFoundErrorMsg = ".vmdk was not found"
@@ -42,6 +46,10 @@ type vsphereOps struct {
 	conn   *vclib.VSphereConnection
 	cfg    *VSphereConfig
 	dsLock store.Store
+	// fcdIDs caches the IDs of the First Class Disks by the path of their
+	// vmdk, as finding them requires listing all the disks of the datastore
+	fcdIDs     map[string]string
+	fcdIDsLock sync.Mutex
 }
 
 var (
@@ -141,6 +149,10 @@ func (ops *vsphereOps) Create(opts interface{}, labels map[string]string, option
 		return nil, fmt.Errorf("datastore is required for the create call")
 	}
 
+	if err := setThinProvisioning(volumeOptions, options); err != nil {
+		return nil, err
+	}
+
 	datastore := strings.TrimSpace(volumeOptions.Datastore)
 	logrus.Infof("Given datastore/datastore cluster: %s for new disk", datastore)
 
@@ -196,19 +208,7 @@ func (ops *vsphereOps) Create(opts interface{}, labels map[string]string, option
 	if apiVersion.GreaterThan(keepDiskVersion) || apiVersion.Equal(keepDiskVersion) {
 		// create disk using the new API so it doesn't get deleted after VM deletion
 		m := vslm.NewObjectManager(vmObj.Client())
-		var provisioningType string
-		switch volumeOptions.DiskFormat {
-		case vclib.LazyZeroedThickDiskType:
-			provisioningType = string(types.BaseConfigInfoDiskFileBackingInfoProvisioningTypeLazyZeroedThick)
-		case vclib.ThinDiskType:
-			provisioningType = string(types.BaseConfigInfoDiskFileBackingInfoProvisioningTypeThin)
-		case vclib.ZeroedThickDiskType:
-			fallthrough
-		case vclib.EagerZeroedThickDiskType:
-			fallthrough
-		default:
-			provisioningType = string(types.BaseConfigInfoDiskFileBackingInfoProvisioningTypeEagerZeroedThick)
-		}
+		provisioningType := diskProvisioningType(volumeOptions.DiskFormat)
 
 		spec := types.VslmCreateSpec{
 			Name:              volumeOptions.Name,
@@ -285,6 +285,13 @@ func (ops *vsphereOps) Create(opts interface{}, labels map[string]string, option
 	}, nil
 }
 
+// CloneVolume creates a volume from the contents of the given volume
+func (ops *vsphereOps) CloneVolume(sourceVolumeID string, template interface{}, labels map[string]string) (interface{}, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "CloneVolume",
+	}
+}
+
 func (ops *vsphereOps) GetDeviceID(vDisk interface{}) (string, error) {
 	disk, ok := vDisk.(*VirtualDisk)
 	if !ok {
@@ -296,6 +303,10 @@ func (ops *vsphereOps) GetDeviceID(vDisk interface{}) (string, error) {
 
 // Attach takes in the path of the vmdk file and returns where it is attached inside the vm instance
 func (ops *vsphereOps) Attach(diskPath string, options map[string]string) (string, error) {
+	if err := cloudops.ValidateVolumeID(diskPath); err != nil {
+		return "", err
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -342,6 +353,10 @@ func (ops *vsphereOps) DetachFrom(diskPath, instanceID string) error {
 }
 
 func (ops *vsphereOps) detachInternal(diskPath, instanceID string) error {
+	if err := cloudops.ValidateVolumeID(diskPath); err != nil {
+		return err
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -377,6 +392,10 @@ func (ops *vsphereOps) DeleteFrom(diskPath, instanceID string) error {
 }
 
 func (ops *vsphereOps) deleteInternal(diskPath, instanceID string) error {
+	if err := cloudops.ValidateVolumeID(diskPath); err != nil {
+		return err
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -424,6 +443,10 @@ func (ops *vsphereOps) FreeDevices() ([]string, error) {
 }
 
 func (ops *vsphereOps) Inspect(vmdksWithDS []*string, options map[string]string) ([]interface{}, error) {
+	if err := cloudops.ValidateVolumeIDs(vmdksWithDS); err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -505,6 +528,10 @@ func (ops *vsphereOps) DeviceMappings() (map[string]string, error) {
 
 // DevicePath for the given volume i.e path where it's attached
 func (ops *vsphereOps) DevicePath(diskPath string) (string, error) {
+	if err := cloudops.ValidateVolumeID(diskPath); err != nil {
+		return "", err
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -545,6 +572,12 @@ func (ops *vsphereOps) Enumerate(volumeIds []*string,
 	}
 }
 
+func (ops *vsphereOps) WaitForVolumeState(volumeID string, desiredState string, timeout time.Duration) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "WaitForVolumeState",
+	}
+}
+
 func (ops *vsphereOps) AreVolumesReadyToExpand(volumeIDs []*string) (bool, error) {
 	return true, &cloudops.ErrNotSupported{
 		Operation: "vsphereOps:IsVolumesReadyToExpand",
@@ -556,6 +589,10 @@ func (ops *vsphereOps) Expand(
 	newSizeInGiB uint64,
 	options map[string]string,
 ) (uint64, error) {
+	if err := cloudops.ValidateVolumeID(vmdkPath); err != nil {
+		return 0, err
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -642,6 +679,13 @@ func (ops *vsphereOps) Expand(
 	return newSizeInGiB, nil
 }
 
+// ModifyVolumeType changes the type of the given volume in place
+func (ops *vsphereOps) ModifyVolumeType(volumeID, newType string, iops, throughput *int64) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "ModifyVolumeType",
+	}
+}
+
 // Snapshot the volume with given volumeID
 func (ops *vsphereOps) Snapshot(volumeID string, readonly bool, options map[string]string) (interface{}, error) {
 	return nil, &cloudops.ErrNotSupported{
@@ -649,6 +693,13 @@ func (ops *vsphereOps) Snapshot(volumeID string, readonly bool, options map[stri
 	}
 }
 
+// SnapshotWithOptions snapshots the volume with given volumeID
+func (ops *vsphereOps) SnapshotWithOptions(volumeID string, readonly bool, opts cloudops.SnapshotOptions) (interface{}, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "SnapshotWithOptions",
+	}
+}
+
 // SnapshotDelete deletes the snapshot with given ID
 func (ops *vsphereOps) SnapshotDelete(snapID string, options map[string]string) error {
 	return &cloudops.ErrNotSupported{
@@ -656,24 +707,537 @@ func (ops *vsphereOps) SnapshotDelete(snapID string, options map[string]string)
 	}
 }
 
-// ApplyTags will apply given labels/tags on the given volume
+// ApplyTags will apply given labels/tags on the given volume. Each label is a
+// vCenter tag named after its value, in the category named after its key,
+// attached to the First Class Disk of the volume. A tag attached to the disk
+// in the category of a label is replaced. The categories and tags must exist
+// in vCenter as they cannot be created through the vSphere API, an
+// ErrNotFound naming the category and tag is returned if they do not.
 func (ops *vsphereOps) ApplyTags(volumeID string, labels map[string]string, options map[string]string) error {
-	return &cloudops.ErrNotSupported{
-		Operation: "ApplyTags",
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return err
+	}
+
+	if len(labels) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m, fcdID, err := ops.firstClassDisk(ctx, volumeID, "ApplyTags")
+	if err != nil {
+		return err
+	}
+
+	attached, err := m.ListAttachedTags(ctx, fcdID)
+	if err != nil {
+		return tagError(err, "ApplyTags")
+	}
+
+	detach, attach, err := tagChanges(attached, labels)
+	if err != nil {
+		return err
 	}
+
+	for _, tag := range detach {
+		if err := m.DetachTag(ctx, fcdID, tag); err != nil {
+			return tagError(err, "ApplyTags")
+		}
+	}
+	for _, tag := range attach {
+		if err := m.AttachTag(ctx, fcdID, tag); err != nil {
+			return attachTagError(err, tag)
+		}
+	}
+	return nil
 }
 
-// RemoveTags removes labels/tags from the given volume
+// RemoveTags removes labels/tags from the given volume. The tags attached to
+// the First Class Disk of the volume in the categories named after the label
+// keys are detached.
 func (ops *vsphereOps) RemoveTags(volumeID string, labels map[string]string, options map[string]string) error {
-	return &cloudops.ErrNotSupported{
-		Operation: "RemoveTags",
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return err
+	}
+
+	if len(labels) == 0 {
+		return nil
 	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m, fcdID, err := ops.firstClassDisk(ctx, volumeID, "RemoveTags")
+	if err != nil {
+		return err
+	}
+
+	attached, err := m.ListAttachedTags(ctx, fcdID)
+	if err != nil {
+		return tagError(err, "RemoveTags")
+	}
+
+	for _, tag := range attached {
+		if _, ok := labels[tag.ParentCategoryName]; !ok {
+			continue
+		}
+		if err := m.DetachTag(ctx, fcdID, tag); err != nil {
+			return tagError(err, "RemoveTags")
+		}
+	}
+	return nil
 }
 
-// Tags will list the existing labels/tags on the given volume
+// Tags will list the existing labels/tags on the given volume. The tags
+// attached to the First Class Disk of the volume are returned keyed by their
+// category.
 func (ops *vsphereOps) Tags(volumeID string) (map[string]string, error) {
+	if err := cloudops.ValidateVolumeID(volumeID); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m, fcdID, err := ops.firstClassDisk(ctx, volumeID, "Tags")
+	if err != nil {
+		return nil, err
+	}
+
+	attached, err := m.ListAttachedTags(ctx, fcdID)
+	if err != nil {
+		return nil, tagError(err, "Tags")
+	}
+	return tagsToLabels(attached), nil
+}
+
+// firstClassDisk returns the object manager of First Class Disks along with
+// the ID of the First Class Disk backed by the given vmdk. ErrNotSupported is
+// returned if the vCenter or the disk does not support First Class Disks.
+func (ops *vsphereOps) firstClassDisk(ctx context.Context, diskPath, operation string) (*vslm.ObjectManager, string, error) {
+	vmObj, err := ops.renewVM(ctx, ops.vm)
+	if err != nil {
+		return nil, "", err
+	}
+
+	about := vmObj.Client().ServiceContent.About
+	apiVersion, err := version.NewVersion(about.ApiVersion)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to detect vSphere API version due to: %v", err)
+	}
+
+	fcdVersion, err := version.NewVersion(keepAfterDeleteVMApiVersion)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse vSphere API version that supports first class disks due to: %v", err)
+	}
+
+	if apiVersion.LessThan(fcdVersion) {
+		return nil, "", &cloudops.ErrNotSupported{
+			Operation: operation,
+			Reason:    fmt.Sprintf("tagging disks is not supported for version less than %s", fcdVersion),
+		}
+	}
+
+	ds, err := vmObj.Datacenter.GetDatastoreByPath(ctx, diskPath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	m := vslm.NewObjectManager(vmObj.Client())
+	if id, ok := ops.cachedFirstClassDisk(diskPath); ok {
+		// the disk may have been deleted and its path reused since
+		obj, err := m.Retrieve(ctx, ds, id)
+		if err == nil && fcdFilePath(obj) == diskPath {
+			return m, id, nil
+		}
+		ops.cacheFirstClassDisk(diskPath, "")
+	}
+
+	ids, err := m.List(ctx, ds)
+	if err != nil {
+		return nil, "", tagError(err, operation)
+	}
+
+	for _, id := range ids {
+		obj, err := m.Retrieve(ctx, ds, id.Id)
+		if err != nil {
+			return nil, "", tagError(err, operation)
+		}
+		if fcdFilePath(obj) == diskPath {
+			ops.cacheFirstClassDisk(diskPath, id.Id)
+			return m, id.Id, nil
+		}
+	}
+
+	return nil, "", &cloudops.ErrNotSupported{
+		Operation: operation,
+		Reason:    fmt.Sprintf("disk %s is not a first class disk", diskPath),
+	}
+}
+
+// cachedFirstClassDisk returns the cached ID of the First Class Disk backed by
+// the given vmdk
+func (ops *vsphereOps) cachedFirstClassDisk(diskPath string) (string, bool) {
+	ops.fcdIDsLock.Lock()
+	defer ops.fcdIDsLock.Unlock()
+	id, ok := ops.fcdIDs[diskPath]
+	return id, ok
+}
+
+// cacheFirstClassDisk caches the ID of the First Class Disk backed by the
+// given vmdk, an empty ID removes it from the cache
+func (ops *vsphereOps) cacheFirstClassDisk(diskPath, id string) {
+	ops.fcdIDsLock.Lock()
+	defer ops.fcdIDsLock.Unlock()
+	if len(id) == 0 {
+		delete(ops.fcdIDs, diskPath)
+		return
+	}
+	if ops.fcdIDs == nil {
+		ops.fcdIDs = make(map[string]string)
+	}
+	ops.fcdIDs[diskPath] = id
+}
+
+// fcdFilePath returns the path of the vmdk backing the First Class Disk
+func fcdFilePath(obj *types.VStorageObject) string {
+	if fileInfo, ok := obj.Config.Backing.(*types.BaseConfigInfoDiskFileBackingInfo); ok {
+		return fileInfo.FilePath
+	}
+	return ""
+}
+
+// tagChanges returns the tags to detach from and attach to a First Class Disk
+// with the given tags attached, for it to have the given labels
+func tagChanges(attached []types.VslmTagEntry, labels map[string]string) ([]types.VslmTagEntry, []types.VslmTagEntry, error) {
+	keys := make([]string, 0, len(labels))
+	for k, v := range labels {
+		if len(v) == 0 {
+			return nil, nil, cloudops.NewStorageError(cloudops.ErrVolInval,
+				fmt.Sprintf("label %s has no value to use as the tag name", k), "")
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var detach, attach []types.VslmTagEntry
+	current := make(map[string]bool)
+	for _, tag := range attached {
+		value, ok := labels[tag.ParentCategoryName]
+		if !ok {
+			continue
+		}
+		if tag.TagName == value {
+			current[tag.ParentCategoryName] = true
+			continue
+		}
+		detach = append(detach, tag)
+	}
+	for _, k := range keys {
+		if !current[k] {
+			attach = append(attach, types.VslmTagEntry{ParentCategoryName: k, TagName: labels[k]})
+		}
+	}
+	return detach, attach, nil
+}
+
+// tagsToLabels returns the given tags as labels keyed by their category
+func tagsToLabels(tags []types.VslmTagEntry) map[string]string {
+	labels := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		labels[tag.ParentCategoryName] = tag.TagName
+	}
+	return labels
+}
+
+// tagError returns ErrNotSupported if err reports that the vCenter does not
+// support tagging First Class Disks, else err as is
+func tagError(err error, operation string) error {
+	if !soap.IsSoapFault(err) {
+		return err
+	}
+	switch soap.ToSoapFault(err).VimFault().(type) {
+	case types.NotSupported, types.NotImplemented, types.MethodNotFound:
+		return &cloudops.ErrNotSupported{
+			Operation: operation,
+			Reason:    err.Error(),
+		}
+	}
+	return err
+}
+
+// attachTagError returns an ErrNotFound naming the category and name of the
+// tag if err reports that the tag does not exist in vCenter, else the error
+// returned by tagError
+func attachTagError(err error, tag types.VslmTagEntry) error {
+	if soap.IsSoapFault(err) {
+		if _, ok := soap.ToSoapFault(err).VimFault().(types.NotFound); ok {
+			return &cloudops.ErrNotFound{
+				Type: "Tag",
+				ID:   fmt.Sprintf("%s in category %s", tag.TagName, tag.ParentCategoryName),
+			}
+		}
+	}
+	return tagError(err, "ApplyTags")
+}
+
+// ReconcileDataDisks removes stale data disk entries from the given instance
+func (ops *vsphereOps) ReconcileDataDisks(instanceID string) ([]string, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "ReconcileDataDisks",
+	}
+}
+
+// GetEffectivePerformance returns the IOPS and throughput provisioned for the given volume
+func (ops *vsphereOps) GetEffectivePerformance(volumeID string) (uint64, uint64, error) {
+	return 0, 0, &cloudops.ErrNotSupported{
+		Operation: "GetEffectivePerformance",
+	}
+}
+
+// ConfigureReplication configures replication of the given volume to the target region
+func (ops *vsphereOps) ConfigureReplication(volumeID, targetRegion string, options map[string]string) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "ConfigureReplication",
+	}
+}
+
+// StopReplication stops the replication of the given volume
+func (ops *vsphereOps) StopReplication(volumeID string) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "StopReplication",
+	}
+}
+
+// EnumerateSnapshots returns the snapshots of the given volumes that match the given labels
+func (ops *vsphereOps) EnumerateSnapshots(volumeIDs []*string, labels map[string]string) ([]*cloudops.SnapshotInfo, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "EnumerateSnapshots",
+	}
+}
+
+// ListSnapshots returns the snapshots that match the given labels
+func (ops *vsphereOps) ListSnapshots(labels map[string]string) ([]*cloudops.SnapshotInfo, error) {
+	return ops.EnumerateSnapshots(nil, labels)
+}
+
+// InspectSnapshot returns the snapshot with given ID
+func (ops *vsphereOps) InspectSnapshot(snapID string) (*cloudops.SnapshotInfo, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "InspectSnapshot",
+	}
+}
+
+// ReportCapacityByLabel returns the provisioned capacity of the disks grouped by the given label
+func (ops *vsphereOps) ReportCapacityByLabel(labelKey string) (map[string]uint64, error) {
 	return nil, &cloudops.ErrNotSupported{
-		Operation: "Tags",
+		Operation: "ReportCapacityByLabel",
+	}
+}
+
+// UpdateAttachmentCaching changes the caching mode of the given disk attached to the given instance
+func (ops *vsphereOps) UpdateAttachmentCaching(instanceID, diskName, caching string) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "UpdateAttachmentCaching",
+	}
+}
+
+// GetVolumeQoS returns the IOPS and throughput limits enforced on the given volume
+func (ops *vsphereOps) GetVolumeQoS(volumeID string) (uint64, uint64, error) {
+	return 0, 0, &cloudops.ErrNotSupported{
+		Operation: "GetVolumeQoS",
+	}
+}
+
+// ExpandMany expands the given volumes to the new size
+func (ops *vsphereOps) ExpandMany(volumeIDs []string, newSizeInGiB uint64, options map[string]string) (map[string]uint64, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "ExpandMany",
+	}
+}
+
+// LockVolume locks the given volume for the given owner
+func (ops *vsphereOps) LockVolume(volumeID, owner string) (bool, error) {
+	return false, &cloudops.ErrNotSupported{
+		Operation: "LockVolume",
+	}
+}
+
+// UnlockVolume releases the lock the given owner holds on the volume
+func (ops *vsphereOps) UnlockVolume(volumeID, owner string) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "UnlockVolume",
+	}
+}
+
+// GetStorageLayout returns the specs of the volumes attached to the given instance
+func (ops *vsphereOps) GetStorageLayout(instanceID string) ([]cloudops.VolumeSpec, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "GetStorageLayout",
+	}
+}
+
+// ProvisionStorageLayout creates a volume matching each of the given specs
+func (ops *vsphereOps) ProvisionStorageLayout(specs []cloudops.VolumeSpec, labels map[string]string) ([]interface{}, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "ProvisionStorageLayout",
+	}
+}
+
+// GetVolumeLineage returns the source the given volume was created from
+func (ops *vsphereOps) GetVolumeLineage(volumeID string) (string, string, error) {
+	return "", "", &cloudops.ErrNotSupported{
+		Operation: "GetVolumeLineage",
+	}
+}
+
+// BatchInspect returns the given volumes keyed by volume ID
+func (ops *vsphereOps) BatchInspect(volumeIds []*string) (map[string]interface{}, error) {
+	return cloudops.InspectVolumes(ops, volumeIds)
+}
+
+// AdoptVolume brings the given volume under cloudops management
+func (ops *vsphereOps) AdoptVolume(volumeID string, labels map[string]string) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "AdoptVolume",
+	}
+}
+
+// DeleteSnapshotChain deletes the given snapshots in dependency order
+func (ops *vsphereOps) DeleteSnapshotChain(snapIDs []string) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "DeleteSnapshotChain",
+	}
+}
+
+// GetStoragePressure returns the capacity of the virtual disks attached to the
+// given VM along with the space their vmdk files consume on the datastore
+func (ops *vsphereOps) GetStoragePressure(instanceID string) ([]cloudops.VolumePressureInfo, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var vmObj *vclib.VirtualMachine
+	var err error
+	if instanceID == ops.cfg.VMUUID {
+		vmObj, err = ops.renewVM(ctx, ops.vm)
+	} else {
+		vmObj, err = GetVMObject(ctx, ops.conn, instanceID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var o mo.VirtualMachine
+	err = vmObj.Properties(ctx, vmObj.Reference(), []string{configProperty, layoutExProperty}, &o)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get disks of vm: %s. err: %v", vmObj.Name(), err)
+	}
+	if o.Config == nil {
+		return nil, fmt.Errorf("failed to get disks of vm: %s. err: vm has no config", vmObj.Name())
+	}
+
+	return diskPressure(o.Config.Hardware.Device, o.LayoutEx), nil
+}
+
+// diskPressure returns the pressure info of the virtual disks in devices. The
+// consumed size of a disk is the size of all the files in its chain as listed
+// by the file layout of the VM.
+func diskPressure(devices []types.BaseVirtualDevice, layout *types.VirtualMachineFileLayoutEx) []cloudops.VolumePressureInfo {
+	fileSizes := make(map[int32]int64)
+	diskFiles := make(map[int32][]int32)
+	if layout != nil {
+		for _, file := range layout.File {
+			fileSizes[file.Key] = file.Size
+		}
+		for _, disk := range layout.Disk {
+			for _, unit := range disk.Chain {
+				diskFiles[disk.Key] = append(diskFiles[disk.Key], unit.FileKey...)
+			}
+		}
+	}
+
+	pressure := []cloudops.VolumePressureInfo{}
+	for _, device := range devices {
+		disk, ok := device.(*types.VirtualDisk)
+		if !ok {
+			continue
+		}
+		backing, ok := disk.Backing.(*types.VirtualDiskFlatVer2BackingInfo)
+		if !ok {
+			continue
+		}
+
+		info := cloudops.VolumePressureInfo{
+			VolumeID:         backing.FileName,
+			ProvisionedBytes: uint64(disk.CapacityInBytes),
+			ThinProvisioned:  backing.ThinProvisioned != nil && *backing.ThinProvisioned,
+		}
+		if info.ProvisionedBytes == 0 {
+			info.ProvisionedBytes = uint64(disk.CapacityInKB) * units.KB
+		}
+		if fileKeys, ok := diskFiles[disk.Key]; ok {
+			var consumed uint64
+			for _, key := range fileKeys {
+				consumed += uint64(fileSizes[key])
+			}
+			info.ConsumedBytes = &consumed
+		}
+		pressure = append(pressure, info)
+	}
+	return pressure
+}
+
+func (ops *vsphereOps) CheckVolumeQuota(required []*cloudops.StoragePoolSpec) error {
+	return &cloudops.ErrNotSupported{
+		Operation: "CheckVolumeQuota",
+	}
+}
+
+func (ops *vsphereOps) ListVolumesPaged(labels map[string]string, pageSize int) (cloudops.VolumeIterator, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "ListVolumesPaged",
+	}
+}
+
+func (ops *vsphereOps) AttachByInstanceID(instanceID, volumeID string, options map[string]string) (string, error) {
+	return "", &cloudops.ErrNotSupported{
+		Operation: "AttachByInstanceID",
+	}
+}
+
+func (ops *vsphereOps) DeleteVolumes(volumeIDs []string) (map[string]error, error) {
+	return nil, &cloudops.ErrNotSupported{
+		Operation: "DeleteVolumes",
+	}
+}
+
+// setThinProvisioning sets the disk format of the volume to thin if thin
+// provisioning is requested in the given options
+func setThinProvisioning(volumeOptions *vclib.VolumeOptions, options map[string]string) error {
+	thin, err := cloudops.ThinProvisioningRequested(options)
+	if err != nil {
+		return err
+	}
+	if thin {
+		volumeOptions.DiskFormat = vclib.ThinDiskType
+	}
+	return nil
+}
+
+// diskProvisioningType returns the vSphere provisioning type for the given disk format
+func diskProvisioningType(diskFormat string) string {
+	switch diskFormat {
+	case vclib.LazyZeroedThickDiskType:
+		return string(types.BaseConfigInfoDiskFileBackingInfoProvisioningTypeLazyZeroedThick)
+	case vclib.ThinDiskType:
+		return string(types.BaseConfigInfoDiskFileBackingInfoProvisioningTypeThin)
+	case vclib.ZeroedThickDiskType:
+		fallthrough
+	case vclib.EagerZeroedThickDiskType:
+		fallthrough
+	default:
+		return string(types.BaseConfigInfoDiskFileBackingInfoProvisioningTypeEagerZeroedThick)
 	}
 }
 
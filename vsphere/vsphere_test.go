@@ -2,15 +2,19 @@ package vsphere
 
 import (
 	"fmt"
+	"strconv"
 	"testing"
 
 	"github.com/libopenstorage/cloudops"
+	"github.com/libopenstorage/cloudops/pkg/storagedistribution"
 	"github.com/libopenstorage/cloudops/test"
 	"github.com/libopenstorage/cloudops/vsphere/lib/vsphere/vclib"
 	"github.com/libopenstorage/cloudops/store"
 	"github.com/pborman/uuid"
 	"github.com/stretchr/testify/require"
 	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
 )
 
 const (
@@ -97,3 +101,181 @@ func TestDevicePath(t *testing.T) {
 	}
 
 }
+
+func TestThinProvisioningFromDecisionMatrix(t *testing.T) {
+	decisionMatrix := &cloudops.StorageDecisionMatrix{
+		Rows: []cloudops.StorageDecisionMatrixRow{
+			{
+				InstanceType:      "*",
+				InstanceMinDrives: 1,
+				InstanceMaxDrives: 12,
+				Region:            "*",
+				MinSize:           32,
+				MaxSize:           1024,
+				ThinProvisioning:  true,
+				DriveType:         vclib.ThinDiskType,
+			},
+		},
+	}
+
+	spec, _, _, err := storagedistribution.GetStorageDistributionForPool(decisionMatrix,
+		&cloudops.StorageSpec{MinCapacity: 1024, MaxCapacity: 2048}, 1, 1)
+	require.NoError(t, err)
+	require.True(t, spec.ThinProvisioning)
+
+	// the disk format of the template is overridden by the matrix row
+	volumeOptions := &vclib.VolumeOptions{DiskFormat: vclib.EagerZeroedThickDiskType}
+	err = setThinProvisioning(volumeOptions, map[string]string{
+		cloudops.ThinProvisioningOption: strconv.FormatBool(spec.ThinProvisioning),
+	})
+	require.NoError(t, err)
+	require.Equal(t, vclib.ThinDiskType, volumeOptions.DiskFormat)
+	require.Equal(t, string(types.BaseConfigInfoDiskFileBackingInfoProvisioningTypeThin),
+		diskProvisioningType(volumeOptions.DiskFormat))
+
+	volumeOptions = &vclib.VolumeOptions{DiskFormat: vclib.EagerZeroedThickDiskType}
+	require.NoError(t, setThinProvisioning(volumeOptions, nil))
+	require.Equal(t, string(types.BaseConfigInfoDiskFileBackingInfoProvisioningTypeEagerZeroedThick),
+		diskProvisioningType(volumeOptions.DiskFormat))
+
+	require.Error(t, setThinProvisioning(volumeOptions, map[string]string{
+		cloudops.ThinProvisioningOption: "maybe",
+	}))
+}
+
+func TestDiskPressure(t *testing.T) {
+	const thinDisk = "[datastore] disks/thin.vmdk"
+	devices := object.VirtualDeviceList{
+		&types.VirtualDisk{
+			VirtualDevice: types.VirtualDevice{
+				Key: 2000,
+				Backing: &types.VirtualDiskFlatVer2BackingInfo{
+					VirtualDeviceFileBackingInfo: types.VirtualDeviceFileBackingInfo{FileName: thinDisk},
+					ThinProvisioned:              types.NewBool(true),
+				},
+			},
+			CapacityInKB: 10 * 1024 * 1024,
+		},
+		&types.VirtualDisk{
+			VirtualDevice: types.VirtualDevice{
+				Key: 2001,
+				Backing: &types.VirtualDiskFlatVer2BackingInfo{
+					VirtualDeviceFileBackingInfo: types.VirtualDeviceFileBackingInfo{FileName: "[datastore] disks/thick.vmdk"},
+				},
+			},
+			CapacityInBytes: 1 << 30,
+		},
+		&types.VirtualCdrom{},
+	}
+	layout := &types.VirtualMachineFileLayoutEx{
+		File: []types.VirtualMachineFileLayoutExFileInfo{
+			{Key: 1, Name: "[datastore] disks/thin.vmdk", Size: 512},
+			{Key: 2, Name: "[datastore] disks/thin-flat.vmdk", Size: 3 << 30},
+		},
+		Disk: []types.VirtualMachineFileLayoutExDiskLayout{
+			{Key: 2000, Chain: []types.VirtualMachineFileLayoutExDiskUnit{{FileKey: []int32{1, 2}}}},
+		},
+	}
+
+	pressure := diskPressure(devices, layout)
+	require.Len(t, pressure, 2)
+
+	require.Equal(t, thinDisk, pressure[0].VolumeID)
+	require.True(t, pressure[0].ThinProvisioned)
+	require.Equal(t, uint64(10<<30), pressure[0].ProvisionedBytes)
+	require.NotNil(t, pressure[0].ConsumedBytes)
+	require.Equal(t, uint64(3<<30+512), *pressure[0].ConsumedBytes)
+
+	require.False(t, pressure[1].ThinProvisioned)
+	require.Equal(t, uint64(1<<30), pressure[1].ProvisionedBytes)
+	require.Nil(t, pressure[1].ConsumedBytes)
+}
+
+func TestTagChanges(t *testing.T) {
+	attached := []types.VslmTagEntry{
+		{ParentCategoryName: "app", TagName: "db"},
+		{ParentCategoryName: "set", TagName: "a"},
+		{ParentCategoryName: "other", TagName: "x"},
+	}
+
+	detach, attach, err := tagChanges(attached, map[string]string{
+		"app":  "db",
+		"set":  "b",
+		"zone": "z1",
+	})
+	require.NoError(t, err)
+	require.Equal(t, []types.VslmTagEntry{{ParentCategoryName: "set", TagName: "a"}}, detach)
+	require.Equal(t, []types.VslmTagEntry{
+		{ParentCategoryName: "set", TagName: "b"},
+		{ParentCategoryName: "zone", TagName: "z1"},
+	}, attach)
+
+	_, _, err = tagChanges(attached, map[string]string{"app": ""})
+	se, ok := err.(*cloudops.StorageError)
+	require.True(t, ok, "expected a storage error, got %v", err)
+	require.Equal(t, cloudops.ErrVolInval, se.Code)
+
+	require.Equal(t, map[string]string{"app": "db", "set": "a", "other": "x"}, tagsToLabels(attached))
+}
+
+func TestTagError(t *testing.T) {
+	err := tagError(soap.WrapSoapFault(&soap.Fault{
+		Detail: struct {
+			Fault types.AnyType `xml:",any,typeattr"`
+		}{Fault: types.NotSupported{}},
+	}), "Tags")
+	_, ok := err.(*cloudops.ErrNotSupported)
+	require.True(t, ok, "expected ErrNotSupported, got %v", err)
+
+	notFound := soap.WrapSoapFault(&soap.Fault{
+		Detail: struct {
+			Fault types.AnyType `xml:",any,typeattr"`
+		}{Fault: types.NotFound{}},
+	})
+	require.Equal(t, notFound, tagError(notFound, "Tags"))
+}
+
+func TestAttachTagError(t *testing.T) {
+	tag := types.VslmTagEntry{ParentCategoryName: "app", TagName: "db"}
+	err := attachTagError(soap.WrapSoapFault(&soap.Fault{
+		Detail: struct {
+			Fault types.AnyType `xml:",any,typeattr"`
+		}{Fault: types.NotFound{}},
+	}), tag)
+	notFound, ok := err.(*cloudops.ErrNotFound)
+	require.True(t, ok, "expected ErrNotFound, got %v", err)
+	require.Contains(t, notFound.ID, "category app")
+
+	err = attachTagError(soap.WrapSoapFault(&soap.Fault{
+		Detail: struct {
+			Fault types.AnyType `xml:",any,typeattr"`
+		}{Fault: types.NotSupported{}},
+	}), tag)
+	_, ok = err.(*cloudops.ErrNotSupported)
+	require.True(t, ok, "expected ErrNotSupported, got %v", err)
+}
+
+func TestFirstClassDiskCache(t *testing.T) {
+	ops := &vsphereOps{}
+	_, ok := ops.cachedFirstClassDisk("[ds] disk.vmdk")
+	require.False(t, ok)
+
+	ops.cacheFirstClassDisk("[ds] disk.vmdk", "fcd-1")
+	id, ok := ops.cachedFirstClassDisk("[ds] disk.vmdk")
+	require.True(t, ok)
+	require.Equal(t, "fcd-1", id)
+
+	ops.cacheFirstClassDisk("[ds] disk.vmdk", "")
+	_, ok = ops.cachedFirstClassDisk("[ds] disk.vmdk")
+	require.False(t, ok)
+
+	require.Equal(t, "[ds] disk.vmdk", fcdFilePath(&types.VStorageObject{
+		Config: types.VStorageObjectConfigInfo{
+			BaseConfigInfo: types.BaseConfigInfo{
+				Backing: &types.BaseConfigInfoDiskFileBackingInfo{
+					BaseConfigInfoFileBackingInfo: types.BaseConfigInfoFileBackingInfo{FilePath: "[ds] disk.vmdk"},
+				},
+			},
+		},
+	}))
+}